@@ -7,50 +7,241 @@ import (
 	"net"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/encoding/gzip"
 	"google.golang.org/grpc/health"
 	"google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/keepalive"
 	"google.golang.org/grpc/reflection"
 
+	"github.com/dasmlab/iskoces/pkg/audit"
+	"github.com/dasmlab/iskoces/pkg/logging"
+	longrunningpb "github.com/dasmlab/iskoces/pkg/proto/google/longrunning"
 	"github.com/dasmlab/iskoces/pkg/proto/v1"
+	nanabushv2 "github.com/dasmlab/iskoces/pkg/proto/v2"
 	"github.com/dasmlab/iskoces/pkg/server"
 	"github.com/dasmlab/iskoces/pkg/service"
+	"github.com/dasmlab/iskoces/pkg/tracing"
 	"github.com/dasmlab/iskoces/pkg/translate"
 	"github.com/sirupsen/logrus"
 )
 
+// version is the server build version, set at build time with
+// -ldflags "-X main.version=...".
+var version = "dev"
+
 var (
 	// Server configuration flags
-	port         = flag.Int("port", 50051, "gRPC server port")
-	insecureMode = flag.Bool("insecure", true, "Run server in insecure mode (no TLS)")
+	port             = flag.Int("port", 50051, "gRPC server port")
+	insecureMode     = flag.Bool("insecure", true, "Run server in insecure mode (no TLS)")
+	httpPort         = flag.Int("http-port", 8080, "HTTP port for job status, job events, results and worker state (0 = no HTTP server)")
+	singlePort       = flag.Bool("single-port", false, "Serve gRPC, gRPC-Web and the HTTP API together on -port, for ingresses allowing one service port (-http-port is ignored; without TLS, gRPC clients connect with h2c)")
+	httpReadTimeout  = flag.Duration("http-read-timeout", server.DefaultReadTimeout, "Maximum time for the HTTP server to read a request, body included")
+	httpWriteTimeout = flag.Duration("http-write-timeout", server.DefaultWriteTimeout, "Maximum time for an HTTP response, covering synchronous translations (event streams and WebSockets are exempt)")
+	httpIdleTimeout  = flag.Duration("http-idle-timeout", server.DefaultIdleTimeout, "How long an idle HTTP keep-alive connection is kept open")
+	httpSSEKeepalive = flag.Duration("http-sse-keepalive", server.DefaultSSEKeepalive, "Send a keepalive comment on job event streams idle this long, so proxies and ingresses don't drop them")
+	asyncEnabled     = flag.Bool("async-enabled", true, "Accept async translation jobs (SubmitTranslation, SubmitBatch, schedules); when false, large documents are translated synchronously by Translate")
+
+	// HTTP request limits
+	httpMaxBodyBytes      = flag.Int64("http-max-body-bytes", 0, "Maximum HTTP request body size in bytes (0 = the gRPC receive limit, see -max-recv-message-bytes)")
+	httpRateLimitIP       = flag.Int("http-rate-limit-ip", 0, "HTTP requests a client IP may make per minute (0 = unlimited; probes and /metrics are exempt)")
+	httpRateLimitKey      = flag.Int("http-rate-limit-key", 0, "HTTP requests an authenticated caller (API key or JWT subject) may make per minute (0 = unlimited)")
+	httpTrustForwardedFor = flag.Bool("http-trust-forwarded-for", false, "Rate limit by the last X-Forwarded-For address (only behind a proxy that sets it)")
 
 	// Translation engine configuration
-	mtEngine = flag.String("mt-engine", "libretranslate", "Translation engine: libretranslate or argos")
-	mtURL    = flag.String("mt-url", "http://localhost:5000", "Base URL for translation engine API")
+	mtEngine                  = flag.String("mt-engine", "libretranslate", "Translation engine: libretranslate or argos")
+	mtURL                     = flag.String("mt-url", "http://localhost:5000", "Base URL for translation engine API")
+	engineCostPerMillionChars = flag.Float64("engine-cost-per-million-chars", 0, "Price of translating a million characters with the engine, used to estimate async job costs per namespace (0 = not estimated)")
+
+	// Worker pool sizing and autoscaling
+	minWorkers        = flag.Int("min-workers", 4, "Minimum number of Python translation workers kept running")
+	maxWorkers        = flag.Int("max-workers", 4, "Maximum number of Python translation workers (autoscaling when above -min-workers)")
+	scaleUpQueueWait  = flag.Duration("scale-up-queue-wait", translate.DefaultScaleUpQueueWait, "Add a worker when a request waited longer than this for one")
+	scaleUpBusyRatio  = flag.Float64("scale-up-busy-ratio", translate.DefaultScaleUpBusyRatio, "Add a worker when at least this fraction of workers is busy")
+	workerMaxInFlight = flag.Int("worker-max-in-flight", translate.DefaultMaxInFlightPerWorker, "Requests outstanding per worker; the worker batches queued requests for the same language pair")
+	workerShutdownTimeout = flag.Duration("worker-shutdown-timeout", translate.DefaultWorkerShutdownTimeout, "On shutdown, how long to wait for translations in flight before stopping the workers")
+	pinnedWorkers     = flag.String("pinned-workers", "", "Dedicate workers to language pairs, keeping their models loaded, e.g. en:fr=2,fr:en (count defaults to 1)")
+	pythonPath           = flag.String("python", translate.DefaultPythonPath, "Python interpreter used to run translation workers")
+	workerScript         = flag.String("worker-script", translate.DefaultWorkerScriptPath, "Path to the translation worker script (scripts/translate_worker.py)")
+	workerSocketDir      = flag.String("worker-socket-dir", translate.DefaultWorkerSocketDir, "Directory for the workers' Unix sockets")
+	workerRequestTimeout = flag.Duration("worker-request-timeout", translate.DefaultWorkerRequestTimeout, "Maximum time for one request to a worker")
+	workerReadyTimeout   = flag.Duration("worker-ready-timeout", translate.DefaultWorkerReadyTimeout, "Maximum time for a new worker to load and report ready")
+	workerQueueTimeout   = flag.Duration("worker-queue-timeout", translate.DefaultQueueTimeout, "Maximum time a request waits for a free worker")
+	workerQueueCapacity  = flag.Int("worker-queue-capacity", 0, "Maximum requests waiting for a worker; further requests are rejected with RESOURCE_EXHAUSTED (0 = unbounded)")
+	overloadThreshold    = flag.Duration("worker-overload-threshold", translate.DefaultOverloadThreshold, "Reject a request with RESOURCE_EXHAUSTED after waiting this long for a worker if it would time out anyway (negative disables)")
+	workerMaxRSSMB       = flag.Int64("worker-max-rss-mb", 0, "Recycle a worker whose resident memory exceeds this many MiB (0 = no limit)")
+	workerMaxRequests    = flag.Int("worker-max-requests", 0, "Recycle a worker after it has served this many requests (0 = no limit)")
+	interactiveWorkers   = flag.Int("interactive-workers", 1, "Workers reserved for high-priority requests such as titles (at most -min-workers minus 1)")
+	workerTransport      = flag.String("worker-transport", translate.WorkerTransportJSON, "Worker protocol over the Unix sockets: json or grpc (WorkerService in proto/worker.proto; needs grpcio in the worker image)")
+	workerUID            = flag.Int("worker-uid", 0, "Run translation workers as this user ID (0 = the server's user; needs the server to run as root)")
+	workerGID            = flag.Int("worker-gid", 0, "Run translation workers with this group ID (0 = the server's group)")
+	workerCgroup         = flag.String("worker-cgroup", "", "Cgroup directory each worker is moved into, e.g. /sys/fs/cgroup/iskoces-workers")
+	workerMaxASMB        = flag.Int64("worker-max-address-space-mb", 0, "Per-worker address space limit in MiB (RLIMIT_AS, 0 = no limit)")
+	workerMaxFileSizeMB  = flag.Int64("worker-max-file-size-mb", 0, "Largest file a worker may write in MiB (RLIMIT_FSIZE, 0 = no limit)")
+	workerMaxOpenFiles   = flag.Int("worker-max-open-files", 0, "Per-worker open file limit (RLIMIT_NOFILE, 0 = no limit)")
+	workerMaxProcesses   = flag.Int("worker-max-processes", 0, "Process limit for the worker user (RLIMIT_NPROC, 0 = no limit)")
+	workerReadOnly       = flag.Bool("worker-read-only", false, "Refuse file writes from workers outside the socket directory and -worker-writable-dirs")
+	workerWritableDirs   = flag.String("worker-writable-dirs", "", "Comma-separated directories workers may write to with -worker-read-only")
+	remoteWorkers        = flag.String("remote-workers", "", "Comma-separated host:port addresses of workers on other nodes, reached over TCP with mutual TLS")
+	remoteWorkerCert     = flag.String("remote-worker-cert", "", "Client certificate presented to remote workers")
+	remoteWorkerKey      = flag.String("remote-worker-key", "", "Private key for -remote-worker-cert")
+	remoteWorkerCA       = flag.String("remote-worker-ca", "", "CA certificate that signs the remote workers' certificates")
+	remoteWorkerServerName = flag.String("remote-worker-server-name", "", "Name checked against remote workers' certificates (default: each address's host)")
+	workerIdleTimeout = flag.Duration("worker-idle-timeout", translate.DefaultWorkerIdleTimeout, "Stop workers above -min-workers after being idle this long")
+
+	// TLS configuration flags
+	tlsCertPath       = flag.String("tls-cert", "", "Path to the gRPC server's TLS certificate (required with -insecure=false)")
+	tlsKeyPath        = flag.String("tls-key", "", "Path to the gRPC server's TLS private key")
+	tlsCAPath         = flag.String("tls-ca", "", "Path to CA certificate for client verification (mTLS)")
+	tlsReloadInterval = flag.Duration("tls-reload-interval", server.DefaultCertReloadInterval, "How often TLS certificate, key and CA files are checked for changes, so rotated certificates are used without a restart")
+	httpTLSCert       = flag.String("http-tls-cert", "", "Serve HTTPS, with HTTP/2, using this certificate (empty = plain HTTP)")
+	httpTLSKey        = flag.String("http-tls-key", "", "Private key for -http-tls-cert")
+	httpTLSClientCA   = flag.String("http-tls-client-ca", "", "Require HTTPS clients to present a certificate signed by this CA (mTLS)")
+	httpTLSClientCertOptional = flag.Bool("http-tls-client-cert-optional", false, "With -http-tls-client-ca, also accept HTTPS clients without a certificate, e.g. probes (certificates presented are still verified)")
+	httpH2C           = flag.Bool("http-h2c", false, "Accept HTTP/2 without TLS (h2c), e.g. from a proxy that terminates TLS (ignored with -http-tls-cert)")
+
+	// Request limits
+	maxDocumentBytes = flag.Int("max-document-bytes", service.DefaultMaxDocumentBytes, "Maximum document (markdown) size in bytes")
+	maxTitleLength   = flag.Int("max-title-length", service.DefaultMaxTitleLength, "Maximum title length in characters")
+	maxRecvMsgBytes  = flag.Int("max-recv-message-bytes", 0, "Maximum gRPC message size received (0 = max-document-bytes plus 64KB headroom)")
+	maxSendMsgBytes  = flag.Int("max-send-message-bytes", 0, "Maximum gRPC message size sent (0 = unlimited)")
+	chunkParallelism = flag.Int("chunk-parallelism", service.DefaultChunkParallelism, "Chunks of a large document translated at once (bounded by the worker pool size)")
+
+	// Job scheduling
+	maxConcurrentJobs   = flag.Int("max-concurrent-jobs", service.DefaultMaxConcurrentJobs, "Async jobs processed at once; further jobs wait their turn (0 = unlimited)")
+	maxNamespaceJobs    = flag.Int("max-concurrent-jobs-per-namespace", 0, "Async jobs one namespace may process at once, so one tenant can't hold every slot (0 = unlimited)")
+	jobOrder            = flag.String("job-order", string(service.JobOrderFair), "Order waiting async jobs start in: fair (higher priority first, namespaces taking turns, then oldest), priority (higher priority first, then oldest) or fifo")
+	jobPriorityAging    = flag.Duration("job-priority-aging", service.DefaultPriorityAging, "With -job-order fair or priority, how long a waiting async job takes to gain a priority level, so bulk jobs aren't starved (0 = no aging)")
+	jobNamespaceWeights = flag.String("job-namespace-weights", "", "With -job-order fair, comma-separated namespace=weight turns per round (e.g. team-a=3,team-b=2; default weight 1)")
+
+	// Job retries
+	jobMaxAttempts     = flag.Int("job-max-attempts", service.DefaultJobRetryPolicy.MaxAttempts, "Attempts per async job when the engine fails transiently (unavailable, overloaded, timeout); 1 disables retries")
+	jobRetryBackoff    = flag.Duration("job-retry-backoff", service.DefaultJobRetryPolicy.Backoff, "Delay before an async job's first retry; doubles with each retry")
+	jobRetryMaxBackoff = flag.Duration("job-retry-max-backoff", service.DefaultJobRetryPolicy.MaxBackoff, "Longest delay between an async job's attempts")
+	deadLetterRetention = flag.Duration("dead-letter-retention", service.DefaultDeadLetterRetention, "How long async jobs that exhausted their retries stay on the dead-letter list for re-queueing (0 = until re-queued or deleted)")
+
+	// Finished job retention
+	jobRetentionCompleted = flag.Duration("job-retention-completed", service.DefaultJobRetention.Completed, "How long completed async jobs are kept for status lookups, at least -result-ttl (0 = no limit)")
+	jobRetentionFailed    = flag.Duration("job-retention-failed", service.DefaultJobRetention.Failed, "How long failed async jobs are kept (0 = no limit)")
+	jobRetentionCancelled = flag.Duration("job-retention-cancelled", service.DefaultJobRetention.Cancelled, "How long cancelled async jobs are kept (0 = no limit)")
+	jobRetentionMaxJobs   = flag.Int("job-retention-max-jobs", 0, "Most finished async jobs kept; the oldest beyond this are removed early (0 = no limit)")
+
+	// Job deduplication
+	jobDedupWindow = flag.Duration("job-dedup-window", 0, "Reuse the result of an async job that completed this recently when the same content is submitted again for the same language pair, and link submissions of content already being translated to that job (0 = disabled; not with a Redis job store)")
+
+	// Require callers to register before translating
+	requireRegistration = flag.Bool("require-registration", false, "Reject Translate/TranslateStream/CheckTitle calls without a registered client_id")
+
+	// Client labels
+	labelSchemaFile = flag.String("label-schema", "", "Path to a JSON file describing allowed client labels and the routing/quota policy of each value")
+
+	// Feedback
+	feedbackFile = flag.String("feedback-file", "", "Path to a JSON lines file where translation feedback is stored (empty = memory only)")
+
+	// Async jobs
+	jobStoreFile        = flag.String("job-store", "", "Where async jobs are stored so unfinished jobs resume after a restart: bolt:// and a BoltDB file path, a JSON lines file path, or a redis:// or rediss:// URL to share the queue between replicas (empty = memory only)")
+	jobStoreConcurrency = flag.Int("job-store-concurrency", service.DefaultSharedJobConcurrency, "Jobs this replica processes at once from a shared (Redis) job queue")
+
+	// Job results
+	resultStoreLocation = flag.String("result-store", "", "Where large async job results are offloaded: a directory path, or an s3://bucket[/prefix][?endpoint=URL&region=REGION] URL for S3-compatible object storage, with credentials from AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY (empty = kept in memory)")
+	resultOffloadBytes  = flag.Int("result-offload-bytes", service.DefaultResultOffloadBytes, "Translated markdown of at least this many bytes is offloaded to the result store")
+	resultTTL           = flag.Duration("result-ttl", service.DefaultResultTTL, "How long completed job results are kept; finished jobs are kept at least this long (0 = as long as the job)")
+	resultBaseURL       = flag.String("result-base-url", "", "External base URL of the HTTP server (e.g. https://iskoces.example.com), used to make result URLs in job status absolute")
+
+	// Job completion webhooks
+	webhooksFile = flag.String("webhooks-file", "", "Path to a JSON file with per-namespace job completion webhooks and the HMAC secrets callbacks are signed with")
+
+	// Job lifecycle events
+	jobEventsURL = flag.String("job-events", "", "Publish job lifecycle events to nats://[user:password@|token@]host[:port][/subject-prefix] (tls:// for TLS) or kafka://host[:port][,host[:port]...][/topic] (empty = disabled)")
+
+	// Job archive
+	jobArchiveLocation  = flag.String("job-archive", "", "Where finished async jobs' metadata is archived as JSON lines files for analytics: a directory path, or an s3://bucket[/prefix][?endpoint=URL&region=REGION] URL (empty = not archived)")
+	jobArchiveInterval  = flag.Duration("job-archive-interval", service.DefaultJobArchiveInterval, "How often finished jobs are written to the job archive")
+	jobArchiveRetention = flag.Duration("job-archive-retention", 0, "How long job archive files in a directory are kept, independent of job retention (0 = no limit; use a lifecycle rule for object storage)")
+
+	// Job schedules
+	jobSchedulesFile = flag.String("job-schedules", "", "Path to a JSON file where recurring job schedules are kept (empty = memory only)")
+
+	// Service level objectives
+	sloFile = flag.String("slo-file", "", "Path to a JSON file of service level objectives tracked in metrics and at /slo (empty = the built-in objectives)")
+
+	// Quotas
+	quotaFile = flag.String("quota-file", "", "Path to a JSON file with per-namespace quotas (requests_per_minute, characters_per_day)")
+
+	// Audit log (none unless a destination is given)
+	auditLog            = flag.String("audit-log", "", "Where to append JSON audit records of translation calls and jobs: a file path, syslog, or syslog://host:port / syslog+tcp://host:port (empty = no audit log)")
+	auditIncludeContent = flag.Bool("audit-include-content", false, "Include the text translated and its translation in audit records (by default only their sizes are recorded)")
+
+	// HTTP API authentication (none unless API keys or a JWKS URL are given)
+	httpAPIKeys           = flag.String("http-api-keys", "", "Path to a JSON file of API keys for the HTTP API ({\"keys\": [{\"name\", \"key\" or \"sha256\", \"namespaces\"}]})")
+	httpJWKSURL           = flag.String("http-jwks-url", "", "URL of the JSON Web Key Set whose keys sign bearer JWTs accepted by the HTTP API")
+	httpJWTIssuer         = flag.String("http-jwt-issuer", "", "Required iss of bearer JWTs (empty = any)")
+	httpJWTAudience       = flag.String("http-jwt-audience", "", "Required aud of bearer JWTs (empty = any)")
+	httpJWTNamespaceClaim = flag.String("http-jwt-namespace-claim", server.DefaultNamespaceClaim, "JWT claim with the namespaces the caller may use (a string or array; tokens without it may use any)")
+
+	// HTTP API cross-origin policy (same origin only unless origins are given)
+	httpCORSOrigins     = flag.String("http-cors-origins", "", "Comma-separated origins whose pages may call the HTTP API (e.g. https://app.example.com,https://*.example.com; * = any)")
+	httpCORSMethods     = flag.String("http-cors-methods", strings.Join(server.DefaultCORSMethods, ","), "Comma-separated methods allowed cross-origin")
+	httpCORSHeaders     = flag.String("http-cors-headers", strings.Join(server.DefaultCORSHeaders, ","), "Comma-separated request headers allowed cross-origin (* = any)")
+	httpCORSCredentials = flag.Bool("http-cors-credentials", false, "Allow cross-origin requests with cookies and HTTP authentication (needs explicit origins)")
+	httpCORSMaxAge      = flag.Duration("http-cors-max-age", server.DefaultCORSMaxAge, "How long browsers may cache a CORS preflight response")
 
-	// TLS configuration flags (for future use)
-	tlsCertPath = flag.String("tls-cert", "", "Path to TLS server certificate")
-	tlsKeyPath  = flag.String("tls-key", "", "Path to TLS server private key")
-	tlsCAPath   = flag.String("tls-ca", "", "Path to CA certificate for client verification (mTLS)")
+	// Readiness (/readyz on the HTTP port)
+	readyMaxEngineCheckAge = flag.Duration("ready-max-engine-check-age", 0, "Not ready when the last successful engine health check is older than this (0 = three check intervals, 90s)")
+	readyMaxQueuedRequests = flag.Int("ready-max-queued-requests", 0, "Not ready when this many requests wait for a worker (0 = at -worker-queue-capacity, if set)")
+	readyMaxBacklogJobs    = flag.Int("ready-max-backlog-jobs", 0, "Not ready when this many async jobs are unfinished (0 = no limit)")
+
+	// Engine health check hysteresis
+	engineUnhealthyThreshold = flag.Int("engine-unhealthy-threshold", service.DefaultUnhealthyThreshold, "Engine health checks that must fail in a row before the engine is marked unhealthy")
+	engineHealthyThreshold   = flag.Int("engine-healthy-threshold", service.DefaultHealthyThreshold, "Engine health checks that must succeed in a row before an unhealthy engine is marked healthy again")
+
+	// Tracing (OpenTelemetry spans from gRPC calls through job processing to the workers)
+	otelEndpoint    = flag.String("otel-endpoint", "", "Export trace spans over OTLP/HTTP to this collector URL, e.g. http://otel-collector:4318 (empty = tracing disabled)")
+	otelSampleRatio = flag.Float64("otel-sample-ratio", 1, "Fraction of new traces recorded; traces continued from a caller's traceparent follow the caller's decision")
+	otelServiceName = flag.String("otel-service-name", tracing.DefaultServiceName, "service.name of the exported spans")
+
+	// Debugging
+	enableReflection = flag.Bool("reflection", false, "Enable gRPC server reflection (for grpcurl/debugging)")
 
 	// Logging configuration
-	logLevel = flag.String("log-level", "info", "Log level: debug, info, warn, error")
+	logLevel       = flag.String("log-level", "info", "Log level: debug, info, warn, error")
+	accessLogLevel = flag.String("access-log-level", "", "Level of gRPC call and HTTP request logs (successes are logged at debug, failures at info or warn; empty = -log-level)")
+	logFormat      = flag.String("log-format", logging.FormatText, "Log format: text or json (one JSON object per line)")
+	logFile        = flag.String("log-file", "", "Write logs to this file instead of stderr, rotating it per -log-max-size and -log-max-age")
+	logMaxSize     = flag.Int64("log-max-size", 100, "Rotate -log-file before it grows past this many MiB (0 = no size limit)")
+	logMaxAge      = flag.Duration("log-max-age", 0, "Rotate -log-file once it has been written to for this long, e.g. 24h (0 = no age limit)")
+	logMaxBackups  = flag.Int("log-max-backups", 5, "Rotated log files to keep; older ones are removed (0 = keep all)")
 )
 
 func main() {
 	flag.Parse()
 
-	// Initialize logger
+	// Initialize logger: text or JSON, to stderr or a rotated log file
 	logger := logrus.New()
-	logger.SetFormatter(&logrus.TextFormatter{
-		FullTimestamp: true,
-		TimestampFormat: time.RFC3339,
-	})
+	formatter, err := logging.NewFormatter(*logFormat)
+	if err != nil {
+		logger.WithError(err).Fatal("Invalid log format")
+	}
+	logger.SetFormatter(formatter)
+	var logOutput *logging.File
+	if *logFile != "" {
+		logOutput, err = logging.OpenFile(*logFile, logging.RotateConfig{
+			MaxBytes:   *logMaxSize << 20,
+			MaxAge:     *logMaxAge,
+			MaxBackups: *logMaxBackups,
+		})
+		if err != nil {
+			logger.WithError(err).Fatal("Failed to open log file")
+		}
+		defer logOutput.Close()
+		logger.SetOutput(logOutput)
+	}
 
 	// Set log level
 	level, err := logrus.ParseLevel(*logLevel)
@@ -60,25 +251,115 @@ func main() {
 	}
 	logger.SetLevel(level)
 
+	// gRPC call and HTTP request logs go to the same place at their own level
+	accessLogger := logrus.New()
+	accessLogger.SetFormatter(formatter)
+	accessLogger.SetOutput(logger.Out)
+	accessLevel := level
+	if *accessLogLevel != "" {
+		accessLevel, err = logrus.ParseLevel(*accessLogLevel)
+		if err != nil {
+			logger.WithError(err).Warn("Invalid access log level, using -log-level")
+			accessLevel = level
+		}
+	}
+	accessLogger.SetLevel(accessLevel)
+
 	logger.WithFields(logrus.Fields{
 		"port":      *port,
 		"insecure":  *insecureMode,
 		"mt_engine": *mtEngine,
 		"mt_url":    *mtURL,
 		"log_level": level.String(),
+		"access_log_level": accessLevel.String(),
+		"log_format": *logFormat,
+		"log_file":   *logFile,
+		"version":   version,
+		"max_document_bytes": *maxDocumentBytes,
+		"max_title_length":   *maxTitleLength,
+		"max_recv_message_bytes": *maxRecvMsgBytes,
+		"max_send_message_bytes": *maxSendMsgBytes,
+		"require_registration": *requireRegistration,
+		"quota_file":           *quotaFile,
+		"reflection":           *enableReflection,
 	}).Info("Starting Iskoces gRPC server")
 
+	// Tracing starts first so the workers' spans are recorded from the start
+	var spanExporter *tracing.Exporter
+	if *otelEndpoint != "" {
+		spanExporter, err = tracing.NewExporter(tracing.ExporterConfig{
+			Endpoint:       *otelEndpoint,
+			ServiceName:    *otelServiceName,
+			ServiceVersion: version,
+			SampleRatio:    *otelSampleRatio,
+		}, logger)
+		if err != nil {
+			logger.WithError(err).Fatal("Invalid -otel-endpoint or -otel-sample-ratio")
+		}
+		tracing.SetExporter(spanExporter)
+		logger.WithFields(logrus.Fields{
+			"endpoint":     *otelEndpoint,
+			"sample_ratio": *otelSampleRatio,
+		}).Info("Exporting trace spans over OTLP")
+	}
+
 	// Parse translation engine type
 	engineType, err := translate.ParseEngineType(*mtEngine)
 	if err != nil {
 		logger.WithError(err).Fatal("Failed to parse translation engine type")
 	}
 
+	pinned, err := translate.ParsePinnedPairs(*pinnedWorkers)
+	if err != nil {
+		logger.WithError(err).Fatal("Invalid -pinned-workers")
+	}
+
+	writableDirs := splitList(*workerWritableDirs)
+	remoteAddrs := splitList(*remoteWorkers)
+
 	// Create translator instance with worker pool (fast, no HTTP)
 	translator, err := translate.NewTranslator(translate.Config{
 		Engine:       engineType,
 		UseWorkerPool: true, // Use fast worker pool with Unix sockets
-		MaxWorkers:   4,     // 4 concurrent Python workers
+		MinWorkers:   *minWorkers,
+		MaxWorkers:   *maxWorkers,
+		MaxInFlightPerWorker: *workerMaxInFlight,
+		PythonPath:           *pythonPath,
+		WorkerScriptPath:     *workerScript,
+		WorkerSocketDir:      *workerSocketDir,
+		WorkerRequestTimeout: *workerRequestTimeout,
+		WorkerReadyTimeout:   *workerReadyTimeout,
+		QueueTimeout:         *workerQueueTimeout,
+		QueueCapacity:        *workerQueueCapacity,
+		OverloadThreshold:    *overloadThreshold,
+		WorkerMaxRSSBytes:    *workerMaxRSSMB * 1024 * 1024,
+		WorkerMaxRequests:    *workerMaxRequests,
+		InteractiveWorkers:   *interactiveWorkers,
+		WorkerTransport:      *workerTransport,
+		WorkerSandbox: translate.WorkerSandbox{
+			UID:                  *workerUID,
+			GID:                  *workerGID,
+			CgroupPath:           *workerCgroup,
+			MaxAddressSpaceBytes: *workerMaxASMB * 1024 * 1024,
+			MaxFileSizeBytes:     *workerMaxFileSizeMB * 1024 * 1024,
+			MaxOpenFiles:         *workerMaxOpenFiles,
+			MaxProcesses:         *workerMaxProcesses,
+			ReadOnly:             *workerReadOnly,
+			WritableDirs:         writableDirs,
+		},
+		RemoteWorkers: remoteAddrs,
+		RemoteWorkerTLS: translate.WorkerTLS{
+			CertFile:   *remoteWorkerCert,
+			KeyFile:    *remoteWorkerKey,
+			CAFile:     *remoteWorkerCA,
+			ServerName: *remoteWorkerServerName,
+		},
+		Scaling: translate.ScalingConfig{
+			ScaleUpQueueWait: *scaleUpQueueWait,
+			ScaleUpBusyRatio: *scaleUpBusyRatio,
+			IdleTimeout:      *workerIdleTimeout,
+			Pinned:           pinned,
+		},
 		Logger:       logger,
 	})
 	if err != nil {
@@ -97,23 +378,347 @@ func main() {
 		logger.Info("Translator health check passed")
 	}
 
-	// Create listener
-	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", *port))
+	// Create listener (with -single-port, the HTTP server listens instead)
+	var lis net.Listener
+	if !*singlePort {
+		var err error
+		lis, err = net.Listen("tcp", fmt.Sprintf(":%d", *port))
+		if err != nil {
+			logger.WithError(err).WithFields(logrus.Fields{
+				"port": *port,
+			}).Fatal("Failed to listen on port")
+		}
+	}
+
+	// The transport receive limit leaves headroom over the document limit so
+	// oversized documents get a structured InvalidArgument from the validation
+	// interceptor rather than a bare RESOURCE_EXHAUSTED.
+	recvLimit := *maxRecvMsgBytes
+	if recvLimit <= 0 {
+		recvLimit = *maxDocumentBytes + 64*1024
+	}
+	if recvLimit < *maxDocumentBytes {
+		logger.WithFields(logrus.Fields{
+			"max_recv_message_bytes": recvLimit,
+			"max_document_bytes":     *maxDocumentBytes,
+		}).Warn("max-recv-message-bytes is below max-document-bytes; large documents will be rejected by the transport")
+	}
+
+	// Create translation service
+	translationService := service.NewTranslationService(translator, logger)
+	translationService.Info = service.ServerInfo{
+		Version:             version,
+		Engines:             []string{string(engineType)},
+		MaxDocumentBytes:    *maxDocumentBytes,
+		RequireRegistration: *requireRegistration,
+		MaxRecvMessageBytes: recvLimit,
+		MaxSendMessageBytes: *maxSendMsgBytes,
+		Compressors:         []string{gzip.Name},
+		V2API:               true,
+	}
+	translationService.JobQueue.Processor().SetChunkParallelism(*chunkParallelism)
+	translationService.JobQueue.Processor().SetCostPerMillionChars(*engineCostPerMillionChars)
+	switch order := service.JobOrder(*jobOrder); order {
+	case service.JobOrderFair, service.JobOrderPriority, service.JobOrderFIFO:
+		translationService.JobQueue.SetScheduling(*maxConcurrentJobs, order)
+		translationService.JobQueue.SetPriorityAging(*jobPriorityAging)
+	default:
+		logger.WithField("job_order", *jobOrder).Fatal("Invalid -job-order: must be fair, priority or fifo")
+	}
+	namespaceWeights, err := service.ParseNamespaceWeights(*jobNamespaceWeights)
 	if err != nil {
-		logger.WithError(err).WithFields(logrus.Fields{
-			"port": *port,
-		}).Fatal("Failed to listen on port")
+		logger.WithError(err).Fatal("Invalid -job-namespace-weights")
+	}
+	translationService.JobQueue.SetNamespaceScheduling(service.NamespaceScheduling{
+		Weights:       namespaceWeights,
+		MaxConcurrent: *maxNamespaceJobs,
+	})
+	translationService.JobQueue.Processor().SetRetryPolicy(service.JobRetryPolicy{
+		MaxAttempts: *jobMaxAttempts,
+		Backoff:     *jobRetryBackoff,
+		MaxBackoff:  *jobRetryMaxBackoff,
+	})
+	translationService.JobQueue.SetDeadLetterRetention(*deadLetterRetention)
+	translationService.JobQueue.SetDeduplication(service.DedupConfig{
+		Window: *jobDedupWindow,
+		Engine: string(engineType),
+	})
+
+	// Per-namespace quotas: unlimited unless a quota file is given or set via AdminService
+	quotaConfig := service.QuotaConfig{}
+	if *quotaFile != "" {
+		quotaConfig, err = service.LoadQuotaConfig(*quotaFile)
+		if err != nil {
+			logger.WithError(err).Fatal("Failed to load quota file")
+		}
+		logger.WithFields(logrus.Fields{
+			"default_requests_per_minute": quotaConfig.Default.RequestsPerMinute,
+			"default_characters_per_day":  quotaConfig.Default.CharactersPerDay,
+			"namespaces":                  len(quotaConfig.Namespaces),
+		}).Info("Loaded namespace quotas")
+	}
+	translationService.Quotas = service.NewQuotaManager(quotaConfig)
+
+	// Client label schema: without one, labels are only checked for syntax
+	if *labelSchemaFile != "" {
+		schema, err := service.LoadLabelSchema(*labelSchemaFile)
+		if err != nil {
+			logger.WithError(err).Fatal("Failed to load label schema")
+		}
+		translationService.LabelSchema = schema
+		logger.WithFields(logrus.Fields{
+			"labels":        len(schema.Labels),
+			"allow_unknown": schema.AllowUnknown,
+		}).Info("Loaded client label schema")
+	}
+
+	// Post-edit feedback: kept in memory unless a feedback file is given
+	if *feedbackFile != "" {
+		feedbackStore, err := service.NewFeedbackStore(*feedbackFile, service.DefaultMaxFeedbackRecords)
+		if err != nil {
+			logger.WithError(err).Fatal("Failed to open feedback file")
+		}
+		defer feedbackStore.Close()
+		translationService.Feedback = feedbackStore
+		logger.WithFields(logrus.Fields{
+			"path":    *feedbackFile,
+			"records": len(feedbackStore.Records()),
+		}).Info("Loaded translation feedback")
+	}
+
+	// Job completion webhooks: only requests with a callback_url get one (unsigned)
+	// unless a webhooks file is given
+	if *webhooksFile != "" {
+		webhookConfig, err := service.LoadWebhookConfig(*webhooksFile)
+		if err != nil {
+			logger.WithError(err).Fatal("Failed to load webhooks file")
+		}
+		translationService.JobQueue.SetWebhooks(webhookConfig)
+		logger.WithFields(logrus.Fields{
+			"namespaces": len(webhookConfig.Namespaces),
+			"signed":     webhookConfig.Secret != "",
+		}).Info("Loaded job completion webhooks")
+	}
+
+	// Job lifecycle events: published only if a broker is given
+	if *jobEventsURL != "" {
+		publisher, err := service.OpenJobEventPublisher(*jobEventsURL, logger)
+		if err != nil {
+			logger.WithError(err).Fatal("Failed to connect to job event broker")
+		}
+		translationService.JobQueue.SetEventPublisher(publisher)
+		defer translationService.JobQueue.StopEvents()
+		scheme, _, _ := strings.Cut(*jobEventsURL, "://")
+		logger.WithField("broker", scheme).Info("Publishing job lifecycle events")
+	}
+
+	// Job archive: finished jobs' metadata is kept for analytics only if an
+	// archive location is given
+	if *jobArchiveLocation != "" {
+		archiveStore, err := service.OpenResultStore(*jobArchiveLocation)
+		if err != nil {
+			logger.WithError(err).Fatal("Failed to open job archive")
+		}
+		translationService.JobQueue.SetArchive(service.JobArchiveConfig{
+			Store:     archiveStore,
+			Interval:  *jobArchiveInterval,
+			Retention: *jobArchiveRetention,
+		})
+		defer translationService.JobQueue.StopArchive()
+		logger.WithFields(logrus.Fields{
+			"interval":  *jobArchiveInterval,
+			"retention": *jobArchiveRetention,
+		}).Info("Archiving finished jobs")
+	}
+
+	// Audit log: calls doing translation work and finished jobs are recorded
+	// only if a destination is given
+	if *auditLog != "" {
+		auditLogger, err := audit.Open(audit.Config{
+			Destination:    *auditLog,
+			IncludeContent: *auditIncludeContent,
+			Engine:         string(engineType),
+		}, logger)
+		if err != nil {
+			logger.WithError(err).Fatal("Failed to open audit log")
+		}
+		defer auditLogger.Close()
+		translationService.Audit = auditLogger
+		translationService.JobQueue.SetAudit(auditLogger)
+		logger.WithFields(logrus.Fields{
+			"destination":     *auditLog,
+			"include_content": *auditIncludeContent,
+		}).Info("Writing audit log")
+	}
+
+	// Job results: kept in memory with their jobs unless a result store is
+	// given, and dropped after -result-ttl
+	resultConfig := service.ResultConfig{
+		OffloadBytes: *resultOffloadBytes,
+		TTL:          *resultTTL,
+		BaseURL:      *resultBaseURL,
+	}
+	if *resultStoreLocation != "" {
+		resultStore, err := service.OpenResultStore(*resultStoreLocation)
+		if err != nil {
+			logger.WithError(err).Fatal("Failed to open result store")
+		}
+		resultConfig.Store = resultStore
+		storeKind, _, _ := strings.Cut(*resultStoreLocation, "://")
+		if storeKind != "s3" {
+			storeKind = "disk"
+		}
+		logger.WithFields(logrus.Fields{
+			"store":         storeKind,
+			"offload_bytes": *resultOffloadBytes,
+		}).Info("Offloading large job results")
+	}
+	translationService.JobQueue.SetResults(resultConfig)
+
+	// Async jobs: kept in memory unless a job store is given, in which case
+	// jobs left unfinished by the last run are queued again (or, with Redis,
+	// replicas share one queue)
+	if !*asyncEnabled {
+		translationService.AsyncDisabled = true
+		if *jobStoreFile != "" || *jobSchedulesFile != "" {
+			logger.Warn("Async jobs are disabled; ignoring -job-store and -job-schedules")
+		}
+		logger.Info("Async translation jobs disabled")
+	} else if *jobStoreFile != "" {
+		jobStore, err := service.OpenJobStore(*jobStoreFile, logger)
+		if err != nil {
+			logger.WithError(err).Fatal("Failed to open job store")
+		}
+		defer jobStore.Close()
+		translationService.JobQueue.SetSharedConcurrency(*jobStoreConcurrency)
+		translationService.JobQueue.SetStore(jobStore)
+	}
+
+	// Finished jobs are removed once past their retention, checked every
+	// 30s; completed jobs are kept at least as long as their results
+	jobRetention := service.JobRetention{
+		Completed: *jobRetentionCompleted,
+		Failed:    *jobRetentionFailed,
+		Cancelled: *jobRetentionCancelled,
+		MaxJobs:   *jobRetentionMaxJobs,
+	}
+	if jobRetention.Completed > 0 && *resultTTL > 0 {
+		jobRetention.Completed = max(jobRetention.Completed, *resultTTL)
+	}
+	translationService.JobQueue.StartRetention(jobRetention)
+
+	// Job schedules: recurring jobs are created over the v2 API and kept in
+	// memory unless a schedules file is given
+	if *jobSchedulesFile != "" && *asyncEnabled {
+		count, err := translationService.Schedules.Load(*jobSchedulesFile)
+		if err != nil {
+			logger.WithError(err).Fatal("Failed to load job schedules")
+		}
+		logger.WithField("schedules", count).Info("Loaded job schedules")
 	}
 
 	// Create gRPC server with options
 	var opts []grpc.ServerOption
 
-	// TODO: Configure TLS/mTLS when certificates are available
+	// Message size limits. gzip is registered by importing the encoding package;
+	// responses are compressed when the client compresses its requests.
+	opts = append(opts, grpc.MaxRecvMsgSize(recvLimit))
+	if *maxSendMsgBytes > 0 {
+		opts = append(opts, grpc.MaxSendMsgSize(*maxSendMsgBytes))
+	}
+
+	// Service level objectives: the built-in ones unless an SLO file is given
+	sloConfig := service.DefaultSLOConfig()
+	if *sloFile != "" {
+		sloConfig, err = service.LoadSLOConfig(*sloFile)
+		if err != nil {
+			logger.WithError(err).Fatal("Failed to load SLO file")
+		}
+	}
+	slos, err := service.NewSLOTracker(sloConfig)
+	if err != nil {
+		logger.WithError(err).Fatal("Invalid service level objectives")
+	}
+	slos.Start()
+	defer slos.Stop()
+	logger.WithField("objectives", len(sloConfig.Objectives)).Info("Tracking service level objectives")
+
+	// Interceptors are collected so gRPC calls over the HTTP server (REST
+	// endpoints, JSON gateway) run through the same ones
+	var unaryInterceptors []grpc.UnaryServerInterceptor
+	var streamInterceptors []grpc.StreamServerInterceptor
+
+	// Outermost interceptors: request logging (with x-request-id), tracing,
+	// Prometheus metrics and SLOs, the audit log (so rejected calls are
+	// audited too), then panic recovery so a handler panic becomes INTERNAL
+	// instead of killing the server
+	unaryInterceptors = append(unaryInterceptors,
+		service.LoggingUnaryInterceptor(accessLogger),
+		service.TracingUnaryInterceptor(),
+		service.MetricsUnaryInterceptor(),
+		service.SLOUnaryInterceptor(slos),
+		service.AuditUnaryInterceptor(translationService),
+		service.RecoveryUnaryInterceptor(logger),
+	)
+	streamInterceptors = append(streamInterceptors,
+		service.LoggingStreamInterceptor(accessLogger),
+		service.TracingStreamInterceptor(),
+		service.MetricsStreamInterceptor(),
+		service.SLOStreamInterceptor(slos),
+		service.AuditStreamInterceptor(translationService),
+		service.RecoveryStreamInterceptor(logger),
+	)
+
+	// Reject registrations and new translation work while draining (UNAVAILABLE with a retry hint)
+	unaryInterceptors = append(unaryInterceptors, service.DrainUnaryInterceptor(translationService))
+	streamInterceptors = append(streamInterceptors, service.DrainStreamInterceptor(translationService))
+
+	// With async jobs disabled, calls that would create them are UNIMPLEMENTED
+	unaryInterceptors = append(unaryInterceptors, service.AsyncUnaryInterceptor(translationService))
+
+	// Validate request sizes and encoding before any backend work
+	limits := service.RequestLimits{
+		MaxDocumentBytes: *maxDocumentBytes,
+		MaxTitleLength:   *maxTitleLength,
+	}
+	unaryInterceptors = append(unaryInterceptors, service.ValidationUnaryInterceptor(limits, logger))
+	streamInterceptors = append(streamInterceptors, service.ValidationStreamInterceptor(limits, logger))
+
+	// Optionally require a registered client_id (x-client-id metadata) on translation calls
+	if *requireRegistration {
+		unaryInterceptors = append(unaryInterceptors, service.RegistrationUnaryInterceptor(translationService))
+		streamInterceptors = append(streamInterceptors, service.RegistrationStreamInterceptor(translationService))
+	}
+
+	// Enforce per-namespace quotas (RESOURCE_EXHAUSTED with a retry-after header)
+	unaryInterceptors = append(unaryInterceptors, service.QuotaUnaryInterceptor(translationService))
+	streamInterceptors = append(streamInterceptors, service.QuotaStreamInterceptor(translationService))
+	opts = append(opts,
+		grpc.ChainUnaryInterceptor(unaryInterceptors...),
+		grpc.ChainStreamInterceptor(streamInterceptors...),
+	)
+
+	// TLS (mutual with -tls-ca) unless insecure; certificates are reloaded
+	// when rotated, here and on the HTTP server
+	tlsCtx, tlsCancel := context.WithCancel(context.Background())
+	defer tlsCancel()
+	var grpcCerts *server.CertReloader
 	if !*insecureMode {
-		// TODO: Load TLS credentials from flags
-		// For now, log warning and continue with insecure
-		logger.Warn("TLS requested but not yet implemented, using insecure mode")
-		opts = append(opts, grpc.Creds(insecure.NewCredentials()))
+		var err error
+		grpcCerts, err = server.NewCertReloader(server.TLSConfig{
+			CertFile:     *tlsCertPath,
+			KeyFile:      *tlsKeyPath,
+			ClientCAFile: *tlsCAPath,
+		}, logger)
+		if err != nil {
+			logger.WithError(err).Fatal("Failed to load gRPC TLS certificate")
+		}
+		go grpcCerts.Run(tlsCtx, *tlsReloadInterval)
+		opts = append(opts, grpc.Creds(credentials.NewTLS(grpcCerts.ServerConfig())))
+		logger.WithFields(logrus.Fields{
+			"cert": *tlsCertPath,
+			"mtls": *tlsCAPath != "",
+		}).Info("gRPC server uses TLS")
 	} else {
 		opts = append(opts, grpc.Creds(insecure.NewCredentials()))
 	}
@@ -145,29 +750,165 @@ func main() {
 	// Create gRPC server
 	s := grpc.NewServer(opts...)
 
-	// Register health check service
+	// Register health check service. Statuses are reported per service: the
+	// server and AdminService are SERVING while the process is up; the engine
+	// and translation services follow the engine's health check.
 	healthServer := health.NewServer()
 	grpc_health_v1.RegisterHealthServer(s, healthServer)
-	healthServer.SetServingStatus("", grpc_health_v1.HealthCheckResponse_SERVING)
+	healthCtx, healthCancel := context.WithCancel(context.Background())
+	defer healthCancel()
+	healthMonitor := service.NewHealthMonitor(healthServer, translator, logger, 30*time.Second)
+	healthMonitor.SetThresholds(service.HealthThresholds{
+		Unhealthy: *engineUnhealthyThreshold,
+		Healthy:   *engineHealthyThreshold,
+	})
+	translationService.OnDrainChange = healthMonitor.SetDraining
+	translationService.Health = healthMonitor
+	go healthMonitor.Run(healthCtx)
 
-	// Create and register translation service
-	translationService := service.NewTranslationService(translator, logger)
+	// Register translation service
 	nanabushv1.RegisterTranslationServiceServer(s, translationService)
 
+	// Register the v2 translation API alongside v1 (same job queue and engine)
+	translationServiceV2 := service.NewTranslationServiceV2(translationService, logger)
+	nanabushv2.RegisterTranslationServiceServer(s, translationServiceV2)
+
+	// Expose jobs as google.longrunning operations for LRO-aware clients and gateways
+	longrunningpb.RegisterOperationsServer(s, service.NewOperationsService(translationService, logger))
+
+	// Register admin service (operator controls, e.g. pushing config to clients)
+	adminService := service.NewAdminService(translationService, logger)
+	nanabushv1.RegisterAdminServiceServer(s, adminService)
+
 	// Start HTTP server for job status and SSE (in background)
-	httpPort := 5000 // HTTP port for job status API
-	go func() {
-		httpServer := server.NewHTTPServer(translationService.JobQueue, logger, httpPort)
-		if err := httpServer.Start(); err != nil {
-			logger.WithError(err).Error("HTTP server failed")
+	var httpServer *server.HTTPServer
+	if *httpPort > 0 || *singlePort {
+		listenPort := *httpPort
+		if *singlePort {
+			listenPort = *port
 		}
-	}()
-	logger.WithFields(logrus.Fields{
-		"port": httpPort,
-	}).Info("HTTP server started for job status and SSE")
+		httpServer = server.NewHTTPServer(translationService.JobQueue, logger, listenPort)
+		httpServer.SetAccessLogger(accessLogger)
+		httpServer.SetSLOs(slos)
+		httpServer.SetTimeouts(server.Timeouts{
+			Read:         *httpReadTimeout,
+			Write:        *httpWriteTimeout,
+			Idle:         *httpIdleTimeout,
+			SSEKeepalive: *httpSSEKeepalive,
+		})
+		if pool, ok := translate.Backend(translator).(*translate.WorkerPool); ok {
+			httpServer.SetWorkerPool(pool)
+		}
+		httpServer.SetLimits(server.Limits{
+			MaxBodyBytes:         *httpMaxBodyBytes,
+			IPRequestsPerMinute:  *httpRateLimitIP,
+			KeyRequestsPerMinute: *httpRateLimitKey,
+			TrustForwardedFor:    *httpTrustForwardedFor,
+		})
+		httpServer.SetCallOptions(server.CallOptions{
+			MaxBodyBytes: recvLimit,
+			Unary:        unaryInterceptors,
+			Stream:       streamInterceptors,
+		})
+		if *httpTLSCert != "" || *httpTLSKey != "" {
+			certs, err := server.NewCertReloader(server.TLSConfig{
+				CertFile:           *httpTLSCert,
+				KeyFile:            *httpTLSKey,
+				ClientCAFile:       *httpTLSClientCA,
+				ClientCertOptional: *httpTLSClientCertOptional,
+			}, logger)
+			if err != nil {
+				logger.WithError(err).Fatal("Failed to load HTTP TLS certificate")
+			}
+			go certs.Run(tlsCtx, *tlsReloadInterval)
+			httpServer.SetTLS(certs)
+			logger.WithFields(logrus.Fields{
+				"cert": *httpTLSCert,
+				"mtls": *httpTLSClientCA != "",
+			}).Info("HTTP server uses TLS")
+		} else if *singlePort && grpcCerts != nil {
+			// The shared port presents the gRPC certificate
+			httpServer.SetTLS(grpcCerts)
+		} else if *httpTLSClientCA != "" {
+			logger.Fatal("-http-tls-client-ca needs -http-tls-cert and -http-tls-key")
+		}
+		httpServer.SetH2C(*httpH2C || *singlePort)
+		if *singlePort {
+			httpServer.SetGRPCServer(s)
+		}
+		httpServer.SetTranslationService(translationService)
+		httpServer.SetAdminService(adminService)
+		httpServer.SetReadiness(server.ReadinessConfig{
+			Health:            healthMonitor,
+			MaxEngineCheckAge: *readyMaxEngineCheckAge,
+			MaxQueuedRequests: *readyMaxQueuedRequests,
+			MaxBacklogJobs:    *readyMaxBacklogJobs,
+		})
+		var auths []server.Authenticator
+		if *httpAPIKeys != "" {
+			keys, err := server.LoadAPIKeys(*httpAPIKeys)
+			if err != nil {
+				logger.WithError(err).Fatal("Failed to load HTTP API keys")
+			}
+			auth, err := server.NewAPIKeyAuthenticator(keys)
+			if err != nil {
+				logger.WithError(err).Fatal("Invalid HTTP API key file")
+			}
+			auths = append(auths, auth)
+			logger.WithField("keys", len(keys.Keys)).Info("Loaded HTTP API keys")
+		}
+		if *httpJWKSURL != "" {
+			auth, err := server.NewJWTAuthenticator(server.JWTConfig{
+				JWKSURL:        *httpJWKSURL,
+				Issuer:         *httpJWTIssuer,
+				Audience:       *httpJWTAudience,
+				NamespaceClaim: *httpJWTNamespaceClaim,
+			}, logger)
+			if err != nil {
+				logger.WithError(err).Fatal("Failed to set up HTTP JWT authentication")
+			}
+			auths = append(auths, auth)
+		}
+		if len(auths) > 0 {
+			httpServer.SetAuthenticator(server.Authenticators(auths...))
+		} else {
+			logger.Warn("HTTP API is unauthenticated: anyone who can reach it can read job results; use -http-api-keys or -http-jwks-url")
+		}
+		if origins := splitList(*httpCORSOrigins); len(origins) > 0 {
+			err := httpServer.SetCORS(server.CORSConfig{
+				AllowedOrigins:   origins,
+				AllowedMethods:   splitList(*httpCORSMethods),
+				AllowedHeaders:   splitList(*httpCORSHeaders),
+				AllowCredentials: *httpCORSCredentials,
+				MaxAge:           *httpCORSMaxAge,
+			})
+			if err != nil {
+				logger.WithError(err).Fatal("Invalid HTTP CORS policy")
+			}
+			logger.WithField("origins", origins).Info("HTTP API allows cross-origin requests")
+		}
+		// Every TranslationService RPC as JSON, described at /openapi.json
+		nanabushv1.RegisterTranslationServiceServer(httpServer, translationService)
+		nanabushv2.RegisterTranslationServiceServer(httpServer, translationServiceV2)
+		// With -single-port, the HTTP server is started in place of the
+		// gRPC server, once every gRPC service is registered
+		if !*singlePort {
+			go func() {
+				if err := httpServer.Start(); err != nil {
+					logger.WithError(err).Error("HTTP server failed")
+				}
+			}()
+			logger.WithFields(logrus.Fields{
+				"port": *httpPort,
+			}).Info("HTTP server started for job status and SSE")
+		}
+	}
 
-	// Enable reflection for grpcurl/debugging (can be disabled in production)
-	reflection.Register(s)
+	// Reflection is opt-in (useful for grpcurl/debugging, not needed in production)
+	if *enableReflection {
+		reflection.Register(s)
+		logger.Info("gRPC reflection enabled")
+	}
 
 	// Start periodic cleanup goroutine for expired clients
 	cleanupCtx, cleanupCancel := context.WithCancel(context.Background())
@@ -187,8 +928,8 @@ func main() {
 			select {
 			case <-ticker.C:
 				translationService.CleanupExpiredClients(maxIdleTime)
-				// Also cleanup old translation jobs (keep for 1 hour)
-				translationService.JobQueue.CleanupOldJobs(1 * time.Hour)
+				// Idempotent retries are honored for as long as results are kept
+				translationService.CleanupIdempotentResults(1 * time.Hour)
 			case <-cleanupCtx.Done():
 				return
 			}
@@ -199,51 +940,18 @@ func main() {
 		"max_idle_time":    "60 seconds (2x heartbeat interval)",
 	}).Info("Started client cleanup goroutine")
 
-	// Start periodic metrics logging
-	metricsCtx, metricsCancel := context.WithCancel(context.Background())
-	defer metricsCancel()
-
-	go func() {
-		ticker := time.NewTicker(1 * time.Minute) // Log metrics every minute
-		defer ticker.Stop()
-
-		for {
-			select {
-			case <-ticker.C:
-				// Get registered clients
-				clients := translationService.GetRegisteredClients()
-				logger.WithFields(logrus.Fields{
-					"total_clients": len(clients),
-				}).Debug("Client metrics")
-
-				if len(clients) > 0 {
-					// Log namespace distribution
-					nsCount := make(map[string]int)
-					for _, client := range clients {
-						ns := client.Namespace
-						if ns == "" {
-							ns = "unknown"
-						}
-						nsCount[ns]++
-					}
-
-					for ns, count := range nsCount {
-						logger.WithFields(logrus.Fields{
-							"namespace": ns,
-							"count":     count,
-						}).Debug("Clients by namespace")
-					}
-				}
-			case <-metricsCtx.Done():
-				return
-			}
-		}
-	}()
-	logger.Info("Started metrics logging goroutine (logs every minute)")
-
 	// Start server in goroutine
 	errChan := make(chan error, 1)
 	go func() {
+		if *singlePort {
+			logger.WithFields(logrus.Fields{
+				"port": *port,
+			}).Info("gRPC, gRPC-Web and HTTP API listening on a single port")
+			if err := httpServer.Start(); err != nil {
+				errChan <- fmt.Errorf("failed to serve: %w", err)
+			}
+			return
+		}
 		logger.WithFields(logrus.Fields{
 			"port": *port,
 		}).Info("gRPC server listening")
@@ -252,6 +960,33 @@ func main() {
 		}
 	}()
 
+	// SIGUSR1 enters drain mode: new work is rejected while in-flight jobs finish.
+	// Use AdminService.GetDrainStatus to wait for them, SetDrainMode to leave it.
+	drainChan := make(chan os.Signal, 1)
+	signal.Notify(drainChan, syscall.SIGUSR1)
+	go func() {
+		for range drainChan {
+			translationService.SetDraining(true, "SIGUSR1")
+		}
+	}()
+
+	// SIGHUP reopens the log and audit log files after an external rotator
+	// moved them
+	reopenChan := make(chan os.Signal, 1)
+	signal.Notify(reopenChan, syscall.SIGHUP)
+	go func() {
+		for range reopenChan {
+			if logOutput != nil {
+				if err := logOutput.Reopen(); err != nil {
+					logger.WithError(err).Error("Failed to reopen log file")
+				}
+			}
+			if err := translationService.Audit.Reopen(); err != nil {
+				logger.WithError(err).Error("Failed to reopen audit log")
+			}
+		}
+	}()
+
 	// Wait for interrupt signal
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
@@ -268,15 +1003,29 @@ func main() {
 		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 		defer cancel()
 
-		// Set health status to NOT_SERVING
-		healthServer.SetServingStatus("", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+		// Set every health status to NOT_SERVING (and ignore further updates)
+		healthServer.Shutdown()
 
-		// Graceful stop
+		// Graceful stop, with the HTTP server stopping alongside (its event
+		// streams end at once, other requests get until the timeout). With
+		// -single-port, the HTTP server waits for the gRPC calls instead, as
+		// GracefulStop can't drain calls that didn't come through s.Serve.
 		stopped := make(chan struct{})
 		go func() {
-			s.GracefulStop()
+			if !*singlePort {
+				s.GracefulStop()
+			}
 			close(stopped)
 		}()
+		if httpServer != nil {
+			if err := httpServer.Stop(ctx); err != nil {
+				logger.WithError(err).Warn("HTTP server did not shut down cleanly")
+			}
+		}
+		if *singlePort {
+			// End the gRPC calls still open after the timeout
+			s.Stop()
+		}
 
 		select {
 		case <-stopped:
@@ -285,6 +1034,36 @@ func main() {
 			logger.Warn("Graceful shutdown timeout, forcing stop...")
 			s.Stop()
 		}
+
+		// Let translations still running (e.g. async jobs) finish before
+		// the workers are stopped. Jobs cut short stay queued in the job
+		// store rather than being recorded as failed.
+		translationService.Schedules.Stop()
+		jobCtx, jobCancel := context.WithTimeout(context.Background(), *workerShutdownTimeout)
+		if !translationService.JobQueue.Shutdown(jobCtx) {
+			logger.Warn("Async jobs still running at shutdown; they will be queued again on restart if a job store is configured")
+		}
+		jobCancel()
+		if pool, ok := translate.Backend(translator).(*translate.WorkerPool); ok {
+			poolCtx, poolCancel := context.WithTimeout(context.Background(), *workerShutdownTimeout)
+			pool.Shutdown(poolCtx)
+			poolCancel()
+		}
+
+		// Export the spans of the work that just finished
+		if spanExporter != nil {
+			spanExporter.Stop()
+		}
 	}
 }
 
+// splitList splits a comma-separated flag value, dropping empty items.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
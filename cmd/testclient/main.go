@@ -10,6 +10,8 @@ import (
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/metadata"
 
 	"github.com/dasmlab/iskoces/pkg/proto/v1"
 	"github.com/sirupsen/logrus"
@@ -21,6 +23,7 @@ var (
 	targetLang = flag.String("target", "fr", "Target language code (e.g., en, fr)")
 	textFile   = flag.String("file", "", "Path to text file to translate")
 	text       = flag.String("text", "", "Text to translate (if file not provided)")
+	useGzip    = flag.Bool("gzip", false, "Compress requests and responses with gzip")
 )
 
 func main() {
@@ -59,7 +62,12 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	conn, err := grpc.DialContext(ctx, *serverAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	dialOpts := []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+	if *useGzip {
+		dialOpts = append(dialOpts, grpc.WithDefaultCallOptions(grpc.UseCompressor(gzip.Name)))
+	}
+
+	conn, err := grpc.DialContext(ctx, *serverAddr, dialOpts...)
 	if err != nil {
 		logger.WithError(err).Fatal("Failed to connect to server")
 	}
@@ -81,6 +89,9 @@ func main() {
 		"client_id": registerResp.ClientId,
 	}).Info("Client registered successfully")
 
+	// Identify ourselves on later calls (required when the server runs with -require-registration)
+	ctx = metadata.AppendToOutgoingContext(ctx, "x-client-id", registerResp.ClientId)
+
 	// Perform translation
 	logger.Info("Translating text...")
 	startTime := time.Now()
@@ -108,6 +119,14 @@ func main() {
 
 	duration := time.Since(startTime)
 
+	if translateResp.Queued != nil {
+		logger.WithFields(logrus.Fields{
+			"job_id": translateResp.Queued.JobId,
+			"state":  translateResp.Queued.State,
+		}).Info("Translation queued as an async job; poll GetTranslationResult for the result")
+		return
+	}
+
 	if !translateResp.Success {
 		logger.WithFields(logrus.Fields{
 			"error": translateResp.ErrorMessage,
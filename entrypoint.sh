@@ -9,7 +9,9 @@ MT_ENGINE="${ISKOCES_MT_ENGINE:-libretranslate}"
 MT_URL="${ISKOCES_MT_URL:-http://localhost:5000}"
 MT_PORT="${ISKOCES_MT_PORT:-5000}"
 GRPC_PORT="${ISKOCES_GRPC_PORT:-50051}"
+HTTP_PORT="${ISKOCES_HTTP_PORT:-8080}"
 LOG_LEVEL="${ISKOCES_LOG_LEVEL:-info}"
+LOG_FORMAT="${ISKOCES_LOG_FORMAT:-text}"
 
 # Language configuration - comma-separated list of language codes or "all"
 # Default: en, fr, es (English, French, Spanish)
@@ -25,6 +27,7 @@ echo "[iskoces] MT Engine: $MT_ENGINE"
 echo "[iskoces] MT URL: $MT_URL"
 echo "[iskoces] MT Port: $MT_PORT"
 echo "[iskoces] gRPC Port: $GRPC_PORT"
+echo "[iskoces] HTTP Port: $HTTP_PORT"
 echo "[iskoces] Languages: $MT_LANGUAGES"
 echo "[iskoces] Model Directory: $MODEL_DIR"
 
@@ -237,8 +240,10 @@ fi
 echo "[iskoces] Starting gRPC server on port $GRPC_PORT..."
 exec /usr/local/bin/iskoces-server \
     -port "$GRPC_PORT" \
+    -http-port "$HTTP_PORT" \
     -insecure \
     -mt-engine "$MT_ENGINE" \
     -mt-url "$MT_URL" \
-    -log-level "$LOG_LEVEL"
+    -log-level "$LOG_LEVEL" \
+    -log-format "$LOG_FORMAT"
 
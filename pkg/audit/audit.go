@@ -0,0 +1,217 @@
+// Package audit writes an append-only log of translation activity for
+// compliance: one JSON record per translation call or async job, saying who
+// asked for what (namespace, job, language pair, sizes, engine) and how it
+// ended. The text translated is only recorded if the log is configured to
+// include content.
+//
+// Records go to a file, which is only ever appended to, or to syslog. A nil
+// *Logger records nothing, so callers don't need to check whether auditing
+// is enabled.
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/syslog"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/sirupsen/logrus"
+)
+
+// syslogTag is the program name audit records are sent to syslog with.
+const syslogTag = "iskoces-audit"
+
+var recordsTotal = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "iskoces_audit_records_total",
+		Help: "Total number of audit records, by outcome (written, failed)",
+	},
+	[]string{"outcome"},
+)
+
+// Events recorded in the audit log.
+const (
+	EventCall = "call" // A translation call, answered synchronously or by submitting jobs
+	EventJob  = "job"  // An async job that finished
+)
+
+// Record is one entry of the audit log, written as a JSON line.
+type Record struct {
+	Time  time.Time `json:"time"`
+	Event string    `json:"event"`
+	// Method is the gRPC method of a call (also for calls over HTTP).
+	Method    string `json:"method,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+
+	// Who: the registered client, the caller authenticated by the HTTP API,
+	// and the address the call came from
+	ClientID   string `json:"client_id,omitempty"`
+	ClientName string `json:"client_name,omitempty"`
+	Caller     string `json:"caller,omitempty"`
+	Peer       string `json:"peer,omitempty"`
+	Namespace  string `json:"namespace,omitempty"`
+
+	// What
+	JobID           string `json:"job_id,omitempty"`        // Server job (or batch of a SubmitBatch call)
+	ClientJobID     string `json:"client_job_id,omitempty"` // The client's own identifier
+	BatchID         string `json:"batch_id,omitempty"`
+	Primitive       string `json:"primitive,omitempty"`
+	SourceLanguage  string `json:"source_language,omitempty"`
+	TargetLanguage  string `json:"target_language,omitempty"`
+	Engine          string `json:"engine,omitempty"`
+	Items           int    `json:"items,omitempty"` // Texts, chunks, documents or jobs submitted
+	SourceChars     int64  `json:"source_characters"`
+	TranslatedChars int64  `json:"translated_characters"`
+	RequestBytes    int    `json:"request_bytes,omitempty"`
+	ResponseBytes   int    `json:"response_bytes,omitempty"`
+
+	// How it ended: the gRPC status code of a call (OK for success), or the
+	// final status of a job
+	Outcome    string `json:"outcome"`
+	ErrorClass string `json:"error_class,omitempty"`
+	Error      string `json:"error,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+
+	// Content, only kept if the log includes content
+	Source      []string `json:"source,omitempty"`
+	Translation []string `json:"translation,omitempty"`
+}
+
+// Config configures a Logger.
+type Config struct {
+	// Destination is a file path, "syslog" for the local syslog daemon, or
+	// syslog://host:port (UDP) or syslog+tcp://host:port for a remote one.
+	Destination string
+	// IncludeContent keeps the text translated and its translation in the
+	// records; otherwise only their sizes are recorded.
+	IncludeContent bool
+	// Engine is recorded as the engine of every record.
+	Engine string
+}
+
+// Logger writes audit records. Records are written synchronously, each with
+// a single write, so they are on their way to the file or syslog once the
+// call or job they describe has finished.
+type Logger struct {
+	cfg    Config
+	logger *logrus.Logger
+
+	mu sync.Mutex
+	w  io.WriteCloser
+}
+
+// Open opens the audit log at cfg.Destination.
+func Open(cfg Config, logger *logrus.Logger) (*Logger, error) {
+	l := &Logger{cfg: cfg, logger: logger}
+	w, err := l.open()
+	if err != nil {
+		return nil, err
+	}
+	l.w = w
+	return l, nil
+}
+
+// open opens the log's destination.
+func (l *Logger) open() (io.WriteCloser, error) {
+	dest := l.cfg.Destination
+	if dest == "syslog" {
+		return syslog.New(syslog.LOG_INFO|syslog.LOG_AUTHPRIV, syslogTag)
+	}
+	if strings.HasPrefix(dest, "syslog://") || strings.HasPrefix(dest, "syslog+tcp://") {
+		u, err := url.Parse(dest)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid syslog address %q (want syslog://host:port)", dest)
+		}
+		network := "udp"
+		if u.Scheme == "syslog+tcp" {
+			network = "tcp"
+		}
+		return syslog.Dial(network, u.Host, syslog.LOG_INFO|syslog.LOG_AUTHPRIV, syslogTag)
+	}
+	// Append-only: records are never rewritten, and only the server can read them
+	return os.OpenFile(dest, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+}
+
+// IncludesContent reports whether records keep the text translated, so
+// callers only collect it when it will be written.
+func (l *Logger) IncludesContent() bool {
+	return l != nil && l.cfg.IncludeContent
+}
+
+// Log writes rec, setting its time and engine if unset and dropping its
+// content unless the log includes content. Failures are logged and counted;
+// they don't fail the activity being audited.
+func (l *Logger) Log(rec Record) {
+	if l == nil {
+		return
+	}
+	if rec.Time.IsZero() {
+		rec.Time = time.Now().UTC()
+	}
+	if rec.Engine == "" {
+		rec.Engine = l.cfg.Engine
+	}
+	if !l.cfg.IncludeContent {
+		rec.Source, rec.Translation = nil, nil
+	}
+	line, err := json.Marshal(rec)
+	if err != nil {
+		l.failed(err, rec)
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err := l.w.Write(append(line, '\n')); err != nil {
+		l.failed(err, rec)
+		return
+	}
+	recordsTotal.WithLabelValues("written").Inc()
+}
+
+// failed reports a record that couldn't be written.
+func (l *Logger) failed(err error, rec Record) {
+	recordsTotal.WithLabelValues("failed").Inc()
+	l.logger.WithError(err).WithFields(logrus.Fields{
+		"event":  rec.Event,
+		"method": rec.Method,
+		"job_id": rec.JobID,
+	}).Error("Failed to write audit record")
+}
+
+// Reopen reopens the audit log file, after it was rotated. Syslog
+// destinations are left as they are.
+func (l *Logger) Reopen() error {
+	if l == nil {
+		return nil
+	}
+	if _, isFile := l.w.(*os.File); !isFile {
+		return nil
+	}
+	w, err := l.open()
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	old := l.w
+	l.w = w
+	l.mu.Unlock()
+	return old.Close()
+}
+
+// Close closes the audit log.
+func (l *Logger) Close() error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.w.Close()
+}
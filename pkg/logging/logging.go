@@ -0,0 +1,197 @@
+// Package logging sets up the server's logs: text or JSON formatting, and a
+// log file that is rotated by size and age.
+package logging
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Log formats.
+const (
+	FormatText = "text" // logrus' key=value lines
+	FormatJSON = "json" // One JSON object per line
+)
+
+// NewFormatter returns the formatter of format. Timestamps are RFC 3339 in
+// both formats; JSON lines have the keys time, level and msg besides the
+// entry's fields.
+func NewFormatter(format string) (logrus.Formatter, error) {
+	switch format {
+	case FormatText, "":
+		return &logrus.TextFormatter{
+			FullTimestamp:   true,
+			TimestampFormat: time.RFC3339,
+		}, nil
+	case FormatJSON:
+		return &logrus.JSONFormatter{
+			TimestampFormat: time.RFC3339Nano,
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown log format %q (want text or json)", format)
+}
+
+// RotateConfig says when a log file is rotated and how many rotated files
+// are kept. Zero fields disable that limit.
+type RotateConfig struct {
+	// MaxBytes rotates the file before a write would take it over this size.
+	MaxBytes int64
+	// MaxAge rotates the file once it has been written to for this long.
+	MaxAge time.Duration
+	// MaxBackups is how many rotated files are kept; older ones are removed.
+	MaxBackups int
+}
+
+// backupTimeFormat names rotated files; it sorts in time order.
+const backupTimeFormat = "20060102T150405.000"
+
+// File is a log file that rotates itself: past its size or age, it is
+// renamed with the time of rotation (app.log becomes
+// app-20250102T150405.000.log) and a new file is started. It is safe for
+// concurrent use, so loggers can share it.
+type File struct {
+	path string
+	cfg  RotateConfig
+
+	mu     sync.Mutex
+	f      *os.File
+	size   int64
+	opened time.Time
+}
+
+// OpenFile opens the log file at path, appending to it if it exists.
+func OpenFile(path string, cfg RotateConfig) (*File, error) {
+	f := &File{path: path, cfg: cfg}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// open opens the file at f.path. Callers hold f.mu.
+func (f *File) open() error {
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.f, f.size, f.opened = file, info.Size(), time.Now()
+	return nil
+}
+
+// Write writes p to the file, rotating it first if it is due.
+func (f *File) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.f == nil {
+		return 0, os.ErrClosed
+	}
+	if f.due(int64(len(p))) {
+		if err := f.rotate(); err != nil {
+			// Keep logging to the current file rather than losing lines
+			fmt.Fprintf(os.Stderr, "Failed to rotate log file %s: %v\n", f.path, err)
+		}
+	}
+	n, err := f.f.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// due reports whether the file must be rotated before writing n bytes.
+// Callers hold f.mu.
+func (f *File) due(n int64) bool {
+	if f.size == 0 {
+		return false
+	}
+	if f.cfg.MaxBytes > 0 && f.size+n > f.cfg.MaxBytes {
+		return true
+	}
+	return f.cfg.MaxAge > 0 && time.Since(f.opened) >= f.cfg.MaxAge
+}
+
+// rotate renames the file to its backup name, starts a new one and removes
+// backups past MaxBackups. Callers hold f.mu.
+func (f *File) rotate() error {
+	ext := filepath.Ext(f.path)
+	backup := strings.TrimSuffix(f.path, ext) + "-" + time.Now().Format(backupTimeFormat) + ext
+	if err := os.Rename(f.path, backup); err != nil {
+		return err
+	}
+	old := f.f
+	if err := f.open(); err != nil {
+		// Put the file back, so writes carry on where they were
+		os.Rename(backup, f.path)
+		return err
+	}
+	old.Close()
+	f.prune()
+	return nil
+}
+
+// prune removes the oldest backups past MaxBackups. Callers hold f.mu.
+func (f *File) prune() {
+	if f.cfg.MaxBackups <= 0 {
+		return
+	}
+	backups := f.backups()
+	for len(backups) > f.cfg.MaxBackups {
+		if err := os.Remove(backups[0]); err != nil && !os.IsNotExist(err) {
+			fmt.Fprintf(os.Stderr, "Failed to remove old log file %s: %v\n", backups[0], err)
+		}
+		backups = backups[1:]
+	}
+}
+
+// backups returns the file's rotated files, oldest first.
+func (f *File) backups() []string {
+	ext := filepath.Ext(f.path)
+	prefix := strings.TrimSuffix(f.path, ext) + "-"
+	matches, _ := filepath.Glob(prefix + "*" + ext)
+	var backups []string
+	for _, match := range matches {
+		stamp := strings.TrimSuffix(strings.TrimPrefix(match, prefix), ext)
+		if _, err := time.Parse(backupTimeFormat, stamp); err == nil {
+			backups = append(backups, match)
+		}
+	}
+	sort.Strings(backups)
+	return backups
+}
+
+// Reopen reopens the file at its path, after it was moved by an external
+// log rotator.
+func (f *File) Reopen() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	old := f.f
+	if err := f.open(); err != nil {
+		return err
+	}
+	if old != nil {
+		old.Close()
+	}
+	return nil
+}
+
+// Close closes the file; later writes fail.
+func (f *File) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.f == nil {
+		return nil
+	}
+	err := f.f.Close()
+	f.f = nil
+	return err
+}
@@ -1,4 +1,8 @@
 // Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.2.0
+// - protoc             v3.21.12
+// source: translation.proto
 
 package nanabushv1
 
@@ -11,6 +15,7 @@ import (
 
 // This is a compile-time assertion to ensure that this generated file
 // is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
 const _ = grpc.SupportPackageIsVersion7
 
 // TranslationServiceClient is the client API for TranslationService service.
@@ -19,12 +24,24 @@ const _ = grpc.SupportPackageIsVersion7
 type TranslationServiceClient interface {
 	// RegisterClient registers a new client with the server.
 	// This should be called immediately after establishing a connection.
-	// Returns a client_id that should be used for subsequent heartbeats.
+	// Returns a client_id that should be used for subsequent heartbeats, and the
+	// policy derived from the client's labels. Labels that don't match the
+	// server's label schema are rejected with INVALID_ARGUMENT.
 	RegisterClient(ctx context.Context, in *RegisterClientRequest, opts ...grpc.CallOption) (*RegisterClientResponse, error)
 	// Heartbeat sends a keepalive and re-authentication signal from the client.
 	// Should be called periodically (recommended: every 30-60 seconds).
 	// If the socket is torn down, the client should re-register.
 	Heartbeat(ctx context.Context, in *HeartbeatRequest, opts ...grpc.CallOption) (*HeartbeatResponse, error)
+	// HeartbeatStream is a long-lived alternative to Heartbeat. The client sends
+	// heartbeats on the stream and receives a response to each one. When the
+	// stream closes (client exit, network failure) the server unregisters the
+	// client immediately instead of waiting for idle cleanup.
+	HeartbeatStream(ctx context.Context, opts ...grpc.CallOption) (TranslationService_HeartbeatStreamClient, error)
+	// WatchConfig streams client-facing configuration (heartbeat interval,
+	// maintenance notices, supported languages) to a registered client. The
+	// current configuration is sent immediately and again whenever it changes,
+	// so clients pick up changes without reconnecting.
+	WatchConfig(ctx context.Context, in *WatchConfigRequest, opts ...grpc.CallOption) (TranslationService_WatchConfigClient, error)
 	// CheckTitle performs a lightweight pre-flight check with title only.
 	// This validates that Iskoces is ready and can handle the request.
 	CheckTitle(ctx context.Context, in *TitleCheckRequest, opts ...grpc.CallOption) (*TitleCheckResponse, error)
@@ -33,7 +50,48 @@ type TranslationServiceClient interface {
 	Translate(ctx context.Context, in *TranslateRequest, opts ...grpc.CallOption) (*TranslateResponse, error)
 	// TranslateStream supports streaming for large documents.
 	// Client sends chunks, server responds with translated chunks.
+	// Chunks are translated concurrently but emitted in chunk_index order per
+	// ordering_key. The client requests a window_size on its first message and
+	// the server echoes the negotiated value before any translated chunk; the
+	// client may then have at most that many chunks outstanding (sent but not
+	// yet received back), otherwise the stream fails with RESOURCE_EXHAUSTED.
 	TranslateStream(ctx context.Context, opts ...grpc.CallOption) (TranslationService_TranslateStreamClient, error)
+	// DetectLanguage identifies the language of a text sample.
+	// Returns candidate languages ordered by descending confidence, so callers
+	// can decide whether a document needs translation at all.
+	DetectLanguage(ctx context.Context, in *DetectLanguageRequest, opts ...grpc.CallOption) (*DetectLanguageResponse, error)
+	// SubmitTranslation queues a translation job and returns immediately with a job ID.
+	// Use GetTranslationStatus to poll progress and GetTranslationResult to fetch output,
+	// instead of holding a unary Translate call open for large documents.
+	SubmitTranslation(ctx context.Context, in *TranslateRequest, opts ...grpc.CallOption) (*SubmitTranslationResponse, error)
+	// GetTranslationStatus returns the current state and progress of a submitted job.
+	GetTranslationStatus(ctx context.Context, in *GetTranslationStatusRequest, opts ...grpc.CallOption) (*TranslationStatus, error)
+	// GetTranslationResult returns the output of a finished job.
+	// Returns FAILED_PRECONDITION if the job is still queued or processing, and the
+	// job's error (with google.rpc.ErrorInfo details) if it failed or was cancelled.
+	GetTranslationResult(ctx context.Context, in *GetTranslationResultRequest, opts ...grpc.CallOption) (*TranslateResponse, error)
+	// CancelTranslation cancels a queued or in-flight job.
+	// Chunk processing stops and any outstanding worker request is abandoned.
+	// Returns FAILED_PRECONDITION if the job already finished.
+	CancelTranslation(ctx context.Context, in *CancelTranslationRequest, opts ...grpc.CallOption) (*TranslationStatus, error)
+	// BatchTranslate translates many short strings (UI labels, titles) in one call.
+	// Translations are returned in the same order as the input texts.
+	BatchTranslate(ctx context.Context, in *BatchTranslateRequest, opts ...grpc.CallOption) (*BatchTranslateResponse, error)
+	// GetServerInfo reports server version, engine, limits, and supported features
+	// so clients can adapt (e.g., chunk size) without out-of-band configuration.
+	GetServerInfo(ctx context.Context, in *GetServerInfoRequest, opts ...grpc.CallOption) (*GetServerInfoResponse, error)
+	// TranslateDocumentSet translates an ordered set of related documents (e.g.
+	// a docs site section) as one job. The documents share a translation memory
+	// (identical titles and paragraphs are translated once and reused) and an
+	// optional glossary, keeping terminology consistent across the set. The
+	// server streams aggregated progress and each translated document, in order.
+	TranslateDocumentSet(ctx context.Context, in *DocumentSetRequest, opts ...grpc.CallOption) (TranslationService_TranslateDocumentSetClient, error)
+	// ReportTranslationFeedback records a reviewer's post-edit feedback (corrected
+	// text and/or rating) for one segment of a translation. Feedback is stored for
+	// seeding translation memory and comparing engine quality. When job_id names a
+	// job the server still holds, missing source and machine text are filled in
+	// from the job.
+	ReportTranslationFeedback(ctx context.Context, in *TranslationFeedback, opts ...grpc.CallOption) (*ReportTranslationFeedbackResponse, error)
 }
 
 type translationServiceClient struct {
@@ -62,6 +120,69 @@ func (c *translationServiceClient) Heartbeat(ctx context.Context, in *HeartbeatR
 	return out, nil
 }
 
+func (c *translationServiceClient) HeartbeatStream(ctx context.Context, opts ...grpc.CallOption) (TranslationService_HeartbeatStreamClient, error) {
+	stream, err := c.cc.NewStream(ctx, &TranslationService_ServiceDesc.Streams[0], "/nanabush.v1.TranslationService/HeartbeatStream", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &translationServiceHeartbeatStreamClient{stream}
+	return x, nil
+}
+
+type TranslationService_HeartbeatStreamClient interface {
+	Send(*HeartbeatRequest) error
+	Recv() (*HeartbeatResponse, error)
+	grpc.ClientStream
+}
+
+type translationServiceHeartbeatStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *translationServiceHeartbeatStreamClient) Send(m *HeartbeatRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *translationServiceHeartbeatStreamClient) Recv() (*HeartbeatResponse, error) {
+	m := new(HeartbeatResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *translationServiceClient) WatchConfig(ctx context.Context, in *WatchConfigRequest, opts ...grpc.CallOption) (TranslationService_WatchConfigClient, error) {
+	stream, err := c.cc.NewStream(ctx, &TranslationService_ServiceDesc.Streams[1], "/nanabush.v1.TranslationService/WatchConfig", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &translationServiceWatchConfigClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type TranslationService_WatchConfigClient interface {
+	Recv() (*ConfigUpdate, error)
+	grpc.ClientStream
+}
+
+type translationServiceWatchConfigClient struct {
+	grpc.ClientStream
+}
+
+func (x *translationServiceWatchConfigClient) Recv() (*ConfigUpdate, error) {
+	m := new(ConfigUpdate)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 func (c *translationServiceClient) CheckTitle(ctx context.Context, in *TitleCheckRequest, opts ...grpc.CallOption) (*TitleCheckResponse, error) {
 	out := new(TitleCheckResponse)
 	err := c.cc.Invoke(ctx, "/nanabush.v1.TranslationService/CheckTitle", in, out, opts...)
@@ -81,7 +202,7 @@ func (c *translationServiceClient) Translate(ctx context.Context, in *TranslateR
 }
 
 func (c *translationServiceClient) TranslateStream(ctx context.Context, opts ...grpc.CallOption) (TranslationService_TranslateStreamClient, error) {
-	stream, err := c.cc.NewStream(ctx, &_TranslationService_serviceDesc.Streams[0], "/nanabush.v1.TranslationService/TranslateStream", opts...)
+	stream, err := c.cc.NewStream(ctx, &TranslationService_ServiceDesc.Streams[2], "/nanabush.v1.TranslationService/TranslateStream", opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -111,18 +232,134 @@ func (x *translationServiceTranslateStreamClient) Recv() (*TranslateChunk, error
 	return m, nil
 }
 
+func (c *translationServiceClient) DetectLanguage(ctx context.Context, in *DetectLanguageRequest, opts ...grpc.CallOption) (*DetectLanguageResponse, error) {
+	out := new(DetectLanguageResponse)
+	err := c.cc.Invoke(ctx, "/nanabush.v1.TranslationService/DetectLanguage", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *translationServiceClient) SubmitTranslation(ctx context.Context, in *TranslateRequest, opts ...grpc.CallOption) (*SubmitTranslationResponse, error) {
+	out := new(SubmitTranslationResponse)
+	err := c.cc.Invoke(ctx, "/nanabush.v1.TranslationService/SubmitTranslation", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *translationServiceClient) GetTranslationStatus(ctx context.Context, in *GetTranslationStatusRequest, opts ...grpc.CallOption) (*TranslationStatus, error) {
+	out := new(TranslationStatus)
+	err := c.cc.Invoke(ctx, "/nanabush.v1.TranslationService/GetTranslationStatus", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *translationServiceClient) GetTranslationResult(ctx context.Context, in *GetTranslationResultRequest, opts ...grpc.CallOption) (*TranslateResponse, error) {
+	out := new(TranslateResponse)
+	err := c.cc.Invoke(ctx, "/nanabush.v1.TranslationService/GetTranslationResult", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *translationServiceClient) CancelTranslation(ctx context.Context, in *CancelTranslationRequest, opts ...grpc.CallOption) (*TranslationStatus, error) {
+	out := new(TranslationStatus)
+	err := c.cc.Invoke(ctx, "/nanabush.v1.TranslationService/CancelTranslation", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *translationServiceClient) BatchTranslate(ctx context.Context, in *BatchTranslateRequest, opts ...grpc.CallOption) (*BatchTranslateResponse, error) {
+	out := new(BatchTranslateResponse)
+	err := c.cc.Invoke(ctx, "/nanabush.v1.TranslationService/BatchTranslate", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *translationServiceClient) GetServerInfo(ctx context.Context, in *GetServerInfoRequest, opts ...grpc.CallOption) (*GetServerInfoResponse, error) {
+	out := new(GetServerInfoResponse)
+	err := c.cc.Invoke(ctx, "/nanabush.v1.TranslationService/GetServerInfo", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *translationServiceClient) TranslateDocumentSet(ctx context.Context, in *DocumentSetRequest, opts ...grpc.CallOption) (TranslationService_TranslateDocumentSetClient, error) {
+	stream, err := c.cc.NewStream(ctx, &TranslationService_ServiceDesc.Streams[3], "/nanabush.v1.TranslationService/TranslateDocumentSet", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &translationServiceTranslateDocumentSetClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type TranslationService_TranslateDocumentSetClient interface {
+	Recv() (*DocumentSetProgress, error)
+	grpc.ClientStream
+}
+
+type translationServiceTranslateDocumentSetClient struct {
+	grpc.ClientStream
+}
+
+func (x *translationServiceTranslateDocumentSetClient) Recv() (*DocumentSetProgress, error) {
+	m := new(DocumentSetProgress)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *translationServiceClient) ReportTranslationFeedback(ctx context.Context, in *TranslationFeedback, opts ...grpc.CallOption) (*ReportTranslationFeedbackResponse, error) {
+	out := new(ReportTranslationFeedbackResponse)
+	err := c.cc.Invoke(ctx, "/nanabush.v1.TranslationService/ReportTranslationFeedback", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // TranslationServiceServer is the server API for TranslationService service.
 // All implementations must embed UnimplementedTranslationServiceServer
 // for forward compatibility
 type TranslationServiceServer interface {
 	// RegisterClient registers a new client with the server.
 	// This should be called immediately after establishing a connection.
-	// Returns a client_id that should be used for subsequent heartbeats.
+	// Returns a client_id that should be used for subsequent heartbeats, and the
+	// policy derived from the client's labels. Labels that don't match the
+	// server's label schema are rejected with INVALID_ARGUMENT.
 	RegisterClient(context.Context, *RegisterClientRequest) (*RegisterClientResponse, error)
 	// Heartbeat sends a keepalive and re-authentication signal from the client.
 	// Should be called periodically (recommended: every 30-60 seconds).
 	// If the socket is torn down, the client should re-register.
 	Heartbeat(context.Context, *HeartbeatRequest) (*HeartbeatResponse, error)
+	// HeartbeatStream is a long-lived alternative to Heartbeat. The client sends
+	// heartbeats on the stream and receives a response to each one. When the
+	// stream closes (client exit, network failure) the server unregisters the
+	// client immediately instead of waiting for idle cleanup.
+	HeartbeatStream(TranslationService_HeartbeatStreamServer) error
+	// WatchConfig streams client-facing configuration (heartbeat interval,
+	// maintenance notices, supported languages) to a registered client. The
+	// current configuration is sent immediately and again whenever it changes,
+	// so clients pick up changes without reconnecting.
+	WatchConfig(*WatchConfigRequest, TranslationService_WatchConfigServer) error
 	// CheckTitle performs a lightweight pre-flight check with title only.
 	// This validates that Iskoces is ready and can handle the request.
 	CheckTitle(context.Context, *TitleCheckRequest) (*TitleCheckResponse, error)
@@ -131,7 +368,48 @@ type TranslationServiceServer interface {
 	Translate(context.Context, *TranslateRequest) (*TranslateResponse, error)
 	// TranslateStream supports streaming for large documents.
 	// Client sends chunks, server responds with translated chunks.
+	// Chunks are translated concurrently but emitted in chunk_index order per
+	// ordering_key. The client requests a window_size on its first message and
+	// the server echoes the negotiated value before any translated chunk; the
+	// client may then have at most that many chunks outstanding (sent but not
+	// yet received back), otherwise the stream fails with RESOURCE_EXHAUSTED.
 	TranslateStream(TranslationService_TranslateStreamServer) error
+	// DetectLanguage identifies the language of a text sample.
+	// Returns candidate languages ordered by descending confidence, so callers
+	// can decide whether a document needs translation at all.
+	DetectLanguage(context.Context, *DetectLanguageRequest) (*DetectLanguageResponse, error)
+	// SubmitTranslation queues a translation job and returns immediately with a job ID.
+	// Use GetTranslationStatus to poll progress and GetTranslationResult to fetch output,
+	// instead of holding a unary Translate call open for large documents.
+	SubmitTranslation(context.Context, *TranslateRequest) (*SubmitTranslationResponse, error)
+	// GetTranslationStatus returns the current state and progress of a submitted job.
+	GetTranslationStatus(context.Context, *GetTranslationStatusRequest) (*TranslationStatus, error)
+	// GetTranslationResult returns the output of a finished job.
+	// Returns FAILED_PRECONDITION if the job is still queued or processing, and the
+	// job's error (with google.rpc.ErrorInfo details) if it failed or was cancelled.
+	GetTranslationResult(context.Context, *GetTranslationResultRequest) (*TranslateResponse, error)
+	// CancelTranslation cancels a queued or in-flight job.
+	// Chunk processing stops and any outstanding worker request is abandoned.
+	// Returns FAILED_PRECONDITION if the job already finished.
+	CancelTranslation(context.Context, *CancelTranslationRequest) (*TranslationStatus, error)
+	// BatchTranslate translates many short strings (UI labels, titles) in one call.
+	// Translations are returned in the same order as the input texts.
+	BatchTranslate(context.Context, *BatchTranslateRequest) (*BatchTranslateResponse, error)
+	// GetServerInfo reports server version, engine, limits, and supported features
+	// so clients can adapt (e.g., chunk size) without out-of-band configuration.
+	GetServerInfo(context.Context, *GetServerInfoRequest) (*GetServerInfoResponse, error)
+	// TranslateDocumentSet translates an ordered set of related documents (e.g.
+	// a docs site section) as one job. The documents share a translation memory
+	// (identical titles and paragraphs are translated once and reused) and an
+	// optional glossary, keeping terminology consistent across the set. The
+	// server streams aggregated progress and each translated document, in order.
+	TranslateDocumentSet(*DocumentSetRequest, TranslationService_TranslateDocumentSetServer) error
+	// ReportTranslationFeedback records a reviewer's post-edit feedback (corrected
+	// text and/or rating) for one segment of a translation. Feedback is stored for
+	// seeding translation memory and comparing engine quality. When job_id names a
+	// job the server still holds, missing source and machine text are filled in
+	// from the job.
+	ReportTranslationFeedback(context.Context, *TranslationFeedback) (*ReportTranslationFeedbackResponse, error)
 	mustEmbedUnimplementedTranslationServiceServer()
 }
 
@@ -145,6 +423,12 @@ func (UnimplementedTranslationServiceServer) RegisterClient(context.Context, *Re
 func (UnimplementedTranslationServiceServer) Heartbeat(context.Context, *HeartbeatRequest) (*HeartbeatResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Heartbeat not implemented")
 }
+func (UnimplementedTranslationServiceServer) HeartbeatStream(TranslationService_HeartbeatStreamServer) error {
+	return status.Errorf(codes.Unimplemented, "method HeartbeatStream not implemented")
+}
+func (UnimplementedTranslationServiceServer) WatchConfig(*WatchConfigRequest, TranslationService_WatchConfigServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchConfig not implemented")
+}
 func (UnimplementedTranslationServiceServer) CheckTitle(context.Context, *TitleCheckRequest) (*TitleCheckResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method CheckTitle not implemented")
 }
@@ -154,6 +438,33 @@ func (UnimplementedTranslationServiceServer) Translate(context.Context, *Transla
 func (UnimplementedTranslationServiceServer) TranslateStream(TranslationService_TranslateStreamServer) error {
 	return status.Errorf(codes.Unimplemented, "method TranslateStream not implemented")
 }
+func (UnimplementedTranslationServiceServer) DetectLanguage(context.Context, *DetectLanguageRequest) (*DetectLanguageResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DetectLanguage not implemented")
+}
+func (UnimplementedTranslationServiceServer) SubmitTranslation(context.Context, *TranslateRequest) (*SubmitTranslationResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SubmitTranslation not implemented")
+}
+func (UnimplementedTranslationServiceServer) GetTranslationStatus(context.Context, *GetTranslationStatusRequest) (*TranslationStatus, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetTranslationStatus not implemented")
+}
+func (UnimplementedTranslationServiceServer) GetTranslationResult(context.Context, *GetTranslationResultRequest) (*TranslateResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetTranslationResult not implemented")
+}
+func (UnimplementedTranslationServiceServer) CancelTranslation(context.Context, *CancelTranslationRequest) (*TranslationStatus, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CancelTranslation not implemented")
+}
+func (UnimplementedTranslationServiceServer) BatchTranslate(context.Context, *BatchTranslateRequest) (*BatchTranslateResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BatchTranslate not implemented")
+}
+func (UnimplementedTranslationServiceServer) GetServerInfo(context.Context, *GetServerInfoRequest) (*GetServerInfoResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetServerInfo not implemented")
+}
+func (UnimplementedTranslationServiceServer) TranslateDocumentSet(*DocumentSetRequest, TranslationService_TranslateDocumentSetServer) error {
+	return status.Errorf(codes.Unimplemented, "method TranslateDocumentSet not implemented")
+}
+func (UnimplementedTranslationServiceServer) ReportTranslationFeedback(context.Context, *TranslationFeedback) (*ReportTranslationFeedbackResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReportTranslationFeedback not implemented")
+}
 func (UnimplementedTranslationServiceServer) mustEmbedUnimplementedTranslationServiceServer() {}
 
 // UnsafeTranslationServiceServer may be embedded to opt out of forward compatibility for this service.
@@ -163,8 +474,8 @@ type UnsafeTranslationServiceServer interface {
 	mustEmbedUnimplementedTranslationServiceServer()
 }
 
-func RegisterTranslationServiceServer(s *grpc.Server, srv TranslationServiceServer) {
-	s.RegisterService(&_TranslationService_serviceDesc, srv)
+func RegisterTranslationServiceServer(s grpc.ServiceRegistrar, srv TranslationServiceServer) {
+	s.RegisterService(&TranslationService_ServiceDesc, srv)
 }
 
 func _TranslationService_RegisterClient_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
@@ -203,6 +514,53 @@ func _TranslationService_Heartbeat_Handler(srv interface{}, ctx context.Context,
 	return interceptor(ctx, in, info, handler)
 }
 
+func _TranslationService_HeartbeatStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(TranslationServiceServer).HeartbeatStream(&translationServiceHeartbeatStreamServer{stream})
+}
+
+type TranslationService_HeartbeatStreamServer interface {
+	Send(*HeartbeatResponse) error
+	Recv() (*HeartbeatRequest, error)
+	grpc.ServerStream
+}
+
+type translationServiceHeartbeatStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *translationServiceHeartbeatStreamServer) Send(m *HeartbeatResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *translationServiceHeartbeatStreamServer) Recv() (*HeartbeatRequest, error) {
+	m := new(HeartbeatRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _TranslationService_WatchConfig_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchConfigRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(TranslationServiceServer).WatchConfig(m, &translationServiceWatchConfigServer{stream})
+}
+
+type TranslationService_WatchConfigServer interface {
+	Send(*ConfigUpdate) error
+	grpc.ServerStream
+}
+
+type translationServiceWatchConfigServer struct {
+	grpc.ServerStream
+}
+
+func (x *translationServiceWatchConfigServer) Send(m *ConfigUpdate) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 func _TranslationService_CheckTitle_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(TitleCheckRequest)
 	if err := dec(in); err != nil {
@@ -265,7 +623,175 @@ func (x *translationServiceTranslateStreamServer) Recv() (*TranslateChunk, error
 	return m, nil
 }
 
-var _TranslationService_serviceDesc = grpc.ServiceDesc{
+func _TranslationService_DetectLanguage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DetectLanguageRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TranslationServiceServer).DetectLanguage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/nanabush.v1.TranslationService/DetectLanguage",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TranslationServiceServer).DetectLanguage(ctx, req.(*DetectLanguageRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TranslationService_SubmitTranslation_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TranslateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TranslationServiceServer).SubmitTranslation(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/nanabush.v1.TranslationService/SubmitTranslation",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TranslationServiceServer).SubmitTranslation(ctx, req.(*TranslateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TranslationService_GetTranslationStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetTranslationStatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TranslationServiceServer).GetTranslationStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/nanabush.v1.TranslationService/GetTranslationStatus",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TranslationServiceServer).GetTranslationStatus(ctx, req.(*GetTranslationStatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TranslationService_GetTranslationResult_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetTranslationResultRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TranslationServiceServer).GetTranslationResult(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/nanabush.v1.TranslationService/GetTranslationResult",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TranslationServiceServer).GetTranslationResult(ctx, req.(*GetTranslationResultRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TranslationService_CancelTranslation_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CancelTranslationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TranslationServiceServer).CancelTranslation(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/nanabush.v1.TranslationService/CancelTranslation",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TranslationServiceServer).CancelTranslation(ctx, req.(*CancelTranslationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TranslationService_BatchTranslate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchTranslateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TranslationServiceServer).BatchTranslate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/nanabush.v1.TranslationService/BatchTranslate",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TranslationServiceServer).BatchTranslate(ctx, req.(*BatchTranslateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TranslationService_GetServerInfo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetServerInfoRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TranslationServiceServer).GetServerInfo(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/nanabush.v1.TranslationService/GetServerInfo",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TranslationServiceServer).GetServerInfo(ctx, req.(*GetServerInfoRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TranslationService_TranslateDocumentSet_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(DocumentSetRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(TranslationServiceServer).TranslateDocumentSet(m, &translationServiceTranslateDocumentSetServer{stream})
+}
+
+type TranslationService_TranslateDocumentSetServer interface {
+	Send(*DocumentSetProgress) error
+	grpc.ServerStream
+}
+
+type translationServiceTranslateDocumentSetServer struct {
+	grpc.ServerStream
+}
+
+func (x *translationServiceTranslateDocumentSetServer) Send(m *DocumentSetProgress) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _TranslationService_ReportTranslationFeedback_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TranslationFeedback)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TranslationServiceServer).ReportTranslationFeedback(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/nanabush.v1.TranslationService/ReportTranslationFeedback",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TranslationServiceServer).ReportTranslationFeedback(ctx, req.(*TranslationFeedback))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// TranslationService_ServiceDesc is the grpc.ServiceDesc for TranslationService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var TranslationService_ServiceDesc = grpc.ServiceDesc{
 	ServiceName: "nanabush.v1.TranslationService",
 	HandlerType: (*TranslationServiceServer)(nil),
 	Methods: []grpc.MethodDesc{
@@ -285,14 +811,62 @@ var _TranslationService_serviceDesc = grpc.ServiceDesc{
 			MethodName: "Translate",
 			Handler:    _TranslationService_Translate_Handler,
 		},
+		{
+			MethodName: "DetectLanguage",
+			Handler:    _TranslationService_DetectLanguage_Handler,
+		},
+		{
+			MethodName: "SubmitTranslation",
+			Handler:    _TranslationService_SubmitTranslation_Handler,
+		},
+		{
+			MethodName: "GetTranslationStatus",
+			Handler:    _TranslationService_GetTranslationStatus_Handler,
+		},
+		{
+			MethodName: "GetTranslationResult",
+			Handler:    _TranslationService_GetTranslationResult_Handler,
+		},
+		{
+			MethodName: "CancelTranslation",
+			Handler:    _TranslationService_CancelTranslation_Handler,
+		},
+		{
+			MethodName: "BatchTranslate",
+			Handler:    _TranslationService_BatchTranslate_Handler,
+		},
+		{
+			MethodName: "GetServerInfo",
+			Handler:    _TranslationService_GetServerInfo_Handler,
+		},
+		{
+			MethodName: "ReportTranslationFeedback",
+			Handler:    _TranslationService_ReportTranslationFeedback_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "HeartbeatStream",
+			Handler:       _TranslationService_HeartbeatStream_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "WatchConfig",
+			Handler:       _TranslationService_WatchConfig_Handler,
+			ServerStreams: true,
+		},
 		{
 			StreamName:    "TranslateStream",
 			Handler:       _TranslationService_TranslateStream_Handler,
 			ServerStreams: true,
 			ClientStreams: true,
 		},
+		{
+			StreamName:    "TranslateDocumentSet",
+			Handler:       _TranslationService_TranslateDocumentSet_Handler,
+			ServerStreams: true,
+		},
 	},
 	Metadata: "translation.proto",
 }
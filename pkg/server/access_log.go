@@ -0,0 +1,146 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/dasmlab/iskoces/pkg/service"
+)
+
+// requestIDHeader is the HTTP header carrying the request ID, the same as
+// the gRPC x-request-id metadata.
+var requestIDHeader = http.CanonicalHeaderKey(service.RequestIDMetadataKey)
+
+// accessLogKey is the context key of a request's accessInfo.
+type accessLogKey struct{}
+
+// accessInfo is what handlers learn about a request for its access log
+// entry.
+type accessInfo struct {
+	caller string // Authenticated caller's name
+}
+
+// setCaller records the authenticated caller of the request with ctx for
+// the access log.
+func setCaller(ctx context.Context, name string) {
+	if info, ok := ctx.Value(accessLogKey{}).(*accessInfo); ok {
+		info.caller = name
+	}
+}
+
+// SetAccessLogger logs served requests to logger instead of the server's
+// logger, e.g. to give access logs their own level. It must be called
+// before Start.
+func (s *HTTPServer) SetAccessLogger(logger *logrus.Logger) {
+	s.accessLogger = logger
+}
+
+// withAccessLog assigns every request an ID, the caller's X-Request-Id or a
+// new one, which is echoed in the response and passed on to gRPC methods
+// called over HTTP, and logs the request once it is served, like the gRPC
+// logging interceptors: successes at debug, client errors at info and
+// server errors at warn. The query string isn't logged, as it may hold an
+// access token.
+func (s *HTTPServer) withAccessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(requestIDHeader)
+		if requestID == "" {
+			requestID = uuid.New().String()
+			r.Header.Set(requestIDHeader, requestID)
+		}
+		w.Header().Set(requestIDHeader, requestID)
+
+		info := &accessInfo{}
+		recorder := &accessRecorder{ResponseWriter: w}
+		start := time.Now()
+		next.ServeHTTP(recorder, r.WithContext(context.WithValue(r.Context(), accessLogKey{}, info)))
+
+		code := recorder.status
+		if code == 0 {
+			code = http.StatusOK
+		}
+		fields := logrus.Fields{
+			"method":      r.Method,
+			"proto":       r.Proto,
+			"path":        r.URL.Path,
+			"status":      code,
+			"duration_ms": time.Since(start).Milliseconds(),
+			"bytes":       recorder.bytes,
+			"request_id":  requestID,
+			"remote":      s.clientIP(r),
+		}
+		if r.ContentLength > 0 {
+			fields["request_bytes"] = r.ContentLength
+		}
+		if info.caller != "" {
+			fields["caller"] = info.caller
+		}
+		if recorder.hijacked {
+			fields["upgraded"] = true
+		}
+		entry := s.accessLogger.WithFields(fields)
+		switch {
+		case code >= 500:
+			entry.Warn("HTTP request failed")
+		case code >= 400:
+			entry.Info("HTTP request failed")
+		default:
+			entry.Debug("HTTP request completed")
+		}
+	})
+}
+
+// accessRecorder records a response's status code and size. It passes
+// flushes through for event streams and hijacking for WebSockets.
+type accessRecorder struct {
+	http.ResponseWriter
+	status   int
+	bytes    int64
+	hijacked bool
+}
+
+func (a *accessRecorder) WriteHeader(code int) {
+	if a.status == 0 {
+		a.status = code
+	}
+	a.ResponseWriter.WriteHeader(code)
+}
+
+func (a *accessRecorder) Write(p []byte) (int, error) {
+	if a.status == 0 {
+		a.status = http.StatusOK
+	}
+	n, err := a.ResponseWriter.Write(p)
+	a.bytes += int64(n)
+	return n, err
+}
+
+func (a *accessRecorder) Flush() {
+	a.FlushError()
+}
+
+// FlushError lets http.ResponseController report failed flushes, which is
+// how event streams notice that their client is gone.
+func (a *accessRecorder) FlushError() error {
+	return http.NewResponseController(a.ResponseWriter).Flush()
+}
+
+func (a *accessRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(a.ResponseWriter).Hijack()
+	if err == nil {
+		a.hijacked = true
+		a.status = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (a *accessRecorder) Unwrap() http.ResponseWriter {
+	return a.ResponseWriter
+}
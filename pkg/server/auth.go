@@ -0,0 +1,396 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"github.com/dasmlab/iskoces/pkg/service"
+)
+
+// APIKeyHeader is the request header carrying an API key.
+const APIKeyHeader = "X-API-Key"
+
+// ErrNoCredentials is returned by an Authenticator when a request carries
+// no credentials of the kind it checks.
+var ErrNoCredentials = errors.New("no credentials")
+
+// Principal is an authenticated HTTP caller.
+type Principal struct {
+	// Name identifies the caller in logs: the API key's name or the JWT
+	// subject.
+	Name string
+	// Namespaces are the namespaces the caller may use; empty means all.
+	Namespaces []string
+}
+
+// Allows reports whether the caller may use namespace. A nil Principal
+// (no authentication configured) allows every namespace.
+func (p *Principal) Allows(namespace string) bool {
+	return p == nil || len(p.Namespaces) == 0 || slices.Contains(p.Namespaces, namespace)
+}
+
+// bound reports whether the caller is limited to some namespaces.
+func (p *Principal) bound() bool {
+	return p != nil && len(p.Namespaces) > 0
+}
+
+// defaultNamespace returns the namespace a bound caller's requests use when
+// they don't name one: its only namespace, or "" if it has several.
+func (p *Principal) defaultNamespace() string {
+	if p != nil && len(p.Namespaces) == 1 {
+		return p.Namespaces[0]
+	}
+	return ""
+}
+
+// Authenticator authenticates HTTP requests.
+type Authenticator interface {
+	// Authenticate returns the request's caller. It returns
+	// ErrNoCredentials if the request carries no credentials it checks,
+	// and another error if they are invalid.
+	Authenticate(r *http.Request) (*Principal, error)
+}
+
+// Authenticators combines authenticators: the first that finds credentials
+// in a request decides.
+func Authenticators(auths ...Authenticator) Authenticator {
+	return authChain(auths)
+}
+
+type authChain []Authenticator
+
+func (c authChain) Authenticate(r *http.Request) (*Principal, error) {
+	for _, auth := range c {
+		p, err := auth.Authenticate(r)
+		if !errors.Is(err, ErrNoCredentials) {
+			return p, err
+		}
+	}
+	return nil, ErrNoCredentials
+}
+
+// SetAuthenticator requires callers of the job, batch, translate, gateway
+// and worker endpoints to authenticate with auth. Their namespaces are
+// enforced: jobs and batches of other namespaces are not found, requests
+// for other namespaces are denied, and requests without a namespace use
+// the caller's only one. /debug/workers needs a caller bound to no
+// namespace. It must be called before Start.
+func (s *HTTPServer) SetAuthenticator(auth Authenticator) {
+	s.auth = auth
+}
+
+// principalKey is the request context key of the authenticated Principal.
+type principalKey struct{}
+
+// principalFrom returns the caller of a request, or nil if authentication
+// isn't configured.
+func principalFrom(ctx context.Context) *Principal {
+	p, _ := ctx.Value(principalKey{}).(*Principal)
+	return p
+}
+
+// authenticated wraps a handler with authentication, if configured, and
+// charges the request to the caller's rate limit (see Limits). An
+// X-Namespace header (gRPC metadata for quotas) must be one of the caller's
+// namespaces, and is set to its only one when missing.
+func (s *HTTPServer) authenticated(next http.HandlerFunc) http.HandlerFunc {
+	if s.auth == nil {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		p, err := s.auth.Authenticate(r)
+		if err != nil {
+			message := "authentication required: use an API key or a bearer token"
+			if !errors.Is(err, ErrNoCredentials) {
+				s.logger.WithError(err).WithFields(logrus.Fields{
+					"path":   r.URL.Path,
+					"remote": r.RemoteAddr,
+				}).Warn("HTTP authentication failed")
+				message = fmt.Sprintf("invalid credentials: %v", err)
+			}
+			w.Header().Set("WWW-Authenticate", `Bearer realm="iskoces"`)
+			writeStatusError(w, status.Error(codes.Unauthenticated, message))
+			return
+		}
+		setCaller(r.Context(), p.Name)
+		if !s.limitKey(w, r, p) {
+			return
+		}
+		namespace := r.Header.Get(service.NamespaceMetadataKey)
+		if namespace == "" && p.defaultNamespace() != "" {
+			r.Header.Set(service.NamespaceMetadataKey, p.defaultNamespace())
+		} else if namespace != "" && !p.Allows(namespace) {
+			writeStatusError(w, status.Errorf(codes.PermissionDenied, "namespace %q is not allowed for %s", namespace, p.Name))
+			return
+		}
+		ctx := context.WithValue(r.Context(), principalKey{}, p)
+		ctx = service.WithCallerNamespaces(service.WithCaller(ctx, p.Name), p.Namespaces)
+		next(w, r.WithContext(ctx))
+	}
+}
+
+// bearerToken returns the request's bearer token: the Authorization
+// header, or the access_token query parameter on job event stream and
+// WebSocket requests, as browsers' EventSource and WebSocket can't set
+// headers. Other routes don't take it, so tokens stay out of proxy logs
+// and Referer headers.
+func bearerToken(r *http.Request) string {
+	if scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " "); ok && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(token)
+	}
+	if acceptsQueryToken(r) {
+		return r.URL.Query().Get("access_token")
+	}
+	return ""
+}
+
+// acceptsQueryToken reports whether r is a GET of a job's event stream or
+// WebSocket.
+func acceptsQueryToken(r *http.Request) bool {
+	if r.Method != http.MethodGet {
+		return false
+	}
+	path, ok := strings.CutPrefix(r.URL.Path, "/api/v1/jobs/")
+	if !ok {
+		return false
+	}
+	id, ok := strings.CutSuffix(path, "/events")
+	if !ok {
+		id, ok = strings.CutSuffix(path, "/ws")
+	}
+	return ok && id != "" && !strings.Contains(id, "/")
+}
+
+// APIKeyConfig is the API key file format.
+type APIKeyConfig struct {
+	Keys []APIKey `json:"keys"`
+}
+
+// APIKey is a static API key. Either the key or its SHA-256 is given, so
+// the file needn't hold the key itself.
+type APIKey struct {
+	Name       string   `json:"name"`
+	Key        string   `json:"key,omitempty"`
+	SHA256     string   `json:"sha256,omitempty"` // Hex
+	Namespaces []string `json:"namespaces,omitempty"`
+}
+
+// LoadAPIKeys reads a JSON API key file.
+func LoadAPIKeys(path string) (APIKeyConfig, error) {
+	var cfg APIKeyConfig
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, fmt.Errorf("failed to read API key file: %w", err)
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("failed to parse API key file %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// apiKeyAuthenticator checks API keys sent in the X-API-Key header or as
+// bearer tokens.
+type apiKeyAuthenticator struct {
+	hashes [][sha256.Size]byte
+	keys   []APIKey
+}
+
+// NewAPIKeyAuthenticator authenticates requests carrying one of the keys,
+// in the X-API-Key header or as a bearer token. Bearer tokens that look like
+// JWTs are left to other authenticators.
+func NewAPIKeyAuthenticator(cfg APIKeyConfig) (Authenticator, error) {
+	a := &apiKeyAuthenticator{}
+	names := make(map[string]bool)
+	for i, key := range cfg.Keys {
+		if key.Name == "" {
+			return nil, fmt.Errorf("API key %d has no name", i)
+		}
+		if names[key.Name] {
+			return nil, fmt.Errorf("API key name %q is used twice", key.Name)
+		}
+		names[key.Name] = true
+
+		var hash [sha256.Size]byte
+		switch {
+		case key.Key != "" && key.SHA256 != "":
+			return nil, fmt.Errorf("API key %q has both key and sha256", key.Name)
+		case key.Key != "":
+			hash = sha256.Sum256([]byte(key.Key))
+		case key.SHA256 != "":
+			decoded, err := hex.DecodeString(key.SHA256)
+			if err != nil || len(decoded) != sha256.Size {
+				return nil, fmt.Errorf("API key %q: sha256 must be 64 hex digits", key.Name)
+			}
+			copy(hash[:], decoded)
+		default:
+			return nil, fmt.Errorf("API key %q has neither key nor sha256", key.Name)
+		}
+		key.Key = ""
+		a.hashes = append(a.hashes, hash)
+		a.keys = append(a.keys, key)
+	}
+	return a, nil
+}
+
+func (a *apiKeyAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	key := r.Header.Get(APIKeyHeader)
+	if key == "" {
+		key = bearerToken(r)
+		if key == "" || strings.Count(key, ".") == 2 {
+			return nil, ErrNoCredentials
+		}
+	}
+	hash := sha256.Sum256([]byte(key))
+	match := -1
+	for i := range a.hashes {
+		// Compare every key, so timing doesn't tell which one matched
+		if subtle.ConstantTimeCompare(hash[:], a.hashes[i][:]) == 1 {
+			match = i
+		}
+	}
+	if match < 0 {
+		return nil, errors.New("unknown API key")
+	}
+	return &Principal{Name: a.keys[match].Name, Namespaces: a.keys[match].Namespaces}, nil
+}
+
+// callScope says how a gRPC method called over HTTP is checked for a
+// namespace-bound caller.
+type callScope int
+
+const (
+	// scopeNamespaces checks the namespaces in the request
+	scopeNamespaces callScope = iota
+	// scopeJob also checks the namespace of the job named by job_id
+	scopeJob
+	// scopeBatch also checks the namespace of the batch named by batch_id
+	scopeBatch
+)
+
+// callScopes are the methods namespace-bound callers may call over HTTP.
+// The others (client registration, config, schedules) are denied to them.
+var callScopes = map[string]callScope{
+	"/nanabush.v1.TranslationService/CheckTitle":                scopeNamespaces,
+	"/nanabush.v1.TranslationService/Translate":                 scopeNamespaces,
+	"/nanabush.v1.TranslationService/TranslateStream":           scopeNamespaces,
+	"/nanabush.v1.TranslationService/DetectLanguage":            scopeNamespaces,
+	"/nanabush.v1.TranslationService/SubmitTranslation":         scopeNamespaces,
+	"/nanabush.v1.TranslationService/GetTranslationStatus":      scopeJob,
+	"/nanabush.v1.TranslationService/GetTranslationResult":      scopeJob,
+	"/nanabush.v1.TranslationService/CancelTranslation":         scopeJob,
+	"/nanabush.v1.TranslationService/BatchTranslate":            scopeNamespaces,
+	"/nanabush.v1.TranslationService/GetServerInfo":             scopeNamespaces,
+	"/nanabush.v1.TranslationService/TranslateDocumentSet":      scopeNamespaces,
+	"/nanabush.v1.TranslationService/ReportTranslationFeedback": scopeNamespaces,
+	"/nanabush.v2.TranslationService/SubmitTranslation":         scopeNamespaces,
+	"/nanabush.v2.TranslationService/GetJob":                    scopeJob,
+	"/nanabush.v2.TranslationService/WaitJob":                   scopeJob,
+	"/nanabush.v2.TranslationService/CancelJob":                 scopeJob,
+	"/nanabush.v2.TranslationService/SubmitBatch":               scopeNamespaces,
+	"/nanabush.v2.TranslationService/GetBatch":                  scopeBatch,
+	"/nanabush.v2.TranslationService/Translate":                 scopeNamespaces,
+}
+
+// authorizeCall checks a request to method over HTTP against the caller's
+// namespaces (only the method if req is nil). A request without a
+// namespace gets the X-Namespace header's (see authenticated).
+func (s *HTTPServer) authorizeCall(ctx context.Context, method string, req proto.Message) error {
+	p := principalFrom(ctx)
+	if !p.bound() {
+		return nil
+	}
+	scope, ok := callScopes[method]
+	if !ok {
+		return status.Errorf(codes.PermissionDenied, "%s is not available to namespace-bound callers", method)
+	}
+	if req == nil {
+		// Checking the method only
+		return nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	var fallback string
+	if values := md.Get(service.NamespaceMetadataKey); len(values) > 0 {
+		fallback = values[0]
+	}
+	msg := req.ProtoReflect()
+	if err := checkNamespaces(p, msg, fallback, true); err != nil {
+		return err
+	}
+
+	switch scope {
+	case scopeJob:
+		id := stringField(msg, "job_id")
+		if job, err := s.jobQueue.GetJob(id); err == nil && !p.Allows(job.Namespace) {
+			return status.Errorf(codes.NotFound, "job not found: %s", id)
+		}
+	case scopeBatch:
+		id := stringField(msg, "batch_id")
+		if batch, err := s.jobQueue.GetBatch(id); err == nil && !p.Allows(batch.Namespace) {
+			return status.Errorf(codes.NotFound, "batch not found: %s", id)
+		}
+	}
+	return nil
+}
+
+// checkNamespaces checks every namespace field in msg and the messages in
+// it. An empty top-level namespace is set to fallback; empty nested ones
+// inherit the request's.
+func checkNamespaces(p *Principal, msg protoreflect.Message, fallback string, top bool) error {
+	fields := msg.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		f := fields.Get(i)
+		switch {
+		case f.Name() == "namespace" && f.Kind() == protoreflect.StringKind && !f.IsList():
+			namespace := msg.Get(f).String()
+			if namespace == "" {
+				if !top {
+					continue
+				}
+				if fallback == "" {
+					return status.Error(codes.InvalidArgument, "namespace is required: the caller may use several")
+				}
+				msg.Set(f, protoreflect.ValueOfString(fallback))
+				namespace = fallback
+			}
+			if !p.Allows(namespace) {
+				return status.Errorf(codes.PermissionDenied, "namespace %q is not allowed for %s", namespace, p.Name)
+			}
+		case f.Message() != nil && f.IsList():
+			list := msg.Get(f).List()
+			for j := 0; j < list.Len(); j++ {
+				if err := checkNamespaces(p, list.Get(j).Message(), fallback, false); err != nil {
+					return err
+				}
+			}
+		case f.Message() != nil && !f.IsMap() && msg.Has(f):
+			if err := checkNamespaces(p, msg.Get(f).Message(), fallback, false); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// stringField returns the value of msg's string field name.
+func stringField(msg protoreflect.Message, name protoreflect.Name) string {
+	if f := msg.Descriptor().Fields().ByName(name); f != nil && f.Kind() == protoreflect.StringKind {
+		return msg.Get(f).String()
+	}
+	return ""
+}
@@ -0,0 +1,395 @@
+package server
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	_ "crypto/sha256" // Hashes for the RS/PS/ES algorithms
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultJWKSRefreshInterval is how often the JWKS is fetched again.
+	DefaultJWKSRefreshInterval = 10 * time.Minute
+
+	// DefaultNamespaceClaim is the JWT claim naming the caller's namespaces.
+	DefaultNamespaceClaim = "namespaces"
+
+	// jwksMinRefetch bounds how often a token with an unknown key ID can
+	// make the JWKS be fetched again.
+	jwksMinRefetch = 1 * time.Minute
+
+	// jwksTimeout bounds fetching the JWKS.
+	jwksTimeout = 10 * time.Second
+
+	// jwtLeeway allows for clock skew in exp and nbf.
+	jwtLeeway = 1 * time.Minute
+)
+
+// JWTConfig controls bearer JWT validation.
+type JWTConfig struct {
+	// JWKSURL serves the JSON Web Key Set whose keys sign valid tokens.
+	JWKSURL string
+	// Issuer, if set, must be the token's iss.
+	Issuer string
+	// Audience, if set, must be in the token's aud.
+	Audience string
+	// NamespaceClaim names the claim with the caller's namespaces, a
+	// string or an array of strings; tokens without it may use any
+	// namespace (default DefaultNamespaceClaim).
+	NamespaceClaim string
+	// RefreshInterval is how often the JWKS is fetched again (default
+	// DefaultJWKSRefreshInterval). Tokens signed with an unknown key also
+	// trigger a fetch, at most once a minute.
+	RefreshInterval time.Duration
+}
+
+// jwtAlgorithms are the accepted signing algorithms and their hashes.
+// "none" and the HMAC algorithms are never accepted.
+var jwtAlgorithms = map[string]crypto.Hash{
+	"RS256": crypto.SHA256,
+	"RS384": crypto.SHA384,
+	"RS512": crypto.SHA512,
+	"PS256": crypto.SHA256,
+	"PS384": crypto.SHA384,
+	"PS512": crypto.SHA512,
+	"ES256": crypto.SHA256,
+	"ES384": crypto.SHA384,
+	"ES512": crypto.SHA512,
+}
+
+// jwtAuthenticator validates bearer JWTs against a JWKS.
+type jwtAuthenticator struct {
+	cfg    JWTConfig
+	client *http.Client
+	logger *logrus.Logger
+
+	mu        sync.Mutex
+	keys      map[string]crypto.PublicKey // By key ID ("" for keys without one)
+	fetchedAt time.Time
+}
+
+// NewJWTAuthenticator authenticates requests carrying a bearer JWT signed
+// by a key in cfg.JWKSURL. The key set is fetched now; if that fails it is
+// retried when tokens arrive.
+func NewJWTAuthenticator(cfg JWTConfig, logger *logrus.Logger) (Authenticator, error) {
+	if cfg.JWKSURL == "" {
+		return nil, errors.New("a JWKS URL is required")
+	}
+	if cfg.NamespaceClaim == "" {
+		cfg.NamespaceClaim = DefaultNamespaceClaim
+	}
+	if cfg.RefreshInterval <= 0 {
+		cfg.RefreshInterval = DefaultJWKSRefreshInterval
+	}
+	a := &jwtAuthenticator{
+		cfg:    cfg,
+		client: &http.Client{Timeout: jwksTimeout},
+		logger: logger,
+	}
+	a.fetchedAt = time.Now()
+	if err := a.refresh(); err != nil {
+		logger.WithError(err).WithField("url", cfg.JWKSURL).Warn("Failed to fetch JWKS; retrying when tokens arrive")
+	}
+	return a, nil
+}
+
+func (a *jwtAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	token := bearerToken(r)
+	if strings.Count(token, ".") != 2 {
+		return nil, ErrNoCredentials
+	}
+	claims, err := a.verify(token, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Principal{}
+	p.Name, _ = claims["sub"].(string)
+	switch namespaces := claims[a.cfg.NamespaceClaim].(type) {
+	case nil:
+	case string:
+		p.Namespaces = []string{namespaces}
+	case []any:
+		for _, namespace := range namespaces {
+			name, ok := namespace.(string)
+			if !ok {
+				return nil, fmt.Errorf("claim %s must hold strings", a.cfg.NamespaceClaim)
+			}
+			p.Namespaces = append(p.Namespaces, name)
+		}
+		if len(p.Namespaces) == 0 {
+			return nil, fmt.Errorf("claim %s names no namespace", a.cfg.NamespaceClaim)
+		}
+	default:
+		return nil, fmt.Errorf("claim %s must be a string or an array of strings", a.cfg.NamespaceClaim)
+	}
+	return p, nil
+}
+
+// verify checks a token's signature and its time, issuer and audience
+// claims, and returns its claims.
+func (a *jwtAuthenticator) verify(token string, now time.Time) (map[string]any, error) {
+	parts := strings.Split(token, ".")
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("invalid token header: %w", err)
+	}
+	hash, ok := jwtAlgorithms[header.Alg]
+	if !ok {
+		return nil, fmt.Errorf("token algorithm %q is not accepted", header.Alg)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("invalid token signature encoding: %w", err)
+	}
+
+	h := hash.New()
+	h.Write([]byte(parts[0] + "." + parts[1]))
+	digest := h.Sum(nil)
+	verified := false
+	for _, key := range a.keysFor(header.Kid, now) {
+		if verifyJWTSignature(header.Alg, hash, key, digest, signature) {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, errors.New("token signature is not valid for any known key")
+	}
+
+	claims := make(map[string]any)
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("invalid token claims: %w", err)
+	}
+	exp, ok := claims["exp"].(json.Number)
+	if !ok {
+		return nil, errors.New("token has no exp")
+	}
+	if t, err := exp.Float64(); err != nil || now.After(time.Unix(int64(t), 0).Add(jwtLeeway)) {
+		return nil, errors.New("token has expired")
+	}
+	if nbf, ok := claims["nbf"].(json.Number); ok {
+		if t, err := nbf.Float64(); err != nil || now.Add(jwtLeeway).Before(time.Unix(int64(t), 0)) {
+			return nil, errors.New("token is not valid yet")
+		}
+	}
+	if a.cfg.Issuer != "" && claims["iss"] != a.cfg.Issuer {
+		return nil, fmt.Errorf("token issuer %v is not %s", claims["iss"], a.cfg.Issuer)
+	}
+	if a.cfg.Audience != "" && !jwtAudienceHas(claims["aud"], a.cfg.Audience) {
+		return nil, fmt.Errorf("token audience does not include %s", a.cfg.Audience)
+	}
+	return claims, nil
+}
+
+// decodeJWTPart decodes a base64url JSON token part, keeping numbers
+// exact.
+func decodeJWTPart(part string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+// jwtAudienceHas reports whether an aud claim, a string or an array of
+// strings, includes audience.
+func jwtAudienceHas(aud any, audience string) bool {
+	switch aud := aud.(type) {
+	case string:
+		return aud == audience
+	case []any:
+		for _, value := range aud {
+			if value == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// verifyJWTSignature checks a signature over digest with key.
+func verifyJWTSignature(alg string, hash crypto.Hash, key crypto.PublicKey, digest, signature []byte) bool {
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		if strings.HasPrefix(alg, "PS") {
+			return rsa.VerifyPSS(key, hash, digest, signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}) == nil
+		}
+		return strings.HasPrefix(alg, "RS") && rsa.VerifyPKCS1v15(key, hash, digest, signature) == nil
+	case *ecdsa.PublicKey:
+		// ES signatures are r and s, each the size of the curve
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if !strings.HasPrefix(alg, "ES") || len(signature) != 2*size || key.Curve.Params().BitSize != jwtCurveBits(alg) {
+			return false
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		return ecdsa.Verify(key, digest, r, s)
+	}
+	return false
+}
+
+// jwtCurveBits returns the curve size an ES algorithm requires.
+func jwtCurveBits(alg string) int {
+	switch alg {
+	case "ES256":
+		return 256
+	case "ES384":
+		return 384
+	default:
+		return 521
+	}
+}
+
+// keysFor returns the keys a token with key ID kid may be signed with:
+// that key, or every key if the token names none. An unknown key ID makes
+// the set be fetched again, if it wasn't fetched recently.
+func (a *jwtAuthenticator) keysFor(kid string, now time.Time) []crypto.PublicKey {
+	a.mu.Lock()
+	stale := now.Sub(a.fetchedAt) > a.cfg.RefreshInterval
+	_, known := a.keys[kid]
+	if kid != "" && !known && now.Sub(a.fetchedAt) > jwksMinRefetch {
+		stale = true
+	}
+	if stale {
+		// Failed fetches count too, so an unreachable JWKS isn't fetched
+		// per request
+		a.fetchedAt = now
+	}
+	a.mu.Unlock()
+	if stale {
+		if err := a.refresh(); err != nil {
+			a.logger.WithError(err).WithField("url", a.cfg.JWKSURL).Warn("Failed to fetch JWKS; using the keys already fetched")
+		}
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if kid != "" {
+		if key, ok := a.keys[kid]; ok {
+			return []crypto.PublicKey{key}
+		}
+		return nil
+	}
+	keys := make([]crypto.PublicKey, 0, len(a.keys))
+	for _, key := range a.keys {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// refresh fetches the key set. Keys that can't be used are skipped.
+func (a *jwtAuthenticator) refresh() error {
+	resp, err := a.client.Get(a.cfg.JWKSURL)
+	if err != nil {
+		return fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch JWKS: %s", resp.Status)
+	}
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&set); err != nil {
+		return fmt.Errorf("failed to parse JWKS: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey)
+	for _, jwk := range set.Keys {
+		key, err := jwk.publicKey()
+		if err != nil {
+			a.logger.WithError(err).WithField("kid", jwk.Kid).Warn("Skipping unusable JWKS key")
+			continue
+		}
+		if key != nil {
+			keys[jwk.Kid] = key
+		}
+	}
+	a.mu.Lock()
+	a.keys = keys
+	a.mu.Unlock()
+	a.logger.WithFields(logrus.Fields{
+		"url":  a.cfg.JWKSURL,
+		"keys": len(keys),
+	}).Debug("Fetched JWKS")
+	return nil
+}
+
+// jsonWebKey is a key in a JWKS.
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// publicKey returns the key, or nil for keys not used for signatures or of
+// other types.
+func (k jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	if k.Use != "" && k.Use != "sig" {
+		return nil, nil
+	}
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid n: %w", err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, errors.New("invalid e")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		var point ecdh.Curve
+		switch k.Crv {
+		case "P-256":
+			curve, point = elliptic.P256(), ecdh.P256()
+		case "P-384":
+			curve, point = elliptic.P384(), ecdh.P384()
+		case "P-521":
+			curve, point = elliptic.P521(), ecdh.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		size := (curve.Params().BitSize + 7) / 8
+		x, errX := base64.RawURLEncoding.DecodeString(k.X)
+		y, errY := base64.RawURLEncoding.DecodeString(k.Y)
+		if errX != nil || errY != nil || len(x) != size || len(y) != size {
+			return nil, errors.New("invalid x or y")
+		}
+		// Reject points that aren't on the curve
+		if _, err := point.NewPublicKey(append(append([]byte{4}, x...), y...)); err != nil {
+			return nil, fmt.Errorf("invalid point: %w", err)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,447 @@
+package server
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// testJWKS serves a JWKS whose keys can be changed, counting fetches.
+type testJWKS struct {
+	mu      sync.Mutex
+	keys    []map[string]string
+	fetches atomic.Int32
+}
+
+func (j *testJWKS) set(keys ...map[string]string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.keys = keys
+}
+
+func (j *testJWKS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	j.fetches.Add(1)
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	json.NewEncoder(w).Encode(map[string]any{"keys": j.keys})
+}
+
+func b64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func rsaJWK(kid string, key *rsa.PublicKey) map[string]string {
+	return map[string]string{
+		"kty": "RSA",
+		"kid": kid,
+		"use": "sig",
+		"n":   b64(key.N.Bytes()),
+		"e":   b64(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+func ecJWK(kid string, key *ecdsa.PublicKey) map[string]string {
+	size := (key.Curve.Params().BitSize + 7) / 8
+	return map[string]string{
+		"kty": "EC",
+		"kid": kid,
+		"crv": key.Curve.Params().Name,
+		"x":   b64(key.X.FillBytes(make([]byte, size))),
+		"y":   b64(key.Y.FillBytes(make([]byte, size))),
+	}
+}
+
+// signingInput encodes a token's header and claims.
+func signingInput(t *testing.T, header, claims map[string]any) string {
+	t.Helper()
+	h, err := json.Marshal(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b64(h) + "." + b64(c)
+}
+
+func signRS256(t *testing.T, key *rsa.PrivateKey, kid string, claims map[string]any) string {
+	t.Helper()
+	input := signingInput(t, map[string]any{"alg": "RS256", "kid": kid, "typ": "JWT"}, claims)
+	digest := sha256.Sum256([]byte(input))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return input + "." + b64(sig)
+}
+
+// tamper changes a character in the middle of a token's part.
+func tamper(token string, part int) string {
+	parts := strings.Split(token, ".")
+	b := []byte(parts[part])
+	i := len(b) / 2
+	if b[i] == 'A' {
+		b[i] = 'B'
+	} else {
+		b[i] = 'A'
+	}
+	parts[part] = string(b)
+	return strings.Join(parts, ".")
+}
+
+func newTestJWT(t *testing.T, cfg JWTConfig, jwks *testJWKS) *jwtAuthenticator {
+	t.Helper()
+	srv := httptest.NewServer(jwks)
+	t.Cleanup(srv.Close)
+	cfg.JWKSURL = srv.URL
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	auth, err := NewJWTAuthenticator(cfg, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return auth.(*jwtAuthenticator)
+}
+
+func TestJWTVerify(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwks := &testJWKS{}
+	jwks.set(rsaJWK("rsa", &rsaKey.PublicKey))
+	auth := newTestJWT(t, JWTConfig{Issuer: "https://issuer.example", Audience: "iskoces"}, jwks)
+
+	now := time.Now()
+	claims := func(overrides map[string]any) map[string]any {
+		c := map[string]any{
+			"sub": "alice",
+			"iss": "https://issuer.example",
+			"aud": []string{"other", "iskoces"},
+			"exp": now.Add(time.Hour).Unix(),
+		}
+		for name, value := range overrides {
+			if value == nil {
+				delete(c, name)
+			} else {
+				c[name] = value
+			}
+		}
+		return c
+	}
+
+	// HS256 signed with the RSA public key as the HMAC secret: an
+	// algorithm confusion attack
+	der, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hsInput := signingInput(t, map[string]any{"alg": "HS256", "kid": "rsa"}, claims(nil))
+	mac := hmac.New(sha256.New, der)
+	mac.Write([]byte(hsInput))
+	hs256 := hsInput + "." + b64(mac.Sum(nil))
+
+	tests := []struct {
+		name  string
+		token string
+		ok    bool
+	}{
+		{"valid", signRS256(t, rsaKey, "rsa", claims(nil)), true},
+		{"alg none", signingInput(t, map[string]any{"alg": "none"}, claims(nil)) + ".", false},
+		{"alg none with kid", signingInput(t, map[string]any{"alg": "none", "kid": "rsa"}, claims(nil)) + ".", false},
+		{"HS256 with the RSA key", hs256, false},
+		{"tampered claims", tamper(signRS256(t, rsaKey, "rsa", claims(nil)), 1), false},
+		{"tampered signature", tamper(signRS256(t, rsaKey, "rsa", claims(nil)), 2), false},
+		{"expired", signRS256(t, rsaKey, "rsa", claims(map[string]any{"exp": now.Add(-2 * time.Minute).Unix()})), false},
+		{"expired within leeway", signRS256(t, rsaKey, "rsa", claims(map[string]any{"exp": now.Add(-30 * time.Second).Unix()})), true},
+		{"no exp", signRS256(t, rsaKey, "rsa", claims(map[string]any{"exp": nil})), false},
+		{"nbf beyond leeway", signRS256(t, rsaKey, "rsa", claims(map[string]any{"nbf": now.Add(2 * time.Minute).Unix()})), false},
+		{"nbf within leeway", signRS256(t, rsaKey, "rsa", claims(map[string]any{"nbf": now.Add(30 * time.Second).Unix()})), true},
+		{"wrong iss", signRS256(t, rsaKey, "rsa", claims(map[string]any{"iss": "https://evil.example"})), false},
+		{"no iss", signRS256(t, rsaKey, "rsa", claims(map[string]any{"iss": nil})), false},
+		{"wrong aud", signRS256(t, rsaKey, "rsa", claims(map[string]any{"aud": "other"})), false},
+		{"aud string", signRS256(t, rsaKey, "rsa", claims(map[string]any{"aud": "iskoces"})), true},
+		{"no aud", signRS256(t, rsaKey, "rsa", claims(map[string]any{"aud": nil})), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := auth.verify(tt.token, now)
+			if tt.ok && err != nil {
+				t.Errorf("verify() = %v, want success", err)
+			}
+			if !tt.ok && err == nil {
+				t.Error("verify() succeeded, want an error")
+			}
+		})
+	}
+}
+
+func TestJWTUnknownKeyRefetch(t *testing.T) {
+	oldKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwks := &testJWKS{}
+	jwks.set(rsaJWK("old", &oldKey.PublicKey))
+	auth := newTestJWT(t, JWTConfig{}, jwks)
+	if got := jwks.fetches.Load(); got != 1 {
+		t.Fatalf("fetches after start = %d, want 1", got)
+	}
+
+	// The issuer rotates its key
+	jwks.set(rsaJWK("old", &oldKey.PublicKey), rsaJWK("new", &newKey.PublicKey))
+	now := time.Now()
+	claims := map[string]any{"sub": "alice", "exp": now.Add(time.Hour).Unix()}
+
+	// Right after a fetch, an unknown key ID doesn't fetch again
+	if _, err := auth.verify(signRS256(t, newKey, "new", claims), now); err == nil {
+		t.Error("verify() with a key not fetched yet succeeded")
+	}
+	if got := jwks.fetches.Load(); got != 1 {
+		t.Errorf("fetches within a minute = %d, want 1", got)
+	}
+
+	// Later, it fetches the set once and finds the new key
+	later := now.Add(2 * jwksMinRefetch)
+	if _, err := auth.verify(signRS256(t, newKey, "new", claims), later); err != nil {
+		t.Errorf("verify() with the rotated key = %v", err)
+	}
+	if got := jwks.fetches.Load(); got != 2 {
+		t.Errorf("fetches after the unknown key = %d, want 2", got)
+	}
+
+	// Tokens with bogus key IDs don't make it fetch again
+	for i := 0; i < 3; i++ {
+		if _, err := auth.verify(signRS256(t, newKey, "bogus", claims), later); err == nil {
+			t.Error("verify() with an unknown key ID succeeded")
+		}
+	}
+	if got := jwks.fetches.Load(); got != 2 {
+		t.Errorf("fetches after bogus key IDs = %d, want 2", got)
+	}
+	// Known keys still verify without a fetch
+	if _, err := auth.verify(signRS256(t, oldKey, "old", claims), later); err != nil {
+		t.Errorf("verify() with the old key = %v", err)
+	}
+	if got := jwks.fetches.Load(); got != 2 {
+		t.Errorf("fetches after a known key = %d, want 2", got)
+	}
+}
+
+func TestJWTES256(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p384Key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwks := &testJWKS{}
+	jwks.set(ecJWK("ec", &ecKey.PublicKey), ecJWK("p384", &p384Key.PublicKey))
+	auth := newTestJWT(t, JWTConfig{}, jwks)
+
+	now := time.Now()
+	claims := map[string]any{"sub": "bob", "exp": now.Add(time.Hour).Unix()}
+	sign := func(alg, kid string, key *ecdsa.PrivateKey, encode func(r, s *big.Int, size int) []byte) string {
+		input := signingInput(t, map[string]any{"alg": alg, "kid": kid}, claims)
+		digest := sha256.Sum256([]byte(input))
+		r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return input + "." + b64(encode(r, s, (key.Curve.Params().BitSize+7)/8))
+	}
+	raw := func(r, s *big.Int, size int) []byte {
+		return append(r.FillBytes(make([]byte, size)), s.FillBytes(make([]byte, size))...)
+	}
+	der := func(r, s *big.Int, size int) []byte {
+		sig, err := ecdsaDER(r, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sig
+	}
+	short := func(r, s *big.Int, size int) []byte {
+		return raw(r, s, size)[1:]
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		ok    bool
+	}{
+		{"raw r||s", sign("ES256", "ec", ecKey, raw), true},
+		{"ASN.1 DER", sign("ES256", "ec", ecKey, der), false},
+		{"short", sign("ES256", "ec", ecKey, short), false},
+		{"ES256 with a P-384 key", sign("ES256", "p384", p384Key, raw), false},
+		{"RS256 with an EC key", func() string {
+			token := sign("ES256", "ec", ecKey, raw)
+			input := signingInput(t, map[string]any{"alg": "RS256", "kid": "ec"}, claims)
+			return input + token[len(input):]
+		}(), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := auth.verify(tt.token, now)
+			if tt.ok && err != nil {
+				t.Errorf("verify() = %v, want success", err)
+			}
+			if !tt.ok && err == nil {
+				t.Error("verify() succeeded, want an error")
+			}
+		})
+	}
+}
+
+// ecdsaDER encodes an ECDSA signature as ASN.1 DER, as crypto/ecdsa's
+// SignASN1 does.
+func ecdsaDER(r, s *big.Int) ([]byte, error) {
+	encodeInt := func(n *big.Int) []byte {
+		b := n.Bytes()
+		if len(b) == 0 || b[0]&0x80 != 0 {
+			b = append([]byte{0}, b...)
+		}
+		return append([]byte{0x02, byte(len(b))}, b...)
+	}
+	body := append(encodeInt(r), encodeInt(s)...)
+	if len(body) > 127 {
+		return nil, errors.New("signature too long")
+	}
+	return append([]byte{0x30, byte(len(body))}, body...), nil
+}
+
+func TestJWTAuthenticateNamespaces(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwks := &testJWKS{}
+	jwks.set(rsaJWK("rsa", &rsaKey.PublicKey))
+	auth := newTestJWT(t, JWTConfig{}, jwks)
+
+	exp := time.Now().Add(time.Hour).Unix()
+	token := signRS256(t, rsaKey, "rsa", map[string]any{"sub": "alice", "exp": exp, "namespaces": []string{"a", "b"}})
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/jobs", nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+	p, err := auth.Authenticate(r)
+	if err != nil {
+		t.Fatalf("Authenticate() = %v", err)
+	}
+	if p.Name != "alice" || !p.Allows("a") || !p.Allows("b") || p.Allows("c") {
+		t.Errorf("Authenticate() = %+v, want alice in a and b", p)
+	}
+
+	token = signRS256(t, rsaKey, "rsa", map[string]any{"sub": "alice", "exp": exp, "namespaces": []any{"a", 1}})
+	r.Header.Set("Authorization", "Bearer "+token)
+	if _, err := auth.Authenticate(r); err == nil {
+		t.Error("Authenticate() with a non-string namespace succeeded")
+	}
+}
+
+func TestAPIKeyAuthenticate(t *testing.T) {
+	hash := sha256.Sum256([]byte("hashed-secret"))
+	auth, err := NewAPIKeyAuthenticator(APIKeyConfig{Keys: []APIKey{
+		{Name: "ci", Key: "plain-secret", Namespaces: []string{"ci"}},
+		{Name: "ops", SHA256: hex.EncodeToString(hash[:])},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		header string
+		bearer string
+		want   string
+		err    error
+	}{
+		{name: "header", header: "plain-secret", want: "ci"},
+		{name: "bearer", bearer: "plain-secret", want: "ci"},
+		{name: "hashed key", header: "hashed-secret", want: "ops"},
+		{name: "wrong key", header: "wrong-secret", err: errors.New("unknown API key")},
+		{name: "wrong bearer", bearer: "wrong-secret", err: errors.New("unknown API key")},
+		{name: "prefix of a key", header: "plain", err: errors.New("unknown API key")},
+		{name: "none", err: ErrNoCredentials},
+		{name: "JWT bearer left to others", bearer: "a.b.c", err: ErrNoCredentials},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/api/v1/jobs", nil)
+			if tt.header != "" {
+				r.Header.Set(APIKeyHeader, tt.header)
+			}
+			if tt.bearer != "" {
+				r.Header.Set("Authorization", "Bearer "+tt.bearer)
+			}
+			p, err := auth.Authenticate(r)
+			switch {
+			case tt.err != nil && err == nil:
+				t.Errorf("Authenticate() = %+v, want error %v", p, tt.err)
+			case tt.err == nil && err != nil:
+				t.Errorf("Authenticate() = %v, want %s", err, tt.want)
+			case errors.Is(tt.err, ErrNoCredentials) && !errors.Is(err, ErrNoCredentials):
+				t.Errorf("Authenticate() = %v, want ErrNoCredentials", err)
+			case tt.err == nil && p.Name != tt.want:
+				t.Errorf("Authenticate() = %s, want %s", p.Name, tt.want)
+			}
+		})
+	}
+}
+
+func TestBearerTokenQuery(t *testing.T) {
+	tests := []struct {
+		method string
+		target string
+		want   string
+	}{
+		{http.MethodGet, "/api/v1/jobs/job-1/events?access_token=t", "t"},
+		{http.MethodGet, "/api/v1/jobs/job-1/ws?access_token=t", "t"},
+		{http.MethodGet, "/api/v1/jobs/job-1?access_token=t", ""},
+		{http.MethodGet, "/api/v1/jobs/job-1/result?access_token=t", ""},
+		{http.MethodGet, "/api/v1/jobs?access_token=t", ""},
+		{http.MethodGet, "/api/v1/jobs//events?access_token=t", ""},
+		{http.MethodGet, "/api/v1/jobs/a/b/events?access_token=t", ""},
+		{http.MethodGet, "/nanabush.v1.TranslationService/GetServerInfo?access_token=t", ""},
+		{http.MethodGet, "/debug/workers?access_token=t", ""},
+		{http.MethodPost, "/api/v1/jobs/job-1/events?access_token=t", ""},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, tt.target, nil)
+		if got := bearerToken(r); got != tt.want {
+			t.Errorf("bearerToken(%s %s) = %q, want %q", tt.method, tt.target, got, tt.want)
+		}
+	}
+
+	// The header is taken everywhere
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/jobs?access_token=q", nil)
+	r.Header.Set("Authorization", "Bearer h")
+	if got := bearerToken(r); got != "h" {
+		t.Errorf("bearerToken() with a header = %q, want h", got)
+	}
+}
@@ -0,0 +1,235 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Default CORS policy settings (see CORSConfig).
+var (
+	DefaultCORSMethods = []string{http.MethodGet, http.MethodPost}
+	DefaultCORSHeaders = []string{
+		"Authorization", "Content-Type", "Idempotency-Key", "If-None-Match", "Last-Event-ID",
+		APIKeyHeader, "X-Client-Id", "X-Namespace", "X-Request-Id",
+		// gRPC-Web calls (see SetGRPCServer)
+		"Grpc-Timeout", "X-Grpc-Web", "X-User-Agent",
+		// Trace context (see the tracing package)
+		"Traceparent",
+	}
+)
+
+// DefaultCORSMaxAge is how long browsers may cache a preflight response.
+const DefaultCORSMaxAge = 10 * time.Minute
+
+// corsExposedHeaders are the response headers cross-origin scripts may read.
+const corsExposedHeaders = "ETag, Grpc-Message, Grpc-Status, Location, Retry-After, WWW-Authenticate, X-Request-Id"
+
+// CORSConfig is the cross-origin policy of the HTTP API. Without one, no
+// CORS headers are sent, so browsers only let pages served from the same
+// origin read responses.
+type CORSConfig struct {
+	// AllowedOrigins are the origins (scheme://host[:port]) whose pages may
+	// call the API. "*" allows any origin, and a "*." host prefix any
+	// subdomain (e.g. "https://*.example.com").
+	AllowedOrigins []string
+	// AllowedMethods defaults to DefaultCORSMethods.
+	AllowedMethods []string
+	// AllowedHeaders are the request headers pages may send; defaults to
+	// DefaultCORSHeaders, and "*" allows any.
+	AllowedHeaders []string
+	// AllowCredentials lets pages send cookies and HTTP authentication,
+	// which requires explicit origins.
+	AllowCredentials bool
+	// MaxAge defaults to DefaultCORSMaxAge.
+	MaxAge time.Duration
+}
+
+// corsPolicy is a validated CORSConfig.
+type corsPolicy struct {
+	anyOrigin   bool
+	origins     map[string]bool
+	wildcards   []originWildcard
+	methods     map[string]bool
+	anyHeader   bool
+	headers     map[string]bool
+	credentials bool
+
+	allowMethods string
+	allowHeaders string
+	maxAge       string
+}
+
+// originWildcard matches the subdomains of a "scheme://*.host" origin.
+type originWildcard struct {
+	scheme string // With "://"
+	suffix string // ".host"
+}
+
+// SetCORS applies a cross-origin policy to every HTTP endpoint. It must be
+// called before Start.
+func (s *HTTPServer) SetCORS(cfg CORSConfig) error {
+	if len(cfg.AllowedMethods) == 0 {
+		cfg.AllowedMethods = DefaultCORSMethods
+	}
+	if len(cfg.AllowedHeaders) == 0 {
+		cfg.AllowedHeaders = DefaultCORSHeaders
+	}
+	if cfg.MaxAge <= 0 {
+		cfg.MaxAge = DefaultCORSMaxAge
+	}
+
+	p := &corsPolicy{
+		origins:     make(map[string]bool),
+		methods:     make(map[string]bool),
+		headers:     make(map[string]bool),
+		credentials: cfg.AllowCredentials,
+		maxAge:      strconv.Itoa(int(cfg.MaxAge.Seconds())),
+	}
+	for _, origin := range cfg.AllowedOrigins {
+		origin = strings.ToLower(strings.TrimSuffix(origin, "/"))
+		if origin == "*" {
+			if cfg.AllowCredentials {
+				return fmt.Errorf("CORS origin * can't be combined with credentials; list the allowed origins")
+			}
+			p.anyOrigin = true
+			continue
+		}
+		u, err := url.Parse(origin)
+		if err != nil || u.Scheme == "" || u.Host == "" || u.Path != "" || u.RawQuery != "" {
+			return fmt.Errorf("invalid CORS origin %q: want scheme://host[:port]", origin)
+		}
+		if host, ok := strings.CutPrefix(u.Host, "*."); ok {
+			p.wildcards = append(p.wildcards, originWildcard{scheme: u.Scheme + "://", suffix: "." + host})
+			continue
+		}
+		p.origins[origin] = true
+	}
+	if !p.anyOrigin && len(p.origins) == 0 && len(p.wildcards) == 0 {
+		return fmt.Errorf("CORS policy allows no origins")
+	}
+	var methods []string
+	for _, method := range cfg.AllowedMethods {
+		method = strings.ToUpper(strings.TrimSpace(method))
+		p.methods[method] = true
+		methods = append(methods, method)
+	}
+	p.allowMethods = strings.Join(methods, ", ")
+	var headers []string
+	for _, header := range cfg.AllowedHeaders {
+		header = http.CanonicalHeaderKey(strings.TrimSpace(header))
+		if header == "*" {
+			p.anyHeader = true
+			continue
+		}
+		p.headers[header] = true
+		headers = append(headers, header)
+	}
+	p.allowHeaders = strings.Join(headers, ", ")
+
+	s.cors = p
+	return nil
+}
+
+// allowsOrigin reports whether pages from origin may call the API.
+func (p *corsPolicy) allowsOrigin(origin string) bool {
+	origin = strings.ToLower(origin)
+	if p.anyOrigin || p.origins[origin] {
+		return true
+	}
+	for _, w := range p.wildcards {
+		if host, ok := strings.CutPrefix(origin, w.scheme); ok && strings.HasSuffix(host, w.suffix) && len(host) > len(w.suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// withCORS answers preflight requests and adds CORS headers to responses
+// for allowed origins. Preflights come without credentials, so it runs
+// before authentication.
+func (s *HTTPServer) withCORS(next http.Handler) http.Handler {
+	p := s.cors
+	if p == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if !p.anyOrigin {
+			w.Header().Add("Vary", "Origin")
+		}
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		allowed := p.allowsOrigin(origin)
+
+		requestMethod := r.Header.Get("Access-Control-Request-Method")
+		if r.Method == http.MethodOptions && requestMethod != "" {
+			if !allowed || !p.methods[strings.ToUpper(requestMethod)] {
+				s.logger.WithField("origin", origin).WithField("method", requestMethod).Debug("Rejected CORS preflight")
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			allowHeaders := p.allowHeaders
+			if requested := r.Header.Get("Access-Control-Request-Headers"); requested != "" {
+				for _, header := range strings.Split(requested, ",") {
+					header = http.CanonicalHeaderKey(strings.TrimSpace(header))
+					if header != "" && !p.anyHeader && !p.headers[header] {
+						s.logger.WithField("origin", origin).WithField("header", header).Debug("Rejected CORS preflight")
+						w.WriteHeader(http.StatusForbidden)
+						return
+					}
+				}
+				if p.anyHeader {
+					allowHeaders = requested
+				}
+			}
+			p.setOrigin(w, origin)
+			w.Header().Add("Vary", "Access-Control-Request-Method, Access-Control-Request-Headers")
+			w.Header().Set("Access-Control-Allow-Methods", p.allowMethods)
+			if allowHeaders != "" {
+				w.Header().Set("Access-Control-Allow-Headers", allowHeaders)
+			}
+			w.Header().Set("Access-Control-Max-Age", p.maxAge)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		if allowed {
+			p.setOrigin(w, origin)
+			w.Header().Set("Access-Control-Expose-Headers", corsExposedHeaders)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// setOrigin sets the headers granting origin access to a response.
+func (p *corsPolicy) setOrigin(w http.ResponseWriter, origin string) {
+	if p.anyOrigin {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		return
+	}
+	w.Header().Set("Access-Control-Allow-Origin", origin)
+	if p.credentials {
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+// allowsWebSocket reports whether a WebSocket handshake may be accepted.
+// Browsers don't apply CORS to WebSockets, so the origin is checked here:
+// clients that send none (not browsers), pages from the server's own
+// origin, and origins the CORS policy allows.
+func (s *HTTPServer) allowsWebSocket(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	return s.cors != nil && s.cors.allowsOrigin(origin)
+}
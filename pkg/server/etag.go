@@ -0,0 +1,53 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// writeETagged writes body with an ETag computed from it, so a client
+// polling a job gets 304 Not Modified without the body until the job's
+// state changes. The caller sets the other headers.
+func writeETagged(w http.ResponseWriter, r *http.Request, body []byte) {
+	sum := sha256.Sum256(body)
+	etag := `"` + base64.RawURLEncoding.EncodeToString(sum[:16]) + `"`
+	w.Header().Set("ETag", etag)
+	if w.Header().Get("Cache-Control") == "" {
+		// Caches must check with the server before reusing a response
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+	if etagMatches(r.Header.Values("If-None-Match"), etag) {
+		w.Header().Del("Content-Length")
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Write(body)
+}
+
+// writeETaggedJSON encodes v as JSON and writes it with writeETagged.
+func writeETaggedJSON(w http.ResponseWriter, r *http.Request, v any) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, "Failed to encode response: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	writeETagged(w, r, append(body, '\n'))
+}
+
+// etagMatches reports whether If-None-Match header values list etag or are
+// "*", comparing weakly as RFC 9110 requires for If-None-Match.
+func etagMatches(values []string, etag string) bool {
+	for _, value := range values {
+		for _, candidate := range strings.Split(value, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == "*" || candidate == etag {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,317 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/dasmlab/iskoces/pkg/service"
+)
+
+// CallOptions apply to gRPC methods called over HTTP: the REST endpoints
+// and the JSON gateway.
+type CallOptions struct {
+	// MaxBodyBytes bounds request bodies, like the gRPC server's receive
+	// limit (default: the default document size limit plus 64KB).
+	MaxBodyBytes int
+	// Unary and Stream are the gRPC server's interceptors, so calls over
+	// HTTP get the same drain, validation, registration and quota checks.
+	Unary  []grpc.UnaryServerInterceptor
+	Stream []grpc.StreamServerInterceptor
+}
+
+// SetCallOptions sets how gRPC methods are called over HTTP. It must be
+// called before Start.
+func (s *HTTPServer) SetCallOptions(opts CallOptions) {
+	s.callOptions = opts
+}
+
+// maxBodyBytes returns the request body limit.
+func (s *HTTPServer) maxBodyBytes() int64 {
+	if s.limits.MaxBodyBytes > 0 {
+		return s.limits.MaxBodyBytes
+	}
+	if s.callOptions.MaxBodyBytes <= 0 {
+		return service.DefaultMaxDocumentBytes + 64*1024
+	}
+	return int64(s.callOptions.MaxBodyBytes)
+}
+
+// gatewayService is a gRPC service exposed as JSON over HTTP.
+type gatewayService struct {
+	desc *grpc.ServiceDesc
+	impl any
+}
+
+// RegisterService exposes a gRPC service on the HTTP port as JSON, so the
+// server can be passed to the generated Register*Server functions. Every
+// method is served as POST /<package>.<Service>/<Method> with the request
+// message in protobuf JSON as the body. Streamed messages, in either
+// direction, are newline-delimited JSON; as over HTTP/1.1 the request
+// stream is read in full before responses are sent. /openapi.json describes
+// the registered services. It must be called before Start.
+func (s *HTTPServer) RegisterService(desc *grpc.ServiceDesc, impl any) {
+	s.gateways = append(s.gateways, gatewayService{desc: desc, impl: impl})
+}
+
+// handleGateway serves the methods of one registered service.
+func (s *HTTPServer) handleGateway(g gatewayService) http.HandlerFunc {
+	prefix := "/" + g.desc.ServiceName + "/"
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		name := strings.TrimPrefix(r.URL.Path, prefix)
+		for i := range g.desc.Methods {
+			if g.desc.Methods[i].MethodName == name {
+				s.serveUnary(w, r, g, &g.desc.Methods[i])
+				return
+			}
+		}
+		for i := range g.desc.Streams {
+			if g.desc.Streams[i].StreamName == name {
+				s.serveStream(w, r, g, &g.desc.Streams[i])
+				return
+			}
+		}
+		writeStatusError(w, status.Errorf(codes.Unimplemented, "unknown method %s for service %s", name, g.desc.ServiceName))
+	}
+}
+
+// serveUnary calls a unary method with the request body as its request.
+func (s *HTTPServer) serveUnary(w http.ResponseWriter, r *http.Request, g gatewayService, md *grpc.MethodDesc) {
+	method := "/" + g.desc.ServiceName + "/" + md.MethodName
+	body, ok := s.readBody(w, r)
+	if !ok {
+		return
+	}
+	ctx, stream := s.callContext(r, method)
+	decode := func(msg any) error {
+		if len(body) > 0 {
+			if err := protojson.Unmarshal(body, msg.(proto.Message)); err != nil {
+				return status.Errorf(codes.InvalidArgument, "invalid request JSON: %v", err)
+			}
+		}
+		return s.authorizeCall(ctx, method, msg.(proto.Message))
+	}
+	resp, err := md.Handler(g.impl, ctx, decode, chainUnary(s.callOptions.Unary))
+	stream.copyHeader(w)
+	if err != nil {
+		writeStatusError(w, err)
+		return
+	}
+	writeProtoJSON(w, http.StatusOK, resp.(proto.Message))
+}
+
+// serveStream calls a streaming method, reading the request stream from
+// the body and writing the responses as they are sent.
+func (s *HTTPServer) serveStream(w http.ResponseWriter, r *http.Request, g gatewayService, sd *grpc.StreamDesc) {
+	method := "/" + g.desc.ServiceName + "/" + sd.StreamName
+	keepOpen(w)
+	ctx, headers := s.callContext(r, method)
+	stream := &jsonServerStream{
+		ctx:     ctx,
+		headers: headers,
+		w:       w,
+		body:    json.NewDecoder(http.MaxBytesReader(w, r.Body, s.maxBodyBytes())),
+		authorize: func(msg proto.Message) error {
+			return s.authorizeCall(ctx, method, msg)
+		},
+	}
+	if err := s.authorizeCall(ctx, method, nil); err != nil {
+		writeStatusError(w, err)
+		return
+	}
+
+	err := s.chainStream(method, sd)(g.impl, stream)
+
+	switch {
+	case err != nil && !stream.started:
+		headers.copyHeader(w)
+		writeStatusError(w, err)
+	case err != nil:
+		// Too late for an HTTP status: the error ends the stream instead
+		data, _ := restJSON.Marshal(status.Convert(err).Proto())
+		w.Write([]byte(`{"error":`))
+		w.Write(data)
+		w.Write([]byte("}\n"))
+	case !stream.started:
+		stream.start()
+	}
+}
+
+// readBody reads a request body of at most MaxBodyBytes. On failure it
+// writes the error and returns false.
+func (s *HTTPServer) readBody(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, s.maxBodyBytes()))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeBodyTooLarge(w, tooLarge.Limit)
+		return nil, false
+	}
+	if err != nil {
+		writeStatusError(w, status.Errorf(codes.InvalidArgument, "failed to read request body: %v", err))
+		return nil, false
+	}
+	return body, true
+}
+
+// callContext returns the context a method called over HTTP runs in: the
+// request headers are its incoming metadata, and headers it sets are
+// collected in the returned stream.
+func (s *HTTPServer) callContext(r *http.Request, method string) (context.Context, *headerStream) {
+	md := metadata.MD{}
+	for name, values := range r.Header {
+		md.Append(strings.ToLower(name), values...)
+	}
+	stream := &headerStream{method: method}
+	ctx := metadata.NewIncomingContext(r.Context(), md)
+	return grpc.NewContextWithServerTransportStream(ctx, stream), stream
+}
+
+// chainUnary combines interceptors into one, outermost first (nil if there
+// are none).
+func chainUnary(interceptors []grpc.UnaryServerInterceptor) grpc.UnaryServerInterceptor {
+	if len(interceptors) == 0 {
+		return nil
+	}
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			interceptor, next := interceptors[i], handler
+			handler = func(ctx context.Context, req any) (any, error) {
+				return interceptor(ctx, req, info, next)
+			}
+		}
+		return handler(ctx, req)
+	}
+}
+
+// chainStream returns sd's handler wrapped in the stream interceptors,
+// outermost first.
+func (s *HTTPServer) chainStream(method string, sd *grpc.StreamDesc) grpc.StreamHandler {
+	handler := sd.Handler
+	info := &grpc.StreamServerInfo{
+		FullMethod:     method,
+		IsClientStream: sd.ClientStreams,
+		IsServerStream: sd.ServerStreams,
+	}
+	for i := len(s.callOptions.Stream) - 1; i >= 0; i-- {
+		interceptor, next := s.callOptions.Stream[i], handler
+		handler = func(srv any, ss grpc.ServerStream) error {
+			return interceptor(srv, ss, info, next)
+		}
+	}
+	return handler
+}
+
+// headerStream collects the headers interceptors and handlers set on a
+// call over HTTP.
+type headerStream struct {
+	method string
+	mu     sync.Mutex
+	header metadata.MD
+}
+
+func (s *headerStream) Method() string { return s.method }
+
+func (s *headerStream) SetHeader(md metadata.MD) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+
+func (s *headerStream) SendHeader(md metadata.MD) error { return s.SetHeader(md) }
+
+func (s *headerStream) SetTrailer(metadata.MD) error { return nil }
+
+// copyHeader sets the collected headers on the response, replacing any
+// already set there (e.g. the access log's X-Request-Id).
+func (s *headerStream) copyHeader(w http.ResponseWriter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for name, values := range s.header {
+		w.Header()[http.CanonicalHeaderKey(name)] = append([]string(nil), values...)
+	}
+}
+
+// jsonServerStream is a grpc.ServerStream over an HTTP request: messages
+// are received from the body and sent as newline-delimited JSON.
+type jsonServerStream struct {
+	ctx     context.Context
+	headers *headerStream
+	w       http.ResponseWriter
+	body    *json.Decoder
+	started bool // The response status and headers have been written
+
+	// authorize checks each request message against the caller's namespaces
+	authorize func(proto.Message) error
+}
+
+func (s *jsonServerStream) Context() context.Context { return s.ctx }
+
+func (s *jsonServerStream) SetHeader(md metadata.MD) error { return s.headers.SetHeader(md) }
+
+func (s *jsonServerStream) SendHeader(md metadata.MD) error {
+	s.headers.SetHeader(md)
+	s.start()
+	return nil
+}
+
+func (s *jsonServerStream) SetTrailer(metadata.MD) {}
+
+// start writes the response status and headers, once.
+func (s *jsonServerStream) start() {
+	if s.started {
+		return
+	}
+	s.started = true
+	s.headers.copyHeader(s.w)
+	s.w.Header().Set("Content-Type", "application/x-ndjson")
+	s.w.WriteHeader(http.StatusOK)
+}
+
+// SendMsg writes m as one line of JSON and flushes it to the client.
+func (s *jsonServerStream) SendMsg(m any) error {
+	data, err := restJSON.Marshal(m.(proto.Message))
+	if err != nil {
+		return status.Errorf(codes.Internal, "failed to encode response: %v", err)
+	}
+	s.start()
+	if _, err := s.w.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	if flusher, ok := s.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return nil
+}
+
+// RecvMsg decodes the next JSON message from the body, or returns io.EOF
+// at its end.
+func (s *jsonServerStream) RecvMsg(m any) error {
+	var raw json.RawMessage
+	if err := s.body.Decode(&raw); err != nil {
+		if errors.Is(err, io.EOF) {
+			return io.EOF
+		}
+		return status.Errorf(codes.InvalidArgument, "invalid request stream: %v", err)
+	}
+	if err := protojson.Unmarshal(raw, m.(proto.Message)); err != nil {
+		return status.Errorf(codes.InvalidArgument, "invalid request JSON: %v", err)
+	}
+	return s.authorize(m.(proto.Message))
+}
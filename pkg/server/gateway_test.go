@@ -0,0 +1,171 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	nanabushv1 "github.com/dasmlab/iskoces/pkg/proto/v1"
+	nanabushv2 "github.com/dasmlab/iskoces/pkg/proto/v2"
+)
+
+// detectServer implements DetectLanguage, echoing the request's text as
+// the detected language; every other method is unimplemented.
+type detectServer struct {
+	nanabushv1.UnimplementedTranslationServiceServer
+}
+
+func (detectServer) DetectLanguage(ctx context.Context, req *nanabushv1.DetectLanguageRequest) (*nanabushv1.DetectLanguageResponse, error) {
+	return &nanabushv1.DetectLanguageResponse{Candidates: []*nanabushv1.LanguageCandidate{
+		{Language: req.Text, Confidence: float64(req.MaxCandidates)},
+	}}, nil
+}
+
+// newGatewayServer returns an HTTP server with the services main exposes
+// as JSON.
+func newGatewayServer() *HTTPServer {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	s := NewHTTPServer(nil, logger, 0)
+	nanabushv1.RegisterTranslationServiceServer(s, detectServer{})
+	nanabushv2.RegisterTranslationServiceServer(s, nanabushv2.UnimplementedTranslationServiceServer{})
+	return s
+}
+
+// gatewayCall POSTs body to path and returns the response.
+func gatewayCall(t *testing.T, mux *http.ServeMux, method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+	return w
+}
+
+// Every RPC of the TranslationService descriptors has a gateway route and
+// is in the OpenAPI document, and every route is an RPC. An RPC added to
+// the proto without its ServiceDesc being regenerated and registered fails
+// here.
+func TestGatewayRoutesEveryRPC(t *testing.T) {
+	s := newGatewayServer()
+	mux := s.routes()
+	doc, err := openAPIDocument(s.gateways)
+	if err != nil {
+		t.Fatalf("openAPIDocument() = %v", err)
+	}
+	paths := doc["paths"].(map[string]any)
+
+	services := []struct {
+		file protoreflect.FileDescriptor
+		desc *grpc.ServiceDesc
+	}{
+		{nanabushv1.File_translation_proto, &nanabushv1.TranslationService_ServiceDesc},
+		{nanabushv2.File_v2_translation_proto, &nanabushv2.TranslationService_ServiceDesc},
+	}
+	for _, svc := range services {
+		sd := svc.file.Services().ByName("TranslationService")
+		if sd == nil {
+			t.Fatalf("%s has no TranslationService", svc.file.Path())
+		}
+		if string(sd.FullName()) != svc.desc.ServiceName {
+			t.Errorf("descriptor %s is registered as %s", sd.FullName(), svc.desc.ServiceName)
+		}
+
+		routed := make(map[string]bool)
+		for _, m := range svc.desc.Methods {
+			routed[m.MethodName] = true
+		}
+		for _, m := range svc.desc.Streams {
+			routed[m.StreamName] = true
+		}
+
+		methods := sd.Methods()
+		for i := 0; i < methods.Len(); i++ {
+			m := methods.Get(i)
+			path := "/" + string(sd.FullName()) + "/" + string(m.Name())
+			t.Run(path, func(t *testing.T) {
+				if !routed[string(m.Name())] {
+					t.Errorf("RPC %s has no method in the registered ServiceDesc", m.FullName())
+				}
+				delete(routed, string(m.Name()))
+				if _, ok := paths[path]; !ok {
+					t.Errorf("OpenAPI document has no path %s", path)
+				}
+
+				// The call reaches the service: its handler answers (here,
+				// that it isn't implemented), not the gateway
+				w := gatewayCall(t, mux, http.MethodPost, path, "{}")
+				if w.Code == http.StatusNotFound {
+					t.Fatalf("POST %s = 404", path)
+				}
+				if m.Name() == "DetectLanguage" {
+					return
+				}
+				var st struct {
+					Code    any    `json:"code"`
+					Message string `json:"message"`
+				}
+				if err := json.Unmarshal(w.Body.Bytes(), &st); err != nil {
+					t.Fatalf("POST %s = %d %q, want a JSON status", path, w.Code, w.Body)
+				}
+				if w.Code != http.StatusNotImplemented || !strings.Contains(st.Message, "not implemented") {
+					t.Errorf("POST %s = %d %q, want the service's Unimplemented error", path, w.Code, st.Message)
+				}
+			})
+		}
+		for name := range routed {
+			t.Errorf("%s/%s is routed but isn't an RPC of the descriptor", svc.desc.ServiceName, name)
+		}
+	}
+	if len(paths) != nanabushv1.File_translation_proto.Services().ByName("TranslationService").Methods().Len()+
+		nanabushv2.File_v2_translation_proto.Services().ByName("TranslationService").Methods().Len() {
+		t.Errorf("OpenAPI document has %d paths, want one per RPC", len(paths))
+	}
+}
+
+func TestGatewayCall(t *testing.T) {
+	mux := newGatewayServer().routes()
+	w := gatewayCall(t, mux, http.MethodPost, "/nanabush.v1.TranslationService/DetectLanguage", `{"text":"fr","max_candidates":2}`)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("POST DetectLanguage = %d %s %q", w.Code, w.Header().Get("Content-Type"), w.Body)
+	}
+	var resp struct {
+		Candidates []struct {
+			Language   string  `json:"language"`
+			Confidence float64 `json:"confidence"`
+		} `json:"candidates"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Candidates) != 1 || resp.Candidates[0].Language != "fr" || resp.Candidates[0].Confidence != 2 {
+		t.Errorf("DetectLanguage response = %+v, want the request echoed", resp)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		code   int
+	}{
+		{"unknown method", http.MethodPost, "/nanabush.v1.TranslationService/Transmogrify", "{}", http.StatusNotImplemented},
+		{"GET", http.MethodGet, "/nanabush.v1.TranslationService/DetectLanguage", "", http.StatusMethodNotAllowed},
+		{"bad JSON", http.MethodPost, "/nanabush.v1.TranslationService/DetectLanguage", `{"text":`, http.StatusBadRequest},
+		{"unknown field", http.MethodPost, "/nanabush.v1.TranslationService/DetectLanguage", `{"txet":"fr"}`, http.StatusBadRequest},
+		{"unknown service", http.MethodPost, "/nanabush.v3.TranslationService/DetectLanguage", "{}", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := gatewayCall(t, mux, tt.method, tt.path, tt.body); w.Code != tt.code {
+				t.Errorf("%s %s = %d %q, want %d", tt.method, tt.path, w.Code, w.Body, tt.code)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// SetGRPCServer serves g's gRPC calls, and gRPC-Web calls from browsers,
+// on the HTTP port alongside the HTTP API, so a single port can be exposed.
+// Requests are told apart by their content type. gRPC clients need HTTP/2,
+// so the server should use SetTLS or SetH2C. It must be called before
+// Start, once every service has been registered with g.
+func (s *HTTPServer) SetGRPCServer(g *grpc.Server) {
+	s.grpcServer = g
+}
+
+// withGRPC passes gRPC and gRPC-Web requests to the gRPC server, and other
+// requests to next. gRPC calls are subject to the gRPC server's
+// interceptors and limits rather than the HTTP API's authentication and
+// request limits.
+func (s *HTTPServer) withGRPC(next http.Handler) http.Handler {
+	if s.grpcServer == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType := r.Header.Get("Content-Type")
+		if r.Method != http.MethodPost || !strings.HasPrefix(contentType, "application/grpc") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		s.grpcCalls.Add(1)
+		defer s.grpcCalls.Add(-1)
+
+		// Calls set their own deadlines, and streams stay open indefinitely.
+		// Unlike event streams, calls aren't cancelled as soon as the
+		// server starts shutting down: as on the gRPC port, they get until
+		// the shutdown timeout (see Stop).
+		keepOpen(w)
+
+		if strings.HasPrefix(contentType, grpcWebContentType) {
+			s.serveGRPCWeb(w, r, contentType)
+			return
+		}
+		s.grpcServer.ServeHTTP(w, r)
+	})
+}
+
+// waitGRPCCalls waits for the gRPC calls in flight to finish, or for ctx
+// to be done. Calls on HTTP/2 connections accepted with h2c aren't tracked
+// by http.Server.Shutdown, so they are counted here.
+func (s *HTTPServer) waitGRPCCalls(ctx context.Context) error {
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	for s.grpcCalls.Load() > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
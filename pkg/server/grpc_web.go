@@ -0,0 +1,124 @@
+package server
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// grpcWebContentType prefixes the content types of gRPC-Web requests:
+// application/grpc-web[+proto] for binary messages and
+// application/grpc-web-text[+proto] for base64.
+const grpcWebContentType = "application/grpc-web"
+
+// grpcWebTrailerFlag marks the frame carrying a gRPC-Web response's
+// trailers, after its messages.
+const grpcWebTrailerFlag = 0x80
+
+// serveGRPCWeb serves a gRPC-Web call as the gRPC call it wraps: the
+// request is passed to the gRPC server as HTTP/2 gRPC, and the response's
+// trailers, which browsers can't read, are sent as a final frame of the
+// body. See https://github.com/grpc/grpc/blob/master/doc/PROTOCOL-WEB.md.
+func (s *HTTPServer) serveGRPCWeb(w http.ResponseWriter, r *http.Request, contentType string) {
+	text := strings.HasPrefix(contentType, grpcWebContentType+"-text")
+	subtype := strings.TrimPrefix(strings.TrimPrefix(contentType, grpcWebContentType), "-text")
+
+	r.ProtoMajor, r.ProtoMinor, r.Proto = 2, 0, "HTTP/2.0"
+	r.Header.Set("Content-Type", "application/grpc"+subtype)
+	r.Header.Del("Content-Length")
+	if text {
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{base64.NewDecoder(base64.StdEncoding, r.Body), r.Body}
+	}
+
+	gw := &grpcWebWriter{w: w, header: make(http.Header), contentType: contentType, text: text}
+	s.grpcServer.ServeHTTP(gw, r)
+	gw.writeTrailers()
+}
+
+// grpcWebWriter turns a gRPC response into a gRPC-Web response.
+type grpcWebWriter struct {
+	w           http.ResponseWriter
+	header      http.Header // The gRPC response's headers and trailers
+	contentType string
+	text        bool
+	buf         bytes.Buffer // Base64 responses are encoded as they are flushed
+	wroteHeader bool
+}
+
+func (g *grpcWebWriter) Header() http.Header {
+	return g.header
+}
+
+func (g *grpcWebWriter) WriteHeader(code int) {
+	if g.wroteHeader {
+		return
+	}
+	g.wroteHeader = true
+	trailers := g.trailerNames()
+	h := g.w.Header()
+	for name, values := range g.header {
+		if name == "Trailer" || trailers[name] || strings.HasPrefix(name, http.TrailerPrefix) {
+			continue
+		}
+		h[name] = values
+	}
+	h.Set("Content-Type", g.contentType)
+	h.Del("Content-Length")
+	g.w.WriteHeader(code)
+}
+
+func (g *grpcWebWriter) Write(p []byte) (int, error) {
+	g.WriteHeader(http.StatusOK)
+	if g.text {
+		return g.buf.Write(p)
+	}
+	return g.w.Write(p)
+}
+
+func (g *grpcWebWriter) Flush() {
+	g.WriteHeader(http.StatusOK)
+	if g.text && g.buf.Len() > 0 {
+		g.w.Write([]byte(base64.StdEncoding.EncodeToString(g.buf.Bytes())))
+		g.buf.Reset()
+	}
+	http.NewResponseController(g.w).Flush()
+}
+
+// writeTrailers writes the trailers the gRPC server set as the final frame.
+func (g *grpcWebWriter) writeTrailers() {
+	var block bytes.Buffer
+	trailers := g.trailerNames()
+	for name, values := range g.header {
+		key, undeclared := strings.CutPrefix(name, http.TrailerPrefix)
+		if !undeclared && !trailers[name] {
+			continue
+		}
+		for _, value := range values {
+			block.WriteString(strings.ToLower(key) + ": " + value + "\r\n")
+		}
+	}
+	frame := make([]byte, 5, 5+block.Len())
+	frame[0] = grpcWebTrailerFlag
+	binary.BigEndian.PutUint32(frame[1:], uint32(block.Len()))
+	g.Write(append(frame, block.Bytes()...))
+	g.Flush()
+}
+
+// trailerNames returns the trailers declared in the Trailer header.
+func (g *grpcWebWriter) trailerNames() map[string]bool {
+	names := make(map[string]bool)
+	for _, value := range g.header.Values("Trailer") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names[http.CanonicalHeaderKey(name)] = true
+			}
+		}
+	}
+	return names
+}
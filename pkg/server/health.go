@@ -0,0 +1,186 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/dasmlab/iskoces/pkg/service"
+	"github.com/dasmlab/iskoces/pkg/translate"
+)
+
+// ReadinessConfig sets what /readyz requires of the server besides not
+// shutting down.
+type ReadinessConfig struct {
+	// Health reports the engine's health checks; without it the engine
+	// isn't checked.
+	Health *service.HealthMonitor
+	// MaxEngineCheckAge is how recent the last successful engine check
+	// must be (default: three check intervals).
+	MaxEngineCheckAge time.Duration
+	// MaxQueuedRequests is the most requests that may wait for a worker
+	// (0 = the worker pool's queue capacity, if it has one).
+	MaxQueuedRequests int
+	// MaxBacklogJobs is the most unfinished async jobs (0 = no limit).
+	MaxBacklogJobs int
+}
+
+// readinessCheck is one condition reported by /readyz.
+type readinessCheck struct {
+	Name    string         `json:"name"`
+	OK      bool           `json:"ok"`
+	Message string         `json:"message"`
+	Detail  map[string]any `json:"detail,omitempty"`
+}
+
+// SetReadiness sets the conditions /readyz checks. It must be called
+// before Start.
+func (s *HTTPServer) SetReadiness(cfg ReadinessConfig) {
+	s.readiness = cfg
+}
+
+// handleLivez reports that the process is up and serving HTTP. It never
+// depends on the engine, so a pod isn't restarted while its engine loads
+// models or recovers.
+func (s *HTTPServer) handleLivez(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]any{
+		"status":         "ok",
+		"started_at":     s.startedAt.Format(time.RFC3339),
+		"uptime_seconds": int64(time.Since(s.startedAt).Seconds()),
+	})
+}
+
+// handleReadyz reports whether the server should receive traffic: it isn't
+// shutting down or draining, the engine passed a recent health check, a
+// worker is ready and the worker queue and job backlog are below their
+// limits. It answers 503 Service Unavailable with the failing checks
+// otherwise.
+func (s *HTTPServer) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	checks := s.readinessChecks(time.Now())
+	ready := true
+	for _, check := range checks {
+		ready = ready && check.OK
+	}
+
+	code, state := http.StatusOK, "ready"
+	if !ready {
+		code, state = http.StatusServiceUnavailable, "not ready"
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]any{
+		"status": state,
+		"checks": checks,
+	})
+}
+
+// readinessChecks runs the readiness checks that apply to the server.
+func (s *HTTPServer) readinessChecks(now time.Time) []readinessCheck {
+	cfg := s.readiness
+	checks := []readinessCheck{{Name: "server", OK: true, Message: "serving"}}
+	if s.ctx.Err() != nil {
+		checks[0] = readinessCheck{Name: "server", Message: "shutting down"}
+	}
+
+	if cfg.Health != nil {
+		engine := cfg.Health.EngineStatus()
+		maxAge := cfg.MaxEngineCheckAge
+		if maxAge <= 0 {
+			maxAge = 3 * engine.Interval
+		}
+		check := readinessCheck{Name: "engine", Detail: map[string]any{"max_check_age_seconds": maxAge.Seconds()}}
+		if engine.Checked {
+			check.Detail["checked_at"] = engine.CheckedAt.Format(time.RFC3339)
+			check.Detail["consecutive_failures"] = engine.ConsecutiveFailures
+			check.Detail["consecutive_successes"] = engine.ConsecutiveSuccesses
+			check.Detail["success_ratio"] = engine.SuccessRatio
+			check.Detail["checks"] = engine.Checks
+			check.Detail["flapping"] = engine.Flapping
+		}
+		if engine.LastError != "" {
+			check.Detail["last_error"] = engine.LastError
+			check.Detail["last_error_at"] = engine.LastErrorAt.Format(time.RFC3339)
+		}
+		switch age := now.Sub(engine.CheckedAt); {
+		case !engine.Checked:
+			check.Message = "not checked yet"
+		case !engine.Healthy:
+			check.Message = "unhealthy: " + engine.Error
+		case age > maxAge:
+			check.Message = fmt.Sprintf("last checked %s ago", age.Round(time.Second))
+		case engine.ConsecutiveFailures > 0:
+			// Not enough failures in a row to mark the engine unhealthy yet
+			check.OK = true
+			check.Message = fmt.Sprintf("healthy; last %d checks failed: %s", engine.ConsecutiveFailures, engine.Error)
+		default:
+			check.OK, check.Message = true, "healthy"
+		}
+		checks = append(checks, check)
+
+		drain := readinessCheck{Name: "drain", OK: !engine.Draining, Message: "accepting requests"}
+		if engine.Draining {
+			drain.Message = "draining"
+		}
+		checks = append(checks, drain)
+	}
+
+	if s.workerPool != nil {
+		checks = append(checks, workerReadiness(s.workerPool.PoolStats(), cfg.MaxQueuedRequests))
+	}
+
+	if cfg.MaxBacklogJobs > 0 {
+		jobs, _ := s.jobQueue.Backlog(translate.PriorityLow)
+		check := readinessCheck{
+			Name:    "jobs",
+			OK:      jobs < cfg.MaxBacklogJobs,
+			Message: fmt.Sprintf("%d of %d unfinished jobs", jobs, cfg.MaxBacklogJobs),
+			Detail:  map[string]any{"unfinished": jobs, "limit": cfg.MaxBacklogJobs},
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+// workerReadiness checks that a worker can take requests and that the
+// general workers' queue isn't full.
+func workerReadiness(stats translate.PoolStats, maxQueued int) readinessCheck {
+	ready := 0
+	for _, worker := range stats.PerWorker {
+		if worker.State == "ready" && !worker.Standby && !worker.Quarantined {
+			ready++
+		}
+	}
+	if maxQueued <= 0 {
+		maxQueued = stats.QueueCapacity
+	}
+	queued := stats.QueueDepthByPair[""]
+	check := readinessCheck{
+		Name: "workers",
+		Detail: map[string]any{
+			"ready":   ready,
+			"workers": stats.Workers,
+			"busy":    stats.Busy,
+			"queued":  queued,
+		},
+	}
+	if maxQueued > 0 {
+		check.Detail["queue_limit"] = maxQueued
+	}
+	switch {
+	case ready == 0:
+		check.Message = fmt.Sprintf("none of %d workers ready", stats.Workers)
+		if stats.LastError != "" {
+			check.Message += "; last error: " + stats.LastError
+		}
+	case maxQueued > 0 && queued >= maxQueued:
+		check.Message = fmt.Sprintf("%d requests waiting for a worker, at the limit of %d", queued, maxQueued)
+	default:
+		check.OK = true
+		check.Message = fmt.Sprintf("%d of %d workers ready, %d requests waiting", ready, stats.Workers, queued)
+	}
+	return check
+}
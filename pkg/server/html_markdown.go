@@ -0,0 +1,407 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"golang.org/x/net/html/charset"
+)
+
+// htmlToMarkdown converts an uploaded HTML document to markdown, so it can
+// be translated like other documents, and returns its <title>. The
+// document's charset is taken from contentType or its <meta> tags.
+// Headings, paragraphs, lists, block quotes, code, tables, emphasis, links
+// and images are kept; scripts, styles and forms are dropped.
+func htmlToMarkdown(r io.Reader, contentType string) (title, markdown string, err error) {
+	utf8Reader, err := charset.NewReader(r, contentType)
+	if err != nil {
+		return "", "", fmt.Errorf("unsupported charset: %w", err)
+	}
+	doc, err := html.Parse(utf8Reader)
+	if err != nil {
+		return "", "", err
+	}
+	if n := findElement(doc, atom.Title); n != nil {
+		title = strings.Join(strings.Fields(textContent(n)), " ")
+	}
+	root := findElement(doc, atom.Body)
+	if root == nil {
+		root = doc
+	}
+	blocks := htmlBlocks(root)
+	if len(blocks) == 0 {
+		return title, "", nil
+	}
+	return title, strings.Join(blocks, "\n\n") + "\n", nil
+}
+
+// findElement returns the first element of type a under n.
+func findElement(n *html.Node, a atom.Atom) *html.Node {
+	if n.Type == html.ElementNode && n.DataAtom == a {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := findElement(c, a); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// textContent returns the text under n.
+func textContent(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var b strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		b.WriteString(textContent(c))
+	}
+	return b.String()
+}
+
+// attr returns an attribute of n.
+func attr(n *html.Node, name string) string {
+	for _, a := range n.Attr {
+		if a.Key == name {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// htmlSkipped are elements whose content isn't part of the document text.
+var htmlSkipped = map[atom.Atom]bool{
+	atom.Head: true, atom.Script: true, atom.Style: true, atom.Template: true,
+	atom.Noscript: true, atom.Iframe: true, atom.Object: true, atom.Svg: true,
+	atom.Canvas: true, atom.Button: true, atom.Input: true, atom.Select: true,
+	atom.Textarea: true,
+}
+
+// htmlBlockElements start a new block.
+var htmlBlockElements = map[atom.Atom]bool{
+	atom.Address: true, atom.Article: true, atom.Aside: true, atom.Blockquote: true,
+	atom.Body: true, atom.Dd: true, atom.Details: true, atom.Dialog: true,
+	atom.Div: true, atom.Dl: true, atom.Dt: true, atom.Fieldset: true,
+	atom.Figcaption: true, atom.Figure: true, atom.Footer: true, atom.Form: true,
+	atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true,
+	atom.H6: true, atom.Header: true, atom.Hgroup: true, atom.Hr: true,
+	atom.Html: true, atom.Li: true, atom.Main: true, atom.Nav: true,
+	atom.Ol: true, atom.P: true, atom.Pre: true, atom.Section: true,
+	atom.Summary: true, atom.Table: true, atom.Ul: true,
+}
+
+// htmlBlocks converts the children of n to markdown blocks; runs of inline
+// content between block elements become paragraphs.
+func htmlBlocks(n *html.Node) []string {
+	var blocks []string
+	var inline strings.Builder
+	flush := func() {
+		if text := trimInline(inline.String()); text != "" {
+			blocks = append(blocks, text)
+		}
+		inline.Reset()
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && (htmlBlockElements[c.DataAtom] || htmlSkipped[c.DataAtom]) {
+			flush()
+			blocks = append(blocks, htmlBlock(c)...)
+			continue
+		}
+		inline.WriteString(htmlInline(c))
+	}
+	flush()
+	return blocks
+}
+
+// htmlBlock converts a block element to markdown blocks.
+func htmlBlock(n *html.Node) []string {
+	switch n.DataAtom {
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		text := strings.Join(strings.Fields(htmlInlineChildren(n)), " ")
+		if text == "" {
+			return nil
+		}
+		level := int(n.Data[1] - '0')
+		return []string{strings.Repeat("#", level) + " " + text}
+
+	case atom.P:
+		if text := trimInline(htmlInlineChildren(n)); text != "" {
+			return []string{text}
+		}
+		return nil
+
+	case atom.Hr:
+		return []string{"---"}
+
+	case atom.Pre:
+		code := strings.TrimRight(textContent(n), "\n")
+		language := codeLanguage(n)
+		if inner := findElement(n, atom.Code); inner != nil && language == "" {
+			language = codeLanguage(inner)
+		}
+		fence := "```"
+		for strings.Contains(code, fence) {
+			fence += "`"
+		}
+		return []string{fence + language + "\n" + code + "\n" + fence}
+
+	case atom.Blockquote:
+		inner := strings.Join(htmlBlocks(n), "\n\n")
+		if inner == "" {
+			return nil
+		}
+		lines := strings.Split(inner, "\n")
+		for i, line := range lines {
+			lines[i] = strings.TrimRight("> "+line, " ")
+		}
+		return []string{strings.Join(lines, "\n")}
+
+	case atom.Ul, atom.Ol:
+		return htmlList(n)
+
+	case atom.Table:
+		return htmlTable(n)
+
+	default:
+		if htmlSkipped[n.DataAtom] {
+			return nil
+		}
+		return htmlBlocks(n)
+	}
+}
+
+// codeLanguage returns the language of a "language-..." class.
+func codeLanguage(n *html.Node) string {
+	for _, class := range strings.Fields(attr(n, "class")) {
+		if language, ok := strings.CutPrefix(class, "language-"); ok {
+			return language
+		}
+	}
+	return ""
+}
+
+// htmlList converts a list to a markdown list, nesting its items' blocks.
+func htmlList(n *html.Node) []string {
+	var items []string
+	number := 1
+	if n.DataAtom == atom.Ol {
+		if _, err := fmt.Sscan(attr(n, "start"), &number); err != nil {
+			number = 1
+		}
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.ElementNode || c.DataAtom != atom.Li {
+			continue
+		}
+		marker := "- "
+		if n.DataAtom == atom.Ol {
+			marker = fmt.Sprintf("%d. ", number)
+			number++
+		}
+		// Nested lists follow the item's text directly, keeping the list
+		// tight
+		var content strings.Builder
+		for i, block := range htmlBlocks(c) {
+			if i > 0 && isListItem(block) {
+				content.WriteString("\n")
+			} else if i > 0 {
+				content.WriteString("\n\n")
+			}
+			content.WriteString(block)
+		}
+		lines := strings.Split(content.String(), "\n")
+		indent := strings.Repeat(" ", len(marker))
+		for i := range lines {
+			if i > 0 && lines[i] != "" {
+				lines[i] = indent + lines[i]
+			}
+		}
+		items = append(items, marker+strings.Join(lines, "\n"))
+	}
+	if len(items) == 0 {
+		return nil
+	}
+	return []string{strings.Join(items, "\n")}
+}
+
+// htmlTable converts a table to a GitHub table, its first row the header.
+func htmlTable(n *html.Node) []string {
+	var rows [][]string
+	var collect func(*html.Node)
+	collect = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode {
+				continue
+			}
+			switch c.DataAtom {
+			case atom.Thead, atom.Tbody, atom.Tfoot:
+				collect(c)
+			case atom.Tr:
+				var row []string
+				for cell := c.FirstChild; cell != nil; cell = cell.NextSibling {
+					if cell.Type == html.ElementNode && (cell.DataAtom == atom.Th || cell.DataAtom == atom.Td) {
+						text := strings.Join(strings.Fields(htmlInlineChildren(cell)), " ")
+						row = append(row, strings.ReplaceAll(text, "|", "\\|"))
+					}
+				}
+				rows = append(rows, row)
+			}
+		}
+	}
+	collect(n)
+
+	columns := 0
+	for _, row := range rows {
+		columns = max(columns, len(row))
+	}
+	if columns == 0 {
+		return nil
+	}
+	var b strings.Builder
+	writeRow := func(row []string) {
+		b.WriteString("|")
+		for i := 0; i < columns; i++ {
+			cell := ""
+			if i < len(row) {
+				cell = row[i]
+			}
+			b.WriteString(" " + cell + " |")
+		}
+		b.WriteString("\n")
+	}
+	writeRow(rows[0])
+	b.WriteString("|" + strings.Repeat(" --- |", columns) + "\n")
+	for _, row := range rows[1:] {
+		writeRow(row)
+	}
+	return []string{strings.TrimSuffix(b.String(), "\n")}
+}
+
+// htmlInline converts inline content to markdown, collapsing whitespace
+// as browsers do.
+func htmlInline(n *html.Node) string {
+	switch n.Type {
+	case html.TextNode:
+		return escapeMarkdown(collapseSpace(n.Data))
+	case html.ElementNode:
+	default:
+		return ""
+	}
+	if htmlSkipped[n.DataAtom] {
+		return ""
+	}
+
+	switch n.DataAtom {
+	case atom.Br:
+		return "  \n"
+	case atom.Strong, atom.B:
+		return wrapInline(htmlInlineChildren(n), "**")
+	case atom.Em, atom.I:
+		return wrapInline(htmlInlineChildren(n), "*")
+	case atom.Del, atom.S, atom.Strike:
+		return wrapInline(htmlInlineChildren(n), "~~")
+	case atom.Code, atom.Kbd, atom.Samp, atom.Tt:
+		code := collapseSpace(textContent(n))
+		if strings.TrimSpace(code) == "" {
+			return code
+		}
+		fence := "`"
+		for strings.Contains(code, fence) {
+			fence += "`"
+		}
+		if strings.HasPrefix(code, "`") || strings.HasSuffix(code, "`") {
+			code = " " + code + " "
+		}
+		return fence + code + fence
+	case atom.A:
+		text := htmlInlineChildren(n)
+		href := strings.TrimSpace(attr(n, "href"))
+		if strings.TrimSpace(text) == "" || href == "" || safeURL(href, false) == "" {
+			return text
+		}
+		return "[" + strings.TrimSpace(text) + "](" + markdownDestination(href) + ")"
+	case atom.Img:
+		src := strings.TrimSpace(attr(n, "src"))
+		if src == "" || safeURL(src, true) == "" {
+			return ""
+		}
+		return "![" + escapeMarkdown(collapseSpace(attr(n, "alt"))) + "](" + markdownDestination(src) + ")"
+	default:
+		return htmlInlineChildren(n)
+	}
+}
+
+// htmlInlineChildren converts the children of n as inline content.
+func htmlInlineChildren(n *html.Node) string {
+	var b strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		b.WriteString(htmlInline(c))
+	}
+	return b.String()
+}
+
+// wrapInline wraps text in emphasis markers, keeping its surrounding
+// whitespace outside them.
+func wrapInline(text, marker string) string {
+	trimmed := strings.TrimSpace(text)
+	if trimmed == "" {
+		return text
+	}
+	start := strings.Index(text, trimmed)
+	return text[:start] + marker + trimmed + marker + text[start+len(trimmed):]
+}
+
+// markdownDestination writes a link destination, in angle brackets if it
+// has spaces or parentheses.
+func markdownDestination(dest string) string {
+	if strings.ContainsAny(dest, " ()<>") {
+		return "<" + strings.NewReplacer("<", "%3C", ">", "%3E").Replace(dest) + ">"
+	}
+	return dest
+}
+
+// collapseSpace collapses runs of whitespace to one space.
+func collapseSpace(s string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range s {
+		if r == ' ' || r == '\t' || r == '\n' || r == '\r' || r == '\f' {
+			if !space {
+				b.WriteByte(' ')
+			}
+			space = true
+			continue
+		}
+		space = false
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// trimInline trims a paragraph's whitespace, including around line breaks.
+func trimInline(s string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if i+1 < len(lines) {
+			lines[i] = strings.TrimLeft(line, " ")
+		} else {
+			lines[i] = strings.TrimSpace(line)
+		}
+	}
+	// Line breaks keep their two trailing spaces, except at the end
+	return strings.TrimRight(strings.TrimLeft(strings.Join(lines, "\n"), " \n"), " \n")
+}
+
+// markdownEscaper escapes text that markdown would read as markup.
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`, "[", `\[`, "]", `\]`,
+)
+
+// escapeMarkdown escapes markdown markup characters in text.
+func escapeMarkdown(text string) string {
+	return markdownEscaper.Replace(text)
+}
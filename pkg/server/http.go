@@ -1,93 +1,405 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/dasmlab/iskoces/pkg/service"
+	"github.com/dasmlab/iskoces/pkg/translate"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/sirupsen/logrus"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"google.golang.org/grpc"
 )
 
+// sseRetry is the reconnection delay suggested to SSE clients.
+const sseRetry = 2 * time.Second
+
+// DefaultSSEKeepalive is how often an idle event stream gets a keepalive
+// comment, well within the 60 seconds after which ingresses commonly drop
+// idle connections.
+const DefaultSSEKeepalive = 15 * time.Second
+
+// Default HTTP server timeouts (see Timeouts).
+const (
+	DefaultReadTimeout  = 1 * time.Minute
+	DefaultWriteTimeout = 5 * time.Minute
+	DefaultIdleTimeout  = 2 * time.Minute
+
+	// maxReadHeaderTimeout bounds reading request headers, so slow clients
+	// can't hold connections open before a handler runs
+	maxReadHeaderTimeout = 10 * time.Second
+)
+
+// Timeouts bound how long HTTP connections may take. Event streams, the job
+// WebSocket and streaming gateway calls are exempt from the write timeout
+// for as long as they stay open.
+type Timeouts struct {
+	// Read bounds reading a request, body included.
+	Read time.Duration
+	// Write bounds a response, from the end of the request headers, so it
+	// must cover the slowest synchronous translation.
+	Write time.Duration
+	// Idle bounds how long a keep-alive connection waits for the next
+	// request.
+	Idle time.Duration
+	// SSEKeepalive is how long an event stream may go without an event
+	// before it gets a comment, so proxies don't drop it as idle.
+	SSEKeepalive time.Duration
+}
+
 // HTTPServer provides HTTP endpoints for translation job status and SSE progress updates.
 type HTTPServer struct {
-	jobQueue *service.JobQueue
-	logger   *logrus.Logger
-	port     int
+	jobQueue   *service.JobQueue
+	workerPool *translate.WorkerPool // Optional: served on /debug/workers
+	// Optional: serves the REST translate and job submission endpoints
+	translation *service.TranslationService
+	// Optional: serves the /admin/ operator endpoints (with an authenticator)
+	admin *service.AdminService
+	// gRPC services exposed as JSON, and how their methods are called
+	gateways    []gatewayService
+	callOptions CallOptions
+	// Optional: authenticates callers of the job and translate endpoints
+	auth Authenticator
+	// Optional: cross-origin policy of every endpoint
+	cors *corsPolicy
+	// Body and rate limits of every request
+	limits     Limits
+	ipLimiter  *rateLimiter
+	keyLimiter *rateLimiter
+	// Accept HTTP/2 over plaintext connections (without TLS)
+	h2c bool
+	// Optional: serves gRPC and gRPC-Web calls on the same port
+	grpcServer *grpc.Server
+	grpcCalls  atomic.Int64
+	// Longest an event stream goes without a write
+	sseKeepalive time.Duration
+	// What /readyz checks
+	readiness ReadinessConfig
+	// Service level objectives reported by /slo, if set
+	slos *service.SLOTracker
+	startedAt time.Time
+	logger     *logrus.Logger
+	accessLogger *logrus.Logger // Logs served requests; logger unless set
+	port       int
+	srv        *http.Server
+
+	// ctx is cancelled when the server shuts down, and with it the
+	// requests other than gRPC calls (see endOnShutdown), so open event
+	// streams end
+	ctx    context.Context
+	cancel context.CancelFunc
 }
 
 // NewHTTPServer creates a new HTTP server for job status and SSE.
 func NewHTTPServer(jobQueue *service.JobQueue, logger *logrus.Logger, port int) *HTTPServer {
-	return &HTTPServer{
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &HTTPServer{
 		jobQueue: jobQueue,
 		logger:   logger,
+		accessLogger: logger,
 		port:     port,
+		ctx:      ctx,
+		cancel:   cancel,
+		startedAt: time.Now(),
 	}
+	s.srv = &http.Server{
+		Addr: fmt.Sprintf(":%d", port),
+	}
+	s.srv.RegisterOnShutdown(cancel)
+	s.SetTimeouts(Timeouts{})
+	return s
+}
+
+// SetTimeouts sets the connection timeouts; zero fields get the defaults. It
+// must be called before Start.
+func (s *HTTPServer) SetTimeouts(t Timeouts) {
+	if t.Read <= 0 {
+		t.Read = DefaultReadTimeout
+	}
+	if t.Write <= 0 {
+		t.Write = DefaultWriteTimeout
+	}
+	if t.Idle <= 0 {
+		t.Idle = DefaultIdleTimeout
+	}
+	if t.SSEKeepalive <= 0 {
+		t.SSEKeepalive = DefaultSSEKeepalive
+	}
+	s.srv.ReadTimeout = t.Read
+	s.srv.ReadHeaderTimeout = min(t.Read, maxReadHeaderTimeout)
+	s.srv.WriteTimeout = t.Write
+	s.srv.IdleTimeout = t.Idle
+	s.sseKeepalive = t.SSEKeepalive
+}
+
+// SetTLS serves HTTPS with certs' certificate, reloaded when it is
+// rotated, and verifies client certificates if certs has a client CA.
+// Clients negotiate HTTP/2 or HTTP/1.1 with ALPN. It must be called before
+// Start.
+func (s *HTTPServer) SetTLS(certs *CertReloader) {
+	s.srv.TLSConfig = certs.ServerConfig()
+}
+
+// SetH2C accepts HTTP/2 without TLS (h2c), with prior knowledge or an
+// Upgrade from HTTP/1.1, for proxies that terminate TLS and speak HTTP/2 to
+// their backends. It has no effect with SetTLS. It must be called before
+// Start.
+func (s *HTTPServer) SetH2C(enabled bool) {
+	s.h2c = enabled
+}
+
+// SetWorkerPool exposes the worker pool's state on /debug/workers.
+func (s *HTTPServer) SetWorkerPool(pool *translate.WorkerPool) {
+	s.workerPool = pool
 }
 
 // Start starts the HTTP server.
 func (s *HTTPServer) Start() error {
+	mux := s.routes()
+
+	useTLS := s.srv.TLSConfig != nil
+	s.logger.WithFields(logrus.Fields{
+		"port": s.port,
+		"tls":  useTLS,
+		"h2c":  s.h2c && !useTLS,
+		"grpc": s.grpcServer != nil,
+	}).Info("Starting HTTP server for job status and SSE")
+
+	handler := s.withAccessLog(s.withCORS(s.withGRPC(s.endOnShutdown(s.withLimits(mux)))))
+	if s.h2c && !useTLS {
+		h2s := &http2.Server{IdleTimeout: s.srv.IdleTimeout}
+		// Registers h2s with the server so h2c connections get a GOAWAY
+		// when it shuts down
+		if err := http2.ConfigureServer(s.srv, h2s); err != nil {
+			return err
+		}
+		handler = h2c.NewHandler(handler, h2s)
+	}
+	s.srv.Handler = handler
+
+	var err error
+	if useTLS {
+		// The certificate comes from TLSConfig.GetCertificate
+		err = s.srv.ListenAndServeTLS("", "")
+	} else {
+		err = s.srv.ListenAndServe()
+	}
+	if !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// routes returns the server's endpoints, before the middleware every
+// request goes through.
+func (s *HTTPServer) routes() *http.ServeMux {
 	mux := http.NewServeMux()
 
 	// Job status endpoint (GET /api/v1/jobs/:jobID)
 	// SSE endpoint for job progress (GET /api/v1/jobs/:jobID/events)
-	// Both handled by the same function which routes based on path
-	mux.HandleFunc("/api/v1/jobs/", s.handleJobRequest)
+	// WebSocket for job progress and streamed content (GET /api/v1/jobs/:jobID/ws)
+	// Job cancellation (POST /api/v1/jobs/:jobID/cancel?reason=...)
+	// Job result download (GET /api/v1/jobs/:jobID/result)
+	// Job source text (GET /api/v1/jobs/:jobID/source)
+	// All handled by the same function which routes based on path
+	mux.HandleFunc("/api/v1/jobs/", s.authenticated(s.handleJobRequest))
+
+	// Job listing (GET /api/v1/jobs?namespace=&client_id=&status=&page_token=...)
+	// Job submission (POST /api/v1/jobs), with a translation service
+	mux.HandleFunc("/api/v1/jobs", s.authenticated(s.handleJobs))
+
+	// Synchronous translation (POST /api/v1/translate), with a translation service
+	if s.translation != nil {
+		mux.HandleFunc("/api/v1/translate", s.authenticated(s.handleTranslate))
+	}
+
+	// JSON gateway (POST /<package>.<Service>/<Method>) and its OpenAPI
+	// document (GET /openapi.json), for registered gRPC services
+	for _, g := range s.gateways {
+		mux.HandleFunc("/"+g.desc.ServiceName+"/", s.authenticated(s.handleGateway(g)))
+	}
+	if len(s.gateways) > 0 {
+		mux.HandleFunc("/openapi.json", s.handleOpenAPI)
+	}
 
-	// Health check endpoint
+	// Batch status (GET /api/v1/batches/:batchID)
+	mux.HandleFunc("/api/v1/batches/", s.authenticated(s.handleBatchStatus))
+
+	// Health check endpoint (kept for existing probes), liveness and
+	// readiness
 	mux.HandleFunc("/health", s.handleHealth)
+	mux.HandleFunc("/livez", s.handleLivez)
+	mux.HandleFunc("/readyz", s.handleReadyz)
 
 	// Prometheus metrics endpoint
 	mux.Handle("/metrics", promhttp.Handler())
 
-	addr := fmt.Sprintf(":%d", s.port)
-	s.logger.WithFields(logrus.Fields{
-		"port": s.port,
-	}).Info("Starting HTTP server for job status and SSE")
+	// Service level objective status (GET /slo)
+	if s.slos != nil {
+		mux.HandleFunc("/slo", s.handleSLO)
+	}
+
+	// Job monitoring UI (GET /ui/), calling the job endpoints from the browser
+	mux.Handle("/ui/", s.handleUI())
 
-	return http.ListenAndServe(addr, mux)
+	// Worker pool state (GET /debug/workers)
+	mux.HandleFunc("/debug/workers", s.authenticated(s.handleWorkers))
+
+	// Operator endpoints (GET /admin/clients, /admin/jobs, /admin/workers;
+	// GET, POST and DELETE /admin/drain), never without authentication
+	if s.admin != nil {
+		if s.auth != nil {
+			mux.HandleFunc("/admin/", s.authenticated(s.handleAdmin))
+		} else {
+			s.logger.Warn("HTTP admin endpoints are disabled: they need -http-api-keys or -http-jwks-url")
+		}
+	}
+	return mux
 }
 
-// handleJobRequest handles both job status and SSE events based on the path.
-func (s *HTTPServer) handleJobRequest(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
+// Stop shuts the server down gracefully: it stops accepting connections,
+// ends open event streams and job WebSockets (clients reconnect, e.g. to
+// another replica), and waits for other requests to finish until ctx is
+// done, when the remaining connections are closed. Start returns nil once
+// it has been called. gRPC calls served with SetGRPCServer are waited for
+// too, without being cancelled.
+func (s *HTTPServer) Stop(ctx context.Context) error {
+	err := s.srv.Shutdown(ctx)
+	if err == nil {
+		err = s.waitGRPCCalls(ctx)
+	}
+	if err != nil {
+		s.srv.Close()
 	}
+	return err
+}
 
+// endOnShutdown cancels a request's context when the server shuts down, so
+// event streams and WebSockets end rather than holding the shutdown up.
+func (s *HTTPServer) endOnShutdown(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+		stop := context.AfterFunc(s.ctx, cancel)
+		defer stop()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// keepOpen lifts the server's read and write timeouts from a long-lived
+// response such as an event stream.
+func keepOpen(w http.ResponseWriter) {
+	rc := http.NewResponseController(w)
+	rc.SetReadDeadline(time.Time{})
+	rc.SetWriteDeadline(time.Time{})
+}
+
+// handleJobRequest handles job status, SSE events, results, source text and
+// cancellation based on the path.
+func (s *HTTPServer) handleJobRequest(w http.ResponseWriter, r *http.Request) {
 	// Extract job ID from path
 	path := r.URL.Path[len("/api/v1/jobs/"):]
-	if path == "" {
-		http.Error(w, "Job ID is required", http.StatusBadRequest)
-		return
-	}
 
-	// Check if this is an SSE request
+	// Check if this is an SSE, WebSocket, result, source or cancel request
 	isSSE := false
+	isWebSocket := false
+	isResult := false
+	isSource := false
 	jobID := path
-	if len(path) > len("/events") && path[len(path)-len("/events"):] == "/events" {
+	method := http.MethodGet
+	if id, ok := strings.CutSuffix(path, "/events"); ok {
 		isSSE = true
-		jobID = path[:len(path)-len("/events")]
+		jobID = id
+	} else if id, ok := strings.CutSuffix(path, "/ws"); ok {
+		isWebSocket = true
+		jobID = id
+	} else if id, ok := strings.CutSuffix(path, "/result"); ok {
+		isResult = true
+		jobID = id
+	} else if id, ok := strings.CutSuffix(path, "/source"); ok {
+		isSource = true
+		jobID = id
+	} else if id, ok := strings.CutSuffix(path, "/cancel"); ok {
+		method = http.MethodPost
+		jobID = id
+	}
+	if r.Method != method {
+		w.Header().Set("Allow", method)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if jobID == "" {
+		http.Error(w, "Job ID is required", http.StatusBadRequest)
+		return
 	}
 
-	// Get job from queue
+	// Get job from queue; jobs in namespaces the caller can't use are not
+	// found either
 	job, err := s.jobQueue.GetJob(jobID)
+	if err == nil && !principalFrom(r.Context()).Allows(job.Namespace) {
+		err = fmt.Errorf("%w: %s", service.ErrJobNotFound, jobID)
+	}
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Job not found: %v", err), http.StatusNotFound)
 		return
 	}
 
+	if method == http.MethodPost {
+		s.handleJobCancel(w, r, jobID)
+		return
+	}
+
 	if isSSE {
 		s.handleJobEventsSSE(w, r, job)
+	} else if isWebSocket {
+		s.handleJobWebSocket(w, r, job)
+	} else if isResult {
+		s.handleJobResult(w, r, job)
+	} else if isSource {
+		s.handleJobSource(w, r, job)
 	} else {
 		s.handleJobStatusJSON(w, r, job)
 	}
 }
 
-// handleJobStatusJSON returns the current status of a translation job as JSON.
+// handleJobCancel cancels a queued or running job and returns its status.
+// A job that already finished is reported with 409 Conflict.
+func (s *HTTPServer) handleJobCancel(w http.ResponseWriter, r *http.Request, jobID string) {
+	job, err := s.jobQueue.Cancel(jobID, r.URL.Query().Get("reason"))
+	switch {
+	case errors.Is(err, service.ErrJobNotFound):
+		http.Error(w, fmt.Sprintf("Job not found: %v", err), http.StatusNotFound)
+		return
+	case errors.Is(err, service.ErrJobFinished):
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+	case err != nil:
+		http.Error(w, fmt.Sprintf("Failed to cancel job: %v", err), http.StatusInternalServerError)
+		return
+	}
+	s.handleJobStatusJSON(w, r, job)
+}
+
+// handleJobStatusJSON returns the current status of a translation job as JSON,
+// with an ETag of it: pollers sending it back in If-None-Match get 304 Not
+// Modified until the job changes.
 func (s *HTTPServer) handleJobStatusJSON(w http.ResponseWriter, r *http.Request, job *service.TranslationJob) {
+	writeETaggedJSON(w, r, jobStatusJSON(job, true))
+}
+
+// jobStatusJSON builds a job's status response, with its result if it
+// completed and includeResult is set.
+func jobStatusJSON(job *service.TranslationJob, includeResult bool) map[string]interface{} {
 	// Get current status (thread-safe)
 	status, message, progress := job.GetStatus()
 
@@ -98,9 +410,24 @@ func (s *HTTPServer) handleJobStatusJSON(w http.ResponseWriter, r *http.Request,
 		"status":          string(status),
 		"progress_percent": progress,
 		"progress_message": message,
+		"priority":        job.Priority.String(),
 		"created_at":      job.CreatedAt.Format(time.RFC3339),
+		"source_language": job.SourceLang,
+		"target_language": job.TargetLang,
 	}
 
+	if job.Namespace != "" {
+		response["namespace"] = job.Namespace
+	}
+	if job.ClientID != "" {
+		response["client_id"] = job.ClientID
+	}
+	if job.BatchID != "" {
+		response["batch_id"] = job.BatchID
+	}
+	if pausedBy := job.PauseOrigin(); pausedBy != "" {
+		response["paused_by"] = pausedBy
+	}
 	if job.StartedAt != nil {
 		response["started_at"] = job.StartedAt.Format(time.RFC3339)
 	}
@@ -110,13 +437,195 @@ func (s *HTTPServer) handleJobStatusJSON(w http.ResponseWriter, r *http.Request,
 	if job.Error != "" {
 		response["error"] = job.Error
 	}
+	if job.Crash != nil {
+		response["crash"] = crashReportJSON(job.Crash)
+	}
+	if job.DeadLetter {
+		response["dead_letter"] = true
+	}
+	if job.RequeuedAs != "" {
+		response["requeued_as"] = job.RequeuedAs
+	}
+	if job.DuplicateOf != "" {
+		response["duplicate_of"] = job.DuplicateOf
+	}
+	if position := job.QueuePosition(); position > 0 {
+		response["queue_position"] = position
+	}
+	if job.NotBefore != nil {
+		response["not_before"] = job.NotBefore.Format(time.RFC3339)
+	}
+	if attempt, failed := job.AttemptHistory(); attempt > 0 {
+		response["attempt"] = attempt
+		if len(failed) > 0 {
+			response["failed_attempts"] = jobAttemptsJSON(failed)
+		}
+	}
+	if webhook := job.WebhookState(); webhook != nil {
+		response["webhook"] = webhookDeliveryJSON(webhook)
+	}
+	response["progress_history"] = progressEventsJSON(job.ProgressHistory(0))
+	response["usage"] = jobUsageJSON(job.UsageSnapshot())
 
-	// If completed, include results
-	if status == service.JobStatusCompleted {
-		response["translated_title"] = job.TranslatedTitle
-		response["translated_markdown"] = job.TranslatedMarkdown
-		response["tokens_used"] = job.TokensUsed
-		response["inference_time"] = job.InferenceTime
+	// If completed, include results (offloaded markdown is fetched from
+	// result_url instead)
+	if status == service.JobStatusCompleted && includeResult {
+		addResultJSON(response, job)
+	}
+
+	return response
+}
+
+// handleBatchStatus returns a batch's aggregate state and its jobs' states
+// as JSON. Job results are fetched per job.
+func (s *HTTPServer) handleBatchStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	batchID := strings.TrimPrefix(r.URL.Path, "/api/v1/batches/")
+	if batchID == "" {
+		http.Error(w, "Batch ID is required", http.StatusBadRequest)
+		return
+	}
+	batch, err := s.jobQueue.GetBatch(batchID)
+	if err == nil && !principalFrom(r.Context()).Allows(batch.Namespace) {
+		err = fmt.Errorf("%w: %s", service.ErrBatchNotFound, batchID)
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Batch not found: %v", err), http.StatusNotFound)
+		return
+	}
+
+	st := batch.Status()
+	response := map[string]interface{}{
+		"batch_id":         batch.ID,
+		"state":            string(st.State),
+		"progress_percent": st.ProgressPercent,
+		"created_at":       batch.CreatedAt.Format(time.RFC3339),
+		"total_jobs":       len(batch.Jobs),
+		"queued_jobs":      st.Queued,
+		"running_jobs":     st.Running,
+		"succeeded_jobs":   st.Succeeded,
+		"failed_jobs":      st.Failed,
+		"cancelled_jobs":   st.Cancelled,
+		"jobs":             st.Jobs,
+	}
+	if batch.RequestID != "" {
+		response["request_id"] = batch.RequestID
+	}
+	if batch.Namespace != "" {
+		response["namespace"] = batch.Namespace
+	}
+	if st.CompletedAt != nil {
+		response["completed_at"] = st.CompletedAt.Format(time.RFC3339)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleListJobs lists jobs as JSON, newest first. Query parameters:
+// namespace, client_id, status (comma-separated or repeated),
+// created_after, created_before, completed_after and completed_before
+// (RFC 3339), sort (see service.JobSort), page_size (or limit), and
+// page_token (next_page_token of the previous page) or offset. The
+// response's total_size counts the matching jobs on every page. Results
+// aren't included; fetch them per job.
+func (s *HTTPServer) handleListJobs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	filter := service.JobFilter{
+		Namespace: query.Get("namespace"),
+		ClientID:  query.Get("client_id"),
+	}
+	if p := principalFrom(r.Context()); p.bound() {
+		// Callers only list jobs of their namespaces
+		if filter.Namespace == "" {
+			filter.Namespace = p.defaultNamespace()
+		}
+		if filter.Namespace == "" {
+			http.Error(w, "namespace is required: the caller may use several", http.StatusBadRequest)
+			return
+		}
+		if !p.Allows(filter.Namespace) {
+			http.Error(w, fmt.Sprintf("namespace %q is not allowed", filter.Namespace), http.StatusForbidden)
+			return
+		}
+	}
+	for _, value := range query["status"] {
+		for _, name := range strings.Split(value, ",") {
+			status, err := service.ParseJobStatus(strings.TrimSpace(name))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			filter.Statuses = append(filter.Statuses, status)
+		}
+	}
+	for _, bound := range []struct {
+		name string
+		t    *time.Time
+	}{
+		{"created_after", &filter.CreatedAfter},
+		{"created_before", &filter.CreatedBefore},
+		{"completed_after", &filter.CompletedAfter},
+		{"completed_before", &filter.CompletedBefore},
+	} {
+		if value := query.Get(bound.name); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("%s must be an RFC 3339 time", bound.name), http.StatusBadRequest)
+				return
+			}
+			*bound.t = t
+		}
+	}
+	page := service.JobPage{Token: query.Get("page_token")}
+	for _, number := range []struct {
+		name string
+		n    *int
+	}{
+		{"page_size", &page.Size},
+		{"limit", &page.Size},
+		{"offset", &page.Offset},
+	} {
+		if value := query.Get(number.name); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				http.Error(w, fmt.Sprintf("%s must be a non-negative integer", number.name), http.StatusBadRequest)
+				return
+			}
+			*number.n = n
+		}
+	}
+	sortBy, err := service.ParseJobSort(query.Get("sort"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	page.Sort = sortBy
+
+	list, err := s.jobQueue.FindJobs(filter, page)
+	if err != nil {
+		http.Error(w, "page_token is not a token returned by this endpoint for this sort", http.StatusBadRequest)
+		return
+	}
+	entries := make([]map[string]interface{}, 0, len(list.Jobs))
+	for _, job := range list.Jobs {
+		entry := jobStatusJSON(job, false)
+		delete(entry, "progress_history")
+		entries = append(entries, entry)
+	}
+	response := map[string]interface{}{"jobs": entries, "total_size": list.TotalSize}
+	if list.NextPageToken != "" {
+		response["next_page_token"] = list.NextPageToken
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -124,51 +633,135 @@ func (s *HTTPServer) handleJobStatusJSON(w http.ResponseWriter, r *http.Request,
 }
 
 // handleJobEventsSSE provides Server-Sent Events (SSE) for job progress updates.
+// The job's progress history is replayed first as "progress" events, whose
+// IDs are their sequence numbers, so a client that connects late sees what
+// happened; one that reconnects with Last-Event-ID gets only the events it
+// missed. "status" events carry the latest snapshot, with the ID of the
+// last progress event before it. A client reconnecting after it has seen
+// the job finish gets 204 No Content, which stops EventSource reconnecting.
+// Idle streams get a ": keepalive" comment every sseKeepalive, so proxies
+// don't drop them, and the stream ends as soon as a write fails.
 func (s *HTTPServer) handleJobEventsSSE(w http.ResponseWriter, r *http.Request, job *service.TranslationJob) {
+	lastSeq := lastEventID(r)
+	if state, _, _ := job.GetStatus(); lastSeq > 0 && state.IsTerminal() && len(job.ProgressHistory(lastSeq)) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
 	// Set up SSE headers
+	keepOpen(w)
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Connection", "keep-alive")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	// A write error means the client is gone, though it may only show
+	// once the connection's buffers fill up
+	closed := func(err error) {
+		s.logger.WithError(err).WithField("job_id", job.ID).Debug("Job event stream closed: client unreachable")
+	}
+
+	// Suggest a reconnection delay, replay the history, then send the
+	// initial status
+	fmt.Fprintf(w, "retry: %d\n\n", sseRetry.Milliseconds())
+	lastSeq, err := s.sendProgressEvents(w, job, lastSeq)
+	if err != nil {
+		closed(err)
+		return
+	}
+	initial, _, lastProgress := job.GetStatus()
+	lastStatus := string(initial)
+	lastPosition := job.QueuePosition()
+	if err := s.sendSSEEvent(w, lastSeq, "status", job); err != nil {
+		closed(err)
+		return
+	}
+
+	// A finished job's stream ends with its final status
+	if initial.IsTerminal() {
+		return
+	}
 
 	// Create a ticker to poll job status
+	lastWrite := time.Now()
 	ticker := time.NewTicker(1 * time.Second)
 	defer ticker.Stop()
 
-	// Send initial status
-	s.sendSSEEvent(w, "status", job)
-
 	// Poll for updates
-	lastStatus := ""
-	lastProgress := int32(-1)
 
 	for {
 		select {
 		case <-r.Context().Done():
 			// Client disconnected
 			return
-		case <-ticker.C:
+		case now := <-ticker.C:
+			seq, err := s.sendProgressEvents(w, job, lastSeq)
+			if err != nil {
+				closed(err)
+				return
+			}
+			if seq != lastSeq {
+				lastSeq = seq
+				lastWrite = now
+			}
+
 			// Get current status
 			status, _, progress := job.GetStatus()
+			position := job.QueuePosition()
 
-			// Send update if status or progress changed
-			if string(status) != lastStatus || progress != lastProgress {
-				s.sendSSEEvent(w, "status", job)
+			// Send update if status, progress or queue position changed
+			if string(status) != lastStatus || progress != lastProgress || position != lastPosition {
+				if err := s.sendSSEEvent(w, lastSeq, "status", job); err != nil {
+					closed(err)
+					return
+				}
+				lastWrite = now
 				lastStatus = string(status)
 				lastProgress = progress
+				lastPosition = position
 
-				// If job is completed or failed, send final event and close
-				if status == service.JobStatusCompleted || status == service.JobStatusFailed {
+				// If job is completed, failed, or cancelled, send final event and close
+				if status.IsTerminal() {
 					time.Sleep(100 * time.Millisecond) // Small delay to ensure final event is sent
 					return
 				}
 			}
+
+			// Keep an idle stream open
+			if now.Sub(lastWrite) >= s.sseKeepalive {
+				if err := writeSSEKeepalive(w); err != nil {
+					closed(err)
+					return
+				}
+				lastWrite = now
+			}
 		}
 	}
 }
 
-// sendSSEEvent sends a Server-Sent Event.
-func (s *HTTPServer) sendSSEEvent(w http.ResponseWriter, eventType string, job *service.TranslationJob) {
+// sendSSEEvent sends a Server-Sent Event with the job's status and seq, the
+// last progress event sent, as its ID.
+func (s *HTTPServer) sendSSEEvent(w http.ResponseWriter, seq int, eventType string, job *service.TranslationJob) error {
+	return s.writeSSE(w, strconv.Itoa(seq), eventType, statusEventJSON(job))
+}
+
+// lastEventID returns the sequence number of the last event a reconnecting
+// client saw: the Last-Event-ID header, or the last_event_id query
+// parameter for clients that can't set it (0 if neither is valid).
+func lastEventID(r *http.Request) int {
+	id := r.Header.Get("Last-Event-ID")
+	if id == "" {
+		id = r.URL.Query().Get("last_event_id")
+	}
+	seq, err := strconv.Atoi(id)
+	if err != nil || seq < 0 {
+		return 0
+	}
+	return seq
+}
+
+// statusEventJSON builds a "status" event: the job's latest snapshot, with
+// its result if it completed.
+func statusEventJSON(job *service.TranslationJob) map[string]interface{} {
 	status, message, progress := job.GetStatus()
 
 	event := map[string]interface{}{
@@ -180,33 +773,70 @@ func (s *HTTPServer) sendSSEEvent(w http.ResponseWriter, eventType string, job *
 		"timestamp":        time.Now().Format(time.RFC3339),
 	}
 
+	if position := job.QueuePosition(); position > 0 {
+		event["queue_position"] = position
+	}
+	if pausedBy := job.PauseOrigin(); pausedBy != "" {
+		event["paused_by"] = pausedBy
+	}
 	if job.Error != "" {
 		event["error"] = job.Error
 	}
 
 	// If completed, include results
 	if status == service.JobStatusCompleted {
-		event["translated_title"] = job.TranslatedTitle
-		event["translated_markdown"] = job.TranslatedMarkdown
-		event["tokens_used"] = job.TokensUsed
-		event["inference_time"] = job.InferenceTime
+		addResultJSON(event, job)
+	}
+
+	return event
+}
+
+// sendProgressEvents sends the job's progress events after seq as
+// "progress" events and returns the last sequence number sent (seq if
+// there were none), stopping at the first write error.
+func (s *HTTPServer) sendProgressEvents(w http.ResponseWriter, job *service.TranslationJob, seq int) (int, error) {
+	for _, event := range job.ProgressHistory(seq) {
+		entry := progressEventJSON(event)
+		entry["job_id"] = job.ID
+		if err := s.writeSSE(w, strconv.Itoa(event.Seq), "progress", entry); err != nil {
+			return seq, err
+		}
+		seq = event.Seq
 	}
+	return seq, nil
+}
 
+// writeSSE writes one Server-Sent Event with payload as its JSON data and,
+// if id is set, as its event ID. It returns the error writing or flushing
+// it to the client.
+func (s *HTTPServer) writeSSE(w http.ResponseWriter, id, eventType string, payload interface{}) error {
 	// Encode to JSON
-	data, err := json.Marshal(event)
+	data, err := json.Marshal(payload)
 	if err != nil {
 		s.logger.WithError(err).Error("Failed to marshal SSE event")
-		return
+		return nil
 	}
 
-	// Write SSE format: event: <type>\ndata: <json>\n\n
+	// Write SSE format: [id: <id>\n]event: <type>\ndata: <json>\n\n
+	if id != "" {
+		fmt.Fprintf(w, "id: %s\n", id)
+	}
 	fmt.Fprintf(w, "event: %s\n", eventType)
-	fmt.Fprintf(w, "data: %s\n\n", string(data))
+	if _, err := fmt.Fprintf(w, "data: %s\n\n", string(data)); err != nil {
+		return err
+	}
 
 	// Flush to ensure data is sent immediately
-	if flusher, ok := w.(http.Flusher); ok {
-		flusher.Flush()
+	return http.NewResponseController(w).Flush()
+}
+
+// writeSSEKeepalive writes a comment, which clients ignore, so proxies see
+// traffic on an idle event stream.
+func writeSSEKeepalive(w http.ResponseWriter) error {
+	if _, err := io.WriteString(w, ": keepalive\n\n"); err != nil {
+		return err
 	}
+	return http.NewResponseController(w).Flush()
 }
 
 // handleHealth provides a health check endpoint.
@@ -217,3 +847,205 @@ func (s *HTTPServer) handleHealth(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+
+// handleWorkers returns the worker pool's state as JSON: each worker's state,
+// load, uptime, last error and model versions, and the queue depth.
+func (s *HTTPServer) handleWorkers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if principalFrom(r.Context()).bound() {
+		// Worker state spans namespaces (e.g. the last request of a crashed worker)
+		http.Error(w, "Worker state is only available to callers not bound to namespaces", http.StatusForbidden)
+		return
+	}
+	if s.workerPool == nil {
+		http.Error(w, "The translation engine does not use a worker pool", http.StatusNotFound)
+		return
+	}
+
+	stats := s.workerPool.PoolStats()
+	workers := make([]map[string]interface{}, 0, len(stats.PerWorker))
+	for _, ws := range stats.PerWorker {
+		worker := map[string]interface{}{
+			"id":             ws.ID,
+			"generation":     ws.Generation,
+			"pair":           ws.Pair,
+			"interactive":    ws.Interactive,
+			"standby":        ws.Standby,
+			"state":          ws.State,
+			"quarantined":    ws.Quarantined,
+			"in_flight":      ws.InFlight,
+			"requests":       ws.Requests,
+			"started_at":     ws.StartedAt.Format(time.RFC3339),
+			"uptime_seconds": ws.Uptime.Seconds(),
+			"last_used":      ws.LastUsed.Format(time.RFC3339),
+			"rss_bytes":      ws.RSSBytes,
+			"version":        ws.Version,
+			"engine_version": ws.EngineVersion,
+			"models":         ws.Models,
+		}
+		if ws.Address != "" {
+			worker["address"] = ws.Address
+		}
+		if ws.LastError != "" {
+			worker["last_error"] = ws.LastError
+			worker["last_error_at"] = ws.LastErrorAt.Format(time.RFC3339)
+		}
+		workers = append(workers, worker)
+	}
+
+	response := map[string]interface{}{
+		"engine":                     string(stats.Engine),
+		"min_workers":                stats.MinWorkers,
+		"max_workers":                stats.MaxWorkers,
+		"workers":                    stats.Workers,
+		"busy_workers":               stats.Busy,
+		"queue_depth":                stats.QueueDepth,
+		"queue_depth_by_pair":        stats.QueueDepthByPair,
+		"average_round_trip_seconds": stats.AverageRoundTrip.Seconds(),
+		"worker_status":              workers,
+	}
+	if stats.LastError != "" {
+		response["last_error"] = stats.LastError
+		response["last_error_at"] = stats.LastErrorAt.Format(time.RFC3339)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// crashReportJSON converts a worker crash report for a job status response.
+func crashReportJSON(report *translate.CrashReport) map[string]interface{} {
+	crash := map[string]interface{}{
+		"worker_id":      report.WorkerID,
+		"generation":     report.Generation,
+		"class":          string(report.Class),
+		"exit_code":      report.ExitCode,
+		"at":             report.At.Format(time.RFC3339),
+		"uptime_seconds": report.Uptime.Seconds(),
+		"rss_bytes":      report.RSSBytes,
+		"output":         report.Output,
+	}
+	if report.Pair != "" {
+		crash["pair"] = report.Pair
+	}
+	if report.Signal != "" {
+		crash["signal"] = report.Signal
+	}
+	if req := report.LastRequest; req != nil {
+		lastRequest := map[string]interface{}{
+			"source_lang": req.SourceLang,
+			"target_lang": req.TargetLang,
+			"texts":       req.Texts,
+			"chars":       req.Chars,
+			"started_at":  req.StartedAt.Format(time.RFC3339),
+		}
+		if req.RequestID != "" {
+			lastRequest["request_id"] = req.RequestID
+		}
+		crash["last_request"] = lastRequest
+	}
+	return crash
+}
+
+// maxInlineResultBytes is the largest translated markdown status responses
+// include; larger results are only fetched from result_url.
+const maxInlineResultBytes = 64 << 10
+
+// addResultJSON adds a completed job's result to a status response: the
+// translation itself unless it is large, was offloaded or has expired, and
+// where to fetch it.
+func addResultJSON(response map[string]interface{}, job *service.TranslationJob) {
+	result := job.ResultInfo()
+	if !result.Expired {
+		response["translated_title"] = job.TranslatedTitle
+		if !result.Offloaded && len(job.TranslatedMarkdown) <= maxInlineResultBytes {
+			response["translated_markdown"] = job.TranslatedMarkdown
+		}
+	}
+	response["tokens_used"] = job.TokensUsed
+	response["inference_time"] = job.InferenceTime
+	if result.URL != "" {
+		response["result_url"] = result.URL
+	}
+	if result.ExpiresAt != nil {
+		response["result_expires_at"] = result.ExpiresAt.Format(time.RFC3339)
+	}
+	if result.Expired {
+		response["result_expired"] = true
+	}
+}
+
+// jobUsageJSON converts a job's resource usage for JSON responses.
+func jobUsageJSON(u service.JobUsage) map[string]interface{} {
+	return map[string]interface{}{
+		"characters":     u.Characters,
+		"chunks":         u.Chunks,
+		"engine_seconds": u.EngineTime.Seconds(),
+		"queue_seconds":  u.QueueTime.Seconds(),
+		"estimated_cost": u.EstimatedCost,
+	}
+}
+
+// webhookDeliveryJSON converts a job's webhook delivery state for JSON
+// responses.
+func webhookDeliveryJSON(d *service.WebhookDelivery) map[string]interface{} {
+	entry := map[string]interface{}{
+		"delivery_id": d.ID,
+		"status":      d.Status,
+		"attempts":    d.Attempts,
+	}
+	if d.LastError != "" {
+		entry["last_error"] = d.LastError
+	}
+	if d.DeliveredAt != nil {
+		entry["delivered_at"] = d.DeliveredAt.Format(time.RFC3339)
+	}
+	return entry
+}
+
+// progressEventsJSON converts a job's progress history for JSON responses.
+func progressEventsJSON(events []service.JobProgressEvent) []map[string]interface{} {
+	out := make([]map[string]interface{}, 0, len(events))
+	for _, event := range events {
+		out = append(out, progressEventJSON(event))
+	}
+	return out
+}
+
+// progressEventJSON converts one progress event for JSON responses.
+func progressEventJSON(event service.JobProgressEvent) map[string]interface{} {
+	entry := map[string]interface{}{
+		"seq":              event.Seq,
+		"timestamp":        event.Time.Format(time.RFC3339Nano),
+		"status":           string(event.Status),
+		"progress_percent": event.Percent,
+		"progress_message": event.Message,
+	}
+	if event.Chunk > 0 {
+		entry["chunk"] = event.Chunk
+		entry["total_chunks"] = event.TotalChunks
+	}
+	return entry
+}
+
+// jobAttemptsJSON converts a job's failed attempts for JSON responses.
+func jobAttemptsJSON(attempts []service.JobAttempt) []map[string]interface{} {
+	out := make([]map[string]interface{}, 0, len(attempts))
+	for _, attempt := range attempts {
+		entry := map[string]interface{}{
+			"attempt":     attempt.Attempt,
+			"started_at":  attempt.StartedAt.Format(time.RFC3339),
+			"ended_at":    attempt.EndedAt.Format(time.RFC3339),
+			"error":       attempt.Error,
+			"error_class": string(attempt.ErrorClass),
+		}
+		if attempt.RetryDelay > 0 {
+			entry["retry_delay_seconds"] = attempt.RetryDelay.Seconds()
+		}
+		out = append(out, entry)
+	}
+	return out
+}
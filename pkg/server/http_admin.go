@@ -0,0 +1,225 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	nanabushv1 "github.com/dasmlab/iskoces/pkg/proto/v1"
+	"github.com/dasmlab/iskoces/pkg/service"
+)
+
+// SetAdminService serves the operator endpoints under /admin/ with svc:
+// GET /admin/clients, /admin/jobs and /admin/workers, and GET, POST and
+// DELETE /admin/drain. Except for /admin/clients they call the AdminService
+// methods as set with SetCallOptions, as if over gRPC, and return their
+// responses in protobuf JSON. They are only served with an authenticator
+// (see SetAuthenticator), to callers not bound to namespaces. It must be
+// called before Start.
+func (s *HTTPServer) SetAdminService(svc *service.AdminService) {
+	s.admin = svc
+}
+
+// handleAdmin routes the operator endpoints.
+func (s *HTTPServer) handleAdmin(w http.ResponseWriter, r *http.Request) {
+	if principalFrom(r.Context()).bound() {
+		// Operator state and controls span namespaces
+		writeStatusError(w, status.Error(codes.PermissionDenied, "admin endpoints are only available to callers not bound to namespaces"))
+		return
+	}
+	switch strings.TrimPrefix(r.URL.Path, "/admin/") {
+	case "clients":
+		s.handleAdminClients(w, r)
+	case "jobs":
+		s.handleAdminJobs(w, r)
+	case "workers":
+		s.handleAdminWorkers(w, r)
+	case "drain":
+		s.handleAdminDrain(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// handleAdminClients lists the registered clients (GET
+// /admin/clients?namespace=), by name.
+func (s *HTTPServer) handleAdminClients(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	namespace := r.URL.Query().Get("namespace")
+	clients := s.admin.Translation.GetRegisteredClients()
+	sort.Slice(clients, func(i, j int) bool {
+		if clients[i].ClientName != clients[j].ClientName {
+			return clients[i].ClientName < clients[j].ClientName
+		}
+		return clients[i].ClientID < clients[j].ClientID
+	})
+
+	now := time.Now()
+	entries := make([]map[string]interface{}, 0, len(clients))
+	for _, client := range clients {
+		if namespace != "" && client.Namespace != namespace {
+			continue
+		}
+		entry := map[string]interface{}{
+			"client_id":               client.ClientID,
+			"client_name":             client.ClientName,
+			"client_version":          client.ClientVersion,
+			"namespace":               client.Namespace,
+			"registered_at":           client.RegisteredAt.Format(time.RFC3339),
+			"last_heartbeat":          client.LastHeartbeat.Format(time.RFC3339),
+			"seconds_since_heartbeat": int64(now.Sub(client.LastHeartbeat).Seconds()),
+		}
+		if len(client.Labels) > 0 {
+			entry["labels"] = client.Labels
+		}
+		if len(client.Metadata) > 0 {
+			entry["metadata"] = client.Metadata
+		}
+		entries = append(entries, entry)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"clients": entries,
+		"count":   len(entries),
+	})
+}
+
+// handleAdminJobs lists jobs with AdminService.ListJobs (GET
+// /admin/jobs?namespace=&client_id=&state=&created_after=&created_before=&page_size=&page_token=).
+// state takes JobState names with or without the JOB_STATE_ prefix, e.g.
+// state=failed,cancelled; times are RFC 3339.
+func (s *HTTPServer) handleAdminJobs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	query := r.URL.Query()
+	req := &nanabushv1.ListJobsRequest{
+		Namespace: query.Get("namespace"),
+		ClientId:  query.Get("client_id"),
+		PageToken: query.Get("page_token"),
+	}
+	for _, value := range query["state"] {
+		for _, name := range strings.Split(value, ",") {
+			name = strings.ToUpper(strings.TrimSpace(name))
+			state, ok := nanabushv1.JobState_value[name]
+			if !ok {
+				state, ok = nanabushv1.JobState_value["JOB_STATE_"+name]
+			}
+			if !ok || state == 0 {
+				writeStatusError(w, status.Errorf(codes.InvalidArgument, "unknown job state %q", name))
+				return
+			}
+			req.States = append(req.States, nanabushv1.JobState(state))
+		}
+	}
+	for _, bound := range []struct {
+		name string
+		ts   **timestamppb.Timestamp
+	}{
+		{"created_after", &req.CreatedAfter},
+		{"created_before", &req.CreatedBefore},
+	} {
+		if value := query.Get(bound.name); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				writeStatusError(w, status.Errorf(codes.InvalidArgument, "%s must be an RFC 3339 time", bound.name))
+				return
+			}
+			*bound.ts = timestamppb.New(t)
+		}
+	}
+	if value := query.Get("page_size"); value != "" {
+		n, err := strconv.ParseInt(value, 10, 32)
+		if err != nil {
+			writeStatusError(w, status.Error(codes.InvalidArgument, "page_size must be an integer"))
+			return
+		}
+		req.PageSize = int32(n)
+	}
+
+	s.callAdmin(w, r, "ListJobs", req, func(ctx context.Context, req any) (any, error) {
+		return s.admin.ListJobs(ctx, req.(*nanabushv1.ListJobsRequest))
+	})
+}
+
+// handleAdminWorkers reports the worker pool with AdminService.GetWorkerPool
+// (GET /admin/workers).
+func (s *HTTPServer) handleAdminWorkers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	s.callAdmin(w, r, "GetWorkerPool", &nanabushv1.GetWorkerPoolRequest{}, func(ctx context.Context, req any) (any, error) {
+		return s.admin.GetWorkerPool(ctx, req.(*nanabushv1.GetWorkerPoolRequest))
+	})
+}
+
+// handleAdminDrain reports the drain status (GET), enters drain mode
+// (POST, with a SetDrainModeRequest body, or none and an optional ?reason=)
+// or leaves it (DELETE), returning the DrainStatus.
+func (s *HTTPServer) handleAdminDrain(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.callAdmin(w, r, "GetDrainStatus", &nanabushv1.GetDrainStatusRequest{}, func(ctx context.Context, req any) (any, error) {
+			return s.admin.GetDrainStatus(ctx, req.(*nanabushv1.GetDrainStatusRequest))
+		})
+		return
+	case http.MethodPost, http.MethodDelete:
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	req := &nanabushv1.SetDrainModeRequest{}
+	if r.Method == http.MethodPost {
+		body, ok := s.readBody(w, r)
+		if !ok {
+			return
+		}
+		if len(body) > 0 {
+			if err := protojson.Unmarshal(body, req); err != nil {
+				writeStatusError(w, status.Errorf(codes.InvalidArgument, "invalid SetDrainModeRequest JSON: %v", err))
+				return
+			}
+		} else {
+			req.Draining = true
+			req.Reason = r.URL.Query().Get("reason")
+		}
+	}
+	s.callAdmin(w, r, "SetDrainMode", req, func(ctx context.Context, req any) (any, error) {
+		return s.admin.SetDrainMode(ctx, req.(*nanabushv1.SetDrainModeRequest))
+	})
+}
+
+// callAdmin calls an AdminService method through the interceptors and
+// writes its response.
+func (s *HTTPServer) callAdmin(w http.ResponseWriter, r *http.Request, name string, req proto.Message, handler grpc.UnaryHandler) {
+	method := fmt.Sprintf("/%s/%s", nanabushv1.AdminService_ServiceDesc.ServiceName, name)
+	resp, err := s.invoke(w, r, method, req, handler)
+	if err != nil {
+		writeStatusError(w, err)
+		return
+	}
+	writeProtoJSON(w, http.StatusOK, resp.(proto.Message))
+}
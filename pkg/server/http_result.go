@@ -0,0 +1,216 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	nanabushv1 "github.com/dasmlab/iskoces/pkg/proto/v1"
+	"github.com/dasmlab/iskoces/pkg/service"
+)
+
+// resultFormat is a representation of a job result.
+type resultFormat struct {
+	name        string
+	contentType string
+	extension   string
+}
+
+// resultFormats are the result representations, the default first.
+var resultFormats = []resultFormat{
+	{name: "markdown", contentType: "text/markdown; charset=utf-8", extension: "md"},
+	{name: "html", contentType: "text/html; charset=utf-8", extension: "html"},
+	{name: "json", contentType: "application/json", extension: "json"},
+}
+
+// resultMediaTypes maps Accept media types to result formats.
+var resultMediaTypes = map[string]string{
+	"text/markdown":         "markdown",
+	"text/x-markdown":       "markdown",
+	"text/plain":            "markdown",
+	"text/*":                "markdown",
+	"*/*":                   "markdown",
+	"text/html":             "html",
+	"application/xhtml+xml": "html",
+	"application/json":      "json",
+}
+
+// resultHTMLPolicy keeps rendered results from loading anything but images
+// and inline styles, as a second line of defence behind renderMarkdown.
+const resultHTMLPolicy = "default-src 'none'; img-src * data:; style-src 'unsafe-inline'; base-uri 'none'; form-action 'none'"
+
+// handleJobResult returns a completed job's translation (the title for
+// title jobs) as markdown, rendered HTML or JSON, chosen by the format
+// query parameter (markdown, html or json) or else the Accept header, and
+// reads an offloaded result from the result store. ?download=true makes it
+// an attachment. It carries an ETag, honouring If-None-Match. A job that
+// hasn't completed is 409 Conflict, an expired result 410 Gone.
+func (s *HTTPServer) handleJobResult(w http.ResponseWriter, r *http.Request, job *service.TranslationJob) {
+	w.Header().Add("Vary", "Accept")
+	format, err := negotiateResultFormat(r)
+	if err != nil {
+		status := http.StatusNotAcceptable
+		if r.URL.Query().Has("format") {
+			status = http.StatusBadRequest
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	download := false
+	if value := r.URL.Query().Get("download"); value != "" {
+		if download, err = strconv.ParseBool(value); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid download %q: want true or false", value), http.StatusBadRequest)
+			return
+		}
+	}
+
+	title, markdown, err := job.Result()
+	switch {
+	case errors.Is(err, service.ErrJobRunning), errors.Is(err, service.ErrNoResult):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case errors.Is(err, service.ErrResultNotFound):
+		http.Error(w, err.Error(), http.StatusGone)
+		return
+	case err != nil:
+		s.logger.WithError(err).WithField("job_id", job.ID).Error("Failed to read job result")
+		http.Error(w, fmt.Sprintf("Failed to read job result: %v", err), http.StatusBadGateway)
+		return
+	}
+	isTitle := job.Primitive == nanabushv1.PrimitiveType_PRIMITIVE_TITLE
+
+	var body []byte
+	switch format.name {
+	case "html":
+		body = []byte(resultHTML(job, title, markdown, isTitle))
+		w.Header().Set("Content-Security-Policy", resultHTMLPolicy)
+	case "json":
+		response := map[string]interface{}{
+			"job_id":              job.ID,
+			"request_id":          job.RequestID,
+			"source_language":     job.SourceLang,
+			"target_language":     job.TargetLang,
+			"translated_title":    title,
+			"translated_markdown": markdown,
+		}
+		if job.Namespace != "" {
+			response["namespace"] = job.Namespace
+		}
+		if job.CompletedAt != nil {
+			response["completed_at"] = job.CompletedAt.Format(time.RFC3339)
+		}
+		if body, err = json.Marshal(response); err != nil {
+			http.Error(w, fmt.Sprintf("Failed to encode job result: %v", err), http.StatusInternalServerError)
+			return
+		}
+		body = append(body, '\n')
+	default:
+		body = []byte(markdown)
+		if isTitle {
+			body = []byte(title)
+		}
+	}
+
+	disposition := "inline"
+	if download {
+		disposition = "attachment"
+	}
+	w.Header().Set("Content-Type", format.contentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{
+		"filename": job.ID + "." + format.extension,
+	}))
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	writeETagged(w, r, body)
+}
+
+// negotiateResultFormat picks a result's format from the format query
+// parameter, or the Accept media type with the highest quality that has
+// one (markdown when there is no Accept header).
+func negotiateResultFormat(r *http.Request) (resultFormat, error) {
+	name := strings.ToLower(r.URL.Query().Get("format"))
+	if name == "md" {
+		name = "markdown"
+	}
+	if name != "" {
+		for _, format := range resultFormats {
+			if format.name == name {
+				return format, nil
+			}
+		}
+		return resultFormat{}, fmt.Errorf("unknown format %q: want markdown, html or json", name)
+	}
+
+	accept := strings.Join(r.Header.Values("Accept"), ",")
+	if strings.TrimSpace(accept) == "" {
+		return resultFormats[0], nil
+	}
+	best, bestQuality := "", 0.0
+	for _, entry := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(entry))
+		if err != nil {
+			continue
+		}
+		quality := 1.0
+		if q, ok := params["q"]; ok {
+			if quality, err = strconv.ParseFloat(q, 64); err != nil {
+				continue
+			}
+		}
+		if name, ok := resultMediaTypes[mediaType]; ok && quality > bestQuality {
+			best, bestQuality = name, quality
+		}
+	}
+	for _, format := range resultFormats {
+		if format.name == best {
+			return format, nil
+		}
+	}
+	return resultFormat{}, fmt.Errorf("no acceptable format: results are text/markdown, text/html or application/json")
+}
+
+// resultHTML renders a job result as a standalone HTML document.
+func resultHTML(job *service.TranslationJob, title, markdown string, isTitle bool) string {
+	var b strings.Builder
+	heading := title
+	if heading == "" {
+		heading = job.ID
+	}
+	b.WriteString("<!DOCTYPE html>\n")
+	fmt.Fprintf(&b, "<html lang=\"%s\">\n<head>\n<meta charset=\"utf-8\">\n", html.EscapeString(job.TargetLang))
+	fmt.Fprintf(&b, "<title>%s</title>\n</head>\n<body>\n", html.EscapeString(heading))
+	if isTitle {
+		fmt.Fprintf(&b, "<h1>%s</h1>\n", html.EscapeString(title))
+	} else {
+		b.WriteString(renderMarkdown(markdown))
+	}
+	b.WriteString("</body>\n</html>\n")
+	return b.String()
+}
+
+// handleJobSource returns the text a job translates, its title and, for
+// document jobs, its markdown, as JSON, so the result can be checked
+// against it.
+func (s *HTTPServer) handleJobSource(w http.ResponseWriter, r *http.Request, job *service.TranslationJob) {
+	response := map[string]interface{}{
+		"job_id":          job.ID,
+		"request_id":      job.RequestID,
+		"primitive":       job.Primitive.String(),
+		"source_language": job.SourceLang,
+		"target_language": job.TargetLang,
+		"title":           job.Title,
+	}
+	if doc := job.Document; doc != nil {
+		if job.Title == "" {
+			response["title"] = doc.Title
+		}
+		response["markdown"] = doc.Markdown
+	}
+	writeETaggedJSON(w, r, response)
+}
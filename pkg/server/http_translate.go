@@ -0,0 +1,197 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	nanabushv1 "github.com/dasmlab/iskoces/pkg/proto/v1"
+	"github.com/dasmlab/iskoces/pkg/service"
+)
+
+// The REST endpoints run as these gRPC methods, so interceptors (drain,
+// validation, registration, quotas) treat them like the gRPC calls.
+const (
+	translateMethod = "/nanabush.v1.TranslationService/Translate"
+	submitMethod    = "/nanabush.v1.TranslationService/SubmitTranslation"
+)
+
+// restJSON encodes REST responses with the proto field names, like the job
+// status JSON.
+var restJSON = protojson.MarshalOptions{UseProtoNames: true}
+
+// SetTranslationService serves POST /api/v1/translate (v1 Translate) and
+// POST /api/v1/jobs (v1 SubmitTranslation) with svc. Request bodies are
+// TranslateRequest messages in protobuf JSON, or for job submissions a
+// multipart form with an uploaded document, and calls are made as set
+// with SetCallOptions, as if over gRPC; request headers become gRPC
+// metadata (x-client-id, x-namespace, x-request-id, ...). It must be called
+// before Start.
+func (s *HTTPServer) SetTranslationService(svc *service.TranslationService) {
+	s.translation = svc
+}
+
+// handleJobs lists jobs (GET) or, with a translation service, submits one
+// (POST).
+func (s *HTTPServer) handleJobs(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost && s.translation != nil {
+		s.handleSubmitJob(w, r)
+		return
+	}
+	if r.Method != http.MethodGet && s.translation != nil {
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	s.handleListJobs(w, r)
+}
+
+// handleTranslate translates a title or document synchronously and returns
+// the TranslateResponse. A document Translate queued as a job gets 202
+// Accepted instead, with the job's status URL in Location.
+func (s *HTTPServer) handleTranslate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	req, ok := s.readTranslateRequest(w, r)
+	if !ok {
+		return
+	}
+	resp, err := s.invoke(w, r, translateMethod, req, func(ctx context.Context, req any) (any, error) {
+		return s.translation.Translate(ctx, req.(*nanabushv1.TranslateRequest))
+	})
+	if err != nil {
+		writeStatusError(w, err)
+		return
+	}
+	translated := resp.(*nanabushv1.TranslateResponse)
+	if translated.Queued != nil {
+		w.Header().Set("Location", "/api/v1/jobs/"+translated.Queued.JobId)
+		writeProtoJSON(w, http.StatusAccepted, translated)
+		return
+	}
+	writeProtoJSON(w, http.StatusOK, translated)
+}
+
+// handleSubmitJob submits an async job, from a TranslateRequest or an
+// uploaded file (see readUploadRequest), and returns 202 Accepted with the
+// SubmitTranslationResponse, and the job's status URL in Location.
+func (s *HTTPServer) handleSubmitJob(w http.ResponseWriter, r *http.Request) {
+	var req *nanabushv1.TranslateRequest
+	var ok bool
+	if isMultipart(r) {
+		req, ok = s.readUploadRequest(w, r)
+	} else {
+		req, ok = s.readTranslateRequest(w, r)
+	}
+	if !ok {
+		return
+	}
+	resp, err := s.invoke(w, r, submitMethod, req, func(ctx context.Context, req any) (any, error) {
+		return s.translation.SubmitTranslation(ctx, req.(*nanabushv1.TranslateRequest))
+	})
+	if err != nil {
+		writeStatusError(w, err)
+		return
+	}
+	submitted := resp.(*nanabushv1.SubmitTranslationResponse)
+	w.Header().Set("Location", "/api/v1/jobs/"+submitted.JobId)
+	writeProtoJSON(w, http.StatusAccepted, submitted)
+}
+
+// readTranslateRequest decodes a TranslateRequest from the body. An
+// Idempotency-Key header is used when the body has no idempotency_key. On
+// failure it writes the error and returns false.
+func (s *HTTPServer) readTranslateRequest(w http.ResponseWriter, r *http.Request) (*nanabushv1.TranslateRequest, bool) {
+	body, ok := s.readBody(w, r)
+	if !ok {
+		return nil, false
+	}
+	req := &nanabushv1.TranslateRequest{}
+	if err := protojson.Unmarshal(body, req); err != nil {
+		writeStatusError(w, status.Errorf(codes.InvalidArgument, "invalid TranslateRequest JSON: %v", err))
+		return nil, false
+	}
+	if req.IdempotencyKey == "" {
+		req.IdempotencyKey = r.Header.Get("Idempotency-Key")
+	}
+	return req, true
+}
+
+// invoke calls handler through the unary interceptors as method, with the
+// request headers as incoming metadata. Headers the interceptors set (e.g.
+// retry-after, x-request-id) are copied to the response.
+func (s *HTTPServer) invoke(w http.ResponseWriter, r *http.Request, method string, req any, handler grpc.UnaryHandler) (any, error) {
+	ctx, stream := s.callContext(r, method)
+	if err := s.authorizeCall(ctx, method, req.(proto.Message)); err != nil {
+		return nil, err
+	}
+	info := &grpc.UnaryServerInfo{Server: s.translation, FullMethod: method}
+	var resp any
+	var err error
+	if interceptor := chainUnary(s.callOptions.Unary); interceptor != nil {
+		resp, err = interceptor(ctx, req, info, handler)
+	} else {
+		resp, err = handler(ctx, req)
+	}
+	stream.copyHeader(w)
+	return resp, err
+}
+
+// writeProtoJSON writes msg as protobuf JSON with the given status code.
+func writeProtoJSON(w http.ResponseWriter, code int, msg proto.Message) {
+	data, err := restJSON.Marshal(msg)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to encode response: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(data)
+}
+
+// writeStatusError writes a gRPC status error as its google.rpc.Status JSON
+// (code, message, details) with the matching HTTP status code.
+func writeStatusError(w http.ResponseWriter, err error) {
+	st := status.Convert(err)
+	writeProtoJSON(w, httpStatusFromCode(st.Code()), st.Proto())
+}
+
+// httpStatusFromCode maps a gRPC code to the HTTP status code gateways use
+// for it.
+func httpStatusFromCode(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return 499 // Client closed request
+	case codes.InvalidArgument, codes.OutOfRange, codes.FailedPrecondition:
+		return http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,192 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	nanabushv1 "github.com/dasmlab/iskoces/pkg/proto/v1"
+)
+
+// Upload formats, named by the format form field.
+const (
+	uploadMarkdown = "markdown"
+	uploadText     = "text"
+	uploadHTML     = "html"
+)
+
+// uploadExtensions and uploadMediaTypes identify an upload's format when
+// the form doesn't name it.
+var (
+	uploadExtensions = map[string]string{
+		".md": uploadMarkdown, ".markdown": uploadMarkdown, ".mdown": uploadMarkdown,
+		".txt": uploadText, ".text": uploadText,
+		".html": uploadHTML, ".htm": uploadHTML, ".xhtml": uploadHTML,
+	}
+	uploadMediaTypes = map[string]string{
+		"text/markdown": uploadMarkdown, "text/x-markdown": uploadMarkdown,
+		"text/plain": uploadText,
+		"text/html":  uploadHTML, "application/xhtml+xml": uploadHTML,
+	}
+)
+
+// isMultipart reports whether a request body is a multipart form.
+func isMultipart(r *http.Request) bool {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return mediaType == "multipart/form-data"
+}
+
+// readUploadRequest builds a document TranslateRequest from a multipart
+// form: the file field holds a markdown, text or HTML document (format
+// from the format field, else the file name's extension or the part's
+// Content-Type), title optionally names it, and other fields set the
+// TranslateRequest fields of the same name (source_language,
+// target_language, namespace, priority, callback_url, ...). job_id
+// defaults to the file name. HTML is converted to markdown, its <title>
+// becoming the default title; text is translated as is. On failure it
+// writes the error and returns false.
+func (s *HTTPServer) readUploadRequest(w http.ResponseWriter, r *http.Request) (*nanabushv1.TranslateRequest, bool) {
+	r.Body = http.MaxBytesReader(w, r.Body, s.maxBodyBytes())
+	if err := r.ParseMultipartForm(s.maxBodyBytes()); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeBodyTooLarge(w, tooLarge.Limit)
+			return nil, false
+		}
+		writeStatusError(w, status.Errorf(codes.InvalidArgument, "invalid multipart form: %v", err))
+		return nil, false
+	}
+	form := r.MultipartForm
+	defer form.RemoveAll()
+
+	files := form.File["file"]
+	if len(files) != 1 {
+		writeStatusError(w, status.Errorf(codes.InvalidArgument, "the form must have exactly one file field, has %d", len(files)))
+		return nil, false
+	}
+	file := files[0]
+
+	format := strings.ToLower(r.FormValue("format"))
+	switch format {
+	case "":
+		format = uploadExtensions[strings.ToLower(path.Ext(file.Filename))]
+		if format == "" {
+			mediaType, _, _ := mime.ParseMediaType(file.Header.Get("Content-Type"))
+			format = uploadMediaTypes[mediaType]
+		}
+	case "md":
+		format = uploadMarkdown
+	case "txt":
+		format = uploadText
+	}
+	if format != uploadMarkdown && format != uploadText && format != uploadHTML {
+		st := status.Newf(codes.InvalidArgument, "unsupported file %q: upload markdown (.md), text (.txt) or HTML (.html), or set format", file.Filename)
+		writeProtoJSON(w, http.StatusUnsupportedMediaType, st.Proto())
+		return nil, false
+	}
+
+	req, err := uploadFields(form.Value)
+	if err != nil {
+		writeStatusError(w, err)
+		return nil, false
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		writeStatusError(w, status.Errorf(codes.Internal, "failed to read the uploaded file: %v", err))
+		return nil, false
+	}
+	defer f.Close()
+	title := r.FormValue("title")
+	var markdown string
+	if format == uploadHTML {
+		htmlTitle, converted, err := htmlToMarkdown(f, file.Header.Get("Content-Type"))
+		if err != nil {
+			writeStatusError(w, status.Errorf(codes.InvalidArgument, "failed to read the uploaded HTML: %v", err))
+			return nil, false
+		}
+		if title == "" {
+			title = htmlTitle
+		}
+		markdown = converted
+	} else {
+		data, err := io.ReadAll(f)
+		if err != nil {
+			writeStatusError(w, status.Errorf(codes.Internal, "failed to read the uploaded file: %v", err))
+			return nil, false
+		}
+		if !utf8.Valid(data) {
+			writeStatusError(w, status.Errorf(codes.InvalidArgument, "the uploaded file is not UTF-8 text"))
+			return nil, false
+		}
+		markdown = string(data)
+	}
+	if strings.TrimSpace(markdown) == "" {
+		writeStatusError(w, status.Errorf(codes.InvalidArgument, "the uploaded file %q has no text", file.Filename))
+		return nil, false
+	}
+
+	req.Primitive = nanabushv1.PrimitiveType_PRIMITIVE_DOC_TRANSLATE
+	req.Source = &nanabushv1.TranslateRequest_Doc{Doc: &nanabushv1.DocumentContent{
+		Title:    title,
+		Markdown: markdown,
+		Slug:     req.PageSlug,
+	}}
+	if req.JobId == "" {
+		req.JobId = path.Base(file.Filename)
+	}
+	if req.IdempotencyKey == "" {
+		req.IdempotencyKey = r.Header.Get("Idempotency-Key")
+	}
+	return req, true
+}
+
+// uploadFields sets the scalar TranslateRequest fields named by an upload
+// form's other fields, parsed as in protobuf JSON (enums by name,
+// timestamps in RFC 3339).
+func uploadFields(values map[string][]string) (*nanabushv1.TranslateRequest, error) {
+	req := &nanabushv1.TranslateRequest{}
+	fields := req.ProtoReflect().Descriptor().Fields()
+	set := make(map[string]any)
+	for name, value := range values {
+		if name == "title" || name == "format" {
+			continue
+		}
+		fd := fields.ByName(protoreflect.Name(name))
+		if fd == nil || fd.IsList() || fd.IsMap() || fd.ContainingOneof() != nil || name == "primitive" ||
+			(fd.Message() != nil && fd.Message().FullName() != "google.protobuf.Timestamp") {
+			return nil, status.Errorf(codes.InvalidArgument, "unsupported form field %q", name)
+		}
+		if len(value) != 1 {
+			return nil, status.Errorf(codes.InvalidArgument, "form field %q is repeated", name)
+		}
+		if fd.Kind() == protoreflect.BoolKind {
+			b, err := strconv.ParseBool(value[0])
+			if err != nil {
+				return nil, status.Errorf(codes.InvalidArgument, "form field %q must be true or false", name)
+			}
+			set[name] = b
+			continue
+		}
+		set[name] = value[0]
+	}
+	data, err := json.Marshal(set)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to encode form fields: %v", err)
+	}
+	if err := protojson.Unmarshal(data, req); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid form fields: %v", err)
+	}
+	return req, nil
+}
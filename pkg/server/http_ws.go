@@ -0,0 +1,346 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/net/websocket"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	nanabushv1 "github.com/dasmlab/iskoces/pkg/proto/v1"
+	"github.com/dasmlab/iskoces/pkg/service"
+)
+
+// translateStreamMethod is the gRPC method that translates the content a
+// WebSocket client streams in.
+const translateStreamMethod = "/nanabush.v1.TranslationService/TranslateStream"
+
+// wsEvent is a message to a WebSocket client: the events of the SSE
+// stream ("progress", "status"), plus "chunk" for translated content and
+// "error" for a failed client message (data is a google.rpc.Status).
+type wsEvent struct {
+	Event string `json:"event"`
+	ID    string `json:"id,omitempty"`
+	Data  any    `json:"data"`
+}
+
+// wsClientMessage is a message from a WebSocket client: "cancel" cancels
+// the job, "chunk" streams a TranslateChunk in to be translated.
+type wsClientMessage struct {
+	Type   string          `json:"type"`
+	Reason string          `json:"reason,omitempty"`
+	Chunk  json.RawMessage `json:"chunk,omitempty"`
+}
+
+// handleJobWebSocket serves a WebSocket alternative to the SSE stream, for
+// browsers behind proxies that buffer event streams. The same "progress"
+// and "status" events, with the same IDs, are sent as JSON messages
+// (?last_event_id= replaces Last-Event-ID), and the connection closes once
+// the job has finished.
+//
+// The client can send {"type": "cancel", "reason": ...} to cancel the job,
+// and {"type": "chunk", "chunk": {...}} messages carrying TranslateChunks to
+// have content translated as by TranslateStream: the first chunk sets the
+// languages (and window), translated chunks come back as "chunk" events,
+// and the chunk marked is_final ends the content stream, one per
+// connection. Chunks without a job_id get the job's. Browsers can't set
+// headers on a WebSocket, so ?client_id= and ?namespace= stand in for
+// X-Client-Id and X-Namespace.
+func (s *HTTPServer) handleJobWebSocket(w http.ResponseWriter, r *http.Request, job *service.TranslationJob) {
+	// Browsers don't apply CORS to WebSockets, so the origin is checked
+	// here rather than by websocket.Handler. The hijacked connection keeps
+	// the server's deadlines unless they are lifted first.
+	if !s.allowsWebSocket(r) {
+		http.Error(w, "Origin not allowed", http.StatusForbidden)
+		return
+	}
+	keepOpen(w)
+	websocket.Server{Handler: func(conn *websocket.Conn) {
+		s.serveJobSocket(conn, r, job)
+	}}.ServeHTTP(w, r)
+}
+
+// serveJobSocket runs one job WebSocket connection until the job has
+// finished (and its content stream, if any, has ended), the client closes
+// it or the server shuts down.
+func (s *HTTPServer) serveJobSocket(conn *websocket.Conn, r *http.Request, job *service.TranslationJob) {
+	defer conn.Close()
+	conn.MaxPayloadBytes = int(s.maxBodyBytes())
+
+	// The request context isn't cancelled when a hijacked connection closes
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	query := r.URL.Query()
+	header := r.Header.Clone()
+	if clientID := query.Get("client_id"); clientID != "" && header.Get("X-Client-Id") == "" {
+		header.Set("X-Client-Id", clientID)
+	}
+	if namespace := query.Get("namespace"); namespace != "" && header.Get("X-Namespace") == "" && principalFrom(ctx).Allows(namespace) {
+		header.Set("X-Namespace", namespace)
+	}
+	r = r.WithContext(ctx)
+	r.Header = header
+
+	sock := &jobSocket{server: s, conn: conn, request: r, job: job}
+	messages := make(chan wsClientMessage)
+	go sock.receive(ctx, messages)
+
+	ticker := time.NewTicker(1 * time.Second)
+	defer ticker.Stop()
+
+	// Replay the history, then send the initial status
+	lastSeq := sock.sendProgress(lastEventID(r))
+	sock.send("status", strconv.Itoa(lastSeq), statusEventJSON(job))
+
+	lastStatus, _, lastProgress := job.GetStatus()
+	lastPosition := job.QueuePosition()
+	finished := lastStatus.IsTerminal()
+
+	for !finished || sock.streaming() {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-messages:
+			if !ok {
+				// Client closed the connection
+				return
+			}
+			sock.handle(ctx, msg)
+		case <-sock.streamDone:
+			sock.endStream()
+		case <-ticker.C:
+			lastSeq = sock.sendProgress(lastSeq)
+
+			// Send update if status, progress or queue position changed
+			state, _, progress := job.GetStatus()
+			position := job.QueuePosition()
+			if state != lastStatus || progress != lastProgress || position != lastPosition {
+				sock.send("status", strconv.Itoa(lastSeq), statusEventJSON(job))
+				lastStatus, lastProgress, lastPosition = state, progress, position
+				finished = state.IsTerminal()
+			}
+		}
+	}
+}
+
+// jobSocket is the state of one job WebSocket connection. Its fields are
+// owned by the goroutine running serveJobSocket.
+type jobSocket struct {
+	server  *HTTPServer
+	conn    *websocket.Conn
+	request *http.Request
+	job     *service.TranslationJob
+
+	// The content stream: chunks feeds it until the final chunk, and
+	// streamDone is closed when it has ended (both nil once seen)
+	streamStarted bool
+	chunks        chan *nanabushv1.TranslateChunk
+	streamDone    chan struct{}
+}
+
+// streaming reports whether the content stream is still running.
+func (sock *jobSocket) streaming() bool {
+	return sock.streamDone != nil
+}
+
+// endStream forgets the content stream once it has ended.
+func (sock *jobSocket) endStream() {
+	sock.chunks = nil
+	sock.streamDone = nil
+}
+
+// send writes one event to the client. Writes may come from the content
+// stream's goroutine too; websocket.Conn serializes them.
+func (sock *jobSocket) send(event, id string, data any) {
+	if err := websocket.JSON.Send(sock.conn, wsEvent{Event: event, ID: id, Data: data}); err != nil {
+		sock.server.logger.WithError(err).WithField("job_id", sock.job.ID).Debug("Failed to send WebSocket event")
+	}
+}
+
+// sendError sends err as an "error" event with its google.rpc.Status.
+func (sock *jobSocket) sendError(err error) {
+	data, _ := restJSON.Marshal(status.Convert(err).Proto())
+	sock.send("error", "", json.RawMessage(data))
+}
+
+// sendProgress sends the job's progress events after seq and returns the
+// last sequence number sent, like sendProgressEvents.
+func (sock *jobSocket) sendProgress(seq int) int {
+	for _, event := range sock.job.ProgressHistory(seq) {
+		entry := progressEventJSON(event)
+		entry["job_id"] = sock.job.ID
+		sock.send("progress", strconv.Itoa(event.Seq), entry)
+		seq = event.Seq
+	}
+	return seq
+}
+
+// receive reads client messages into messages until the connection closes,
+// then closes it. Messages that aren't JSON are answered with an error.
+func (sock *jobSocket) receive(ctx context.Context, messages chan<- wsClientMessage) {
+	defer close(messages)
+	for {
+		var data []byte
+		if err := websocket.Message.Receive(sock.conn, &data); err != nil {
+			if !errors.Is(err, io.EOF) {
+				sock.server.logger.WithError(err).WithField("job_id", sock.job.ID).Debug("WebSocket receive failed")
+			}
+			return
+		}
+		var msg wsClientMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			sock.sendError(status.Errorf(codes.InvalidArgument, "invalid message JSON: %v", err))
+			continue
+		}
+		select {
+		case messages <- msg:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// handle acts on one client message.
+func (sock *jobSocket) handle(ctx context.Context, msg wsClientMessage) {
+	switch msg.Type {
+	case "cancel":
+		_, err := sock.server.jobQueue.Cancel(sock.job.ID, msg.Reason)
+		switch {
+		case errors.Is(err, service.ErrJobFinished):
+			sock.sendError(status.Error(codes.FailedPrecondition, err.Error()))
+		case errors.Is(err, service.ErrJobNotFound):
+			sock.sendError(status.Error(codes.NotFound, err.Error()))
+		case err != nil:
+			sock.sendError(status.Errorf(codes.Internal, "failed to cancel job: %v", err))
+		}
+	case "chunk":
+		sock.streamChunk(ctx, msg.Chunk)
+	default:
+		sock.sendError(status.Errorf(codes.InvalidArgument, "unknown message type %q; expected cancel or chunk", msg.Type))
+	}
+}
+
+// streamChunk passes a chunk to the content stream, starting it with the
+// first one.
+func (sock *jobSocket) streamChunk(ctx context.Context, data json.RawMessage) {
+	if sock.server.translation == nil {
+		sock.sendError(status.Error(codes.Unimplemented, "streaming translation is not enabled on this server"))
+		return
+	}
+	chunk := &nanabushv1.TranslateChunk{}
+	if err := protojson.Unmarshal(data, chunk); err != nil {
+		sock.sendError(status.Errorf(codes.InvalidArgument, "invalid TranslateChunk JSON: %v", err))
+		return
+	}
+	if chunk.JobId == "" {
+		chunk.JobId = sock.job.ID
+	}
+
+	if sock.chunks == nil {
+		if sock.streamStarted {
+			sock.sendError(status.Error(codes.FailedPrecondition, "the content stream has ended; open a new connection for another"))
+			return
+		}
+		sock.startStream()
+	}
+
+	select {
+	case sock.chunks <- chunk:
+		if chunk.IsFinal {
+			close(sock.chunks)
+			sock.chunks = nil
+		}
+	case <-sock.streamDone:
+		// The stream failed and has sent its error; the chunk is dropped
+		sock.endStream()
+	case <-ctx.Done():
+	}
+}
+
+// startStream runs TranslateStream through the stream interceptors, fed by
+// sock.chunks and sending translated chunks as "chunk" events.
+func (sock *jobSocket) startStream() {
+	s := sock.server
+	sock.streamStarted = true
+	sock.chunks = make(chan *nanabushv1.TranslateChunk)
+	sock.streamDone = make(chan struct{})
+
+	ctx, headers := s.callContext(sock.request, translateStreamMethod)
+	stream := &socketStream{ctx: ctx, headers: headers, sock: sock, chunks: sock.chunks}
+	handler := s.chainStream(translateStreamMethod, translateStreamDesc())
+	done := sock.streamDone
+	go func() {
+		defer close(done)
+		if err := handler(s.translation, stream); err != nil {
+			s.logger.WithError(err).WithFields(logrus.Fields{
+				"job_id": sock.job.ID,
+			}).Debug("WebSocket content stream failed")
+			sock.sendError(err)
+		}
+	}()
+}
+
+// translateStreamDesc returns the TranslateStream method's description.
+func translateStreamDesc() *grpc.StreamDesc {
+	desc := &nanabushv1.TranslationService_ServiceDesc
+	for i := range desc.Streams {
+		if "/"+desc.ServiceName+"/"+desc.Streams[i].StreamName == translateStreamMethod {
+			return &desc.Streams[i]
+		}
+	}
+	panic("TranslateStream missing from " + desc.ServiceName)
+}
+
+// socketStream is a grpc.ServerStream over a job WebSocket: chunks are
+// received from the client's "chunk" messages and sent as "chunk" events.
+type socketStream struct {
+	ctx     context.Context
+	headers *headerStream
+	sock    *jobSocket
+	chunks  <-chan *nanabushv1.TranslateChunk
+}
+
+func (s *socketStream) Context() context.Context { return s.ctx }
+
+func (s *socketStream) SetHeader(md metadata.MD) error { return s.headers.SetHeader(md) }
+
+func (s *socketStream) SendHeader(md metadata.MD) error { return s.headers.SetHeader(md) }
+
+func (s *socketStream) SetTrailer(metadata.MD) {}
+
+// SendMsg sends m as a "chunk" event.
+func (s *socketStream) SendMsg(m any) error {
+	data, err := restJSON.Marshal(m.(proto.Message))
+	if err != nil {
+		return status.Errorf(codes.Internal, "failed to encode chunk: %v", err)
+	}
+	if err := websocket.JSON.Send(s.sock.conn, wsEvent{Event: "chunk", Data: json.RawMessage(data)}); err != nil {
+		return status.Errorf(codes.Unavailable, "failed to send chunk: %v", err)
+	}
+	return nil
+}
+
+// RecvMsg receives the client's next chunk, or io.EOF after the final one.
+func (s *socketStream) RecvMsg(m any) error {
+	select {
+	case chunk, ok := <-s.chunks:
+		if !ok {
+			return io.EOF
+		}
+		proto.Merge(m.(proto.Message), chunk)
+		return nil
+	case <-s.ctx.Done():
+		return status.FromContextError(s.ctx.Err()).Err()
+	}
+}
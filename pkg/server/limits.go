@@ -0,0 +1,198 @@
+package server
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// httpRequestsRejected counts HTTP requests refused by the request limits.
+var httpRequestsRejected = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "iskoces_http_requests_rejected_total",
+		Help: "HTTP requests rejected by a rate limit (ip_rate_limit, key_rate_limit) or for their size (body_too_large)",
+	},
+	[]string{"reason"},
+)
+
+// unlimitedPaths are exempt from the rate limits, so probes and scrapes
+// keep working while a caller is being throttled.
+var unlimitedPaths = map[string]bool{
+	"/health":  true,
+	"/livez":   true,
+	"/readyz":  true,
+	"/metrics": true,
+	"/slo":     true,
+}
+
+// Limits bound what HTTP callers may send.
+type Limits struct {
+	// MaxBodyBytes bounds every request body (default: CallOptions'
+	// MaxBodyBytes). Larger bodies get 413 Request Entity Too Large.
+	MaxBodyBytes int64
+	// IPRequestsPerMinute and KeyRequestsPerMinute are the requests a
+	// client IP, and an authenticated caller (by API key or token
+	// subject), may make per minute, in bursts of up to a minute's worth
+	// (0 = unlimited). Excess requests get 429 Too Many Requests with
+	// Retry-After.
+	IPRequestsPerMinute  int
+	KeyRequestsPerMinute int
+	// TrustForwardedFor takes the client IP from the last X-Forwarded-For
+	// address, set by the proxy in front of the server. Only enable it
+	// behind a proxy that sets the header, or callers can pick their IP.
+	TrustForwardedFor bool
+}
+
+// SetLimits sets the request body limit and rate limits. It must be called
+// before Start.
+func (s *HTTPServer) SetLimits(l Limits) {
+	s.limits = l
+	s.ipLimiter = newRateLimiter(l.IPRequestsPerMinute)
+	s.keyLimiter = newRateLimiter(l.KeyRequestsPerMinute)
+}
+
+// withLimits applies the body limit to every request and the per-IP rate
+// limit to all but unlimitedPaths. The per-key limit is applied once the
+// caller is authenticated (see authenticated).
+func (s *HTTPServer) withLimits(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !unlimitedPaths[r.URL.Path] && !s.allowRate(w, r, s.ipLimiter, s.clientIP(r), "ip_rate_limit") {
+			return
+		}
+		limit := s.maxBodyBytes()
+		if r.ContentLength > limit {
+			writeBodyTooLarge(w, limit)
+			return
+		}
+		if r.Body != nil && r.Body != http.NoBody {
+			r.Body = http.MaxBytesReader(w, r.Body, limit)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// allowRate charges a request to key's bucket of limiter. If it is empty
+// it writes 429 Too Many Requests and returns false.
+func (s *HTTPServer) allowRate(w http.ResponseWriter, r *http.Request, limiter *rateLimiter, key, reason string) bool {
+	wait, ok := limiter.allow(key, time.Now())
+	if ok {
+		return true
+	}
+	httpRequestsRejected.WithLabelValues(reason).Inc()
+	s.logger.WithFields(logrus.Fields{
+		"path":   r.URL.Path,
+		"reason": reason,
+		"caller": key,
+	}).Debug("HTTP request rate limited")
+
+	st := status.Newf(codes.ResourceExhausted, "too many requests from %s: at most %d per minute", key, limiter.perMinute)
+	if detailed, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(wait)}); err == nil {
+		st = detailed
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	writeStatusError(w, st.Err())
+	return false
+}
+
+// clientIP returns the address a request came from.
+func (s *HTTPServer) clientIP(r *http.Request) string {
+	if s.limits.TrustForwardedFor {
+		hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+		if last := strings.TrimSpace(hops[len(hops)-1]); last != "" {
+			return last
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// writeBodyTooLarge writes 413 Request Entity Too Large for a body over
+// limit bytes.
+func writeBodyTooLarge(w http.ResponseWriter, limit int64) {
+	httpRequestsRejected.WithLabelValues("body_too_large").Inc()
+	st := status.Newf(codes.InvalidArgument, "request body exceeds %d bytes", limit)
+	writeProtoJSON(w, http.StatusRequestEntityTooLarge, st.Proto())
+}
+
+// rateLimiter is a token bucket per key, holding a minute's worth of
+// requests. A nil rateLimiter allows everything.
+type rateLimiter struct {
+	perMinute int
+
+	mu      sync.Mutex
+	buckets map[string]*rateBucket
+	sweptAt time.Time
+}
+
+// rateBucket is one key's requests available.
+type rateBucket struct {
+	tokens     float64
+	refilledAt time.Time
+}
+
+// newRateLimiter returns a limiter of perMinute requests, or nil if
+// perMinute isn't positive.
+func newRateLimiter(perMinute int) *rateLimiter {
+	if perMinute <= 0 {
+		return nil
+	}
+	return &rateLimiter{perMinute: perMinute, buckets: make(map[string]*rateBucket)}
+}
+
+// allow charges a request to key, or returns how long until it could be
+// made.
+func (l *rateLimiter) allow(key string, now time.Time) (time.Duration, bool) {
+	if l == nil {
+		return 0, true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Buckets untouched for a minute are full again, the same as new ones
+	if now.Sub(l.sweptAt) >= time.Minute {
+		for k, b := range l.buckets {
+			if now.Sub(b.refilledAt) >= time.Minute {
+				delete(l.buckets, k)
+			}
+		}
+		l.sweptAt = now
+	}
+
+	capacity := float64(l.perMinute)
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &rateBucket{tokens: capacity, refilledAt: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(capacity, b.tokens+now.Sub(b.refilledAt).Seconds()*capacity/60)
+	b.refilledAt = now
+	if b.tokens < 1 {
+		return time.Duration((1 - b.tokens) / capacity * float64(time.Minute)), false
+	}
+	b.tokens--
+	return 0, true
+}
+
+// limitKey charges an authenticated request to its caller's rate limit,
+// writing 429 and returning false if it is used up.
+func (s *HTTPServer) limitKey(w http.ResponseWriter, r *http.Request, p *Principal) bool {
+	if p == nil {
+		return true
+	}
+	return s.allowRate(w, r, s.keyLimiter, p.Name, "key_rate_limit")
+}
@@ -0,0 +1,690 @@
+package server
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+)
+
+// renderMarkdown renders translated markdown as HTML for the result
+// endpoint. It covers the CommonMark blocks documents use (headings,
+// paragraphs, lists, block quotes, code, thematic breaks) and GitHub
+// tables, with emphasis, code spans, links and images inline. Raw HTML is
+// escaped rather than passed through, and links and images are limited to
+// http(s), mailto and relative URLs, so a rendered result can't run
+// scripts.
+func renderMarkdown(src string) string {
+	var b strings.Builder
+	renderBlocks(&b, markdownLines(src), false)
+	return b.String()
+}
+
+var (
+	atxHeadingPattern    = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))??(?:[ \t]+#+)?[ \t]*$`)
+	fenceOpenPattern     = regexp.MustCompile("^( {0,3})(`{3,}|~{3,})(.*)$")
+	thematicBreakPattern = regexp.MustCompile(`^ {0,3}(?:(?:\*[ \t]*){3,}|(?:-[ \t]*){3,}|(?:_[ \t]*){3,})$`)
+	tableDelimiterRow    = regexp.MustCompile(`^ {0,3}\|?[ \t]*:?-+:?[ \t]*(?:\|[ \t]*:?-+:?[ \t]*)*\|?[ \t]*$`)
+)
+
+// markdownLines splits markdown into lines, expanding tabs in indentation.
+func markdownLines(src string) []string {
+	src = strings.ReplaceAll(src, "\r\n", "\n")
+	lines := strings.Split(strings.TrimRight(src, "\n"), "\n")
+	for i, line := range lines {
+		rest := strings.TrimLeft(line, " \t")
+		if indent := line[:len(line)-len(rest)]; strings.Contains(indent, "\t") {
+			lines[i] = strings.ReplaceAll(indent, "\t", "    ") + rest
+		}
+	}
+	return lines
+}
+
+// indentOf returns a line's leading spaces.
+func indentOf(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+// dedent removes up to n leading spaces.
+func dedent(line string, n int) string {
+	return line[min(n, indentOf(line)):]
+}
+
+// renderBlocks renders block-level markdown. Paragraphs of tight list
+// items are written without <p>.
+func renderBlocks(b *strings.Builder, lines []string, tight bool) {
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+			i++
+
+		case indentOf(line) >= 4:
+			// Indented code, up to the next line indented less
+			j := i
+			for j < len(lines) && (strings.TrimSpace(lines[j]) == "" || indentOf(lines[j]) >= 4) {
+				j++
+			}
+			end := j
+			for end > i && strings.TrimSpace(lines[end-1]) == "" {
+				end--
+			}
+			var code []string
+			for _, l := range lines[i:end] {
+				code = append(code, dedent(l, 4))
+			}
+			writeCodeBlock(b, "", code)
+			i = j
+
+		case fenceOpenPattern.MatchString(line):
+			m := fenceOpenPattern.FindStringSubmatch(line)
+			indent, fence, info := len(m[1]), m[2], strings.TrimSpace(m[3])
+			if fence[0] == '`' && strings.Contains(info, "`") {
+				i = renderParagraph(b, lines, i, tight)
+				break
+			}
+			var code []string
+			j := i + 1
+			for ; j < len(lines); j++ {
+				if closing := strings.TrimSpace(lines[j]); indentOf(lines[j]) < 4 && len(closing) >= len(fence) && strings.Trim(closing, fence[:1]) == "" {
+					j++
+					break
+				}
+				code = append(code, dedent(lines[j], indent))
+			}
+			language, _, _ := strings.Cut(info, " ")
+			writeCodeBlock(b, language, code)
+			i = j
+
+		case atxHeadingPattern.MatchString(line):
+			m := atxHeadingPattern.FindStringSubmatch(line)
+			writeHeading(b, len(m[1]), m[2])
+			i++
+
+		case thematicBreakPattern.MatchString(line):
+			b.WriteString("<hr>\n")
+			i++
+
+		case strings.HasPrefix(trimmed, ">") && indentOf(line) < 4:
+			// Block quote, with lazy continuation lines
+			var quoted []string
+			j := i
+			for ; j < len(lines); j++ {
+				l := strings.TrimLeft(lines[j], " ")
+				if rest, ok := strings.CutPrefix(l, ">"); ok && indentOf(lines[j]) < 4 {
+					quoted = append(quoted, strings.TrimPrefix(rest, " "))
+					continue
+				}
+				if j == i || strings.TrimSpace(l) == "" || startsBlock(lines[j]) {
+					break
+				}
+				quoted = append(quoted, lines[j])
+			}
+			b.WriteString("<blockquote>\n")
+			renderBlocks(b, quoted, false)
+			b.WriteString("</blockquote>\n")
+			i = j
+
+		case isListItem(line):
+			i = renderList(b, lines, i)
+
+		case i+1 < len(lines) && strings.Contains(line, "|") && tableDelimiterRow.MatchString(lines[i+1]) && strings.Contains(lines[i+1], "|"):
+			i = renderTable(b, lines, i)
+
+		default:
+			i = renderParagraph(b, lines, i, tight)
+		}
+	}
+}
+
+// startsBlock reports whether a line starts a block that interrupts a
+// paragraph.
+func startsBlock(line string) bool {
+	if indentOf(line) >= 4 {
+		return false
+	}
+	trimmed := strings.TrimSpace(line)
+	return fenceOpenPattern.MatchString(line) || atxHeadingPattern.MatchString(line) ||
+		thematicBreakPattern.MatchString(line) || strings.HasPrefix(trimmed, ">") || isListItem(line)
+}
+
+// renderParagraph renders the paragraph starting at lines[i] (a setext
+// heading if underlined) and returns the index of the line after it.
+func renderParagraph(b *strings.Builder, lines []string, i int, tight bool) int {
+	var text []string
+	j := i
+	for ; j < len(lines); j++ {
+		line := lines[j]
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			break
+		}
+		if j > i && indentOf(line) < 4 {
+			if underline := strings.Trim(trimmed, "="); underline == "" {
+				writeHeading(b, 1, strings.Join(text, "\n"))
+				return j + 1
+			}
+			if underline := strings.Trim(trimmed, "-"); underline == "" && len(trimmed) >= 2 {
+				writeHeading(b, 2, strings.Join(text, "\n"))
+				return j + 1
+			}
+			if startsBlock(line) {
+				break
+			}
+		}
+		// Two trailing spaces are a hard line break
+		if j+1 < len(lines) && strings.HasSuffix(line, "  ") {
+			trimmed += "\\"
+		}
+		text = append(text, trimmed)
+	}
+
+	if !tight {
+		b.WriteString("<p>")
+	}
+	renderInline(b, strings.Join(text, "\n"))
+	if !tight {
+		b.WriteString("</p>")
+	}
+	b.WriteString("\n")
+	return j
+}
+
+// writeHeading writes a heading of the given level.
+func writeHeading(b *strings.Builder, level int, text string) {
+	fmt.Fprintf(b, "<h%d>", level)
+	renderInline(b, strings.TrimSpace(text))
+	fmt.Fprintf(b, "</h%d>\n", level)
+}
+
+// writeCodeBlock writes a code block, marking its language as the
+// CommonMark spec suggests.
+func writeCodeBlock(b *strings.Builder, language string, code []string) {
+	b.WriteString("<pre><code")
+	if language != "" {
+		b.WriteString(` class="language-` + html.EscapeString(language) + `"`)
+	}
+	b.WriteString(">")
+	for _, line := range code {
+		b.WriteString(html.EscapeString(line))
+		b.WriteString("\n")
+	}
+	b.WriteString("</code></pre>\n")
+}
+
+// listItem is a list item's marker.
+type listItem struct {
+	ordered   bool
+	delimiter byte // Bullet character, or . or ) after the number
+	start     string
+	width     int // Columns before the content
+	content   string
+}
+
+// parseListItem parses the marker of a list item line.
+func parseListItem(line string) (listItem, bool) {
+	indent := indentOf(line)
+	if indent >= 4 {
+		return listItem{}, false
+	}
+	rest := line[indent:]
+	item := listItem{}
+	switch {
+	case rest == "":
+		return listItem{}, false
+	case strings.IndexByte("-*+", rest[0]) >= 0:
+		item.delimiter = rest[0]
+		rest = rest[1:]
+		item.width = indent + 1
+	default:
+		digits := len(rest) - len(strings.TrimLeft(rest, "0123456789"))
+		if digits == 0 || digits > 9 || digits == len(rest) || (rest[digits] != '.' && rest[digits] != ')') {
+			return listItem{}, false
+		}
+		item.ordered = true
+		item.start = strings.TrimLeft(rest[:digits], "0")
+		item.delimiter = rest[digits]
+		rest = rest[digits+1:]
+		item.width = indent + digits + 1
+	}
+	if rest != "" && rest[0] != ' ' {
+		return listItem{}, false
+	}
+	spaces := indentOf(rest)
+	if spaces > 4 || strings.TrimSpace(rest) == "" {
+		spaces = min(spaces, 1)
+	}
+	item.width += spaces
+	item.content = rest[spaces:]
+	return item, true
+}
+
+// isListItem reports whether a line starts a list item.
+func isListItem(line string) bool {
+	_, ok := parseListItem(line)
+	return ok
+}
+
+// renderList renders the list starting at lines[i] and returns the index
+// of the line after it. A list with blank lines between or inside its
+// items is loose: its paragraphs are wrapped in <p>.
+func renderList(b *strings.Builder, lines []string, i int) int {
+	first, _ := parseListItem(lines[i])
+	var items [][]string
+	loose := false
+	j := i
+	for j < len(lines) {
+		marker, ok := parseListItem(lines[j])
+		if !ok || marker.ordered != first.ordered || marker.delimiter != first.delimiter {
+			break
+		}
+		if len(items) > 0 && strings.TrimSpace(lines[j-1]) == "" {
+			loose = true
+		}
+		item := []string{marker.content}
+		blank := false
+		for j++; j < len(lines); j++ {
+			line := lines[j]
+			switch {
+			case strings.TrimSpace(line) == "":
+				blank = true
+				item = append(item, "")
+				continue
+			case indentOf(line) >= marker.width:
+				if blank {
+					loose = true
+				}
+				item = append(item, dedent(line, marker.width))
+				blank = false
+				continue
+			case !blank && !startsBlock(line):
+				// Lazy paragraph continuation
+				item = append(item, line)
+				continue
+			}
+			break
+		}
+		for len(item) > 0 && strings.TrimSpace(item[len(item)-1]) == "" {
+			item = item[:len(item)-1]
+		}
+		items = append(items, item)
+		if blank && (j >= len(lines) || !isListItem(lines[j])) {
+			break
+		}
+	}
+
+	tag := "ul"
+	if first.ordered {
+		tag = "ol"
+		if first.start != "1" {
+			start := first.start
+			if start == "" {
+				start = "0"
+			}
+			fmt.Fprintf(b, "<ol start=\"%s\">\n", start)
+		} else {
+			b.WriteString("<ol>\n")
+		}
+	} else {
+		b.WriteString("<ul>\n")
+	}
+	for _, item := range items {
+		b.WriteString("<li>")
+		var inner strings.Builder
+		renderBlocks(&inner, item, !loose)
+		b.WriteString(strings.TrimSuffix(inner.String(), "\n"))
+		b.WriteString("</li>\n")
+	}
+	fmt.Fprintf(b, "</%s>\n", tag)
+	return j
+}
+
+// renderTable renders the GitHub table starting at lines[i] and returns
+// the index of the line after it.
+func renderTable(b *strings.Builder, lines []string, i int) int {
+	header := tableCells(lines[i])
+	var aligns []string
+	for _, cell := range tableCells(lines[i+1]) {
+		switch {
+		case strings.HasPrefix(cell, ":") && strings.HasSuffix(cell, ":"):
+			aligns = append(aligns, "center")
+		case strings.HasSuffix(cell, ":"):
+			aligns = append(aligns, "right")
+		case strings.HasPrefix(cell, ":"):
+			aligns = append(aligns, "left")
+		default:
+			aligns = append(aligns, "")
+		}
+	}
+	writeRow := func(cells []string, tag string) {
+		b.WriteString("<tr>")
+		for k, align := range aligns {
+			if align != "" {
+				fmt.Fprintf(b, `<%s style="text-align: %s">`, tag, align)
+			} else {
+				fmt.Fprintf(b, "<%s>", tag)
+			}
+			if k < len(cells) {
+				renderInline(b, cells[k])
+			}
+			fmt.Fprintf(b, "</%s>", tag)
+		}
+		b.WriteString("</tr>\n")
+	}
+
+	b.WriteString("<table>\n<thead>\n")
+	writeRow(header, "th")
+	b.WriteString("</thead>\n")
+	j := i + 2
+	if j < len(lines) && strings.TrimSpace(lines[j]) != "" && !startsBlock(lines[j]) {
+		b.WriteString("<tbody>\n")
+		for ; j < len(lines) && strings.TrimSpace(lines[j]) != "" && !startsBlock(lines[j]); j++ {
+			writeRow(tableCells(lines[j]), "td")
+		}
+		b.WriteString("</tbody>\n")
+	}
+	b.WriteString("</table>\n")
+	return j
+}
+
+// tableCells splits a table row into its cells; \| is a literal pipe.
+func tableCells(row string) []string {
+	row = strings.TrimSpace(row)
+	row = strings.TrimPrefix(row, "|")
+	if strings.HasSuffix(row, "|") && !strings.HasSuffix(row, "\\|") {
+		row = row[:len(row)-1]
+	}
+	var cells []string
+	var cell strings.Builder
+	for k := 0; k < len(row); k++ {
+		switch {
+		case row[k] == '\\' && k+1 < len(row) && row[k+1] == '|':
+			cell.WriteByte('|')
+			k++
+		case row[k] == '|':
+			cells = append(cells, strings.TrimSpace(cell.String()))
+			cell.Reset()
+		default:
+			cell.WriteByte(row[k])
+		}
+	}
+	return append(cells, strings.TrimSpace(cell.String()))
+}
+
+// inlineSpecial are the characters that may start inline markup.
+const inlineSpecial = "\\`![<*_~"
+
+// renderInline renders inline markdown: backslash escapes, hard line
+// breaks, code spans, emphasis, strikethrough, links, images and
+// autolinks. Everything else is escaped text.
+func renderInline(b *strings.Builder, s string) {
+	for i := 0; i < len(s); {
+		switch c := s[i]; c {
+		case '\\':
+			if i+1 < len(s) && s[i+1] == '\n' {
+				b.WriteString("<br>\n")
+				i += 2
+				continue
+			}
+			if i+1 < len(s) && strings.IndexByte("!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~", s[i+1]) >= 0 {
+				b.WriteString(html.EscapeString(s[i+1 : i+2]))
+				i += 2
+				continue
+			}
+
+		case '`':
+			n := runLength(s, i, '`')
+			if end := codeSpanEnd(s, i+n, n); end >= 0 {
+				code := strings.ReplaceAll(s[i+n:end], "\n", " ")
+				if len(code) >= 2 && code[0] == ' ' && code[len(code)-1] == ' ' && strings.TrimSpace(code) != "" {
+					code = code[1 : len(code)-1]
+				}
+				b.WriteString("<code>" + html.EscapeString(code) + "</code>")
+				i = end + n
+				continue
+			}
+			b.WriteString(s[i : i+n])
+			i += n
+			continue
+
+		case '!':
+			if text, dest, title, end, ok := parseLink(s, i+1); ok {
+				if src := safeURL(dest, true); src != "" {
+					b.WriteString(`<img src="` + html.EscapeString(src) + `" alt="` + html.EscapeString(text) + `"`)
+					if title != "" {
+						b.WriteString(` title="` + html.EscapeString(title) + `"`)
+					}
+					b.WriteString(">")
+				} else {
+					b.WriteString(html.EscapeString(text))
+				}
+				i = end
+				continue
+			}
+
+		case '[':
+			if text, dest, title, end, ok := parseLink(s, i); ok {
+				if href := safeURL(dest, false); href != "" {
+					b.WriteString(`<a href="` + html.EscapeString(href) + `"`)
+					if title != "" {
+						b.WriteString(` title="` + html.EscapeString(title) + `"`)
+					}
+					b.WriteString(">")
+					renderInline(b, text)
+					b.WriteString("</a>")
+				} else {
+					renderInline(b, text)
+				}
+				i = end
+				continue
+			}
+
+		case '<':
+			if end := strings.IndexByte(s[i:], '>'); end > 1 {
+				target := s[i+1 : i+end]
+				href := ""
+				if !strings.ContainsAny(target, " \n<") {
+					if safeURL(target, false) == target && strings.Contains(target, ":") {
+						href = target
+					} else if at := strings.IndexByte(target, '@'); at > 0 && at < len(target)-1 && !strings.Contains(target, ":") {
+						href = "mailto:" + target
+					}
+				}
+				if href != "" {
+					b.WriteString(`<a href="` + html.EscapeString(href) + `">` + html.EscapeString(target) + "</a>")
+					i += end + 1
+					continue
+				}
+			}
+
+		case '*', '_', '~':
+			n := runLength(s, i, c)
+			if width, end, ok := emphasisEnd(s, i, n, c); ok {
+				tag := "em"
+				if c == '~' {
+					tag = "del"
+				} else if width == 2 {
+					tag = "strong"
+				}
+				b.WriteString("<" + tag + ">")
+				renderInline(b, s[i+width:end])
+				b.WriteString("</" + tag + ">")
+				i = end + width
+				continue
+			}
+			b.WriteString(s[i : i+n])
+			i += n
+			continue
+		}
+
+		// Plain text up to the next character that may start markup
+		next := strings.IndexAny(s[i+1:], inlineSpecial)
+		if next < 0 {
+			next = len(s)
+		} else {
+			next += i + 1
+		}
+		b.WriteString(html.EscapeString(s[i:next]))
+		i = next
+	}
+}
+
+// runLength returns how many times c repeats from s[i].
+func runLength(s string, i int, c byte) int {
+	n := 0
+	for i+n < len(s) && s[i+n] == c {
+		n++
+	}
+	return n
+}
+
+// codeSpanEnd returns where the backtick run of length n closing a code
+// span starting at from is, or -1.
+func codeSpanEnd(s string, from, n int) int {
+	for i := from; i < len(s); {
+		if s[i] != '`' {
+			i++
+			continue
+		}
+		run := runLength(s, i, '`')
+		if run == n {
+			return i
+		}
+		i += run
+	}
+	return -1
+}
+
+// emphasisEnd finds the closing delimiter of the emphasis opened by the
+// run of n c's at s[i]: its width (1 for emphasis, 2 for strong and
+// strikethrough) and position. Delimiters must hug the text, and
+// underscores inside words don't count.
+func emphasisEnd(s string, i, n int, c byte) (width, end int, ok bool) {
+	width = min(n, 2)
+	if c == '~' && n != 2 {
+		return 0, 0, false
+	}
+	isSpace := func(b byte) bool { return b == ' ' || b == '\n' || b == '\t' }
+	isWord := func(b byte) bool {
+		return b >= '0' && b <= '9' || b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= 0x80
+	}
+	if i+n >= len(s) || isSpace(s[i+n]) || (c == '_' && i > 0 && isWord(s[i-1])) {
+		return 0, 0, false
+	}
+	for p := i + width; p < len(s); {
+		q := strings.IndexByte(s[p:], c)
+		if q < 0 {
+			break
+		}
+		p += q
+		run := runLength(s, p, c)
+		closer := p + run - width
+		// A run of two closes strong emphasis, not emphasis
+		if run >= width && (width == 2 || run != 2) && closer > i+width && !isSpace(s[p-1]) && s[p-1] != '\\' &&
+			(c != '_' || p+run >= len(s) || !isWord(s[p+run])) && (c != '~' || run == 2) {
+			return width, closer, true
+		}
+		p += run
+	}
+	return 0, 0, false
+}
+
+// parseLink parses an inline link [text](destination "title") starting at
+// s[i] and returns the index after it.
+func parseLink(s string, i int) (text, dest, title string, end int, ok bool) {
+	if i >= len(s) || s[i] != '[' {
+		return "", "", "", 0, false
+	}
+	depth := 0
+	k := i
+	for ; k < len(s); k++ {
+		switch s[k] {
+		case '\\':
+			k++
+			continue
+		case '`':
+			if close := codeSpanEnd(s, k+runLength(s, k, '`'), runLength(s, k, '`')); close >= 0 {
+				k = close + runLength(s, k, '`') - 1
+			}
+			continue
+		case '[':
+			depth++
+		case ']':
+			depth--
+		}
+		if depth == 0 {
+			break
+		}
+	}
+	if k+1 >= len(s) || s[k+1] != '(' {
+		return "", "", "", 0, false
+	}
+	text = s[i+1 : k]
+
+	p := k + 2
+	for p < len(s) && (s[p] == ' ' || s[p] == '\n') {
+		p++
+	}
+	if p < len(s) && s[p] == '<' {
+		close := strings.IndexByte(s[p:], '>')
+		if close < 0 {
+			return "", "", "", 0, false
+		}
+		dest = s[p+1 : p+close]
+		p += close + 1
+	} else {
+		start, parens := p, 0
+		for ; p < len(s) && s[p] != ' ' && s[p] != '\n'; p++ {
+			if s[p] == '(' {
+				parens++
+			} else if s[p] == ')' {
+				if parens == 0 {
+					break
+				}
+				parens--
+			}
+		}
+		dest = s[start:p]
+	}
+	for p < len(s) && (s[p] == ' ' || s[p] == '\n') {
+		p++
+	}
+	if p < len(s) && (s[p] == '"' || s[p] == '\'') {
+		close := strings.IndexByte(s[p+1:], s[p])
+		if close < 0 {
+			return "", "", "", 0, false
+		}
+		title = s[p+1 : p+1+close]
+		p += close + 2
+		for p < len(s) && (s[p] == ' ' || s[p] == '\n') {
+			p++
+		}
+	}
+	if p >= len(s) || s[p] != ')' {
+		return "", "", "", 0, false
+	}
+	return text, dest, title, p + 1, true
+}
+
+// safeURL returns a link or image destination if its scheme is safe to
+// render (http, https, mailto for links, or none), otherwise "".
+func safeURL(dest string, image bool) string {
+	dest = strings.TrimSpace(dest)
+	if strings.ContainsFunc(dest, func(r rune) bool { return r < ' ' || r == 0x7f }) {
+		return ""
+	}
+	colon := strings.IndexByte(dest, ':')
+	if colon < 0 || strings.ContainsAny(dest[:colon], "/?#") {
+		return dest
+	}
+	switch strings.ToLower(dest[:colon]) {
+	case "http", "https":
+		return dest
+	case "mailto":
+		if !image {
+			return dest
+		}
+	}
+	return ""
+}
@@ -0,0 +1,220 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// handleOpenAPI serves an OpenAPI 3 document describing the methods of the
+// gRPC services exposed as JSON.
+func (s *HTTPServer) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	doc, err := openAPIDocument(s.gateways)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to build OpenAPI document")
+		http.Error(w, fmt.Sprintf("Failed to build OpenAPI document: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(doc)
+}
+
+// openAPIDocument describes the gateway's services from their registered
+// protobuf descriptors: one POST path per method, and a schema per message
+// in its protobuf JSON form (proto field names; 64-bit integers as
+// strings).
+func openAPIDocument(gateways []gatewayService) (map[string]any, error) {
+	b := &schemaBuilder{schemas: make(map[string]any)}
+	errorSchema := b.ref((&spb.Status{}).ProtoReflect().Descriptor())
+	paths := make(map[string]any)
+	var tags []any
+
+	for _, g := range gateways {
+		found, err := protoregistry.GlobalFiles.FindDescriptorByName(protoreflect.FullName(g.desc.ServiceName))
+		if err != nil {
+			return nil, fmt.Errorf("service %s: %w", g.desc.ServiceName, err)
+		}
+		sd, ok := found.(protoreflect.ServiceDescriptor)
+		if !ok {
+			return nil, fmt.Errorf("%s is not a service", g.desc.ServiceName)
+		}
+		tags = append(tags, map[string]any{"name": g.desc.ServiceName})
+
+		methods := sd.Methods()
+		for i := 0; i < methods.Len(); i++ {
+			m := methods.Get(i)
+			paths["/"+g.desc.ServiceName+"/"+string(m.Name())] = map[string]any{
+				"post": map[string]any{
+					"operationId": string(m.FullName()),
+					"tags":        []string{g.desc.ServiceName},
+					"requestBody": map[string]any{
+						"required": true,
+						"content":  b.content(m.Input(), m.IsStreamingClient()),
+					},
+					"responses": map[string]any{
+						"200": map[string]any{
+							"description": "Response" + streamNote(m.IsStreamingServer()),
+							"content":     b.content(m.Output(), m.IsStreamingServer()),
+						},
+						"default": map[string]any{
+							"description": "Error: a google.rpc.Status with the HTTP status matching its code",
+							"content": map[string]any{
+								"application/json": map[string]any{"schema": errorSchema},
+							},
+						},
+					},
+				},
+			}
+		}
+	}
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":       "Iskoces",
+			"version":     "v1",
+			"description": "gRPC methods over HTTP: POST the request message as protobuf JSON to /<package>.<Service>/<Method>. Streamed messages are newline-delimited JSON; an error after a stream has started is sent as a final {\"error\": Status} line.",
+		},
+		"tags":       tags,
+		"paths":      paths,
+		"components": map[string]any{"schemas": b.schemas},
+	}, nil
+}
+
+// streamNote describes a streamed body in an operation.
+func streamNote(streaming bool) string {
+	if streaming {
+		return " stream: one JSON message per line"
+	}
+	return ""
+}
+
+// schemaBuilder collects the schemas of the messages an OpenAPI document
+// refers to.
+type schemaBuilder struct {
+	schemas map[string]any
+}
+
+// content returns a body's media type and schema.
+func (b *schemaBuilder) content(md protoreflect.MessageDescriptor, streaming bool) map[string]any {
+	mediaType := "application/json"
+	if streaming {
+		mediaType = "application/x-ndjson"
+	}
+	return map[string]any{mediaType: map[string]any{"schema": b.ref(md)}}
+}
+
+// ref returns the schema of a message field or body: well-known types
+// inline, other messages as a reference to their component schema.
+func (b *schemaBuilder) ref(md protoreflect.MessageDescriptor) map[string]any {
+	if schema, ok := wellKnownSchema(md); ok {
+		return schema
+	}
+	name := string(md.FullName())
+	if _, ok := b.schemas[name]; !ok {
+		b.schemas[name] = nil // Recursive messages refer back to it
+		b.schemas[name] = b.message(md)
+	}
+	return map[string]any{"$ref": "#/components/schemas/" + name}
+}
+
+// message returns the schema of a message's fields.
+func (b *schemaBuilder) message(md protoreflect.MessageDescriptor) map[string]any {
+	properties := make(map[string]any)
+	fields := md.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		f := fields.Get(i)
+		properties[string(f.Name())] = b.field(f)
+	}
+	return map[string]any{"type": "object", "properties": properties}
+}
+
+// field returns the schema of a field, including repeated and map fields.
+func (b *schemaBuilder) field(f protoreflect.FieldDescriptor) map[string]any {
+	switch {
+	case f.IsMap():
+		return map[string]any{"type": "object", "additionalProperties": b.value(f.MapValue())}
+	case f.IsList():
+		return map[string]any{"type": "array", "items": b.value(f)}
+	default:
+		return b.value(f)
+	}
+}
+
+// value returns the schema of one value of a field.
+func (b *schemaBuilder) value(f protoreflect.FieldDescriptor) map[string]any {
+	switch f.Kind() {
+	case protoreflect.BoolKind:
+		return map[string]any{"type": "boolean"}
+	case protoreflect.StringKind:
+		return map[string]any{"type": "string"}
+	case protoreflect.BytesKind:
+		return map[string]any{"type": "string", "format": "byte"}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return map[string]any{"type": "integer", "format": "int32"}
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return map[string]any{"type": "integer", "format": "int64", "minimum": 0}
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return map[string]any{"type": "string", "format": "int64"}
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return map[string]any{"type": "string", "format": "uint64"}
+	case protoreflect.FloatKind:
+		return map[string]any{"type": "number", "format": "float"}
+	case protoreflect.DoubleKind:
+		return map[string]any{"type": "number", "format": "double"}
+	case protoreflect.EnumKind:
+		values := f.Enum().Values()
+		names := make([]string, 0, values.Len())
+		for i := 0; i < values.Len(); i++ {
+			names = append(names, string(values.Get(i).Name()))
+		}
+		return map[string]any{"type": "string", "enum": names}
+	default: // Message and group
+		return b.ref(f.Message())
+	}
+}
+
+// wellKnownSchema returns the schema of a well-known type with a special
+// JSON form.
+func wellKnownSchema(md protoreflect.MessageDescriptor) (map[string]any, bool) {
+	name := string(md.FullName())
+	switch name {
+	case "google.protobuf.Timestamp":
+		return map[string]any{"type": "string", "format": "date-time"}, true
+	case "google.protobuf.Duration":
+		return map[string]any{"type": "string", "example": "1.5s"}, true
+	case "google.protobuf.FieldMask":
+		return map[string]any{"type": "string"}, true
+	case "google.protobuf.Empty", "google.protobuf.Struct":
+		return map[string]any{"type": "object"}, true
+	case "google.protobuf.ListValue":
+		return map[string]any{"type": "array", "items": map[string]any{}}, true
+	case "google.protobuf.Value":
+		return map[string]any{}, true
+	case "google.protobuf.Any":
+		return map[string]any{
+			"type":                 "object",
+			"properties":           map[string]any{"@type": map[string]any{"type": "string"}},
+			"additionalProperties": true,
+		}, true
+	}
+	if strings.HasPrefix(name, "google.protobuf.") && strings.HasSuffix(name, "Value") {
+		// Wrappers are their value, or null
+		if value := md.Fields().ByName("value"); value != nil {
+			schema := (&schemaBuilder{}).value(value)
+			schema["nullable"] = true
+			return schema, true
+		}
+	}
+	return nil, false
+}
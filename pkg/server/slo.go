@@ -0,0 +1,40 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/dasmlab/iskoces/pkg/service"
+)
+
+// SetSLOs serves the status of the tracker's service level objectives at
+// /slo. It must be called before Start.
+func (s *HTTPServer) SetSLOs(t *service.SLOTracker) {
+	s.slos = t
+}
+
+// handleSLO reports whether each service level objective is being met:
+// its status (ok, warning, critical or no_data), the good calls and burn
+// rate over each window, and the error budget left.
+func (s *HTTPServer) handleSLO(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	statuses := s.slos.Status()
+	overall := service.SLOStatusOK
+	for _, st := range statuses {
+		switch {
+		case st.Status == service.SLOStatusCritical:
+			overall = service.SLOStatusCritical
+		case st.Status == service.SLOStatusWarning && overall != service.SLOStatusCritical:
+			overall = service.SLOStatusWarning
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]any{
+		"status":     overall,
+		"objectives": statuses,
+	})
+}
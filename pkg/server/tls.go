@@ -0,0 +1,177 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultCertReloadInterval is how often a CertReloader checks its files
+// for changes.
+const DefaultCertReloadInterval = time.Minute
+
+// TLSConfig locates a server's certificate and, for mutual TLS, the CA its
+// clients' certificates must be signed by.
+type TLSConfig struct {
+	CertFile string
+	KeyFile  string
+	// ClientCAFile makes clients present a certificate signed by this CA
+	// (empty = client certificates aren't asked for).
+	ClientCAFile string
+	// ClientCertOptional also accepts clients without a certificate, such
+	// as probes; a certificate that is presented must still be valid.
+	ClientCertOptional bool
+}
+
+// CertReloader serves a certificate and client CA loaded from files and
+// reloads them when the files change, so rotated certificates (e.g. renewed
+// by cert-manager) are picked up without a restart. The gRPC and HTTP
+// listeners both take their TLS configuration from one.
+type CertReloader struct {
+	config TLSConfig
+	logger *logrus.Logger
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+	contents  []byte // The files as last loaded, to notice changes
+}
+
+// NewCertReloader loads the certificate, key and client CA files.
+func NewCertReloader(config TLSConfig, logger *logrus.Logger) (*CertReloader, error) {
+	if config.CertFile == "" || config.KeyFile == "" {
+		return nil, errors.New("a certificate and key are required")
+	}
+	c := &CertReloader{config: config, logger: logger}
+	if _, err := c.reload(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// ServerConfig returns a server TLS configuration using the current
+// certificate and client CA. Each listener should get its own, as gRPC and
+// net/http add their ALPN protocols to it.
+func (c *CertReloader) ServerConfig() *tls.Config {
+	config := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			c.mu.RLock()
+			defer c.mu.RUnlock()
+			return c.cert, nil
+		},
+	}
+	if c.config.ClientCAFile != "" {
+		// Client certificates are verified in VerifyConnection rather than
+		// with ClientCAs, so the CA can be reloaded too
+		config.ClientAuth = tls.RequireAnyClientCert
+		if c.config.ClientCertOptional {
+			config.ClientAuth = tls.RequestClientCert
+		}
+		config.VerifyConnection = c.verifyClient
+	}
+	return config
+}
+
+// Run checks the files every interval (default: DefaultCertReloadInterval)
+// until ctx is done, reloading them when they change. If they fail to load,
+// e.g. while only some have been replaced, the previous certificate is kept
+// and they are tried again at the next check.
+func (c *CertReloader) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultCertReloadInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		changed, err := c.reload()
+		if err != nil {
+			c.logger.WithError(err).WithField("cert_file", c.config.CertFile).Warn("Failed to reload TLS certificate, keeping the previous one")
+		} else if changed {
+			c.logger.WithField("cert_file", c.config.CertFile).Info("Reloaded TLS certificate")
+		}
+	}
+}
+
+// reload loads the files if they changed since they were last loaded.
+func (c *CertReloader) reload() (bool, error) {
+	certPEM, err := os.ReadFile(c.config.CertFile)
+	if err != nil {
+		return false, fmt.Errorf("certificate: %w", err)
+	}
+	keyPEM, err := os.ReadFile(c.config.KeyFile)
+	if err != nil {
+		return false, fmt.Errorf("key: %w", err)
+	}
+	var caPEM []byte
+	if c.config.ClientCAFile != "" {
+		if caPEM, err = os.ReadFile(c.config.ClientCAFile); err != nil {
+			return false, fmt.Errorf("client CA: %w", err)
+		}
+	}
+	contents := bytes.Join([][]byte{certPEM, keyPEM, caPEM}, []byte{0})
+
+	c.mu.RLock()
+	unchanged := bytes.Equal(contents, c.contents)
+	c.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return false, fmt.Errorf("certificate: %w", err)
+	}
+	var clientCAs *x509.CertPool
+	if caPEM != nil {
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(caPEM) {
+			return false, fmt.Errorf("client CA: no certificates in %s", c.config.ClientCAFile)
+		}
+	}
+
+	c.mu.Lock()
+	c.cert = &cert
+	c.clientCAs = clientCAs
+	c.contents = contents
+	c.mu.Unlock()
+	return true, nil
+}
+
+// verifyClient verifies the client's certificate, if it presented one,
+// against the current client CA.
+func (c *CertReloader) verifyClient(state tls.ConnectionState) error {
+	if len(state.PeerCertificates) == 0 {
+		return nil // Only allowed with ClientCertOptional (see ClientAuth)
+	}
+	c.mu.RLock()
+	roots := c.clientCAs
+	c.mu.RUnlock()
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range state.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := state.PeerCertificates[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	if err != nil {
+		return fmt.Errorf("client certificate: %w", err)
+	}
+	return nil
+}
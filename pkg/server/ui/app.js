@@ -0,0 +1,376 @@
+// Job monitoring UI: lists jobs, follows the selected job's progress over
+// Server-Sent Events and shows its source and translation side by side.
+// Everything the server sends is inserted as text, never as HTML.
+"use strict";
+
+(function () {
+  const base = new URL("../", document.baseURI);
+  const terminal = new Set(["completed", "failed", "cancelled"]);
+  const refreshInterval = 5000;
+
+  let token = sessionStorage.getItem("iskoces-token") || "";
+  let selected = "";
+  let events = null;
+  let timer = null;
+
+  const $ = (id) => document.getElementById(id);
+
+  // apiURL returns the URL of an API path, with the token as access_token
+  // for requests that can't set headers (EventSource). The server only
+  // takes access_token on job event streams and WebSockets.
+  function apiURL(path, params, withToken) {
+    const url = new URL(path, base);
+    for (const [name, value] of Object.entries(params || {})) {
+      if (value) {
+        url.searchParams.set(name, value);
+      }
+    }
+    if (withToken && token) {
+      url.searchParams.set("access_token", token);
+    }
+    return url;
+  }
+
+  // request fetches an API path with the token in the Authorization header.
+  // Errors carry the server's message.
+  async function request(path, params, accept) {
+    const headers = { Accept: accept };
+    if (token) {
+      headers.Authorization = "Bearer " + token;
+    }
+    const resp = await fetch(apiURL(path, params), { headers: headers });
+    if (!resp.ok) {
+      let message = (await resp.text()).trim();
+      try {
+        message = JSON.parse(message).message || message;
+      } catch (e) {
+        // Plain text error
+      }
+      if (resp.status === 401) {
+        message = "Sign in with an API key or token: " + message;
+      }
+      const err = new Error(message || resp.statusText);
+      err.status = resp.status;
+      throw err;
+    }
+    return resp;
+  }
+
+  // api fetches an API path as JSON.
+  async function api(path, params) {
+    return (await request(path, params, "application/json")).json();
+  }
+
+  // download saves an API path's response as a file, named as the server
+  // suggests. Links can't carry the token in a header, so it is fetched.
+  async function download(path, params, fallbackName) {
+    const resp = await request(path, params, "*/*");
+    const disposition = resp.headers.get("Content-Disposition") || "";
+    const match = /filename="?([^";]+)"?/.exec(disposition);
+    const url = URL.createObjectURL(await resp.blob());
+    const link = element("a");
+    link.href = url;
+    link.download = match ? match[1] : fallbackName;
+    document.body.append(link);
+    link.click();
+    link.remove();
+    URL.revokeObjectURL(url);
+  }
+
+  function showError(el, err) {
+    el.textContent = err ? String(err.message || err) : "";
+    el.hidden = !err;
+  }
+
+  function element(tag, text, className) {
+    const el = document.createElement(tag);
+    if (text !== undefined) {
+      el.textContent = text;
+    }
+    if (className) {
+      el.className = className;
+    }
+    return el;
+  }
+
+  function formatTime(value) {
+    return value ? new Date(value).toLocaleString() : "";
+  }
+
+  function languages(job) {
+    return (job.source_language || "?") + " → " + (job.target_language || "?");
+  }
+
+  // Job list
+
+  async function loadJobs(append) {
+    const params = {
+      namespace: $("namespace").value.trim(),
+      status: $("status").value,
+      sort: $("sort").value,
+      page_size: "50",
+    };
+    if (append) {
+      params.page_token = $("more").dataset.token;
+    }
+    try {
+      const page = await api("api/v1/jobs", params);
+      showError($("error"), null);
+      renderJobs(page.jobs || [], append);
+      const total = page.total_size || 0;
+      $("total").textContent = total ? $("jobs").children.length + " of " + total + " jobs" : "";
+      $("more").dataset.token = page.next_page_token || "";
+      $("more").hidden = !page.next_page_token;
+    } catch (err) {
+      showError($("error"), err);
+    }
+  }
+
+  function renderJobs(jobs, append) {
+    const body = $("jobs");
+    if (!append) {
+      body.replaceChildren();
+    }
+    for (const job of jobs) {
+      const row = document.createElement("tr");
+      row.dataset.job = job.job_id;
+      row.classList.toggle("selected", job.job_id === selected);
+      row.append(element("td", job.request_id || job.job_id));
+
+      const state = element("td");
+      state.append(element("span", job.status, "status " + job.status));
+      row.append(state);
+
+      const progress = element("td");
+      const bar = element("progress");
+      bar.max = 100;
+      bar.value = job.progress_percent || 0;
+      bar.title = (job.progress_percent || 0) + "%";
+      progress.append(bar);
+      row.append(progress);
+
+      row.append(element("td", languages(job)));
+      row.append(element("td", job.namespace || ""));
+      row.append(element("td", formatTime(job.created_at)));
+      row.addEventListener("click", () => {
+        location.hash = "job=" + encodeURIComponent(job.job_id);
+      });
+      body.append(row);
+    }
+    $("empty").hidden = body.children.length > 0;
+  }
+
+  function scheduleRefresh() {
+    clearInterval(timer);
+    if ($("auto").checked) {
+      timer = setInterval(() => loadJobs(false), refreshInterval);
+    }
+  }
+
+  // Job detail
+
+  function selectFromHash() {
+    const match = /^#job=(.+)$/.exec(location.hash);
+    const id = match ? decodeURIComponent(match[1]) : "";
+    if (id !== selected) {
+      selected = id;
+      showJob(id);
+    }
+  }
+
+  async function showJob(id) {
+    if (events) {
+      events.close();
+      events = null;
+    }
+    for (const row of $("jobs").children) {
+      row.classList.toggle("selected", row.dataset.job === id);
+    }
+    $("detail").hidden = !id;
+    if (!id) {
+      return;
+    }
+    $("compare-rows").replaceChildren();
+    $("compare-note").textContent = "";
+    $("detail-links").hidden = true;
+    try {
+      const job = await api("api/v1/jobs/" + encodeURIComponent(id));
+      if (id !== selected) {
+        return;
+      }
+      renderJob(job);
+      renderFields(job);
+      await loadComparison(job);
+      if (!terminal.has(job.status)) {
+        follow(job);
+      }
+    } catch (err) {
+      $("detail-title").textContent = id;
+      showError($("detail-error"), err);
+    }
+  }
+
+  function renderJob(job) {
+    $("detail-title").textContent = job.request_id || job.job_id;
+    const state = $("detail-status");
+    state.textContent = job.status;
+    state.className = "status " + job.status;
+    let message = job.progress_message || "";
+    if (job.queue_position) {
+      message += " (position " + job.queue_position + " in the queue)";
+    }
+    $("detail-message").textContent = message;
+    $("detail-progress").value = job.progress_percent || 0;
+    showError($("detail-error"), job.error);
+  }
+
+  function renderFields(job) {
+    const fields = [
+      ["Job ID", job.job_id],
+      ["Languages", languages(job)],
+      ["Namespace", job.namespace],
+      ["Client", job.client_id],
+      ["Batch", job.batch_id],
+      ["Priority", job.priority],
+      ["Created", formatTime(job.created_at)],
+      ["Started", formatTime(job.started_at)],
+      ["Completed", formatTime(job.completed_at)],
+      ["Attempt", job.attempt],
+    ];
+    const list = $("detail-fields");
+    list.replaceChildren();
+    for (const [name, value] of fields) {
+      if (value) {
+        list.append(element("dt", name), element("dd", String(value)));
+      }
+    }
+  }
+
+  // follow updates the detail from the job's event stream until it
+  // finishes, then shows the translation.
+  function follow(job) {
+    const id = job.job_id;
+    const stream = new EventSource(apiURL("api/v1/jobs/" + encodeURIComponent(id) + "/events", {}, true));
+    events = stream;
+    stream.addEventListener("status", async (e) => {
+      if (stream !== events) {
+        return;
+      }
+      const update = JSON.parse(e.data);
+      renderJob(Object.assign({}, job, update));
+      if (!terminal.has(update.status)) {
+        return;
+      }
+      stream.close();
+      events = null;
+      try {
+        const done = await api("api/v1/jobs/" + encodeURIComponent(id));
+        if (id === selected) {
+          renderFields(done);
+          await loadComparison(done);
+        }
+      } catch (err) {
+        showError($("detail-error"), err);
+      }
+      loadJobs(false);
+    });
+    stream.onerror = () => {
+      if (stream === events && stream.readyState === EventSource.CLOSED) {
+        showError($("detail-error"), "Lost the job's event stream; reselect the job to reconnect");
+      }
+    };
+  }
+
+  async function loadComparison(job) {
+    const id = encodeURIComponent(job.job_id);
+    $("compare-source").textContent = "Source (" + (job.source_language || "?") + ")";
+    $("compare-target").textContent = "Translation (" + (job.target_language || "?") + ")";
+    let source = {};
+    let result = null;
+    let step = "source";
+    try {
+      source = await api("api/v1/jobs/" + id + "/source");
+      if (job.status === "completed") {
+        step = "translation";
+        result = await api("api/v1/jobs/" + id + "/result", { format: "json" });
+        $("download-md").onclick = saveResult(id, "markdown", ".md");
+        $("download-html").onclick = saveResult(id, "html", ".html");
+        $("detail-links").hidden = false;
+      }
+    } catch (err) {
+      $("compare-note").textContent = "The " + step + " is not available: " + err.message;
+    }
+    if (!terminal.has(job.status)) {
+      $("compare-note").textContent = "The translation appears when the job completes.";
+    } else if (job.status !== "completed") {
+      $("compare-note").textContent = "The job has no translation.";
+    }
+    renderComparison(source, result);
+  }
+
+  // saveResult returns a click handler downloading a job's result in format.
+  function saveResult(id, format, ext) {
+    return async (e) => {
+      e.preventDefault();
+      showError($("detail-error"), null);
+      try {
+        await download("api/v1/jobs/" + id + "/result", { format: format, download: "true" }, id + ext);
+      } catch (err) {
+        showError($("detail-error"), err);
+      }
+    };
+  }
+
+  // paragraphs splits markdown on blank lines, so the source and its
+  // translation line up block by block.
+  function paragraphs(text) {
+    return (text || "").split(/\n\s*\n/).map((p) => p.replace(/^\n+|\s+$/g, "")).filter((p) => p);
+  }
+
+  function renderComparison(source, result) {
+    const rows = $("compare-rows");
+    rows.replaceChildren();
+    const translated = result || {};
+    if (source.title || translated.translated_title) {
+      const row = element("tr", undefined, "title");
+      row.append(element("td", source.title || ""), element("td", translated.translated_title || ""));
+      rows.append(row);
+    }
+    const left = paragraphs(source.markdown);
+    const right = paragraphs(translated.translated_markdown);
+    for (let i = 0; i < Math.max(left.length, right.length); i++) {
+      const row = element("tr");
+      row.append(element("td", left[i] || ""), element("td", right[i] || ""));
+      rows.append(row);
+    }
+  }
+
+  // Wiring
+
+  $("token").value = token;
+  $("auth").addEventListener("submit", (e) => {
+    e.preventDefault();
+    token = $("token").value.trim();
+    if (token) {
+      sessionStorage.setItem("iskoces-token", token);
+    } else {
+      sessionStorage.removeItem("iskoces-token");
+    }
+    loadJobs(false);
+    selected = "";
+    selectFromHash();
+  });
+  $("filters").addEventListener("submit", (e) => {
+    e.preventDefault();
+    loadJobs(false);
+  });
+  $("status").addEventListener("change", () => loadJobs(false));
+  $("sort").addEventListener("change", () => loadJobs(false));
+  $("auto").addEventListener("change", scheduleRefresh);
+  $("more").addEventListener("click", () => loadJobs(true));
+  window.addEventListener("hashchange", selectFromHash);
+
+  loadJobs(false);
+  scheduleRefresh();
+  selectFromHash();
+})();
@@ -0,0 +1,471 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	nanabushv1 "github.com/dasmlab/iskoces/pkg/proto/v1"
+	"github.com/dasmlab/iskoces/pkg/translate"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// minHeartbeatIntervalSeconds and maxHeartbeatIntervalSeconds bound the
+	// heartbeat interval an operator may set.
+	minHeartbeatIntervalSeconds = 1
+	maxHeartbeatIntervalSeconds = 3600
+)
+
+// AdminService implements the AdminService gRPC service (operator controls).
+type AdminService struct {
+	nanabushv1.UnimplementedAdminServiceServer
+
+	// Translation is the service being administered.
+	Translation *TranslationService
+
+	// Logger for admin operations.
+	Logger *logrus.Logger
+}
+
+// NewAdminService creates an AdminService for the given TranslationService.
+func NewAdminService(translation *TranslationService, logger *logrus.Logger) *AdminService {
+	if logger == nil {
+		logger = logrus.New()
+	}
+	return &AdminService{
+		Translation: translation,
+		Logger:      logger,
+	}
+}
+
+// UpdateConfig changes client-facing configuration and pushes it to watchers.
+func (a *AdminService) UpdateConfig(ctx context.Context, req *nanabushv1.UpdateConfigRequest) (*nanabushv1.ConfigUpdate, error) {
+	if req.HeartbeatIntervalSeconds != nil {
+		if n := *req.HeartbeatIntervalSeconds; n < minHeartbeatIntervalSeconds || n > maxHeartbeatIntervalSeconds {
+			return nil, invalidArgument("heartbeat_interval_seconds", "must be between 1 and 3600")
+		}
+	}
+
+	a.Logger.WithFields(logrus.Fields{
+		"heartbeat_interval_seconds": req.HeartbeatIntervalSeconds,
+		"maintenance_mode":           req.MaintenanceMode,
+		"set_supported_languages":    req.SetSupportedLanguages,
+	}).Info("[gRPC] UpdateConfig request received")
+
+	cfg := a.Translation.UpdateClientConfig(func(c *ClientConfig) {
+		if req.HeartbeatIntervalSeconds != nil {
+			c.HeartbeatIntervalSeconds = *req.HeartbeatIntervalSeconds
+		}
+		if req.MaintenanceMode != nil {
+			c.MaintenanceMode = *req.MaintenanceMode
+		}
+		if req.MaintenanceMessage != nil {
+			c.MaintenanceMessage = *req.MaintenanceMessage
+		}
+		if req.SetSupportedLanguages {
+			c.SupportedLanguages = append([]string(nil), req.SupportedLanguages...)
+		}
+	})
+
+	return cfg.toProto(), nil
+}
+
+// SetQuota sets or resets a namespace's quota.
+func (a *AdminService) SetQuota(ctx context.Context, req *nanabushv1.SetQuotaRequest) (*nanabushv1.QuotaStatus, error) {
+	quotas := a.Translation.Quotas
+	if quotas == nil {
+		return nil, status.Error(codes.FailedPrecondition, "quotas are not enabled on this server")
+	}
+	if req.RequestsPerMinute < 0 {
+		return nil, invalidArgument("requests_per_minute", "must not be negative")
+	}
+	if req.CharactersPerDay < 0 {
+		return nil, invalidArgument("characters_per_day", "must not be negative")
+	}
+
+	if req.ResetToDefault {
+		if req.Namespace == "" {
+			return nil, invalidArgument("namespace", "is required with reset_to_default")
+		}
+		quotas.ResetLimits(req.Namespace)
+	} else {
+		quotas.SetLimits(req.Namespace, QuotaLimits{
+			RequestsPerMinute: int(req.RequestsPerMinute),
+			CharactersPerDay:  req.CharactersPerDay,
+		})
+	}
+
+	a.Logger.WithFields(logrus.Fields{
+		"namespace":           namespaceLabel(req.Namespace),
+		"requests_per_minute": req.RequestsPerMinute,
+		"characters_per_day":  req.CharactersPerDay,
+		"reset_to_default":    req.ResetToDefault,
+	}).Info("[gRPC] Quota updated")
+
+	return quotaStatusProto(quotas.Usage(req.Namespace)), nil
+}
+
+// GetQuotas reports the default quota and per-namespace quotas and usage.
+func (a *AdminService) GetQuotas(ctx context.Context, req *nanabushv1.GetQuotasRequest) (*nanabushv1.GetQuotasResponse, error) {
+	quotas := a.Translation.Quotas
+	if quotas == nil {
+		return nil, status.Error(codes.FailedPrecondition, "quotas are not enabled on this server")
+	}
+
+	defaults, usage := quotas.AllUsage()
+	resp := &nanabushv1.GetQuotasResponse{
+		DefaultQuota: &nanabushv1.QuotaStatus{
+			RequestsPerMinute: int32(defaults.RequestsPerMinute),
+			CharactersPerDay:  defaults.CharactersPerDay,
+		},
+	}
+	for _, u := range usage {
+		resp.Namespaces = append(resp.Namespaces, quotaStatusProto(u))
+	}
+	return resp, nil
+}
+
+// quotaStatusProto converts QuotaUsage to its wire form.
+func quotaStatusProto(u QuotaUsage) *nanabushv1.QuotaStatus {
+	return &nanabushv1.QuotaStatus{
+		Namespace:           u.Namespace,
+		RequestsPerMinute:   int32(u.Limits.RequestsPerMinute),
+		CharactersPerDay:    u.Limits.CharactersPerDay,
+		CharactersUsedToday: u.CharactersUsed,
+		RequestsAvailable:   u.RequestsAvailable,
+	}
+}
+
+// SetDrainMode enters or leaves drain mode.
+func (a *AdminService) SetDrainMode(ctx context.Context, req *nanabushv1.SetDrainModeRequest) (*nanabushv1.DrainStatus, error) {
+	a.Logger.WithFields(logrus.Fields{
+		"draining": req.Draining,
+		"reason":   req.Reason,
+	}).Info("[gRPC] SetDrainMode request received")
+
+	a.Translation.SetDraining(req.Draining, req.Reason)
+	return a.drainStatusProto(), nil
+}
+
+// GetDrainStatus reports the drain state and in-flight jobs.
+func (a *AdminService) GetDrainStatus(ctx context.Context, req *nanabushv1.GetDrainStatusRequest) (*nanabushv1.DrainStatus, error) {
+	return a.drainStatusProto(), nil
+}
+
+// drainStatusProto returns the current drain status in its wire form.
+func (a *AdminService) drainStatusProto() *nanabushv1.DrainStatus {
+	st := a.Translation.DrainStatus()
+	resp := &nanabushv1.DrainStatus{
+		Draining:          st.Draining,
+		Reason:            st.Reason,
+		InFlightJobs:      int32(st.InFlightJobs),
+		InFlightBytes:     int64(st.InFlightBytes),
+		RetryAfterSeconds: int32(math.Ceil(a.Translation.drainRetryDelay().Seconds())),
+	}
+	if !st.Since.IsZero() {
+		resp.Since = timestamppb.New(st.Since)
+	}
+	return resp
+}
+
+// GetWorkerPool reports the state of the translation worker pool.
+func (a *AdminService) GetWorkerPool(ctx context.Context, req *nanabushv1.GetWorkerPoolRequest) (*nanabushv1.WorkerPoolStatus, error) {
+	pool, ok := translate.Backend(a.Translation.Translator).(*translate.WorkerPool)
+	if !ok {
+		return nil, status.Error(codes.FailedPrecondition, "the translation engine does not use a worker pool")
+	}
+	return workerPoolStatusProto(pool.PoolStats()), nil
+}
+
+// UpgradeWorkers replaces the worker pool's workers blue/green.
+func (a *AdminService) UpgradeWorkers(ctx context.Context, req *nanabushv1.UpgradeWorkersRequest) (*nanabushv1.WorkerPoolStatus, error) {
+	pool, ok := translate.Backend(a.Translation.Translator).(*translate.WorkerPool)
+	if !ok {
+		return nil, status.Error(codes.FailedPrecondition, "the translation engine does not use a worker pool")
+	}
+
+	if req.ScriptPath != "" {
+		if _, err := os.Stat(req.ScriptPath); err != nil {
+			return nil, invalidArgument("script_path", err.Error())
+		}
+	}
+
+	env := make([]string, 0, len(req.Env))
+	for name, value := range req.Env {
+		if name == "" || strings.Contains(name, "=") {
+			return nil, invalidArgument("env", fmt.Sprintf("has invalid variable name %q", name))
+		}
+		env = append(env, name+"="+value)
+	}
+	sort.Strings(env)
+
+	a.Logger.WithFields(logrus.Fields{
+		"python_path": req.PythonPath,
+		"script_path": req.ScriptPath,
+		"env":         env,
+	}).Info("[gRPC] UpgradeWorkers request received")
+
+	err := pool.Upgrade(ctx, translate.WorkerLaunch{
+		PythonPath: req.PythonPath,
+		ScriptPath: req.ScriptPath,
+		Env:        env,
+	})
+	switch {
+	case errors.Is(err, translate.ErrUpgradeInProgress):
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	case err != nil:
+		return nil, status.Error(codes.Aborted, err.Error())
+	}
+	return workerPoolStatusProto(pool.PoolStats()), nil
+}
+
+// ListJobs lists the jobs this server holds, newest first.
+func (a *AdminService) ListJobs(ctx context.Context, req *nanabushv1.ListJobsRequest) (*nanabushv1.ListJobsResponse, error) {
+	if req.PageSize < 0 {
+		return nil, invalidArgument("page_size", "must not be negative")
+	}
+	filter := JobFilter{
+		Namespace: req.Namespace,
+		ClientID:  req.ClientId,
+	}
+	for _, state := range req.States {
+		jobStatus, ok := jobStatusFromProto(state)
+		if !ok {
+			return nil, invalidArgument("states", fmt.Sprintf("has unsupported state %v", state))
+		}
+		filter.Statuses = append(filter.Statuses, jobStatus)
+	}
+	for _, bound := range []struct {
+		field string
+		ts    *timestamppb.Timestamp
+		t     *time.Time
+	}{
+		{"created_after", req.CreatedAfter, &filter.CreatedAfter},
+		{"created_before", req.CreatedBefore, &filter.CreatedBefore},
+	} {
+		if bound.ts == nil {
+			continue
+		}
+		if err := bound.ts.CheckValid(); err != nil {
+			return nil, invalidArgument(bound.field, "is not a valid timestamp")
+		}
+		*bound.t = bound.ts.AsTime()
+	}
+
+	jobs, next, err := a.Translation.JobQueue.FilterJobs(filter, int(req.PageSize), req.PageToken)
+	if err != nil {
+		return nil, invalidArgument("page_token", "is not a token returned by ListJobs")
+	}
+
+	resp := &nanabushv1.ListJobsResponse{NextPageToken: next}
+	for _, job := range jobs {
+		resp.Jobs = append(resp.Jobs, jobSummaryProto(job))
+	}
+	return resp, nil
+}
+
+// ListDeadLetterJobs lists the dead-letter jobs, newest first.
+func (a *AdminService) ListDeadLetterJobs(ctx context.Context, req *nanabushv1.ListDeadLetterJobsRequest) (*nanabushv1.ListJobsResponse, error) {
+	if req.PageSize < 0 {
+		return nil, invalidArgument("page_size", "must not be negative")
+	}
+	jobs, next, err := a.Translation.JobQueue.DeadLetterJobs(req.Namespace, int(req.PageSize), req.PageToken)
+	if err != nil {
+		return nil, invalidArgument("page_token", "is not a token returned by ListDeadLetterJobs")
+	}
+
+	resp := &nanabushv1.ListJobsResponse{NextPageToken: next}
+	for _, job := range jobs {
+		resp.Jobs = append(resp.Jobs, jobSummaryProto(job))
+	}
+	return resp, nil
+}
+
+// RequeueDeadLetterJob submits a dead-letter job again as a new job.
+func (a *AdminService) RequeueDeadLetterJob(ctx context.Context, req *nanabushv1.RequeueDeadLetterJobRequest) (*nanabushv1.TranslationStatus, error) {
+	if req.JobId == "" {
+		return nil, invalidArgument("job_id", "is required")
+	}
+	job, err := a.Translation.JobQueue.RequeueDeadLetter(req.JobId)
+	switch {
+	case errors.Is(err, ErrJobNotFound):
+		return nil, status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, ErrNotDeadLetter):
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, ErrIdempotencyConflict):
+		return nil, status.Error(codes.AlreadyExists, err.Error())
+	case err != nil:
+		return nil, status.Error(codes.Internal, fmt.Sprintf("failed to re-queue job: %v", err))
+	}
+	return jobToStatusProto(job), nil
+}
+
+// GetUsage reports the resources each namespace's jobs have used since the
+// server started.
+func (a *AdminService) GetUsage(ctx context.Context, req *nanabushv1.GetUsageRequest) (*nanabushv1.GetUsageResponse, error) {
+	usage, since := a.Translation.JobQueue.Usage()
+	resp := &nanabushv1.GetUsageResponse{Since: timestamppb.New(since)}
+	for _, u := range usage {
+		if req.Namespace != nil && u.Namespace != req.GetNamespace() {
+			continue
+		}
+		resp.Namespaces = append(resp.Namespaces, &nanabushv1.NamespaceUsage{
+			Namespace: u.Namespace,
+			Jobs:      u.Jobs,
+			Usage:     jobUsageProto(u.JobUsage),
+		})
+	}
+	return resp, nil
+}
+
+// PauseJob pauses an async job until ResumeJob.
+func (a *AdminService) PauseJob(ctx context.Context, req *nanabushv1.PauseJobRequest) (*nanabushv1.TranslationStatus, error) {
+	if req.JobId == "" {
+		return nil, invalidArgument("job_id", "is required")
+	}
+	job, err := a.Translation.JobQueue.PauseJob(req.JobId, req.Reason)
+	switch {
+	case errors.Is(err, ErrJobNotFound):
+		return nil, status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, ErrJobFinished), errors.Is(err, ErrPauseUnsupported):
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	case err != nil:
+		return nil, status.Error(codes.Internal, fmt.Sprintf("failed to pause job: %v", err))
+	}
+	return jobToStatusProto(job), nil
+}
+
+// ResumeJob queues a paused job again.
+func (a *AdminService) ResumeJob(ctx context.Context, req *nanabushv1.ResumeJobRequest) (*nanabushv1.TranslationStatus, error) {
+	if req.JobId == "" {
+		return nil, invalidArgument("job_id", "is required")
+	}
+	job, err := a.Translation.JobQueue.ResumeJob(req.JobId)
+	switch {
+	case errors.Is(err, ErrJobNotFound):
+		return nil, status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, ErrJobNotPaused):
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	case err != nil:
+		return nil, status.Error(codes.Internal, fmt.Sprintf("failed to resume job: %v", err))
+	}
+	return jobToStatusProto(job), nil
+}
+
+// PauseQueue pauses the async jobs at or below a priority (low by default)
+// until ResumeQueue.
+func (a *AdminService) PauseQueue(ctx context.Context, req *nanabushv1.PauseQueueRequest) (*nanabushv1.QueuePauseStatus, error) {
+	maxPriority := translate.PriorityLow
+	if req.MaxPriority != nanabushv1.Priority_PRIORITY_UNSPECIFIED {
+		maxPriority = requestPriority(&nanabushv1.TranslateRequest{Priority: req.MaxPriority})
+	}
+	pause, held, err := a.Translation.JobQueue.PauseQueue(maxPriority, req.Reason)
+	if err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return queuePauseProto(pause, held), nil
+}
+
+// ResumeQueue ends the queue pause and resumes the jobs it paused.
+func (a *AdminService) ResumeQueue(ctx context.Context, req *nanabushv1.ResumeQueueRequest) (*nanabushv1.QueuePauseStatus, error) {
+	resumed := a.Translation.JobQueue.ResumeQueue()
+	return queuePauseProto(QueuePause{}, resumed), nil
+}
+
+// GetQueuePause reports the current queue pause.
+func (a *AdminService) GetQueuePause(ctx context.Context, req *nanabushv1.GetQueuePauseRequest) (*nanabushv1.QueuePauseStatus, error) {
+	return queuePauseProto(a.Translation.JobQueue.QueuePaused(), 0), nil
+}
+
+// queuePauseProto converts a queue pause and the jobs the call changed.
+func queuePauseProto(p QueuePause, changed int) *nanabushv1.QueuePauseStatus {
+	st := &nanabushv1.QueuePauseStatus{
+		Paused:      p.Paused,
+		Reason:      p.Reason,
+		ChangedJobs: int32(changed),
+	}
+	if p.Paused {
+		st.MaxPriority = priorityToProto(p.MaxPriority)
+		st.Since = timestamppb.New(p.Since)
+	}
+	return st
+}
+
+// jobSummaryProto converts a job for ListJobs (thread-safe). The progress
+// history is left out to keep pages small; GetTranslationStatus has it.
+func jobSummaryProto(job *TranslationJob) *nanabushv1.JobSummary {
+	summary := &nanabushv1.JobSummary{Status: jobToStatusProto(job)}
+	summary.Status.ProgressHistory = nil
+	job.mu.RLock()
+	defer job.mu.RUnlock()
+	summary.Namespace = job.Namespace
+	summary.ClientId = job.ClientID
+	summary.Primitive = job.Primitive
+	summary.SourceLanguage = job.SourceLang
+	summary.TargetLanguage = job.TargetLang
+	summary.ContentBytes = int64(job.ContentBytes())
+	summary.DeadLetter = job.DeadLetter
+	summary.RequeuedAs = job.RequeuedAs
+	return summary
+}
+
+// workerPoolStatusProto converts PoolStats to its wire form.
+func workerPoolStatusProto(stats translate.PoolStats) *nanabushv1.WorkerPoolStatus {
+	resp := &nanabushv1.WorkerPoolStatus{
+		Engine:                  string(stats.Engine),
+		MinWorkers:              int32(stats.MinWorkers),
+		MaxWorkers:              int32(stats.MaxWorkers),
+		Workers:                 int32(stats.Workers),
+		BusyWorkers:             int32(stats.Busy),
+		QueueDepth:              int32(stats.QueueDepth),
+		QueueDepthByPair:        make(map[string]int32, len(stats.QueueDepthByPair)),
+		AverageRoundTripSeconds: stats.AverageRoundTrip.Seconds(),
+		LastError:               stats.LastError,
+		LastErrorAt:             timestampIfSet(stats.LastErrorAt),
+	}
+	for pair, n := range stats.QueueDepthByPair {
+		resp.QueueDepthByPair[pair] = int32(n)
+	}
+	for _, w := range stats.PerWorker {
+		resp.WorkerStatus = append(resp.WorkerStatus, &nanabushv1.WorkerStatus{
+			Id:            int32(w.ID),
+			Generation:    w.Generation,
+			Pair:          w.Pair,
+			Interactive:   w.Interactive,
+			Standby:       w.Standby,
+			Address:       w.Address,
+			State:         w.State,
+			Quarantined:   w.Quarantined,
+			InFlight:      int32(w.InFlight),
+			Requests:      int64(w.Requests),
+			StartedAt:     timestampIfSet(w.StartedAt),
+			UptimeSeconds: w.Uptime.Seconds(),
+			LastUsed:      timestampIfSet(w.LastUsed),
+			RssBytes:      w.RSSBytes,
+			LastError:     w.LastError,
+			LastErrorAt:   timestampIfSet(w.LastErrorAt),
+			Version:       w.Version,
+			EngineVersion: w.EngineVersion,
+			Models:        w.Models,
+		})
+	}
+	return resp
+}
+
+// timestampIfSet converts t, leaving the zero time unset.
+func timestampIfSet(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
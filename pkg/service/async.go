@@ -0,0 +1,30 @@
+package service
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// asyncMethods are the RPCs that create async jobs for the client to
+// collect later, rejected when async jobs are disabled. Status, result and
+// cancel calls keep working; they find no jobs.
+var asyncMethods = map[string]bool{
+	"/nanabush.v1.TranslationService/SubmitTranslation": true,
+	"/nanabush.v2.TranslationService/SubmitTranslation": true,
+	"/nanabush.v2.TranslationService/SubmitBatch":       true,
+	"/nanabush.v2.TranslationService/PutSchedule":       true,
+}
+
+// AsyncUnaryInterceptor rejects calls that create async jobs with
+// UNIMPLEMENTED while svc.AsyncDisabled is set.
+func AsyncUnaryInterceptor(svc *TranslationService) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if asyncMethods[info.FullMethod] && svc.AsyncDisabled {
+			return nil, status.Error(codes.Unimplemented, "async translation jobs are disabled on this server; use Translate")
+		}
+		return handler(ctx, req)
+	}
+}
@@ -0,0 +1,348 @@
+package service
+
+import (
+	"context"
+	"slices"
+	"time"
+	"unicode/utf8"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+
+	"github.com/dasmlab/iskoces/pkg/audit"
+	nanabushv1 "github.com/dasmlab/iskoces/pkg/proto/v1"
+	nanabushv2 "github.com/dasmlab/iskoces/pkg/proto/v2"
+)
+
+// callerKey is the context key of the caller authenticated by the HTTP API.
+type callerKey struct{}
+
+// WithCaller returns ctx with the name of the caller the HTTP API
+// authenticated, for the audit log.
+func WithCaller(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, callerKey{}, name)
+}
+
+// callerFromContext returns the authenticated caller's name, or "".
+func callerFromContext(ctx context.Context) string {
+	name, _ := ctx.Value(callerKey{}).(string)
+	return name
+}
+
+// callerNamespacesKey is the context key of the namespaces the caller
+// authenticated by the HTTP API may use.
+type callerNamespacesKey struct{}
+
+// WithCallerNamespaces returns ctx with the namespaces the caller the HTTP
+// API authenticated may use; none means all.
+func WithCallerNamespaces(ctx context.Context, namespaces []string) context.Context {
+	return context.WithValue(ctx, callerNamespacesKey{}, namespaces)
+}
+
+// callerAllows reports whether the caller may use namespace. Callers not
+// bound to namespaces, including unauthenticated ones, may use all.
+func callerAllows(ctx context.Context, namespace string) bool {
+	namespaces, _ := ctx.Value(callerNamespacesKey{}).([]string)
+	return len(namespaces) == 0 || slices.Contains(namespaces, namespace)
+}
+
+// AuditUnaryInterceptor writes an audit record for each unary call that
+// does translation work (the calls subject to quotas), including calls
+// rejected before reaching the handler.
+func AuditUnaryInterceptor(svc *TranslationService) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if svc.Audit == nil || !quotaMethods[info.FullMethod] {
+			return handler(ctx, req)
+		}
+		start := time.Now()
+		resp, err := handler(ctx, req)
+
+		rec := svc.auditRecord(ctx, info.FullMethod, req)
+		content := svc.Audit.IncludesContent()
+		describeRequest(&rec, req, content)
+		rec.RequestBytes = messageSize(req)
+		if err == nil {
+			describeResponse(&rec, resp, content)
+			rec.ResponseBytes = messageSize(resp)
+		}
+		endAuditRecord(&rec, start, err)
+		svc.Audit.Log(rec)
+		return resp, err
+	}
+}
+
+// AuditStreamInterceptor writes an audit record for each translation stream
+// once it ends, covering every message received and sent on it.
+func AuditStreamInterceptor(svc *TranslationService) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if svc.Audit == nil || !quotaMethods[info.FullMethod] {
+			return handler(srv, ss)
+		}
+		audited := &auditedStream{
+			ServerStream: ss,
+			content:      svc.Audit.IncludesContent(),
+			rec:          svc.auditRecord(ss.Context(), info.FullMethod, nil),
+		}
+		start := time.Now()
+		err := handler(srv, audited)
+
+		// The first message may name the namespace
+		if audited.first != nil {
+			audited.rec.Namespace = svc.quotaNamespace(ss.Context(), audited.first)
+		}
+		endAuditRecord(&audited.rec, start, err)
+		svc.Audit.Log(audited.rec)
+		return err
+	}
+}
+
+// auditedStream describes the messages of a stream in an audit record.
+type auditedStream struct {
+	grpc.ServerStream
+	content bool
+	first   any // First message received
+	rec     audit.Record
+}
+
+// RecvMsg receives a message and adds it to the record.
+func (s *auditedStream) RecvMsg(m any) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		if s.first == nil {
+			s.first = m
+		}
+		describeRequest(&s.rec, m, s.content)
+		s.rec.RequestBytes += messageSize(m)
+	}
+	return err
+}
+
+// SendMsg sends a message and adds it to the record.
+func (s *auditedStream) SendMsg(m any) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		describeResponse(&s.rec, m, s.content)
+		s.rec.ResponseBytes += messageSize(m)
+	}
+	return err
+}
+
+// auditRecord starts the audit record of a call: who made it, from where
+// and for which namespace.
+func (s *TranslationService) auditRecord(ctx context.Context, method string, req any) audit.Record {
+	rec := audit.Record{
+		Event:     audit.EventCall,
+		Method:    method,
+		RequestID: RequestIDFromContext(ctx),
+		ClientID:  callerClientID(ctx),
+		Caller:    callerFromContext(ctx),
+		Peer:      peerAddr(ctx),
+		Namespace: s.quotaNamespace(ctx, req),
+	}
+	if rec.ClientID != "" {
+		s.clientsMutex.RLock()
+		if client, ok := s.clients[rec.ClientID]; ok {
+			rec.ClientName = client.ClientName
+		}
+		s.clientsMutex.RUnlock()
+	}
+	return rec
+}
+
+// endAuditRecord records how a call that started at start ended.
+func endAuditRecord(rec *audit.Record, start time.Time, err error) {
+	rec.DurationMS = time.Since(start).Milliseconds()
+	st := status.Convert(err)
+	rec.Outcome = st.Code().String()
+	if err == nil {
+		return
+	}
+	rec.Error = st.Message()
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok && info.Metadata["engine_error_class"] != "" {
+			rec.ErrorClass = info.Metadata["engine_error_class"]
+		}
+	}
+}
+
+// describeRequest adds what a request message asks to translate to rec:
+// its identifiers, language pair, items and characters, and with content,
+// the text itself.
+func describeRequest(rec *audit.Record, msg any, content bool) {
+	var texts []string
+	switch m := msg.(type) {
+	case *nanabushv1.TranslateRequest:
+		rec.ClientJobID = m.JobId
+		rec.Primitive = m.Primitive.String()
+		setPair(rec, m.SourceLanguage, m.TargetLanguage)
+		texts = append(texts, m.GetTitle())
+		if doc := m.GetDoc(); doc != nil {
+			texts = append(texts, doc.Title, doc.Markdown)
+		}
+		rec.Items++
+	case *nanabushv1.TitleCheckRequest:
+		setPair(rec, m.SourceLanguage, m.LanguageTag)
+		texts = append(texts, m.Title)
+		rec.Items++
+	case *nanabushv1.BatchTranslateRequest:
+		rec.ClientJobID = m.JobId
+		setPair(rec, m.SourceLanguage, m.TargetLanguage)
+		texts = m.Texts
+		rec.Items += len(m.Texts)
+	case *nanabushv1.DetectLanguageRequest:
+		texts = append(texts, m.Text)
+		rec.Items++
+	case *nanabushv1.TranslateChunk:
+		if rec.ClientJobID == "" {
+			rec.ClientJobID = m.JobId
+		}
+		setPair(rec, m.SourceLanguage, m.TargetLanguage)
+		texts = append(texts, m.Content)
+		rec.Items++
+	case *nanabushv1.DocumentSetRequest:
+		rec.ClientJobID = m.JobId
+		setPair(rec, m.SourceLanguage, m.TargetLanguage)
+		for _, doc := range m.Documents {
+			texts = append(texts, doc.GetTitle(), doc.GetMarkdown())
+		}
+		rec.Items += len(m.Documents)
+	case *nanabushv2.TranslationRequest:
+		rec.ClientJobID = m.RequestId
+		setPair(rec, m.SourceLanguage, m.TargetLanguage)
+		texts = append(texts, m.GetText())
+		if doc := m.GetDocument(); doc != nil {
+			texts = append(texts, doc.Title, doc.Markdown)
+		}
+		rec.Items++
+	case *nanabushv2.SubmitBatchRequest:
+		rec.ClientJobID = m.RequestId
+		for i, r := range m.Requests {
+			if i == 0 {
+				setPair(rec, r.SourceLanguage, r.TargetLanguage)
+			} else if r.SourceLanguage != rec.SourceLanguage || r.TargetLanguage != rec.TargetLanguage {
+				// The jobs' own records have their pairs
+				rec.SourceLanguage, rec.TargetLanguage = "", ""
+			}
+			texts = append(texts, r.GetText())
+			if doc := r.GetDocument(); doc != nil {
+				texts = append(texts, doc.Title, doc.Markdown)
+			}
+		}
+		rec.Items += len(m.Requests)
+	}
+	rec.SourceChars += addTexts(&rec.Source, texts, content)
+}
+
+// describeResponse adds what a response message returned to rec: the job
+// or batch it created, or the translation and its characters.
+func describeResponse(rec *audit.Record, msg any, content bool) {
+	var texts []string
+	switch m := msg.(type) {
+	case *nanabushv1.TranslateResponse:
+		texts = append(texts, m.TranslatedTitle, m.TranslatedMarkdown)
+		if m.Queued != nil {
+			rec.JobID = m.Queued.JobId
+		}
+	case *nanabushv1.BatchTranslateResponse:
+		texts = m.Translations
+	case *nanabushv1.DetectLanguageResponse:
+		if len(m.Candidates) > 0 && rec.SourceLanguage == "" {
+			rec.SourceLanguage = m.Candidates[0].Language
+		}
+	case *nanabushv1.SubmitTranslationResponse:
+		rec.JobID = m.JobId
+	case *nanabushv1.TranslateChunk:
+		texts = append(texts, m.Content)
+	case *nanabushv1.DocumentSetProgress:
+		if doc := m.TranslatedDocument; doc != nil {
+			texts = append(texts, doc.Title, doc.Markdown)
+		}
+	case *nanabushv2.TranslationJob:
+		rec.JobID = m.JobId
+		rec.BatchID = m.BatchId
+	case *nanabushv2.Batch:
+		rec.BatchID = m.BatchId
+	case *nanabushv2.TranslationResult:
+		texts = append(texts, m.GetText())
+		if doc := m.GetDocument(); doc != nil {
+			texts = append(texts, doc.Title, doc.Markdown)
+		}
+	}
+	rec.TranslatedChars += addTexts(&rec.Translation, texts, content)
+}
+
+// setPair sets rec's language pair, unless a message already did.
+func setPair(rec *audit.Record, source, target string) {
+	if rec.SourceLanguage == "" && rec.TargetLanguage == "" {
+		rec.SourceLanguage, rec.TargetLanguage = source, target
+	}
+}
+
+// addTexts returns the characters in texts, appending the non-empty ones
+// to dst if content is recorded.
+func addTexts(dst *[]string, texts []string, content bool) int64 {
+	var n int64
+	for _, text := range texts {
+		if text == "" {
+			continue
+		}
+		n += int64(utf8.RuneCountInString(text))
+		if content {
+			*dst = append(*dst, text)
+		}
+	}
+	return n
+}
+
+// auditedResult is what a completed job translated, taken before its
+// markdown may be offloaded to the result store.
+type auditedResult struct {
+	chars   int64
+	content []string
+}
+
+// auditFinished writes the audit record of the job's final state, if the
+// queue keeps an audit log. Callers hold j.mu.
+func (j *TranslationJob) auditFinished() {
+	if j.audit == nil {
+		return
+	}
+	rec := audit.Record{
+		Event:          audit.EventJob,
+		ClientID:       j.ClientID,
+		Namespace:      j.Namespace,
+		JobID:          j.ID,
+		ClientJobID:    j.RequestID,
+		BatchID:        j.BatchID,
+		Primitive:      j.Primitive.String(),
+		SourceLanguage: j.SourceLang,
+		TargetLanguage: j.TargetLang,
+		Items:          1,
+		Outcome:        string(j.Status),
+		ErrorClass:     string(j.ErrorClass),
+		Error:          j.Error,
+	}
+	// Timed from submission, including the wait in the queue
+	if j.CompletedAt != nil {
+		rec.DurationMS = j.CompletedAt.Sub(j.CreatedAt).Milliseconds()
+	}
+	content := j.audit.IncludesContent()
+	texts := []string{j.Title}
+	if j.Document != nil {
+		texts = append(texts, j.Document.Markdown)
+	}
+	rec.SourceChars = addTexts(&rec.Source, texts, content)
+	if j.auditResult != nil {
+		rec.TranslatedChars = j.auditResult.chars
+		rec.Translation = j.auditResult.content
+	}
+	j.audit.Log(rec)
+}
+
+// SetAudit makes the queue write an audit record for each job as it
+// finishes. It must be called before jobs are submitted or restored from a
+// store.
+func (q *JobQueue) SetAudit(l *audit.Logger) {
+	q.audit = l
+}
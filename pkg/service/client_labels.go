@@ -0,0 +1,267 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	nanabushv1 "github.com/dasmlab/iskoces/pkg/proto/v1"
+)
+
+const (
+	// MaxClientLabels is the maximum number of labels a client may register with.
+	MaxClientLabels = 32
+	// maxLabelLength bounds label names and values.
+	maxLabelLength = 63
+)
+
+// LabelSchema describes the labels clients may register with and the policy
+// each label value implies. It is the format of the label schema file, e.g.:
+//
+//	{"labels": {"tier": {"values": ["standard", "premium"], "default": "standard",
+//	  "policies": {"premium": {"priority": "high", "quota_namespace": "premium"}}}}}
+type LabelSchema struct {
+	// Labels maps label names to their specification.
+	Labels map[string]LabelSpec `json:"labels"`
+	// AllowUnknown accepts labels that aren't in Labels (they imply no policy).
+	AllowUnknown bool `json:"allow_unknown"`
+}
+
+// LabelSpec specifies one label.
+type LabelSpec struct {
+	// Values lists the allowed values; empty allows any value.
+	Values []string `json:"values"`
+	// Default is applied when the client doesn't send the label.
+	Default string `json:"default"`
+	// Required rejects registrations without the label (and no default).
+	Required bool `json:"required"`
+	// Policies maps label values to the policy they imply.
+	Policies map[string]LabelPolicy `json:"policies"`
+}
+
+// LabelPolicy is the routing and quota policy implied by a label value.
+type LabelPolicy struct {
+	// Priority ("low", "normal", "high") applies to requests that leave priority unspecified.
+	Priority string `json:"priority"`
+	// QuotaNamespace is the namespace the client's calls are charged to.
+	QuotaNamespace string `json:"quota_namespace"`
+}
+
+// LoadLabelSchema reads and checks a JSON label schema file.
+func LoadLabelSchema(path string) (*LabelSchema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read label schema: %w", err)
+	}
+	var schema LabelSchema
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return nil, fmt.Errorf("failed to parse label schema %s: %w", path, err)
+	}
+	for name, spec := range schema.Labels {
+		if spec.Default != "" && !spec.allows(spec.Default) {
+			return nil, fmt.Errorf("label schema %s: default %q of label %q is not an allowed value", path, spec.Default, name)
+		}
+		for value, policy := range spec.Policies {
+			if _, ok := parsePolicyPriority(policy.Priority); !ok {
+				return nil, fmt.Errorf("label schema %s: label %s=%s has unknown priority %q", path, name, value, policy.Priority)
+			}
+		}
+	}
+	return &schema, nil
+}
+
+// allows reports whether value is allowed for the label.
+func (spec LabelSpec) allows(value string) bool {
+	if len(spec.Values) == 0 {
+		return true
+	}
+	for _, v := range spec.Values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientPolicy is the effective policy of a registered client.
+type ClientPolicy struct {
+	// Labels are the client's labels with schema defaults applied.
+	Labels map[string]string
+	// Priority applies to requests that leave priority unspecified
+	// (PRIORITY_UNSPECIFIED keeps the server default).
+	Priority nanabushv1.Priority
+	// QuotaNamespace overrides the namespace the client's calls are charged to.
+	QuotaNamespace string
+}
+
+// resolveClientPolicy validates labels against the schema (a nil schema only
+// checks label syntax) and derives the client's policy. Returns an
+// InvalidArgument status error with a violation per bad label.
+func resolveClientPolicy(schema *LabelSchema, labels map[string]string) (ClientPolicy, error) {
+	var v []*errdetails.BadRequest_FieldViolation
+	policy := ClientPolicy{Labels: make(map[string]string, len(labels))}
+
+	if len(labels) > MaxClientLabels {
+		v = append(v, &errdetails.BadRequest_FieldViolation{
+			Field:       "labels",
+			Description: fmt.Sprintf("has %d entries, exceeding the limit of %d", len(labels), MaxClientLabels),
+		})
+	}
+	for name, value := range labels {
+		field := fmt.Sprintf("labels[%q]", name)
+		switch {
+		case !validLabelToken(name, false):
+			v = append(v, &errdetails.BadRequest_FieldViolation{Field: field,
+				Description: fmt.Sprintf("name must be 1-%d characters of letters, digits, '-', '_' or '.'", maxLabelLength)})
+		case !validLabelToken(value, true):
+			v = append(v, &errdetails.BadRequest_FieldViolation{Field: field,
+				Description: fmt.Sprintf("value must be at most %d characters of letters, digits, '-', '_' or '.'", maxLabelLength)})
+		case schema != nil && !schema.AllowUnknown && !schema.hasLabel(name):
+			v = append(v, &errdetails.BadRequest_FieldViolation{Field: field, Description: "is not a known label"})
+		case schema != nil && schema.hasLabel(name) && !schema.Labels[name].allows(value):
+			v = append(v, &errdetails.BadRequest_FieldViolation{Field: field,
+				Description: fmt.Sprintf("value %q is not one of %s", value, strings.Join(schema.Labels[name].Values, ", "))})
+		default:
+			policy.Labels[name] = value
+		}
+	}
+
+	if schema != nil {
+		for name, spec := range schema.Labels {
+			if _, ok := policy.Labels[name]; ok {
+				continue
+			}
+			if _, sent := labels[name]; sent {
+				continue // already reported as invalid
+			}
+			if spec.Default != "" {
+				policy.Labels[name] = spec.Default
+			} else if spec.Required {
+				v = append(v, &errdetails.BadRequest_FieldViolation{Field: fmt.Sprintf("labels[%q]", name), Description: "is required"})
+			}
+		}
+	}
+
+	if len(v) > 0 {
+		sort.Slice(v, func(i, j int) bool { return v[i].Field < v[j].Field })
+		st := status.New(codes.InvalidArgument, fmt.Sprintf("invalid %s: %s", v[0].Field, v[0].Description))
+		if detailed, err := st.WithDetails(&errdetails.BadRequest{FieldViolations: v}); err == nil {
+			st = detailed
+		}
+		return ClientPolicy{}, st.Err()
+	}
+
+	if schema == nil {
+		return policy, nil
+	}
+
+	// Apply label policies in label-name order: the highest priority wins and
+	// the first quota namespace wins.
+	names := make([]string, 0, len(policy.Labels))
+	for name := range policy.Labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		lp, ok := schema.Labels[name].Policies[policy.Labels[name]]
+		if !ok {
+			continue
+		}
+		if p, _ := parsePolicyPriority(lp.Priority); p > policy.Priority {
+			policy.Priority = p
+		}
+		if policy.QuotaNamespace == "" {
+			policy.QuotaNamespace = lp.QuotaNamespace
+		}
+	}
+	return policy, nil
+}
+
+// hasLabel reports whether the schema specifies the label.
+func (schema *LabelSchema) hasLabel(name string) bool {
+	_, ok := schema.Labels[name]
+	return ok
+}
+
+// validLabelToken checks a label name or value's length and characters.
+func validLabelToken(s string, allowEmpty bool) bool {
+	if len(s) > maxLabelLength || (s == "" && !allowEmpty) {
+		return false
+	}
+	for _, r := range s {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+// parsePolicyPriority parses a label policy priority; "" is unspecified.
+func parsePolicyPriority(s string) (nanabushv1.Priority, bool) {
+	switch strings.ToLower(s) {
+	case "":
+		return nanabushv1.Priority_PRIORITY_UNSPECIFIED, true
+	case "low":
+		return nanabushv1.Priority_PRIORITY_LOW, true
+	case "normal":
+		return nanabushv1.Priority_PRIORITY_NORMAL, true
+	case "high":
+		return nanabushv1.Priority_PRIORITY_HIGH, true
+	}
+	return nanabushv1.Priority_PRIORITY_UNSPECIFIED, false
+}
+
+// callerPolicy returns the policy of the registered client named by the
+// x-client-id metadata, if any.
+func (s *TranslationService) callerPolicy(ctx context.Context) (ClientPolicy, bool) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(ClientIDMetadataKey)
+	if len(values) == 0 {
+		return ClientPolicy{}, false
+	}
+	s.clientsMutex.RLock()
+	defer s.clientsMutex.RUnlock()
+	client, ok := s.clients[values[0]]
+	if !ok {
+		return ClientPolicy{}, false
+	}
+	return client.Policy, true
+}
+
+// callerPriority returns p, or the calling client's policy priority when p is unspecified.
+func (s *TranslationService) callerPriority(ctx context.Context, p nanabushv1.Priority) nanabushv1.Priority {
+	if p != nanabushv1.Priority_PRIORITY_UNSPECIFIED {
+		return p
+	}
+	if policy, ok := s.callerPolicy(ctx); ok {
+		return policy.Priority
+	}
+	return p
+}
+
+// policyToProto converts a client's policy for RegisterClientResponse,
+// including the quota limits of the namespace it is charged to.
+func (s *TranslationService) policyToProto(policy ClientPolicy, namespace string) *nanabushv1.ClientPolicy {
+	if policy.QuotaNamespace != "" {
+		namespace = policy.QuotaNamespace
+	}
+	resp := &nanabushv1.ClientPolicy{
+		Labels:          policy.Labels,
+		DefaultPriority: policy.Priority,
+		QuotaNamespace:  namespace,
+	}
+	if s.Quotas != nil {
+		limits := s.Quotas.Usage(namespace).Limits
+		resp.RequestsPerMinute = int32(limits.RequestsPerMinute)
+		resp.CharactersPerDay = limits.CharactersPerDay
+	}
+	return resp
+}
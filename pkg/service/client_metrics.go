@@ -0,0 +1,86 @@
+package service
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Reasons a registered client was removed, for iskoces_client_evictions_total.
+const (
+	evictionExpired             = "expired"              // No heartbeat within the cleanup's idle time
+	evictionStale               = "stale"                // No recent heartbeat, found while registering another client
+	evictionReplaced            = "replaced"             // A client with the same name registered again
+	evictionRegistrationExpired = "registration_expired" // Registered more than 24 hours ago
+	evictionStreamClosed        = "stream_closed"        // Its heartbeat stream ended
+)
+
+var (
+	registeredClients = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "iskoces_registered_clients",
+			Help: "Number of registered clients, by namespace",
+		},
+		[]string{"namespace"},
+	)
+
+	clientHeartbeatMissesTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "iskoces_client_heartbeat_misses_total",
+			Help: "Total number of heartbeat intervals registered clients let pass without a heartbeat, counted at their next heartbeat or when they are evicted for missing heartbeats, by namespace",
+		},
+		[]string{"namespace"},
+	)
+
+	clientEvictionsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "iskoces_client_evictions_total",
+			Help: "Total number of registered clients removed, by namespace and reason (expired, stale, replaced, registration_expired, stream_closed)",
+		},
+		[]string{"namespace", "reason"},
+	)
+)
+
+// evictClient removes a registered client for reason and records it. A
+// client evicted for missing heartbeats is charged the heartbeats it
+// missed. Callers hold s.clientsMutex and call updateRegisteredClients
+// afterwards.
+func (s *TranslationService) evictClient(clientID string, client *ClientInfo, reason string, now time.Time) {
+	delete(s.clients, clientID)
+	namespace := namespaceLabel(client.Namespace)
+	clientEvictionsTotal.WithLabelValues(namespace, reason).Inc()
+	if reason == evictionExpired || reason == evictionStale {
+		// None of the heartbeats due since the last one arrived
+		s.recordHeartbeatMisses(client, s.heartbeatsDue(now.Sub(client.LastHeartbeat)))
+	}
+}
+
+// recordHeartbeatMisses charges client with missed heartbeats.
+func (s *TranslationService) recordHeartbeatMisses(client *ClientInfo, missed int) {
+	if missed > 0 {
+		clientHeartbeatMissesTotal.WithLabelValues(namespaceLabel(client.Namespace)).Add(float64(missed))
+	}
+}
+
+// heartbeatsDue returns how many heartbeat intervals fit in elapsed.
+func (s *TranslationService) heartbeatsDue(elapsed time.Duration) int {
+	interval := time.Duration(s.heartbeatInterval()) * time.Second
+	if interval <= 0 {
+		return 0
+	}
+	return int(elapsed / interval)
+}
+
+// updateRegisteredClients sets iskoces_registered_clients from the current
+// clients. Callers hold s.clientsMutex.
+func (s *TranslationService) updateRegisteredClients() {
+	counts := make(map[string]int)
+	for _, client := range s.clients {
+		counts[namespaceLabel(client.Namespace)]++
+	}
+	registeredClients.Reset()
+	for namespace, count := range counts {
+		registeredClients.WithLabelValues(namespace).Set(float64(count))
+	}
+}
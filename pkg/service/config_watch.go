@@ -0,0 +1,186 @@
+package service
+
+import (
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	nanabushv1 "github.com/dasmlab/iskoces/pkg/proto/v1"
+	"github.com/sirupsen/logrus"
+)
+
+// ClientConfig is the configuration pushed to clients via WatchConfig.
+type ClientConfig struct {
+	// Revision increases with every change.
+	Revision int64
+	// HeartbeatIntervalSeconds is the interval clients should heartbeat at.
+	HeartbeatIntervalSeconds int32
+	// MaintenanceMode and MaintenanceMessage announce planned maintenance.
+	MaintenanceMode    bool
+	MaintenanceMessage string
+	// SupportedLanguages lists the languages offered; empty means unrestricted.
+	SupportedLanguages []string
+	// UpdatedAt is when the configuration last changed.
+	UpdatedAt time.Time
+}
+
+// toProto converts the configuration to its wire form.
+func (c ClientConfig) toProto() *nanabushv1.ConfigUpdate {
+	return &nanabushv1.ConfigUpdate{
+		Revision:                 c.Revision,
+		HeartbeatIntervalSeconds: c.HeartbeatIntervalSeconds,
+		MaintenanceMode:          c.MaintenanceMode,
+		MaintenanceMessage:       c.MaintenanceMessage,
+		SupportedLanguages:       c.SupportedLanguages,
+		UpdatedAt:                timestamppb.New(c.UpdatedAt),
+	}
+}
+
+// configHub holds the current ClientConfig and fans changes out to watchers.
+type configHub struct {
+	mu       sync.Mutex
+	current  ClientConfig
+	watchers map[chan ClientConfig]struct{}
+}
+
+// newConfigHub creates a hub with the given heartbeat interval.
+func newConfigHub(heartbeatIntervalSeconds int32) *configHub {
+	return &configHub{
+		current: ClientConfig{
+			Revision:                 1,
+			HeartbeatIntervalSeconds: heartbeatIntervalSeconds,
+			UpdatedAt:                time.Now(),
+		},
+		watchers: make(map[chan ClientConfig]struct{}),
+	}
+}
+
+// get returns the current configuration.
+func (h *configHub) get() ClientConfig {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.current
+}
+
+// update applies fn to the configuration, bumps the revision, and notifies
+// watchers. Watchers only ever need the latest configuration, so a watcher
+// that hasn't consumed the previous update has it replaced.
+func (h *configHub) update(fn func(*ClientConfig)) ClientConfig {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	next := h.current
+	next.SupportedLanguages = append([]string(nil), h.current.SupportedLanguages...)
+	fn(&next)
+	next.Revision = h.current.Revision + 1
+	next.UpdatedAt = time.Now()
+	h.current = next
+
+	for ch := range h.watchers {
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- next
+	}
+	return next
+}
+
+// watch subscribes to configuration changes. The returned function
+// unsubscribes and must be called when the watcher is done.
+func (h *configHub) watch() (<-chan ClientConfig, func()) {
+	ch := make(chan ClientConfig, 1)
+
+	h.mu.Lock()
+	h.watchers[ch] = struct{}{}
+	h.mu.Unlock()
+
+	return ch, func() {
+		h.mu.Lock()
+		delete(h.watchers, ch)
+		h.mu.Unlock()
+	}
+}
+
+// count returns the number of active watchers.
+func (h *configHub) count() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.watchers)
+}
+
+// heartbeatInterval returns the heartbeat interval clients are told to use.
+func (s *TranslationService) heartbeatInterval() int32 {
+	return s.config.get().HeartbeatIntervalSeconds
+}
+
+// ClientConfig returns the current client-facing configuration.
+func (s *TranslationService) ClientConfig() ClientConfig {
+	return s.config.get()
+}
+
+// UpdateClientConfig applies fn to the client-facing configuration and pushes
+// the result to every WatchConfig subscriber.
+func (s *TranslationService) UpdateClientConfig(fn func(*ClientConfig)) ClientConfig {
+	cfg := s.config.update(fn)
+
+	s.Logger.WithFields(logrus.Fields{
+		"revision":            cfg.Revision,
+		"heartbeat_interval":  cfg.HeartbeatIntervalSeconds,
+		"maintenance_mode":    cfg.MaintenanceMode,
+		"supported_languages": len(cfg.SupportedLanguages),
+		"watchers":            s.config.count(),
+	}).Info("Client configuration updated")
+
+	return cfg
+}
+
+// WatchConfig streams the client-facing configuration to a registered client:
+// the current configuration first, then every change until the client disconnects.
+func (s *TranslationService) WatchConfig(req *nanabushv1.WatchConfigRequest, stream nanabushv1.TranslationService_WatchConfigServer) error {
+	if req.ClientId == "" {
+		return invalidArgument("client_id", "is required")
+	}
+	if !s.IsRegistered(req.ClientId) {
+		s.Logger.WithFields(logrus.Fields{
+			"client_id": req.ClientId,
+		}).Warn("WatchConfig from unregistered client")
+		return unregisteredError(req.ClientId)
+	}
+
+	updates, stop := s.config.watch()
+	defer stop()
+
+	s.Logger.WithFields(logrus.Fields{
+		"client_id": req.ClientId,
+	}).Info("Client watching configuration")
+
+	cfg := s.config.get()
+	if err := stream.Send(cfg.toProto()); err != nil {
+		return err
+	}
+	sent := cfg.Revision
+
+	ctx := stream.Context()
+	for {
+		select {
+		case cfg := <-updates:
+			if cfg.Revision <= sent {
+				continue
+			}
+			if err := stream.Send(cfg.toProto()); err != nil {
+				s.Logger.WithError(err).WithFields(logrus.Fields{
+					"client_id": req.ClientId,
+				}).Warn("WatchConfig send error")
+				return err
+			}
+			sent = cfg.Revision
+		case <-ctx.Done():
+			s.Logger.WithFields(logrus.Fields{
+				"client_id": req.ClientId,
+			}).Debug("Client stopped watching configuration")
+			return nil
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	nanabushv1 "github.com/dasmlab/iskoces/pkg/proto/v1"
+	"github.com/dasmlab/iskoces/pkg/translate"
+	"github.com/sirupsen/logrus"
+)
+
+// MaxDocumentSetDocuments is the maximum number of documents in a TranslateDocumentSet request.
+const MaxDocumentSetDocuments = 500
+
+// documentSet holds the shared state of one TranslateDocumentSet call.
+type documentSet struct {
+	service    *TranslationService
+	ctx        context.Context
+	sourceLang string
+	targetLang string
+
+	// memory maps source segments (titles, paragraphs) to their translation,
+	// shared by every document in the set
+	memory map[string]string
+	reused int
+
+	// glossary maps source terms to required target terms; rendering holds
+	// the engine's own translation of each term, replaced in output
+	glossary  map[string]string
+	rendering map[string]string
+	terms     []string // glossary terms, longest first
+}
+
+// TranslateDocumentSet translates an ordered set of documents with a shared
+// translation memory and glossary, streaming progress and results.
+func (s *TranslationService) TranslateDocumentSet(req *nanabushv1.DocumentSetRequest, stream nanabushv1.TranslationService_TranslateDocumentSetServer) error {
+	s.log(stream.Context()).WithFields(logrus.Fields{
+		"job_id":      req.JobId,
+		"namespace":   req.Namespace,
+		"documents":   len(req.Documents),
+		"glossary":    len(req.Glossary),
+		"source_lang": req.SourceLanguage,
+		"target_lang": req.TargetLanguage,
+	}).Info("TranslateDocumentSet request received")
+
+	if req.SourceLanguage == "" {
+		return invalidArgument("source_language", "is required")
+	}
+	if req.TargetLanguage == "" {
+		return invalidArgument("target_language", "is required")
+	}
+	if len(req.Documents) == 0 {
+		return invalidArgument("documents", "must not be empty")
+	}
+	if len(req.Documents) > MaxDocumentSetDocuments {
+		return invalidArgument("documents",
+			fmt.Sprintf("has %d entries, exceeding the limit of %d", len(req.Documents), MaxDocumentSetDocuments))
+	}
+
+	priority := translate.PriorityNormal
+	switch s.callerPriority(stream.Context(), req.Priority) {
+	case nanabushv1.Priority_PRIORITY_LOW:
+		priority = translate.PriorityLow
+	case nanabushv1.Priority_PRIORITY_HIGH:
+		priority = translate.PriorityHigh
+	}
+
+	set := &documentSet{
+		service:    s,
+		ctx:        translate.WithPriority(stream.Context(), priority),
+		sourceLang: s.LanguageMapper.ToBackendCode(req.SourceLanguage),
+		targetLang: s.LanguageMapper.ToBackendCode(req.TargetLanguage),
+		memory:     make(map[string]string),
+		glossary:   req.Glossary,
+		rendering:  make(map[string]string),
+	}
+
+	startTime := time.Now()
+	total := int32(len(req.Documents))
+	totalBytes := 0
+
+	if err := set.prepareGlossary(); err != nil {
+		s.log(stream.Context()).WithError(err).WithFields(logrus.Fields{
+			"job_id": req.JobId,
+		}).Error("TranslateDocumentSet: glossary translation failed")
+		return engineError(err, "glossary translation failed", set.sourceLang, set.targetLang)
+	}
+
+	for i, doc := range req.Documents {
+		if err := stream.Send(&nanabushv1.DocumentSetProgress{
+			JobId:              req.JobId,
+			ProgressPercent:    int32(i) * 100 / total,
+			ProgressMessage:    fmt.Sprintf("Translating document %d/%d...", i+1, total),
+			DocumentsCompleted: int32(i),
+			DocumentsTotal:     total,
+			SegmentsReused:     int32(set.reused),
+		}); err != nil {
+			return status.Error(codes.Unavailable, fmt.Sprintf("failed to send progress: %v", err))
+		}
+
+		translated, err := set.translateDocument(doc)
+		if err != nil {
+			s.log(stream.Context()).WithError(err).WithFields(logrus.Fields{
+				"job_id":         req.JobId,
+				"document_index": i,
+			}).Error("TranslateDocumentSet: document translation failed")
+			return engineError(err, fmt.Sprintf("document %d translation failed", i), set.sourceLang, set.targetLang)
+		}
+		if req.LocalizeFormats && s.Localizer != nil {
+			translated.Title = s.Localizer.Localize(translated.Title, set.sourceLang, req.TargetLanguage)
+			translated.Markdown = s.Localizer.Localize(translated.Markdown, set.sourceLang, req.TargetLanguage)
+		}
+		totalBytes += len(doc.Title) + len(doc.Markdown)
+
+		if err := stream.Send(&nanabushv1.DocumentSetProgress{
+			JobId:              req.JobId,
+			ProgressPercent:    int32(i+1) * 100 / total,
+			ProgressMessage:    fmt.Sprintf("Translated document %d/%d", i+1, total),
+			DocumentsCompleted: int32(i + 1),
+			DocumentsTotal:     total,
+			DocumentIndex:      int32(i),
+			TranslatedDocument: translated,
+			SegmentsReused:     int32(set.reused),
+		}); err != nil {
+			return status.Error(codes.Unavailable, fmt.Sprintf("failed to send document: %v", err))
+		}
+	}
+
+	elapsed := time.Since(startTime)
+	if s.Estimator != nil {
+		s.Estimator.Observe(totalBytes, elapsed)
+	}
+
+	s.log(stream.Context()).WithFields(logrus.Fields{
+		"job_id":          req.JobId,
+		"documents":       total,
+		"unique_segments": len(set.memory),
+		"segments_reused": set.reused,
+		"inference_time":  elapsed.Seconds(),
+	}).Info("TranslateDocumentSet completed")
+
+	return stream.Send(&nanabushv1.DocumentSetProgress{
+		JobId:                req.JobId,
+		ProgressPercent:      100,
+		ProgressMessage:      "Translation completed",
+		DocumentsCompleted:   total,
+		DocumentsTotal:       total,
+		SegmentsReused:       int32(set.reused),
+		IsFinal:              true,
+		InferenceTimeSeconds: elapsed.Seconds(),
+	})
+}
+
+// prepareGlossary translates each glossary term on its own to learn how the
+// engine renders it, so those renderings can be replaced with the required
+// target terms.
+func (set *documentSet) prepareGlossary() error {
+	for term := range set.glossary {
+		if strings.TrimSpace(term) != "" {
+			set.terms = append(set.terms, term)
+		}
+	}
+	if len(set.terms) == 0 {
+		return nil
+	}
+	sort.Slice(set.terms, func(i, j int) bool {
+		if len(set.terms[i]) != len(set.terms[j]) {
+			return len(set.terms[i]) > len(set.terms[j])
+		}
+		return set.terms[i] < set.terms[j]
+	})
+
+	renderings, err := translate.TranslateBatch(set.ctx, set.service.Translator, set.terms, set.sourceLang, set.targetLang)
+	if err != nil {
+		return err
+	}
+	for i, term := range set.terms {
+		set.rendering[term] = renderings[i]
+	}
+	return nil
+}
+
+// translateDocument translates a document's title and paragraphs, reusing
+// earlier translations from the set's memory and applying the glossary.
+func (set *documentSet) translateDocument(doc *nanabushv1.DocumentContent) (*nanabushv1.DocumentContent, error) {
+	if doc == nil {
+		return &nanabushv1.DocumentContent{}, nil
+	}
+
+	paragraphs := strings.Split(doc.Markdown, "\n\n")
+	segments := append([]string{doc.Title}, paragraphs...)
+
+	// Translate only segments the set hasn't seen, in one batch
+	var pending []string
+	queued := make(map[string]bool)
+	for _, seg := range segments {
+		if strings.TrimSpace(seg) == "" {
+			continue
+		}
+		if _, ok := set.memory[seg]; ok {
+			set.reused++
+			continue
+		}
+		if !queued[seg] {
+			queued[seg] = true
+			pending = append(pending, seg)
+		}
+	}
+	if len(pending) > 0 {
+		translated, err := translate.TranslateBatch(set.ctx, set.service.Translator, pending, set.sourceLang, set.targetLang)
+		if err != nil {
+			return nil, err
+		}
+		for i, seg := range pending {
+			set.memory[seg] = set.applyGlossary(seg, translated[i])
+		}
+	}
+
+	lookup := func(seg string) string {
+		if strings.TrimSpace(seg) == "" {
+			return seg
+		}
+		return set.memory[seg]
+	}
+
+	out := make([]string, len(paragraphs))
+	for i, para := range paragraphs {
+		out[i] = lookup(para)
+	}
+	return &nanabushv1.DocumentContent{
+		Title:    lookup(doc.Title),
+		Markdown: strings.Join(out, "\n\n"),
+		Slug:     doc.Slug,
+		Metadata: doc.Metadata,
+	}, nil
+}
+
+// applyGlossary replaces the engine's rendering of each glossary term that
+// appears in source with the required target term.
+func (set *documentSet) applyGlossary(source, translated string) string {
+	for _, term := range set.terms {
+		rendering := set.rendering[term]
+		target := set.glossary[term]
+		if rendering == "" || rendering == target || !strings.Contains(source, term) {
+			continue
+		}
+		translated = strings.ReplaceAll(translated, rendering, target)
+	}
+	return translated
+}
@@ -0,0 +1,180 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/dasmlab/iskoces/pkg/translate"
+	"github.com/sirupsen/logrus"
+)
+
+// ReasonDraining is the ErrorInfo reason for calls rejected while the server drains.
+const ReasonDraining = "DRAINING"
+
+// DefaultDrainRetryDelay is the retry hint given to calls rejected while draining.
+const DefaultDrainRetryDelay = 5 * time.Second
+
+// drainMethods are the RPCs that start new work and are rejected while
+// draining. Status, result, cancel, and heartbeat calls keep working so
+// in-flight jobs can finish and be collected.
+var drainMethods = map[string]bool{
+	"/nanabush.v1.TranslationService/RegisterClient":       true,
+	"/nanabush.v1.TranslationService/CheckTitle":           true,
+	"/nanabush.v1.TranslationService/Translate":            true,
+	"/nanabush.v1.TranslationService/TranslateStream":      true,
+	"/nanabush.v1.TranslationService/DetectLanguage":       true,
+	"/nanabush.v1.TranslationService/SubmitTranslation":    true,
+	"/nanabush.v1.TranslationService/BatchTranslate":       true,
+	"/nanabush.v1.TranslationService/TranslateDocumentSet": true,
+	"/nanabush.v2.TranslationService/SubmitTranslation":    true,
+	"/nanabush.v2.TranslationService/SubmitBatch":          true,
+	"/nanabush.v2.TranslationService/Translate":            true,
+}
+
+// drainState records whether the server is draining.
+type drainState struct {
+	mu       sync.RWMutex
+	draining bool
+	reason   string
+	since    time.Time
+}
+
+// DrainStatus reports the drain state and the work still in flight.
+type DrainStatus struct {
+	Draining      bool
+	Reason        string
+	Since         time.Time
+	InFlightJobs  int
+	InFlightBytes int
+}
+
+// SetDraining enters (or leaves) drain mode: new translation work and
+// registrations are rejected with UNAVAILABLE and a retry hint while jobs
+// already accepted run to completion. Watching clients are sent a maintenance
+// notice. Returns false if the server was already in the requested state.
+func (s *TranslationService) SetDraining(draining bool, reason string) bool {
+	s.drain.mu.Lock()
+	if s.drain.draining == draining {
+		s.drain.mu.Unlock()
+		return false
+	}
+	s.drain.draining = draining
+	s.drain.reason = reason
+	s.drain.since = time.Now()
+	s.drain.mu.Unlock()
+
+	s.UpdateClientConfig(func(c *ClientConfig) {
+		c.MaintenanceMode = draining
+		c.MaintenanceMessage = ""
+		if draining {
+			c.MaintenanceMessage = "Server is draining; reconnect to another instance"
+			if reason != "" {
+				c.MaintenanceMessage += ": " + reason
+			}
+		}
+	})
+	if s.OnDrainChange != nil {
+		s.OnDrainChange(draining)
+	}
+
+	st := s.DrainStatus()
+	fields := logrus.Fields{
+		"reason":          reason,
+		"in_flight_jobs":  st.InFlightJobs,
+		"in_flight_bytes": st.InFlightBytes,
+	}
+	if draining {
+		s.Logger.WithFields(fields).Warn("Drain mode enabled; rejecting new translation work")
+	} else {
+		s.Logger.WithFields(fields).Info("Drain mode disabled; accepting translation work")
+	}
+	return true
+}
+
+// Draining reports whether the server is draining.
+func (s *TranslationService) Draining() bool {
+	s.drain.mu.RLock()
+	defer s.drain.mu.RUnlock()
+	return s.drain.draining
+}
+
+// DrainStatus returns the drain state and the unfinished jobs.
+func (s *TranslationService) DrainStatus() DrainStatus {
+	s.drain.mu.RLock()
+	st := DrainStatus{
+		Draining: s.drain.draining,
+		Reason:   s.drain.reason,
+		Since:    s.drain.since,
+	}
+	s.drain.mu.RUnlock()
+
+	if s.JobQueue != nil {
+		st.InFlightJobs, st.InFlightBytes = s.JobQueue.Backlog(translate.PriorityLow)
+	}
+	return st
+}
+
+// drainRetryDelay returns the retry hint for rejected calls.
+func (s *TranslationService) drainRetryDelay() time.Duration {
+	if s.DrainRetryDelay > 0 {
+		return s.DrainRetryDelay
+	}
+	return DefaultDrainRetryDelay
+}
+
+// drainingError returns the UNAVAILABLE error for calls rejected while
+// draining, with ErrorInfo and a RetryInfo hint.
+func (s *TranslationService) drainingError() error {
+	delay := s.drainRetryDelay()
+	st := status.New(codes.Unavailable, "server is draining and not accepting new work; retry on another instance")
+	if detailed, err := st.WithDetails(
+		&errdetails.ErrorInfo{
+			Reason: ReasonDraining,
+			Domain: errorDomain,
+			Metadata: map[string]string{
+				"retryable": "true",
+			},
+		},
+		&errdetails.RetryInfo{RetryDelay: durationpb.New(delay)},
+	); err == nil {
+		st = detailed
+	}
+	return st.Err()
+}
+
+// DrainUnaryInterceptor rejects registrations and new translation calls while
+// the server drains.
+func DrainUnaryInterceptor(svc *TranslationService) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if drainMethods[info.FullMethod] && svc.Draining() {
+			_ = grpc.SetHeader(ctx, retryAfterHeader(svc.drainRetryDelay()))
+			svc.Logger.WithFields(logrus.Fields{
+				"method": info.FullMethod,
+			}).Debug("Rejected call while draining")
+			return nil, svc.drainingError()
+		}
+		return handler(ctx, req)
+	}
+}
+
+// DrainStreamInterceptor rejects new translation streams while the server
+// drains. Streams opened before draining started continue.
+func DrainStreamInterceptor(svc *TranslationService) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if drainMethods[info.FullMethod] && svc.Draining() {
+			_ = ss.SetHeader(retryAfterHeader(svc.drainRetryDelay()))
+			svc.Logger.WithFields(logrus.Fields{
+				"method": info.FullMethod,
+			}).Debug("Rejected stream while draining")
+			return svc.drainingError()
+		}
+		return handler(srv, ss)
+	}
+}
@@ -0,0 +1,144 @@
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/dasmlab/iskoces/pkg/translate"
+)
+
+// errorDomain is the google.rpc.ErrorInfo domain for errors raised by this service.
+const errorDomain = "iskoces.dasmlab.io"
+
+// Error reasons reported in google.rpc.ErrorInfo. Clients should switch on
+// these rather than parsing error messages.
+const (
+	ReasonInvalidRequest          = "INVALID_REQUEST"
+	ReasonUnsupportedLanguagePair = "UNSUPPORTED_LANGUAGE_PAIR"
+	ReasonEngineUnavailable       = "ENGINE_UNAVAILABLE"
+	ReasonEngineOverloaded        = "ENGINE_OVERLOADED"
+	ReasonEngineTimeout           = "ENGINE_TIMEOUT"
+	ReasonEngineError             = "ENGINE_ERROR"
+	ReasonCancelled               = "CANCELLED"
+	ReasonQueueError              = "QUEUE_ERROR"
+)
+
+// classStatus maps an engine error class to a gRPC code and ErrorInfo reason.
+func classStatus(class translate.ErrorClass) (codes.Code, string) {
+	switch class {
+	case translate.ErrorClassUnsupportedLanguage:
+		return codes.InvalidArgument, ReasonUnsupportedLanguagePair
+	case translate.ErrorClassUnavailable:
+		return codes.Unavailable, ReasonEngineUnavailable
+	case translate.ErrorClassOverloaded:
+		return codes.ResourceExhausted, ReasonEngineOverloaded
+	case translate.ErrorClassTimeout:
+		return codes.DeadlineExceeded, ReasonEngineTimeout
+	case translate.ErrorClassCancelled:
+		return codes.Canceled, ReasonCancelled
+	default:
+		return codes.Internal, ReasonEngineError
+	}
+}
+
+// engineError converts an error from the translation backend into a status
+// error carrying ErrorInfo (reason, retryability, engine error class), a
+// RetryInfo with the estimated wait when the engine is overloaded and, for
+// unsupported language pairs, BadRequest violations on the language fields.
+func engineError(err error, message, sourceLang, targetLang string) error {
+	class := translate.ClassifyError(err)
+	retryAfter, _ := translate.RetryAfter(err)
+	return statusError(class, fmt.Sprintf("%s: %v", message, err), sourceLang, targetLang, retryAfter)
+}
+
+// classError builds the status error for an engine error class.
+func classError(class translate.ErrorClass, message, sourceLang, targetLang string) error {
+	return statusError(class, message, sourceLang, targetLang, 0)
+}
+
+// statusError builds the status error for an engine error class, adding a
+// RetryInfo detail if retryAfter is set.
+func statusError(class translate.ErrorClass, message, sourceLang, targetLang string, retryAfter time.Duration) error {
+	code, reason := classStatus(class)
+	st := status.New(code, message)
+
+	info := &errdetails.ErrorInfo{
+		Reason: reason,
+		Domain: errorDomain,
+		Metadata: map[string]string{
+			"retryable":          strconv.FormatBool(class.Retryable()),
+			"engine_error_class": string(class),
+		},
+	}
+	if sourceLang != "" {
+		info.Metadata["source_language"] = sourceLang
+	}
+	if targetLang != "" {
+		info.Metadata["target_language"] = targetLang
+	}
+
+	var detailed *status.Status
+	var detailErr error
+	if class == translate.ErrorClassUnsupportedLanguage {
+		detailed, detailErr = st.WithDetails(info, &errdetails.BadRequest{
+			FieldViolations: []*errdetails.BadRequest_FieldViolation{
+				{Field: "source_language", Description: fmt.Sprintf("%q -> %q is not supported by the engine", sourceLang, targetLang)},
+				{Field: "target_language", Description: fmt.Sprintf("%q -> %q is not supported by the engine", sourceLang, targetLang)},
+			},
+		})
+	} else if retryAfter > 0 {
+		detailed, detailErr = st.WithDetails(info, &errdetails.RetryInfo{RetryDelay: durationpb.New(retryAfter)})
+	} else {
+		detailed, detailErr = st.WithDetails(info)
+	}
+	if detailErr == nil {
+		st = detailed
+	}
+	return st.Err()
+}
+
+// invalidArgument returns an InvalidArgument error with ErrorInfo and a
+// BadRequest violation naming the offending field.
+func invalidArgument(field, description string) error {
+	st := status.New(codes.InvalidArgument, fmt.Sprintf("%s %s", field, description))
+	if detailed, err := st.WithDetails(
+		&errdetails.ErrorInfo{
+			Reason: ReasonInvalidRequest,
+			Domain: errorDomain,
+			Metadata: map[string]string{
+				"retryable": "false",
+				"field":     field,
+			},
+		},
+		&errdetails.BadRequest{
+			FieldViolations: []*errdetails.BadRequest_FieldViolation{
+				{Field: field, Description: description},
+			},
+		},
+	); err == nil {
+		st = detailed
+	}
+	return st.Err()
+}
+
+// internalError returns an Internal error with ErrorInfo for failures that
+// aren't caused by the engine (e.g. the job queue).
+func internalError(reason, message string) error {
+	st := status.New(codes.Internal, message)
+	if detailed, err := st.WithDetails(&errdetails.ErrorInfo{
+		Reason: reason,
+		Domain: errorDomain,
+		Metadata: map[string]string{
+			"retryable": "true",
+		},
+	}); err == nil {
+		st = detailed
+	}
+	return st.Err()
+}
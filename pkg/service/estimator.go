@@ -0,0 +1,75 @@
+package service
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultBytesPerSecond is the assumed per-worker throughput before any
+	// translation has been observed. Deliberately conservative for CPU-only MT.
+	defaultBytesPerSecond = 500.0
+
+	// estimatorSmoothing is the EWMA weight given to each new observation.
+	estimatorSmoothing = 0.2
+
+	// requestOverhead is the fixed cost of a translation request
+	// (worker dispatch, socket round trip, model warm-up).
+	requestOverhead = 500 * time.Millisecond
+)
+
+// CompletionEstimator predicts how long a translation will take from
+// observed per-worker throughput and the amount of work already queued.
+type CompletionEstimator struct {
+	mu             sync.Mutex
+	bytesPerSecond float64
+	samples        int
+}
+
+// NewCompletionEstimator creates an estimator seeded with the default throughput.
+func NewCompletionEstimator() *CompletionEstimator {
+	return &CompletionEstimator{
+		bytesPerSecond: defaultBytesPerSecond,
+	}
+}
+
+// Observe records a completed translation of size bytes that took d.
+func (e *CompletionEstimator) Observe(bytes int, d time.Duration) {
+	if bytes <= 0 || d <= 0 {
+		return
+	}
+	rate := float64(bytes) / d.Seconds()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.samples == 0 {
+		e.bytesPerSecond = rate
+	} else {
+		e.bytesPerSecond = estimatorSmoothing*rate + (1-estimatorSmoothing)*e.bytesPerSecond
+	}
+	e.samples++
+}
+
+// Estimate returns the expected wait before a request of docBytes starts
+// (the backlog draining across workers) and the total time until it completes.
+func (e *CompletionEstimator) Estimate(docBytes, backlogBytes, workers int) (wait, total time.Duration) {
+	e.mu.Lock()
+	rate := e.bytesPerSecond
+	e.mu.Unlock()
+
+	if workers < 1 {
+		workers = 1
+	}
+
+	wait = time.Duration(float64(backlogBytes) / (rate * float64(workers)) * float64(time.Second))
+	run := requestOverhead + time.Duration(float64(docBytes)/rate*float64(time.Second))
+
+	return wait, wait + run
+}
+
+// roundUpSeconds converts a duration to whole seconds, rounding up.
+func roundUpSeconds(d time.Duration) int32 {
+	return int32(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,271 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	nanabushv1 "github.com/dasmlab/iskoces/pkg/proto/v1"
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultMaxFeedbackRecords is the number of feedback records kept in memory.
+const DefaultMaxFeedbackRecords = 10000
+
+// MaxFeedbackRating is the highest accepted feedback rating.
+const MaxFeedbackRating = 5
+
+var feedbackTotal = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "iskoces_translation_feedback_total",
+		Help: "Total number of translation feedback reports, by engine and rating (0 = not rated)",
+	},
+	[]string{"engine", "rating", "corrected"},
+)
+
+// FeedbackRecord is one stored piece of post-edit feedback. It is the line
+// format of the feedback file (JSON lines).
+type FeedbackRecord struct {
+	ID                 string    `json:"id"`
+	JobID              string    `json:"job_id"`
+	SegmentIndex       int       `json:"segment_index"`
+	Namespace          string    `json:"namespace,omitempty"`
+	SourceLanguage     string    `json:"source_language,omitempty"`
+	TargetLanguage     string    `json:"target_language,omitempty"`
+	SourceText         string    `json:"source_text,omitempty"`
+	MachineTranslation string    `json:"machine_translation,omitempty"`
+	CorrectedText      string    `json:"corrected_text,omitempty"`
+	Rating             int       `json:"rating,omitempty"`
+	Reviewer           string    `json:"reviewer,omitempty"`
+	Comment            string    `json:"comment,omitempty"`
+	Engine             string    `json:"engine,omitempty"`
+	ReceivedAt         time.Time `json:"received_at"`
+}
+
+// FeedbackStore keeps recent feedback in memory and, when backed by a file,
+// appends every record to it so feedback survives restarts.
+type FeedbackStore struct {
+	mu      sync.RWMutex
+	records []FeedbackRecord
+	max     int
+	file    *os.File
+}
+
+// NewFeedbackStore creates a feedback store. If path is non-empty, existing
+// records are loaded from it and new records are appended to it.
+func NewFeedbackStore(path string, maxRecords int) (*FeedbackStore, error) {
+	if maxRecords <= 0 {
+		maxRecords = DefaultMaxFeedbackRecords
+	}
+	store := &FeedbackStore{max: maxRecords}
+	if path == "" {
+		return store, nil
+	}
+
+	if in, err := os.Open(path); err == nil {
+		scanner := bufio.NewScanner(in)
+		scanner.Buffer(make([]byte, 0, 64*1024), DefaultMaxDocumentBytes*4)
+		line := 0
+		for scanner.Scan() {
+			line++
+			if strings.TrimSpace(scanner.Text()) == "" {
+				continue
+			}
+			var rec FeedbackRecord
+			if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+				in.Close()
+				return nil, fmt.Errorf("failed to parse feedback file %s line %d: %w", path, line, err)
+			}
+			store.append(rec)
+		}
+		in.Close()
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read feedback file: %w", err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to open feedback file: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open feedback file for writing: %w", err)
+	}
+	store.file = file
+	return store, nil
+}
+
+// Add stores a record, writing it to the feedback file first if there is one.
+func (f *FeedbackStore) Add(rec FeedbackRecord) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file != nil {
+		line, err := json.Marshal(rec)
+		if err != nil {
+			return fmt.Errorf("failed to encode feedback: %w", err)
+		}
+		if _, err := f.file.Write(append(line, '\n')); err != nil {
+			return fmt.Errorf("failed to write feedback: %w", err)
+		}
+	}
+	f.append(rec)
+	return nil
+}
+
+// append adds a record to memory, dropping the oldest beyond the limit. Callers hold f.mu.
+func (f *FeedbackStore) append(rec FeedbackRecord) {
+	f.records = append(f.records, rec)
+	if over := len(f.records) - f.max; over > 0 {
+		f.records = append(f.records[:0:0], f.records[over:]...)
+	}
+}
+
+// Records returns a copy of the feedback held in memory, oldest first.
+func (f *FeedbackStore) Records() []FeedbackRecord {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return append([]FeedbackRecord(nil), f.records...)
+}
+
+// Close closes the feedback file, if any.
+func (f *FeedbackStore) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+// ReportTranslationFeedback stores a reviewer's correction and/or rating for
+// one segment of a translation.
+func (s *TranslationService) ReportTranslationFeedback(ctx context.Context, req *nanabushv1.TranslationFeedback) (*nanabushv1.ReportTranslationFeedbackResponse, error) {
+	s.log(ctx).WithFields(logrus.Fields{
+		"job_id":        req.JobId,
+		"segment_index": req.SegmentIndex,
+		"namespace":     req.Namespace,
+		"rating":        req.Rating,
+		"corrected":     req.CorrectedText != "",
+	}).Info("ReportTranslationFeedback request received")
+
+	if req.JobId == "" {
+		return nil, invalidArgument("job_id", "is required")
+	}
+	if req.SegmentIndex < 0 {
+		return nil, invalidArgument("segment_index", "must not be negative")
+	}
+	if req.Rating < 0 || req.Rating > MaxFeedbackRating {
+		return nil, invalidArgument("rating", fmt.Sprintf("must be between 1 and %d (0 = not rated)", MaxFeedbackRating))
+	}
+	if req.CorrectedText == "" && req.Rating == 0 {
+		return nil, invalidArgument("corrected_text", "or rating is required")
+	}
+	if s.Feedback == nil {
+		return nil, status.Error(codes.Unimplemented, "feedback collection is not enabled on this server")
+	}
+
+	rec := FeedbackRecord{
+		ID:                 uuid.New().String(),
+		JobID:              req.JobId,
+		SegmentIndex:       int(req.SegmentIndex),
+		Namespace:          req.Namespace,
+		SourceLanguage:     req.SourceLanguage,
+		TargetLanguage:     req.TargetLanguage,
+		SourceText:         req.SourceText,
+		MachineTranslation: req.MachineTranslation,
+		CorrectedText:      req.CorrectedText,
+		Rating:             int(req.Rating),
+		Reviewer:           req.Reviewer,
+		Comment:            req.Comment,
+		Engine:             strings.Join(s.Info.Engines, ","),
+		ReceivedAt:         time.Now().UTC(),
+	}
+
+	// Jobs in namespaces the caller can't use aren't matched, so their
+	// content isn't copied into the record
+	matched := false
+	if s.JobQueue != nil {
+		if job, err := s.JobQueue.FindJob(req.JobId, func(namespace string) bool {
+			return callerAllows(ctx, namespace)
+		}); err == nil {
+			matched = true
+			fillFeedbackFromJob(&rec, job)
+		}
+	}
+
+	if err := s.Feedback.Add(rec); err != nil {
+		s.log(ctx).WithError(err).WithFields(logrus.Fields{
+			"job_id": req.JobId,
+		}).Error("Failed to store translation feedback")
+		return nil, status.Error(codes.Internal, fmt.Sprintf("failed to store feedback: %v", err))
+	}
+	feedbackTotal.WithLabelValues(rec.Engine, strconv.Itoa(rec.Rating), strconv.FormatBool(rec.CorrectedText != "")).Inc()
+
+	s.log(ctx).WithFields(logrus.Fields{
+		"feedback_id": rec.ID,
+		"job_id":      rec.JobID,
+		"matched_job": matched,
+	}).Info("Translation feedback stored")
+
+	return &nanabushv1.ReportTranslationFeedbackResponse{
+		FeedbackId: rec.ID,
+		ReceivedAt: timestamppb.New(rec.ReceivedAt),
+		MatchedJob: matched,
+	}, nil
+}
+
+// fillFeedbackFromJob fills the record's empty languages, source text, and
+// machine translation from the job. Segment 0 is the title; segment N is the
+// Nth blank-line separated paragraph of the markdown.
+func fillFeedbackFromJob(rec *FeedbackRecord, job *TranslationJob) {
+	translatedTitle, translatedMarkdown, _ := job.Result()
+
+	job.mu.RLock()
+	defer job.mu.RUnlock()
+
+	if rec.SourceLanguage == "" {
+		rec.SourceLanguage = job.SourceLang
+	}
+	if rec.TargetLanguage == "" {
+		rec.TargetLanguage = job.TargetLang
+	}
+
+	source, machine := job.Title, translatedTitle
+	if rec.SegmentIndex > 0 {
+		source, machine = "", ""
+		if job.Document != nil {
+			source = paragraphAt(job.Document.Markdown, rec.SegmentIndex-1)
+			machine = paragraphAt(translatedMarkdown, rec.SegmentIndex-1)
+		}
+	}
+	if rec.SourceText == "" {
+		rec.SourceText = source
+	}
+	if rec.MachineTranslation == "" && job.Status == JobStatusCompleted {
+		rec.MachineTranslation = machine
+	}
+}
+
+// paragraphAt returns the i-th blank-line separated paragraph of text, or "".
+func paragraphAt(text string, i int) string {
+	paragraphs := strings.Split(text, "\n\n")
+	if i < 0 || i >= len(paragraphs) {
+		return ""
+	}
+	return paragraphs[i]
+}
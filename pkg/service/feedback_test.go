@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	nanabushv1 "github.com/dasmlab/iskoces/pkg/proto/v1"
+)
+
+func TestReportTranslationFeedbackNamespaces(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	queue := NewJobQueue(logger)
+	now := time.Now()
+	for _, job := range []*TranslationJob{
+		{ID: "job-a", RequestID: "client-1", Namespace: "a", Title: "Title A", SourceLang: "en", TargetLang: "fr", CreatedAt: now},
+		{ID: "job-b", RequestID: "client-1", Namespace: "b", Title: "Title B", SourceLang: "en", TargetLang: "de", CreatedAt: now.Add(time.Second)},
+	} {
+		queue.jobs[job.ID] = job
+	}
+	feedback, err := NewFeedbackStore("", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &TranslationService{Logger: logger, JobQueue: queue, Feedback: feedback}
+
+	tests := []struct {
+		name       string
+		namespaces []string
+		jobID      string
+		matched    bool
+		source     string
+	}{
+		{name: "own job", namespaces: []string{"a"}, jobID: "job-a", matched: true, source: "Title A"},
+		{name: "other namespace's job", namespaces: []string{"a"}, jobID: "job-b"},
+		{name: "client ID in own namespace", namespaces: []string{"a"}, jobID: "client-1", matched: true, source: "Title A"},
+		{name: "client ID in other namespace", namespaces: []string{"c"}, jobID: "client-1"},
+		{name: "unbound caller", jobID: "client-1", matched: true, source: "Title B"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := WithCallerNamespaces(context.Background(), tt.namespaces)
+			resp, err := s.ReportTranslationFeedback(ctx, &nanabushv1.TranslationFeedback{
+				JobId:  tt.jobID,
+				Rating: 4,
+			})
+			if err != nil {
+				t.Fatalf("ReportTranslationFeedback() = %v", err)
+			}
+			if resp.MatchedJob != tt.matched {
+				t.Errorf("matched_job = %v, want %v", resp.MatchedJob, tt.matched)
+			}
+			records := feedback.records
+			rec := records[len(records)-1]
+			if rec.SourceText != tt.source {
+				t.Errorf("source text = %q, want %q", rec.SourceText, tt.source)
+			}
+			if !tt.matched && rec.TargetLanguage != "" {
+				t.Errorf("target language = %q copied from another namespace's job", rec.TargetLanguage)
+			}
+		})
+	}
+}
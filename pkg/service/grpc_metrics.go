@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	grpcRequestsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "iskoces_grpc_requests_total",
+			Help: "Total number of gRPC calls handled, by service, method, type (unary, client_stream, server_stream, bidi_stream) and status code",
+		},
+		[]string{"service", "method", "type", "code"},
+	)
+
+	grpcRequestDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "iskoces_grpc_request_duration_seconds",
+			Help:    "Duration of gRPC calls in seconds, until the handler returns (the whole stream for streaming calls)",
+			Buckets: []float64{0.005, 0.01, 0.05, 0.1, 0.5, 1.0, 2.0, 5.0, 10.0, 30.0, 60.0, 300.0},
+		},
+		[]string{"service", "method", "type"},
+	)
+
+	grpcRequestsInFlight = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "iskoces_grpc_requests_in_flight",
+			Help: "Number of gRPC calls being handled",
+		},
+		[]string{"service", "method", "type"},
+	)
+
+	grpcMessageSize = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "iskoces_grpc_message_size_bytes",
+			Help:    "Encoded size of gRPC messages, by direction (received, sent)",
+			Buckets: []float64{100, 1000, 10000, 100000, 1000000, 4000000, 16000000},
+		},
+		[]string{"service", "method", "direction"},
+	)
+)
+
+// MetricsUnaryInterceptor records Prometheus metrics for each call: its
+// status code, duration and message sizes, and the calls in flight. It
+// should run outside the recovery interceptor, so calls that panicked are
+// counted as INTERNAL.
+func MetricsUnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		service, method := splitMethod(info.FullMethod)
+		inFlight := grpcRequestsInFlight.WithLabelValues(service, method, "unary")
+		inFlight.Inc()
+		defer inFlight.Dec()
+
+		grpcMessageSize.WithLabelValues(service, method, "received").Observe(float64(messageSize(req)))
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		grpcRequestDuration.WithLabelValues(service, method, "unary").Observe(time.Since(start).Seconds())
+		grpcRequestsTotal.WithLabelValues(service, method, "unary", status.Code(err).String()).Inc()
+		if err == nil {
+			grpcMessageSize.WithLabelValues(service, method, "sent").Observe(float64(messageSize(resp)))
+		}
+		return resp, err
+	}
+}
+
+// MetricsStreamInterceptor records Prometheus metrics for each stream, as
+// MetricsUnaryInterceptor does for unary calls, observing the size of every
+// message received and sent.
+func MetricsStreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		service, method := splitMethod(info.FullMethod)
+		kind := streamType(info)
+		inFlight := grpcRequestsInFlight.WithLabelValues(service, method, kind)
+		inFlight.Inc()
+		defer inFlight.Dec()
+
+		metered := &meteredStream{
+			ServerStream: ss,
+			received:     grpcMessageSize.WithLabelValues(service, method, "received"),
+			sent:         grpcMessageSize.WithLabelValues(service, method, "sent"),
+		}
+		start := time.Now()
+		err := handler(srv, metered)
+		grpcRequestDuration.WithLabelValues(service, method, kind).Observe(time.Since(start).Seconds())
+		grpcRequestsTotal.WithLabelValues(service, method, kind, status.Code(err).String()).Inc()
+		return err
+	}
+}
+
+// splitMethod splits a full method name, /package.Service/Method, into the
+// service and method.
+func splitMethod(fullMethod string) (service, method string) {
+	service, method, ok := strings.Cut(strings.TrimPrefix(fullMethod, "/"), "/")
+	if !ok {
+		return "unknown", service
+	}
+	return service, method
+}
+
+// streamType names the kind of a streaming call for metric labels.
+func streamType(info *grpc.StreamServerInfo) string {
+	switch {
+	case info.IsClientStream && info.IsServerStream:
+		return "bidi_stream"
+	case info.IsClientStream:
+		return "client_stream"
+	default:
+		return "server_stream"
+	}
+}
+
+// meteredStream observes the size of each message on a stream.
+type meteredStream struct {
+	grpc.ServerStream
+	received, sent prometheus.Observer
+}
+
+// RecvMsg receives a message and observes its size.
+func (s *meteredStream) RecvMsg(m any) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		s.received.Observe(float64(messageSize(m)))
+	}
+	return err
+}
+
+// SendMsg sends a message and observes its size.
+func (s *meteredStream) SendMsg(m any) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		s.sent.Observe(float64(messageSize(m)))
+	}
+	return err
+}
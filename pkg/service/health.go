@@ -0,0 +1,319 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+
+	longrunningpb "github.com/dasmlab/iskoces/pkg/proto/google/longrunning"
+	nanabushv1 "github.com/dasmlab/iskoces/pkg/proto/v1"
+	nanabushv2 "github.com/dasmlab/iskoces/pkg/proto/v2"
+	"github.com/dasmlab/iskoces/pkg/translate"
+	"github.com/sirupsen/logrus"
+)
+
+// HealthServiceEngine is the health check service name reporting whether the
+// translation engine is ready. It has no gRPC service of its own.
+const HealthServiceEngine = "iskoces.engine"
+
+const (
+	// DefaultUnhealthyThreshold is how many engine checks must fail in a row
+	// before the engine is marked unhealthy.
+	DefaultUnhealthyThreshold = 3
+
+	// DefaultHealthyThreshold is how many engine checks must succeed in a
+	// row before an unhealthy engine is marked healthy again.
+	DefaultHealthyThreshold = 2
+
+	// healthHistorySize is how many engine check results are kept for the
+	// success ratio and flap detection.
+	healthHistorySize = 20
+
+	// flapTransitions is how many changes of the check result within the
+	// history make the engine flapping.
+	flapTransitions = 4
+)
+
+// HealthThresholds add hysteresis to the engine's status, so a single failed
+// check doesn't flip readiness. The first check sets the status directly.
+type HealthThresholds struct {
+	// Unhealthy is how many checks must fail in a row to mark a healthy
+	// engine unhealthy (default: DefaultUnhealthyThreshold).
+	Unhealthy int
+	// Healthy is how many checks must succeed in a row to mark an unhealthy
+	// engine healthy (default: DefaultHealthyThreshold).
+	Healthy int
+}
+
+// HealthMonitor keeps per-service statuses on the gRPC health server up to date:
+//
+//   - "" (the server as a whole), the AdminService, and google.longrunning
+//     Operations are SERVING while the process is up, so liveness probes don't restart a pod whose engine is
+//     still loading models.
+//   - HealthServiceEngine and the v1/v2 TranslationService follow the engine's
+//     health check, so readiness probes keep traffic away until it can translate.
+//   - The v1/v2 TranslationService are also NOT_SERVING while the server drains.
+type HealthMonitor struct {
+	health     *health.Server
+	translator translate.Translator
+	logger     *logrus.Logger
+	interval   time.Duration
+
+	thresholds HealthThresholds
+
+	mu        sync.Mutex
+	healthy   *bool // engine status after hysteresis; nil before the first check
+	checkedAt time.Time
+	checkErr  error
+	draining  bool
+
+	// History of the check results, oldest first, and the current runs
+	history       []bool
+	failures      int // Consecutive failed checks
+	successes     int // Consecutive successful checks
+	lastErr       error
+	lastErrAt     time.Time
+	lastSuccessAt time.Time
+	flapping      bool
+}
+
+// EngineStatus is the engine's last health check, as of
+// HealthMonitor.EngineStatus.
+type EngineStatus struct {
+	// Checked is false until the first check has finished.
+	Checked   bool
+	Healthy   bool
+	CheckedAt time.Time
+	// Error is why the last check failed, or while unhealthy, why the last
+	// failed check did.
+	Error string
+	// Interval is how often the engine is checked.
+	Interval time.Duration
+	// Draining is set while the server drains.
+	Draining bool
+
+	// ConsecutiveFailures and ConsecutiveSuccesses are the current run of
+	// check results.
+	ConsecutiveFailures  int
+	ConsecutiveSuccesses int
+	// Checks is how many results the history holds, and SuccessRatio the
+	// fraction of them that succeeded.
+	Checks       int
+	SuccessRatio float64
+	// LastError is why the last failed check failed, at LastErrorAt.
+	LastError     string
+	LastErrorAt   time.Time
+	LastSuccessAt time.Time
+	// Transitions counts changes of the check result within the history;
+	// Flapping is set while they reach the flap threshold.
+	Transitions int
+	Flapping    bool
+}
+
+// NewHealthMonitor creates a monitor that checks the engine every interval.
+func NewHealthMonitor(healthServer *health.Server, translator translate.Translator, logger *logrus.Logger, interval time.Duration) *HealthMonitor {
+	return &HealthMonitor{
+		health:     healthServer,
+		translator: translator,
+		logger:     logger,
+		interval:   interval,
+		thresholds: HealthThresholds{Unhealthy: DefaultUnhealthyThreshold, Healthy: DefaultHealthyThreshold},
+	}
+}
+
+// SetThresholds sets how many failed or successful checks in a row change
+// the engine's status; zero fields keep the defaults. It must be called
+// before Run.
+func (m *HealthMonitor) SetThresholds(t HealthThresholds) {
+	if t.Unhealthy <= 0 {
+		t.Unhealthy = DefaultUnhealthyThreshold
+	}
+	if t.Healthy <= 0 {
+		t.Healthy = DefaultHealthyThreshold
+	}
+	m.thresholds = t
+}
+
+// Run sets the initial statuses, then re-checks the engine until ctx is done.
+func (m *HealthMonitor) Run(ctx context.Context) {
+	m.health.SetServingStatus("", grpc_health_v1.HealthCheckResponse_SERVING)
+	m.health.SetServingStatus(nanabushv1.AdminService_ServiceDesc.ServiceName, grpc_health_v1.HealthCheckResponse_SERVING)
+	m.health.SetServingStatus(longrunningpb.Operations_ServiceDesc.ServiceName, grpc_health_v1.HealthCheckResponse_SERVING)
+	m.check(ctx)
+
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			m.check(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// check runs the engine health check and updates the engine-dependent statuses.
+func (m *HealthMonitor) check(ctx context.Context) {
+	checkCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	err := m.translator.CheckHealth(checkCtx)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.checkedAt, m.checkErr = time.Now(), err
+	m.record(err)
+	healthy := m.decide(err == nil)
+	m.setStatuses(healthy)
+
+	// Log transitions, and failures not (yet) counted as one
+	if m.healthy != nil && *m.healthy == healthy {
+		if healthy && err != nil {
+			m.logger.WithError(err).WithFields(logrus.Fields{
+				"consecutive_failures": m.failures,
+				"unhealthy_threshold":  m.thresholds.Unhealthy,
+			}).Warn("Translation engine health check failed")
+		}
+		return
+	}
+	m.healthy = &healthy
+	fields := logrus.Fields{
+		"consecutive_failures":  m.failures,
+		"consecutive_successes": m.successes,
+	}
+	if healthy {
+		m.logger.WithFields(fields).Info("Translation engine healthy; translation services SERVING")
+	} else {
+		m.logger.WithError(err).WithFields(fields).Warn("Translation engine unhealthy; translation services NOT_SERVING")
+	}
+}
+
+// record adds a check result to the history. Callers hold m.mu.
+func (m *HealthMonitor) record(err error) {
+	now := m.checkedAt
+	m.history = append(m.history, err == nil)
+	if len(m.history) > healthHistorySize {
+		m.history = m.history[1:]
+	}
+	if err == nil {
+		m.successes++
+		m.failures = 0
+		m.lastSuccessAt = now
+	} else {
+		m.failures++
+		m.successes = 0
+		m.lastErr, m.lastErrAt = err, now
+	}
+
+	flapping := transitions(m.history) >= flapTransitions
+	if flapping != m.flapping {
+		m.flapping = flapping
+		if flapping {
+			m.logger.WithField("transitions", transitions(m.history)).Warn("Translation engine health is flapping")
+		} else {
+			m.logger.Info("Translation engine health stopped flapping")
+		}
+	}
+}
+
+// decide returns the engine's status after a check: the first check sets
+// it, later ones only change it after enough checks in a row. Callers hold
+// m.mu.
+func (m *HealthMonitor) decide(ok bool) bool {
+	switch {
+	case m.healthy == nil:
+		return ok
+	case *m.healthy:
+		return m.failures < m.thresholds.Unhealthy
+	default:
+		return m.successes >= m.thresholds.Healthy
+	}
+}
+
+// transitions counts the changes of result in history.
+func transitions(history []bool) int {
+	n := 0
+	for i := 1; i < len(history); i++ {
+		if history[i] != history[i-1] {
+			n++
+		}
+	}
+	return n
+}
+
+// EngineStatus returns the engine's last health check (thread-safe).
+func (m *HealthMonitor) EngineStatus() EngineStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	status := EngineStatus{
+		Checked:   m.healthy != nil,
+		Healthy:   m.healthy != nil && *m.healthy,
+		CheckedAt: m.checkedAt,
+		Interval:  m.interval,
+		Draining:  m.draining,
+
+		ConsecutiveFailures:  m.failures,
+		ConsecutiveSuccesses: m.successes,
+		Checks:               len(m.history),
+		LastErrorAt:          m.lastErrAt,
+		LastSuccessAt:        m.lastSuccessAt,
+		Transitions:          transitions(m.history),
+		Flapping:             m.flapping,
+	}
+	if len(m.history) > 0 {
+		succeeded := 0
+		for _, ok := range m.history {
+			if ok {
+				succeeded++
+			}
+		}
+		status.SuccessRatio = float64(succeeded) / float64(len(m.history))
+	}
+	if m.lastErr != nil {
+		status.LastError = m.lastErr.Error()
+	}
+	switch {
+	case m.checkErr != nil:
+		status.Error = m.checkErr.Error()
+	case !status.Healthy:
+		status.Error = status.LastError
+	}
+	return status
+}
+
+// SetDraining marks the translation services NOT_SERVING while draining (and
+// restores them to the engine's status afterwards), so readiness probes stop
+// routing new traffic to the instance.
+func (m *HealthMonitor) SetDraining(draining bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.draining = draining
+	if m.healthy != nil {
+		m.setStatuses(*m.healthy)
+	}
+}
+
+// setStatuses updates the engine-dependent statuses. Callers hold m.mu.
+func (m *HealthMonitor) setStatuses(healthy bool) {
+	engineStatus := grpc_health_v1.HealthCheckResponse_SERVING
+	if !healthy {
+		engineStatus = grpc_health_v1.HealthCheckResponse_NOT_SERVING
+	}
+	m.health.SetServingStatus(HealthServiceEngine, engineStatus)
+
+	serviceStatus := engineStatus
+	if m.draining {
+		serviceStatus = grpc_health_v1.HealthCheckResponse_NOT_SERVING
+	}
+	for _, name := range []string{
+		nanabushv1.TranslationService_ServiceDesc.ServiceName,
+		nanabushv2.TranslationService_ServiceDesc.ServiceName,
+	} {
+		m.health.SetServingStatus(name, serviceStatus)
+	}
+}
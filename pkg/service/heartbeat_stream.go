@@ -0,0 +1,96 @@
+package service
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	nanabushv1 "github.com/dasmlab/iskoces/pkg/proto/v1"
+	"github.com/sirupsen/logrus"
+)
+
+// HeartbeatStream handles heartbeats sent over a long-lived stream.
+//
+// Each request is processed exactly like a unary Heartbeat and answered on the
+// stream. The first acknowledged heartbeat binds the stream to its client_id;
+// when the stream ends for any reason the client is unregistered right away,
+// so a vanished client doesn't linger until CleanupExpiredClients runs.
+func (s *TranslationService) HeartbeatStream(stream nanabushv1.TranslationService_HeartbeatStreamServer) error {
+	ctx := stream.Context()
+	var clientID string
+
+	defer func() {
+		if clientID != "" {
+			s.unregisterClient(clientID, "heartbeat stream closed")
+		}
+	}()
+
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				// Client went away; the deferred unregister is the whole point
+				return nil
+			}
+			s.log(stream.Context()).WithError(err).WithFields(logrus.Fields{
+				"client_id": clientID,
+			}).Warn("HeartbeatStream receive error")
+			return recvError(err)
+		}
+
+		if clientID != "" && req.ClientId != clientID {
+			return status.Error(codes.InvalidArgument,
+				fmt.Sprintf("heartbeat stream is bound to client_id %q; open a new stream for %q", clientID, req.ClientId))
+		}
+
+		resp, err := s.Heartbeat(ctx, req)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case resp.ReRegisterRequired:
+			// The registration is gone; the client may re-register and keep using the stream
+			clientID = ""
+		case clientID == "":
+			clientID = req.ClientId
+			s.log(stream.Context()).WithFields(logrus.Fields{
+				"client_id":   req.ClientId,
+				"client_name": req.ClientName,
+			}).Info("Heartbeat stream established")
+		}
+
+		if err := stream.Send(resp); err != nil {
+			s.log(stream.Context()).WithError(err).WithFields(logrus.Fields{
+				"client_id": req.ClientId,
+			}).Warn("HeartbeatStream send error")
+			return status.Error(codes.Unavailable, fmt.Sprintf("failed to send heartbeat response: %v", err))
+		}
+	}
+}
+
+// unregisterClient removes a client when its heartbeat stream closes.
+func (s *TranslationService) unregisterClient(clientID, reason string) {
+	s.clientsMutex.Lock()
+	defer s.clientsMutex.Unlock()
+
+	client, ok := s.clients[clientID]
+	if !ok {
+		return
+	}
+	s.evictClient(clientID, client, evictionStreamClosed, time.Now())
+	s.updateRegisteredClients()
+
+	s.Logger.WithFields(logrus.Fields{
+		"client_id":   clientID,
+		"client_name": client.ClientName,
+		"reason":      reason,
+		"remaining":   len(s.clients),
+	}).Info("Client unregistered")
+}
@@ -0,0 +1,142 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	nanabushv1 "github.com/dasmlab/iskoces/pkg/proto/v1"
+)
+
+// ErrIdempotencyConflict is returned when an explicit idempotency key is
+// reused with a request that would produce a different translation.
+var ErrIdempotencyConflict = errors.New("idempotency key reused with a different request")
+
+// idempotencyKey returns the key identifying retries of req. An explicit
+// idempotency_key wins; otherwise the client job_id is used. Both are scoped
+// by namespace, so tenants choosing the same key don't see or block each
+// other's requests. explicit reports which one it is: a mismatched payload
+// under an explicit key is a client error, under a job_id it's simply a new
+// request.
+func idempotencyKey(req *nanabushv1.TranslateRequest) (key string, explicit bool) {
+	if req.IdempotencyKey != "" {
+		return explicitKeyPrefix(req.Namespace) + req.IdempotencyKey, true
+	}
+	if req.JobId != "" {
+		return "job/" + req.Namespace + "/" + req.JobId, false
+	}
+	return "", false
+}
+
+// explicitKeyPrefix is the prefix of the idempotency keys built from an
+// explicit idempotency_key in namespace.
+func explicitKeyPrefix(namespace string) string {
+	return "key/" + namespace + "/"
+}
+
+// requestFingerprint hashes the fields that determine a request's result.
+// Scheduling hints and timestamps are excluded so a retry with a fresh
+// requested_at or a different deadline still matches.
+func requestFingerprint(req *nanabushv1.TranslateRequest) string {
+	clone := proto.Clone(req).(*nanabushv1.TranslateRequest)
+	clone.RequestedAt = nil
+	clone.Priority = nanabushv1.Priority_PRIORITY_UNSPECIFIED
+	clone.DeadlineSeconds = 0
+	clone.IdempotencyKey = ""
+
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(clone)
+	if err != nil {
+		// Unmarshalable requests never match each other
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// idempotentResult is a synchronous Translate result shared by retries.
+type idempotentResult struct {
+	fingerprint string
+	done        chan struct{} // closed once resp/err are set
+	resp        *nanabushv1.TranslateResponse
+	err         error
+	completedAt time.Time
+}
+
+// resultCache remembers recent synchronous Translate results by idempotency
+// key. Concurrent duplicates wait for the first request instead of
+// translating again; only successful results are kept.
+type resultCache struct {
+	mu      sync.Mutex
+	entries map[string]*idempotentResult
+}
+
+// newResultCache creates an empty result cache.
+func newResultCache() *resultCache {
+	return &resultCache{
+		entries: make(map[string]*idempotentResult),
+	}
+}
+
+// begin looks up key. If a matching entry exists it is returned with
+// leader=false and the caller should wait on it. Otherwise a new entry is
+// registered and the caller (leader=true) must complete it with finish.
+func (c *resultCache) begin(key, fingerprint string, explicit bool) (entry *idempotentResult, leader bool, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if existing, ok := c.entries[key]; ok {
+		if existing.fingerprint == fingerprint {
+			return existing, false, nil
+		}
+		if explicit {
+			return nil, false, ErrIdempotencyConflict
+		}
+		// Same job_id with different content: a new request, not a retry
+	}
+
+	entry = &idempotentResult{
+		fingerprint: fingerprint,
+		done:        make(chan struct{}),
+	}
+	c.entries[key] = entry
+	return entry, true, nil
+}
+
+// finish records the leader's result and wakes waiters. Failed results are
+// dropped so that a later retry translates again; a queued job is kept, so
+// a retry gets the same job rather than queueing another.
+func (c *resultCache) finish(key string, entry *idempotentResult, resp *nanabushv1.TranslateResponse, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry.resp = resp
+	entry.err = err
+	entry.completedAt = time.Now()
+	close(entry.done)
+
+	if err != nil || resp == nil || (!resp.Success && resp.Queued == nil) {
+		if c.entries[key] == entry {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// cleanup removes results completed more than maxAge ago.
+func (c *resultCache) cleanup(maxAge time.Duration) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cutoff := time.Now().Add(-maxAge)
+	removed := 0
+	for key, entry := range c.entries {
+		if !entry.completedAt.IsZero() && entry.completedAt.Before(cutoff) {
+			delete(c.entries, key)
+			removed++
+		}
+	}
+	return removed
+}
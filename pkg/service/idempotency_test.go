@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	nanabushv1 "github.com/dasmlab/iskoces/pkg/proto/v1"
+)
+
+// Idempotency keys are scoped by namespace: two tenants choosing the same
+// key get their own jobs, and a conflict within a namespace doesn't name
+// the job holding the key.
+func TestIdempotencyKeyNamespaces(t *testing.T) {
+	logger := quietLogger()
+	s := &TranslationService{Logger: logger, JobQueue: NewJobQueue(logger), results: newResultCache()}
+	request := func(namespace, markdown string) *nanabushv1.TranslateRequest {
+		return &nanabushv1.TranslateRequest{
+			JobId:          "doc-1",
+			IdempotencyKey: "retry-1",
+			Namespace:      namespace,
+			Primitive:      nanabushv1.PrimitiveType_PRIMITIVE_DOC_TRANSLATE,
+			SourceLanguage: "en",
+			TargetLanguage: "fr",
+			Source: &nanabushv1.TranslateRequest_Doc{Doc: &nanabushv1.DocumentContent{
+				Markdown: strings.Repeat(markdown, AsyncThresholdBytes+1),
+			}},
+		}
+	}
+
+	a, err := s.Translate(context.Background(), request("tenant-a", "a"))
+	if err != nil {
+		t.Fatalf("Translate(tenant-a) = %v", err)
+	}
+	b, err := s.Translate(context.Background(), request("tenant-b", "b"))
+	if err != nil {
+		t.Fatalf("Translate(tenant-b) with tenant-a's key = %v", err)
+	}
+	if a.Queued.GetJobId() == "" || a.Queued.GetJobId() == b.Queued.GetJobId() {
+		t.Errorf("tenants got jobs %q and %q, want two jobs", a.Queued.GetJobId(), b.Queued.GetJobId())
+	}
+
+	// A retry in tenant-b gets its own job back
+	again, err := s.Translate(context.Background(), request("tenant-b", "b"))
+	if err != nil {
+		t.Fatalf("retried Translate(tenant-b) = %v", err)
+	}
+	if again.Queued.GetJobId() != b.Queued.GetJobId() {
+		t.Errorf("retry got job %q, want %q", again.Queued.GetJobId(), b.Queued.GetJobId())
+	}
+
+	// Reusing the key for another document in the same namespace conflicts,
+	// in the result cache and in the job queue
+	if _, err := s.Translate(context.Background(), request("tenant-a", "c")); err == nil {
+		t.Error("Translate() with a reused key and another document succeeded")
+	}
+	_, err = s.JobQueue.CreateJob(context.Background(), request("tenant-a", "c"), "")
+	if !errors.Is(err, ErrIdempotencyConflict) {
+		t.Fatalf("CreateJob() with a reused key = %v, want ErrIdempotencyConflict", err)
+	}
+	if strings.Contains(err.Error(), a.Queued.GetJobId()) {
+		t.Errorf("conflict error %q names the job holding the key", err)
+	}
+	if n := len(s.JobQueue.ListJobs()); n != 2 {
+		t.Errorf("queue has %d jobs, want 2", n)
+	}
+}
+
+// A dead-lettered job's rebuilt request keeps its explicit key.
+func TestJobRequestIdempotencyKey(t *testing.T) {
+	job := &TranslationJob{Namespace: "tenant-a", Title: "Hello"}
+	job.idempotencyKey, _ = idempotencyKey(&nanabushv1.TranslateRequest{Namespace: "tenant-a", IdempotencyKey: "retry-1"})
+	if got := job.request().IdempotencyKey; got != "retry-1" {
+		t.Errorf("request() idempotency key = %q, want retry-1", got)
+	}
+	job.idempotencyKey, _ = idempotencyKey(&nanabushv1.TranslateRequest{Namespace: "tenant-a", JobId: "doc-1"})
+	if got := job.request().IdempotencyKey; got != "" {
+		t.Errorf("request() idempotency key = %q for a job_id key, want none", got)
+	}
+}
@@ -0,0 +1,215 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"unicode/utf8"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	nanabushv1 "github.com/dasmlab/iskoces/pkg/proto/v1"
+	nanabushv2 "github.com/dasmlab/iskoces/pkg/proto/v2"
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultMaxTitleLength is the default maximum title length in characters.
+const DefaultMaxTitleLength = 1000
+
+// RequestLimits bounds the requests accepted by the validation interceptors.
+type RequestLimits struct {
+	// MaxDocumentBytes is the maximum size of a document's markdown, a stream
+	// chunk, a detection sample, or a whole BatchTranslate request.
+	MaxDocumentBytes int
+	// MaxTitleLength is the maximum title length in characters.
+	MaxTitleLength int
+}
+
+// DefaultRequestLimits returns the limits used when none are configured.
+func DefaultRequestLimits() RequestLimits {
+	return RequestLimits{
+		MaxDocumentBytes: DefaultMaxDocumentBytes,
+		MaxTitleLength:   DefaultMaxTitleLength,
+	}
+}
+
+// Validate checks a request message against the limits and for valid UTF-8.
+// Returns an InvalidArgument status error with BadRequest field violations,
+// or nil if the message is acceptable (or of a type that isn't checked).
+func (l RequestLimits) Validate(msg any) error {
+	var v []*errdetails.BadRequest_FieldViolation
+
+	switch m := msg.(type) {
+	case *nanabushv1.TranslateRequest:
+		v = l.checkTitle(v, "title", m.GetTitle())
+		if doc := m.GetDoc(); doc != nil {
+			v = l.checkDocument(v, "doc", doc)
+		}
+		if m.TemplateHelper != nil {
+			v = l.checkDocument(v, "template_helper", m.TemplateHelper)
+		}
+	case *nanabushv1.TitleCheckRequest:
+		v = l.checkTitle(v, "title", m.Title)
+	case *nanabushv1.BatchTranslateRequest:
+		total := 0
+		for i, text := range m.Texts {
+			total += len(text)
+			v = checkUTF8(v, fmt.Sprintf("texts[%d]", i), text)
+		}
+		if total > l.MaxDocumentBytes {
+			v = append(v, &errdetails.BadRequest_FieldViolation{
+				Field:       "texts",
+				Description: fmt.Sprintf("total size %d bytes exceeds the limit of %d bytes", total, l.MaxDocumentBytes),
+			})
+		}
+	case *nanabushv1.DetectLanguageRequest:
+		v = l.checkText(v, "text", m.Text)
+	case *nanabushv1.TranslateChunk:
+		v = l.checkText(v, "content", m.Content)
+	case *nanabushv1.DocumentSetRequest:
+		for i, doc := range m.Documents {
+			if doc != nil {
+				v = l.checkDocument(v, fmt.Sprintf("documents[%d]", i), doc)
+			}
+		}
+		for term, target := range m.Glossary {
+			v = checkUTF8(v, fmt.Sprintf("glossary[%q]", term), target)
+		}
+	case *nanabushv1.TranslationFeedback:
+		v = l.checkText(v, "source_text", m.SourceText)
+		v = l.checkText(v, "machine_translation", m.MachineTranslation)
+		v = l.checkText(v, "corrected_text", m.CorrectedText)
+		v = checkUTF8(v, "comment", m.Comment)
+	case *nanabushv2.TranslationRequest:
+		v = l.checkV2Request(v, "", m)
+	case *nanabushv2.SubmitBatchRequest:
+		for i, r := range m.Requests {
+			if r != nil {
+				v = l.checkV2Request(v, fmt.Sprintf("requests[%d].", i), r)
+			}
+		}
+	}
+
+	if len(v) == 0 {
+		return nil
+	}
+
+	st := status.New(codes.InvalidArgument, fmt.Sprintf("invalid %s: %s", v[0].Field, v[0].Description))
+	if detailed, err := st.WithDetails(&errdetails.BadRequest{FieldViolations: v}); err == nil {
+		st = detailed
+	}
+	return st.Err()
+}
+
+// checkV2Request validates a v2 request's content, reporting fields under
+// prefix.
+func (l RequestLimits) checkV2Request(v []*errdetails.BadRequest_FieldViolation, prefix string, m *nanabushv2.TranslationRequest) []*errdetails.BadRequest_FieldViolation {
+	if m.GetText() != "" {
+		v = l.checkText(v, prefix+"text", m.GetText())
+	}
+	if doc := m.GetDocument(); doc != nil {
+		v = l.checkDocument(v, prefix+"document", &nanabushv1.DocumentContent{
+			Title:    doc.Title,
+			Markdown: doc.Markdown,
+			Metadata: doc.Metadata,
+		})
+	}
+	return v
+}
+
+// checkDocument validates a document's title, markdown, and metadata.
+func (l RequestLimits) checkDocument(v []*errdetails.BadRequest_FieldViolation, field string, doc *nanabushv1.DocumentContent) []*errdetails.BadRequest_FieldViolation {
+	v = l.checkTitle(v, field+".title", doc.Title)
+	v = l.checkText(v, field+".markdown", doc.Markdown)
+	for key, value := range doc.Metadata {
+		v = checkUTF8(v, fmt.Sprintf("%s.metadata[%q]", field, key), value)
+	}
+	return v
+}
+
+// checkTitle validates a title's length (in characters) and encoding.
+func (l RequestLimits) checkTitle(v []*errdetails.BadRequest_FieldViolation, field, title string) []*errdetails.BadRequest_FieldViolation {
+	if !utf8.ValidString(title) {
+		return checkUTF8(v, field, title)
+	}
+	if n := utf8.RuneCountInString(title); n > l.MaxTitleLength {
+		v = append(v, &errdetails.BadRequest_FieldViolation{
+			Field:       field,
+			Description: fmt.Sprintf("length %d characters exceeds the limit of %d", n, l.MaxTitleLength),
+		})
+	}
+	return v
+}
+
+// checkText validates a text field's size (in bytes) and encoding.
+func (l RequestLimits) checkText(v []*errdetails.BadRequest_FieldViolation, field, text string) []*errdetails.BadRequest_FieldViolation {
+	if len(text) > l.MaxDocumentBytes {
+		return append(v, &errdetails.BadRequest_FieldViolation{
+			Field:       field,
+			Description: fmt.Sprintf("size %d bytes exceeds the limit of %d bytes", len(text), l.MaxDocumentBytes),
+		})
+	}
+	return checkUTF8(v, field, text)
+}
+
+// checkUTF8 records a violation if s isn't valid UTF-8.
+func checkUTF8(v []*errdetails.BadRequest_FieldViolation, field, s string) []*errdetails.BadRequest_FieldViolation {
+	if utf8.ValidString(s) {
+		return v
+	}
+	return append(v, &errdetails.BadRequest_FieldViolation{
+		Field:       field,
+		Description: "must be valid UTF-8",
+	})
+}
+
+// ValidationUnaryInterceptor rejects unary requests that violate limits
+// before the handler (and any backend work) runs.
+func ValidationUnaryInterceptor(limits RequestLimits, logger *logrus.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if err := limits.Validate(req); err != nil {
+			logger.WithError(err).WithFields(logrus.Fields{
+				"method": info.FullMethod,
+			}).Warn("Rejected invalid request")
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// ValidationStreamInterceptor applies the same checks to every message
+// received on a stream.
+func ValidationStreamInterceptor(limits RequestLimits, logger *logrus.Logger) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, &validatingStream{
+			ServerStream: ss,
+			limits:       limits,
+			logger:       logger,
+			method:       info.FullMethod,
+		})
+	}
+}
+
+// validatingStream validates each message as it is received.
+type validatingStream struct {
+	grpc.ServerStream
+	limits RequestLimits
+	logger *logrus.Logger
+	method string
+}
+
+// RecvMsg receives the next message and validates it.
+func (s *validatingStream) RecvMsg(m any) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	if err := s.limits.Validate(m); err != nil {
+		s.logger.WithError(err).WithFields(logrus.Fields{
+			"method": s.method,
+		}).Warn("Rejected invalid stream message")
+		return err
+	}
+	return nil
+}
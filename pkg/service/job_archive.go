@@ -0,0 +1,266 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/sirupsen/logrus"
+
+	"github.com/dasmlab/iskoces/pkg/translate"
+)
+
+const (
+	// DefaultJobArchiveInterval is how often finished jobs are written to
+	// the archive.
+	DefaultJobArchiveInterval = 5 * time.Minute
+
+	// jobArchiveMaxPending is how many finished jobs can wait to be
+	// archived, e.g. while the archive store is unreachable; further jobs
+	// are dropped.
+	jobArchiveMaxPending = 100000
+)
+
+var jobsArchivedTotal = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "iskoces_jobs_archived_total",
+		Help: "Total number of finished jobs written to the job archive, by outcome (written, dropped)",
+	},
+	[]string{"outcome"},
+)
+
+// ArchivedJob is a finished job's metadata as written to the archive, one
+// JSON object per line. Source text and results are left out.
+type ArchivedJob struct {
+	JobID           string               `json:"job_id"`
+	RequestID       string               `json:"request_id,omitempty"`
+	Namespace       string               `json:"namespace,omitempty"`
+	ClientID        string               `json:"client_id,omitempty"`
+	BatchID         string               `json:"batch_id,omitempty"`
+	Primitive       string               `json:"primitive"`
+	Status          TranslationJobStatus `json:"status"`
+	SourceLanguage  string               `json:"source_language"`
+	TargetLanguage  string               `json:"target_language"`
+	LocalizeFormats bool                 `json:"localize_formats,omitempty"`
+	Priority        string               `json:"priority"`
+	SourceChars     int                  `json:"source_characters"` // Title and markdown
+	CreatedAt       time.Time            `json:"created_at"`
+	NotBefore       *time.Time           `json:"not_before,omitempty"`
+	StartedAt       *time.Time           `json:"started_at,omitempty"`
+	CompletedAt     *time.Time           `json:"completed_at,omitempty"`
+	Attempts        int                  `json:"attempts"`
+	Error           string               `json:"error,omitempty"`
+	ErrorClass      translate.ErrorClass `json:"error_class,omitempty"`
+	DeadLetter      bool                 `json:"dead_letter,omitempty"`
+	DuplicateOf     string               `json:"duplicate_of,omitempty"`
+
+	// Usage, as in JobUsage
+	Characters    int64   `json:"characters"`
+	Chunks        int     `json:"chunks"`
+	EngineSeconds float64 `json:"engine_seconds"`
+	QueueSeconds  float64 `json:"queue_seconds"`
+	EstimatedCost float64 `json:"estimated_cost,omitempty"`
+}
+
+// JobArchiveConfig controls the job archive: finished jobs are written, in
+// batches, as JSON lines files named jobs-<time>-<id>.jsonl, for analytics
+// long after the queue has forgotten them.
+type JobArchiveConfig struct {
+	// Store receives the archive files (see OpenResultStore).
+	Store ResultStore
+	// Interval is how often finished jobs are written (default
+	// DefaultJobArchiveInterval).
+	Interval time.Duration
+	// Retention is how long archive files are kept, independent of job
+	// retention (0 = no limit). Only directory stores remove old files;
+	// use a lifecycle rule for object storage.
+	Retention time.Duration
+}
+
+// jobArchiver collects finished jobs and writes them to the archive store
+// in the background.
+type jobArchiver struct {
+	cfg    JobArchiveConfig
+	logger *logrus.Logger
+
+	mu      sync.Mutex
+	pending []ArchivedJob
+
+	quit     chan struct{} // Closed to stop; pending jobs are still written
+	stopOnce sync.Once
+	stopped  chan struct{}
+
+	// holdFailures is set during shutdown when failed jobs will run again
+	// after the restart, so they aren't archived as failed.
+	holdFailures atomic.Bool
+}
+
+// newJobArchiver starts archiving to cfg.Store.
+func newJobArchiver(cfg JobArchiveConfig, logger *logrus.Logger) *jobArchiver {
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultJobArchiveInterval
+	}
+	a := &jobArchiver{
+		cfg:     cfg,
+		logger:  logger,
+		quit:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go a.run()
+	return a
+}
+
+// add queues the job's final state for the next write. Callers hold j.mu.
+func (a *jobArchiver) add(j *TranslationJob) {
+	if j.Status == JobStatusFailed && a.holdFailures.Load() {
+		return
+	}
+	rec := ArchivedJob{
+		JobID:           j.ID,
+		RequestID:       j.RequestID,
+		Namespace:       j.Namespace,
+		ClientID:        j.ClientID,
+		BatchID:         j.BatchID,
+		Primitive:       j.Primitive.String(),
+		Status:          j.Status,
+		SourceLanguage:  j.SourceLang,
+		TargetLanguage:  j.TargetLang,
+		LocalizeFormats: j.LocalizeFormats,
+		Priority:        j.Priority.String(),
+		SourceChars:     utf8.RuneCountInString(j.Title),
+		CreatedAt:       j.CreatedAt,
+		NotBefore:       j.NotBefore,
+		StartedAt:       j.StartedAt,
+		CompletedAt:     j.CompletedAt,
+		Attempts:        j.Attempt,
+		Error:           j.Error,
+		ErrorClass:      j.ErrorClass,
+		DeadLetter:      j.DeadLetter,
+		DuplicateOf:     j.DuplicateOf,
+		Characters:      j.Usage.Characters,
+		Chunks:          j.Usage.Chunks,
+		EngineSeconds:   j.Usage.EngineTime.Seconds(),
+		QueueSeconds:    j.Usage.QueueTime.Seconds(),
+		EstimatedCost:   j.Usage.EstimatedCost,
+	}
+	if j.Document != nil {
+		rec.SourceChars += utf8.RuneCountInString(j.Document.Markdown)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(a.pending) >= jobArchiveMaxPending {
+		jobsArchivedTotal.WithLabelValues("dropped").Inc()
+		return
+	}
+	a.pending = append(a.pending, rec)
+}
+
+// run writes pending jobs every interval, removing expired archive files,
+// until the archiver is stopped.
+func (a *jobArchiver) run() {
+	defer close(a.stopped)
+	ticker := time.NewTicker(a.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			a.flush(time.Now())
+			a.expire(time.Now())
+		case <-a.quit:
+			a.flush(time.Now())
+			return
+		}
+	}
+}
+
+// flush writes the pending jobs as one archive file. If the write fails
+// they are kept for the next one.
+func (a *jobArchiver) flush(now time.Time) {
+	a.mu.Lock()
+	recs := a.pending
+	a.pending = nil
+	a.mu.Unlock()
+	if len(recs) == 0 {
+		return
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, rec := range recs {
+		if err := enc.Encode(rec); err != nil {
+			a.logger.WithError(err).WithField("job_id", rec.JobID).Warn("Failed to encode archived job")
+		}
+	}
+	key := fmt.Sprintf("jobs-%s-%s.jsonl", now.UTC().Format("20060102T150405Z"), uuid.New().String()[:8])
+	if err := a.cfg.Store.Put(key, buf.Bytes()); err != nil {
+		a.logger.WithError(err).WithField("jobs", len(recs)).Warn("Failed to write job archive, retrying with the next write")
+		a.mu.Lock()
+		a.pending = append(recs, a.pending...)
+		if over := len(a.pending) - jobArchiveMaxPending; over > 0 {
+			jobsArchivedTotal.WithLabelValues("dropped").Add(float64(over))
+			a.pending = a.pending[over:]
+		}
+		a.mu.Unlock()
+		return
+	}
+	jobsArchivedTotal.WithLabelValues("written").Add(float64(len(recs)))
+	a.logger.WithFields(logrus.Fields{
+		"key":  key,
+		"jobs": len(recs),
+	}).Debug("Archived finished jobs")
+}
+
+// expire removes archive files older than the retention, if the store
+// can.
+func (a *jobArchiver) expire(now time.Time) {
+	sweeper, ok := a.cfg.Store.(resultSweeper)
+	if a.cfg.Retention <= 0 || !ok {
+		return
+	}
+	if removed := sweeper.sweep(now.Add(-a.cfg.Retention)); removed > 0 {
+		a.logger.WithField("files", removed).Info("Removed expired job archive files")
+	}
+}
+
+// stop writes the pending jobs, waiting up to jobEventFlushTimeout.
+func (a *jobArchiver) stop() {
+	a.stopOnce.Do(func() {
+		close(a.quit)
+		select {
+		case <-a.stopped:
+		case <-time.After(jobEventFlushTimeout):
+			a.logger.Warn("Timed out writing the remaining archived jobs")
+		}
+	})
+}
+
+// archiveFinished queues the job for the archive, if the queue keeps one.
+// Callers hold j.mu.
+func (j *TranslationJob) archiveFinished() {
+	if j.archive != nil {
+		j.archive.add(j)
+	}
+}
+
+// SetArchive makes the queue archive the metadata of jobs as they finish
+// (see JobArchiveConfig). It must be called before jobs are submitted or
+// restored from a store; StopArchive writes the jobs still pending.
+func (q *JobQueue) SetArchive(cfg JobArchiveConfig) {
+	q.archive = newJobArchiver(cfg, q.logger)
+}
+
+// StopArchive writes the finished jobs not yet archived, if the queue
+// keeps an archive.
+func (q *JobQueue) StopArchive() {
+	if q.archive != nil {
+		q.archive.stop()
+	}
+}
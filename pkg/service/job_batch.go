@@ -0,0 +1,352 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	nanabushv1 "github.com/dasmlab/iskoces/pkg/proto/v1"
+	"github.com/dasmlab/iskoces/pkg/translate"
+)
+
+// MaxBatchJobs is the most requests one batch may hold.
+const MaxBatchJobs = 1000
+
+// ErrBatchNotFound is returned when a batch doesn't exist (or was cleaned
+// up).
+var ErrBatchNotFound = errors.New("batch not found")
+
+// BatchState is the aggregate state of a batch's jobs.
+type BatchState string
+
+const (
+	BatchStateRunning         BatchState = "running"          // Some jobs haven't finished
+	BatchStateSucceeded       BatchState = "succeeded"        // Every job completed
+	BatchStatePartiallyFailed BatchState = "partially_failed" // Finished; some jobs failed or were cancelled
+	BatchStateFailed          BatchState = "failed"           // Finished; no job completed
+)
+
+// BatchSpec describes a batch being submitted.
+type BatchSpec struct {
+	RequestID   string // Client identifier; resubmitting it in the same namespace returns the existing batch
+	Namespace   string
+	ClientID    string // Registered client submitting the batch, if known
+	CallbackURL string // Receives one webhook once every job has finished
+}
+
+// JobBatch is a set of jobs submitted together. Its jobs run like any
+// other; the batch reports their aggregate progress and sends one webhook
+// once all of them have finished.
+type JobBatch struct {
+	ID          string
+	RequestID   string
+	Namespace   string
+	ClientID    string
+	CallbackURL string
+	CreatedAt   time.Time
+	Jobs        []*TranslationJob // In request order; fixed once created
+
+	completedAt *time.Time // Set once every job has finished (guarded by mu)
+	mu          sync.RWMutex
+}
+
+// BatchJobStatus is the state of one job in a batch.
+type BatchJobStatus struct {
+	JobID           string               `json:"job_id"`
+	RequestID       string               `json:"request_id,omitempty"`
+	Status          TranslationJobStatus `json:"status"`
+	ProgressPercent int32                `json:"progress_percent"`
+	Message         string               `json:"message,omitempty"`
+	Error           string               `json:"error,omitempty"`
+	ErrorClass      translate.ErrorClass `json:"error_class,omitempty"`
+}
+
+// BatchStatus is a snapshot of a batch's aggregate state.
+type BatchStatus struct {
+	State           BatchState
+	ProgressPercent int32 // Across all jobs, weighted by content size
+	CompletedAt     *time.Time
+	Queued          int // Including paused jobs
+	Running         int
+	Succeeded       int
+	Failed          int
+	Cancelled       int
+	Jobs            []BatchJobStatus // In request order
+}
+
+// Status returns the batch's aggregate state and its jobs' states
+// (thread-safe).
+func (b *JobBatch) Status() BatchStatus {
+	var st BatchStatus
+	var totalBytes, doneBytes int64
+	for _, job := range b.Jobs {
+		job.mu.RLock()
+		js := BatchJobStatus{
+			JobID:           job.ID,
+			RequestID:       job.RequestID,
+			Status:          job.Status,
+			ProgressPercent: job.ProgressPercent,
+			Message:         job.ProgressMessage,
+			Error:           job.Error,
+			ErrorClass:      job.ErrorClass,
+		}
+		job.mu.RUnlock()
+		st.Jobs = append(st.Jobs, js)
+
+		size := int64(max(job.ContentBytes(), 1))
+		totalBytes += size
+		switch js.Status {
+		case JobStatusQueued, JobStatusPaused:
+			st.Queued++
+		case JobStatusProcessing:
+			st.Running++
+		case JobStatusCompleted:
+			st.Succeeded++
+		case JobStatusFailed:
+			st.Failed++
+		case JobStatusCancelled:
+			st.Cancelled++
+		}
+		if js.Status.IsTerminal() {
+			doneBytes += size * 100
+		} else {
+			doneBytes += size * int64(js.ProgressPercent)
+		}
+	}
+	if totalBytes > 0 {
+		st.ProgressPercent = int32(doneBytes / totalBytes)
+	}
+
+	b.mu.RLock()
+	st.CompletedAt = b.completedAt
+	b.mu.RUnlock()
+	switch {
+	case st.Queued+st.Running > 0:
+		st.State = BatchStateRunning
+	case st.Succeeded == len(b.Jobs):
+		st.State = BatchStateSucceeded
+	case st.Succeeded > 0:
+		st.State = BatchStatePartiallyFailed
+	default:
+		st.State = BatchStateFailed
+	}
+	return st
+}
+
+// CreateBatch creates a job for each request, in one batch, and returns the
+// batch. Requests must not set a callback URL; the batch reports to
+// spec.CallbackURL (or the namespace's webhook) once, when every job has
+// finished. If a job can't be created, those already created are cancelled
+// and the error is returned. The jobs are traced as CreateJob's are.
+func (q *JobQueue) CreateBatch(ctx context.Context, spec BatchSpec, reqs []*nanabushv1.TranslateRequest) (*JobBatch, error) {
+	if len(reqs) == 0 || len(reqs) > MaxBatchJobs {
+		return nil, fmt.Errorf("a batch must hold between 1 and %d requests, got %d", MaxBatchJobs, len(reqs))
+	}
+	key := ""
+	if spec.RequestID != "" {
+		key = spec.Namespace + "/" + spec.RequestID
+	}
+
+	if existing := q.batchForRequest(key); existing != nil {
+		return existing, nil
+	}
+
+	// Jobs are created without holding batchesMu, which ranks below jobsMu
+	batch := &JobBatch{
+		ID:          uuid.New().String(),
+		RequestID:   spec.RequestID,
+		Namespace:   spec.Namespace,
+		ClientID:    spec.ClientID,
+		CallbackURL: spec.CallbackURL,
+		CreatedAt:   time.Now(),
+	}
+	for i, req := range reqs {
+		if req.CallbackUrl != "" {
+			q.abandonBatch(batch)
+			return nil, fmt.Errorf("request %d: callback URLs are set on the batch, not its requests", i)
+		}
+		jobID, err := q.createJob(ctx, req, spec.ClientID, batch.ID)
+		if err == nil {
+			var job *TranslationJob
+			if job, err = q.GetJob(jobID); err == nil {
+				batch.Jobs = append(batch.Jobs, job)
+				continue
+			}
+		}
+		q.abandonBatch(batch)
+		return nil, fmt.Errorf("request %d: %w", i, err)
+	}
+
+	q.batchesMu.Lock()
+	if existing, ok := q.batchByRequest[key]; ok && key != "" {
+		// Lost a race with a concurrent submission of the same batch
+		q.batchesMu.Unlock()
+		q.abandonBatch(batch)
+		return existing, nil
+	}
+	q.batches[batch.ID] = batch
+	if key != "" {
+		q.batchByRequest[key] = batch
+	}
+	q.batchesMu.Unlock()
+	go q.watchBatch(batch)
+
+	q.logger.WithFields(logrus.Fields{
+		"batch_id":   batch.ID,
+		"request_id": batch.RequestID,
+		"namespace":  batch.Namespace,
+		"jobs":       len(batch.Jobs),
+	}).Info("Created translation batch")
+	return batch, nil
+}
+
+// batchForRequest returns the batch submitted with the namespaced request
+// key, if any.
+func (q *JobQueue) batchForRequest(key string) *JobBatch {
+	if key == "" {
+		return nil
+	}
+	q.batchesMu.RLock()
+	defer q.batchesMu.RUnlock()
+	return q.batchByRequest[key]
+}
+
+// abandonBatch cancels the jobs created so far for a batch that couldn't be
+// submitted in full.
+func (q *JobQueue) abandonBatch(batch *JobBatch) {
+	for _, job := range batch.Jobs {
+		if err := q.cancelJob(job, "Batch submission failed"); err != nil && !errors.Is(err, ErrJobFinished) {
+			q.logger.WithError(err).WithField("job_id", job.ID).Warn("Failed to cancel job of abandoned batch")
+		}
+	}
+}
+
+// GetBatch returns the batch with batchID.
+func (q *JobQueue) GetBatch(batchID string) (*JobBatch, error) {
+	q.batchesMu.RLock()
+	defer q.batchesMu.RUnlock()
+	batch, ok := q.batches[batchID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrBatchNotFound, batchID)
+	}
+	return batch, nil
+}
+
+// watchBatch waits for every job in the batch to finish, then reports the
+// batch.
+func (q *JobQueue) watchBatch(batch *JobBatch) {
+	for _, job := range batch.Jobs {
+		<-job.Done()
+	}
+	now := time.Now()
+	batch.mu.Lock()
+	batch.completedAt = &now
+	batch.mu.Unlock()
+
+	st := batch.Status()
+	q.logger.WithFields(logrus.Fields{
+		"batch_id":  batch.ID,
+		"state":     st.State,
+		"succeeded": st.Succeeded,
+		"failed":    st.Failed,
+		"cancelled": st.Cancelled,
+	}).Info("Translation batch finished")
+	q.webhooks.notifyBatch(batch, st)
+}
+
+// dropFinishedBatches forgets finished batches none of whose jobs are kept
+// any more. Callers hold q.jobsMu.
+func (q *JobQueue) dropFinishedBatches() {
+	q.batchesMu.Lock()
+	defer q.batchesMu.Unlock()
+	for id, batch := range q.batches {
+		batch.mu.RLock()
+		finished := batch.completedAt != nil
+		batch.mu.RUnlock()
+		if !finished {
+			continue
+		}
+		kept := false
+		for _, job := range batch.Jobs {
+			if q.jobs[job.ID] == job {
+				kept = true
+				break
+			}
+		}
+		if kept {
+			continue
+		}
+		delete(q.batches, id)
+		if key := batch.Namespace + "/" + batch.RequestID; q.batchByRequest[key] == batch {
+			delete(q.batchByRequest, key)
+		}
+	}
+}
+
+// BatchWebhookEvent is the JSON body POSTed when every job in a batch has
+// finished.
+type BatchWebhookEvent struct {
+	Event       string           `json:"event"`
+	BatchID     string           `json:"batch_id"`
+	RequestID   string           `json:"request_id,omitempty"`
+	Namespace   string           `json:"namespace,omitempty"`
+	State       BatchState       `json:"state"`
+	Total       int              `json:"total_jobs"`
+	Succeeded   int              `json:"succeeded_jobs"`
+	Failed      int              `json:"failed_jobs"`
+	Cancelled   int              `json:"cancelled_jobs"`
+	CreatedAt   time.Time        `json:"created_at"`
+	CompletedAt *time.Time       `json:"completed_at,omitempty"`
+	Jobs        []BatchJobStatus `json:"jobs"`
+	// ResultURL is the batch's HTTP status endpoint; fetch each job's
+	// result with GetTranslationResult (or v2 GetJob) using its job_id.
+	ResultURL string `json:"result_url"`
+}
+
+// notifyBatch reports a finished batch, if it has a callback URL or its
+// namespace has a webhook. Delivery happens in the background.
+func (n *webhookNotifier) notifyBatch(b *JobBatch, st BatchStatus) {
+	cfg, target, secret := n.endpoint(b.CallbackURL, b.Namespace)
+	if target == "" {
+		return
+	}
+
+	event := BatchWebhookEvent{
+		Event:       WebhookEventBatchFinished,
+		BatchID:     b.ID,
+		RequestID:   b.RequestID,
+		Namespace:   b.Namespace,
+		State:       st.State,
+		Total:       len(b.Jobs),
+		Succeeded:   st.Succeeded,
+		Failed:      st.Failed,
+		Cancelled:   st.Cancelled,
+		CreatedAt:   b.CreatedAt,
+		CompletedAt: st.CompletedAt,
+		Jobs:        st.Jobs,
+		ResultURL:   strings.TrimSuffix(cfg.ResultBaseURL, "/") + "/api/v1/batches/" + b.ID,
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		n.logger.WithError(err).WithField("batch_id", b.ID).Error("Failed to encode batch webhook")
+		return
+	}
+	go n.deliver(webhookDelivery{
+		target:      target,
+		secret:      secret,
+		eventType:   WebhookEventBatchFinished,
+		body:        body,
+		maxAttempts: cfg.maxAttempts(),
+		fields: logrus.Fields{
+			"batch_id": b.ID,
+			"state":    st.State,
+		},
+	})
+}
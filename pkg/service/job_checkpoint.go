@@ -0,0 +1,99 @@
+package service
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var jobChunksResumedTotal = promauto.NewCounter(
+	prometheus.CounterOpts{
+		Name: "iskoces_job_chunks_resumed_total",
+		Help: "Total number of document chunks taken from a job's checkpoint instead of being translated again",
+	},
+)
+
+// JobCheckpoint holds the pieces of a document job translated so far, so a
+// job interrupted by a crash, a restart or an engine outage resumes from
+// them instead of from the start. It is kept with the job (and in the job
+// store) until the job finishes.
+type JobCheckpoint struct {
+	Title     string         `json:"title,omitempty"`
+	TitleDone bool           `json:"title_done,omitempty"`
+	ChunkSize int            `json:"chunk_size,omitempty"`  // Chunk size the markdown was split with
+	Chunks    int            `json:"chunks,omitempty"`      // Chunks the markdown was split into
+	Done      map[int]string `json:"done_chunks,omitempty"` // Translated chunks by 0-based index
+}
+
+// clone returns a deep copy of the checkpoint (nil for nil), so a record
+// can be encoded while chunks are still being added.
+func (cp *JobCheckpoint) clone() *JobCheckpoint {
+	if cp == nil {
+		return nil
+	}
+	c := *cp
+	c.Done = make(map[int]string, len(cp.Done))
+	for i, translated := range cp.Done {
+		c.Done[i] = translated
+	}
+	return &c
+}
+
+// checkpointedTitle returns the job's translated document title, if the
+// checkpoint has it.
+func (j *TranslationJob) checkpointedTitle() (string, bool) {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	if j.Checkpoint == nil || !j.Checkpoint.TitleDone {
+		return "", false
+	}
+	return j.Checkpoint.Title, true
+}
+
+// checkpointTitle records the translated document title and persists it.
+func (j *TranslationJob) checkpointTitle(title string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.Checkpoint == nil {
+		j.Checkpoint = &JobCheckpoint{}
+	}
+	j.Checkpoint.Title = title
+	j.Checkpoint.TitleDone = true
+	j.persist()
+}
+
+// resumeChunks returns a copy of the chunks already translated when the
+// markdown is split into chunks of chunkSize. A checkpoint made with
+// another split is discarded.
+func (j *TranslationJob) resumeChunks(chunkSize, chunks int) map[int]string {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.Checkpoint == nil {
+		j.Checkpoint = &JobCheckpoint{}
+	}
+	cp := j.Checkpoint
+	if cp.ChunkSize != chunkSize || cp.Chunks != chunks {
+		cp.ChunkSize = chunkSize
+		cp.Chunks = chunks
+		cp.Done = nil
+	}
+
+	done := make(map[int]string, len(cp.Done))
+	for i, translated := range cp.Done {
+		done[i] = translated
+	}
+	return done
+}
+
+// checkpointChunk records a translated chunk. It is persisted with the next
+// progress update.
+func (j *TranslationJob) checkpointChunk(i int, translated string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.Checkpoint == nil {
+		return
+	}
+	if j.Checkpoint.Done == nil {
+		j.Checkpoint.Done = make(map[int]string)
+	}
+	j.Checkpoint.Done[i] = translated
+}
@@ -0,0 +1,135 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/sirupsen/logrus"
+
+	nanabushv1 "github.com/dasmlab/iskoces/pkg/proto/v1"
+)
+
+// DefaultDeadLetterRetention is how long dead-letter jobs are kept.
+const DefaultDeadLetterRetention = 7 * 24 * time.Hour
+
+// ErrNotDeadLetter is returned when re-queueing a job that isn't on the
+// dead-letter list.
+var ErrNotDeadLetter = errors.New("job is not a dead-letter job")
+
+var jobsDeadLetteredTotal = promauto.NewCounter(
+	prometheus.CounterOpts{
+		Name: "iskoces_jobs_dead_lettered_total",
+		Help: "Total number of translation jobs that exhausted their retries and were put on the dead-letter list",
+	},
+)
+
+// deadLetter puts the job on the dead-letter list if it failed with a
+// transient error, i.e. retrying (later) may succeed. Callers hold j.mu.
+func (j *TranslationJob) deadLetter() {
+	if !j.ErrorClass.Retryable() {
+		return
+	}
+	j.DeadLetter = true
+	jobsDeadLetteredTotal.Inc()
+}
+
+// SetDeadLetterRetention sets how long dead-letter jobs are kept after they
+// fail (default DefaultDeadLetterRetention; 0 keeps them until re-queued or
+// deleted). Other finished jobs follow the queue's JobRetention.
+func (q *JobQueue) SetDeadLetterRetention(retention time.Duration) {
+	q.jobsMu.Lock()
+	defer q.jobsMu.Unlock()
+	q.deadLetterRetention = retention
+}
+
+// keepDeadLetter reports whether the finished job is kept because it is on
+// the dead-letter list and within its retention. Callers hold q.jobsMu.
+func (q *JobQueue) keepDeadLetter(job *TranslationJob, now time.Time) bool {
+	job.mu.RLock()
+	defer job.mu.RUnlock()
+	if !job.DeadLetter {
+		return false
+	}
+	return q.deadLetterRetention <= 0 || job.CompletedAt == nil || now.Sub(*job.CompletedAt) <= q.deadLetterRetention
+}
+
+// DeadLetterJobs returns a page of the dead-letter jobs in namespace (""
+// for all), newest first, as FilterJobs does.
+func (q *JobQueue) DeadLetterJobs(namespace string, pageSize int, pageToken string) ([]*TranslationJob, string, error) {
+	return q.FilterJobs(JobFilter{Namespace: namespace, DeadLetter: true}, pageSize, pageToken)
+}
+
+// RequeueDeadLetter submits a dead-letter job's request again as a new job
+// for the same client and returns it. The old job leaves the dead-letter
+// list, keeps its failed attempts and records the new job's ID, and is then
+// cleaned up like any finished job.
+func (q *JobQueue) RequeueDeadLetter(jobID string) (*TranslationJob, error) {
+	job, err := q.GetJob(jobID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Taking the job off the list first stops concurrent re-queues
+	job.mu.Lock()
+	if !job.DeadLetter {
+		job.mu.Unlock()
+		return nil, fmt.Errorf("%w: %s", ErrNotDeadLetter, jobID)
+	}
+	job.DeadLetter = false
+	req := job.request()
+	clientID := job.ClientID
+	job.mu.Unlock()
+
+	newID, err := q.CreateJob(context.Background(), req, clientID)
+	if err != nil {
+		job.mu.Lock()
+		job.DeadLetter = true
+		job.mu.Unlock()
+		return nil, err
+	}
+	requeued, err := q.GetJob(newID)
+	if err != nil {
+		return nil, err
+	}
+
+	job.mu.Lock()
+	job.RequeuedAs = newID
+	job.persist()
+	job.mu.Unlock()
+
+	q.logger.WithFields(logrus.Fields{
+		"job_id":     jobID,
+		"new_job_id": newID,
+		"request_id": req.JobId,
+	}).Info("Re-queued dead-letter translation job")
+	return requeued, nil
+}
+
+// request rebuilds the request the job was submitted with. Callers hold
+// j.mu.
+func (j *TranslationJob) request() *nanabushv1.TranslateRequest {
+	req := &nanabushv1.TranslateRequest{
+		JobId:           j.RequestID,
+		Namespace:       j.Namespace,
+		Primitive:       j.Primitive,
+		SourceLanguage:  j.SourceLang,
+		TargetLanguage:  j.TargetLang,
+		LocalizeFormats: j.LocalizeFormats,
+		Priority:        priorityToProto(j.Priority),
+		CallbackUrl:     j.CallbackURL,
+	}
+	if key, explicit := strings.CutPrefix(j.idempotencyKey, explicitKeyPrefix(j.Namespace)); explicit {
+		req.IdempotencyKey = key
+	}
+	if j.Document != nil {
+		req.Source = &nanabushv1.TranslateRequest_Doc{Doc: j.Document}
+	} else {
+		req.Source = &nanabushv1.TranslateRequest_Title{Title: j.Title}
+	}
+	return req
+}
@@ -0,0 +1,121 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/protobuf/proto"
+
+	nanabushv1 "github.com/dasmlab/iskoces/pkg/proto/v1"
+)
+
+var jobDedupTotal = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "iskoces_job_dedup_total",
+		Help: "Total number of translation jobs matching an earlier job's content, by outcome (reused, linked, fallback)",
+	},
+	[]string{"outcome"},
+)
+
+// DedupConfig controls job deduplication by content: a job whose content,
+// language pair and engine match a job that completed within Window reuses
+// its result, and one matching a job still in flight waits for it instead of
+// translating the same content twice.
+type DedupConfig struct {
+	Window time.Duration // How long a completed job's result is reused (0 disables deduplication)
+	Engine string        // Translation engine, part of the content hash
+}
+
+// SetDeduplication enables job deduplication. It must be called before
+// jobs are submitted, and has no effect with a shared job store, where jobs
+// run on whichever replica claims them. Delayed jobs aren't deduplicated.
+func (q *JobQueue) SetDeduplication(cfg DedupConfig) {
+	q.dedup = cfg
+}
+
+// contentHash identifies what a request translates and how: the engine,
+// language pair, primitive, format localization and content.
+func contentHash(engine string, req *nanabushv1.TranslateRequest) string {
+	h := sha256.New()
+	for _, part := range []string{engine, req.SourceLanguage, req.TargetLanguage, req.Primitive.String(), strconv.FormatBool(req.LocalizeFormats)} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	if doc := req.GetDoc(); doc != nil {
+		data, _ := proto.MarshalOptions{Deterministic: true}.Marshal(doc)
+		h.Write(data)
+	} else {
+		h.Write([]byte(req.GetTitle()))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// duplicateSource returns the job whose result a new job with hash can
+// reuse: one still queued or processing, or one that completed within the
+// dedup window with its result still available. Callers hold q.jobsMu.
+func (q *JobQueue) duplicateSource(hash string, now time.Time) *TranslationJob {
+	source := q.byContent[hash]
+	if source == nil {
+		return nil
+	}
+	source.mu.RLock()
+	defer source.mu.RUnlock()
+	switch {
+	case !source.Status.IsTerminal():
+		return source
+	case source.Status == JobStatusCompleted && source.CompletedAt != nil &&
+		now.Sub(*source.CompletedAt) <= q.dedup.Window && !resultExpired(source.ResultExpiresAt, now):
+		return source
+	}
+	return nil
+}
+
+// runDuplicate completes job with the result of source, the job it
+// duplicates, once source finishes. If source fails, is cancelled or its
+// result can't be read, job is scheduled to translate on its own.
+func (q *JobQueue) runDuplicate(job, source *TranslationJob) {
+	select {
+	case <-source.Done():
+	case <-job.Context().Done():
+		return
+	}
+
+	title, markdown, err := source.Result()
+	if err != nil {
+		jobDedupTotal.WithLabelValues("fallback").Inc()
+		q.logger.WithError(err).WithFields(logrus.Fields{
+			"job_id":       job.ID,
+			"duplicate_of": source.ID,
+		}).Info("Duplicate job's source has no result, translating it separately")
+		job.mu.Lock()
+		job.DuplicateOf = ""
+		job.mu.Unlock()
+		// Later duplicates follow this job instead
+		q.jobsMu.Lock()
+		if q.byContent[job.contentHash] == source {
+			q.byContent[job.contentHash] = job
+		}
+		q.jobsMu.Unlock()
+		if q.processor != nil {
+			q.scheduler.submit(job)
+		}
+		return
+	}
+
+	source.mu.RLock()
+	tokens := source.TokensUsed
+	source.mu.RUnlock()
+	job.UpdateStatus(JobStatusProcessing, fmt.Sprintf("Reusing the result of job %s", source.ID))
+	job.SetResult(title, markdown, tokens, 0)
+	q.logger.WithFields(logrus.Fields{
+		"job_id":       job.ID,
+		"request_id":   job.RequestID,
+		"duplicate_of": source.ID,
+	}).Info("Completed translation job with a duplicate job's result")
+}
@@ -0,0 +1,227 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/sirupsen/logrus"
+
+	"github.com/dasmlab/iskoces/pkg/translate"
+)
+
+// Job lifecycle event types.
+const (
+	JobEventCreated   = "created"
+	JobEventStarted   = "started"
+	JobEventProgress  = "progress"
+	JobEventCompleted = "completed"
+	JobEventFailed    = "failed"
+	JobEventCancelled = "cancelled"
+)
+
+const (
+	// DefaultJobEventTopic is the NATS subject prefix or Kafka topic events
+	// are published to when the events URL doesn't name one.
+	DefaultJobEventTopic = "iskoces.jobs"
+
+	// jobEventBuffer is how many events can wait to be published; further
+	// events are dropped rather than slowing down jobs.
+	jobEventBuffer = 1024
+	// jobEventBatch is the most events published at once.
+	jobEventBatch = 100
+	// jobEventFlushTimeout bounds publishing the buffered events at shutdown.
+	jobEventFlushTimeout = 5 * time.Second
+)
+
+var jobEventsTotal = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "iskoces_job_events_total",
+		Help: "Total number of job lifecycle events, by outcome (published, failed, dropped)",
+	},
+	[]string{"outcome"},
+)
+
+// JobEvent is a change in a job's lifecycle, published as JSON.
+type JobEvent struct {
+	Type            string               `json:"type"`
+	JobID           string               `json:"job_id"`
+	RequestID       string               `json:"request_id,omitempty"`
+	Namespace       string               `json:"namespace,omitempty"`
+	OperationName   string               `json:"operation_name"`
+	Status          TranslationJobStatus `json:"status"`
+	SourceLanguage  string               `json:"source_language"`
+	TargetLanguage  string               `json:"target_language"`
+	ProgressPercent int32                `json:"progress_percent"`
+	ProgressMessage string               `json:"progress_message,omitempty"`
+	Attempt         int                  `json:"attempt,omitempty"`
+	Error           string               `json:"error,omitempty"`
+	ErrorClass      translate.ErrorClass `json:"error_class,omitempty"`
+	Time            time.Time            `json:"time"`
+}
+
+// JobEventPublisher sends job events to a message broker.
+type JobEventPublisher interface {
+	// Publish sends events, in order. Events for one job must keep their
+	// order; events for different jobs may be interleaved.
+	Publish(events []JobEvent) error
+	// Close releases the publisher.
+	Close() error
+}
+
+// OpenJobEventPublisher opens a publisher for location: a
+// nats://[user:password@|token@]host[:port][/subject-prefix] (or tls://)
+// URL, or a kafka://host[:port][,host[:port]...][/topic] URL.
+func OpenJobEventPublisher(location string, logger *logrus.Logger) (JobEventPublisher, error) {
+	scheme, _, _ := strings.Cut(location, "://")
+	switch scheme {
+	case "nats", "tls":
+		return NewNATSEventPublisher(location, logger)
+	case "kafka":
+		return NewKafkaEventPublisher(location, logger)
+	default:
+		return nil, fmt.Errorf("unsupported job events URL %q (want nats://, tls:// or kafka://)", location)
+	}
+}
+
+// jobEventStream buffers job events and publishes them in the background,
+// so a slow or unreachable broker never holds up jobs.
+type jobEventStream struct {
+	publisher JobEventPublisher
+	logger    *logrus.Logger
+	events    chan JobEvent
+	quit      chan struct{} // Closed to stop; the buffered events are still published
+	stopOnce  sync.Once
+	stopped   chan struct{}
+
+	// holdFailures is set during shutdown when failed jobs will run again
+	// after the restart, so they aren't reported as failed.
+	holdFailures atomic.Bool
+}
+
+// newJobEventStream starts publishing events to publisher.
+func newJobEventStream(publisher JobEventPublisher, logger *logrus.Logger) *jobEventStream {
+	s := &jobEventStream{
+		publisher: publisher,
+		logger:    logger,
+		events:    make(chan JobEvent, jobEventBuffer),
+		quit:      make(chan struct{}),
+		stopped:   make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+// emit queues an event for the job's current state. Callers hold j.mu.
+func (s *jobEventStream) emit(eventType string, j *TranslationJob) {
+	if eventType == JobEventFailed && s.holdFailures.Load() {
+		return
+	}
+	event := JobEvent{
+		Type:            eventType,
+		JobID:           j.ID,
+		RequestID:       j.RequestID,
+		Namespace:       j.Namespace,
+		OperationName:   OperationName(j.ID),
+		Status:          j.Status,
+		SourceLanguage:  j.SourceLang,
+		TargetLanguage:  j.TargetLang,
+		ProgressPercent: j.ProgressPercent,
+		ProgressMessage: j.ProgressMessage,
+		Attempt:         j.Attempt,
+		Error:           j.Error,
+		ErrorClass:      j.ErrorClass,
+		Time:            time.Now(),
+	}
+	select {
+	case s.events <- event:
+	default:
+		jobEventsTotal.WithLabelValues("dropped").Inc()
+	}
+}
+
+// run publishes events in batches of whatever is waiting until the stream
+// is stopped and its buffer is empty.
+func (s *jobEventStream) run() {
+	defer close(s.stopped)
+	for {
+		var batch []JobEvent
+		select {
+		case event := <-s.events:
+			batch = append(batch, event)
+		case <-s.quit:
+		}
+	collect:
+		for len(batch) < jobEventBatch {
+			select {
+			case event := <-s.events:
+				batch = append(batch, event)
+			default:
+				break collect
+			}
+		}
+		if len(batch) == 0 {
+			return // Stopped, and nothing left to publish
+		}
+		s.publish(batch)
+	}
+}
+
+// publish sends a batch, trying once more if the first attempt fails (the
+// publishers reconnect after an error).
+func (s *jobEventStream) publish(batch []JobEvent) {
+	err := s.publisher.Publish(batch)
+	if err != nil {
+		err = s.publisher.Publish(batch)
+	}
+	if err != nil {
+		jobEventsTotal.WithLabelValues("failed").Add(float64(len(batch)))
+		s.logger.WithError(err).WithField("events", len(batch)).Warn("Failed to publish job events")
+		return
+	}
+	jobEventsTotal.WithLabelValues("published").Add(float64(len(batch)))
+}
+
+// stop publishes the events still buffered, waiting up to
+// jobEventFlushTimeout, and closes the publisher.
+func (s *jobEventStream) stop() {
+	s.stopOnce.Do(func() {
+		close(s.quit)
+		select {
+		case <-s.stopped:
+		case <-time.After(jobEventFlushTimeout):
+			s.logger.Warn("Timed out publishing the remaining job events")
+		}
+		if err := s.publisher.Close(); err != nil {
+			s.logger.WithError(err).Warn("Failed to close job event publisher")
+		}
+	})
+}
+
+// emitEvent publishes a lifecycle event for the job, if the queue publishes
+// events. Callers hold j.mu.
+func (j *TranslationJob) emitEvent(eventType string) {
+	if j.events != nil {
+		j.events.emit(eventType, j)
+	}
+}
+
+// SetEventPublisher makes the queue publish job lifecycle events (created,
+// started, progress, and the final completed, failed or cancelled) to
+// publisher. It must be called before jobs are submitted; StopEvents
+// flushes and closes it.
+func (q *JobQueue) SetEventPublisher(publisher JobEventPublisher) {
+	q.events = newJobEventStream(publisher, q.logger)
+}
+
+// StopEvents publishes the job events still buffered and closes the event
+// publisher, if there is one.
+func (q *JobQueue) StopEvents() {
+	if q.events != nil {
+		q.events.stop()
+	}
+}
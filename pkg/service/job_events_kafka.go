@@ -0,0 +1,516 @@
+package service
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"hash/fnv"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// kafkaTimeout bounds dialing and each request round trip.
+	kafkaTimeout = 10 * time.Second
+	// kafkaClientID identifies the producer to brokers.
+	kafkaClientID = "iskoces"
+
+	kafkaAPIProduce  = 0
+	kafkaAPIMetadata = 3
+)
+
+// kafkaErrors names the broker error codes a producer commonly sees.
+var kafkaErrors = map[int16]string{
+	3:  "UNKNOWN_TOPIC_OR_PARTITION",
+	5:  "LEADER_NOT_AVAILABLE",
+	6:  "NOT_LEADER_OR_FOLLOWER",
+	7:  "REQUEST_TIMED_OUT",
+	10: "MESSAGE_TOO_LARGE",
+	19: "NOT_ENOUGH_REPLICAS",
+	20: "NOT_ENOUGH_REPLICAS_AFTER_APPEND",
+	29: "TOPIC_AUTHORIZATION_FAILED",
+	87: "INVALID_RECORD",
+}
+
+// kafkaError is an error code returned by a broker.
+type kafkaError int16
+
+func (e kafkaError) Error() string {
+	if name, ok := kafkaErrors[int16(e)]; ok {
+		return "kafka: " + name
+	}
+	return fmt.Sprintf("kafka: error code %d", int16(e))
+}
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// KafkaEventPublisher publishes job events to a Kafka topic, keyed by job
+// ID so each job's events land on one partition in order. The event type
+// is also set as the "type" record header. It is a minimal producer:
+// plaintext connections without SASL, no compression, and acks from all
+// in-sync replicas.
+type KafkaEventPublisher struct {
+	mu        sync.Mutex
+	bootstrap []string
+	topic     string
+	logger    *logrus.Logger
+
+	brokers map[int32]string     // Broker addresses by node ID
+	leaders []int32              // Leader node ID by partition; nil until metadata is fetched
+	conns   map[int32]*kafkaConn // Open connections by node ID
+}
+
+// NewKafkaEventPublisher parses a URL of the form
+// kafka://host[:port][,host[:port]...][/topic] and fetches the topic's
+// metadata (creating the topic if the brokers allow it).
+func NewKafkaEventPublisher(location string, logger *logrus.Logger) (*KafkaEventPublisher, error) {
+	rest, ok := strings.CutPrefix(location, "kafka://")
+	if !ok {
+		return nil, fmt.Errorf("invalid Kafka URL %q", location)
+	}
+	hosts, topic, _ := strings.Cut(rest, "/")
+	p := &KafkaEventPublisher{
+		topic:   strings.Trim(topic, "/"),
+		logger:  logger,
+		brokers: make(map[int32]string),
+		conns:   make(map[int32]*kafkaConn),
+	}
+	if p.topic == "" {
+		p.topic = DefaultJobEventTopic
+	}
+	for _, host := range strings.Split(hosts, ",") {
+		if host == "" {
+			continue
+		}
+		if _, _, err := net.SplitHostPort(host); err != nil {
+			host = net.JoinHostPort(host, "9092")
+		}
+		p.bootstrap = append(p.bootstrap, host)
+	}
+	if len(p.bootstrap) == 0 {
+		return nil, errors.New("Kafka URL has no brokers")
+	}
+
+	if err := p.refreshMetadata(); err != nil {
+		p.closeConns()
+		return nil, err
+	}
+	return p, nil
+}
+
+// Publish sends events to the partition leaders. After an error the
+// metadata is fetched again before the next publish.
+func (p *KafkaEventPublisher) Publish(events []JobEvent) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.leaders == nil {
+		if err := p.refreshMetadata(); err != nil {
+			return err
+		}
+	}
+
+	// Group the events by partition, then the partitions by leader
+	now := time.Now()
+	partitions := make(map[int32][]kafkaRecord)
+	for _, event := range events {
+		value, err := json.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("failed to encode job event: %w", err)
+		}
+		h := fnv.New32a()
+		h.Write([]byte(event.JobID))
+		partition := int32(h.Sum32() % uint32(len(p.leaders)))
+		partitions[partition] = append(partitions[partition], kafkaRecord{
+			key:       []byte(event.JobID),
+			value:     value,
+			eventType: event.Type,
+		})
+	}
+	byLeader := make(map[int32][]int32)
+	for partition := range partitions {
+		leader := p.leaders[partition]
+		byLeader[leader] = append(byLeader[leader], partition)
+	}
+
+	for leader, leaderPartitions := range byLeader {
+		if err := p.produce(leader, leaderPartitions, partitions, now); err != nil {
+			p.leaders = nil
+			if conn := p.conns[leader]; conn != nil {
+				conn.close()
+				delete(p.conns, leader)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes the connections to the brokers.
+func (p *KafkaEventPublisher) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closeConns()
+	return nil
+}
+
+// closeConns closes all broker connections. Callers hold p.mu.
+func (p *KafkaEventPublisher) closeConns() {
+	for id, conn := range p.conns {
+		conn.close()
+		delete(p.conns, id)
+	}
+}
+
+// produce sends one leader's partitions in a Produce (v3) request.
+// Callers hold p.mu.
+func (p *KafkaEventPublisher) produce(leader int32, leaderPartitions []int32, records map[int32][]kafkaRecord, now time.Time) error {
+	conn, err := p.connTo(leader)
+	if err != nil {
+		return err
+	}
+
+	var req kafkaEncoder
+	req.int16(-1) // transactional_id: null
+	req.int16(-1) // acks: all in-sync replicas
+	req.int32(int32(kafkaTimeout / time.Millisecond))
+	req.int32(1) // topics
+	req.string(p.topic)
+	req.int32(int32(len(leaderPartitions)))
+	for _, partition := range leaderPartitions {
+		req.int32(partition)
+		req.bytes(encodeRecordBatch(records[partition], now))
+	}
+
+	resp, err := conn.roundTrip(kafkaAPIProduce, 3, req.buf)
+	if err != nil {
+		return err
+	}
+	d := kafkaDecoder{buf: resp}
+	for topics := d.int32(); topics > 0; topics-- {
+		d.string()
+		for n := d.int32(); n > 0; n-- {
+			partition := d.int32()
+			code := d.int16()
+			d.int64() // base_offset
+			d.int64() // log_append_time_ms
+			if code != 0 && d.err == nil {
+				return fmt.Errorf("failed to publish to %s partition %d: %w", p.topic, partition, kafkaError(code))
+			}
+		}
+	}
+	return d.err
+}
+
+// refreshMetadata fetches the brokers and the topic's partition leaders
+// (Metadata v4) from any broker that answers. Callers hold p.mu, except
+// from the constructor.
+func (p *KafkaEventPublisher) refreshMetadata() error {
+	var req kafkaEncoder
+	req.int32(1) // topics
+	req.string(p.topic)
+	req.bool(true) // allow_auto_topic_creation
+
+	addrs := append([]string(nil), p.bootstrap...)
+	for _, addr := range p.brokers {
+		addrs = append(addrs, addr)
+	}
+	var lastErr error
+	for _, addr := range addrs {
+		conn, err := dialKafka(addr)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		resp, err := conn.roundTrip(kafkaAPIMetadata, 4, req.buf)
+		conn.close()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		return p.applyMetadata(resp)
+	}
+	return fmt.Errorf("failed to fetch Kafka metadata: %w", lastErr)
+}
+
+// applyMetadata records a Metadata (v4) response. Callers hold p.mu.
+func (p *KafkaEventPublisher) applyMetadata(resp []byte) error {
+	d := kafkaDecoder{buf: resp}
+	d.int32() // throttle_time_ms
+	brokers := make(map[int32]string)
+	for n := d.int32(); n > 0 && d.err == nil; n-- {
+		id := d.int32()
+		host := d.string()
+		port := d.int32()
+		d.string() // rack
+		brokers[id] = net.JoinHostPort(host, strconv.Itoa(int(port)))
+	}
+	d.string() // cluster_id
+	d.int32()  // controller_id
+
+	var leaders []int32
+	for topics := d.int32(); topics > 0 && d.err == nil; topics-- {
+		code := d.int16()
+		name := d.string()
+		d.bool() // is_internal
+		for n := d.int32(); n > 0 && d.err == nil; n-- {
+			d.int16() // partition error_code
+			partition := d.int32()
+			leader := d.int32()
+			d.int32Array() // replica_nodes
+			d.int32Array() // isr_nodes
+			if name == p.topic && partition >= 0 {
+				if int(partition) >= len(leaders) {
+					leaders = append(leaders, make([]int32, int(partition)+1-len(leaders))...)
+				}
+				leaders[partition] = leader
+			}
+		}
+		if name == p.topic && code != 0 && d.err == nil {
+			// LEADER_NOT_AVAILABLE while the topic is being created
+			return fmt.Errorf("kafka topic %s: %w", p.topic, kafkaError(code))
+		}
+	}
+	if d.err != nil {
+		return fmt.Errorf("bad Kafka metadata response: %w", d.err)
+	}
+	if len(leaders) == 0 {
+		return fmt.Errorf("kafka topic %s has no partitions", p.topic)
+	}
+	for partition, leader := range leaders {
+		if _, ok := brokers[leader]; !ok {
+			return fmt.Errorf("kafka topic %s partition %d has no leader", p.topic, partition)
+		}
+	}
+
+	// Connections to brokers that moved are no longer valid
+	for id, conn := range p.conns {
+		if conn.addr != brokers[id] {
+			conn.close()
+			delete(p.conns, id)
+		}
+	}
+	p.brokers = brokers
+	p.leaders = leaders
+	return nil
+}
+
+// connTo returns a connection to a broker, dialling it if needed. Callers
+// hold p.mu.
+func (p *KafkaEventPublisher) connTo(id int32) (*kafkaConn, error) {
+	if conn := p.conns[id]; conn != nil {
+		return conn, nil
+	}
+	addr, ok := p.brokers[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown Kafka broker %d", id)
+	}
+	conn, err := dialKafka(addr)
+	if err != nil {
+		return nil, err
+	}
+	p.conns[id] = conn
+	return conn, nil
+}
+
+// kafkaConn is a connection to one broker, used for one request at a time.
+type kafkaConn struct {
+	addr          string
+	conn          net.Conn
+	reader        *bufio.Reader
+	correlationID int32
+}
+
+// dialKafka connects to a broker.
+func dialKafka(addr string) (*kafkaConn, error) {
+	conn, err := net.DialTimeout("tcp", addr, kafkaTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to Kafka broker %s: %w", addr, err)
+	}
+	return &kafkaConn{addr: addr, conn: conn, reader: bufio.NewReader(conn)}, nil
+}
+
+// roundTrip sends a request (header v1) and returns the response body
+// after its header (v0).
+func (c *kafkaConn) roundTrip(apiKey, version int16, body []byte) ([]byte, error) {
+	c.correlationID++
+	var req kafkaEncoder
+	req.int32(0) // size, filled in below
+	req.int16(apiKey)
+	req.int16(version)
+	req.int32(c.correlationID)
+	req.string(kafkaClientID)
+	req.buf = append(req.buf, body...)
+	binary.BigEndian.PutUint32(req.buf, uint32(len(req.buf)-4))
+
+	c.conn.SetDeadline(time.Now().Add(kafkaTimeout))
+	if _, err := c.conn.Write(req.buf); err != nil {
+		return nil, fmt.Errorf("kafka broker %s: %w", c.addr, err)
+	}
+	var size [4]byte
+	if _, err := io.ReadFull(c.reader, size[:]); err != nil {
+		return nil, fmt.Errorf("kafka broker %s: %w", c.addr, err)
+	}
+	resp := make([]byte, binary.BigEndian.Uint32(size[:]))
+	if _, err := io.ReadFull(c.reader, resp); err != nil {
+		return nil, fmt.Errorf("kafka broker %s: %w", c.addr, err)
+	}
+	if len(resp) < 4 || int32(binary.BigEndian.Uint32(resp)) != c.correlationID {
+		return nil, fmt.Errorf("kafka broker %s: response out of sequence", c.addr)
+	}
+	return resp[4:], nil
+}
+
+// close closes the connection.
+func (c *kafkaConn) close() {
+	c.conn.Close()
+}
+
+// kafkaRecord is one event to produce.
+type kafkaRecord struct {
+	key       []byte
+	value     []byte
+	eventType string
+}
+
+// encodeRecordBatch encodes records as an uncompressed record batch (magic
+// v2) timestamped now.
+func encodeRecordBatch(records []kafkaRecord, now time.Time) []byte {
+	var b kafkaEncoder
+	b.int64(0)  // base_offset
+	b.int32(0)  // batch_length, filled in below
+	b.int32(-1) // partition_leader_epoch
+	b.int8(2)   // magic
+	b.int32(0)  // crc, filled in below
+	crcStart := len(b.buf)
+	b.int16(0) // attributes: no compression, create time
+	b.int32(int32(len(records) - 1))
+	b.int64(now.UnixMilli()) // base_timestamp
+	b.int64(now.UnixMilli()) // max_timestamp
+	b.int64(-1)              // producer_id
+	b.int16(-1)              // producer_epoch
+	b.int32(-1)              // base_sequence
+	b.int32(int32(len(records)))
+	for i, record := range records {
+		var r kafkaEncoder
+		r.int8(0)   // attributes
+		r.varint(0) // timestamp_delta
+		r.varint(int64(i))
+		r.varint(int64(len(record.key)))
+		r.buf = append(r.buf, record.key...)
+		r.varint(int64(len(record.value)))
+		r.buf = append(r.buf, record.value...)
+		r.varint(1) // headers
+		r.varint(int64(len("type")))
+		r.buf = append(r.buf, "type"...)
+		r.varint(int64(len(record.eventType)))
+		r.buf = append(r.buf, record.eventType...)
+
+		b.varint(int64(len(r.buf)))
+		b.buf = append(b.buf, r.buf...)
+	}
+	binary.BigEndian.PutUint32(b.buf[8:], uint32(len(b.buf)-12))
+	binary.BigEndian.PutUint32(b.buf[crcStart-4:], crc32.Checksum(b.buf[crcStart:], castagnoli))
+	return b.buf
+}
+
+// kafkaEncoder appends Kafka protocol primitives.
+type kafkaEncoder struct {
+	buf []byte
+}
+
+func (e *kafkaEncoder) int8(v int8)    { e.buf = append(e.buf, byte(v)) }
+func (e *kafkaEncoder) int16(v int16)  { e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(v)) }
+func (e *kafkaEncoder) int32(v int32)  { e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(v)) }
+func (e *kafkaEncoder) int64(v int64)  { e.buf = binary.BigEndian.AppendUint64(e.buf, uint64(v)) }
+func (e *kafkaEncoder) varint(v int64) { e.buf = binary.AppendVarint(e.buf, v) }
+
+func (e *kafkaEncoder) bool(v bool) {
+	if v {
+		e.int8(1)
+	} else {
+		e.int8(0)
+	}
+}
+
+func (e *kafkaEncoder) string(s string) {
+	e.int16(int16(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+func (e *kafkaEncoder) bytes(b []byte) {
+	e.int32(int32(len(b)))
+	e.buf = append(e.buf, b...)
+}
+
+// kafkaDecoder reads Kafka protocol primitives. After the first short read
+// err is set and every further read returns zero.
+type kafkaDecoder struct {
+	buf []byte
+	err error
+}
+
+// next returns the next n bytes, or nil if there aren't that many.
+func (d *kafkaDecoder) next(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(d.buf) {
+		d.err = io.ErrUnexpectedEOF
+		return nil
+	}
+	b := d.buf[:n]
+	d.buf = d.buf[n:]
+	return b
+}
+
+func (d *kafkaDecoder) int16() int16 {
+	if b := d.next(2); b != nil {
+		return int16(binary.BigEndian.Uint16(b))
+	}
+	return 0
+}
+
+func (d *kafkaDecoder) int32() int32 {
+	if b := d.next(4); b != nil {
+		return int32(binary.BigEndian.Uint32(b))
+	}
+	return 0
+}
+
+func (d *kafkaDecoder) int64() int64 {
+	if b := d.next(8); b != nil {
+		return int64(binary.BigEndian.Uint64(b))
+	}
+	return 0
+}
+
+func (d *kafkaDecoder) bool() bool {
+	b := d.next(1)
+	return b != nil && b[0] != 0
+}
+
+// string reads a string; a null string reads as "".
+func (d *kafkaDecoder) string() string {
+	n := d.int16()
+	if n < 0 {
+		return ""
+	}
+	return string(d.next(int(n)))
+}
+
+// int32Array skips an array of int32s.
+func (d *kafkaDecoder) int32Array() {
+	if n := d.int32(); n > 0 {
+		d.next(4 * int(n))
+	}
+}
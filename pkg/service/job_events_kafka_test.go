@@ -0,0 +1,586 @@
+package service
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"hash/crc32"
+	"hash/fnv"
+	"io"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// kafkaRequestHeader is the header of a request the fake broker received.
+type kafkaRequestHeader struct {
+	apiKey        int16
+	version       int16
+	correlationID int32
+	clientID      string
+}
+
+// producedRecord is a record the fake broker decoded from a Produce request.
+type producedRecord struct {
+	partition int32
+	key       string
+	value     []byte
+	headers   map[string]string
+}
+
+// fakeKafka is a single Kafka broker speaking Metadata v4 and Produce v3,
+// leading every partition of every topic. It checks the framing, the
+// record batch CRC and lengths of everything it is sent.
+type fakeKafka struct {
+	t          *testing.T
+	listener   net.Listener
+	partitions int32
+
+	mu       sync.Mutex
+	accepted int
+	conns    []net.Conn
+	headers  []kafkaRequestHeader
+	acks     []int16
+	topics   []string
+	records  []producedRecord
+	// metadataErr is the topic error code in Metadata responses
+	metadataErr int16
+	// produceErr answers a partition in a Produce request; 0 accepts it
+	produceErr func(partition int32) int16
+	// misbehave breaks the next Produce response: "correlation" answers
+	// with the wrong correlation ID, "truncate" cuts the response short
+	misbehave string
+}
+
+func newFakeKafka(t *testing.T, partitions int32) *fakeKafka {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeKafka{t: t, listener: listener, partitions: partitions}
+	t.Cleanup(func() {
+		listener.Close()
+		s.dropConns()
+	})
+	go s.serve()
+	return s
+}
+
+func (s *fakeKafka) url(topic string) string {
+	return "kafka://" + s.listener.Addr().String() + "/" + topic
+}
+
+func (s *fakeKafka) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.accepted++
+		s.conns = append(s.conns, conn)
+		s.mu.Unlock()
+		go s.handle(conn)
+	}
+}
+
+// dropConns closes every client connection, as a broker restart would.
+func (s *fakeKafka) dropConns() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+	s.conns = nil
+}
+
+// set changes the broker's behaviour under its lock.
+func (s *fakeKafka) set(f func(s *fakeKafka)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f(s)
+}
+
+// requests returns the API keys of the requests received, in order.
+func (s *fakeKafka) requests() []int16 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var keys []int16
+	for _, h := range s.headers {
+		keys = append(keys, h.apiKey)
+	}
+	return keys
+}
+
+func (s *fakeKafka) handle(conn net.Conn) {
+	defer conn.Close()
+	for {
+		var size [4]byte
+		if _, err := io.ReadFull(conn, size[:]); err != nil {
+			return
+		}
+		req := make([]byte, binary.BigEndian.Uint32(size[:]))
+		if _, err := io.ReadFull(conn, req); err != nil {
+			s.t.Errorf("request shorter than its size prefix: %v", err)
+			return
+		}
+		d := kafkaDecoder{buf: req}
+		h := kafkaRequestHeader{
+			apiKey:        d.int16(),
+			version:       d.int16(),
+			correlationID: d.int32(),
+			clientID:      d.string(),
+		}
+		if d.err != nil {
+			s.t.Errorf("bad request header: %v", d.err)
+			return
+		}
+		s.mu.Lock()
+		s.headers = append(s.headers, h)
+		s.mu.Unlock()
+
+		var body []byte
+		switch {
+		case h.apiKey == kafkaAPIMetadata && h.version == 4:
+			body = s.metadata(&d)
+		case h.apiKey == kafkaAPIProduce && h.version == 3:
+			body = s.produce(&d)
+		default:
+			s.t.Errorf("unexpected request: API key %d version %d", h.apiKey, h.version)
+			return
+		}
+		if body == nil {
+			return
+		}
+		if len(d.buf) != 0 {
+			s.t.Errorf("%d bytes left over after API key %d request", len(d.buf), h.apiKey)
+		}
+
+		correlationID := h.correlationID
+		s.mu.Lock()
+		misbehave := s.misbehave
+		if h.apiKey == kafkaAPIProduce {
+			s.misbehave = ""
+		}
+		s.mu.Unlock()
+		if h.apiKey == kafkaAPIProduce {
+			switch misbehave {
+			case "correlation":
+				correlationID++
+			case "truncate":
+				body = body[:len(body)/2]
+			}
+		}
+		var resp kafkaEncoder
+		resp.int32(int32(4 + len(body)))
+		resp.int32(correlationID)
+		resp.buf = append(resp.buf, body...)
+		if _, err := conn.Write(resp.buf); err != nil {
+			return
+		}
+	}
+}
+
+// metadata answers a Metadata v4 request: this broker leads every
+// partition of each topic asked for.
+func (s *fakeKafka) metadata(d *kafkaDecoder) []byte {
+	var topics []string
+	for n := d.int32(); n > 0; n-- {
+		topics = append(topics, d.string())
+	}
+	if !d.bool() {
+		s.t.Error("metadata request doesn't allow auto topic creation")
+	}
+	if d.err != nil {
+		s.t.Errorf("bad metadata request: %v", d.err)
+		return nil
+	}
+	host, portStr, _ := net.SplitHostPort(s.listener.Addr().String())
+	port, _ := strconv.Atoi(portStr)
+	s.mu.Lock()
+	code := s.metadataErr
+	s.mu.Unlock()
+
+	var resp kafkaEncoder
+	resp.int32(0) // throttle_time_ms
+	resp.int32(1) // brokers
+	resp.int32(7) // node_id
+	resp.string(host)
+	resp.int32(int32(port))
+	resp.int16(-1) // rack: null
+	resp.string("fake-cluster")
+	resp.int32(7) // controller_id
+	resp.int32(int32(len(topics)))
+	for _, topic := range topics {
+		resp.int16(code)
+		resp.string(topic)
+		resp.bool(false)
+		if code != 0 {
+			resp.int32(0)
+			continue
+		}
+		resp.int32(s.partitions)
+		for partition := int32(0); partition < s.partitions; partition++ {
+			resp.int16(0)
+			resp.int32(partition)
+			resp.int32(7) // leader
+			resp.int32(1) // replica_nodes
+			resp.int32(7)
+			resp.int32(1) // isr_nodes
+			resp.int32(7)
+		}
+	}
+	return resp.buf
+}
+
+// produce answers a Produce v3 request after decoding its record batches.
+func (s *fakeKafka) produce(d *kafkaDecoder) []byte {
+	if transactionalID := d.int16(); transactionalID != -1 {
+		s.t.Errorf("transactional_id length = %d, want null", transactionalID)
+	}
+	acks := d.int16()
+	d.int32() // timeout_ms
+	type partitionResult struct {
+		partition int32
+		code      int16
+	}
+	var resp kafkaEncoder
+	topics := d.int32()
+	resp.int32(topics)
+	for ; topics > 0 && d.err == nil; topics-- {
+		topic := d.string()
+		var results []partitionResult
+		for n := d.int32(); n > 0 && d.err == nil; n-- {
+			partition := d.int32()
+			size := d.int32()
+			batch := d.next(int(size))
+			if d.err != nil {
+				break
+			}
+			records, ok := s.decodeBatch(partition, batch)
+			if !ok {
+				return nil
+			}
+			s.mu.Lock()
+			code := int16(0)
+			if s.produceErr != nil {
+				code = s.produceErr(partition)
+			}
+			if code == 0 {
+				s.records = append(s.records, records...)
+			}
+			s.mu.Unlock()
+			results = append(results, partitionResult{partition, code})
+		}
+		s.mu.Lock()
+		s.acks = append(s.acks, acks)
+		s.topics = append(s.topics, topic)
+		s.mu.Unlock()
+
+		resp.string(topic)
+		resp.int32(int32(len(results)))
+		for _, r := range results {
+			resp.int32(r.partition)
+			resp.int16(r.code)
+			resp.int64(0)  // base_offset
+			resp.int64(-1) // log_append_time_ms
+		}
+	}
+	if d.err != nil {
+		s.t.Errorf("bad produce request: %v", d.err)
+		return nil
+	}
+	resp.int32(0) // throttle_time_ms
+	return resp.buf
+}
+
+// decodeBatch decodes a magic v2 record batch, checking its length, CRC
+// and record framing.
+func (s *fakeKafka) decodeBatch(partition int32, batch []byte) ([]producedRecord, bool) {
+	if len(batch) < 61 {
+		s.t.Errorf("record batch of %d bytes is shorter than its header", len(batch))
+		return nil, false
+	}
+	if got := binary.BigEndian.Uint32(batch[8:]); int(got) != len(batch)-12 {
+		s.t.Errorf("batch_length = %d, want %d", got, len(batch)-12)
+		return nil, false
+	}
+	if magic := batch[16]; magic != 2 {
+		s.t.Errorf("magic = %d, want 2", magic)
+		return nil, false
+	}
+	if got, want := binary.BigEndian.Uint32(batch[17:]), crc32.Checksum(batch[21:], crc32.MakeTable(crc32.Castagnoli)); got != want {
+		s.t.Errorf("batch CRC = %#x, want %#x", got, want)
+		return nil, false
+	}
+	if attributes := binary.BigEndian.Uint16(batch[21:]); attributes != 0 {
+		s.t.Errorf("batch attributes = %#x, want uncompressed", attributes)
+	}
+	lastOffsetDelta := int32(binary.BigEndian.Uint32(batch[23:]))
+	count := int32(binary.BigEndian.Uint32(batch[57:]))
+	if lastOffsetDelta != count-1 {
+		s.t.Errorf("last_offset_delta = %d for %d records", lastOffsetDelta, count)
+	}
+
+	r := bytes.NewReader(batch[61:])
+	varint := func() int64 {
+		v, err := binary.ReadVarint(r)
+		if err != nil {
+			s.t.Errorf("bad varint in record: %v", err)
+		}
+		return v
+	}
+	field := func() []byte {
+		b := make([]byte, varint())
+		io.ReadFull(r, b)
+		return b
+	}
+	var records []producedRecord
+	for i := int32(0); i < count; i++ {
+		length := varint()
+		start := r.Len()
+		r.ReadByte() // attributes
+		varint()     // timestamp_delta
+		if delta := varint(); delta != int64(i) {
+			s.t.Errorf("record %d offset_delta = %d", i, delta)
+		}
+		rec := producedRecord{partition: partition, key: string(field()), value: field(), headers: map[string]string{}}
+		for n := varint(); n > 0; n-- {
+			key := string(field())
+			rec.headers[key] = string(field())
+		}
+		if read := int64(start - r.Len()); read != length {
+			s.t.Errorf("record %d length = %d, but it has %d bytes", i, length, read)
+			return nil, false
+		}
+		records = append(records, rec)
+	}
+	if r.Len() != 0 {
+		s.t.Errorf("%d bytes left over after %d records", r.Len(), count)
+		return nil, false
+	}
+	return records, true
+}
+
+// kafkaPartition is the partition a job's events go to.
+func kafkaPartition(jobID string, partitions int32) int32 {
+	h := fnv.New32a()
+	h.Write([]byte(jobID))
+	return int32(h.Sum32() % uint32(partitions))
+}
+
+func TestKafkaPublish(t *testing.T) {
+	broker := newFakeKafka(t, 3)
+	pub, err := NewKafkaEventPublisher(broker.url("job-events"), quietLogger())
+	if err != nil {
+		t.Fatalf("NewKafkaEventPublisher() = %v", err)
+	}
+	defer pub.Close()
+
+	events := testEvents(6)
+	if err := pub.Publish(events); err != nil {
+		t.Fatalf("Publish() = %v", err)
+	}
+
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+	for i, h := range broker.headers {
+		if h.clientID != kafkaClientID {
+			t.Errorf("request %d client ID = %q, want %q", i, h.clientID, kafkaClientID)
+		}
+	}
+	for i, acks := range broker.acks {
+		if acks != -1 {
+			t.Errorf("produce %d acks = %d, want -1 (all in-sync replicas)", i, acks)
+		}
+		if broker.topics[i] != "job-events" {
+			t.Errorf("produce %d topic = %s, want job-events", i, broker.topics[i])
+		}
+	}
+	if len(broker.records) != len(events) {
+		t.Fatalf("broker got %d records, want %d", len(broker.records), len(events))
+	}
+
+	// Each job's records are on its partition, in order
+	byJob := make(map[string][]producedRecord)
+	for _, rec := range broker.records {
+		if want := kafkaPartition(rec.key, 3); rec.partition != want {
+			t.Errorf("record for %s on partition %d, want %d", rec.key, rec.partition, want)
+		}
+		byJob[rec.key] = append(byJob[rec.key], rec)
+	}
+	for _, event := range events {
+		recs := byJob[event.JobID]
+		if len(recs) == 0 {
+			t.Fatalf("no record left for event %+v", event)
+		}
+		rec := recs[0]
+		byJob[event.JobID] = recs[1:]
+		var got JobEvent
+		if err := json.Unmarshal(rec.value, &got); err != nil {
+			t.Errorf("record value isn't JSON: %v", err)
+			continue
+		}
+		if got != event {
+			t.Errorf("record = %+v, want %+v", got, event)
+		}
+		if rec.headers["type"] != event.Type || len(rec.headers) != 1 {
+			t.Errorf("record headers = %v, want type %s", rec.headers, event.Type)
+		}
+	}
+}
+
+func TestKafkaDefaultTopicAndBootstrap(t *testing.T) {
+	broker := newFakeKafka(t, 1)
+	// The first bootstrap broker is down; the second answers
+	down, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	downAddr := down.Addr().String()
+	down.Close()
+
+	pub, err := NewKafkaEventPublisher("kafka://"+downAddr+","+broker.listener.Addr().String(), quietLogger())
+	if err != nil {
+		t.Fatalf("NewKafkaEventPublisher() = %v", err)
+	}
+	defer pub.Close()
+	if err := pub.Publish(testEvents(1)); err != nil {
+		t.Fatalf("Publish() = %v", err)
+	}
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+	if broker.topics[0] != DefaultJobEventTopic {
+		t.Errorf("topic = %s, want %s", broker.topics[0], DefaultJobEventTopic)
+	}
+}
+
+func TestKafkaMetadataErrors(t *testing.T) {
+	t.Run("topic error", func(t *testing.T) {
+		broker := newFakeKafka(t, 1)
+		broker.set(func(s *fakeKafka) { s.metadataErr = 5 })
+		_, err := NewKafkaEventPublisher(broker.url("job-events"), quietLogger())
+		if err == nil || !strings.Contains(err.Error(), "LEADER_NOT_AVAILABLE") {
+			t.Errorf("NewKafkaEventPublisher() = %v, want LEADER_NOT_AVAILABLE", err)
+		}
+	})
+	t.Run("no partitions", func(t *testing.T) {
+		broker := newFakeKafka(t, 0)
+		_, err := NewKafkaEventPublisher(broker.url("job-events"), quietLogger())
+		if err == nil || !strings.Contains(err.Error(), "no partitions") {
+			t.Errorf("NewKafkaEventPublisher() = %v, want a no partitions error", err)
+		}
+	})
+	t.Run("no brokers", func(t *testing.T) {
+		for _, url := range []string{"kafka://", "kafka:///topic", "nats://localhost"} {
+			if _, err := NewKafkaEventPublisher(url, quietLogger()); err == nil {
+				t.Errorf("NewKafkaEventPublisher(%q) succeeded", url)
+			}
+		}
+	})
+}
+
+func TestKafkaProduceError(t *testing.T) {
+	broker := newFakeKafka(t, 1)
+	pub, err := NewKafkaEventPublisher(broker.url("job-events"), quietLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pub.Close()
+
+	// The leader moved: the publish fails and the metadata is fetched again
+	// before the next one
+	broker.set(func(s *fakeKafka) {
+		s.produceErr = func(int32) int16 {
+			s.produceErr = nil
+			return 6
+		}
+	})
+	err = pub.Publish(testEvents(1))
+	if err == nil || !strings.Contains(err.Error(), "NOT_LEADER_OR_FOLLOWER") {
+		t.Errorf("Publish() = %v, want NOT_LEADER_OR_FOLLOWER", err)
+	}
+	if err := pub.Publish(testEvents(1)); err != nil {
+		t.Fatalf("Publish() after the error = %v", err)
+	}
+	want := []int16{kafkaAPIMetadata, kafkaAPIProduce, kafkaAPIMetadata, kafkaAPIProduce}
+	if got := broker.requests(); !slices.Equal(got, want) {
+		t.Errorf("requests = %v, want %v", got, want)
+	}
+}
+
+func TestKafkaBadResponse(t *testing.T) {
+	for _, misbehave := range []string{"correlation", "truncate"} {
+		t.Run(misbehave, func(t *testing.T) {
+			broker := newFakeKafka(t, 1)
+			pub, err := NewKafkaEventPublisher(broker.url("job-events"), quietLogger())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer pub.Close()
+
+			broker.set(func(s *fakeKafka) { s.misbehave = misbehave })
+			if err := pub.Publish(testEvents(1)); err == nil {
+				t.Error("Publish() with a bad response succeeded")
+			}
+			// The connection is dropped, so the next response isn't taken
+			// for the broken one's
+			if err := pub.Publish(testEvents(1)); err != nil {
+				t.Fatalf("Publish() after a bad response = %v", err)
+			}
+		})
+	}
+}
+
+func TestKafkaReconnect(t *testing.T) {
+	broker := newFakeKafka(t, 2)
+	pub, err := NewKafkaEventPublisher(broker.url("job-events"), quietLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pub.Close()
+	if err := pub.Publish(testEvents(1)); err != nil {
+		t.Fatalf("Publish() = %v", err)
+	}
+
+	// The broker restarts: the publish on the dead connection fails, and
+	// the next one fetches the metadata and dials again
+	broker.dropConns()
+	if err := pub.Publish(testEvents(1)); err == nil {
+		t.Error("Publish() on a closed connection succeeded")
+	}
+	if err := pub.Publish(testEvents(1)); err != nil {
+		t.Fatalf("Publish() after the broker restarted = %v", err)
+	}
+
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+	// Metadata and produce before the restart, then again after it
+	if broker.accepted != 4 {
+		t.Errorf("broker accepted %d connections, want 4", broker.accepted)
+	}
+	if len(broker.records) != 2 {
+		t.Errorf("broker got %d records, want 2", len(broker.records))
+	}
+}
+
+func TestKafkaBrokerDown(t *testing.T) {
+	broker := newFakeKafka(t, 1)
+	pub, err := NewKafkaEventPublisher(broker.url("job-events"), quietLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pub.Close()
+
+	broker.listener.Close()
+	broker.dropConns()
+	for i := 0; i < 2; i++ {
+		if err := pub.Publish(testEvents(1)); err == nil {
+			t.Errorf("Publish() %d with the broker down succeeded", i)
+		}
+	}
+}
@@ -0,0 +1,317 @@
+package service
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// natsTimeout bounds dialing, the handshake, and each publish round trip.
+const natsTimeout = 5 * time.Second
+
+// NATSEventPublisher publishes job events to NATS (core NATS, no
+// JetStream), on subjects <prefix>.<event type>, e.g.
+// iskoces.jobs.completed. Each batch ends with a PING, so Publish returns
+// once the server has accepted the events.
+type NATSEventPublisher struct {
+	mu      sync.Mutex
+	addr    string
+	tls     *tls.Config // nil for plain TCP
+	connect []byte      // CONNECT line sent after dialling
+	prefix  string
+	logger  *logrus.Logger
+
+	conn *natsConn
+}
+
+// NewNATSEventPublisher parses a nats:// or tls:// URL of the form
+// nats://[user:password@|token@]host[:port][/subject-prefix] and connects.
+func NewNATSEventPublisher(location string, logger *logrus.Logger) (*NATSEventPublisher, error) {
+	u, err := url.Parse(location)
+	if err != nil {
+		return nil, fmt.Errorf("invalid NATS URL: %w", err)
+	}
+	if u.Hostname() == "" {
+		return nil, errors.New("NATS URL has no host")
+	}
+	p := &NATSEventPublisher{
+		addr:   u.Host,
+		prefix: strings.Trim(u.Path, "/"),
+		logger: logger,
+	}
+	if u.Port() == "" {
+		p.addr = net.JoinHostPort(u.Hostname(), "4222")
+	}
+	if p.prefix == "" {
+		p.prefix = DefaultJobEventTopic
+	}
+	if u.Scheme == "tls" {
+		p.tls = &tls.Config{ServerName: u.Hostname(), MinVersion: tls.VersionTLS12}
+	}
+
+	options := map[string]any{
+		"verbose":      false,
+		"pedantic":     false,
+		"tls_required": p.tls != nil,
+		"name":         "iskoces",
+		"lang":         "go",
+		"version":      "1",
+		"protocol":     1,
+	}
+	if u.User != nil {
+		if password, ok := u.User.Password(); ok {
+			options["user"] = u.User.Username()
+			options["pass"] = password
+		} else {
+			options["auth_token"] = u.User.Username()
+		}
+	}
+	connect, _ := json.Marshal(options)
+	p.connect = fmt.Appendf(nil, "CONNECT %s\r\nPING\r\n", connect)
+
+	p.conn, err = p.dial()
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to NATS at %s: %w", p.addr, err)
+	}
+	return p, nil
+}
+
+// Publish sends events and waits for the server to acknowledge them. An
+// error the server reports for them, such as a permissions violation,
+// fails the publish.
+func (p *NATSEventPublisher) Publish(events []JobEvent) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.conn != nil && p.conn.failed() {
+		p.conn.close()
+		p.conn = nil
+	}
+	if p.conn == nil {
+		conn, err := p.dial()
+		if err != nil {
+			return fmt.Errorf("failed to connect to NATS at %s: %w", p.addr, err)
+		}
+		p.conn = conn
+	}
+	if err := p.conn.publish(p.prefix, events); err != nil {
+		p.conn.close()
+		p.conn = nil
+		return err
+	}
+	return nil
+}
+
+// Close closes the connection.
+func (p *NATSEventPublisher) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.conn != nil {
+		p.conn.close()
+		p.conn = nil
+	}
+	return nil
+}
+
+// dial connects and completes the handshake: the server's INFO, an
+// optional TLS upgrade, then CONNECT answered by PONG.
+func (p *NATSEventPublisher) dial() (*natsConn, error) {
+	conn, err := net.DialTimeout("tcp", p.addr, natsTimeout)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(natsTimeout))
+	reader := bufio.NewReader(conn)
+
+	line, err := readNATSLine(reader)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	info, ok := strings.CutPrefix(line, "INFO ")
+	if !ok {
+		conn.Close()
+		return nil, fmt.Errorf("unexpected NATS greeting %q", line)
+	}
+	var serverInfo struct {
+		TLSRequired bool `json:"tls_required"`
+	}
+	if err := json.Unmarshal([]byte(info), &serverInfo); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("bad NATS INFO: %w", err)
+	}
+	if p.tls != nil {
+		tlsConn := tls.Client(conn, p.tls)
+		if err := tlsConn.Handshake(); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = tlsConn
+		reader = bufio.NewReader(conn)
+	} else if serverInfo.TLSRequired {
+		conn.Close()
+		return nil, errors.New("NATS server requires TLS (use a tls:// URL)")
+	}
+
+	if _, err := conn.Write(p.connect); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	for {
+		line, err := readNATSLine(reader)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		if line == "PONG" {
+			break
+		}
+		if msg, ok := strings.CutPrefix(line, "-ERR "); ok {
+			conn.Close()
+			return nil, fmt.Errorf("nats: %s", strings.Trim(msg, "'"))
+		}
+		// INFO updates and +OK need no answer
+	}
+	conn.SetDeadline(time.Time{})
+
+	c := &natsConn{
+		conn:   conn,
+		writer: bufio.NewWriter(conn),
+		pongs:  make(chan struct{}, 1),
+		dead:   make(chan struct{}),
+		logger: p.logger,
+	}
+	go c.read(reader)
+	return c, nil
+}
+
+// natsConn is one connection to the NATS server. A goroutine reads what the
+// server sends: PONGs acknowledging publishes, and PINGs to answer.
+type natsConn struct {
+	conn   net.Conn
+	mu     sync.Mutex // guards writer
+	writer *bufio.Writer
+	pongs  chan struct{}
+	logger *logrus.Logger
+
+	dead     chan struct{} // Closed when the connection fails
+	deadOnce sync.Once
+
+	errMu     sync.Mutex
+	serverErr string // Last -ERR since the publish in progress began
+}
+
+// publish writes events and a PING, and waits for the PONG. The server
+// answers in order, so an -ERR before the PONG is about these events.
+func (c *natsConn) publish(prefix string, events []JobEvent) error {
+	c.errMu.Lock()
+	c.serverErr = ""
+	c.errMu.Unlock()
+
+	c.mu.Lock()
+	c.conn.SetWriteDeadline(time.Now().Add(natsTimeout))
+	for _, event := range events {
+		payload, err := json.Marshal(event)
+		if err != nil {
+			c.mu.Unlock()
+			return fmt.Errorf("failed to encode job event: %w", err)
+		}
+		fmt.Fprintf(c.writer, "PUB %s.%s %d\r\n", prefix, event.Type, len(payload))
+		c.writer.Write(payload)
+		c.writer.WriteString("\r\n")
+	}
+	c.writer.WriteString("PING\r\n")
+	err := c.writer.Flush()
+	c.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to publish to NATS: %w", err)
+	}
+
+	select {
+	case <-c.pongs:
+		c.errMu.Lock()
+		serverErr := c.serverErr
+		c.errMu.Unlock()
+		if serverErr != "" {
+			return fmt.Errorf("nats: %s", serverErr)
+		}
+		return nil
+	case <-c.dead:
+		return errors.New("NATS connection closed")
+	case <-time.After(natsTimeout):
+		return errors.New("timed out waiting for NATS to acknowledge events")
+	}
+}
+
+// read handles what the server sends until the connection fails.
+func (c *natsConn) read(reader *bufio.Reader) {
+	defer c.fail()
+	for {
+		line, err := readNATSLine(reader)
+		if err != nil {
+			return
+		}
+		switch {
+		case line == "PING":
+			c.mu.Lock()
+			c.writer.WriteString("PONG\r\n")
+			err = c.writer.Flush()
+			c.mu.Unlock()
+			if err != nil {
+				return
+			}
+		case line == "PONG":
+			select {
+			case c.pongs <- struct{}{}:
+			default:
+			}
+		case strings.HasPrefix(line, "-ERR "):
+			// e.g. a permissions violation; the server closes the
+			// connection after most errors
+			msg := strings.Trim(line[len("-ERR "):], "'")
+			c.errMu.Lock()
+			c.serverErr = msg
+			c.errMu.Unlock()
+			c.logger.WithField("error", msg).Warn("NATS server reported an error")
+		}
+	}
+}
+
+// fail marks the connection dead.
+func (c *natsConn) fail() {
+	c.deadOnce.Do(func() { close(c.dead) })
+}
+
+// failed reports whether the connection has failed.
+func (c *natsConn) failed() bool {
+	select {
+	case <-c.dead:
+		return true
+	default:
+		return false
+	}
+}
+
+// close closes the connection.
+func (c *natsConn) close() {
+	c.conn.Close()
+	c.fail()
+}
+
+// readNATSLine reads one protocol line without its CRLF.
+func readNATSLine(reader *bufio.Reader) (string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
@@ -0,0 +1,397 @@
+package service
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// natsMessage is a PUB the fake server received.
+type natsMessage struct {
+	subject string
+	payload []byte
+}
+
+// fakeNATS is a NATS server speaking enough of the client protocol for a
+// publisher: INFO, CONNECT, PUB and PING/PONG.
+type fakeNATS struct {
+	t        *testing.T
+	listener net.Listener
+	info     string
+
+	// connect answers a CONNECT line; "" accepts it
+	connect func(options map[string]any) string
+
+	mu sync.Mutex
+	// pub answers a PUB; "" accepts it
+	pub      func(msg natsMessage) string
+	accepted int
+	options  []map[string]any
+	messages []natsMessage
+	conns    []net.Conn
+	pongs    int // PONGs received from the client
+}
+
+// newFakeNATS starts a fake server, after configure (if any) has set its
+// INFO and CONNECT handling.
+func newFakeNATS(t *testing.T, configure ...func(s *fakeNATS)) *fakeNATS {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeNATS{t: t, listener: listener, info: `{"server_id":"fake","max_payload":1048576}`}
+	for _, f := range configure {
+		f(s)
+	}
+	t.Cleanup(func() {
+		listener.Close()
+		s.dropConns()
+	})
+	go s.serve()
+	return s
+}
+
+func (s *fakeNATS) url(userinfo string) string {
+	return "nats://" + userinfo + s.listener.Addr().String()
+}
+
+func (s *fakeNATS) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.accepted++
+		s.conns = append(s.conns, conn)
+		s.mu.Unlock()
+		go s.handle(conn)
+	}
+}
+
+func (s *fakeNATS) setPub(pub func(msg natsMessage) string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pub = pub
+}
+
+// dropConns closes every client connection, as a server restart would.
+func (s *fakeNATS) dropConns() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+	s.conns = nil
+}
+
+// ping sends a PING on every client connection.
+func (s *fakeNATS) ping() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, conn := range s.conns {
+		conn.Write([]byte("PING\r\n"))
+	}
+}
+
+func (s *fakeNATS) handle(conn net.Conn) {
+	defer conn.Close()
+	fmt.Fprintf(conn, "INFO %s\r\n", s.info)
+	reader := bufio.NewReader(conn)
+	for {
+		line, err := readNATSLine(reader)
+		if err != nil {
+			return
+		}
+		verb, args, _ := strings.Cut(line, " ")
+		switch verb {
+		case "CONNECT":
+			var options map[string]any
+			if err := json.Unmarshal([]byte(args), &options); err != nil {
+				s.t.Errorf("CONNECT options aren't JSON: %v", err)
+				return
+			}
+			s.mu.Lock()
+			s.options = append(s.options, options)
+			s.mu.Unlock()
+			if s.connect != nil {
+				if reply := s.connect(options); reply != "" {
+					fmt.Fprintf(conn, "%s\r\n", reply)
+					return
+				}
+			}
+		case "PUB":
+			fields := strings.Fields(args)
+			if len(fields) != 2 {
+				s.t.Errorf("bad PUB line %q", line)
+				return
+			}
+			size, err := strconv.Atoi(fields[1])
+			if err != nil {
+				s.t.Errorf("bad PUB size in %q", line)
+				return
+			}
+			payload := make([]byte, size+2)
+			if _, err := io.ReadFull(reader, payload); err != nil {
+				return
+			}
+			if string(payload[size:]) != "\r\n" {
+				s.t.Errorf("PUB payload of %d bytes not followed by CRLF: %q", size, payload)
+				return
+			}
+			msg := natsMessage{subject: fields[0], payload: payload[:size]}
+			s.mu.Lock()
+			s.messages = append(s.messages, msg)
+			pub := s.pub
+			s.mu.Unlock()
+			if pub != nil {
+				if reply := pub(msg); reply != "" {
+					fmt.Fprintf(conn, "%s\r\n", reply)
+				}
+			}
+		case "PING":
+			conn.Write([]byte("PONG\r\n"))
+		case "PONG":
+			s.mu.Lock()
+			s.pongs++
+			s.mu.Unlock()
+		default:
+			s.t.Errorf("unexpected client line %q", line)
+			return
+		}
+	}
+}
+
+func testEvents(n int) []JobEvent {
+	types := []string{JobEventCreated, JobEventStarted, JobEventCompleted}
+	events := make([]JobEvent, n)
+	for i := range events {
+		events[i] = JobEvent{
+			Type:           types[i%len(types)],
+			JobID:          fmt.Sprintf("job-%d", i%2),
+			Status:         JobStatusQueued,
+			SourceLanguage: "en",
+			TargetLanguage: "fr",
+			Time:           time.Unix(1700000000, 0).UTC(),
+		}
+	}
+	return events
+}
+
+func TestNATSPublish(t *testing.T) {
+	server := newFakeNATS(t)
+	pub, err := NewNATSEventPublisher(server.url("user:secret@")+"/events.jobs", quietLogger())
+	if err != nil {
+		t.Fatalf("NewNATSEventPublisher() = %v", err)
+	}
+	defer pub.Close()
+
+	events := testEvents(3)
+	// A payload with CRLF in it must be framed by its length
+	events[1].ProgressMessage = "line one\r\nPUB fake 1\r\n"
+	if err := pub.Publish(events); err != nil {
+		t.Fatalf("Publish() = %v", err)
+	}
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if len(server.options) != 1 {
+		t.Fatalf("got %d CONNECTs, want 1", len(server.options))
+	}
+	options := server.options[0]
+	if options["user"] != "user" || options["pass"] != "secret" || options["verbose"] != false || options["tls_required"] != false {
+		t.Errorf("CONNECT options = %v", options)
+	}
+	if len(server.messages) != len(events) {
+		t.Fatalf("server got %d messages, want %d", len(server.messages), len(events))
+	}
+	for i, msg := range server.messages {
+		if want := "events.jobs." + events[i].Type; msg.subject != want {
+			t.Errorf("message %d subject = %s, want %s", i, msg.subject, want)
+		}
+		var got JobEvent
+		if err := json.Unmarshal(msg.payload, &got); err != nil {
+			t.Errorf("message %d payload isn't JSON: %v", i, err)
+			continue
+		}
+		if got != events[i] {
+			t.Errorf("message %d = %+v, want %+v", i, got, events[i])
+		}
+	}
+}
+
+func TestNATSConnectToken(t *testing.T) {
+	server := newFakeNATS(t)
+	pub, err := NewNATSEventPublisher(server.url("s3cret@"), quietLogger())
+	if err != nil {
+		t.Fatalf("NewNATSEventPublisher() = %v", err)
+	}
+	defer pub.Close()
+	if err := pub.Publish(testEvents(1)); err != nil {
+		t.Fatalf("Publish() = %v", err)
+	}
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if got := server.options[0]["auth_token"]; got != "s3cret" {
+		t.Errorf("auth_token = %v, want s3cret", got)
+	}
+	if got := server.messages[0].subject; got != DefaultJobEventTopic+"."+JobEventCreated {
+		t.Errorf("subject = %s, want the default prefix", got)
+	}
+}
+
+func TestNATSConnectErrors(t *testing.T) {
+	t.Run("authorization", func(t *testing.T) {
+		server := newFakeNATS(t, func(s *fakeNATS) {
+			s.connect = func(map[string]any) string { return "-ERR 'Authorization Violation'" }
+		})
+		_, err := NewNATSEventPublisher(server.url("user:wrong@"), quietLogger())
+		if err == nil || !strings.Contains(err.Error(), "Authorization Violation") {
+			t.Errorf("NewNATSEventPublisher() = %v, want the authorization error", err)
+		}
+	})
+	t.Run("TLS required", func(t *testing.T) {
+		server := newFakeNATS(t, func(s *fakeNATS) {
+			s.info = `{"server_id":"fake","tls_required":true}`
+		})
+		_, err := NewNATSEventPublisher(server.url(""), quietLogger())
+		if err == nil || !strings.Contains(err.Error(), "requires TLS") {
+			t.Errorf("NewNATSEventPublisher() = %v, want a TLS error", err)
+		}
+	})
+	t.Run("not NATS", func(t *testing.T) {
+		server := newFakeNATS(t, func(s *fakeNATS) {
+			s.info = "garbage"
+		})
+		if _, err := NewNATSEventPublisher(server.url(""), quietLogger()); err == nil {
+			t.Error("NewNATSEventPublisher() with a bad INFO succeeded")
+		}
+	})
+}
+
+func TestNATSPublishError(t *testing.T) {
+	server := newFakeNATS(t)
+	server.setPub(func(msg natsMessage) string {
+		if strings.HasSuffix(msg.subject, "."+JobEventStarted) {
+			return "-ERR 'Permissions Violation for Publish to " + msg.subject + "'"
+		}
+		return ""
+	})
+	pub, err := NewNATSEventPublisher(server.url(""), quietLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pub.Close()
+
+	err = pub.Publish(testEvents(2))
+	if err == nil || !strings.Contains(err.Error(), "Permissions Violation") {
+		t.Errorf("Publish() = %v, want the permissions violation", err)
+	}
+	// The error doesn't stick to later publishes
+	if err := pub.Publish(testEvents(1)); err != nil {
+		t.Errorf("Publish() after the error = %v", err)
+	}
+}
+
+func TestNATSAnswersServerPing(t *testing.T) {
+	server := newFakeNATS(t)
+	pub, err := NewNATSEventPublisher(server.url(""), quietLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pub.Close()
+
+	server.ping()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		server.mu.Lock()
+		pongs := server.pongs
+		server.mu.Unlock()
+		if pongs == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("publisher didn't answer the server's PING")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestNATSReconnect(t *testing.T) {
+	server := newFakeNATS(t)
+	pub, err := NewNATSEventPublisher(server.url(""), quietLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pub.Close()
+	if err := pub.Publish(testEvents(1)); err != nil {
+		t.Fatalf("Publish() = %v", err)
+	}
+
+	// The server restarts; once the publisher has seen the connection
+	// close, the next publish dials again
+	server.dropConns()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		pub.mu.Lock()
+		failed := pub.conn.failed()
+		pub.mu.Unlock()
+		if failed {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("publisher didn't notice the closed connection")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := pub.Publish(testEvents(1)); err != nil {
+		t.Fatalf("Publish() after the server restarted = %v", err)
+	}
+
+	// A connection that dies during a publish fails it, and the next one
+	// reconnects
+	server.setPub(func(natsMessage) string {
+		server.setPub(nil)
+		server.dropConns()
+		return ""
+	})
+	if err := pub.Publish(testEvents(1)); err == nil {
+		t.Error("Publish() on a connection closed mid-publish succeeded")
+	}
+	if err := pub.Publish(testEvents(1)); err != nil {
+		t.Fatalf("Publish() after a failed publish = %v", err)
+	}
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if server.accepted != 3 {
+		t.Errorf("server accepted %d connections, want 3", server.accepted)
+	}
+	if len(server.messages) != 4 {
+		t.Errorf("server got %d messages, want 4", len(server.messages))
+	}
+}
+
+func TestNATSServerDown(t *testing.T) {
+	server := newFakeNATS(t)
+	pub, err := NewNATSEventPublisher(server.url(""), quietLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pub.Close()
+
+	server.listener.Close()
+	server.dropConns()
+	time.Sleep(50 * time.Millisecond)
+	if err := pub.Publish(testEvents(1)); err == nil {
+		t.Error("Publish() with the server down succeeded")
+	}
+}
@@ -0,0 +1,129 @@
+package service
+
+import (
+	"fmt"
+	"maps"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dasmlab/iskoces/pkg/translate"
+)
+
+// NamespaceScheduling controls how async jobs share the queue between
+// namespaces.
+type NamespaceScheduling struct {
+	// Weights gives namespaces more turns in fair order: a namespace with
+	// weight 3 starts three jobs for every one of a namespace with weight 1
+	// (the default), while both have jobs waiting.
+	Weights map[string]int
+	// MaxConcurrent is how many jobs one namespace may run at once (0 =
+	// unlimited), so one tenant's jobs can't hold every slot.
+	MaxConcurrent int
+}
+
+// SetNamespaceScheduling sets the namespace weights used in fair order and
+// the per-namespace concurrency limit. With a shared store each replica
+// claims jobs in submission order instead.
+func (q *JobQueue) SetNamespaceScheduling(cfg NamespaceScheduling) {
+	s := q.scheduler
+	s.mu.Lock()
+	s.weights = maps.Clone(cfg.Weights)
+	s.nsLimit = max(0, cfg.MaxConcurrent)
+	s.mu.Unlock()
+	s.dispatch()
+}
+
+// ParseNamespaceWeights parses namespace weights written as
+// "namespace=weight" pairs separated by commas, e.g. "team-a=3,team-b=2".
+func ParseNamespaceWeights(spec string) (map[string]int, error) {
+	weights := make(map[string]int)
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		namespace, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("%q: want namespace=weight", pair)
+		}
+		weight, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || weight < 1 {
+			return nil, fmt.Errorf("%q: weight must be a positive integer", pair)
+		}
+		weights[strings.TrimSpace(namespace)] = weight
+	}
+	return weights, nil
+}
+
+// weight returns a namespace's weight in fair order. Callers hold s.mu.
+func (s *jobScheduler) weight(namespace string) int {
+	if w, ok := s.weights[namespace]; ok {
+		return w
+	}
+	return 1
+}
+
+// canRun reports whether job's namespace is below its concurrency limit
+// given the running jobs per namespace (nil ignores the limit). Callers
+// hold s.mu.
+func (s *jobScheduler) canRun(job *TranslationJob, running map[string]int) bool {
+	return running == nil || s.nsLimit == 0 || running[job.Namespace] < s.nsLimit
+}
+
+// pickFair returns the index in waiting of the job to start next in fair
+// order, or -1 if none can run: among the namespaces with a job at the top
+// effective priority, the one with the most round-robin credit (smooth
+// weighted round-robin) starts its oldest such job. credits is updated for
+// the pick. waiting is in priority order. Callers hold s.mu.
+func (s *jobScheduler) pickFair(waiting []*TranslationJob, credits, running map[string]int, now time.Time) int {
+	first := make(map[string]int) // Namespace -> index of its first eligible job
+	var namespaces []string       // In order of their first job, which wins ties
+	top := translate.Priority(-1)
+	for i, job := range waiting {
+		if !s.canRun(job, running) {
+			continue
+		}
+		p := s.effectivePriority(job, now)
+		if p < top {
+			break
+		}
+		top = p
+		if _, ok := first[job.Namespace]; !ok {
+			first[job.Namespace] = i
+			namespaces = append(namespaces, job.Namespace)
+		}
+	}
+	if len(namespaces) == 0 {
+		return -1
+	}
+
+	total := 0
+	best := namespaces[0]
+	for _, ns := range namespaces {
+		w := s.weight(ns)
+		credits[ns] += w
+		total += w
+		if credits[ns] > credits[best] {
+			best = ns
+		}
+	}
+	credits[best] -= total
+	return first[best]
+}
+
+// fairPosition returns the 1-based place job, which is waiting, would start
+// in if no more jobs arrived, by replaying the round-robin. Callers hold
+// s.mu.
+func (s *jobScheduler) fairPosition(job *TranslationJob, now time.Time) int {
+	waiting := append([]*TranslationJob(nil), s.waiting...)
+	credits := maps.Clone(s.credits)
+	for n := 1; len(waiting) > 0; n++ {
+		i := s.pickFair(waiting, credits, nil, now)
+		if waiting[i] == job {
+			return n
+		}
+		waiting = append(waiting[:i], waiting[i+1:]...)
+	}
+	return 0
+}
@@ -0,0 +1,274 @@
+package service
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultListJobsPageSize and MaxListJobsPageSize bound ListJobs pages.
+	DefaultListJobsPageSize = 50
+	MaxListJobsPageSize     = 1000
+)
+
+// ErrInvalidPageToken is returned for a page token ListJobs didn't issue,
+// or issued for another sort order.
+var ErrInvalidPageToken = errors.New("invalid page token")
+
+// JobFilter selects jobs to list. Zero fields match every job.
+type JobFilter struct {
+	Namespace     string
+	ClientID      string
+	Statuses      []TranslationJobStatus // Any of these
+	CreatedAfter  time.Time              // Inclusive
+	CreatedBefore time.Time              // Exclusive
+	// CompletedAfter (inclusive) and CompletedBefore (exclusive) bound
+	// when jobs finished; unfinished jobs don't match either
+	CompletedAfter  time.Time
+	CompletedBefore time.Time
+	DeadLetter      bool // Only dead-letter jobs
+}
+
+// matches reports whether the job passes the filter. Callers hold job.mu.
+func (f JobFilter) matches(job *TranslationJob) bool {
+	if f.Namespace != "" && job.Namespace != f.Namespace {
+		return false
+	}
+	if f.ClientID != "" && job.ClientID != f.ClientID {
+		return false
+	}
+	if len(f.Statuses) > 0 {
+		found := false
+		for _, s := range f.Statuses {
+			if job.Status == s {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if !f.CreatedAfter.IsZero() && job.CreatedAt.Before(f.CreatedAfter) {
+		return false
+	}
+	if !f.CreatedBefore.IsZero() && !job.CreatedAt.Before(f.CreatedBefore) {
+		return false
+	}
+	if !f.CompletedAfter.IsZero() && (job.CompletedAt == nil || job.CompletedAt.Before(f.CompletedAfter)) {
+		return false
+	}
+	if !f.CompletedBefore.IsZero() && (job.CompletedAt == nil || !job.CompletedAt.Before(f.CompletedBefore)) {
+		return false
+	}
+	if f.DeadLetter && !job.DeadLetter {
+		return false
+	}
+	return true
+}
+
+// ParseJobStatus parses a job status name (queued, processing, paused,
+// completed, failed or cancelled).
+func ParseJobStatus(name string) (TranslationJobStatus, error) {
+	switch s := TranslationJobStatus(strings.ToLower(name)); s {
+	case JobStatusQueued, JobStatusProcessing, JobStatusPaused, JobStatusCompleted, JobStatusFailed, JobStatusCancelled:
+		return s, nil
+	}
+	return "", fmt.Errorf("unknown job status %q (want queued, processing, paused, completed, failed or cancelled)", name)
+}
+
+// JobSort orders listed jobs by created_at, started_at, completed_at or
+// priority, descending with a "-" prefix. Ties are broken by job ID in the
+// same direction, and jobs that haven't started or finished sort as the
+// oldest.
+type JobSort string
+
+// DefaultJobSort lists the newest jobs first.
+const DefaultJobSort JobSort = "-created_at"
+
+// jobSortKeys return the value a job is sorted by for each sort field.
+// Callers hold job.mu.
+var jobSortKeys = map[string]func(*TranslationJob) int64{
+	"created_at":   func(job *TranslationJob) int64 { return job.CreatedAt.UnixNano() },
+	"started_at":   func(job *TranslationJob) int64 { return timeSortKey(job.StartedAt) },
+	"completed_at": func(job *TranslationJob) int64 { return timeSortKey(job.CompletedAt) },
+	"priority":     func(job *TranslationJob) int64 { return int64(job.Priority) },
+}
+
+// timeSortKey sorts a missing time before every other.
+func timeSortKey(t *time.Time) int64 {
+	if t == nil {
+		return math.MinInt64
+	}
+	return t.UnixNano()
+}
+
+// ParseJobSort parses a sort order; "" is DefaultJobSort.
+func ParseJobSort(name string) (JobSort, error) {
+	if name == "" {
+		return DefaultJobSort, nil
+	}
+	if _, ok := jobSortKeys[strings.TrimPrefix(name, "-")]; !ok {
+		return "", fmt.Errorf("unknown sort %q (want created_at, started_at, completed_at or priority, prefixed with - for descending)", name)
+	}
+	return JobSort(name), nil
+}
+
+// JobPage selects a page of listed jobs: up to Size jobs (default
+// DefaultListJobsPageSize, at most MaxListJobsPageSize) in Sort order
+// (default DefaultJobSort), after the position of the previous page's
+// Token, skipping the first Offset of them.
+type JobPage struct {
+	Size   int
+	Token  string
+	Offset int
+	Sort   JobSort
+}
+
+// JobList is a page of listed jobs.
+type JobList struct {
+	Jobs          []*TranslationJob
+	NextPageToken string // "" after the last page
+	TotalSize     int    // Jobs matching the filter, on every page
+}
+
+// FindJobs returns a page of the jobs this replica holds that match
+// filter. Tokens mark a position rather than an offset, so jobs created or
+// removed between calls don't shift later pages as they do with Offset.
+func (q *JobQueue) FindJobs(filter JobFilter, page JobPage) (JobList, error) {
+	size := page.Size
+	if size <= 0 {
+		size = DefaultListJobsPageSize
+	}
+	size = min(size, MaxListJobsPageSize)
+	order, err := ParseJobSort(string(page.Sort))
+	if err != nil {
+		return JobList{}, err
+	}
+	sortKey := jobSortKeys[order.field()]
+
+	var after *jobCursor
+	if page.Token != "" {
+		cursor, err := parseJobCursor(page.Token)
+		if err != nil {
+			return JobList{}, err
+		}
+		if cursor.order != order {
+			return JobList{}, ErrInvalidPageToken
+		}
+		after = &cursor
+	}
+
+	type listed struct {
+		job *TranslationJob
+		at  jobCursor
+	}
+	var list JobList
+	var jobs []listed
+	q.jobsMu.RLock()
+	for _, job := range q.jobs {
+		job.mu.RLock()
+		match := filter.matches(job)
+		at := jobCursor{order: order, key: sortKey(job), id: job.ID}
+		job.mu.RUnlock()
+		if !match {
+			continue
+		}
+		list.TotalSize++
+		if after == nil || after.before(at) {
+			jobs = append(jobs, listed{job: job, at: at})
+		}
+	}
+	q.jobsMu.RUnlock()
+
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].at.before(jobs[j].at)
+	})
+	if page.Offset > 0 {
+		jobs = jobs[min(page.Offset, len(jobs)):]
+	}
+	if len(jobs) > size {
+		jobs = jobs[:size]
+		list.NextPageToken = jobs[size-1].at.String()
+	}
+	list.Jobs = make([]*TranslationJob, len(jobs))
+	for i, j := range jobs {
+		list.Jobs[i] = j.job
+	}
+	return list, nil
+}
+
+// FilterJobs returns a page of the jobs this replica holds that match
+// filter, newest first, and the token for the next page ("" after the last
+// page). pageToken is "" for the first page.
+func (q *JobQueue) FilterJobs(filter JobFilter, pageSize int, pageToken string) ([]*TranslationJob, string, error) {
+	list, err := q.FindJobs(filter, JobPage{Size: pageSize, Token: pageToken})
+	return list.Jobs, list.NextPageToken, err
+}
+
+// field returns the field jobs are sorted by.
+func (s JobSort) field() string {
+	return strings.TrimPrefix(string(s), "-")
+}
+
+// descending reports whether the order is descending.
+func (s JobSort) descending() bool {
+	return strings.HasPrefix(string(s), "-")
+}
+
+// jobCursor is a job's position in a sort order: its sort key, then ID.
+type jobCursor struct {
+	order JobSort
+	key   int64
+	id    string
+}
+
+// before reports whether the cursor comes before other in its order.
+func (c jobCursor) before(other jobCursor) bool {
+	if c.key != other.key {
+		return (c.key > other.key) == c.order.descending()
+	}
+	return c.id != other.id && (c.id > other.id) == c.order.descending()
+}
+
+// String encodes the cursor as a page token. Tokens for the default order
+// keep their original "key:id" form.
+func (c jobCursor) String() string {
+	value := strconv.FormatInt(c.key, 10) + ":" + c.id
+	if c.order != DefaultJobSort {
+		value = string(c.order) + ":" + value
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(value))
+}
+
+// parseJobCursor decodes a page token.
+func parseJobCursor(token string) (jobCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return jobCursor{}, ErrInvalidPageToken
+	}
+	cursor := jobCursor{order: DefaultJobSort}
+	value := string(data)
+	if name, rest, ok := strings.Cut(value, ":"); ok {
+		if _, err := strconv.ParseInt(name, 10, 64); err != nil {
+			if cursor.order, err = ParseJobSort(name); err != nil {
+				return jobCursor{}, ErrInvalidPageToken
+			}
+			value = rest
+		}
+	}
+	key, id, ok := strings.Cut(value, ":")
+	n, err := strconv.ParseInt(key, 10, 64)
+	if !ok || err != nil || id == "" {
+		return jobCursor{}, ErrInvalidPageToken
+	}
+	cursor.key = n
+	cursor.id = id
+	return cursor, nil
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	jobsSubmittedTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "iskoces_jobs_submitted_total",
+			Help: "Total number of async translation jobs submitted, by primitive",
+		},
+		[]string{"primitive"},
+	)
+
+	jobsFinishedTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "iskoces_jobs_finished_total",
+			Help: "Total number of async translation jobs finished, by final status (completed, failed, cancelled) and primitive",
+		},
+		[]string{"status", "primitive"},
+	)
+
+	jobsByStatus = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "iskoces_jobs",
+			Help: "Number of translation jobs the queue holds, by status, after the last retention pass",
+		},
+		[]string{"status"},
+	)
+
+	jobQueueWait = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "iskoces_job_queue_wait_seconds",
+			Help:    "Time async jobs waited from submission (or not_before) to their first start, by submitted priority",
+			Buckets: []float64{0.01, 0.1, 0.5, 1, 5, 10, 30, 60, 300, 900, 3600},
+		},
+		[]string{"priority"},
+	)
+
+	jobDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "iskoces_job_duration_seconds",
+			Help:    "Time async jobs took from their first start to finishing, including retries and pauses, by primitive and final status",
+			Buckets: []float64{0.1, 0.5, 1, 2, 5, 10, 30, 60, 120, 300, 600, 1800},
+		},
+		[]string{"primitive", "status"},
+	)
+
+	jobChunks = promauto.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "iskoces_job_chunks",
+			Help:    "Number of chunks the documents of completed chunked jobs were split into",
+			Buckets: []float64{2, 4, 8, 16, 32, 64, 128, 256, 512},
+		},
+	)
+
+	jobAttempts = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "iskoces_job_attempts",
+			Help:    "Number of attempts finished async jobs took, by final status",
+			Buckets: []float64{1, 2, 3, 4, 5, 7, 10},
+		},
+		[]string{"status"},
+	)
+)
+
+// jobStatuses are the statuses iskoces_jobs reports, so statuses no job has
+// any more are reported as 0.
+var jobStatuses = []TranslationJobStatus{
+	JobStatusQueued,
+	JobStatusProcessing,
+	JobStatusPaused,
+	JobStatusCompleted,
+	JobStatusFailed,
+	JobStatusCancelled,
+}
+
+// recordFinished records the job's final state in the job metrics. Callers
+// hold j.mu.
+func (j *TranslationJob) recordFinished() {
+	status, primitive := string(j.Status), j.Primitive.String()
+	jobsFinishedTotal.WithLabelValues(status, primitive).Inc()
+	if j.StartedAt != nil && j.CompletedAt != nil {
+		jobDuration.WithLabelValues(primitive, status).Observe(j.CompletedAt.Sub(*j.StartedAt).Seconds())
+	}
+	if j.Attempt > 0 {
+		jobAttempts.WithLabelValues(status).Observe(float64(j.Attempt))
+	}
+}
+
+// setJobsByStatus reports how many of jobs have each status. Callers hold
+// q.jobsMu.
+func setJobsByStatus(jobs map[string]*TranslationJob) {
+	counts := make(map[TranslationJobStatus]int, len(jobStatuses))
+	for _, job := range jobs {
+		job.mu.RLock()
+		counts[job.Status]++
+		job.mu.RUnlock()
+	}
+	for _, status := range jobStatuses {
+		jobsByStatus.WithLabelValues(string(status)).Set(float64(counts[status]))
+	}
+}
@@ -0,0 +1,261 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/dasmlab/iskoces/pkg/translate"
+)
+
+// Who paused a job (TranslationJob.PausedBy).
+const (
+	PausedByAdmin = "admin" // PauseJob; stays paused until ResumeJob, across restarts
+	PausedByQueue = "queue" // PauseQueue; resumes with the queue (or on restart)
+)
+
+var (
+	// ErrJobNotPaused is returned when resuming a job that isn't paused.
+	ErrJobNotPaused = errors.New("job is not paused")
+	// ErrPauseUnsupported is returned when pausing with a shared job store,
+	// where jobs run on whichever replica claims them.
+	ErrPauseUnsupported = errors.New("pausing is not supported with a shared job store")
+
+	// errJobPaused stops an attempt at a job that was paused while running.
+	// The job's checkpoint keeps the chunks translated so far.
+	errJobPaused = errors.New("job paused")
+)
+
+// QueuePause describes a pause of the whole queue.
+type QueuePause struct {
+	Paused      bool
+	MaxPriority translate.Priority // Jobs at or below this priority are held
+	Reason      string
+	Since       time.Time
+}
+
+// holds reports whether the pause holds jobs of priority.
+func (p QueuePause) holds(priority translate.Priority) bool {
+	return p.Paused && priority <= p.MaxPriority
+}
+
+// PauseJob pauses a queued or running job. A queued job is paused at once;
+// a running one stops after the chunks in flight, keeping them in its
+// checkpoint (short jobs finish first). The job stays paused until
+// ResumeJob, even across restarts.
+func (q *JobQueue) PauseJob(jobID, reason string) (*TranslationJob, error) {
+	if q.shared != nil {
+		return nil, ErrPauseUnsupported
+	}
+	job, err := q.GetJob(jobID)
+	if err != nil {
+		return nil, err
+	}
+
+	message := "Paused"
+	if reason != "" {
+		message = fmt.Sprintf("Paused: %s", reason)
+	}
+
+	job.mu.Lock()
+	switch {
+	case job.Status.IsTerminal():
+		current := job.Status
+		job.mu.Unlock()
+		return job, fmt.Errorf("%w: job %s is %s", ErrJobFinished, job.ID, current)
+	case job.Status == JobStatusPaused:
+		// A job held by the queue no longer resumes with it
+		job.PausedBy = PausedByAdmin
+		job.ProgressMessage = message
+		job.recordProgress(message, 0, 0)
+		job.persist()
+		job.mu.Unlock()
+		return job, nil
+	}
+	scheduler := job.scheduler
+	job.mu.Unlock()
+
+	// A job still waiting to start is taken out of line; one that already
+	// started pauses at its next chunk
+	if scheduler != nil && scheduler.remove(job) {
+		job.park(PausedByAdmin, message)
+	} else {
+		job.mu.Lock()
+		if !job.Status.IsTerminal() {
+			job.pauseMessage = message
+			job.ProgressMessage = "Pausing after the chunks in flight..."
+			job.recordProgress(job.ProgressMessage, 0, 0)
+			job.persist()
+		}
+		job.mu.Unlock()
+	}
+
+	q.logger.WithFields(logrus.Fields{
+		"job_id":     jobID,
+		"request_id": job.RequestID,
+		"reason":     reason,
+	}).Info("Paused translation job")
+	return job, nil
+}
+
+// ResumeJob resumes a paused job: it is queued again and continues from its
+// checkpoint. A running job whose pause hasn't taken effect yet just
+// carries on.
+func (q *JobQueue) ResumeJob(jobID string) (*TranslationJob, error) {
+	job, err := q.GetJob(jobID)
+	if err != nil {
+		return nil, err
+	}
+
+	job.mu.Lock()
+	if job.pauseMessage != "" && !job.Status.IsTerminal() {
+		job.pauseMessage = ""
+		job.ProgressMessage = "Resumed"
+		job.recordProgress(job.ProgressMessage, 0, 0)
+		job.mu.Unlock()
+		return job, nil
+	}
+	if job.Status != JobStatusPaused {
+		current := job.Status
+		job.mu.Unlock()
+		return job, fmt.Errorf("%w: job %s is %s", ErrJobNotPaused, job.ID, current)
+	}
+	job.unpark("Resumed")
+	job.mu.Unlock()
+
+	if q.processor != nil {
+		q.scheduler.submit(job)
+	}
+	q.logger.WithFields(logrus.Fields{
+		"job_id":     jobID,
+		"request_id": job.RequestID,
+	}).Info("Resumed translation job")
+	return job, nil
+}
+
+// PauseQueue holds jobs at or below maxPriority: waiting jobs and new ones
+// are paused instead of starting, and running ones pause after their chunks
+// in flight. ResumeQueue releases them. Pausing again replaces the pause;
+// jobs the earlier one paused stay paused until ResumeQueue. It returns the
+// pause and how many waiting jobs it paused.
+func (q *JobQueue) PauseQueue(maxPriority translate.Priority, reason string) (QueuePause, int, error) {
+	if q.shared != nil {
+		return QueuePause{}, 0, ErrPauseUnsupported
+	}
+	pause := QueuePause{
+		Paused:      true,
+		MaxPriority: maxPriority,
+		Reason:      reason,
+		Since:       time.Now(),
+	}
+	held := q.scheduler.pause(pause)
+
+	q.logger.WithFields(logrus.Fields{
+		"max_priority": maxPriority.String(),
+		"reason":       reason,
+		"held":         held,
+	}).Info("Paused job queue")
+	return pause, held, nil
+}
+
+// ResumeQueue ends a queue pause and resumes the jobs it paused, returning
+// how many. Jobs paused with PauseJob stay paused.
+func (q *JobQueue) ResumeQueue() int {
+	q.scheduler.pause(QueuePause{})
+
+	q.jobsMu.RLock()
+	var resumed []*TranslationJob
+	for _, job := range q.jobs {
+		job.mu.Lock()
+		if job.Status == JobStatusPaused && job.PausedBy == PausedByQueue {
+			job.unpark("Resumed with the queue")
+			resumed = append(resumed, job)
+		}
+		job.mu.Unlock()
+	}
+	q.jobsMu.RUnlock()
+
+	if q.processor != nil {
+		for _, job := range resumed {
+			q.scheduler.submit(job)
+		}
+	}
+	q.logger.WithField("resumed", len(resumed)).Info("Resumed job queue")
+	return len(resumed)
+}
+
+// QueuePaused returns the queue's current pause, if any.
+func (q *JobQueue) QueuePaused() QueuePause {
+	return q.scheduler.paused()
+}
+
+// queuePauseMessage is the progress message of jobs paused with the queue.
+func queuePauseMessage(reason string) string {
+	if reason == "" {
+		return "Paused with the queue"
+	}
+	return fmt.Sprintf("Paused with the queue: %s", reason)
+}
+
+// PauseOrigin returns who paused the job (PausedByAdmin or PausedByQueue),
+// or "" unless it is paused (thread-safe).
+func (j *TranslationJob) PauseOrigin() string {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	return j.PausedBy
+}
+
+// park marks the job paused by by with message, unless it finished.
+func (j *TranslationJob) park(by, message string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if !j.Status.IsTerminal() {
+		j.parkLocked(by, message)
+	}
+}
+
+// parkLocked marks the job paused. Callers hold j.mu.
+func (j *TranslationJob) parkLocked(by, message string) {
+	j.Status = JobStatusPaused
+	j.PausedBy = by
+	j.pauseMessage = ""
+	j.ProgressMessage = message
+	j.recordProgress(message, 0, 0)
+	j.persist()
+}
+
+// unpark marks a paused job queued again with message. Callers hold j.mu.
+func (j *TranslationJob) unpark(message string) {
+	j.Status = JobStatusQueued
+	j.PausedBy = ""
+	j.ProgressMessage = message
+	j.recordProgress(message, 0, 0)
+	j.persist()
+}
+
+// pausing reports whether the running job should stop after its chunks in
+// flight, because it or the queue was paused.
+func (j *TranslationJob) pausing() bool {
+	j.mu.RLock()
+	requested := j.pauseMessage != ""
+	scheduler := j.scheduler
+	j.mu.RUnlock()
+	return requested || (scheduler != nil && scheduler.paused().holds(j.Priority))
+}
+
+// parkRunning pauses a running job that stopped because it was pausing,
+// and reports whether it did; if the pause was lifted meanwhile, the job
+// carries on.
+func (j *TranslationJob) parkRunning() bool {
+	j.mu.Lock()
+	if j.pauseMessage != "" && !j.Status.IsTerminal() {
+		j.parkLocked(PausedByAdmin, j.pauseMessage)
+		j.mu.Unlock()
+		return true
+	}
+	scheduler := j.scheduler
+	j.mu.Unlock()
+	return scheduler != nil && scheduler.hold(j)
+}
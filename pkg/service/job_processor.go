@@ -2,11 +2,15 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"runtime/debug"
 	"strings"
+	"sync"
 	"time"
 
 	nanabushv1 "github.com/dasmlab/iskoces/pkg/proto/v1"
+	"github.com/dasmlab/iskoces/pkg/tracing"
 	"github.com/dasmlab/iskoces/pkg/translate"
 	"github.com/sirupsen/logrus"
 )
@@ -15,26 +19,94 @@ import (
 type JobProcessor struct {
 	translator     translate.Translator
 	languageMapper *translate.LanguageMapper
+	localizer      *translate.Localizer
+	estimator      *CompletionEstimator // Optional: receives observed throughput
 	logger         *logrus.Logger
 	chunkSize      int // Maximum chunk size in bytes (default: 10KB)
+
+	// Chunks of one job translated concurrently (default: DefaultChunkParallelism)
+	chunkParallelism int
+
+	// How failed jobs are retried (default: DefaultJobRetryPolicy)
+	retryPolicy JobRetryPolicy
+
+	// usage totals job usage per namespace (set by JobQueue.SetProcessor);
+	// costPerMillionChars prices the characters translated
+	usage               *usageLedger
+	costPerMillionChars float64
 }
 
+// DefaultChunkParallelism is how many chunks of a large document are
+// translated at once.
+const DefaultChunkParallelism = 4
+
 // NewJobProcessor creates a new job processor.
 func NewJobProcessor(translator translate.Translator, languageMapper *translate.LanguageMapper, logger *logrus.Logger) *JobProcessor {
 	return &JobProcessor{
 		translator:     translator,
 		languageMapper: languageMapper,
+		localizer:      translate.NewLocalizer(),
 		logger:         logger,
 		chunkSize:      10 * 1024, // 10KB default
+
+		chunkParallelism: DefaultChunkParallelism,
+		retryPolicy:      DefaultJobRetryPolicy,
 	}
 }
 
+// SetChunkParallelism sets how many chunks of one job are translated at
+// once; values below 1 translate chunks one at a time. It must be called
+// before jobs are submitted.
+func (p *JobProcessor) SetChunkParallelism(n int) {
+	p.chunkParallelism = max(1, n)
+}
+
 // ProcessJob processes a translation job asynchronously.
 func (p *JobProcessor) ProcessJob(job *TranslationJob) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	// Jobs run on their own goroutine, outside the gRPC recovery interceptor;
+	// a panic here must fail the job, not the server
+	defer func() {
+		if r := recover(); r != nil {
+			p.logger.WithFields(logrus.Fields{
+				"job_id": job.ID,
+				"panic":  fmt.Sprint(r),
+				"stack":  string(debug.Stack()),
+			}).Error("Recovered from panic while processing translation job")
+			job.SetError(fmt.Errorf("internal error while processing job: %v", r))
+		}
+	}()
+
+	// Derive from the job's context so JobQueue.Cancel stops processing
+	ctx, cancel := context.WithTimeout(job.Context(), 10*time.Minute)
 	defer cancel()
 
+	// A job cancelled while still queued never starts
+	if ctx.Err() != nil {
+		p.logger.WithFields(logrus.Fields{
+			"job_id": job.ID,
+		}).Info("Skipping cancelled translation job")
+		return
+	}
+
+	// A job paused after it was dispatched doesn't start
+	if job.pausing() && job.parkRunning() {
+		p.logger.WithField("job_id", job.ID).Info("Translation job paused before starting")
+		return
+	}
+
 	startTime := time.Now()
+
+	// The job's spans continue the trace of the call that submitted it
+	ctx, span := tracing.Start(tracing.ContextWithParent(ctx, job.trace), "job.process",
+		tracing.String("job_id", job.ID),
+		tracing.String("namespace", job.Namespace),
+		tracing.String("primitive", job.Primitive.String()),
+		tracing.String("source_lang", job.SourceLang),
+		tracing.String("target_lang", job.TargetLang),
+		tracing.Int("content_bytes", job.ContentBytes()),
+		tracing.Int64("queue_wait_ms", startTime.Sub(job.CreatedAt).Milliseconds()),
+	)
+	defer span.End()
 	
 	p.logger.WithFields(logrus.Fields{
 		"job_id":     job.ID,
@@ -43,28 +115,124 @@ func (p *JobProcessor) ProcessJob(job *TranslationJob) {
 	}).Info("Starting translation job processing")
 
 	job.UpdateStatus(JobStatusProcessing, "Starting translation...")
+	p.startUsage(job)
 
+	// Transient engine failures (e.g. LibreTranslate answering 502 while it
+	// restarts) are retried with backoff rather than failing the job
+	var translatedTitle, translatedMarkdown string
+	for attempt := 1; ; attempt++ {
+		job.startAttempt(attempt)
+		attemptStart := time.Now()
+		span.SetAttributes(tracing.Int("attempts", attempt))
+		attemptCtx, attemptSpan := tracing.Start(ctx, "job.attempt", tracing.Int("attempt", attempt))
+		var err error
+		translatedTitle, translatedMarkdown, err = p.translateJob(attemptCtx, job)
+		attemptSpan.RecordError(err)
+		attemptSpan.End()
+		if err == nil {
+			break
+		}
+		if errors.Is(err, errJobPaused) {
+			if job.parkRunning() {
+				p.logger.WithError(err).WithField("job_id", job.ID).Info("Translation job paused")
+				return
+			}
+			// The pause was lifted before it took effect; carry on from the
+			// checkpoint in the same attempt
+			attempt--
+			continue
+		}
+
+		class := translate.ClassifyError(err)
+		delay, retry := p.retryPolicy.retryDelay(attempt, class)
+		retry = retry && ctx.Err() == nil
+		record := JobAttempt{
+			Attempt:    attempt,
+			StartedAt:  attemptStart,
+			EndedAt:    time.Now(),
+			Error:      err.Error(),
+			ErrorClass: class,
+		}
+		if retry {
+			record.RetryDelay = delay
+		}
+		job.recordAttempt(record)
+		if !retry {
+			span.RecordError(err)
+			job.SetError(err)
+			return
+		}
+
+		jobRetriesTotal.WithLabelValues(string(class)).Inc()
+		p.logger.WithError(err).WithFields(logrus.Fields{
+			"job_id":      job.ID,
+			"attempt":     attempt,
+			"error_class": class,
+			"retry_in":    delay.String(),
+		}).Warn("Translation job attempt failed, retrying")
+		job.UpdateProgress(0, fmt.Sprintf("Attempt %d failed (%s), retrying in %s", attempt, class, delay.Round(100*time.Millisecond)))
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			err = fmt.Errorf("%w (gave up retrying: %w)", err, ctx.Err())
+			span.RecordError(err)
+			job.SetError(err)
+			return
+		case <-timer.C:
+		}
+	}
+
+	// Optional locale formatting pass (uses the full BCP 47 target tag)
+	if job.LocalizeFormats {
+		translatedTitle = p.localizer.Localize(translatedTitle, job.SourceLang, job.TargetLang)
+		translatedMarkdown = p.localizer.Localize(translatedMarkdown, job.SourceLang, job.TargetLang)
+	}
+
+	// The last engine call may have raced with a cancellation
+	if job.Context().Err() != nil {
+		p.logger.WithFields(logrus.Fields{
+			"job_id": job.ID,
+		}).Info("Translation job cancelled, discarding result")
+		return
+	}
+
+	// Calculate inference time
+	inferenceTime := time.Since(startTime).Seconds()
+	if p.estimator != nil {
+		p.estimator.Observe(job.ContentBytes(), time.Since(startTime))
+	}
+
+	// Set result
+	job.SetResult(translatedTitle, translatedMarkdown, 0, inferenceTime)
+
+	p.logger.WithFields(logrus.Fields{
+		"job_id":         job.ID,
+		"request_id":     job.RequestID,
+		"inference_time": inferenceTime,
+		"success":        true,
+	}).Info("Translation job completed successfully")
+}
+
+// translateJob makes one attempt at translating the job's content.
+func (p *JobProcessor) translateJob(ctx context.Context, job *TranslationJob) (translatedTitle, translatedMarkdown string, err error) {
 	// Convert language codes
 	sourceLang := p.languageMapper.ToBackendCode(job.SourceLang)
 	targetLang := p.languageMapper.ToBackendCode(job.TargetLang)
 
-	var translatedTitle string
-	var translatedMarkdown string
-	var err error
-
 	// Handle different primitive types
 	switch job.Primitive {
 	case nanabushv1.PrimitiveType_PRIMITIVE_TITLE:
 		// Title-only translation
 		job.UpdateProgress(10, "Translating title...")
 		if p.translator != nil {
-			translatedTitle, err = p.translator.Translate(ctx, job.Title, sourceLang, targetLang)
+			translatedTitle, err = p.translateText(ctx, job, job.Title, sourceLang, targetLang)
 			if err != nil {
 				p.logger.WithError(err).WithFields(logrus.Fields{
 					"job_id": job.ID,
 				}).Error("Title translation failed")
-				job.SetError(fmt.Errorf("title translation failed: %w", err))
-				return
+				return "", "", fmt.Errorf("title translation failed: %w", err)
 			}
 		}
 		job.UpdateProgress(100, "Translation completed")
@@ -72,22 +240,23 @@ func (p *JobProcessor) ProcessJob(job *TranslationJob) {
 	case nanabushv1.PrimitiveType_PRIMITIVE_DOC_TRANSLATE:
 		// Full document translation
 		if job.Document == nil {
-			job.SetError(fmt.Errorf("document is required for PRIMITIVE_DOC_TRANSLATE"))
-			return
+			return "", "", fmt.Errorf("document is required for PRIMITIVE_DOC_TRANSLATE")
 		}
 
-		// Translate title if present
-		if job.Document.Title != "" {
+		// Translate title if present (and not already checkpointed)
+		if title, ok := job.checkpointedTitle(); ok {
+			translatedTitle = title
+		} else if job.Document.Title != "" {
 			job.UpdateProgress(5, "Translating title...")
 			if p.translator != nil {
-				translatedTitle, err = p.translator.Translate(ctx, job.Document.Title, sourceLang, targetLang)
+				translatedTitle, err = p.translateText(ctx, job, job.Document.Title, sourceLang, targetLang)
 				if err != nil {
 					p.logger.WithError(err).WithFields(logrus.Fields{
 						"job_id": job.ID,
 					}).Error("Title translation failed")
-					job.SetError(fmt.Errorf("title translation failed: %w", err))
-					return
+					return "", "", fmt.Errorf("title translation failed: %w", err)
 				}
+				job.checkpointTitle(translatedTitle)
 			}
 		}
 
@@ -99,23 +268,24 @@ func (p *JobProcessor) ProcessJob(job *TranslationJob) {
 			// Check if we need to chunk the content
 			if len(markdown) > p.chunkSize {
 				translatedMarkdown, err = p.translateChunked(ctx, markdown, sourceLang, targetLang, job)
+				if errors.Is(err, errJobPaused) {
+					return "", "", err
+				}
 				if err != nil {
 					p.logger.WithError(err).WithFields(logrus.Fields{
 						"job_id": job.ID,
 					}).Error("Chunked translation failed")
-					job.SetError(fmt.Errorf("markdown translation failed: %w", err))
-					return
+					return "", "", fmt.Errorf("markdown translation failed: %w", err)
 				}
 			} else {
 				// Small enough to translate in one go
 				if p.translator != nil {
-					translatedMarkdown, err = p.translator.Translate(ctx, markdown, sourceLang, targetLang)
+					translatedMarkdown, err = p.translateText(ctx, job, markdown, sourceLang, targetLang)
 					if err != nil {
 						p.logger.WithError(err).WithFields(logrus.Fields{
 							"job_id": job.ID,
 						}).Error("Markdown translation failed")
-						job.SetError(fmt.Errorf("markdown translation failed: %w", err))
-						return
+						return "", "", fmt.Errorf("markdown translation failed: %w", err)
 					}
 				}
 			}
@@ -124,22 +294,14 @@ func (p *JobProcessor) ProcessJob(job *TranslationJob) {
 		job.UpdateProgress(100, "Translation completed")
 	}
 
-	// Calculate inference time
-	inferenceTime := time.Since(startTime).Seconds()
-
-	// Set result
-	job.SetResult(translatedTitle, translatedMarkdown, 0, inferenceTime)
-
-	p.logger.WithFields(logrus.Fields{
-		"job_id":         job.ID,
-		"request_id":     job.RequestID,
-		"inference_time": inferenceTime,
-		"success":        true,
-	}).Info("Translation job completed successfully")
+	return translatedTitle, translatedMarkdown, nil
 }
 
 // translateChunked translates large content by splitting it into chunks.
-// This helps avoid timeouts and allows progress updates.
+// This helps avoid timeouts and allows progress updates. Chunks are
+// translated in parallel (see parallelism) and joined in their original order.
+// Each translated chunk is checkpointed, and chunks in the job's checkpoint
+// are not translated again.
 func (p *JobProcessor) translateChunked(ctx context.Context, text string, sourceLang, targetLang string, job *TranslationJob) (string, error) {
 	p.logger.WithFields(logrus.Fields{
 		"job_id":     job.ID,
@@ -156,20 +318,110 @@ func (p *JobProcessor) translateChunked(ctx context.Context, text string, source
 		"total_chunks": totalChunks,
 	}).Info("Split document into chunks")
 
-	var translatedChunks []string
-	
+	if p.translator == nil {
+		return "", nil
+	}
+
+	// Chunks are translated concurrently on separate workers and reassembled
+	// in order; the first failure stops the rest
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	parallelism := p.parallelism(totalChunks)
+	ctx, span := tracing.Start(ctx, "job.chunks",
+		tracing.Int("text_bytes", len(text)),
+		tracing.Int("total_chunks", totalChunks),
+		tracing.Int("parallelism", parallelism),
+	)
+	defer span.End()
+	translatedChunks := make([]string, totalChunks)
+	resumed := job.resumeChunks(p.chunkSize, totalChunks)
+	for i, translated := range resumed {
+		translatedChunks[i] = translated
+	}
+	if len(resumed) > 0 {
+		span.SetAttributes(tracing.Int("resumed_chunks", len(resumed)))
+		jobChunksResumedTotal.Add(float64(len(resumed)))
+		p.logger.WithFields(logrus.Fields{
+			"job_id":       job.ID,
+			"resumed":      len(resumed),
+			"total_chunks": totalChunks,
+		}).Info("Resuming chunked translation from checkpoint")
+		progress := 10 + int32((float64(len(resumed))/float64(totalChunks))*80)
+		job.UpdateProgress(progress, fmt.Sprintf("Resuming with %d/%d chunks already translated...", len(resumed), totalChunks))
+	}
+
+	sem := make(chan struct{}, parallelism)
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		done     = len(resumed)
+		firstErr error
+		paused   bool
+	)
 	for i, chunk := range chunks {
-		// Update progress (10% to 90% for content translation)
-		progress := 10 + int32((float64(i+1)/float64(totalChunks))*80)
-		job.UpdateProgress(progress, fmt.Sprintf("Translating chunk %d/%d...", i+1, totalChunks))
-		
-		if p.translator != nil {
-			translated, err := p.translator.Translate(ctx, chunk, sourceLang, targetLang)
+		if _, ok := resumed[i]; ok {
+			continue
+		}
+
+		// Stop starting chunks if the job was cancelled, timed out or failed
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		// A paused job finishes the chunks in flight and stops
+		if job.pausing() {
+			<-sem
+			paused = true
+			break
+		}
+
+		wg.Add(1)
+		go func(i int, chunk string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			chunkCtx, chunkSpan := tracing.Start(ctx, "job.chunk",
+				tracing.Int("chunk", i+1),
+				tracing.Int("chunk_bytes", len(chunk)),
+			)
+			translated, err := p.translateText(chunkCtx, job, chunk, sourceLang, targetLang)
+			chunkSpan.RecordError(err)
+			chunkSpan.End()
+
+			mu.Lock()
+			defer mu.Unlock()
 			if err != nil {
-				return "", fmt.Errorf("chunk %d translation failed: %w", i+1, err)
+				if firstErr == nil {
+					firstErr = fmt.Errorf("chunk %d translation failed: %w", i+1, err)
+					cancel()
+				}
+				return
 			}
-			translatedChunks = append(translatedChunks, translated)
-		}
+			translatedChunks[i] = translated
+			job.checkpointChunk(i, translated)
+			done++
+
+			// Update progress (10% to 90% for content translation)
+			progress := 10 + int32((float64(done)/float64(totalChunks))*80)
+			job.UpdateChunkProgress(progress, fmt.Sprintf("Translated chunk %d/%d...", done, totalChunks), i+1, totalChunks)
+		}(i, chunk)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		span.RecordError(firstErr)
+		return "", firstErr
+	}
+	if paused && done < totalChunks {
+		return "", fmt.Errorf("%w after %d/%d chunks", errJobPaused, done, totalChunks)
+	}
+	if done < totalChunks {
+		return "", fmt.Errorf("stopped after %d/%d chunks: %w", done, totalChunks, ctx.Err())
 	}
 
 	// Join translated chunks
@@ -181,10 +433,51 @@ func (p *JobProcessor) translateChunked(ctx context.Context, text string, source
 		"translated_length": len(result),
 		"chunks":           totalChunks,
 	}).Info("Chunked translation completed")
+	jobChunks.Observe(float64(totalChunks))
 
 	return result, nil
 }
 
+// parallelism returns how many of a job's chunks are translated at once:
+// the processor's chunk parallelism, bounded by the worker pool size so one
+// job doesn't queue more requests than there are workers.
+func (p *JobProcessor) parallelism(chunks int) int {
+	n := p.chunkParallelism
+	if pool, ok := translate.Backend(p.translator).(*translate.WorkerPool); ok {
+		n = min(n, pool.Size())
+	}
+	return max(1, min(n, chunks))
+}
+
+// translateText translates one piece of a job. When the engine rejects the
+// request as overloaded it waits the suggested time and tries again, so bulk
+// jobs are paced to the engine's capacity instead of failing; the job's own
+// timeout still bounds the wait.
+func (p *JobProcessor) translateText(ctx context.Context, job *TranslationJob, text, sourceLang, targetLang string) (string, error) {
+	for {
+		callStart := time.Now()
+		translated, err := p.translator.Translate(ctx, text, sourceLang, targetLang)
+		p.chargeEngineCall(job, text, time.Since(callStart), err == nil)
+		wait, overloaded := translate.RetryAfter(err)
+		if !overloaded {
+			return translated, err
+		}
+
+		p.logger.WithFields(logrus.Fields{
+			"job_id":      job.ID,
+			"retry_after": wait.String(),
+		}).Debug("Translation engine overloaded, pacing job")
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return "", fmt.Errorf("waiting for translation capacity: %w", ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
 // splitIntoChunks splits text into chunks, trying to break at sentence boundaries.
 func (p *JobProcessor) splitIntoChunks(text string, maxChunkSize int) []string {
 	if len(text) <= maxChunkSize {
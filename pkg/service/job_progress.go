@@ -0,0 +1,62 @@
+package service
+
+import "time"
+
+// maxProgressHistory caps each job's progress history. Past the cap the
+// oldest events are dropped, except the first (the job's submission).
+const maxProgressHistory = 256
+
+// JobProgressEvent is one entry in a job's progress history: a status
+// change or a progress update.
+type JobProgressEvent struct {
+	Seq         int                  `json:"seq"` // 1-based and increasing; kept when older events are dropped
+	Time        time.Time            `json:"time"`
+	Status      TranslationJobStatus `json:"status"`
+	Percent     int32                `json:"percent"`
+	Message     string               `json:"message,omitempty"`
+	Chunk       int                  `json:"chunk,omitempty"`        // 1-based chunk just translated (0 if not chunk progress)
+	TotalChunks int                  `json:"total_chunks,omitempty"` // Chunks in the document, with Chunk
+}
+
+// recordProgress adds the job's current status and progress to its history
+// with message. Callers hold j.mu.
+func (j *TranslationJob) recordProgress(message string, chunk, totalChunks int) {
+	seq := 1
+	if n := len(j.Progress); n > 0 {
+		seq = j.Progress[n-1].Seq + 1
+	}
+	j.Progress = append(j.Progress, JobProgressEvent{
+		Seq:         seq,
+		Time:        time.Now(),
+		Status:      j.Status,
+		Percent:     j.ProgressPercent,
+		Message:     message,
+		Chunk:       chunk,
+		TotalChunks: totalChunks,
+	})
+	if len(j.Progress) > maxProgressHistory {
+		j.Progress = append(j.Progress[:1], j.Progress[2:]...)
+	}
+}
+
+// UpdateChunkProgress updates the progress of a job after chunk (1-based)
+// of totalChunks was translated.
+func (j *TranslationJob) UpdateChunkProgress(percent int32, message string, chunk, totalChunks int) {
+	j.updateProgress(percent, message, chunk, totalChunks)
+}
+
+// ProgressHistory returns a copy of the job's progress events with a
+// sequence number above afterSeq (0 for all of them), oldest first
+// (thread-safe).
+func (j *TranslationJob) ProgressHistory(afterSeq int) []JobProgressEvent {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	var events []JobProgressEvent
+	for _, event := range j.Progress {
+		if event.Seq > afterSeq {
+			events = append(events, event)
+		}
+	}
+	return events
+}
@@ -1,11 +1,17 @@
 package service
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
+	"github.com/dasmlab/iskoces/pkg/audit"
 	nanabushv1 "github.com/dasmlab/iskoces/pkg/proto/v1"
+	"github.com/dasmlab/iskoces/pkg/tracing"
+	"github.com/dasmlab/iskoces/pkg/translate"
 	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
 )
@@ -16,19 +22,46 @@ type TranslationJobStatus string
 const (
 	JobStatusQueued    TranslationJobStatus = "queued"
 	JobStatusProcessing TranslationJobStatus = "processing"
+	JobStatusPaused     TranslationJobStatus = "paused"
 	JobStatusCompleted  TranslationJobStatus = "completed"
 	JobStatusFailed     TranslationJobStatus = "failed"
+	JobStatusCancelled  TranslationJobStatus = "cancelled"
 )
 
+var (
+	// ErrJobNotFound is returned when a job ID is unknown (or already cleaned up).
+	ErrJobNotFound = errors.New("job not found")
+	// ErrJobFinished is returned when an operation requires a job that is still running.
+	ErrJobFinished = errors.New("job already finished")
+	// ErrJobRunning is returned when an operation requires a job that has finished.
+	ErrJobRunning = errors.New("job still running")
+)
+
+// IsTerminal reports whether the status is final (no further processing will happen).
+func (s TranslationJobStatus) IsTerminal() bool {
+	return s == JobStatusCompleted || s == JobStatusFailed || s == JobStatusCancelled
+}
+
 // TranslationJob represents an asynchronous translation job.
 type TranslationJob struct {
 	ID            string
 	RequestID     string // Client-provided job ID
+	Namespace     string
+	ClientID      string // Registered client that submitted the job (x-client-id), if known
+	BatchID       string // Batch the job was submitted in, if any
+	CallbackURL   string // Where the final status is POSTed (overrides the namespace webhook)
 	Status        TranslationJobStatus
 	CreatedAt     time.Time
 	StartedAt     *time.Time
 	CompletedAt   *time.Time
 	Error         string
+	ErrorClass    translate.ErrorClass // Engine error class when failed
+	Crash         *translate.CrashReport // Worker crash behind the failure, if any
+	DeadLetter    bool   // Failed transiently after exhausting its retries; kept for re-queueing
+	RequeuedAs    string // Job a dead-letter job was re-queued as
+	DuplicateOf   string // Job with the same content whose result this job reuses
+	PausedBy      string // Who paused the job (PausedByAdmin or PausedByQueue) while paused
+	Webhook       *WebhookDelivery // Completion webhook delivery, if the job has a webhook
 	
 	// Request data
 	Primitive     nanabushv1.PrimitiveType
@@ -36,16 +69,76 @@ type TranslationJob struct {
 	Document      *nanabushv1.DocumentContent
 	SourceLang    string
 	TargetLang    string
+	LocalizeFormats bool // Convert numbers/dates/units to target-locale conventions
+	Priority      translate.Priority // Worker dispatch priority (carried in ctx)
+	NotBefore     *time.Time // Delayed jobs don't start before this time
 	
 	// Result data
 	TranslatedTitle    string
 	TranslatedMarkdown string
 	TokensUsed         int64
 	InferenceTime      float64
+	ResultExpiresAt    *time.Time // When the result is dropped (nil = kept with the job)
+	Usage              JobUsage   // Resources used so far, across attempts
+	Checkpoint         *JobCheckpoint // Pieces translated so far, until the job finishes
 	
 	// Progress tracking
 	ProgressPercent int32
 	ProgressMessage string
+	Attempt         int          // Current (or final) attempt, 1-based; 0 before processing starts
+	Attempts        []JobAttempt // Failed attempts, oldest first
+	Progress        []JobProgressEvent // Status changes and progress updates, oldest first
+	
+	// Cancellation: ctx is the parent context for all processing of this job
+	// and is cancelled by JobQueue.Cancel.
+	ctx    context.Context
+	cancel context.CancelFunc
+	// trace is the span that submitted the job, which its processing spans
+	// are children of
+	trace tracing.SpanContext
+	
+	// Idempotency: retries with the same key and fingerprint reuse this job
+	idempotencyKey string
+	fingerprint    string
+	
+	// contentHash identifies the job's content for deduplication, if enabled
+	contentHash string
+	
+	// done is closed when the job reaches a terminal state
+	done     chan struct{}
+	doneOnce sync.Once
+	
+	// store persists the job's state changes, if the queue has a store
+	store JobStore
+	// owned is set while this replica holds the job's lease (shared store only)
+	owned bool
+	
+	// scheduler is the scheduler the job was submitted to, if any
+	scheduler *jobScheduler
+	// queuedAt is when the job last joined the scheduler's waiting jobs,
+	// which its priority ages from (guarded by the scheduler's mu)
+	queuedAt time.Time
+	
+	// pauseMessage is set when the job was paused while running; it pauses
+	// with this message after the chunks in flight
+	pauseMessage string
+	
+	// webhooks and events report the job's progress and final state, and
+	// archive and audit record it, if this replica does so; notified is set
+	// once the final state has been
+	webhooks *webhookNotifier
+	events   *jobEventStream
+	archive  *jobArchiver
+	audit    *audit.Logger
+	notified bool
+
+	// auditResult is what the job translated, for its audit record
+	auditResult *auditedResult
+	
+	// results is where the job's result is kept; resultKey is set when its
+	// markdown was offloaded to the result store
+	results   *resultStorage
+	resultKey string
 	
 	// Mutex for thread-safe access
 	mu sync.RWMutex
@@ -55,36 +148,154 @@ type TranslationJob struct {
 type JobQueue struct {
 	jobs      map[string]*TranslationJob
 	jobsMu    sync.RWMutex
+	// idempotency maps idempotency keys to the job they created (guarded by jobsMu)
+	idempotency map[string]*TranslationJob
+	// byContent maps content hashes to the latest job translating that
+	// content, for deduplication (guarded by jobsMu)
+	byContent map[string]*TranslationJob
+	dedup     DedupConfig
 	logger    *logrus.Logger
 	processor *JobProcessor
+	scheduler *jobScheduler // Starts jobs with bounded concurrency
+	store     JobStore // nil keeps jobs in memory only
+	webhooks  *webhookNotifier // Reports finished jobs to callbacks
+	events    *jobEventStream  // Publishes job lifecycle events, if set
+	archive   *jobArchiver     // Archives finished jobs, if set
+	audit     *audit.Logger    // Audits finished jobs, if set
+	results   *resultStorage   // Where completed jobs' results are kept
+	usage     *usageLedger     // Job usage totals per namespace
+	deadLetterRetention time.Duration // How long dead-letter jobs are kept (0 = until re-queued or deleted)
+
+	// batches holds submitted batches by ID and by namespaced request ID,
+	// until their jobs have been cleaned up. batchesMu ranks below jobsMu.
+	batches        map[string]*JobBatch
+	batchByRequest map[string]*JobBatch
+	batchesMu      sync.RWMutex
+
+	// retention is applied every retention.Interval once StartRetention is
+	// called, until retentionStop is closed (guarded by jobsMu)
+	retention         JobRetention
+	retentionOnce     sync.Once
+	retentionStop     chan struct{}
+	retentionStopOnce sync.Once
+
+	// Shared store only: this replica's lease owner name, how many jobs it
+	// processes at once, and closing sharedStop stops claiming; claimers
+	// counts the goroutines claiming (and processing) jobs
+	shared            SharedJobStore
+	owner             string
+	sharedConcurrency int
+	sharedStop        chan struct{}
+	sharedStopOnce    sync.Once
+	claimers          sync.WaitGroup
 }
 
 // NewJobQueue creates a new job queue.
 func NewJobQueue(logger *logrus.Logger) *JobQueue {
-	return &JobQueue{
-		jobs:   make(map[string]*TranslationJob),
-		logger: logger,
+	q := &JobQueue{
+		jobs:        make(map[string]*TranslationJob),
+		idempotency: make(map[string]*TranslationJob),
+		byContent:   make(map[string]*TranslationJob),
+		batches:        make(map[string]*JobBatch),
+		batchByRequest: make(map[string]*JobBatch),
+		logger:      logger,
+		webhooks:    newWebhookNotifier(logger),
+		results:     &resultStorage{logger: logger},
+		usage:       newUsageLedger(),
+		deadLetterRetention: DefaultDeadLetterRetention,
+		retentionStop:       make(chan struct{}),
 	}
+	q.scheduler = newJobScheduler(DefaultMaxConcurrentJobs, func(job *TranslationJob) {
+		q.processor.ProcessJob(job)
+	})
+	return q
 }
 
-// SetProcessor sets the job processor for this queue.
+// SetProcessor sets the job processor for this queue. The processor
+// charges job usage to the queue's per-namespace totals.
 func (q *JobQueue) SetProcessor(processor *JobProcessor) {
 	q.processor = processor
+	if processor != nil {
+		processor.usage = q.usage
+	}
 }
 
-// CreateJob creates a new translation job and returns its ID.
-func (q *JobQueue) CreateJob(req *nanabushv1.TranslateRequest) (string, error) {
+// SetScheduling sets how many jobs are processed at once (0 = unlimited;
+// default DefaultMaxConcurrentJobs) and the order waiting jobs start in.
+// With a shared store, SetSharedConcurrency limits this replica instead.
+func (q *JobQueue) SetScheduling(maxConcurrent int, order JobOrder) {
+	q.scheduler.configure(maxConcurrent, order)
+}
+
+// SetPriorityAging sets how long a waiting job takes to gain a priority
+// level when jobs start in priority order (default DefaultPriorityAging;
+// 0 disables aging). Aging only affects the order jobs start in; their
+// chunks keep the submitted priority on the workers.
+func (q *JobQueue) SetPriorityAging(aging time.Duration) {
+	q.scheduler.configureAging(aging)
+}
+
+// Processor returns the queue's job processor, or nil if none is set.
+func (q *JobQueue) Processor() *JobProcessor {
+	return q.processor
+}
+
+// CreateJob creates a new translation job for the client with clientID ("" if
+// unknown) and returns its ID. A retry of an earlier request (same
+// idempotency key and content) returns the existing job's ID instead, unless
+// that job failed or was cancelled. The job's processing is traced as part
+// of the trace of the span in ctx, if any.
+func (q *JobQueue) CreateJob(ctx context.Context, req *nanabushv1.TranslateRequest, clientID string) (string, error) {
+	return q.createJob(ctx, req, clientID, "")
+}
+
+// createJob creates a job as CreateJob does, as part of the batch with
+// batchID if set.
+func (q *JobQueue) createJob(submitCtx context.Context, req *nanabushv1.TranslateRequest, clientID, batchID string) (string, error) {
+	key, explicit := idempotencyKey(req)
+	fingerprint := ""
+	if key != "" {
+		fingerprint = requestFingerprint(req)
+	}
+
 	jobID := uuid.New().String()
+	priority := requestPriority(req)
+	// The job's worker requests carry the ID of the call that submitted it
+	ctx, cancel := context.WithCancel(translate.WithRequestID(translate.WithPriority(context.Background(), priority), RequestIDFromContext(submitCtx)))
 	
 	job := &TranslationJob{
 		ID:         jobID,
 		RequestID:  req.JobId,
+		Namespace:  req.Namespace,
+		ClientID:   clientID,
+		BatchID:    batchID,
+		CallbackURL: req.CallbackUrl,
 		Status:     JobStatusQueued,
 		CreatedAt:  time.Now(),
 		Primitive:  req.Primitive,
 		SourceLang: req.SourceLanguage,
 		TargetLang: req.TargetLanguage,
+		LocalizeFormats: req.LocalizeFormats,
+		Priority:   priority,
+		trace:      tracing.SpanContextFromContext(submitCtx),
+		ctx:        ctx,
+		cancel:     cancel,
+		idempotencyKey: key,
+		fingerprint:    fingerprint,
+		done:           make(chan struct{}),
+		webhooks:       q.webhooks,
+		events:         q.events,
+		archive:        q.archive,
+		audit:          q.audit,
+		results:        q.results,
+	}
+	
+	if req.NotBefore != nil && req.NotBefore.AsTime().After(job.CreatedAt) {
+		notBefore := req.NotBefore.AsTime()
+		job.NotBefore = &notBefore
+		job.ProgressMessage = fmt.Sprintf("Scheduled to start at %s", notBefore.Format(time.RFC3339))
 	}
+	job.recordProgress(job.ProgressMessage, 0, 0)
 	
 	// Store document data
 	if req.Primitive == nanabushv1.PrimitiveType_PRIMITIVE_TITLE {
@@ -96,42 +307,315 @@ func (q *JobQueue) CreateJob(req *nanabushv1.TranslateRequest) (string, error) {
 		}
 	}
 	
+	hash := ""
+	if q.dedup.Window > 0 && q.shared == nil && job.NotBefore == nil {
+		hash = contentHash(q.dedup.Engine, req)
+		job.contentHash = hash
+	}
+	
 	q.jobsMu.Lock()
+	if existing := q.idempotency[key]; key != "" && existing != nil {
+		existingStatus, _, _ := existing.GetStatus()
+		reusable := existingStatus != JobStatusFailed && existingStatus != JobStatusCancelled
+		switch {
+		case reusable && existing.fingerprint == fingerprint:
+			q.jobsMu.Unlock()
+			cancel()
+			q.logger.WithFields(logrus.Fields{
+				"job_id":     existing.ID,
+				"request_id": req.JobId,
+				"status":     existingStatus,
+			}).Info("Reusing existing translation job for retried request")
+			return existing.ID, nil
+		case reusable && explicit:
+			q.jobsMu.Unlock()
+			cancel()
+			return "", fmt.Errorf("%w: key %q", ErrIdempotencyConflict, req.IdempotencyKey)
+		}
+	}
+	var duplicateOf *TranslationJob
+	if hash != "" {
+		if duplicateOf = q.duplicateSource(hash, job.CreatedAt); duplicateOf != nil {
+			job.DuplicateOf = duplicateOf.ID
+		} else {
+			q.byContent[hash] = job
+		}
+	}
 	q.jobs[jobID] = job
+	if key != "" {
+		q.idempotency[key] = job
+	}
+	switch {
+	case q.shared != nil:
+		job.store = q.store
+	case q.store != nil:
+		job.store = q.store
+		job.mu.Lock()
+		job.persist()
+		job.mu.Unlock()
+	}
 	q.jobsMu.Unlock()
+
+	// A shared queue's jobs go to the store, for any replica to claim
+	if q.shared != nil {
+		job.mu.RLock()
+		rec := job.record()
+		job.mu.RUnlock()
+		if err := q.shared.enqueue(rec); err != nil {
+			q.jobsMu.Lock()
+			delete(q.jobs, jobID)
+			if q.idempotency[key] == job {
+				delete(q.idempotency, key)
+			}
+			q.jobsMu.Unlock()
+			cancel()
+			return "", fmt.Errorf("failed to add job to shared queue: %w", err)
+		}
+	}
 	
+	job.mu.Lock()
+	job.emitEvent(JobEventCreated)
+	job.mu.Unlock()
+
 	q.logger.WithFields(logrus.Fields{
 		"job_id":     jobID,
 		"request_id": req.JobId,
 		"primitive":  req.Primitive.String(),
+		"priority":   priority.String(),
 	}).Info("Created translation job")
+	jobsSubmittedTotal.WithLabelValues(req.Primitive.String()).Inc()
+	
+	// A duplicate takes its source's result: at once if the source already
+	// completed, otherwise when it does
+	switch {
+	case duplicateOf != nil:
+		select {
+		case <-duplicateOf.Done():
+			jobDedupTotal.WithLabelValues("reused").Inc()
+			q.runDuplicate(job, duplicateOf)
+		default:
+			jobDedupTotal.WithLabelValues("linked").Inc()
+			go q.runDuplicate(job, duplicateOf)
+		}
 	
 	// Start processing asynchronously if processor is set
-	if q.processor != nil {
-		go q.processor.ProcessJob(job)
+	case q.processor != nil && q.shared == nil:
+		q.scheduler.submit(job)
 	}
 	
 	return jobID, nil
 }
 
-// GetJob retrieves a job by ID.
+// GetJob retrieves a job by ID. With a shared store, a job submitted to
+// another replica is looked up in the store.
 func (q *JobQueue) GetJob(jobID string) (*TranslationJob, error) {
 	q.jobsMu.RLock()
-	defer q.jobsMu.RUnlock()
-	
 	job, exists := q.jobs[jobID]
+	q.jobsMu.RUnlock()
+	
 	if !exists {
-		return nil, fmt.Errorf("job not found: %s", jobID)
+		if q.shared != nil {
+			return q.follow(jobID)
+		}
+		return nil, fmt.Errorf("%w: %s", ErrJobNotFound, jobID)
 	}
 	
 	return job, nil
 }
 
+// ListJobs returns the jobs the queue holds, oldest first.
+func (q *JobQueue) ListJobs() []*TranslationJob {
+	q.jobsMu.RLock()
+	jobs := make([]*TranslationJob, 0, len(q.jobs))
+	for _, job := range q.jobs {
+		jobs = append(jobs, job)
+	}
+	q.jobsMu.RUnlock()
+
+	sort.Slice(jobs, func(i, j int) bool {
+		if !jobs[i].CreatedAt.Equal(jobs[j].CreatedAt) {
+			return jobs[i].CreatedAt.Before(jobs[j].CreatedAt)
+		}
+		return jobs[i].ID < jobs[j].ID
+	})
+	return jobs
+}
+
+// DeleteJob removes a finished job (and its idempotency key) from the queue.
+func (q *JobQueue) DeleteJob(jobID string) error {
+	q.jobsMu.Lock()
+	defer q.jobsMu.Unlock()
+
+	job, exists := q.jobs[jobID]
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrJobNotFound, jobID)
+	}
+	if jobStatus, _, _ := job.GetStatus(); !jobStatus.IsTerminal() {
+		return fmt.Errorf("%w: job %s is %s", ErrJobRunning, jobID, jobStatus)
+	}
+	delete(q.jobs, jobID)
+	if q.idempotency[job.idempotencyKey] == job {
+		delete(q.idempotency, job.idempotencyKey)
+	}
+	if q.byContent[job.contentHash] == job {
+		delete(q.byContent, job.contentHash)
+	}
+	if q.store != nil {
+		q.store.remove(jobID)
+	}
+	job.mu.RLock()
+	resultKey := job.resultKey
+	job.mu.RUnlock()
+	if resultKey != "" {
+		go q.results.remove(resultKey)
+	}
+	return nil
+}
+
+// FindJob retrieves a job by server job ID or, failing that, by the
+// client-provided job ID (the most recent job with that ID). Only jobs in
+// namespaces allows accepts are found. Client-provided IDs are only matched
+// against jobs this replica knows of.
+func (q *JobQueue) FindJob(id string, allows func(namespace string) bool) (*TranslationJob, error) {
+	notFound := fmt.Errorf("%w: %s", ErrJobNotFound, id)
+	q.jobsMu.RLock()
+	if job, exists := q.jobs[id]; exists {
+		q.jobsMu.RUnlock()
+		if !allows(job.Namespace) {
+			return nil, notFound
+		}
+		return job, nil
+	}
+	var found *TranslationJob
+	for _, job := range q.jobs {
+		if job.RequestID == id && allows(job.Namespace) && (found == nil || job.CreatedAt.After(found.CreatedAt)) {
+			found = job
+		}
+	}
+	q.jobsMu.RUnlock()
+	if found == nil {
+		if q.shared != nil {
+			job, err := q.follow(id)
+			if err == nil && !allows(job.Namespace) {
+				return nil, notFound
+			}
+			return job, err
+		}
+		return nil, notFound
+	}
+	return found, nil
+}
+
+// Cancel cancels a queued or running job. The job is marked cancelled immediately
+// and its processing context is cancelled, which stops the chunk loop and
+// abandons any in-flight worker request.
+func (q *JobQueue) Cancel(jobID, reason string) (*TranslationJob, error) {
+	job, err := q.GetJob(jobID)
+	if err != nil {
+		return nil, err
+	}
+
+	message := "Translation cancelled"
+	if reason != "" {
+		message = fmt.Sprintf("Translation cancelled: %s", reason)
+	}
+	if err := q.cancelJob(job, message); err != nil {
+		return job, err
+	}
+
+	q.logger.WithFields(logrus.Fields{
+		"job_id":     jobID,
+		"request_id": job.RequestID,
+		"reason":     reason,
+	}).Info("Cancelled translation job")
+
+	return job, nil
+}
+
+// cancelJob marks a job cancelled with message and stops its processing.
+func (q *JobQueue) cancelJob(job *TranslationJob, message string) error {
+	job.mu.Lock()
+	if job.Status.IsTerminal() {
+		current := job.Status
+		job.mu.Unlock()
+		return fmt.Errorf("%w: job %s is %s", ErrJobFinished, job.ID, current)
+	}
+	job.Status = JobStatusCancelled
+	job.PausedBy = ""
+	job.Checkpoint = nil
+	job.ProgressMessage = message
+	now := time.Now()
+	job.CompletedAt = &now
+	job.recordProgress(message, 0, 0)
+	job.markDone()
+	job.notifyFinished()
+	job.persist()
+	scheduler := job.scheduler
+	job.mu.Unlock()
+
+	// A job that hadn't started gives up its place
+	if scheduler != nil {
+		scheduler.remove(job)
+	}
+
+	// Cancel outside the lock; the processor's error path takes job.mu
+	if job.cancel != nil {
+		job.cancel()
+	}
+	return nil
+}
+
+// Context returns the job's processing context, which is cancelled when the job is.
+func (j *TranslationJob) Context() context.Context {
+	if j.ctx == nil {
+		return context.Background()
+	}
+	return j.ctx
+}
+
+// Done returns a channel that is closed when the job completes, fails, or is cancelled.
+func (j *TranslationJob) Done() <-chan struct{} {
+	return j.done
+}
+
+// markDone closes the done channel once. Callers hold j.mu.
+func (j *TranslationJob) markDone() {
+	if j.done == nil {
+		return
+	}
+	j.doneOnce.Do(func() { close(j.done) })
+}
+
+// notifyFinished reports the job's final state to its webhook and as a
+// lifecycle event, and archives it, once. Callers hold j.mu and persist
+// the job afterwards, so the webhook delivery is stored with its final
+// state.
+func (j *TranslationJob) notifyFinished() {
+	if j.notified {
+		return
+	}
+	j.notified = true
+	j.recordFinished()
+	if j.webhooks != nil {
+		j.webhooks.notify(j)
+	}
+	// The final states double as event types
+	j.emitEvent(string(j.Status))
+	j.archiveFinished()
+	j.auditFinished()
+}
+
 // UpdateJobStatus updates the status of a job.
 func (j *TranslationJob) UpdateStatus(status TranslationJobStatus, message string) {
 	j.mu.Lock()
 	defer j.mu.Unlock()
 	
+	// A cancelled job stays cancelled even if the processor hasn't noticed yet
+	if j.Status == JobStatusCancelled {
+		return
+	}
+	
+	started := status == JobStatusProcessing && j.Status != JobStatusProcessing
 	j.Status = status
 	j.ProgressMessage = message
 	
@@ -145,16 +629,42 @@ func (j *TranslationJob) UpdateStatus(status TranslationJobStatus, message strin
 		if j.CompletedAt == nil {
 			j.CompletedAt = &now
 		}
+		j.markDone()
+	}
+	j.recordProgress(message, 0, 0)
+	if status.IsTerminal() {
+		j.notifyFinished()
+	}
+	j.persist()
+	if started {
+		j.emitEvent(JobEventStarted)
 	}
 }
 
 // UpdateProgress updates the progress of a job.
 func (j *TranslationJob) UpdateProgress(percent int32, message string) {
+	j.updateProgress(percent, message, 0, 0)
+}
+
+// updateProgress updates the progress of a job, after chunk of totalChunks
+// was translated if chunk is set.
+func (j *TranslationJob) updateProgress(percent int32, message string, chunk, totalChunks int) {
 	j.mu.Lock()
 	defer j.mu.Unlock()
 	
+	if j.Status == JobStatusCancelled {
+		return
+	}
+	
 	j.ProgressPercent = percent
 	j.ProgressMessage = message
+	j.recordProgress(message, chunk, totalChunks)
+	
+	// Replicas sharing the queue see progress through the store
+	if _, shared := j.store.(SharedJobStore); shared {
+		j.persist()
+	}
+	j.emitEvent(JobEventProgress)
 }
 
 // SetError sets the error message for a failed job.
@@ -162,25 +672,71 @@ func (j *TranslationJob) SetError(err error) {
 	j.mu.Lock()
 	defer j.mu.Unlock()
 	
+	if j.Status == JobStatusCancelled {
+		return
+	}
+	
 	j.Error = err.Error()
+	j.ErrorClass = translate.ClassifyError(err)
+	j.Crash, _ = translate.CrashReportOf(err)
 	j.Status = JobStatusFailed
+	j.Checkpoint = nil
+	j.deadLetter()
 	now := time.Now()
 	j.CompletedAt = &now
+	j.recordProgress(j.Error, 0, 0)
+	j.markDone()
+	j.notifyFinished()
+	j.persist()
 }
 
-// SetResult sets the translation result for a completed job.
+// SetResult sets the translation result for a completed job. Large
+// markdown is offloaded to the queue's result store, if it has one.
 func (j *TranslationJob) SetResult(title, markdown string, tokens int64, inferenceTime float64) {
+	// Offload before taking the lock; uploads can be slow
+	j.mu.RLock()
+	results := j.results
+	audited := j.audit
+	j.mu.RUnlock()
+	var auditResult *auditedResult
+	if audited != nil {
+		auditResult = &auditedResult{}
+		auditResult.chars = addTexts(&auditResult.content, []string{title, markdown}, audited.IncludesContent())
+	}
+	resultKey := ""
+	if results != nil {
+		markdown, resultKey = results.offload(j.ID, markdown)
+	}
+	
 	j.mu.Lock()
 	defer j.mu.Unlock()
 	
+	if j.Status == JobStatusCancelled {
+		if resultKey != "" {
+			go results.remove(resultKey)
+		}
+		return
+	}
+	
 	j.TranslatedTitle = title
 	j.TranslatedMarkdown = markdown
+	j.resultKey = resultKey
+	j.auditResult = auditResult
 	j.TokensUsed = tokens
 	j.InferenceTime = inferenceTime
 	j.Status = JobStatusCompleted
+	j.Checkpoint = nil
 	now := time.Now()
 	j.CompletedAt = &now
+	if results != nil && results.cfg.TTL > 0 {
+		expiresAt := now.Add(results.cfg.TTL)
+		j.ResultExpiresAt = &expiresAt
+	}
 	j.ProgressPercent = 100
+	j.recordProgress(j.ProgressMessage, 0, 0)
+	j.markDone()
+	j.notifyFinished()
+	j.persist()
 }
 
 // GetStatus returns a copy of the job status (thread-safe).
@@ -191,29 +747,32 @@ func (j *TranslationJob) GetStatus() (TranslationJobStatus, string, int32) {
 	return j.Status, j.ProgressMessage, j.ProgressPercent
 }
 
-// CleanupOldJobs removes jobs older than the specified duration.
-func (q *JobQueue) CleanupOldJobs(maxAge time.Duration) {
-	q.jobsMu.Lock()
-	defer q.jobsMu.Unlock()
-	
-	now := time.Now()
-	removed := 0
-	
-	for id, job := range q.jobs {
-		// Only remove completed or failed jobs that are old
-		if (job.Status == JobStatusCompleted || job.Status == JobStatusFailed) {
-			if job.CompletedAt != nil && now.Sub(*job.CompletedAt) > maxAge {
-				delete(q.jobs, id)
-				removed++
-			}
-		}
+// ContentBytes returns the size of the content the job translates.
+func (j *TranslationJob) ContentBytes() int {
+	size := len(j.Title)
+	if j.Document != nil {
+		size += len(j.Document.Markdown)
 	}
-	
-	if removed > 0 {
-		q.logger.WithFields(logrus.Fields{
-			"removed": removed,
-			"remaining": len(q.jobs),
-		}).Info("Cleaned up old translation jobs")
+	return size
+}
+
+// Backlog returns the number of unfinished jobs at or above minPriority and
+// the content still left to translate for them, in bytes. Lower-priority jobs
+// are excluded because they don't delay higher-priority work, and paused
+// jobs because they don't run until resumed.
+func (q *JobQueue) Backlog(minPriority translate.Priority) (jobs int, bytes int) {
+	q.jobsMu.RLock()
+	defer q.jobsMu.RUnlock()
+
+	for _, job := range q.jobs {
+		job.mu.RLock()
+		if !job.Status.IsTerminal() && job.Status != JobStatusPaused && job.Priority >= minPriority {
+			jobs++
+			bytes += job.ContentBytes() * int(100-job.ProgressPercent) / 100
+		}
+		job.mu.RUnlock()
 	}
+
+	return jobs, bytes
 }
 
@@ -0,0 +1,318 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultSharedJobConcurrency is how many jobs a replica processes at
+	// once from a shared queue.
+	DefaultSharedJobConcurrency = 4
+
+	// jobLeaseDuration is how long a claimed job stays with a replica
+	// without the lease being renewed. A replica that crashes loses its
+	// jobs to the others after this long (the visibility timeout).
+	jobLeaseDuration = 30 * time.Second
+
+	// jobClaimInterval is how often an idle replica polls a shared queue.
+	jobClaimInterval = 500 * time.Millisecond
+
+	// jobSyncInterval is how often claimed jobs' leases are renewed and
+	// other replicas' jobs are refreshed from the store.
+	jobSyncInterval = time.Second
+)
+
+// SharedJobStore is a JobStore several replicas use as one queue. A job
+// submitted to any replica is claimed by one of them, which holds a lease on
+// it while processing; a job whose lease expires (its replica crashed) is
+// queued again for another replica to claim.
+type SharedJobStore interface {
+	JobStore
+	// enqueue stores a new job and queues it to be claimed.
+	enqueue(rec jobRecord) error
+	// claim takes the next queued job for owner, leased for lease. It also
+	// queues again the jobs whose leases have expired.
+	claim(owner string, lease time.Duration) (jobRecord, bool, error)
+	// renew extends owner's lease on a job. It reports false if owner no
+	// longer holds the lease.
+	renew(jobID, owner string, lease time.Duration) (bool, error)
+	// release drops owner's lease on a job it has finished.
+	release(jobID, owner string)
+	// get returns a job's stored state.
+	get(jobID string) (jobRecord, bool, error)
+}
+
+// SetSharedConcurrency sets how many jobs this replica processes at once
+// from a shared queue (default: DefaultSharedJobConcurrency). It must be
+// called before SetStore.
+func (q *JobQueue) SetSharedConcurrency(n int) {
+	q.sharedConcurrency = max(1, n)
+}
+
+// startShared starts claiming jobs from the shared store and keeping leases
+// and other replicas' jobs up to date.
+func (q *JobQueue) startShared() {
+	host, _ := os.Hostname()
+	q.owner = fmt.Sprintf("%s-%d-%s", host, os.Getpid(), uuid.New().String()[:8])
+	q.sharedStop = make(chan struct{})
+	concurrency := q.sharedConcurrency
+	if concurrency == 0 {
+		concurrency = DefaultSharedJobConcurrency
+	}
+
+	if q.processor != nil {
+		q.claimers.Add(concurrency)
+		for i := 0; i < concurrency; i++ {
+			go q.claimJobs()
+		}
+	}
+	go q.syncShared()
+
+	q.logger.WithFields(logrus.Fields{
+		"owner":       q.owner,
+		"concurrency": concurrency,
+	}).Info("Joined shared translation job queue")
+}
+
+// stopShared stops claiming jobs. Jobs already claimed keep running.
+func (q *JobQueue) stopShared() {
+	q.sharedStopOnce.Do(func() { close(q.sharedStop) })
+}
+
+// claimJobs claims and processes jobs one at a time until the queue shuts
+// down.
+func (q *JobQueue) claimJobs() {
+	defer q.claimers.Done()
+	for {
+		select {
+		case <-q.sharedStop:
+			return
+		default:
+		}
+
+		rec, ok, err := q.shared.claim(q.owner, jobLeaseDuration)
+		if err != nil {
+			q.logger.WithError(err).Warn("Failed to claim job from shared queue")
+		}
+		if err != nil || !ok {
+			select {
+			case <-q.sharedStop:
+				return
+			case <-time.After(jobClaimInterval):
+			}
+			continue
+		}
+
+		job := q.adopt(rec)
+		if job == nil {
+			q.shared.release(rec.ID, q.owner)
+			continue
+		}
+		q.logger.WithFields(logrus.Fields{
+			"job_id":     job.ID,
+			"request_id": job.RequestID,
+		}).Info("Claimed translation job from shared queue")
+		q.processor.ProcessJob(job)
+		q.shared.release(job.ID, q.owner)
+	}
+}
+
+// adopt makes a claimed job this replica's own, reusing the local copy if
+// it was submitted here. It returns nil if the job has already finished,
+// e.g. it was cancelled while queued.
+func (q *JobQueue) adopt(rec jobRecord) *TranslationJob {
+	if rec.Status.IsTerminal() {
+		return nil
+	}
+
+	q.jobsMu.Lock()
+	defer q.jobsMu.Unlock()
+
+	job, exists := q.jobs[rec.ID]
+	if !exists {
+		var err error
+		job, err = jobFromRecord(rec, "")
+		if err != nil {
+			q.logger.WithError(err).Warn("Skipping unreadable job from shared queue")
+			return nil
+		}
+		job.store = q.store
+		job.webhooks = q.webhooks
+		job.events = q.events
+		job.archive = q.archive
+		job.audit = q.audit
+		job.results = q.results
+		q.jobs[job.ID] = job
+		if job.idempotencyKey != "" && q.idempotency[job.idempotencyKey] == nil {
+			q.idempotency[job.idempotencyKey] = job
+		}
+	}
+
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	if job.Status.IsTerminal() {
+		return nil
+	}
+	if job.Status == JobStatusProcessing {
+		// The replica processing it stopped renewing its lease
+		job.Status = JobStatusQueued
+		job.StartedAt = nil
+		job.ProgressPercent = 0
+		job.ProgressMessage = "Re-queued after its lease expired"
+		job.recordProgress(job.ProgressMessage, 0, 0)
+	}
+	job.owned = true
+	return job
+}
+
+// follow adds another replica's job to the local map so it can be looked up
+// and waited on here; syncShared keeps it up to date.
+func (q *JobQueue) follow(jobID string) (*TranslationJob, error) {
+	rec, ok, err := q.shared.get(jobID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up job %s: %w", jobID, err)
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrJobNotFound, jobID)
+	}
+	job, err := jobFromRecord(rec, "")
+	if err != nil {
+		return nil, err
+	}
+	job.store = q.store
+	job.webhooks = q.webhooks
+	job.events = q.events
+	job.archive = q.archive
+	job.audit = q.audit
+	job.results = q.results
+
+	q.jobsMu.Lock()
+	defer q.jobsMu.Unlock()
+	if existing, exists := q.jobs[jobID]; exists {
+		return existing, nil
+	}
+	q.jobs[jobID] = job
+	return job, nil
+}
+
+// syncShared renews the leases on this replica's jobs, cancels those
+// cancelled elsewhere, and refreshes other replicas' jobs from the store.
+func (q *JobQueue) syncShared() {
+	ticker := time.NewTicker(jobSyncInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		q.jobsMu.RLock()
+		jobs := make([]*TranslationJob, 0, len(q.jobs))
+		for _, job := range q.jobs {
+			jobs = append(jobs, job)
+		}
+		q.jobsMu.RUnlock()
+
+		for _, job := range jobs {
+			job.mu.RLock()
+			owned, finished := job.owned, job.Status.IsTerminal()
+			job.mu.RUnlock()
+			if finished {
+				continue
+			}
+
+			if owned {
+				held, err := q.shared.renew(job.ID, q.owner, jobLeaseDuration)
+				if err != nil {
+					q.logger.WithError(err).WithField("job_id", job.ID).Warn("Failed to renew job lease")
+					continue
+				}
+				if !held {
+					q.dropClaim(job)
+					continue
+				}
+			}
+
+			rec, ok, err := q.shared.get(job.ID)
+			if err != nil || !ok {
+				continue
+			}
+			if owned {
+				if rec.Status == JobStatusCancelled {
+					q.cancelJob(job, rec.ProgressMessage)
+				}
+				continue
+			}
+			job.apply(rec)
+		}
+	}
+}
+
+// dropClaim stops processing a job whose lease this replica lost, e.g.
+// after a long pause; another replica has it now. Nothing more is recorded
+// from here.
+func (q *JobQueue) dropClaim(job *TranslationJob) {
+	q.logger.WithField("job_id", job.ID).Warn("Lost lease on translation job, abandoning it")
+
+	q.jobsMu.Lock()
+	if q.jobs[job.ID] == job {
+		delete(q.jobs, job.ID)
+	}
+	if q.idempotency[job.idempotencyKey] == job {
+		delete(q.idempotency, job.idempotencyKey)
+	}
+	q.jobsMu.Unlock()
+
+	job.mu.Lock()
+	job.store = nil
+	job.webhooks = nil
+	job.events = nil
+	job.archive = nil
+	job.audit = nil
+	job.results = nil
+	job.owned = false
+	job.mu.Unlock()
+	job.cancel()
+}
+
+// apply copies another replica's progress on the job from its stored state.
+func (j *TranslationJob) apply(rec jobRecord) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.Status.IsTerminal() {
+		return
+	}
+	j.Status = rec.Status
+	j.StartedAt = rec.StartedAt
+	j.CompletedAt = rec.CompletedAt
+	j.Error = rec.Error
+	j.ErrorClass = rec.ErrorClass
+	j.ProgressPercent = rec.ProgressPercent
+	j.ProgressMessage = rec.ProgressMessage
+	j.TranslatedTitle = rec.TranslatedTitle
+	j.TranslatedMarkdown = rec.TranslatedMarkdown
+	j.TokensUsed = rec.TokensUsed
+	j.InferenceTime = rec.InferenceTime
+	j.ResultExpiresAt = rec.ResultExpiresAt
+	j.resultKey = rec.ResultKey
+	j.Attempt = rec.Attempt
+	j.Attempts = rec.Attempts
+	j.DeadLetter = rec.DeadLetter
+	j.RequeuedAs = rec.RequeuedAs
+	j.PausedBy = rec.PausedBy
+	j.Webhook = rec.Webhook
+	j.Progress = rec.Progress
+	j.Usage = rec.Usage
+	j.Checkpoint = rec.Checkpoint
+	if j.Status.IsTerminal() {
+		if j.Status == JobStatusCompleted {
+			j.ProgressPercent = 100
+		}
+		j.markDone()
+		if j.cancel != nil {
+			j.cancel()
+		}
+	}
+}
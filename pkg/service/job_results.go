@@ -0,0 +1,384 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultResultTTL is how long completed job results are kept, matching
+	// how long finished jobs are kept.
+	DefaultResultTTL = time.Hour
+	// DefaultResultOffloadBytes is the size from which translated markdown
+	// is offloaded to the result store, when there is one.
+	DefaultResultOffloadBytes = 64 << 10
+
+	// resultURLMaxLifetime caps how long a presigned result URL is valid
+	// (S3 allows at most 7 days).
+	resultURLMaxLifetime = 7 * 24 * time.Hour
+)
+
+var (
+	// ErrResultNotFound is returned when a completed job's result has
+	// expired or is missing from the result store.
+	ErrResultNotFound = errors.New("job result not found")
+	// ErrNoResult is returned for the result of a job that failed or was
+	// cancelled.
+	ErrNoResult = errors.New("job has no result")
+)
+
+var (
+	jobResultsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "iskoces_job_results_total",
+			Help: "Total number of completed job results, by where they were kept (memory, offloaded, offload_failed)",
+		},
+		[]string{"storage"},
+	)
+
+	jobResultsExpiredTotal = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "iskoces_job_results_expired_total",
+			Help: "Total number of job results dropped after the result TTL",
+		},
+	)
+)
+
+// ResultStore keeps offloaded job results outside the server's memory.
+type ResultStore interface {
+	// Put stores data under key, replacing any earlier value.
+	Put(key string, data []byte) error
+	// Get returns the data under key, or ErrResultNotFound.
+	Get(key string) ([]byte, error)
+	// Delete removes key; deleting a missing key is not an error.
+	Delete(key string) error
+	// URL returns a URL the data under key can be fetched from directly,
+	// valid for lifetime, or "" if the store has no such URLs.
+	URL(key string, lifetime time.Duration) (string, error)
+}
+
+// resultSweeper is implemented by stores that can drop results left behind
+// by jobs the server no longer knows of (e.g. from before a restart).
+type resultSweeper interface {
+	sweep(before time.Time) int
+}
+
+// OpenResultStore opens a result store for location: an
+// s3://bucket[/prefix][?endpoint=URL&region=REGION] URL for S3-compatible
+// object storage, or a directory path (optionally as a file:// URL).
+func OpenResultStore(location string) (ResultStore, error) {
+	if strings.HasPrefix(location, "s3://") {
+		return NewS3ResultStore(location)
+	}
+	return NewDiskResultStore(strings.TrimPrefix(location, "file://"))
+}
+
+// ResultConfig controls where completed job results are kept and for how
+// long.
+type ResultConfig struct {
+	// Store receives translated markdown of at least OffloadBytes bytes (any
+	// size if OffloadBytes is 0); nil keeps every result in memory.
+	Store        ResultStore
+	OffloadBytes int
+	// TTL is how long after completion a result is kept (0 = as long as
+	// the job).
+	TTL time.Duration
+	// BaseURL is the server's external HTTP address (e.g.
+	// "https://iskoces.example.com"), used to make result URLs absolute.
+	BaseURL string
+}
+
+// resultStorage applies the queue's ResultConfig to its jobs' results.
+type resultStorage struct {
+	cfg    ResultConfig
+	logger *logrus.Logger
+}
+
+// offload moves markdown to the result store if the store takes results
+// that large. It returns the markdown to keep in memory and the key it was
+// stored under ("" if it wasn't offloaded). A failed offload keeps the
+// result in memory.
+func (r *resultStorage) offload(jobID, markdown string) (string, string) {
+	if r.cfg.Store == nil || len(markdown) < r.cfg.OffloadBytes || markdown == "" {
+		jobResultsTotal.WithLabelValues("memory").Inc()
+		return markdown, ""
+	}
+	key := jobID + ".md"
+	if err := r.cfg.Store.Put(key, []byte(markdown)); err != nil {
+		jobResultsTotal.WithLabelValues("offload_failed").Inc()
+		r.logger.WithError(err).WithField("job_id", jobID).Warn("Failed to offload job result, keeping it in memory")
+		return markdown, ""
+	}
+	jobResultsTotal.WithLabelValues("offloaded").Inc()
+	return "", key
+}
+
+// load reads an offloaded result.
+func (r *resultStorage) load(key string) (string, error) {
+	if r == nil || r.cfg.Store == nil {
+		return "", fmt.Errorf("%w: no result store is configured to read %s from", ErrResultNotFound, key)
+	}
+	data, err := r.cfg.Store.Get(key)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// remove deletes offloaded results, logging failures.
+func (r *resultStorage) remove(keys ...string) {
+	if r == nil || r.cfg.Store == nil {
+		return
+	}
+	for _, key := range keys {
+		if err := r.cfg.Store.Delete(key); err != nil {
+			r.logger.WithError(err).WithField("key", key).Warn("Failed to delete offloaded job result")
+		}
+	}
+}
+
+// ResultInfo describes where a completed job's result is.
+type ResultInfo struct {
+	// URL is where the translated markdown can be fetched: a presigned
+	// object storage URL for offloaded results if the store has them,
+	// otherwise the server's /api/v1/jobs/{id}/result endpoint. Empty
+	// unless the job completed and its result is still kept.
+	URL string
+	// ExpiresAt is when the result is dropped (nil = kept with the job).
+	ExpiresAt *time.Time
+	// Offloaded is set when the markdown is in the result store rather
+	// than in TranslatedMarkdown.
+	Offloaded bool
+	// Expired is set once the result has been dropped.
+	Expired bool
+}
+
+// ResultInfo returns where the job's result is (thread-safe).
+func (j *TranslationJob) ResultInfo() ResultInfo {
+	j.mu.RLock()
+	status, key, results := j.Status, j.resultKey, j.results
+	info := ResultInfo{ExpiresAt: j.ResultExpiresAt, Offloaded: key != ""}
+	j.mu.RUnlock()
+
+	if status != JobStatusCompleted {
+		return ResultInfo{}
+	}
+	if resultExpired(info.ExpiresAt, time.Now()) {
+		info.Expired = true
+		info.Offloaded = false
+		return info
+	}
+
+	var baseURL string
+	if results != nil {
+		baseURL = results.cfg.BaseURL
+		if key != "" && results.cfg.Store != nil {
+			lifetime := resultURLMaxLifetime
+			if info.ExpiresAt != nil {
+				lifetime = min(lifetime, time.Until(*info.ExpiresAt))
+			}
+			url, err := results.cfg.Store.URL(key, lifetime)
+			if err != nil {
+				results.logger.WithError(err).WithField("job_id", j.ID).Warn("Failed to make result URL")
+			}
+			if url != "" {
+				info.URL = url
+				return info
+			}
+		}
+	}
+	info.URL = strings.TrimSuffix(baseURL, "/") + "/api/v1/jobs/" + j.ID + "/result"
+	return info
+}
+
+// Result returns a completed job's translated title and markdown, reading
+// offloaded markdown from the result store. It returns ErrJobRunning for a
+// job that hasn't finished, ErrNoResult for one that failed or was
+// cancelled, and ErrResultNotFound once the result has expired.
+func (j *TranslationJob) Result() (title, markdown string, err error) {
+	j.mu.RLock()
+	status, key, results, expiresAt := j.Status, j.resultKey, j.results, j.ResultExpiresAt
+	title, markdown = j.TranslatedTitle, j.TranslatedMarkdown
+	j.mu.RUnlock()
+
+	switch {
+	case !status.IsTerminal():
+		return "", "", fmt.Errorf("%w: job %s is %s", ErrJobRunning, j.ID, status)
+	case status != JobStatusCompleted:
+		return "", "", fmt.Errorf("%w: job %s is %s", ErrNoResult, j.ID, status)
+	case resultExpired(expiresAt, time.Now()):
+		return "", "", fmt.Errorf("%w: the result of job %s expired at %s", ErrResultNotFound, j.ID, expiresAt.Format(time.RFC3339))
+	case key == "":
+		return title, markdown, nil
+	}
+	markdown, err = results.load(key)
+	if err != nil {
+		return "", "", err
+	}
+	return title, markdown, nil
+}
+
+// resultExpired reports whether a result expiring at expiresAt has expired
+// by now.
+func resultExpired(expiresAt *time.Time, now time.Time) bool {
+	return expiresAt != nil && !now.Before(*expiresAt)
+}
+
+// SetResults sets where completed job results are kept and for how long.
+// It must be called before jobs are submitted or restored from a store.
+func (q *JobQueue) SetResults(cfg ResultConfig) {
+	q.results.cfg = cfg
+}
+
+// ExpireResults drops the results of jobs that completed more than the
+// result TTL ago, and, for a disk result store, result files that old.
+func (q *JobQueue) ExpireResults() {
+	ttl := q.results.cfg.TTL
+	if ttl <= 0 {
+		return
+	}
+	now := time.Now()
+
+	q.jobsMu.RLock()
+	jobs := make([]*TranslationJob, 0, len(q.jobs))
+	for _, job := range q.jobs {
+		jobs = append(jobs, job)
+	}
+	q.jobsMu.RUnlock()
+
+	var keys []string
+	expired := 0
+	for _, job := range jobs {
+		job.mu.Lock()
+		if job.Status == JobStatusCompleted && resultExpired(job.ResultExpiresAt, now) &&
+			(job.resultKey != "" || job.TranslatedTitle != "" || job.TranslatedMarkdown != "") {
+			if job.resultKey != "" {
+				keys = append(keys, job.resultKey)
+			}
+			job.resultKey = ""
+			job.TranslatedTitle = ""
+			job.TranslatedMarkdown = ""
+			// A shared store's finished jobs expire on their own
+			if _, shared := job.store.(SharedJobStore); !shared {
+				job.persist()
+			}
+			expired++
+		}
+		job.mu.Unlock()
+	}
+	q.results.remove(keys...)
+
+	swept := 0
+	if sweeper, ok := q.results.cfg.Store.(resultSweeper); ok {
+		swept = sweeper.sweep(now.Add(-ttl))
+	}
+	if expired > 0 || swept > 0 {
+		jobResultsExpiredTotal.Add(float64(expired))
+		q.logger.WithFields(logrus.Fields{
+			"expired": expired,
+			"swept":   swept,
+		}).Info("Dropped expired job results")
+	}
+}
+
+// DiskResultStore keeps offloaded results as files in a directory. Its
+// results can only be fetched through the server that wrote them.
+type DiskResultStore struct {
+	dir string
+}
+
+// NewDiskResultStore creates dir if needed and stores results in it.
+func NewDiskResultStore(dir string) (*DiskResultStore, error) {
+	if dir == "" {
+		return nil, errors.New("result store directory is empty")
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create result store directory: %w", err)
+	}
+	return &DiskResultStore{dir: dir}, nil
+}
+
+// Put writes data to a temporary file and renames it into place.
+func (s *DiskResultStore) Put(key string, data []byte) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(s.dir, "."+key+".tmp")
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write result: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write result: %w", err)
+	}
+	return nil
+}
+
+// Get reads the file for key.
+func (s *DiskResultStore) Get(key string) ([]byte, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrResultNotFound, key)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read result: %w", err)
+	}
+	return data, nil
+}
+
+// Delete removes the file for key.
+func (s *DiskResultStore) Delete(key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// URL returns "": files are served by the server's result endpoint.
+func (s *DiskResultStore) URL(key string, lifetime time.Duration) (string, error) {
+	return "", nil
+}
+
+// sweep removes files last written before the given time.
+func (s *DiskResultStore) sweep(before time.Time) int {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return 0
+	}
+	removed := 0
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || !info.Mode().IsRegular() || !info.ModTime().Before(before) {
+			continue
+		}
+		if os.Remove(filepath.Join(s.dir, entry.Name())) == nil {
+			removed++
+		}
+	}
+	return removed
+}
+
+// path returns the file for key, rejecting keys that aren't plain names.
+func (s *DiskResultStore) path(key string) (string, error) {
+	if key == "" || key != filepath.Base(key) || strings.HasPrefix(key, ".") {
+		return "", fmt.Errorf("invalid result key %q", key)
+	}
+	return filepath.Join(s.dir, key), nil
+}
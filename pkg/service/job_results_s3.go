@@ -0,0 +1,341 @@
+package service
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// s3Timeout bounds each request to the object store.
+	s3Timeout = 30 * time.Second
+	// s3DefaultRegion is used when neither the URL nor AWS_REGION sets one.
+	s3DefaultRegion = "us-east-1"
+)
+
+// S3ResultStore keeps offloaded results in an S3-compatible bucket (AWS S3,
+// MinIO, Ceph RGW, ...). Requests are signed with AWS Signature Version 4,
+// and result URLs are presigned GET URLs, so clients fetch large results
+// from the bucket directly and every replica can read them.
+type S3ResultStore struct {
+	endpoint  *url.URL // Scheme and host requests go to
+	bucket    string
+	prefix    string // Prepended to keys, e.g. "iskoces/results/"
+	region    string
+	pathStyle bool // Bucket in the path rather than the host name
+
+	accessKey    string
+	secretKey    string
+	sessionToken string
+
+	client *http.Client
+}
+
+// NewS3ResultStore parses a URL of the form
+// s3://bucket[/prefix][?endpoint=URL&region=REGION]. Without an endpoint
+// the store uses AWS (https://<bucket>.s3.<region>.amazonaws.com); with one,
+// e.g. endpoint=http://minio:9000, it addresses the bucket by path.
+// Credentials come from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and
+// optionally AWS_SESSION_TOKEN; the region from the URL, AWS_REGION, or
+// us-east-1.
+func NewS3ResultStore(location string) (*S3ResultStore, error) {
+	u, err := url.Parse(location)
+	if err != nil {
+		return nil, fmt.Errorf("invalid S3 URL: %w", err)
+	}
+	if u.Host == "" {
+		return nil, errors.New("S3 URL has no bucket")
+	}
+	s := &S3ResultStore{
+		bucket:       u.Host,
+		prefix:       strings.TrimPrefix(u.Path, "/"),
+		region:       u.Query().Get("region"),
+		accessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		sessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		client:       &http.Client{Timeout: s3Timeout},
+	}
+	if s.prefix != "" && !strings.HasSuffix(s.prefix, "/") {
+		s.prefix += "/"
+	}
+	if s.region == "" {
+		s.region = os.Getenv("AWS_REGION")
+	}
+	if s.region == "" {
+		s.region = s3DefaultRegion
+	}
+	if s.accessKey == "" || s.secretKey == "" {
+		return nil, errors.New("S3 result store needs AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	}
+
+	if endpoint := u.Query().Get("endpoint"); endpoint != "" {
+		s.endpoint, err = url.Parse(endpoint)
+		if err != nil || (s.endpoint.Scheme != "http" && s.endpoint.Scheme != "https") || s.endpoint.Host == "" {
+			return nil, fmt.Errorf("invalid S3 endpoint %q (want http:// or https://host[:port])", endpoint)
+		}
+		s.pathStyle = true
+	} else {
+		s.endpoint = &url.URL{Scheme: "https", Host: fmt.Sprintf("%s.s3.%s.amazonaws.com", s.bucket, s.region)}
+	}
+	return s, nil
+}
+
+// Put uploads data as a markdown object, or JSON lines for job archives
+// (keys ending in .jsonl).
+func (s *S3ResultStore) Put(key string, data []byte) error {
+	req, err := http.NewRequest(http.MethodPut, s.objectURL(key).String(), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	if strings.HasSuffix(key, ".jsonl") {
+		req.Header.Set("Content-Type", "application/x-ndjson")
+	} else {
+		req.Header.Set("Content-Type", "text/markdown; charset=utf-8")
+	}
+	sum := sha256.Sum256(data)
+	s.sign(req, hex.EncodeToString(sum[:]), time.Now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to upload result: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return s3Error("upload result", resp)
+	}
+	return nil
+}
+
+// Get downloads an object.
+func (s *S3ResultStore) Get(key string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, s.objectURL(key).String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	s.sign(req, s3EmptyPayloadHash, time.Now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download result: %w", err)
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to download result: %w", err)
+		}
+		return data, nil
+	case http.StatusNotFound:
+		return nil, fmt.Errorf("%w: %s", ErrResultNotFound, key)
+	default:
+		return nil, s3Error("download result", resp)
+	}
+}
+
+// Delete removes an object.
+func (s *S3ResultStore) Delete(key string) error {
+	req, err := http.NewRequest(http.MethodDelete, s.objectURL(key).String(), nil)
+	if err != nil {
+		return err
+	}
+	s.sign(req, s3EmptyPayloadHash, time.Now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to delete result: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return s3Error("delete result", resp)
+	}
+	return nil
+}
+
+// URL returns a presigned GET URL for the object, valid for lifetime
+// (between one second and seven days).
+func (s *S3ResultStore) URL(key string, lifetime time.Duration) (string, error) {
+	return s.presign(key, lifetime, time.Now()), nil
+}
+
+// objectURL returns the URL of the object for key.
+func (s *S3ResultStore) objectURL(key string) *url.URL {
+	path := "/" + s.prefix + key
+	if s.pathStyle {
+		path = "/" + s.bucket + path
+	}
+	u := *s.endpoint
+	u.Path = path
+	u.RawPath = s3Escape(path, false)
+	return &u
+}
+
+// s3EmptyPayloadHash is the SHA-256 of an empty request body.
+const s3EmptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// sign adds AWS Signature Version 4 headers to req, signing its host, query
+// and every header already set on it but User-Agent.
+func (s *S3ResultStore) sign(req *http.Request, payloadHash string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if s.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.sessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		if lower == "authorization" || lower == "user-agent" {
+			continue
+		}
+		// Values are trimmed, inner runs of spaces collapsed, and repeated
+		// headers joined with commas
+		trimmed := make([]string, len(values))
+		for i, value := range values {
+			trimmed[i] = strings.Join(strings.Fields(value), " ")
+		}
+		headers[lower] = strings.Join(trimmed, ",")
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		s3CanonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope, signature := s.signature(amzDate, canonicalRequest)
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKey, scope, signedHeaders, signature))
+}
+
+// presign returns a presigned GET URL for key.
+func (s *S3ResultStore) presign(key string, lifetime time.Duration, now time.Time) string {
+	seconds := int(lifetime.Seconds())
+	seconds = max(1, min(seconds, int(resultURLMaxLifetime.Seconds())))
+	amzDate := now.UTC().Format("20060102T150405Z")
+	u := s.objectURL(key)
+
+	query := url.Values{
+		"X-Amz-Algorithm":     {"AWS4-HMAC-SHA256"},
+		"X-Amz-Credential":    {s.accessKey + "/" + s.scope(amzDate)},
+		"X-Amz-Date":          {amzDate},
+		"X-Amz-Expires":       {strconv.Itoa(seconds)},
+		"X-Amz-SignedHeaders": {"host"},
+	}
+	if s.sessionToken != "" {
+		query.Set("X-Amz-Security-Token", s.sessionToken)
+	}
+	canonicalQuery := s3CanonicalQuery(query)
+
+	canonicalRequest := strings.Join([]string{
+		http.MethodGet,
+		u.EscapedPath(),
+		canonicalQuery,
+		"host:" + u.Host + "\n",
+		"host",
+		"UNSIGNED-PAYLOAD",
+	}, "\n")
+	_, signature := s.signature(amzDate, canonicalRequest)
+	u.RawQuery = canonicalQuery + "&X-Amz-Signature=" + signature
+	return u.String()
+}
+
+// s3CanonicalQuery encodes query for signing: names and values
+// URI-encoded (a space as %20, '/' as %2F), sorted by name and then value.
+// A parameter without a value keeps its '='.
+func s3CanonicalQuery(query url.Values) string {
+	type param struct{ name, value string }
+	var params []param
+	for name, values := range query {
+		for _, value := range values {
+			params = append(params, param{s3Escape(name, true), s3Escape(value, true)})
+		}
+	}
+	sort.Slice(params, func(i, j int) bool {
+		if params[i].name != params[j].name {
+			return params[i].name < params[j].name
+		}
+		return params[i].value < params[j].value
+	})
+	encoded := make([]string, len(params))
+	for i, p := range params {
+		encoded[i] = p.name + "=" + p.value
+	}
+	return strings.Join(encoded, "&")
+}
+
+// scope returns the credential scope for a request made at amzDate.
+func (s *S3ResultStore) scope(amzDate string) string {
+	return amzDate[:8] + "/" + s.region + "/s3/aws4_request"
+}
+
+// signature signs a canonical request made at amzDate and returns the
+// credential scope and the hex signature.
+func (s *S3ResultStore) signature(amzDate, canonicalRequest string) (string, string) {
+	scope := s.scope(amzDate)
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := []byte("AWS4" + s.secretKey)
+	for _, part := range []string{amzDate[:8], s.region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	return scope, hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of data with key.
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// s3Escape URI-encodes s, leaving only unreserved characters (and, unless
+// encodeSlash is set, '/') as they are.
+func s3Escape(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// s3Error describes a failed request from the response's status and the
+// start of its (XML) body.
+func s3Error(action string, resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return fmt.Errorf("failed to %s: S3 returned %s: %s", action, resp.Status, strings.TrimSpace(string(body)))
+}
@@ -0,0 +1,182 @@
+package service
+
+import (
+	"sort"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultJobRetention keeps finished jobs for an hour, however many there
+// are, and applies this every 30 seconds.
+var DefaultJobRetention = JobRetention{
+	Completed: time.Hour,
+	Failed:    time.Hour,
+	Cancelled: time.Hour,
+	Interval:  30 * time.Second,
+}
+
+var (
+	jobsEvictedTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "iskoces_jobs_evicted_total",
+			Help: "Total number of finished translation jobs removed from the queue, by final status and reason (age, capacity)",
+		},
+		[]string{"status", "reason"},
+	)
+	jobsRetained = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "iskoces_jobs_retained",
+			Help: "Number of translation jobs the queue holds, finished or not, after the last retention pass",
+		},
+	)
+)
+
+// JobRetention controls how long finished jobs are kept for status and
+// result lookups. Dead-letter jobs follow SetDeadLetterRetention instead
+// and don't count towards MaxJobs.
+type JobRetention struct {
+	Completed time.Duration // Kept this long after completing (0 = no limit)
+	Failed    time.Duration // Kept this long after failing (0 = no limit)
+	Cancelled time.Duration // Kept this long after being cancelled (0 = no limit)
+	MaxJobs   int           // Most finished jobs kept; the oldest beyond this are removed (0 = no limit)
+	Interval  time.Duration // How often retention is applied (default 30s)
+}
+
+// maxAge returns how long jobs that finished with status are kept.
+func (r JobRetention) maxAge(status TranslationJobStatus) time.Duration {
+	switch status {
+	case JobStatusCompleted:
+		return r.Completed
+	case JobStatusFailed:
+		return r.Failed
+	default:
+		return r.Cancelled
+	}
+}
+
+// StartRetention applies policy every policy.Interval, removing finished
+// jobs and expiring results, until Shutdown. Calling it again replaces the
+// policy from the next pass on.
+func (q *JobQueue) StartRetention(policy JobRetention) {
+	if policy.Interval <= 0 {
+		policy.Interval = DefaultJobRetention.Interval
+	}
+	q.jobsMu.Lock()
+	q.retention = policy
+	q.jobsMu.Unlock()
+
+	q.retentionOnce.Do(func() {
+		go q.runRetention(policy.Interval)
+	})
+}
+
+// runRetention applies the retention policy every interval until the queue
+// shuts down.
+func (q *JobQueue) runRetention(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			q.jobsMu.RLock()
+			policy := q.retention
+			q.jobsMu.RUnlock()
+			q.applyRetention(policy, time.Now())
+			q.ExpireResults()
+		case <-q.retentionStop:
+			return
+		}
+	}
+}
+
+// CleanupOldJobs removes jobs that finished more than maxAge ago, except
+// dead-letter jobs still within their retention.
+func (q *JobQueue) CleanupOldJobs(maxAge time.Duration) {
+	q.applyRetention(JobRetention{Completed: maxAge, Failed: maxAge, Cancelled: maxAge}, time.Now())
+}
+
+// applyRetention removes the finished jobs policy no longer keeps: those
+// past their status's maximum age, then the oldest beyond MaxJobs. Batches
+// are forgotten once none of their jobs are kept.
+func (q *JobQueue) applyRetention(policy JobRetention, now time.Time) {
+	type finishedJob struct {
+		job         *TranslationJob
+		status      TranslationJobStatus
+		completedAt time.Time
+	}
+
+	q.jobsMu.Lock()
+
+	evicted := make(map[string]int) // By reason
+	var resultKeys []string
+	evict := func(f finishedJob, reason string) {
+		delete(q.jobs, f.job.ID)
+		if q.idempotency[f.job.idempotencyKey] == f.job {
+			delete(q.idempotency, f.job.idempotencyKey)
+		}
+		if q.byContent[f.job.contentHash] == f.job {
+			delete(q.byContent, f.job.contentHash)
+		}
+		f.job.mu.RLock()
+		if f.job.resultKey != "" {
+			resultKeys = append(resultKeys, f.job.resultKey)
+		}
+		f.job.mu.RUnlock()
+		jobsEvictedTotal.WithLabelValues(string(f.status), reason).Inc()
+		evicted[reason]++
+	}
+
+	var kept []finishedJob
+	for _, job := range q.jobs {
+		job.mu.RLock()
+		f := finishedJob{job: job, status: job.Status}
+		if job.CompletedAt != nil {
+			f.completedAt = *job.CompletedAt
+		}
+		deadLetter := job.DeadLetter
+		job.mu.RUnlock()
+		if !f.status.IsTerminal() || f.completedAt.IsZero() {
+			continue
+		}
+
+		if deadLetter {
+			if !q.keepDeadLetter(job, now) {
+				evict(f, "age")
+			}
+			continue
+		}
+		if maxAge := policy.maxAge(f.status); maxAge > 0 && now.Sub(f.completedAt) > maxAge {
+			evict(f, "age")
+			continue
+		}
+		kept = append(kept, f)
+	}
+
+	if policy.MaxJobs > 0 && len(kept) > policy.MaxJobs {
+		sort.Slice(kept, func(i, j int) bool {
+			return kept[i].completedAt.Before(kept[j].completedAt)
+		})
+		for _, f := range kept[:len(kept)-policy.MaxJobs] {
+			evict(f, "capacity")
+		}
+	}
+
+	if len(evicted) > 0 {
+		q.compactStore()
+		q.logger.WithFields(logrus.Fields{
+			"removed_age":      evicted["age"],
+			"removed_capacity": evicted["capacity"],
+			"remaining":        len(q.jobs),
+		}).Info("Cleaned up old translation jobs")
+	}
+	q.dropFinishedBatches()
+	jobsRetained.Set(float64(len(q.jobs)))
+	setJobsByStatus(q.jobs)
+	q.jobsMu.Unlock()
+
+	q.results.remove(resultKeys...)
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"math/rand/v2"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/dasmlab/iskoces/pkg/translate"
+)
+
+// retryJitter spreads retry delays by up to this fraction either way, so
+// jobs that failed together don't all retry at the same moment.
+const retryJitter = 0.2
+
+// DefaultJobRetryPolicy retries a job twice on transient engine errors,
+// after about 2s and 4s.
+var DefaultJobRetryPolicy = JobRetryPolicy{
+	MaxAttempts: 3,
+	Backoff:     2 * time.Second,
+	MaxBackoff:  time.Minute,
+}
+
+var jobRetriesTotal = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "iskoces_job_retries_total",
+		Help: "Total number of failed translation job attempts that were retried, by error class",
+	},
+	[]string{"class"},
+)
+
+// JobRetryPolicy controls automatic retries of failed jobs. Only transient
+// engine errors (unavailable, overloaded, timeout) are retried; a job that
+// fails for any other reason, or runs out of attempts, fails. Retries count
+// against the job's overall processing time.
+type JobRetryPolicy struct {
+	MaxAttempts int           // Attempts per job, including the first (1 or less: no retries)
+	Backoff     time.Duration // Delay before the first retry; it doubles with each retry
+	MaxBackoff  time.Duration // Longest delay between attempts (0 = no limit)
+}
+
+// retryDelay returns how long to wait before retrying a job whose attempt
+// (1-based) failed with class, and false if it shouldn't be retried.
+func (p JobRetryPolicy) retryDelay(attempt int, class translate.ErrorClass) (time.Duration, bool) {
+	if attempt >= p.MaxAttempts || !class.Retryable() {
+		return 0, false
+	}
+	delay := p.Backoff
+	for i := 1; i < attempt && (p.MaxBackoff <= 0 || delay < p.MaxBackoff); i++ {
+		delay *= 2
+	}
+	if p.MaxBackoff > 0 {
+		delay = min(delay, p.MaxBackoff)
+	}
+	jitter := 1 + retryJitter*(2*rand.Float64()-1)
+	return time.Duration(float64(delay) * jitter), true
+}
+
+// JobAttempt records a failed attempt at a job.
+type JobAttempt struct {
+	Attempt    int                  `json:"attempt"` // 1-based
+	StartedAt  time.Time            `json:"started_at"`
+	EndedAt    time.Time            `json:"ended_at"`
+	Error      string               `json:"error"`
+	ErrorClass translate.ErrorClass `json:"error_class,omitempty"`
+	RetryDelay time.Duration        `json:"retry_delay_ns,omitempty"` // Wait before the next attempt (0 if none)
+}
+
+// startAttempt marks the start of an attempt (1-based) at the job.
+func (j *TranslationJob) startAttempt(attempt int) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.Attempt = attempt
+}
+
+// recordAttempt adds a failed attempt to the job's history.
+func (j *TranslationJob) recordAttempt(attempt JobAttempt) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.Attempts = append(j.Attempts, attempt)
+}
+
+// AttemptHistory returns the job's current (or final) attempt and a copy of
+// its failed attempts (thread-safe).
+func (j *TranslationJob) AttemptHistory() (int, []JobAttempt) {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	return j.Attempt, append([]JobAttempt(nil), j.Attempts...)
+}
+
+// SetRetryPolicy sets how failed jobs are retried (default:
+// DefaultJobRetryPolicy). It must be called before jobs are submitted.
+func (p *JobProcessor) SetRetryPolicy(policy JobRetryPolicy) {
+	p.retryPolicy = policy
+}
@@ -0,0 +1,389 @@
+package service
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/dasmlab/iskoces/pkg/translate"
+)
+
+// DefaultMaxConcurrentJobs is how many async jobs run at once. Each job
+// translates up to DefaultChunkParallelism chunks at a time, so this keeps
+// the default worker pool busy without piling every job onto it.
+const DefaultMaxConcurrentJobs = 8
+
+// DefaultPriorityAging is how long a job waits to gain one priority level,
+// so bulk jobs aren't starved by a steady stream of interactive ones: a low
+// priority job that has waited 5 minutes competes as normal, and after 10
+// minutes as high.
+const DefaultPriorityAging = 5 * time.Minute
+
+var (
+	jobsWaiting = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "iskoces_jobs_waiting",
+		Help: "Async translation jobs waiting for the scheduler to start them",
+	})
+	jobsWaitingByPriority = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "iskoces_jobs_waiting_by_priority",
+		Help: "Async translation jobs waiting for the scheduler to start them, by the priority they were submitted with",
+	}, []string{"priority"})
+	jobsAgedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "iskoces_jobs_aged_total",
+		Help: "Total number of async jobs started ahead of their submitted priority because they waited long, by submitted priority",
+	}, []string{"priority"})
+	jobsRunning = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "iskoces_jobs_running",
+		Help: "Async translation jobs being processed",
+	})
+	jobsDelayed = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "iskoces_jobs_delayed",
+		Help: "Async translation jobs waiting for their not_before time",
+	})
+)
+
+// JobOrder is the order in which waiting jobs start.
+type JobOrder string
+
+const (
+	// JobOrderPriority starts higher-priority jobs first, then the oldest.
+	JobOrderPriority JobOrder = "priority"
+	// JobOrderFIFO starts jobs in the order they were submitted.
+	JobOrderFIFO JobOrder = "fifo"
+	// JobOrderFair starts higher-priority jobs first, taking turns between
+	// namespaces (weighted round-robin), then the oldest of each namespace.
+	JobOrderFair JobOrder = "fair"
+)
+
+// jobScheduler runs async jobs with bounded concurrency.
+type jobScheduler struct {
+	mu      sync.Mutex
+	limit   int // 0 = unlimited
+	order   JobOrder
+	aging   time.Duration // Wait per priority level gained (0 = no aging; not in fifo order)
+	running int
+	waiting []*TranslationJob               // In priority (or fifo) order, as of the last reorder
+	delayed map[*TranslationJob]*time.Timer // Jobs waiting for their NotBefore time
+	held    QueuePause                      // Jobs it holds are paused instead of starting
+	stopped bool                            // Set on shutdown; waiting jobs no longer start
+	run     func(*TranslationJob)
+
+	weights   map[string]int // Fair order: namespace weights (default 1)
+	credits   map[string]int // Fair order: round-robin credit per namespace, reset when no jobs wait
+	nsLimit   int            // Jobs one namespace may run at once (0 = unlimited)
+	nsRunning map[string]int // Running jobs by namespace
+}
+
+// newJobScheduler creates a scheduler that processes jobs with run.
+func newJobScheduler(limit int, run func(*TranslationJob)) *jobScheduler {
+	return &jobScheduler{
+		limit:     limit,
+		order:     JobOrderFair,
+		aging:     DefaultPriorityAging,
+		delayed:   make(map[*TranslationJob]*time.Timer),
+		run:       run,
+		credits:   make(map[string]int),
+		nsRunning: make(map[string]int),
+	}
+}
+
+// configure changes how many jobs run at once (0 = unlimited) and the
+// order waiting jobs start in. Jobs already waiting keep their places.
+func (s *jobScheduler) configure(limit int, order JobOrder) {
+	s.mu.Lock()
+	s.limit = max(0, limit)
+	s.order = order
+	s.mu.Unlock()
+	s.dispatch()
+}
+
+// configureAging changes how long a waiting job takes to gain a priority
+// level (0 disables aging).
+func (s *jobScheduler) configureAging(aging time.Duration) {
+	s.mu.Lock()
+	s.aging = max(0, aging)
+	s.mu.Unlock()
+	s.dispatch()
+}
+
+// submit queues a job to run, once its NotBefore time (fixed at creation)
+// has come.
+func (s *jobScheduler) submit(job *TranslationJob) {
+	job.mu.Lock()
+	job.scheduler = s
+	job.mu.Unlock()
+
+	if job.NotBefore != nil {
+		if wait := time.Until(*job.NotBefore); wait > 0 {
+			s.mu.Lock()
+			s.delayed[job] = time.AfterFunc(wait, func() { s.release(job) })
+			jobsDelayed.Inc()
+			s.mu.Unlock()
+			return
+		}
+	}
+	s.enqueue(job)
+}
+
+// release queues a delayed job whose time has come, unless it was removed.
+func (s *jobScheduler) release(job *TranslationJob) {
+	s.mu.Lock()
+	_, delayed := s.delayed[job]
+	delete(s.delayed, job)
+	s.mu.Unlock()
+	if delayed {
+		jobsDelayed.Dec()
+		s.enqueue(job)
+	}
+}
+
+// enqueue adds a job to the waiting jobs, or pauses it if the queue is
+// paused for its priority.
+func (s *jobScheduler) enqueue(job *TranslationJob) {
+	s.mu.Lock()
+	if s.held.holds(job.Priority) {
+		job.park(PausedByQueue, queuePauseMessage(s.held.Reason))
+		s.mu.Unlock()
+		return
+	}
+	job.queuedAt = time.Now()
+	i := len(s.waiting)
+	if s.order != JobOrderFIFO {
+		// After the waiting jobs of the same or higher priority. Priority
+		// is fixed at creation, so it can be read without job.mu.
+		i = sort.Search(len(s.waiting), func(i int) bool {
+			return s.waiting[i].Priority < job.Priority
+		})
+	}
+	s.waiting = append(s.waiting, nil)
+	copy(s.waiting[i+1:], s.waiting[i:])
+	s.waiting[i] = job
+	trackWaiting(job, 1)
+	s.mu.Unlock()
+	s.dispatch()
+}
+
+// remove drops a job that hasn't started, e.g. because it was cancelled,
+// and reports whether it was waiting (or delayed).
+func (s *jobScheduler) remove(job *TranslationJob) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if timer, delayed := s.delayed[job]; delayed {
+		timer.Stop()
+		delete(s.delayed, job)
+		jobsDelayed.Dec()
+		return true
+	}
+	for i, waiting := range s.waiting {
+		if waiting == job {
+			s.waiting = append(s.waiting[:i], s.waiting[i+1:]...)
+			trackWaiting(job, -1)
+			return true
+		}
+	}
+	return false
+}
+
+// pause replaces the queue's pause (the zero QueuePause lifts it) and
+// pauses the waiting jobs it holds, returning how many. Delayed jobs are
+// paused once their time comes. Jobs are paused under s.mu so a pause
+// lifted meanwhile can't miss them.
+func (s *jobScheduler) pause(p QueuePause) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.held = p
+	if !p.Paused {
+		return 0
+	}
+	held := 0
+	waiting := s.waiting[:0]
+	for _, job := range s.waiting {
+		if !p.holds(job.Priority) {
+			waiting = append(waiting, job)
+			continue
+		}
+		job.park(PausedByQueue, queuePauseMessage(p.Reason))
+		trackWaiting(job, -1)
+		held++
+	}
+	clear(s.waiting[len(waiting):])
+	s.waiting = waiting
+	return held
+}
+
+// paused returns the queue's current pause.
+func (s *jobScheduler) paused() QueuePause {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.held
+}
+
+// hold pauses a running job if the queue is paused for its priority, and
+// reports whether it did.
+func (s *jobScheduler) hold(job *TranslationJob) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.held.holds(job.Priority) {
+		return false
+	}
+	job.park(PausedByQueue, queuePauseMessage(s.held.Reason))
+	return true
+}
+
+// effectivePriority returns the priority a waiting job competes with at
+// now: its own, raised a level for every aging interval it has waited, up to
+// PriorityHigh. Callers hold s.mu.
+func (s *jobScheduler) effectivePriority(job *TranslationJob, now time.Time) translate.Priority {
+	if s.order == JobOrderFIFO || s.aging <= 0 || job.Priority >= translate.PriorityHigh {
+		return job.Priority
+	}
+	levels := translate.Priority(now.Sub(job.queuedAt) / s.aging)
+	return min(job.Priority+levels, translate.PriorityHigh)
+}
+
+// reorder sorts the waiting jobs by effective priority, then by how long
+// they have waited, as their priority may have aged since they were queued.
+// Callers hold s.mu.
+func (s *jobScheduler) reorder(now time.Time) {
+	if s.order == JobOrderFIFO || s.aging <= 0 {
+		return
+	}
+	sort.SliceStable(s.waiting, func(a, b int) bool {
+		pa, pb := s.effectivePriority(s.waiting[a], now), s.effectivePriority(s.waiting[b], now)
+		if pa != pb {
+			return pa > pb
+		}
+		return s.waiting[a].queuedAt.Before(s.waiting[b].queuedAt)
+	})
+}
+
+// trackWaiting adds delta to the waiting job gauges for job's priority.
+func trackWaiting(job *TranslationJob, delta float64) {
+	jobsWaiting.Add(delta)
+	jobsWaitingByPriority.WithLabelValues(job.Priority.String()).Add(delta)
+}
+
+// position returns the job's 1-based place among the waiting jobs, or 0 if
+// it isn't waiting (or is delayed). In fair order it is the place the
+// round-robin would start it in, ignoring namespace concurrency limits.
+func (s *jobScheduler) position(job *TranslationJob) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	s.reorder(now)
+	for i, waiting := range s.waiting {
+		if waiting != job {
+			continue
+		}
+		if s.order != JobOrderFair {
+			return i + 1
+		}
+		return s.fairPosition(job, now)
+	}
+	return 0
+}
+
+// next returns the index of the waiting job to start next, or -1 if every
+// waiting job's namespace is at its concurrency limit. Callers hold s.mu.
+func (s *jobScheduler) next(now time.Time) int {
+	if s.order == JobOrderFair {
+		return s.pickFair(s.waiting, s.credits, s.nsRunning, now)
+	}
+	for i, job := range s.waiting {
+		if s.canRun(job, s.nsRunning) {
+			return i
+		}
+	}
+	return -1
+}
+
+// stop stops starting jobs, for a shutdown. Waiting jobs stay queued.
+func (s *jobScheduler) stop() {
+	s.mu.Lock()
+	s.stopped = true
+	s.mu.Unlock()
+}
+
+// wait waits until no job is running, or ctx is done, and reports whether
+// every job finished.
+func (s *jobScheduler) wait(ctx context.Context) bool {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		s.mu.Lock()
+		running := s.running
+		s.mu.Unlock()
+		if running == 0 {
+			return true
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+	}
+}
+
+// dispatch starts waiting jobs while there is room.
+func (s *jobScheduler) dispatch() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopped {
+		return
+	}
+	now := time.Now()
+	if len(s.waiting) > 0 && (s.limit == 0 || s.running < s.limit) {
+		s.reorder(now)
+	}
+	for len(s.waiting) > 0 && (s.limit == 0 || s.running < s.limit) {
+		i := s.next(now)
+		if i < 0 {
+			break
+		}
+		job := s.waiting[i]
+		s.waiting = append(s.waiting[:i], s.waiting[i+1:]...)
+		s.running++
+		s.nsRunning[job.Namespace]++
+		trackWaiting(job, -1)
+		jobsRunning.Inc()
+		if s.effectivePriority(job, now) > job.Priority {
+			jobsAgedTotal.WithLabelValues(job.Priority.String()).Inc()
+		}
+		go func() {
+			defer s.finished(job)
+			s.run(job)
+		}()
+	}
+	if len(s.waiting) == 0 {
+		clear(s.credits)
+	}
+}
+
+// finished frees a running job's slot and starts the next job.
+func (s *jobScheduler) finished(job *TranslationJob) {
+	s.mu.Lock()
+	s.running--
+	if s.nsRunning[job.Namespace]--; s.nsRunning[job.Namespace] <= 0 {
+		delete(s.nsRunning, job.Namespace)
+	}
+	jobsRunning.Dec()
+	s.mu.Unlock()
+	s.dispatch()
+}
+
+// QueuePosition returns the job's 1-based place among the jobs waiting to
+// start, or 0 if it isn't waiting (delayed, running, finished, or queued on
+// a shared store).
+func (j *TranslationJob) QueuePosition() int {
+	j.mu.RLock()
+	scheduler := j.scheduler
+	j.mu.RUnlock()
+	if scheduler == nil {
+		return 0
+	}
+	return scheduler.position(j)
+}
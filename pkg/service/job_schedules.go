@@ -0,0 +1,442 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	nanabushv2 "github.com/dasmlab/iskoces/pkg/proto/v2"
+)
+
+// Outcomes of a schedule's run.
+const (
+	ScheduleOutcomeSubmitted = "submitted"
+	ScheduleOutcomeUnchanged = "skipped: content unchanged"
+	ScheduleOutcomeRunning   = "skipped: previous run still in progress"
+	ScheduleOutcomeDraining  = "skipped: server draining"
+)
+
+// scheduleIdleWait is how long the schedule loop sleeps when no run is
+// due sooner, so it notices wall clock changes.
+const scheduleIdleWait = time.Hour
+
+var scheduleNamePattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`)
+
+// ErrScheduleNotFound is returned for an unknown schedule name.
+var ErrScheduleNotFound = errors.New("schedule not found")
+
+// ScheduleFieldError reports an invalid schedule field.
+type ScheduleFieldError struct {
+	Field       string
+	Description string
+}
+
+func (e *ScheduleFieldError) Error() string {
+	return e.Field + " " + e.Description
+}
+
+// JobSchedule submits a translation request as a job on a recurring cron
+// schedule.
+type JobSchedule struct {
+	Name     string
+	Cron     string // Five-field cron expression or @ macro
+	TimeZone string // IANA name the cron fields are in; empty means UTC
+	Request  *nanabushv2.TranslationRequest
+	Always   bool // Run even if the content hasn't changed since the last successful run
+
+	NextRun     time.Time // Zero if the expression never matches again
+	LastRun     *time.Time
+	LastJobID   string
+	LastOutcome string
+	// TranslatedHash identifies the content of the last successful run.
+	TranslatedHash string
+
+	cron *cronSchedule
+	loc  *time.Location
+}
+
+// ToProto converts the schedule to its v2 wire form.
+func (s JobSchedule) ToProto() *nanabushv2.Schedule {
+	out := &nanabushv2.Schedule{
+		Name:        s.Name,
+		Cron:        s.Cron,
+		TimeZone:    s.TimeZone,
+		Request:     s.Request,
+		Always:      s.Always,
+		LastRunTime: timestampOrNil(s.LastRun),
+		LastJobId:   s.LastJobID,
+		LastOutcome: s.LastOutcome,
+	}
+	if !s.NextRun.IsZero() {
+		out.NextRunTime = timestamppb.New(s.NextRun)
+	}
+	return out
+}
+
+// contentHash identifies what a run of the schedule would translate.
+func (s *JobSchedule) contentHash() string {
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(s.Request)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// scheduleRecord is a schedule in the schedules file.
+type scheduleRecord struct {
+	Name           string          `json:"name"`
+	Cron           string          `json:"cron"`
+	TimeZone       string          `json:"time_zone,omitempty"`
+	Request        json.RawMessage `json:"request"` // TranslationRequest in protobuf JSON
+	Always         bool            `json:"always,omitempty"`
+	NextRun        time.Time       `json:"next_run"`
+	LastRun        *time.Time      `json:"last_run,omitempty"`
+	LastJobID      string          `json:"last_job_id,omitempty"`
+	LastOutcome    string          `json:"last_outcome,omitempty"`
+	TranslatedHash string          `json:"translated_hash,omitempty"`
+}
+
+// ScheduleManager runs recurring translation schedules: a goroutine submits
+// each schedule's request to the job queue when its cron expression next
+// matches. Schedules are kept in memory unless a schedules file is loaded.
+type ScheduleManager struct {
+	mu        sync.Mutex
+	schedules map[string]*JobSchedule
+	queue     *JobQueue
+	path      string // Schedules file ("" = memory only)
+	logger    *logrus.Logger
+	paused    func() bool // Runs are skipped while it returns true (e.g. draining)
+
+	wake     chan struct{}
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewScheduleManager creates a manager submitting to queue and starts its
+// loop.
+func NewScheduleManager(queue *JobQueue, logger *logrus.Logger) *ScheduleManager {
+	m := &ScheduleManager{
+		schedules: make(map[string]*JobSchedule),
+		queue:     queue,
+		logger:    logger,
+		wake:      make(chan struct{}, 1),
+		stop:      make(chan struct{}),
+	}
+	go m.run()
+	return m
+}
+
+// Load makes the manager keep its schedules in the JSON file at path,
+// reading the schedules already there (none if it doesn't exist). Runs
+// missed while the server was down run once, straight away.
+func (m *ScheduleManager) Load(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return 0, fmt.Errorf("failed to read schedules file: %w", err)
+	}
+	var file struct {
+		Schedules []scheduleRecord `json:"schedules"`
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &file); err != nil {
+			return 0, fmt.Errorf("failed to parse schedules file %s: %w", path, err)
+		}
+	}
+
+	schedules := make(map[string]*JobSchedule, len(file.Schedules))
+	for _, rec := range file.Schedules {
+		s := &JobSchedule{
+			Name:           rec.Name,
+			Cron:           rec.Cron,
+			TimeZone:       rec.TimeZone,
+			Request:        &nanabushv2.TranslationRequest{},
+			Always:         rec.Always,
+			NextRun:        rec.NextRun,
+			LastRun:        rec.LastRun,
+			LastJobID:      rec.LastJobID,
+			LastOutcome:    rec.LastOutcome,
+			TranslatedHash: rec.TranslatedHash,
+		}
+		if err := protojson.Unmarshal(rec.Request, s.Request); err != nil {
+			return 0, fmt.Errorf("schedules file %s: schedule %q: bad request: %w", path, rec.Name, err)
+		}
+		if err := s.compile(); err != nil {
+			return 0, fmt.Errorf("schedules file %s: schedule %q: %w", path, rec.Name, err)
+		}
+		schedules[s.Name] = s
+	}
+
+	m.mu.Lock()
+	m.path = path
+	for name, s := range schedules {
+		m.schedules[name] = s
+	}
+	m.mu.Unlock()
+	m.poke()
+	return len(schedules), nil
+}
+
+// compile parses the schedule's name, cron expression and time zone.
+func (s *JobSchedule) compile() error {
+	if !scheduleNamePattern.MatchString(s.Name) {
+		return &ScheduleFieldError{"name", "must be 1-128 letters, digits, '.', '_' or '-'"}
+	}
+	cron, err := parseCron(s.Cron)
+	if err != nil {
+		return &ScheduleFieldError{"cron", err.Error()}
+	}
+	loc := time.UTC
+	if s.TimeZone != "" {
+		if loc, err = time.LoadLocation(s.TimeZone); err != nil {
+			return &ScheduleFieldError{"time_zone", fmt.Sprintf("%q is not a known time zone", s.TimeZone)}
+		}
+	}
+	s.cron, s.loc = cron, loc
+	return nil
+}
+
+// Put creates or replaces a schedule, which next runs when its cron
+// expression next matches. A replaced schedule keeps its run history, so
+// re-putting unchanged content doesn't cause a run. The request must
+// already be valid.
+func (m *ScheduleManager) Put(schedule JobSchedule) (JobSchedule, error) {
+	s := &JobSchedule{
+		Name:     schedule.Name,
+		Cron:     schedule.Cron,
+		TimeZone: schedule.TimeZone,
+		Request:  proto.Clone(schedule.Request).(*nanabushv2.TranslationRequest),
+		Always:   schedule.Always,
+	}
+	if err := s.compile(); err != nil {
+		return JobSchedule{}, err
+	}
+	s.NextRun = s.cron.next(time.Now().In(s.loc))
+
+	m.mu.Lock()
+	if existing := m.schedules[s.Name]; existing != nil {
+		s.LastRun = existing.LastRun
+		s.LastJobID = existing.LastJobID
+		s.LastOutcome = existing.LastOutcome
+		s.TranslatedHash = existing.TranslatedHash
+	}
+	m.schedules[s.Name] = s
+	m.save()
+	out := *s
+	m.mu.Unlock()
+
+	m.logger.WithFields(logrus.Fields{
+		"schedule": s.Name,
+		"cron":     s.Cron,
+		"next_run": s.NextRun,
+	}).Info("Saved translation schedule")
+	m.poke()
+	return out, nil
+}
+
+// Get returns a schedule by name.
+func (m *ScheduleManager) Get(name string) (JobSchedule, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := m.schedules[name]
+	if s == nil {
+		return JobSchedule{}, fmt.Errorf("%w: %s", ErrScheduleNotFound, name)
+	}
+	return *s, nil
+}
+
+// List returns all schedules, by name.
+func (m *ScheduleManager) List() []JobSchedule {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]JobSchedule, 0, len(m.schedules))
+	for _, s := range m.schedules {
+		out = append(out, *s)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// Delete removes a schedule.
+func (m *ScheduleManager) Delete(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.schedules[name] == nil {
+		return fmt.Errorf("%w: %s", ErrScheduleNotFound, name)
+	}
+	delete(m.schedules, name)
+	m.save()
+	m.logger.WithField("schedule", name).Info("Deleted translation schedule")
+	return nil
+}
+
+// Stop stops running schedules.
+func (m *ScheduleManager) Stop() {
+	m.stopOnce.Do(func() { close(m.stop) })
+}
+
+// poke makes the loop look at the schedules again.
+func (m *ScheduleManager) poke() {
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run fires schedules as they come due until the manager is stopped.
+func (m *ScheduleManager) run() {
+	timer := time.NewTimer(scheduleIdleWait)
+	defer timer.Stop()
+	for {
+		m.mu.Lock()
+		now := time.Now()
+		next := now.Add(scheduleIdleWait)
+		changed := false
+		for _, s := range m.schedules {
+			if s.NextRun.IsZero() {
+				continue
+			}
+			if !s.NextRun.After(now) {
+				m.fire(s, now)
+				s.NextRun = s.cron.next(now.In(s.loc))
+				changed = true
+				if s.NextRun.IsZero() {
+					continue
+				}
+			}
+			if s.NextRun.Before(next) {
+				next = s.NextRun
+			}
+		}
+		if changed {
+			m.save()
+		}
+		m.mu.Unlock()
+
+		timer.Reset(time.Until(next))
+		select {
+		case <-timer.C:
+		case <-m.wake:
+		case <-m.stop:
+			return
+		}
+	}
+}
+
+// fire runs a schedule: it submits the request as a job unless the
+// previous run's job is still going or, for schedules that aren't Always,
+// the content was already translated. Callers hold m.mu.
+func (m *ScheduleManager) fire(s *JobSchedule, now time.Time) {
+	s.LastRun = &now
+	logger := m.logger.WithField("schedule", s.Name)
+
+	if m.paused != nil && m.paused() {
+		s.LastOutcome = ScheduleOutcomeDraining
+		logger.Info("Skipping scheduled translation: server draining")
+		return
+	}
+	if s.LastJobID != "" {
+		if job, err := m.queue.GetJob(s.LastJobID); err == nil {
+			if jobStatus, _, _ := job.GetStatus(); !jobStatus.IsTerminal() {
+				s.LastOutcome = ScheduleOutcomeRunning
+				logger.WithField("job_id", s.LastJobID).Info("Skipping scheduled translation: previous run still in progress")
+				return
+			}
+		}
+	}
+	hash := s.contentHash()
+	if !s.Always && hash != "" && hash == s.TranslatedHash {
+		s.LastOutcome = ScheduleOutcomeUnchanged
+		logger.Debug("Skipping scheduled translation: content unchanged")
+		return
+	}
+
+	req := requestToV1(s.Request)
+	if s.Request.RequestId == "" {
+		req.JobId = s.Name
+	}
+	// Each run is a new job, even when the content is the same
+	req.IdempotencyKey = fmt.Sprintf("schedule/%s/%d", s.Name, now.Unix())
+	jobID, err := m.queue.CreateJob(context.Background(), req, "")
+	if err != nil {
+		s.LastOutcome = fmt.Sprintf("failed to submit: %v", err)
+		logger.WithError(err).Warn("Failed to submit scheduled translation")
+		return
+	}
+	job, err := m.queue.GetJob(jobID)
+	if err != nil {
+		s.LastOutcome = fmt.Sprintf("failed to submit: %v", err)
+		return
+	}
+	s.LastJobID = jobID
+	s.LastOutcome = ScheduleOutcomeSubmitted
+	logger.WithField("job_id", jobID).Info("Submitted scheduled translation")
+
+	go func() {
+		<-job.Done()
+		if jobStatus, _, _ := job.GetStatus(); jobStatus != JobStatusCompleted {
+			return
+		}
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if current := m.schedules[s.Name]; current != nil && current.LastJobID == jobID {
+			current.TranslatedHash = hash
+			m.save()
+		}
+	}()
+}
+
+// save writes the schedules file, if there is one. Callers hold m.mu.
+func (m *ScheduleManager) save() {
+	if m.path == "" {
+		return
+	}
+	var file struct {
+		Schedules []scheduleRecord `json:"schedules"`
+	}
+	for _, s := range m.schedules {
+		request, err := protojson.Marshal(s.Request)
+		if err != nil {
+			m.logger.WithError(err).WithField("schedule", s.Name).Error("Failed to encode schedule")
+			continue
+		}
+		file.Schedules = append(file.Schedules, scheduleRecord{
+			Name:           s.Name,
+			Cron:           s.Cron,
+			TimeZone:       s.TimeZone,
+			Request:        request,
+			Always:         s.Always,
+			NextRun:        s.NextRun,
+			LastRun:        s.LastRun,
+			LastJobID:      s.LastJobID,
+			LastOutcome:    s.LastOutcome,
+			TranslatedHash: s.TranslatedHash,
+		})
+	}
+	sort.Slice(file.Schedules, func(i, j int) bool { return file.Schedules[i].Name < file.Schedules[j].Name })
+
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err == nil {
+		tmp := filepath.Join(filepath.Dir(m.path), "."+filepath.Base(m.path)+".tmp")
+		if err = os.WriteFile(tmp, data, 0o600); err == nil {
+			err = os.Rename(tmp, m.path)
+		}
+	}
+	if err != nil {
+		m.logger.WithError(err).WithField("path", m.path).Error("Failed to write schedules file")
+	}
+}
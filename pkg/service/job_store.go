@@ -0,0 +1,543 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	nanabushv1 "github.com/dasmlab/iskoces/pkg/proto/v1"
+	"github.com/dasmlab/iskoces/pkg/translate"
+)
+
+// jobRecord is one line of the job store file (JSON lines): a job's full
+// state after a change. The last line for a job ID wins.
+type jobRecord struct {
+	ID                 string                   `json:"id"`
+	RequestID          string                   `json:"request_id,omitempty"`
+	Namespace          string                   `json:"namespace,omitempty"`
+	ClientID           string                   `json:"client_id,omitempty"`
+	BatchID            string                   `json:"batch_id,omitempty"`
+	CallbackURL        string                   `json:"callback_url,omitempty"`
+	Status             TranslationJobStatus     `json:"status"`
+	CreatedAt          time.Time                `json:"created_at"`
+	StartedAt          *time.Time               `json:"started_at,omitempty"`
+	CompletedAt        *time.Time               `json:"completed_at,omitempty"`
+	Error              string                   `json:"error,omitempty"`
+	ErrorClass         translate.ErrorClass     `json:"error_class,omitempty"`
+	Primitive          nanabushv1.PrimitiveType `json:"primitive"`
+	Title              string                   `json:"title,omitempty"`
+	Document           json.RawMessage          `json:"document,omitempty"` // DocumentContent in protobuf JSON
+	SourceLang         string                   `json:"source_lang"`
+	TargetLang         string                   `json:"target_lang"`
+	LocalizeFormats    bool                     `json:"localize_formats,omitempty"`
+	Priority           translate.Priority       `json:"priority"`
+	NotBefore          *time.Time               `json:"not_before,omitempty"`
+	ProgressMessage    string                   `json:"progress_message,omitempty"`
+	IdempotencyKey     string                   `json:"idempotency_key,omitempty"`
+	Fingerprint        string                   `json:"fingerprint,omitempty"`
+	TranslatedTitle    string                   `json:"translated_title,omitempty"`
+	TranslatedMarkdown string                   `json:"translated_markdown,omitempty"`
+	TokensUsed         int64                    `json:"tokens_used,omitempty"`
+	InferenceTime      float64                  `json:"inference_time,omitempty"`
+	ResultKey          string                   `json:"result_key,omitempty"` // Offloaded result in the result store
+	ResultExpiresAt    *time.Time               `json:"result_expires_at,omitempty"`
+	ProgressPercent    int32                    `json:"progress_percent,omitempty"`
+	Attempt            int                      `json:"attempt,omitempty"`
+	Attempts           []JobAttempt             `json:"attempts,omitempty"`
+	DeadLetter         bool                     `json:"dead_letter,omitempty"`
+	RequeuedAs         string                   `json:"requeued_as,omitempty"`
+	DuplicateOf        string                   `json:"duplicate_of,omitempty"`
+	PausedBy           string                   `json:"paused_by,omitempty"`
+	Webhook            *WebhookDelivery         `json:"webhook,omitempty"`
+	Usage              JobUsage                 `json:"usage"`
+	Checkpoint         *JobCheckpoint           `json:"checkpoint,omitempty"`
+	Progress           []JobProgressEvent       `json:"progress,omitempty"`
+	Deleted            bool                     `json:"deleted,omitempty"` // The job was removed from the queue
+}
+
+// JobStore persists the job queue's jobs. A BoltJobStore or a FileJobStore
+// keeps one server's jobs across restarts; a SharedJobStore (RedisJobStore)
+// also lets several replicas work from one queue.
+type JobStore interface {
+	// load returns the jobs to restore when the queue starts using the store.
+	load() []jobRecord
+	// save records a job's state. Failures are logged, not returned.
+	save(rec jobRecord)
+	// remove forgets a job.
+	remove(jobID string)
+	// compact lets the store drop jobs the queue no longer holds; recs are
+	// the jobs it still holds.
+	compact(recs []jobRecord) error
+	// beginShutdown stops recording job failures, so jobs cut short by the
+	// shutdown run again after the restart.
+	beginShutdown()
+	// Close releases the store.
+	Close() error
+}
+
+// OpenJobStore opens the job store at location: bolt:// followed by a file
+// path for a BoltJobStore, a redis:// or rediss:// URL for a RedisJobStore
+// shared between replicas, otherwise a file path for a FileJobStore.
+func OpenJobStore(location string, logger *logrus.Logger) (JobStore, error) {
+	if path, ok := strings.CutPrefix(location, "bolt://"); ok {
+		if path == "" {
+			return nil, fmt.Errorf("bolt:// job store needs a file path")
+		}
+		return OpenBoltJobStore(path, logger)
+	}
+	if strings.HasPrefix(location, "redis://") || strings.HasPrefix(location, "rediss://") {
+		u, err := url.Parse(location)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Redis URL: %w", err)
+		}
+		return NewRedisJobStore(u, logger)
+	}
+	return OpenFileJobStore(location, logger)
+}
+
+// FileJobStore persists translation jobs to a JSON lines file so queued and
+// running jobs survive a restart. Every state change appends the job's full
+// state; the file is compacted to one line per job when opened and after
+// old jobs are cleaned up. Jobs that were running are queued again and
+// resume from their checkpoint. Appends aren't synced to disk, so a crash
+// can lose the latest changes; BoltJobStore syncs each one.
+type FileJobStore struct {
+	mu     sync.Mutex
+	path   string
+	file   *os.File
+	logger *logrus.Logger
+
+	loaded       []jobRecord // Jobs read when opened, until the queue takes them
+	shuttingDown atomic.Bool // Failures are no longer recorded
+}
+
+// OpenFileJobStore opens (or creates) the job store file at path and reads
+// the jobs it holds. A line that can't be parsed, such as one cut short by
+// a crash, is skipped.
+func OpenFileJobStore(path string, logger *logrus.Logger) (*FileJobStore, error) {
+	if logger == nil {
+		logger = logrus.New()
+	}
+	store := &FileJobStore{path: path, logger: logger}
+
+	latest := make(map[string]jobRecord)
+	if in, err := os.Open(path); err == nil {
+		scanner := bufio.NewScanner(in)
+		scanner.Buffer(make([]byte, 0, 64*1024), DefaultMaxDocumentBytes*4)
+		line := 0
+		for scanner.Scan() {
+			line++
+			if strings.TrimSpace(scanner.Text()) == "" {
+				continue
+			}
+			var rec jobRecord
+			if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil || rec.ID == "" {
+				logger.WithError(err).WithFields(logrus.Fields{
+					"path": path,
+					"line": line,
+				}).Warn("Skipping unreadable line in job store")
+				continue
+			}
+			latest[rec.ID] = rec
+		}
+		in.Close()
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read job store: %w", err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to open job store: %w", err)
+	}
+
+	for _, rec := range latest {
+		if !rec.Deleted {
+			store.loaded = append(store.loaded, rec)
+		}
+	}
+	sort.Slice(store.loaded, func(i, j int) bool {
+		return store.loaded[i].CreatedAt.Before(store.loaded[j].CreatedAt)
+	})
+
+	if err := store.compact(store.loaded); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// load hands over the jobs read when the store was opened.
+func (s *FileJobStore) load() []jobRecord {
+	recs := s.loaded
+	s.loaded = nil
+	return recs
+}
+
+// save appends a job's state. Failures are logged rather than failing the job.
+func (s *FileJobStore) save(rec jobRecord) {
+	if rec.Status == JobStatusFailed && s.shuttingDown.Load() {
+		// Left as it was, so the job runs again after the restart
+		return
+	}
+	line, err := json.Marshal(rec)
+	if err == nil {
+		s.mu.Lock()
+		if s.file == nil {
+			err = os.ErrClosed
+		} else {
+			_, err = s.file.Write(append(line, '\n'))
+		}
+		s.mu.Unlock()
+	}
+	if err != nil {
+		s.logger.WithError(err).WithField("job_id", rec.ID).Warn("Failed to write job to job store")
+	}
+}
+
+// remove records that a job was removed from the queue.
+func (s *FileJobStore) remove(jobID string) {
+	s.save(jobRecord{ID: jobID, Deleted: true})
+}
+
+// compact replaces the file with one line per job. The lines are written to
+// a temporary file in the same directory, synced, and renamed over the
+// store, and the directory is synced, so a crash leaves either the old file
+// or the new one.
+func (s *FileJobStore) compact(recs []jobRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to compact job store: %w", err)
+	}
+	partial := out.Name()
+	writer := bufio.NewWriter(out)
+	encoder := json.NewEncoder(writer)
+	for _, rec := range recs {
+		if err = encoder.Encode(rec); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err == nil {
+		err = out.Sync()
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(partial, s.path)
+	}
+	if err != nil {
+		os.Remove(partial)
+		return fmt.Errorf("failed to compact job store: %w", err)
+	}
+	// The rename only survives a crash once the directory is synced
+	if err := syncDir(filepath.Dir(s.path)); err != nil {
+		s.logger.WithError(err).WithField("path", s.path).Warn("Failed to sync job store directory")
+	}
+
+	if s.file != nil {
+		s.file.Close()
+	}
+	s.file, err = os.OpenFile(s.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		s.file = nil
+		return fmt.Errorf("failed to open job store for writing: %w", err)
+	}
+	return nil
+}
+
+// syncDir syncs a directory, making renames in it durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// beginShutdown stops recording job failures.
+func (s *FileJobStore) beginShutdown() {
+	s.shuttingDown.Store(true)
+}
+
+// Close closes the store file.
+func (s *FileJobStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
+
+// record captures the job's state for the store. Callers hold j.mu.
+func (j *TranslationJob) record() jobRecord {
+	rec := jobRecord{
+		ID:                 j.ID,
+		RequestID:          j.RequestID,
+		Namespace:          j.Namespace,
+		ClientID:           j.ClientID,
+		BatchID:            j.BatchID,
+		CallbackURL:        j.CallbackURL,
+		Status:             j.Status,
+		CreatedAt:          j.CreatedAt,
+		StartedAt:          j.StartedAt,
+		CompletedAt:        j.CompletedAt,
+		Error:              j.Error,
+		ErrorClass:         j.ErrorClass,
+		Primitive:          j.Primitive,
+		Title:              j.Title,
+		SourceLang:         j.SourceLang,
+		TargetLang:         j.TargetLang,
+		LocalizeFormats:    j.LocalizeFormats,
+		Priority:           j.Priority,
+		NotBefore:          j.NotBefore,
+		ProgressMessage:    j.ProgressMessage,
+		IdempotencyKey:     j.idempotencyKey,
+		Fingerprint:        j.fingerprint,
+		TranslatedTitle:    j.TranslatedTitle,
+		TranslatedMarkdown: j.TranslatedMarkdown,
+		TokensUsed:         j.TokensUsed,
+		InferenceTime:      j.InferenceTime,
+		ResultKey:          j.resultKey,
+		ResultExpiresAt:    j.ResultExpiresAt,
+		ProgressPercent:    j.ProgressPercent,
+		Attempt:            j.Attempt,
+		Attempts:           append([]JobAttempt(nil), j.Attempts...),
+		DeadLetter:         j.DeadLetter,
+		RequeuedAs:         j.RequeuedAs,
+		DuplicateOf:        j.DuplicateOf,
+		PausedBy:           j.PausedBy,
+		Webhook:            j.Webhook.clone(),
+		Usage:              j.Usage,
+		Checkpoint:         j.Checkpoint.clone(),
+		Progress:           append([]JobProgressEvent(nil), j.Progress...),
+	}
+	if j.Document != nil {
+		if doc, err := protojson.Marshal(j.Document); err == nil {
+			rec.Document = doc
+		}
+	}
+	return rec
+}
+
+// persist saves the job's state if the queue has a store. Callers hold j.mu.
+func (j *TranslationJob) persist() {
+	if j.store != nil {
+		j.store.save(j.record())
+	}
+}
+
+// jobFromRecord rebuilds a stored job. If requeue is set, a job that
+// hadn't finished is queued again with requeue as its progress message,
+// resuming from its checkpoint, unless an admin paused it; otherwise its
+// state is kept as stored.
+func jobFromRecord(rec jobRecord, requeue string) (*TranslationJob, error) {
+	ctx, cancel := context.WithCancel(translate.WithPriority(context.Background(), rec.Priority))
+	job := &TranslationJob{
+		ID:                 rec.ID,
+		RequestID:          rec.RequestID,
+		Namespace:          rec.Namespace,
+		ClientID:           rec.ClientID,
+		BatchID:            rec.BatchID,
+		CallbackURL:        rec.CallbackURL,
+		Status:             rec.Status,
+		CreatedAt:          rec.CreatedAt,
+		StartedAt:          rec.StartedAt,
+		CompletedAt:        rec.CompletedAt,
+		Error:              rec.Error,
+		ErrorClass:         rec.ErrorClass,
+		Primitive:          rec.Primitive,
+		Title:              rec.Title,
+		SourceLang:         rec.SourceLang,
+		TargetLang:         rec.TargetLang,
+		LocalizeFormats:    rec.LocalizeFormats,
+		Priority:           rec.Priority,
+		NotBefore:          rec.NotBefore,
+		ProgressPercent:    rec.ProgressPercent,
+		ProgressMessage:    rec.ProgressMessage,
+		TranslatedTitle:    rec.TranslatedTitle,
+		TranslatedMarkdown: rec.TranslatedMarkdown,
+		TokensUsed:         rec.TokensUsed,
+		InferenceTime:      rec.InferenceTime,
+		ResultExpiresAt:    rec.ResultExpiresAt,
+		resultKey:          rec.ResultKey,
+		Attempt:            rec.Attempt,
+		Attempts:           rec.Attempts,
+		DeadLetter:         rec.DeadLetter,
+		RequeuedAs:         rec.RequeuedAs,
+		DuplicateOf:        rec.DuplicateOf,
+		PausedBy:           rec.PausedBy,
+		Webhook:            rec.Webhook,
+		Usage:              rec.Usage,
+		Checkpoint:         rec.Checkpoint,
+		Progress:           rec.Progress,
+		ctx:                ctx,
+		cancel:             cancel,
+		idempotencyKey:     rec.IdempotencyKey,
+		fingerprint:        rec.Fingerprint,
+		done:               make(chan struct{}),
+	}
+	if len(rec.Document) > 0 {
+		job.Document = &nanabushv1.DocumentContent{}
+		if err := protojson.Unmarshal(rec.Document, job.Document); err != nil {
+			cancel()
+			return nil, fmt.Errorf("job %s: bad document: %w", rec.ID, err)
+		}
+	}
+
+	switch {
+	case job.Status.IsTerminal():
+		if job.Status == JobStatusCompleted {
+			job.ProgressPercent = 100
+		}
+		job.markDone()
+		cancel()
+	case job.Status == JobStatusPaused && job.PausedBy == PausedByAdmin:
+	case requeue != "":
+		job.Status = JobStatusQueued
+		job.PausedBy = "" // Queue pauses don't outlive the server
+		job.StartedAt = nil
+		job.ProgressPercent = 0
+		job.ProgressMessage = requeue
+		job.Attempt = 0
+		job.DuplicateOf = "" // Requeued jobs translate on their own
+		job.recordProgress(requeue, 0, 0)
+	}
+	return job, nil
+}
+
+// SetStore makes the queue persist its jobs to store, and takes the jobs the
+// store held when it was opened: finished jobs are kept for status lookups
+// (and their pending webhooks delivered), and unfinished ones are queued
+// again, except those paused with PauseJob. Call SetWebhooks first.
+// It returns the number re-queued.
+// With a SharedJobStore the queue is shared with other replicas: new jobs
+// go to the store, and this replica claims jobs from it to process.
+func (q *JobQueue) SetStore(store JobStore) int {
+	var requeued, restored []*TranslationJob
+	recs := store.load()
+	q.jobsMu.Lock()
+	q.store = store
+	q.shared, _ = store.(SharedJobStore)
+	for _, rec := range recs {
+		job, err := jobFromRecord(rec, "Re-queued after restart")
+		if err != nil {
+			q.logger.WithError(err).Warn("Skipping stored job")
+			continue
+		}
+		job.store = store
+		job.webhooks = q.webhooks
+		job.events = q.events
+		job.archive = q.archive
+		job.audit = q.audit
+		job.results = q.results
+		q.jobs[job.ID] = job
+		restored = append(restored, job)
+		if job.idempotencyKey != "" {
+			q.idempotency[job.idempotencyKey] = job
+		}
+		if !job.Status.IsTerminal() && job.Status != JobStatusPaused {
+			requeued = append(requeued, job)
+		}
+	}
+	q.jobsMu.Unlock()
+	q.resumeWebhooks(restored)
+
+	for _, job := range requeued {
+		job.mu.Lock()
+		job.persist()
+		job.mu.Unlock()
+		if q.processor != nil {
+			q.scheduler.submit(job)
+		}
+	}
+
+	if q.shared != nil {
+		q.startShared()
+		return 0
+	}
+	q.logger.WithFields(logrus.Fields{
+		"jobs":     len(recs),
+		"requeued": len(requeued),
+	}).Info("Restored translation jobs from job store")
+	return len(requeued)
+}
+
+// Shutdown shuts the queue down: jobs waiting to start no longer start (a
+// shared queue stops claiming jobs), and jobs already running are given
+// until ctx is done to finish. Jobs that fail after that, typically because
+// the worker pool is stopping, stay unfinished in the store (and aren't
+// reported to webhooks or as events) so they run again after the restart
+// (or, with a shared store, on another replica once their lease expires).
+// Finished jobs are no longer removed. It reports whether every running job
+// finished in time.
+func (q *JobQueue) Shutdown(ctx context.Context) bool {
+	q.scheduler.stop()
+	if q.shared != nil {
+		q.stopShared()
+	}
+	finished := q.scheduler.wait(ctx)
+	if q.shared != nil {
+		claimed := make(chan struct{})
+		go func() {
+			q.claimers.Wait()
+			close(claimed)
+		}()
+		select {
+		case <-claimed:
+		case <-ctx.Done():
+			finished = false
+		}
+	}
+
+	if q.store != nil {
+		q.store.beginShutdown()
+		q.webhooks.holdFailures.Store(true)
+		if q.events != nil {
+			q.events.holdFailures.Store(true)
+		}
+		if q.archive != nil {
+			q.archive.holdFailures.Store(true)
+		}
+	}
+	q.retentionStopOnce.Do(func() { close(q.retentionStop) })
+	return finished
+}
+
+// compactStore rewrites the store with the jobs the queue holds. Callers
+// hold q.jobsMu.
+func (q *JobQueue) compactStore() {
+	if q.store == nil {
+		return
+	}
+	recs := make([]jobRecord, 0, len(q.jobs))
+	for _, job := range q.jobs {
+		job.mu.RLock()
+		recs = append(recs, job.record())
+		job.mu.RUnlock()
+	}
+	sort.Slice(recs, func(i, j int) bool {
+		return recs[i].CreatedAt.Before(recs[j].CreatedAt)
+	})
+	if err := q.store.compact(recs); err != nil {
+		q.logger.WithError(err).Warn("Failed to compact job store")
+	}
+}
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	bolt "go.etcd.io/bbolt"
+)
+
+// boltJobsBucket is the bucket holding each job's state (a jobRecord in
+// JSON), keyed by job ID.
+var boltJobsBucket = []byte("jobs")
+
+// boltOpenTimeout bounds waiting for another process to release the file.
+const boltOpenTimeout = 5 * time.Second
+
+// BoltJobStore persists translation jobs in a BoltDB file, an embedded
+// key-value store, so queued and running jobs survive a restart. Each state
+// change replaces the job's record in a transaction that is synced to disk
+// before save returns, so a crash loses at most the change being written
+// and never the rest of the store. Jobs that were running are queued again
+// and resume from their checkpoint.
+type BoltJobStore struct {
+	db     *bolt.DB
+	logger *logrus.Logger
+
+	loaded       []jobRecord // Jobs read when opened, until the queue takes them
+	shuttingDown atomic.Bool // Failures are no longer recorded
+}
+
+// OpenBoltJobStore opens (or creates) the BoltDB file at path and reads the
+// jobs it holds. Only one process can have the file open; it waits up to
+// boltOpenTimeout for another to close it. A record that can't be parsed is
+// skipped.
+func OpenBoltJobStore(path string, logger *logrus.Logger) (*BoltJobStore, error) {
+	if logger == nil {
+		logger = logrus.New()
+	}
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: boltOpenTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open job store: %w", err)
+	}
+	store := &BoltJobStore{db: db, logger: logger}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(boltJobsBucket)
+		if err != nil {
+			return err
+		}
+		return bucket.ForEach(func(id, value []byte) error {
+			var rec jobRecord
+			if err := json.Unmarshal(value, &rec); err != nil || rec.ID != string(id) {
+				logger.WithError(err).WithFields(logrus.Fields{
+					"path":   path,
+					"job_id": string(id),
+				}).Warn("Skipping unreadable job in job store")
+				return nil
+			}
+			store.loaded = append(store.loaded, rec)
+			return nil
+		})
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to read job store: %w", err)
+	}
+	sort.Slice(store.loaded, func(i, j int) bool {
+		return store.loaded[i].CreatedAt.Before(store.loaded[j].CreatedAt)
+	})
+	return store, nil
+}
+
+// load hands over the jobs read when the store was opened.
+func (s *BoltJobStore) load() []jobRecord {
+	recs := s.loaded
+	s.loaded = nil
+	return recs
+}
+
+// save replaces a job's record. Failures are logged rather than failing the
+// job.
+func (s *BoltJobStore) save(rec jobRecord) {
+	if rec.Status == JobStatusFailed && s.shuttingDown.Load() {
+		// Left as it was, so the job runs again after the restart
+		return
+	}
+	value, err := json.Marshal(rec)
+	if err == nil {
+		err = s.db.Update(func(tx *bolt.Tx) error {
+			return tx.Bucket(boltJobsBucket).Put([]byte(rec.ID), value)
+		})
+	}
+	if err != nil {
+		s.logger.WithError(err).WithField("job_id", rec.ID).Warn("Failed to write job to job store")
+	}
+}
+
+// remove deletes a job's record.
+func (s *BoltJobStore) remove(jobID string) {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltJobsBucket).Delete([]byte(jobID))
+	})
+	if err != nil {
+		s.logger.WithError(err).WithField("job_id", jobID).Warn("Failed to remove job from job store")
+	}
+}
+
+// compact deletes the records of jobs not in recs and rewrites the others,
+// in one transaction.
+func (s *BoltJobStore) compact(recs []jobRecord) error {
+	keep := make(map[string]bool, len(recs))
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltJobsBucket)
+		for _, rec := range recs {
+			value, err := json.Marshal(rec)
+			if err != nil {
+				return err
+			}
+			if err := bucket.Put([]byte(rec.ID), value); err != nil {
+				return err
+			}
+			keep[rec.ID] = true
+		}
+		var stale [][]byte
+		bucket.ForEach(func(id, _ []byte) error {
+			if !keep[string(id)] {
+				stale = append(stale, append([]byte(nil), id...))
+			}
+			return nil
+		})
+		for _, id := range stale {
+			if err := bucket.Delete(id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to compact job store: %w", err)
+	}
+	return nil
+}
+
+// beginShutdown stops recording job failures.
+func (s *BoltJobStore) beginShutdown() {
+	s.shuttingDown.Store(true)
+}
+
+// Close closes the store file.
+func (s *BoltJobStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,296 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultRedisJobPrefix prefixes the Redis keys of a shared job queue.
+	// Deployments sharing a Redis server use different prefixes.
+	DefaultRedisJobPrefix = "iskoces:jobs"
+
+	// redisFinishedJobTTL is how long a finished job stays in Redis, matching
+	// how long the server keeps finished jobs in memory.
+	redisFinishedJobTTL = time.Hour
+)
+
+// Lua scripts run atomically on the Redis server. Keys are named after the
+// store's prefix:
+//
+//	<prefix>:job:<id>  the job's state (a jobRecord in JSON)
+//	<prefix>:pending   queued job IDs, by dispatch order (sorted set)
+//	<prefix>:delayed   job IDs waiting for their not_before time, by that time in Unix ms (sorted set)
+//	<prefix>:scores    each unfinished job's dispatch order (hash)
+//	<prefix>:leases    claimed job IDs, by lease expiry in Unix ms (sorted set)
+//	<prefix>:owners    each claimed job's owner (hash)
+const (
+	// KEYS: job, pending, scores, delayed. ARGV: record, id, score,
+	// not_before in Unix ms (0 to queue the job now).
+	redisEnqueueScript = `
+if redis.call('EXISTS', KEYS[1]) == 1 then return 0 end
+redis.call('SET', KEYS[1], ARGV[1])
+if ARGV[4] == '0' then
+  redis.call('ZADD', KEYS[2], ARGV[3], ARGV[2])
+else
+  redis.call('ZADD', KEYS[4], ARGV[4], ARGV[2])
+end
+redis.call('HSET', KEYS[3], ARGV[2], ARGV[3])
+return 1`
+
+	// KEYS: job, pending, scores, delayed. ARGV: record, id, finished TTL in
+	// ms (0 for an unfinished job). A finished job's state is final.
+	redisSaveScript = `
+local current = redis.call('GET', KEYS[1])
+if current then
+  local status = cjson.decode(current)['status']
+  if status == 'completed' or status == 'failed' or status == 'cancelled' then return 0 end
+end
+if ARGV[3] == '0' then
+  redis.call('SET', KEYS[1], ARGV[1])
+else
+  redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[3])
+  redis.call('ZREM', KEYS[2], ARGV[2])
+  redis.call('ZREM', KEYS[4], ARGV[2])
+  redis.call('HDEL', KEYS[3], ARGV[2])
+end
+return 1`
+
+	// KEYS: pending, leases, owners, scores, delayed. ARGV: now in ms, lease
+	// in ms, owner. Returns the claimed job ID, or nil.
+	redisClaimScript = `
+local now = tonumber(ARGV[1])
+for _, id in ipairs(redis.call('ZRANGEBYSCORE', KEYS[5], '-inf', now)) do
+  redis.call('ZREM', KEYS[5], id)
+  local score = redis.call('HGET', KEYS[4], id)
+  if score then redis.call('ZADD', KEYS[1], score, id) end
+end
+for _, id in ipairs(redis.call('ZRANGEBYSCORE', KEYS[2], '-inf', now)) do
+  redis.call('ZREM', KEYS[2], id)
+  redis.call('HDEL', KEYS[3], id)
+  local score = redis.call('HGET', KEYS[4], id)
+  if score then redis.call('ZADD', KEYS[1], score, id) end
+end
+local next = redis.call('ZRANGE', KEYS[1], 0, 0)
+if #next == 0 then return false end
+redis.call('ZREM', KEYS[1], next[1])
+redis.call('ZADD', KEYS[2], now + tonumber(ARGV[2]), next[1])
+redis.call('HSET', KEYS[3], next[1], ARGV[3])
+return next[1]`
+
+	// KEYS: leases, owners. ARGV: id, owner, expiry in Unix ms.
+	redisRenewScript = `
+if redis.call('HGET', KEYS[2], ARGV[1]) ~= ARGV[2] then return 0 end
+redis.call('ZADD', KEYS[1], ARGV[3], ARGV[1])
+return 1`
+
+	// KEYS: leases, owners, scores. ARGV: id, owner.
+	redisReleaseScript = `
+if redis.call('HGET', KEYS[2], ARGV[1]) ~= ARGV[2] then return 0 end
+redis.call('ZREM', KEYS[1], ARGV[1])
+redis.call('HDEL', KEYS[2], ARGV[1])
+redis.call('HDEL', KEYS[3], ARGV[1])
+return 1`
+
+	// KEYS: job, pending, scores, delayed. ARGV: id.
+	redisRemoveScript = `
+redis.call('DEL', KEYS[1])
+redis.call('ZREM', KEYS[2], ARGV[1])
+redis.call('ZREM', KEYS[4], ARGV[1])
+redis.call('HDEL', KEYS[3], ARGV[1])
+return 1`
+)
+
+// RedisJobStore is a SharedJobStore in Redis (or a compatible server), so
+// several replicas can share one job queue. Jobs are dispatched by priority,
+// then age (jobs with a not_before time wait in a delayed set until it
+// passes); each claimed job is leased to its replica and queued again if
+// the lease isn't renewed, so a crashed replica's jobs aren't lost. Finished
+// jobs expire after redisFinishedJobTTL.
+type RedisJobStore struct {
+	client *redisClient
+	prefix string
+	logger *logrus.Logger
+
+	shuttingDown atomic.Bool // Failures are no longer recorded
+}
+
+// NewRedisJobStore connects to the Redis server at u, a URL of the form
+// redis[s]://[[user]:password@]host[:port][/db][?prefix=name]. The prefix
+// defaults to DefaultRedisJobPrefix.
+func NewRedisJobStore(u *url.URL, logger *logrus.Logger) (*RedisJobStore, error) {
+	if logger == nil {
+		logger = logrus.New()
+	}
+	client, err := newRedisClient(u)
+	if err != nil {
+		return nil, err
+	}
+	store := &RedisJobStore{
+		client: client,
+		prefix: DefaultRedisJobPrefix,
+		logger: logger,
+	}
+	if prefix := u.Query().Get("prefix"); prefix != "" {
+		store.prefix = prefix
+	}
+	if _, err := client.do("PING"); err != nil {
+		return nil, fmt.Errorf("failed to reach Redis job store: %w", err)
+	}
+	return store, nil
+}
+
+func (s *RedisJobStore) key(name string) string {
+	return s.prefix + ":" + name
+}
+
+func (s *RedisJobStore) jobKey(jobID string) string {
+	return s.prefix + ":job:" + jobID
+}
+
+// dispatchScore orders queued jobs: higher priority first, then oldest first.
+func dispatchScore(rec jobRecord) string {
+	score := -float64(rec.Priority)*1e13 + float64(rec.CreatedAt.UnixMilli())
+	return strconv.FormatFloat(score, 'f', 0, 64)
+}
+
+// load returns nothing: a shared queue's jobs stay in Redis and are claimed
+// or looked up from there.
+func (s *RedisJobStore) load() []jobRecord {
+	return nil
+}
+
+func (s *RedisJobStore) enqueue(rec jobRecord) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("failed to encode job: %w", err)
+	}
+	notBefore := "0"
+	if rec.NotBefore != nil {
+		notBefore = strconv.FormatInt(rec.NotBefore.UnixMilli(), 10)
+	}
+	_, err = s.client.eval(redisEnqueueScript,
+		[]string{s.jobKey(rec.ID), s.key("pending"), s.key("scores"), s.key("delayed")},
+		string(data), rec.ID, dispatchScore(rec), notBefore)
+	return err
+}
+
+// save stores a job's state. A job's finished state isn't overwritten, so a
+// cancellation from another replica sticks. Failures are logged rather than
+// failing the job.
+func (s *RedisJobStore) save(rec jobRecord) {
+	if rec.Status == JobStatusFailed && s.shuttingDown.Load() {
+		// Left as it was, so the job's lease expires and another replica
+		// runs it
+		return
+	}
+	data, err := json.Marshal(rec)
+	if err == nil {
+		ttl := "0"
+		if rec.Status.IsTerminal() {
+			ttl = strconv.FormatInt(redisFinishedJobTTL.Milliseconds(), 10)
+		}
+		_, err = s.client.eval(redisSaveScript,
+			[]string{s.jobKey(rec.ID), s.key("pending"), s.key("scores"), s.key("delayed")},
+			string(data), rec.ID, ttl)
+	}
+	if err != nil {
+		s.logger.WithError(err).WithField("job_id", rec.ID).Warn("Failed to write job to job store")
+	}
+}
+
+func (s *RedisJobStore) remove(jobID string) {
+	_, err := s.client.eval(redisRemoveScript,
+		[]string{s.jobKey(jobID), s.key("pending"), s.key("scores"), s.key("delayed")},
+		jobID)
+	if err != nil {
+		s.logger.WithError(err).WithField("job_id", jobID).Warn("Failed to remove job from job store")
+	}
+}
+
+// compact does nothing: finished jobs expire on their own.
+func (s *RedisJobStore) compact([]jobRecord) error {
+	return nil
+}
+
+func (s *RedisJobStore) claim(owner string, lease time.Duration) (jobRecord, bool, error) {
+	for {
+		reply, err := s.client.eval(redisClaimScript,
+			[]string{s.key("pending"), s.key("leases"), s.key("owners"), s.key("scores"), s.key("delayed")},
+			strconv.FormatInt(time.Now().UnixMilli(), 10),
+			strconv.FormatInt(lease.Milliseconds(), 10),
+			owner)
+		if err != nil {
+			return jobRecord{}, false, fmt.Errorf("failed to claim job: %w", err)
+		}
+		id, ok := reply.([]byte)
+		if !ok {
+			return jobRecord{}, false, nil
+		}
+
+		rec, found, err := s.get(string(id))
+		if err != nil {
+			return jobRecord{}, false, err
+		}
+		if found {
+			return rec, true, nil
+		}
+		// Removed (or expired) while queued
+		s.release(string(id), owner)
+	}
+}
+
+func (s *RedisJobStore) renew(jobID, owner string, lease time.Duration) (bool, error) {
+	reply, err := s.client.eval(redisRenewScript,
+		[]string{s.key("leases"), s.key("owners")},
+		jobID, owner, strconv.FormatInt(time.Now().Add(lease).UnixMilli(), 10))
+	if err != nil {
+		return false, fmt.Errorf("failed to renew lease: %w", err)
+	}
+	return reply == int64(1), nil
+}
+
+func (s *RedisJobStore) release(jobID, owner string) {
+	_, err := s.client.eval(redisReleaseScript,
+		[]string{s.key("leases"), s.key("owners"), s.key("scores")},
+		jobID, owner)
+	if err != nil {
+		s.logger.WithError(err).WithField("job_id", jobID).Warn("Failed to release job lease")
+	}
+}
+
+func (s *RedisJobStore) get(jobID string) (jobRecord, bool, error) {
+	reply, err := s.client.do("GET", s.jobKey(jobID))
+	if err != nil {
+		return jobRecord{}, false, fmt.Errorf("failed to read job: %w", err)
+	}
+	data, ok := reply.([]byte)
+	if !ok {
+		return jobRecord{}, false, nil
+	}
+	var rec jobRecord
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return jobRecord{}, false, fmt.Errorf("failed to decode job %s: %w", jobID, err)
+	}
+	if rec.ID == "" {
+		return jobRecord{}, false, errors.New("stored job has no ID")
+	}
+	return rec, true, nil
+}
+
+// beginShutdown stops recording job failures.
+func (s *RedisJobStore) beginShutdown() {
+	s.shuttingDown.Store(true)
+}
+
+// Close closes the connection to Redis.
+func (s *RedisJobStore) Close() error {
+	return s.client.close()
+}
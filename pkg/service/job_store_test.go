@@ -0,0 +1,251 @@
+package service
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func quietLogger() *logrus.Logger {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return logger
+}
+
+// jobStoreKinds open each kind of local job store at a path in dir.
+var jobStoreKinds = []struct {
+	name string
+	open func(t *testing.T, dir string) JobStore
+}{
+	{"file", func(t *testing.T, dir string) JobStore {
+		store, err := OpenJobStore(filepath.Join(dir, "jobs.jsonl"), quietLogger())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return store
+	}},
+	{"bolt", func(t *testing.T, dir string) JobStore {
+		store, err := OpenJobStore("bolt://"+filepath.Join(dir, "jobs.db"), quietLogger())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return store
+	}},
+}
+
+// storedJobs are jobs in each state a server can stop with.
+func storedJobs(now time.Time) []jobRecord {
+	started := now.Add(-time.Minute)
+	return []jobRecord{
+		{ID: "queued", Namespace: "ns", Status: JobStatusQueued, CreatedAt: now.Add(-5 * time.Minute), Title: "Queued", SourceLang: "en", TargetLang: "fr"},
+		{ID: "running", Status: JobStatusProcessing, CreatedAt: now.Add(-4 * time.Minute), StartedAt: &started, Title: "Running", SourceLang: "en", TargetLang: "fr",
+			Document:        []byte(`{"markdown":"one\n\ntwo"}`),
+			ProgressPercent: 50, Attempt: 1,
+			Checkpoint: &JobCheckpoint{Title: "En cours", TitleDone: true, ChunkSize: 10, Chunks: 2, Done: map[int]string{0: "un"}}},
+		{ID: "completed", Status: JobStatusCompleted, CreatedAt: now.Add(-3 * time.Minute), CompletedAt: &now, Title: "Done", TranslatedTitle: "Fini", SourceLang: "en", TargetLang: "fr"},
+		{ID: "paused", Status: JobStatusPaused, PausedBy: PausedByAdmin, CreatedAt: now.Add(-2 * time.Minute), Title: "Paused", SourceLang: "en", TargetLang: "fr"},
+		{ID: "removed", Status: JobStatusQueued, CreatedAt: now.Add(-time.Minute), Title: "Removed", SourceLang: "en", TargetLang: "fr"},
+	}
+}
+
+func TestJobStoreRestartRequeues(t *testing.T) {
+	for _, kind := range jobStoreKinds {
+		t.Run(kind.name, func(t *testing.T) {
+			dir := t.TempDir()
+			store := kind.open(t, dir)
+			for _, rec := range storedJobs(time.Now()) {
+				store.save(rec)
+			}
+			store.remove("removed")
+			if err := store.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			// The server restarts
+			store = kind.open(t, dir)
+			queue := NewJobQueue(quietLogger())
+			if requeued := queue.SetStore(store); requeued != 2 {
+				t.Errorf("SetStore() re-queued %d jobs, want 2", requeued)
+			}
+			if _, err := queue.GetJob("removed"); err == nil {
+				t.Error("removed job was restored")
+			}
+			want := map[string]TranslationJobStatus{
+				"queued":    JobStatusQueued,
+				"running":   JobStatusQueued,
+				"completed": JobStatusCompleted,
+				"paused":    JobStatusPaused,
+			}
+			for id, status := range want {
+				job, err := queue.GetJob(id)
+				if err != nil {
+					t.Errorf("job %s not restored: %v", id, err)
+					continue
+				}
+				if job.Status != status {
+					t.Errorf("job %s status = %s, want %s", id, job.Status, status)
+				}
+			}
+			running, err := queue.GetJob("running")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if running.StartedAt != nil || running.Attempt != 0 || running.ProgressMessage != "Re-queued after restart" {
+				t.Errorf("re-queued job kept its run: started %v, attempt %d, message %q", running.StartedAt, running.Attempt, running.ProgressMessage)
+			}
+			if cp := running.Checkpoint; cp == nil || cp.Done[0] != "un" || !cp.TitleDone {
+				t.Errorf("re-queued job lost its checkpoint: %+v", cp)
+			}
+			if running.Document == nil || running.Document.Markdown != "one\n\ntwo" {
+				t.Errorf("re-queued job lost its document: %v", running.Document)
+			}
+			if title, _, _ := mustResult(t, queue, "completed"); title != "Fini" {
+				t.Errorf("completed job result = %q, want Fini", title)
+			}
+			if err := store.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			// The re-queued state was stored, so another restart sees it
+			store = kind.open(t, dir)
+			defer store.Close()
+			for _, rec := range store.load() {
+				if rec.ID == "running" && (rec.Status != JobStatusQueued || rec.Checkpoint == nil) {
+					t.Errorf("stored re-queued job: status %s, checkpoint %v", rec.Status, rec.Checkpoint)
+				}
+			}
+		})
+	}
+}
+
+func mustResult(t *testing.T, queue *JobQueue, id string) (string, string, error) {
+	t.Helper()
+	job, err := queue.GetJob(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return job.Result()
+}
+
+func TestJobStoreShutdownKeepsJobsUnfinished(t *testing.T) {
+	for _, kind := range jobStoreKinds {
+		t.Run(kind.name, func(t *testing.T) {
+			dir := t.TempDir()
+			store := kind.open(t, dir)
+			rec := jobRecord{ID: "job", Status: JobStatusProcessing, CreatedAt: time.Now(), SourceLang: "en", TargetLang: "fr"}
+			store.save(rec)
+			store.beginShutdown()
+			// The worker pool stopping fails the job
+			rec.Status = JobStatusFailed
+			store.save(rec)
+			store.Close()
+
+			store = kind.open(t, dir)
+			defer store.Close()
+			recs := store.load()
+			if len(recs) != 1 || recs[0].Status != JobStatusProcessing {
+				t.Errorf("after shutdown the store holds %+v, want the running job", recs)
+			}
+		})
+	}
+}
+
+func TestJobStoreCompact(t *testing.T) {
+	for _, kind := range jobStoreKinds {
+		t.Run(kind.name, func(t *testing.T) {
+			dir := t.TempDir()
+			store := kind.open(t, dir)
+			now := time.Now()
+			for _, rec := range storedJobs(now) {
+				store.save(rec)
+			}
+			// The queue only holds two of them now
+			kept := []jobRecord{storedJobs(now)[0], storedJobs(now)[2]}
+			if err := store.compact(kept); err != nil {
+				t.Fatalf("compact() = %v", err)
+			}
+			// Saves after compacting are kept too
+			store.save(jobRecord{ID: "new", Status: JobStatusQueued, CreatedAt: now, SourceLang: "en", TargetLang: "de"})
+			store.Close()
+
+			store = kind.open(t, dir)
+			defer store.Close()
+			var ids []string
+			for _, rec := range store.load() {
+				ids = append(ids, rec.ID)
+			}
+			if got := strings.Join(ids, ","); got != "queued,completed,new" {
+				t.Errorf("after compacting the store holds %s, want queued,completed,new", got)
+			}
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, entry := range entries {
+				if strings.HasSuffix(entry.Name(), ".tmp") {
+					t.Errorf("compacting left %s behind", entry.Name())
+				}
+			}
+		})
+	}
+}
+
+func TestFileJobStoreSurvivesCrashes(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "jobs.jsonl")
+	store, err := OpenFileJobStore(path, quietLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	store.save(jobRecord{ID: "a", Status: JobStatusQueued, CreatedAt: now, SourceLang: "en", TargetLang: "fr"})
+	store.save(jobRecord{ID: "b", Status: JobStatusQueued, CreatedAt: now.Add(time.Second), SourceLang: "en", TargetLang: "fr"})
+	store.Close()
+
+	// A crash cut the last append short, and another left a compaction's
+	// temporary file behind
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"id":"b","status":"compl`)
+	f.Close()
+	if err := os.WriteFile(filepath.Join(dir, "jobs.jsonl.123.tmp"), []byte(`{"id":"a","status":"cancelled"}`+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err = OpenFileJobStore(path, quietLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	recs := store.load()
+	if len(recs) != 2 || recs[0].ID != "a" || recs[1].ID != "b" {
+		t.Fatalf("after the crash the store holds %+v, want a and b", recs)
+	}
+	for _, rec := range recs {
+		if rec.Status != JobStatusQueued {
+			t.Errorf("job %s status = %s, want queued", rec.ID, rec.Status)
+		}
+	}
+
+	// Opening compacted the file, dropping the torn line
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines != 2 || strings.Contains(string(data), `"compl`) {
+		t.Errorf("compacted file:\n%s\nwant one line per job", data)
+	}
+}
+
+func TestOpenJobStoreBoltNeedsPath(t *testing.T) {
+	if _, err := OpenJobStore("bolt://", quietLogger()); err == nil {
+		t.Error("OpenJobStore(bolt://) succeeded")
+	}
+}
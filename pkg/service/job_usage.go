@@ -0,0 +1,200 @@
+package service
+
+import (
+	"sort"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	usageJobsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "iskoces_usage_jobs_total",
+			Help: "Total number of async translation jobs started, by namespace",
+		},
+		[]string{"namespace"},
+	)
+	usageCharactersTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "iskoces_usage_characters_total",
+			Help: "Total number of characters async jobs had translated by the engine, by namespace",
+		},
+		[]string{"namespace"},
+	)
+	usageChunksTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "iskoces_usage_chunks_total",
+			Help: "Total number of pieces (titles and document chunks) async jobs had translated, by namespace",
+		},
+		[]string{"namespace"},
+	)
+	usageEngineSeconds = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "iskoces_usage_engine_seconds_total",
+			Help: "Total time async jobs spent in engine calls, by namespace",
+		},
+		[]string{"namespace"},
+	)
+	usageQueueSeconds = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "iskoces_usage_queue_seconds_total",
+			Help: "Total time async jobs waited in the queue before starting, by namespace",
+		},
+		[]string{"namespace"},
+	)
+	usageEstimatedCost = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "iskoces_usage_estimated_cost_total",
+			Help: "Estimated engine cost of async jobs, in the unit of -engine-cost-per-million-chars, by namespace",
+		},
+		[]string{"namespace"},
+	)
+)
+
+// JobUsage is the resources a job used, for chargeback.
+type JobUsage struct {
+	Characters    int64         `json:"characters"`     // Characters translated by the engine, counting retried pieces again
+	Chunks        int           `json:"chunks"`         // Pieces translated: the title and each document chunk
+	EngineTime    time.Duration `json:"engine_time"`    // Time spent in engine calls, including failed ones (overlapping for parallel chunks)
+	QueueTime     time.Duration `json:"queue_time"`     // Time from submission (or not_before) to the first start
+	EstimatedCost float64       `json:"estimated_cost"` // Characters priced with the engine's cost per million characters
+}
+
+// add adds other's counts to u.
+func (u *JobUsage) add(other JobUsage) {
+	u.Characters += other.Characters
+	u.Chunks += other.Chunks
+	u.EngineTime += other.EngineTime
+	u.QueueTime += other.QueueTime
+	u.EstimatedCost += other.EstimatedCost
+}
+
+// NamespaceUsage is the resources a namespace's jobs used since the server
+// started.
+type NamespaceUsage struct {
+	Namespace string
+	Jobs      int64 // Jobs started (a job re-run after a restart counts again)
+	JobUsage
+}
+
+// usageLedger totals job usage per namespace since it was created.
+type usageLedger struct {
+	mu         sync.Mutex
+	namespaces map[string]*NamespaceUsage
+	since      time.Time
+}
+
+// newUsageLedger creates an empty ledger.
+func newUsageLedger() *usageLedger {
+	return &usageLedger{
+		namespaces: make(map[string]*NamespaceUsage),
+		since:      time.Now(),
+	}
+}
+
+// charge adds usage (and jobs started) to namespace's totals.
+func (l *usageLedger) charge(namespace string, jobs int64, usage JobUsage) {
+	label := namespaceLabel(namespace)
+	if jobs > 0 {
+		usageJobsTotal.WithLabelValues(label).Add(float64(jobs))
+	}
+	if usage.Characters > 0 {
+		usageCharactersTotal.WithLabelValues(label).Add(float64(usage.Characters))
+	}
+	if usage.Chunks > 0 {
+		usageChunksTotal.WithLabelValues(label).Add(float64(usage.Chunks))
+	}
+	if usage.EngineTime > 0 {
+		usageEngineSeconds.WithLabelValues(label).Add(usage.EngineTime.Seconds())
+	}
+	if usage.QueueTime > 0 {
+		usageQueueSeconds.WithLabelValues(label).Add(usage.QueueTime.Seconds())
+	}
+	if usage.EstimatedCost > 0 {
+		usageEstimatedCost.WithLabelValues(label).Add(usage.EstimatedCost)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	total, ok := l.namespaces[namespace]
+	if !ok {
+		total = &NamespaceUsage{Namespace: namespace}
+		l.namespaces[namespace] = total
+	}
+	total.Jobs += jobs
+	total.add(usage)
+}
+
+// Usage returns the usage totals of every namespace that ran jobs on this
+// server, by name, and when counting started.
+func (q *JobQueue) Usage() ([]NamespaceUsage, time.Time) {
+	q.usage.mu.Lock()
+	defer q.usage.mu.Unlock()
+
+	usage := make([]NamespaceUsage, 0, len(q.usage.namespaces))
+	for _, total := range q.usage.namespaces {
+		usage = append(usage, *total)
+	}
+	sort.Slice(usage, func(i, j int) bool { return usage[i].Namespace < usage[j].Namespace })
+	return usage, q.usage.since
+}
+
+// UsageSnapshot returns what the job has used so far (thread-safe).
+func (j *TranslationJob) UsageSnapshot() JobUsage {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	return j.Usage
+}
+
+// startUsage records how long the job waited before its first start and
+// charges the start to its namespace.
+func (p *JobProcessor) startUsage(job *TranslationJob) {
+	var usage JobUsage
+	job.mu.Lock()
+	if job.Usage.QueueTime == 0 && job.StartedAt != nil {
+		since := job.CreatedAt
+		if job.NotBefore != nil && job.NotBefore.After(since) {
+			since = *job.NotBefore
+		}
+		usage.QueueTime = max(job.StartedAt.Sub(since), time.Nanosecond)
+		job.Usage.QueueTime = usage.QueueTime
+		jobQueueWait.WithLabelValues(job.Priority.String()).Observe(usage.QueueTime.Seconds())
+	}
+	namespace := job.Namespace
+	job.mu.Unlock()
+
+	if p.usage != nil {
+		p.usage.charge(namespace, 1, usage)
+	}
+}
+
+// chargeEngineCall records an engine call for the job that took elapsed
+// and, if it succeeded, translated text.
+func (p *JobProcessor) chargeEngineCall(job *TranslationJob, text string, elapsed time.Duration, succeeded bool) {
+	usage := JobUsage{EngineTime: elapsed}
+	if succeeded {
+		usage.Characters = int64(utf8.RuneCountInString(text))
+		usage.Chunks = 1
+		usage.EstimatedCost = float64(usage.Characters) * p.costPerMillionChars / 1e6
+	}
+
+	job.mu.Lock()
+	job.Usage.add(usage)
+	namespace := job.Namespace
+	job.mu.Unlock()
+
+	if p.usage != nil {
+		p.usage.charge(namespace, 0, usage)
+	}
+}
+
+// SetCostPerMillionChars sets the engine's price per million characters
+// translated, used to estimate job costs (0, the default, for engines run
+// in-house). It must be called before jobs are submitted.
+func (p *JobProcessor) SetCostPerMillionChars(cost float64) {
+	p.costPerMillionChars = cost
+}
@@ -0,0 +1,436 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/sirupsen/logrus"
+
+	"github.com/dasmlab/iskoces/pkg/translate"
+)
+
+const (
+	// WebhookEventJobFinished is the event sent when a job completes, fails,
+	// or is cancelled.
+	WebhookEventJobFinished = "job.finished"
+
+	// WebhookEventBatchFinished is the event sent once every job in a batch
+	// has finished.
+	WebhookEventBatchFinished = "batch.finished"
+
+	// Webhook request headers. The signature is "sha256=" followed by the
+	// hex HMAC-SHA256 of "<timestamp>.<body>" keyed with the webhook secret;
+	// it is only sent when a secret is configured.
+	WebhookEventHeader       = "X-Iskoces-Event"
+	WebhookDeliveryHeader    = "X-Iskoces-Delivery" // Same for every attempt at one delivery
+	WebhookIdempotencyHeader = "Idempotency-Key"    // The delivery ID again, for receivers that deduplicate on it
+	WebhookTimestampHeader   = "X-Iskoces-Timestamp"
+	WebhookSignatureHeader   = "X-Iskoces-Signature"
+
+	// DefaultWebhookMaxAttempts is how many times a callback is tried before
+	// it is given up on.
+	DefaultWebhookMaxAttempts = 5
+
+	webhookTimeout    = 10 * time.Second
+	webhookBackoff    = time.Second
+	webhookMaxBackoff = time.Minute
+)
+
+// Job webhook delivery states.
+const (
+	WebhookPending   = "pending"
+	WebhookDelivered = "delivered"
+	WebhookFailed    = "failed"
+)
+
+var webhookDeliveriesTotal = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "iskoces_webhook_deliveries_total",
+		Help: "Total number of job and batch completion webhook attempts, by event and outcome (delivered, retried, failed)",
+	},
+	[]string{"event", "outcome"},
+)
+
+// WebhookEndpoint is where a namespace's job completions are reported.
+type WebhookEndpoint struct {
+	URL    string `json:"url"`
+	Secret string `json:"secret,omitempty"` // Overrides WebhookConfig.Secret
+}
+
+// WebhookConfig is the webhooks file format.
+type WebhookConfig struct {
+	// Secret signs callbacks for namespaces without their own secret,
+	// including those sent to a request's callback_url.
+	Secret string `json:"secret,omitempty"`
+	// ResultBaseURL is the server's external HTTP address (e.g.
+	// "https://iskoces.example.com"), used to make result_url absolute.
+	ResultBaseURL string `json:"result_base_url,omitempty"`
+	// MaxAttempts is how many times a callback is tried (default
+	// DefaultWebhookMaxAttempts).
+	MaxAttempts int `json:"max_attempts,omitempty"`
+	// Namespaces maps a namespace to the webhook its jobs are reported to
+	// when the request doesn't set a callback_url.
+	Namespaces map[string]WebhookEndpoint `json:"namespaces,omitempty"`
+}
+
+// LoadWebhookConfig reads a JSON webhooks file.
+func LoadWebhookConfig(path string) (WebhookConfig, error) {
+	var cfg WebhookConfig
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, fmt.Errorf("failed to read webhooks file: %w", err)
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("failed to parse webhooks file %s: %w", path, err)
+	}
+	for namespace, endpoint := range cfg.Namespaces {
+		if err := validateCallbackURL(endpoint.URL); err != nil {
+			return cfg, fmt.Errorf("webhooks file %s: namespace %q: %w", path, namespace, err)
+		}
+	}
+	return cfg, nil
+}
+
+// validateCallbackURL checks that a callback URL is an absolute http(s) URL.
+func validateCallbackURL(callbackURL string) error {
+	u, err := url.Parse(callbackURL)
+	if err != nil {
+		return fmt.Errorf("invalid callback URL: %w", err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("callback URL %q must be an absolute http or https URL", callbackURL)
+	}
+	return nil
+}
+
+// WebhookEvent is the JSON body POSTed when a job finishes.
+type WebhookEvent struct {
+	Event         string               `json:"event"`
+	JobID         string               `json:"job_id"`
+	RequestID     string               `json:"request_id,omitempty"`
+	Namespace     string               `json:"namespace,omitempty"`
+	OperationName string               `json:"operation_name"`
+	Status        TranslationJobStatus `json:"status"`
+	Message       string               `json:"message,omitempty"`
+	Error         string               `json:"error,omitempty"`
+	ErrorClass    translate.ErrorClass `json:"error_class,omitempty"`
+	CreatedAt     time.Time            `json:"created_at"`
+	CompletedAt   *time.Time           `json:"completed_at,omitempty"`
+	// ResultURL is the job's HTTP status endpoint; fetch the result with
+	// GetTranslationResult (or v2 GetJob) using job_id.
+	ResultURL string `json:"result_url"`
+}
+
+// WebhookDelivery is the state of a job's completion webhook. It is stored
+// with the job, so a callback still pending when the server stops, or
+// crashes, is retried after the restart under the same delivery ID.
+type WebhookDelivery struct {
+	ID          string     `json:"id"`     // Sent as X-Iskoces-Delivery and Idempotency-Key on every attempt
+	Status      string     `json:"status"` // WebhookPending, WebhookDelivered or WebhookFailed
+	Attempts    int        `json:"attempts"`
+	LastError   string     `json:"last_error,omitempty"`
+	DeliveredAt *time.Time `json:"delivered_at,omitempty"`
+}
+
+// clone returns a copy of d.
+func (d *WebhookDelivery) clone() *WebhookDelivery {
+	if d == nil {
+		return nil
+	}
+	c := *d
+	return &c
+}
+
+// WebhookState returns the state of the job's completion webhook, or nil
+// if it has none (thread-safe).
+func (j *TranslationJob) WebhookState() *WebhookDelivery {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	return j.Webhook.clone()
+}
+
+// recordWebhook stores the outcome of attempts at the job's webhook so far;
+// final is set once no more attempts will be made.
+func (j *TranslationJob) recordWebhook(attempts int, err error, final bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.Webhook == nil {
+		return
+	}
+	j.Webhook.Attempts = attempts
+	switch {
+	case err == nil:
+		now := time.Now()
+		j.Webhook.Status = WebhookDelivered
+		j.Webhook.LastError = ""
+		j.Webhook.DeliveredAt = &now
+	case final:
+		j.Webhook.Status = WebhookFailed
+		j.Webhook.LastError = err.Error()
+	default:
+		j.Webhook.LastError = err.Error()
+	}
+	j.persist()
+}
+
+// webhookDelivery is one callback: an event body POSTed to target until it
+// is accepted, fails for good, or maxAttempts attempts have been made.
+type webhookDelivery struct {
+	target      string
+	secret      string
+	eventType   string
+	body        []byte
+	id          string // X-Iskoces-Delivery, the same for every attempt
+	attempts    int    // Attempts already made, e.g. before a restart
+	maxAttempts int
+	fields      logrus.Fields // Identify the event in logs
+	// record, if set, is told the outcome of each attempt
+	record func(attempts int, err error, final bool)
+}
+
+// webhookNotifier reports finished jobs to their callback URL or their
+// namespace's webhook.
+type webhookNotifier struct {
+	mu     sync.RWMutex
+	config WebhookConfig
+	client *http.Client
+	logger *logrus.Logger
+
+	// holdFailures is set during shutdown when failed jobs will run again
+	// after the restart, so they aren't reported as failed.
+	holdFailures atomic.Bool
+}
+
+// newWebhookNotifier creates a notifier that only sends to callback URLs
+// given in requests, unsigned, until it is configured.
+func newWebhookNotifier(logger *logrus.Logger) *webhookNotifier {
+	return &webhookNotifier{
+		client: &http.Client{Timeout: webhookTimeout},
+		logger: logger,
+	}
+}
+
+// configure replaces the notifier's configuration.
+func (n *webhookNotifier) configure(cfg WebhookConfig) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.config = cfg
+}
+
+// endpoint returns the notifier's configuration and where, with which
+// secret, to report something submitted in namespace with callbackURL. The
+// target is empty if there is nowhere to report to.
+func (n *webhookNotifier) endpoint(callbackURL, namespace string) (cfg WebhookConfig, target, secret string) {
+	n.mu.RLock()
+	cfg = n.config
+	n.mu.RUnlock()
+
+	target, secret = callbackURL, cfg.Secret
+	if endpoint, ok := cfg.Namespaces[namespace]; ok && namespace != "" {
+		if target == "" {
+			target = endpoint.URL
+		}
+		if endpoint.Secret != "" {
+			secret = endpoint.Secret
+		}
+	}
+	return cfg, target, secret
+}
+
+// notify reports the job's final state, if it has a callback URL or its
+// namespace has a webhook, recording the delivery in j.Webhook; a delivery
+// already recorded as pending is resumed. Jobs in a batch are reported with
+// their batch instead. Callers hold j.mu and persist the job afterwards;
+// delivery happens in the background.
+func (n *webhookNotifier) notify(j *TranslationJob) {
+	if j.BatchID != "" || (j.Status == JobStatusFailed && n.holdFailures.Load()) {
+		return
+	}
+	if j.Webhook != nil && j.Webhook.Status != WebhookPending {
+		return
+	}
+
+	cfg, target, secret := n.endpoint(j.CallbackURL, j.Namespace)
+	if target == "" {
+		return
+	}
+	if j.Webhook == nil {
+		j.Webhook = &WebhookDelivery{ID: uuid.New().String(), Status: WebhookPending}
+	}
+
+	event := WebhookEvent{
+		Event:         WebhookEventJobFinished,
+		JobID:         j.ID,
+		RequestID:     j.RequestID,
+		Namespace:     j.Namespace,
+		OperationName: OperationName(j.ID),
+		Status:        j.Status,
+		Message:       j.ProgressMessage,
+		Error:         j.Error,
+		ErrorClass:    j.ErrorClass,
+		CreatedAt:     j.CreatedAt,
+		CompletedAt:   j.CompletedAt,
+		ResultURL:     strings.TrimSuffix(cfg.ResultBaseURL, "/") + "/api/v1/jobs/" + j.ID,
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		n.logger.WithError(err).WithField("job_id", j.ID).Error("Failed to encode job webhook")
+		return
+	}
+	go n.deliver(webhookDelivery{
+		target:      target,
+		secret:      secret,
+		eventType:   WebhookEventJobFinished,
+		body:        body,
+		id:          j.Webhook.ID,
+		attempts:    j.Webhook.Attempts,
+		maxAttempts: cfg.maxAttempts(),
+		fields: logrus.Fields{
+			"job_id": event.JobID,
+			"status": event.Status,
+		},
+		record: j.recordWebhook,
+	})
+}
+
+// resumeWebhooks delivers the webhooks of finished jobs that were still
+// pending when the server stopped.
+func (q *JobQueue) resumeWebhooks(jobs []*TranslationJob) {
+	resumed := 0
+	for _, job := range jobs {
+		job.mu.Lock()
+		if job.Status.IsTerminal() && job.Webhook != nil && job.Webhook.Status == WebhookPending {
+			job.notified = true
+			q.webhooks.notify(job)
+			resumed++
+		}
+		job.mu.Unlock()
+	}
+	if resumed > 0 {
+		q.logger.WithField("jobs", resumed).Info("Resuming job webhooks pending at the last shutdown")
+	}
+}
+
+// maxAttempts returns how many times a callback is tried.
+func (cfg WebhookConfig) maxAttempts() int {
+	if cfg.MaxAttempts <= 0 {
+		return DefaultWebhookMaxAttempts
+	}
+	return cfg.MaxAttempts
+}
+
+// deliver POSTs d's body to its target, retrying with exponential backoff
+// on network errors, timeouts, 429s and 5xx responses.
+func (n *webhookNotifier) deliver(d webhookDelivery) {
+	if d.id == "" {
+		d.id = uuid.New().String()
+	}
+	fields := d.fields
+	fields["event"] = d.eventType
+	fields["callback"] = redactURL(d.target)
+	fields["delivery_id"] = d.id
+	delay := webhookBackoff
+	for attempt := d.attempts + 1; ; attempt++ {
+		retry, err := n.post(d.target, d.secret, d.eventType, d.body, d.id)
+		final := err == nil || !retry || attempt >= d.maxAttempts
+		if d.record != nil {
+			d.record(attempt, err, final)
+		}
+		if err == nil {
+			webhookDeliveriesTotal.WithLabelValues(d.eventType, "delivered").Inc()
+			n.logger.WithFields(fields).WithField("attempt", attempt).Debug("Delivered webhook")
+			return
+		}
+		if final {
+			webhookDeliveriesTotal.WithLabelValues(d.eventType, "failed").Inc()
+			n.logger.WithFields(fields).WithField("attempt", attempt).WithError(err).Warn("Giving up on webhook")
+			return
+		}
+		webhookDeliveriesTotal.WithLabelValues(d.eventType, "retried").Inc()
+		wait := time.Duration(float64(delay) * (1 + retryJitter*(2*rand.Float64()-1)))
+		n.logger.WithFields(fields).WithFields(logrus.Fields{
+			"attempt":  attempt,
+			"retry_in": wait,
+		}).WithError(err).Info("Webhook failed, retrying")
+		time.Sleep(wait)
+		delay = min(2*delay, webhookMaxBackoff)
+	}
+}
+
+// post makes one delivery attempt. It reports whether a failure is worth
+// retrying.
+func (n *webhookNotifier) post(target, secret, eventType string, body []byte, deliveryID string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "iskoces-webhook")
+	req.Header.Set(WebhookEventHeader, eventType)
+	req.Header.Set(WebhookDeliveryHeader, deliveryID)
+	req.Header.Set(WebhookIdempotencyHeader, deliveryID)
+	req.Header.Set(WebhookTimestampHeader, strconv.FormatInt(timestamp, 10))
+	if secret != "" {
+		req.Header.Set(WebhookSignatureHeader, SignWebhook(secret, timestamp, body))
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests ||
+		resp.StatusCode == http.StatusRequestTimeout
+	return retry, fmt.Errorf("callback returned %s", resp.Status)
+}
+
+// SignWebhook returns the signature header value for a webhook body sent at
+// timestamp (Unix seconds). Receivers recompute it to verify a callback.
+func SignWebhook(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// redactURL strips credentials and the query (which often carries tokens)
+// from a URL for logging.
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "<invalid>"
+	}
+	u.User = nil
+	u.RawQuery = ""
+	return u.String()
+}
+
+// SetWebhooks configures where and how job completions are reported
+// (signing secrets and per-namespace webhooks). Requests can always give
+// their own callback_url; without a secret, callbacks are sent unsigned.
+func (q *JobQueue) SetWebhooks(cfg WebhookConfig) {
+	q.webhooks.configure(cfg)
+}
@@ -0,0 +1,237 @@
+package service
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/dasmlab/iskoces/pkg/tracing"
+	"github.com/dasmlab/iskoces/pkg/translate"
+)
+
+// RequestIDMetadataKey is the gRPC metadata key carrying the request ID. A
+// client-supplied value is kept; otherwise one is generated. Either way it is
+// echoed in the response header and included in request logs.
+const RequestIDMetadataKey = "x-request-id"
+
+// RequestIDFromContext returns the request ID assigned by the request
+// interceptors, or "" outside a gRPC call. Async jobs' contexts carry the ID
+// of the call that submitted them.
+func RequestIDFromContext(ctx context.Context) string {
+	return translate.RequestIDFromContext(ctx)
+}
+
+// RequestLogger returns logger with the fields that correlate a log line
+// with the call ctx belongs to: its request ID and, when the call is
+// traced, its trace ID. Handlers log through it, so every line a call
+// produces can be found from the ID its client was given.
+func RequestLogger(ctx context.Context, logger *logrus.Logger) *logrus.Entry {
+	fields := logrus.Fields{}
+	if id := RequestIDFromContext(ctx); id != "" {
+		fields["request_id"] = id
+	}
+	if sc := tracing.SpanContextFromContext(ctx); sc.IsValid() && sc.Sampled {
+		fields["trace_id"] = hex.EncodeToString(sc.TraceID[:])
+	}
+	return logger.WithContext(ctx).WithFields(fields)
+}
+
+// withRequestID attaches the caller's request ID (or a new one) to ctx.
+func withRequestID(ctx context.Context) (context.Context, string) {
+	var id string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(RequestIDMetadataKey); len(values) > 0 {
+			id = values[0]
+		}
+	}
+	if id == "" {
+		id = uuid.New().String()
+	}
+	return translate.WithRequestID(ctx, id), id
+}
+
+// withRequestInfo adds a RequestInfo detail with the request ID to a failed
+// call's status, unless it has one, so clients can quote the ID when they
+// report the failure. Errors that aren't statuses are left to gRPC.
+func withRequestInfo(err error, requestID string) error {
+	st, ok := status.FromError(err)
+	if !ok || st.Code() == codes.OK {
+		return err
+	}
+	for _, detail := range st.Details() {
+		if _, ok := detail.(*errdetails.RequestInfo); ok {
+			return err
+		}
+	}
+	withInfo, detailErr := st.WithDetails(&errdetails.RequestInfo{RequestId: requestID})
+	if detailErr != nil {
+		return err
+	}
+	return withInfo.Err()
+}
+
+// recoverError converts a recovered panic into an INTERNAL error, logging the stack.
+func recoverError(logger *logrus.Logger, method string, r any) error {
+	logger.WithFields(logrus.Fields{
+		"method": method,
+		"panic":  fmt.Sprint(r),
+		"stack":  string(debug.Stack()),
+	}).Error("Recovered from panic in gRPC handler")
+	return status.Error(codes.Internal, "internal server error")
+}
+
+// RecoveryUnaryInterceptor turns a panic in a unary handler into an INTERNAL
+// error instead of crashing the server.
+func RecoveryUnaryInterceptor(logger *logrus.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				resp, err = nil, recoverError(logger, info.FullMethod, r)
+			}
+		}()
+		return handler(ctx, req)
+	}
+}
+
+// RecoveryStreamInterceptor turns a panic in a stream handler into an INTERNAL
+// error instead of crashing the server.
+func RecoveryStreamInterceptor(logger *logrus.Logger) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = recoverError(logger, info.FullMethod, r)
+			}
+		}()
+		return handler(srv, ss)
+	}
+}
+
+// LoggingUnaryInterceptor assigns a request ID and logs each call with its
+// status code, duration, and request/response sizes.
+func LoggingUnaryInterceptor(logger *logrus.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, requestID := withRequestID(ctx)
+		_ = grpc.SetHeader(ctx, metadata.Pairs(RequestIDMetadataKey, requestID))
+
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		err = withRequestInfo(err, requestID)
+
+		fields := logrus.Fields{
+			"method":        info.FullMethod,
+			"request_id":    requestID,
+			"code":          status.Code(err).String(),
+			"duration_ms":   time.Since(start).Milliseconds(),
+			"request_bytes": messageSize(req),
+			"peer":          peerAddr(ctx),
+		}
+		if err == nil {
+			fields["response_bytes"] = messageSize(resp)
+		}
+		logCall(logger, fields, err)
+		return resp, err
+	}
+}
+
+// LoggingStreamInterceptor assigns a request ID and logs each stream with its
+// status code, duration, and message counts and sizes.
+func LoggingStreamInterceptor(logger *logrus.Logger) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, requestID := withRequestID(ss.Context())
+		_ = ss.SetHeader(metadata.Pairs(RequestIDMetadataKey, requestID))
+
+		counted := &countingStream{ServerStream: ss, ctx: ctx}
+		start := time.Now()
+		err := withRequestInfo(handler(srv, counted), requestID)
+
+		logCall(logger, logrus.Fields{
+			"method":         info.FullMethod,
+			"request_id":     requestID,
+			"code":           status.Code(err).String(),
+			"duration_ms":    time.Since(start).Milliseconds(),
+			"received":       counted.received,
+			"received_bytes": counted.receivedBytes,
+			"sent":           counted.sent,
+			"sent_bytes":     counted.sentBytes,
+			"peer":           peerAddr(ctx),
+		}, err)
+		return err
+	}
+}
+
+// logCall logs a finished call: successes at debug (handlers already log
+// their own progress), failures at info, and server-side faults at warn.
+func logCall(logger *logrus.Logger, fields logrus.Fields, err error) {
+	entry := logger.WithFields(fields)
+	switch status.Code(err) {
+	case codes.OK:
+		entry.Debug("gRPC call completed")
+	case codes.Internal, codes.Unknown, codes.DataLoss:
+		entry.WithError(err).Warn("gRPC call failed")
+	default:
+		entry.WithError(err).Info("gRPC call failed")
+	}
+}
+
+// countingStream carries the request ID context and counts messages in each direction.
+type countingStream struct {
+	grpc.ServerStream
+	ctx context.Context
+
+	received, sent           int
+	receivedBytes, sentBytes int
+}
+
+// Context returns the stream context with the request ID attached.
+func (s *countingStream) Context() context.Context {
+	return s.ctx
+}
+
+// RecvMsg receives a message and counts it.
+func (s *countingStream) RecvMsg(m any) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		s.received++
+		s.receivedBytes += messageSize(m)
+	}
+	return err
+}
+
+// SendMsg sends a message and counts it.
+func (s *countingStream) SendMsg(m any) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		s.sent++
+		s.sentBytes += messageSize(m)
+	}
+	return err
+}
+
+// messageSize returns the encoded size of a proto message, or 0.
+func messageSize(m any) int {
+	if msg, ok := m.(proto.Message); ok {
+		return proto.Size(msg)
+	}
+	return 0
+}
+
+// peerAddr returns the caller's address, or "" if unknown.
+func peerAddr(ctx context.Context) string {
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		return p.Addr.String()
+	}
+	return ""
+}
@@ -0,0 +1,249 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	longrunningpb "github.com/dasmlab/iskoces/pkg/proto/google/longrunning"
+	nanabushv1 "github.com/dasmlab/iskoces/pkg/proto/v1"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// operationsCollection is the parent resource of all operations; an
+	// operation's name is "operations/{job_id}".
+	operationsCollection = "operations"
+
+	// defaultListOperationsPageSize and maxListOperationsPageSize bound ListOperations pages.
+	defaultListOperationsPageSize = 50
+	maxListOperationsPageSize     = 1000
+)
+
+// OperationsService implements google.longrunning.Operations over the job
+// queue, so LRO-aware clients and gateways can manage translation jobs.
+// An operation's metadata is the job's nanabush.v1.TranslationStatus; its
+// response is the nanabush.v1.TranslateResponse, or its error the status
+// GetTranslationResult would return.
+type OperationsService struct {
+	longrunningpb.UnimplementedOperationsServer
+
+	// Translation is the service whose jobs are exposed as operations.
+	Translation *TranslationService
+
+	// Logger for operation calls.
+	Logger *logrus.Logger
+}
+
+// NewOperationsService creates an OperationsService for the given TranslationService.
+func NewOperationsService(translation *TranslationService, logger *logrus.Logger) *OperationsService {
+	if logger == nil {
+		logger = translation.Logger
+	}
+	return &OperationsService{
+		Translation: translation,
+		Logger:      logger,
+	}
+}
+
+// OperationName returns the operation name for a job ID.
+func OperationName(jobID string) string {
+	return operationsCollection + "/" + jobID
+}
+
+// ListOperations lists jobs as operations, oldest first. Supported filters
+// are "done=true" and "done=false".
+func (o *OperationsService) ListOperations(ctx context.Context, req *longrunningpb.ListOperationsRequest) (*longrunningpb.ListOperationsResponse, error) {
+	if req.Name != "" && req.Name != operationsCollection {
+		return nil, invalidArgument("name", fmt.Sprintf("must be empty or %q", operationsCollection))
+	}
+
+	var wantDone *bool
+	switch filter := strings.ReplaceAll(req.Filter, " ", ""); filter {
+	case "":
+	case "done=true", "done=false":
+		done := filter == "done=true"
+		wantDone = &done
+	default:
+		return nil, invalidArgument("filter", `supports only "done=true" and "done=false"`)
+	}
+
+	pageSize := int(req.PageSize)
+	switch {
+	case pageSize < 0:
+		return nil, invalidArgument("page_size", "must not be negative")
+	case pageSize == 0:
+		pageSize = defaultListOperationsPageSize
+	case pageSize > maxListOperationsPageSize:
+		pageSize = maxListOperationsPageSize
+	}
+	offset := 0
+	if req.PageToken != "" {
+		n, err := strconv.Atoi(req.PageToken)
+		if err != nil || n < 0 {
+			return nil, invalidArgument("page_token", "is not a token returned by ListOperations")
+		}
+		offset = n
+	}
+
+	var jobs []*TranslationJob
+	for _, job := range o.Translation.JobQueue.ListJobs() {
+		if wantDone != nil {
+			jobStatus, _, _ := job.GetStatus()
+			if jobStatus.IsTerminal() != *wantDone {
+				continue
+			}
+		}
+		jobs = append(jobs, job)
+	}
+
+	resp := &longrunningpb.ListOperationsResponse{}
+	if offset >= len(jobs) {
+		return resp, nil
+	}
+	end := offset + pageSize
+	if end < len(jobs) {
+		resp.NextPageToken = strconv.Itoa(end)
+	} else {
+		end = len(jobs)
+	}
+	for _, job := range jobs[offset:end] {
+		op, err := o.operation(ctx, job)
+		if err != nil {
+			return nil, err
+		}
+		resp.Operations = append(resp.Operations, op)
+	}
+	return resp, nil
+}
+
+// GetOperation returns a job's latest state as an operation.
+func (o *OperationsService) GetOperation(ctx context.Context, req *longrunningpb.GetOperationRequest) (*longrunningpb.Operation, error) {
+	job, err := o.lookup(req.Name)
+	if err != nil {
+		return nil, err
+	}
+	return o.operation(ctx, job)
+}
+
+// DeleteOperation forgets a finished job. Running jobs must be cancelled first.
+func (o *OperationsService) DeleteOperation(ctx context.Context, req *longrunningpb.DeleteOperationRequest) (*emptypb.Empty, error) {
+	job, err := o.lookup(req.Name)
+	if err != nil {
+		return nil, err
+	}
+	if err := o.Translation.JobQueue.DeleteJob(job.ID); err != nil {
+		switch {
+		case errors.Is(err, ErrJobNotFound):
+			return nil, status.Error(codes.NotFound, err.Error())
+		case errors.Is(err, ErrJobRunning):
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
+		default:
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+	}
+
+	o.Logger.WithFields(logrus.Fields{
+		"job_id": job.ID,
+	}).Info("Deleted translation job operation")
+	return &emptypb.Empty{}, nil
+}
+
+// CancelOperation cancels a queued or running job. The operation then
+// finishes with a CANCELLED error.
+func (o *OperationsService) CancelOperation(ctx context.Context, req *longrunningpb.CancelOperationRequest) (*emptypb.Empty, error) {
+	job, err := o.lookup(req.Name)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := o.Translation.CancelTranslation(ctx, &nanabushv1.CancelTranslationRequest{
+		JobId:  job.ID,
+		Reason: "cancelled via CancelOperation",
+	}); err != nil {
+		return nil, err
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// WaitOperation blocks until the job finishes, the timeout elapses, or the
+// call ends, then returns the latest state.
+func (o *OperationsService) WaitOperation(ctx context.Context, req *longrunningpb.WaitOperationRequest) (*longrunningpb.Operation, error) {
+	job, err := o.lookup(req.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	wait := time.Duration(defaultWaitJobSeconds) * time.Second
+	if req.Timeout != nil {
+		if err := req.Timeout.CheckValid(); err != nil || req.Timeout.AsDuration() < 0 {
+			return nil, invalidArgument("timeout", "must be a non-negative duration")
+		}
+		wait = req.Timeout.AsDuration()
+	}
+	if max := time.Duration(maxWaitJobSeconds) * time.Second; wait > max {
+		wait = max
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-job.Done():
+	case <-timer.C:
+	case <-ctx.Done():
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
+	return o.operation(ctx, job)
+}
+
+// lookup resolves an operation name ("operations/{job_id}") to its job.
+func (o *OperationsService) lookup(name string) (*TranslationJob, error) {
+	jobID, ok := strings.CutPrefix(name, operationsCollection+"/")
+	if !ok || jobID == "" {
+		return nil, invalidArgument("name", fmt.Sprintf("must be of the form %q", OperationName("{job_id}")))
+	}
+	job, err := o.Translation.JobQueue.GetJob(jobID)
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	return job, nil
+}
+
+// operation converts a job into an Operation.
+func (o *OperationsService) operation(ctx context.Context, job *TranslationJob) (*longrunningpb.Operation, error) {
+	statusProto := jobToStatusProto(job)
+	metadata, err := anypb.New(statusProto)
+	if err != nil {
+		return nil, status.Error(codes.Internal, fmt.Sprintf("failed to encode operation metadata: %v", err))
+	}
+
+	op := &longrunningpb.Operation{
+		Name:     OperationName(job.ID),
+		Metadata: metadata,
+	}
+	jobStatus, _, _ := job.GetStatus()
+	if !jobStatus.IsTerminal() {
+		return op, nil
+	}
+
+	op.Done = true
+	result, err := o.Translation.GetTranslationResult(ctx, &nanabushv1.GetTranslationResultRequest{JobId: job.ID})
+	if err != nil {
+		op.Result = &longrunningpb.Operation_Error{Error: status.Convert(err).Proto()}
+		return op, nil
+	}
+	response, err := anypb.New(result)
+	if err != nil {
+		return nil, status.Error(codes.Internal, fmt.Sprintf("failed to encode operation response: %v", err))
+	}
+	op.Result = &longrunningpb.Operation_Response{Response: response}
+	return op, nil
+}
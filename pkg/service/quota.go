@@ -0,0 +1,447 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	nanabushv1 "github.com/dasmlab/iskoces/pkg/proto/v1"
+	nanabushv2 "github.com/dasmlab/iskoces/pkg/proto/v2"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// NamespaceMetadataKey is the gRPC metadata key naming the caller's
+	// namespace for requests that don't carry one (e.g. TranslateStream).
+	NamespaceMetadataKey = "x-namespace"
+
+	// RetryAfterMetadataKey is the response header carrying the number of
+	// seconds to wait after a quota rejection.
+	RetryAfterMetadataKey = "retry-after"
+
+	// ReasonQuotaExceeded is the ErrorInfo reason for quota rejections.
+	ReasonQuotaExceeded = "QUOTA_EXCEEDED"
+)
+
+var quotaRejectionsTotal = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "iskoces_quota_rejections_total",
+		Help: "Total number of requests rejected by namespace quotas",
+	},
+	[]string{"namespace", "limit"},
+)
+
+// QuotaLimits bounds a namespace's usage. Zero means unlimited.
+type QuotaLimits struct {
+	RequestsPerMinute int   `json:"requests_per_minute"`
+	CharactersPerDay  int64 `json:"characters_per_day"`
+}
+
+// QuotaConfig is the quota file format.
+type QuotaConfig struct {
+	// Default applies to namespaces without their own entry.
+	Default QuotaLimits `json:"default"`
+	// Namespaces overrides the default per namespace.
+	Namespaces map[string]QuotaLimits `json:"namespaces"`
+}
+
+// LoadQuotaConfig reads a JSON quota file.
+func LoadQuotaConfig(path string) (QuotaConfig, error) {
+	var cfg QuotaConfig
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, fmt.Errorf("failed to read quota file: %w", err)
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("failed to parse quota file %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// QuotaUsage reports a namespace's limits and current usage.
+type QuotaUsage struct {
+	Namespace      string
+	Limits         QuotaLimits
+	CharactersUsed int64
+	// RequestsAvailable is how many requests can be made right now, or -1 if unlimited.
+	RequestsAvailable float64
+	HasOwnLimits      bool
+}
+
+// quotaState is the usage of one namespace.
+type quotaState struct {
+	tokens     float64   // request tokens (bucket of RequestsPerMinute)
+	refilledAt time.Time // last token refill
+	day        time.Time // UTC day chars counts towards
+	chars      int64
+}
+
+// QuotaManager enforces per-namespace request rates and daily character budgets.
+type QuotaManager struct {
+	mu     sync.Mutex
+	config QuotaConfig
+	usage  map[string]*quotaState
+	now    func() time.Time
+}
+
+// NewQuotaManager creates a manager with the given configuration.
+func NewQuotaManager(cfg QuotaConfig) *QuotaManager {
+	if cfg.Namespaces == nil {
+		cfg.Namespaces = make(map[string]QuotaLimits)
+	}
+	return &QuotaManager{
+		config: cfg,
+		usage:  make(map[string]*quotaState),
+		now:    time.Now,
+	}
+}
+
+// limitsFor returns the limits that apply to namespace. Callers hold q.mu.
+func (q *QuotaManager) limitsFor(namespace string) (QuotaLimits, bool) {
+	if limits, ok := q.config.Namespaces[namespace]; ok && namespace != "" {
+		return limits, true
+	}
+	return q.config.Default, false
+}
+
+// state returns namespace's usage, refilled and rolled over to now. Callers hold q.mu.
+func (q *QuotaManager) state(namespace string, limits QuotaLimits, now time.Time) *quotaState {
+	st, ok := q.usage[namespace]
+	if !ok {
+		st = &quotaState{tokens: float64(limits.RequestsPerMinute), refilledAt: now}
+		q.usage[namespace] = st
+	}
+
+	capacity := float64(limits.RequestsPerMinute)
+	st.tokens = math.Min(capacity, st.tokens+now.Sub(st.refilledAt).Seconds()*capacity/60)
+	st.refilledAt = now
+
+	if day := now.UTC().Truncate(24 * time.Hour); !day.Equal(st.day) {
+		st.day = day
+		st.chars = 0
+	}
+	return st
+}
+
+// Allow charges requests and chars to namespace. If either limit would be
+// exceeded nothing is charged and a RESOURCE_EXHAUSTED error is returned
+// together with how long the caller should wait.
+func (q *QuotaManager) Allow(namespace string, requests int, chars int64) (time.Duration, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := q.now()
+	limits, _ := q.limitsFor(namespace)
+	st := q.state(namespace, limits, now)
+
+	if limits.RequestsPerMinute > 0 && requests > 0 && st.tokens < float64(requests) {
+		rate := float64(limits.RequestsPerMinute) / 60
+		wait := time.Duration((float64(requests) - st.tokens) / rate * float64(time.Second))
+		quotaRejectionsTotal.WithLabelValues(namespaceLabel(namespace), "requests_per_minute").Inc()
+		return wait, quotaError(namespace, "requests_per_minute",
+			fmt.Sprintf("namespace %q exceeded its quota of %d requests per minute", namespaceLabel(namespace), limits.RequestsPerMinute), wait)
+	}
+	if limits.CharactersPerDay > 0 && chars > 0 && st.chars+chars > limits.CharactersPerDay {
+		wait := st.day.Add(24 * time.Hour).Sub(now)
+		quotaRejectionsTotal.WithLabelValues(namespaceLabel(namespace), "characters_per_day").Inc()
+		return wait, quotaError(namespace, "characters_per_day",
+			fmt.Sprintf("namespace %q exceeded its quota of %d characters per day (%d used, %d requested)",
+				namespaceLabel(namespace), limits.CharactersPerDay, st.chars, chars), wait)
+	}
+
+	if limits.RequestsPerMinute > 0 {
+		st.tokens -= float64(requests)
+	}
+	st.chars += chars
+	return 0, nil
+}
+
+// SetLimits sets the limits for namespace ("" for the default).
+func (q *QuotaManager) SetLimits(namespace string, limits QuotaLimits) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if namespace == "" {
+		q.config.Default = limits
+		return
+	}
+	q.config.Namespaces[namespace] = limits
+}
+
+// ResetLimits removes namespace's own limits so the default applies again.
+func (q *QuotaManager) ResetLimits(namespace string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.config.Namespaces, namespace)
+}
+
+// Usage returns the limits and usage of namespace.
+func (q *QuotaManager) Usage(namespace string) QuotaUsage {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.usageLocked(namespace, q.now())
+}
+
+// usageLocked builds a QuotaUsage. Callers hold q.mu.
+func (q *QuotaManager) usageLocked(namespace string, now time.Time) QuotaUsage {
+	limits, own := q.limitsFor(namespace)
+	st := q.state(namespace, limits, now)
+	usage := QuotaUsage{
+		Namespace:         namespace,
+		Limits:            limits,
+		CharactersUsed:    st.chars,
+		RequestsAvailable: st.tokens,
+		HasOwnLimits:      own,
+	}
+	if limits.RequestsPerMinute == 0 {
+		usage.RequestsAvailable = -1
+	}
+	return usage
+}
+
+// AllUsage returns the default limits and the usage of every namespace that
+// has its own limits or has made requests, sorted by namespace.
+func (q *QuotaManager) AllUsage() (QuotaLimits, []QuotaUsage) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := q.now()
+	seen := make(map[string]bool)
+	var out []QuotaUsage
+	for namespace := range q.config.Namespaces {
+		seen[namespace] = true
+		out = append(out, q.usageLocked(namespace, now))
+	}
+	for namespace := range q.usage {
+		if !seen[namespace] {
+			out = append(out, q.usageLocked(namespace, now))
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Namespace < out[j].Namespace })
+	return q.config.Default, out
+}
+
+// namespaceLabel names the empty namespace in messages and metrics.
+func namespaceLabel(namespace string) string {
+	if namespace == "" {
+		return "default"
+	}
+	return namespace
+}
+
+// quotaError builds a RESOURCE_EXHAUSTED error with QuotaFailure, RetryInfo
+// and ErrorInfo details.
+func quotaError(namespace, limit, message string, wait time.Duration) error {
+	st := status.New(codes.ResourceExhausted, message)
+	if detailed, err := st.WithDetails(
+		&errdetails.ErrorInfo{
+			Reason: ReasonQuotaExceeded,
+			Domain: errorDomain,
+			Metadata: map[string]string{
+				"retryable": "true",
+				"namespace": namespaceLabel(namespace),
+				"limit":     limit,
+			},
+		},
+		&errdetails.QuotaFailure{
+			Violations: []*errdetails.QuotaFailure_Violation{
+				{Subject: "namespace:" + namespaceLabel(namespace), Description: message},
+			},
+		},
+		&errdetails.RetryInfo{RetryDelay: durationpb.New(wait)},
+	); err == nil {
+		st = detailed
+	}
+	return st.Err()
+}
+
+// retryAfterHeader returns the retry-after header for a wait.
+func retryAfterHeader(wait time.Duration) metadata.MD {
+	seconds := int64(math.Ceil(wait.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	return metadata.Pairs(RetryAfterMetadataKey, strconv.FormatInt(seconds, 10))
+}
+
+// quotaRequests returns how many requests a request message counts as: one
+// per job for a batch, else one.
+func quotaRequests(msg any) int {
+	if m, ok := msg.(*nanabushv2.SubmitBatchRequest); ok && len(m.Requests) > 0 {
+		return len(m.Requests)
+	}
+	return 1
+}
+
+// quotaCost returns the characters a request message uses.
+func quotaCost(msg any) int64 {
+	switch m := msg.(type) {
+	case *nanabushv2.SubmitBatchRequest:
+		var n int64
+		for _, r := range m.Requests {
+			n += quotaCost(r)
+		}
+		return n
+	case *nanabushv1.TranslateRequest:
+		var n int
+		n += utf8.RuneCountInString(m.GetTitle())
+		if doc := m.GetDoc(); doc != nil {
+			n += utf8.RuneCountInString(doc.Title) + utf8.RuneCountInString(doc.Markdown)
+		}
+		return int64(n)
+	case *nanabushv1.BatchTranslateRequest:
+		var n int
+		for _, text := range m.Texts {
+			n += utf8.RuneCountInString(text)
+		}
+		return int64(n)
+	case *nanabushv1.TranslateChunk:
+		return int64(utf8.RuneCountInString(m.Content))
+	case *nanabushv1.DocumentSetRequest:
+		var n int
+		for _, doc := range m.Documents {
+			n += utf8.RuneCountInString(doc.GetTitle()) + utf8.RuneCountInString(doc.GetMarkdown())
+		}
+		return int64(n)
+	case *nanabushv2.TranslationRequest:
+		n := utf8.RuneCountInString(m.GetText())
+		if doc := m.GetDocument(); doc != nil {
+			n += utf8.RuneCountInString(doc.Title) + utf8.RuneCountInString(doc.Markdown)
+		}
+		return int64(n)
+	}
+	return 0
+}
+
+// quotaMethods are the RPCs subject to quotas: everything that does translation work.
+var quotaMethods = map[string]bool{
+	"/nanabush.v1.TranslationService/CheckTitle":           true,
+	"/nanabush.v1.TranslationService/Translate":            true,
+	"/nanabush.v1.TranslationService/TranslateStream":      true,
+	"/nanabush.v1.TranslationService/DetectLanguage":       true,
+	"/nanabush.v1.TranslationService/SubmitTranslation":    true,
+	"/nanabush.v1.TranslationService/BatchTranslate":       true,
+	"/nanabush.v1.TranslationService/TranslateDocumentSet": true,
+	"/nanabush.v2.TranslationService/SubmitTranslation":    true,
+	"/nanabush.v2.TranslationService/SubmitBatch":          true,
+	"/nanabush.v2.TranslationService/Translate":            true,
+}
+
+// quotaNamespace determines the namespace a call is charged to: the quota
+// namespace of the registered client's label policy, else the request's own
+// namespace, else x-namespace metadata, else the registered client's namespace.
+func (s *TranslationService) quotaNamespace(ctx context.Context, msg any) string {
+	if policy, ok := s.callerPolicy(ctx); ok && policy.QuotaNamespace != "" {
+		return policy.QuotaNamespace
+	}
+	switch req := msg.(type) {
+	case *nanabushv1.TranslateRequest:
+		if req.Namespace != "" {
+			return req.Namespace
+		}
+	case *nanabushv1.DocumentSetRequest:
+		if req.Namespace != "" {
+			return req.Namespace
+		}
+	case *nanabushv2.TranslationRequest:
+		if req.Namespace != "" {
+			return req.Namespace
+		}
+	case *nanabushv2.SubmitBatchRequest:
+		if req.Namespace != "" {
+			return req.Namespace
+		}
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get(NamespaceMetadataKey); len(values) > 0 && values[0] != "" {
+		return values[0]
+	}
+	if values := md.Get(ClientIDMetadataKey); len(values) > 0 {
+		s.clientsMutex.RLock()
+		defer s.clientsMutex.RUnlock()
+		if client, ok := s.clients[values[0]]; ok {
+			return client.Namespace
+		}
+	}
+	return ""
+}
+
+// QuotaUnaryInterceptor enforces namespace quotas on unary translation calls,
+// setting the retry-after header when it rejects one.
+func QuotaUnaryInterceptor(svc *TranslationService) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if svc.Quotas == nil || !quotaMethods[info.FullMethod] {
+			return handler(ctx, req)
+		}
+		namespace := svc.quotaNamespace(ctx, req)
+		if wait, err := svc.Quotas.Allow(namespace, quotaRequests(req), quotaCost(req)); err != nil {
+			_ = grpc.SetHeader(ctx, retryAfterHeader(wait))
+			svc.Logger.WithFields(logrus.Fields{
+				"method":      info.FullMethod,
+				"namespace":   namespaceLabel(namespace),
+				"retry_after": wait.Round(time.Second),
+			}).Warn("Rejected request over quota")
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// QuotaStreamInterceptor enforces namespace quotas on TranslateStream: the
+// stream counts as one request and each chunk's content against the daily budget.
+func QuotaStreamInterceptor(svc *TranslationService) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if svc.Quotas == nil || !quotaMethods[info.FullMethod] {
+			return handler(srv, ss)
+		}
+		namespace := svc.quotaNamespace(ss.Context(), nil)
+		if wait, err := svc.Quotas.Allow(namespace, 1, 0); err != nil {
+			_ = ss.SetHeader(retryAfterHeader(wait))
+			svc.Logger.WithFields(logrus.Fields{
+				"method":      info.FullMethod,
+				"namespace":   namespaceLabel(namespace),
+				"retry_after": wait.Round(time.Second),
+			}).Warn("Rejected stream over quota")
+			return err
+		}
+		return handler(srv, &quotaStream{ServerStream: ss, svc: svc, namespace: namespace})
+	}
+}
+
+// quotaStream charges each received message to its namespace's character budget.
+type quotaStream struct {
+	grpc.ServerStream
+	svc       *TranslationService
+	namespace string
+}
+
+// RecvMsg receives the next message and charges its characters.
+func (s *quotaStream) RecvMsg(m any) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	if wait, err := s.svc.Quotas.Allow(s.namespace, 0, quotaCost(m)); err != nil {
+		_ = s.SetHeader(retryAfterHeader(wait))
+		s.svc.Logger.WithFields(logrus.Fields{
+			"namespace":   namespaceLabel(s.namespace),
+			"retry_after": wait.Round(time.Second),
+		}).Warn("Rejected stream message over quota")
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,232 @@
+package service
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// redisTimeout bounds dialing and each command round trip.
+const redisTimeout = 5 * time.Second
+
+// redisError is an error reply from the Redis server. The connection is
+// still usable after one.
+type redisError string
+
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+// redisClient is a minimal Redis client (RESP2) covering what the job store
+// needs: one connection, one command at a time, redialled after a network
+// error. The server can be Redis or anything that speaks its protocol and
+// runs Lua scripts (e.g. Valkey, KeyDB).
+type redisClient struct {
+	mu       sync.Mutex
+	addr     string
+	tls      *tls.Config // nil for plain TCP
+	username string
+	password string
+	db       int
+
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// newRedisClient parses a redis:// or rediss:// (TLS) URL of the form
+// redis://[[user]:password@]host[:port][/db]. It doesn't connect.
+func newRedisClient(u *url.URL) (*redisClient, error) {
+	c := &redisClient{addr: u.Host}
+	switch u.Scheme {
+	case "redis":
+	case "rediss":
+		c.tls = &tls.Config{ServerName: u.Hostname(), MinVersion: tls.VersionTLS12}
+	default:
+		return nil, fmt.Errorf("unsupported Redis URL scheme %q (want redis or rediss)", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return nil, errors.New("Redis URL has no host")
+	}
+	if u.Port() == "" {
+		c.addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if u.User != nil {
+		c.username = u.User.Username()
+		c.password, _ = u.User.Password()
+	}
+	if db := strings.Trim(u.Path, "/"); db != "" {
+		n, err := strconv.Atoi(db)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid Redis database %q", db)
+		}
+		c.db = n
+	}
+	return c, nil
+}
+
+// do sends a command and returns its reply: a string (simple string), int64,
+// []byte or nil (bulk string), or []any (array). An error reply is returned
+// as a redisError.
+func (c *redisClient) do(args ...string) (any, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn == nil {
+		if err := c.connect(); err != nil {
+			return nil, err
+		}
+	}
+	reply, err := c.roundTrip(args)
+	var replyErr redisError
+	if err != nil && !errors.As(err, &replyErr) {
+		c.closeLocked()
+	}
+	return reply, err
+}
+
+// connect dials the server, authenticates and selects the database.
+// Callers hold c.mu.
+func (c *redisClient) connect() error {
+	dialer := &net.Dialer{Timeout: redisTimeout}
+	var conn net.Conn
+	var err error
+	if c.tls != nil {
+		conn, err = tls.DialWithDialer(dialer, "tcp", c.addr, c.tls)
+	} else {
+		conn, err = dialer.Dial("tcp", c.addr)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect to Redis at %s: %w", c.addr, err)
+	}
+	c.conn = conn
+	c.reader = bufio.NewReader(conn)
+
+	var setup [][]string
+	switch {
+	case c.username != "" && c.password != "":
+		setup = append(setup, []string{"AUTH", c.username, c.password})
+	case c.password != "":
+		setup = append(setup, []string{"AUTH", c.password})
+	}
+	if c.db != 0 {
+		setup = append(setup, []string{"SELECT", strconv.Itoa(c.db)})
+	}
+	for _, args := range setup {
+		if _, err := c.roundTrip(args); err != nil {
+			c.closeLocked()
+			return fmt.Errorf("failed to set up Redis connection (%s): %w", args[0], err)
+		}
+	}
+	return nil
+}
+
+// roundTrip writes one command and reads its reply. Callers hold c.mu.
+func (c *redisClient) roundTrip(args []string) (any, error) {
+	c.conn.SetDeadline(time.Now().Add(redisTimeout))
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := io.WriteString(c.conn, b.String()); err != nil {
+		return nil, err
+	}
+	return c.readReply()
+}
+
+// readReply reads one RESP2 reply. Callers hold c.mu.
+func (c *redisClient) readReply() (any, error) {
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("redis: empty reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("redis: bad bulk length %q", line)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(c.reader, buf); err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("redis: bad array length %q", line)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		items := make([]any, n)
+		var replyErr error
+		for i := range items {
+			// An error reply inside an array fails the command, but the
+			// rest of the array is still read to keep the connection usable
+			item, err := c.readReply()
+			var itemErr redisError
+			switch {
+			case errors.As(err, &itemErr):
+				if replyErr == nil {
+					replyErr = err
+				}
+			case err != nil:
+				return nil, err
+			}
+			items[i] = item
+		}
+		if replyErr != nil {
+			return nil, replyErr
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("redis: unexpected reply %q", line)
+	}
+}
+
+// eval runs a Lua script with the given keys and arguments.
+func (c *redisClient) eval(script string, keys []string, args ...string) (any, error) {
+	cmd := append([]string{"EVAL", script, strconv.Itoa(len(keys))}, keys...)
+	return c.do(append(cmd, args...)...)
+}
+
+// closeLocked drops the connection. Callers hold c.mu.
+func (c *redisClient) closeLocked() {
+	if c.conn != nil {
+		c.conn.Close()
+		c.conn = nil
+		c.reader = nil
+	}
+}
+
+// close drops the connection; a later command reconnects.
+func (c *redisClient) close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closeLocked()
+	return nil
+}
@@ -0,0 +1,456 @@
+package service
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRedis is a server speaking RESP2: it parses each command and answers
+// with whatever raw reply its handler returns.
+type fakeRedis struct {
+	t        *testing.T
+	listener net.Listener
+
+	mu       sync.Mutex
+	reply    func(args []string) string // Raw RESP reply to a command
+	commands [][]string
+	accepted int
+	conns    []net.Conn
+}
+
+// newFakeRedis starts a fake server, over TLS if config is set.
+func newFakeRedis(t *testing.T, config *tls.Config) *fakeRedis {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config != nil {
+		listener = tls.NewListener(listener, config)
+	}
+	s := &fakeRedis{t: t, listener: listener, reply: func([]string) string { return "+OK\r\n" }}
+	t.Cleanup(func() {
+		listener.Close()
+		s.dropConns()
+	})
+	go s.serve()
+	return s
+}
+
+func (s *fakeRedis) url(scheme, userinfo, path string) *url.URL {
+	_, port, _ := net.SplitHostPort(s.listener.Addr().String())
+	u, err := url.Parse(scheme + "://" + userinfo + "localhost:" + port + path)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return u
+}
+
+func (s *fakeRedis) setReply(reply func(args []string) string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reply = reply
+}
+
+func (s *fakeRedis) received() [][]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([][]string(nil), s.commands...)
+}
+
+// dropConns closes every client connection, as a server restart would.
+func (s *fakeRedis) dropConns() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+	s.conns = nil
+}
+
+func (s *fakeRedis) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.accepted++
+		s.conns = append(s.conns, conn)
+		s.mu.Unlock()
+		go s.handle(conn)
+	}
+}
+
+func (s *fakeRedis) handle(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	for {
+		args, err := readRedisCommand(reader)
+		if err != nil {
+			// Connection and TLS errors are the client's to report
+			if errors.Is(err, errBadRedisCommand) {
+				s.t.Error(err)
+			}
+			return
+		}
+		s.mu.Lock()
+		s.commands = append(s.commands, args)
+		reply := s.reply
+		s.mu.Unlock()
+		if _, err := io.WriteString(conn, reply(args)); err != nil {
+			return
+		}
+	}
+}
+
+// errBadRedisCommand is a command the fake server can't parse.
+var errBadRedisCommand = errors.New("bad command from client")
+
+// readRedisCommand reads a command: an array of bulk strings.
+func readRedisCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(line, "*"), "\r\n"))
+	if !strings.HasPrefix(line, "*") || !strings.HasSuffix(line, "\r\n") || err != nil || n < 1 {
+		return nil, fmt.Errorf("%w: not an array: %q", errBadRedisCommand, line)
+	}
+	args := make([]string, n)
+	for i := range args {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(line, "$"), "\r\n"))
+		if !strings.HasPrefix(line, "$") || err != nil || size < 0 {
+			return nil, fmt.Errorf("%w: argument isn't a bulk string: %q", errBadRedisCommand, line)
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, buf); err != nil {
+			return nil, err
+		}
+		if string(buf[size:]) != "\r\n" {
+			return nil, fmt.Errorf("%w: bulk string of %d bytes not followed by CRLF: %q", errBadRedisCommand, size, buf)
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+// testCertificate returns a self-signed server certificate for names and a
+// pool trusting it.
+func testCertificate(t *testing.T, names ...string) (tls.Certificate, *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: names[0]},
+		DNSNames:     names,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, pool
+}
+
+func TestRedisReplies(t *testing.T) {
+	tests := []struct {
+		name  string
+		reply string
+		want  any
+		err   string // Substring of the error; "" for none
+		// redisErr is set for error replies, which keep the connection
+		redisErr bool
+	}{
+		{name: "simple string", reply: "+OK\r\n", want: "OK"},
+		{name: "integer", reply: ":-42\r\n", want: int64(-42)},
+		{name: "bulk string", reply: "$5\r\nhello\r\n", want: []byte("hello")},
+		{name: "bulk string with CRLF", reply: "$8\r\na\r\n$1\r\nb\r\n", want: []byte("a\r\n$1\r\nb")},
+		{name: "empty bulk string", reply: "$0\r\n\r\n", want: []byte{}},
+		{name: "nil bulk string", reply: "$-1\r\n", want: nil},
+		{name: "nil array", reply: "*-1\r\n", want: nil},
+		{name: "empty array", reply: "*0\r\n", want: []any{}},
+		{
+			name:  "array",
+			reply: "*5\r\n+OK\r\n:1\r\n$3\r\nfoo\r\n$-1\r\n*2\r\n:2\r\n$0\r\n\r\n",
+			want:  []any{"OK", int64(1), []byte("foo"), nil, []any{int64(2), []byte{}}},
+		},
+		{name: "error", reply: "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n", err: "WRONGTYPE", redisErr: true},
+		{name: "error in array", reply: "*3\r\n:1\r\n-ERR first\r\n-ERR second\r\n", err: "ERR first", redisErr: true},
+		{name: "unknown type", reply: "?1\r\n", err: "unexpected reply"},
+		{name: "bad integer", reply: ":one\r\n", err: "invalid syntax"},
+		{name: "bad bulk length", reply: "$x\r\n", err: "bad bulk length"},
+		{name: "bad array length", reply: "*x\r\n", err: "bad array length"},
+		{name: "empty reply", reply: "\r\n", err: "empty reply"},
+	}
+	server := newFakeRedis(t, nil)
+	client, err := newRedisClient(server.url("redis", "", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.close()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server.setReply(func([]string) string { return tt.reply })
+			got, err := client.do("GET", "key")
+			if tt.err == "" {
+				if err != nil {
+					t.Fatalf("do() = %v", err)
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("do() = %#v, want %#v", got, tt.want)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("do() = %#v, %v, want an error with %q", got, err, tt.err)
+			}
+			var replyErr redisError
+			if errors.As(err, &replyErr) != tt.redisErr {
+				t.Errorf("do() error %v: is a reply error = %v, want %v", err, !tt.redisErr, tt.redisErr)
+			}
+
+			// An error reply leaves the connection in sync; a protocol error
+			// drops it
+			client.mu.Lock()
+			connected := client.conn != nil
+			client.mu.Unlock()
+			if want := tt.err == "" || tt.redisErr; connected != want {
+				t.Errorf("connected after the reply = %v, want %v", connected, want)
+			}
+			server.setReply(func([]string) string { return "+PONG\r\n" })
+			if got, err := client.do("PING"); err != nil || got != "PONG" {
+				t.Errorf("do(PING) after the reply = %v, %v", got, err)
+			}
+		})
+	}
+}
+
+func TestRedisCommandFraming(t *testing.T) {
+	server := newFakeRedis(t, nil)
+	client, err := newRedisClient(server.url("redis", "", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.close()
+
+	script := "return redis.call('SET', KEYS[1], ARGV[1])\r\n"
+	if _, err := client.eval(script, []string{"job:1"}, "a value\r\nwith CRLF", ""); err != nil {
+		t.Fatalf("eval() = %v", err)
+	}
+	want := [][]string{{"EVAL", script, "1", "job:1", "a value\r\nwith CRLF", ""}}
+	if got := server.received(); !reflect.DeepEqual(got, want) {
+		t.Errorf("server got %q, want %q", got, want)
+	}
+}
+
+func TestRedisAuth(t *testing.T) {
+	tests := []struct {
+		name     string
+		userinfo string
+		path     string
+		setup    [][]string
+	}{
+		{name: "none", setup: nil},
+		{name: "password", userinfo: ":s3cret@", setup: [][]string{{"AUTH", "s3cret"}}},
+		{name: "user and password", userinfo: "iskoces:s3cret@", setup: [][]string{{"AUTH", "iskoces", "s3cret"}}},
+		{name: "database", userinfo: ":s3cret@", path: "/3", setup: [][]string{{"AUTH", "s3cret"}, {"SELECT", "3"}}},
+		{name: "database 0", path: "/0", setup: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeRedis(t, nil)
+			client, err := newRedisClient(server.url("redis", tt.userinfo, tt.path))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer client.close()
+			if _, err := client.do("PING"); err != nil {
+				t.Fatalf("do() = %v", err)
+			}
+			want := append(tt.setup, []string{"PING"})
+			if got := server.received(); !reflect.DeepEqual(got, want) {
+				t.Errorf("server got %q, want %q", got, want)
+			}
+		})
+	}
+
+	t.Run("rejected", func(t *testing.T) {
+		server := newFakeRedis(t, nil)
+		server.setReply(func(args []string) string {
+			if args[0] == "AUTH" {
+				return "-WRONGPASS invalid username-password pair or user is disabled.\r\n"
+			}
+			return "+PONG\r\n"
+		})
+		client, err := newRedisClient(server.url("redis", "iskoces:wrong@", ""))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer client.close()
+		_, err = client.do("PING")
+		if err == nil || !strings.Contains(err.Error(), "AUTH") || !strings.Contains(err.Error(), "WRONGPASS") {
+			t.Errorf("do() = %v, want the AUTH failure", err)
+		}
+		if got := server.received(); len(got) != 1 {
+			t.Errorf("server got %q after a failed AUTH, want only the AUTH", got)
+		}
+	})
+}
+
+func TestRedisTLS(t *testing.T) {
+	cert, pool := testCertificate(t, "localhost")
+	server := newFakeRedis(t, &tls.Config{Certificates: []tls.Certificate{cert}})
+
+	client, err := newRedisClient(server.url("rediss", ":s3cret@", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.close()
+	if client.tls == nil || client.tls.ServerName != "localhost" {
+		t.Fatalf("TLS config = %+v, want server name localhost", client.tls)
+	}
+
+	// The fake's certificate isn't trusted by the system roots
+	if _, err := client.do("PING"); err == nil {
+		t.Fatal("do() with an untrusted certificate succeeded")
+	}
+	client.tls.RootCAs = pool
+	if _, err := client.do("PING"); err != nil {
+		t.Fatalf("do() over TLS = %v", err)
+	}
+	want := [][]string{{"AUTH", "s3cret"}, {"PING"}}
+	if got := server.received(); !reflect.DeepEqual(got, want) {
+		t.Errorf("server got %q, want %q", got, want)
+	}
+
+	t.Run("wrong name", func(t *testing.T) {
+		cert, pool := testCertificate(t, "redis.example.com")
+		server := newFakeRedis(t, &tls.Config{Certificates: []tls.Certificate{cert}})
+		client, err := newRedisClient(server.url("rediss", "", ""))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer client.close()
+		client.tls.RootCAs = pool
+		if _, err := client.do("PING"); err == nil {
+			t.Error("do() with a certificate for another host succeeded")
+		}
+	})
+	t.Run("plain client", func(t *testing.T) {
+		client, err := newRedisClient(server.url("redis", "", ""))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer client.close()
+		if _, err := client.do("PING"); err == nil {
+			t.Error("do() without TLS against a TLS server succeeded")
+		}
+	})
+}
+
+func TestRedisReconnect(t *testing.T) {
+	server := newFakeRedis(t, nil)
+	client, err := newRedisClient(server.url("redis", ":s3cret@", "/2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.close()
+	if _, err := client.do("PING"); err != nil {
+		t.Fatalf("do() = %v", err)
+	}
+
+	// The server restarts: the command on the dead connection fails, and
+	// the next one dials again and authenticates
+	server.dropConns()
+	if _, err := client.do("PING"); err == nil {
+		t.Error("do() on a closed connection succeeded")
+	}
+	if _, err := client.do("PING"); err != nil {
+		t.Fatalf("do() after the server restarted = %v", err)
+	}
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if server.accepted != 2 {
+		t.Errorf("server accepted %d connections, want 2", server.accepted)
+	}
+	want := [][]string{
+		{"AUTH", "s3cret"}, {"SELECT", "2"}, {"PING"},
+		{"AUTH", "s3cret"}, {"SELECT", "2"}, {"PING"},
+	}
+	if !reflect.DeepEqual(server.commands, want) {
+		t.Errorf("server got %q, want %q", server.commands, want)
+	}
+}
+
+func TestNewRedisClient(t *testing.T) {
+	tests := []struct {
+		url  string
+		addr string
+		db   int
+		err  bool
+	}{
+		{url: "redis://redis", addr: "redis:6379"},
+		{url: "redis://redis:6380/5", addr: "redis:6380", db: 5},
+		{url: "rediss://[::1]", addr: "[::1]:6379"},
+		{url: "http://redis", err: true},
+		{url: "redis:///0", err: true},
+		{url: "redis://redis/x", err: true},
+		{url: "redis://redis/-1", err: true},
+	}
+	for _, tt := range tests {
+		u, err := url.Parse(tt.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		client, err := newRedisClient(u)
+		if tt.err {
+			if err == nil {
+				t.Errorf("newRedisClient(%s) succeeded", tt.url)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("newRedisClient(%s) = %v", tt.url, err)
+			continue
+		}
+		if client.addr != tt.addr || client.db != tt.db {
+			t.Errorf("newRedisClient(%s) addr %s db %d, want %s db %d", tt.url, client.addr, client.db, tt.addr, tt.db)
+		}
+	}
+}
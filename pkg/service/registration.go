@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ClientIDMetadataKey is the gRPC metadata key carrying the client_id
+// returned by RegisterClient.
+const ClientIDMetadataKey = "x-client-id"
+
+// ReasonUnregisteredClient is the ErrorInfo reason for calls rejected because
+// the caller isn't registered.
+const ReasonUnregisteredClient = "UNREGISTERED_CLIENT"
+
+// registrationRequiredMethods are the RPCs that require a registered client
+// when registration is enforced.
+var registrationRequiredMethods = map[string]bool{
+	"/nanabush.v1.TranslationService/Translate":            true,
+	"/nanabush.v1.TranslationService/TranslateStream":      true,
+	"/nanabush.v1.TranslationService/CheckTitle":           true,
+	"/nanabush.v1.TranslationService/TranslateDocumentSet": true,
+	"/nanabush.v2.TranslationService/SubmitTranslation":    true,
+	"/nanabush.v2.TranslationService/SubmitBatch":          true,
+	"/nanabush.v2.TranslationService/Translate":            true,
+}
+
+// IsRegistered reports whether clientID belongs to a currently registered client.
+func (s *TranslationService) IsRegistered(clientID string) bool {
+	if clientID == "" {
+		return false
+	}
+	s.clientsMutex.RLock()
+	defer s.clientsMutex.RUnlock()
+	_, ok := s.clients[clientID]
+	return ok
+}
+
+// callerClientID returns the client_id the call carries as x-client-id
+// metadata, or "".
+func callerClientID(ctx context.Context) string {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(ClientIDMetadataKey); len(values) > 0 {
+			return values[0]
+		}
+	}
+	return ""
+}
+
+// checkRegistration returns an Unauthenticated error unless ctx carries the
+// client_id of a registered client.
+func (s *TranslationService) checkRegistration(ctx context.Context, method string) error {
+	clientID := callerClientID(ctx)
+	if s.IsRegistered(clientID) {
+		return nil
+	}
+
+	s.log(ctx).WithFields(logrus.Fields{
+		"method":    method,
+		"client_id": clientID,
+	}).Warn("Rejected call from unregistered client")
+
+	return unregisteredError(clientID)
+}
+
+// unregisteredError returns the Unauthenticated error for a missing or
+// unknown client_id.
+func unregisteredError(clientID string) error {
+	message := "client is not registered or its registration expired; call RegisterClient and send the client_id as " + ClientIDMetadataKey + " metadata"
+	if clientID == "" {
+		message = "missing " + ClientIDMetadataKey + " metadata; call RegisterClient first"
+	}
+	st := status.New(codes.Unauthenticated, message)
+	if detailed, err := st.WithDetails(&errdetails.ErrorInfo{
+		Reason: ReasonUnregisteredClient,
+		Domain: errorDomain,
+		Metadata: map[string]string{
+			"retryable":    "false",
+			"metadata_key": ClientIDMetadataKey,
+		},
+	}); err == nil {
+		st = detailed
+	}
+	return st.Err()
+}
+
+// RegistrationUnaryInterceptor rejects Translate and CheckTitle calls from
+// clients that haven't registered.
+func RegistrationUnaryInterceptor(svc *TranslationService) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if registrationRequiredMethods[info.FullMethod] {
+			if err := svc.checkRegistration(ctx, info.FullMethod); err != nil {
+				return nil, err
+			}
+		}
+		return handler(ctx, req)
+	}
+}
+
+// RegistrationStreamInterceptor rejects TranslateStream calls from clients
+// that haven't registered.
+func RegistrationStreamInterceptor(svc *TranslationService) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if registrationRequiredMethods[info.FullMethod] {
+			if err := svc.checkRegistration(ss.Context(), info.FullMethod); err != nil {
+				return err
+			}
+		}
+		return handler(srv, ss)
+	}
+}
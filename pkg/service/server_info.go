@@ -0,0 +1,159 @@
+package service
+
+import (
+	"context"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	nanabushv1 "github.com/dasmlab/iskoces/pkg/proto/v1"
+	"github.com/dasmlab/iskoces/pkg/translate"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// AsyncThresholdBytes is the markdown size above which Translate queues
+	// the document as an async job instead of translating inline.
+	AsyncThresholdBytes = 10 * 1024
+
+	// DefaultMaxDocumentBytes is the default maximum request size (the gRPC
+	// default receive limit).
+	DefaultMaxDocumentBytes = 4 * 1024 * 1024
+)
+
+// ServerInfo describes the deployment, as reported by GetServerInfo.
+type ServerInfo struct {
+	// Version is the server build version (e.g., set via -ldflags).
+	Version string
+	// Engines lists the active translation engine(s).
+	Engines []string
+	// MaxDocumentBytes is the largest request message the server accepts.
+	MaxDocumentBytes int
+	// MaxRecvMessageBytes and MaxSendMessageBytes are the transport message
+	// limits (0 send means unlimited).
+	MaxRecvMessageBytes int
+	MaxSendMessageBytes int
+	// Compressors lists the registered gRPC compressors.
+	Compressors []string
+	// V2API is set when the v2 TranslationService is served alongside v1.
+	V2API bool
+	// RequireRegistration is set when translation calls require a registered client_id.
+	RequireRegistration bool
+}
+
+// GetServerInfo reports server version, engine, limits, and supported features.
+func (s *TranslationService) GetServerInfo(ctx context.Context, req *nanabushv1.GetServerInfoRequest) (*nanabushv1.GetServerInfoResponse, error) {
+	s.log(ctx).Debug("GetServerInfo request received")
+
+	version := s.Info.Version
+	if version == "" {
+		version = "dev"
+	}
+	maxDocumentBytes := s.Info.MaxDocumentBytes
+	if maxDocumentBytes <= 0 {
+		maxDocumentBytes = DefaultMaxDocumentBytes
+	}
+
+	maxRecvMessageBytes := s.Info.MaxRecvMessageBytes
+	if maxRecvMessageBytes <= 0 {
+		maxRecvMessageBytes = maxDocumentBytes
+	}
+
+	workerPoolSize := s.workerCount()
+	backend := translate.Backend(s.Translator)
+	autoscaling, pinnedWorkers := false, false
+	if pool, ok := backend.(*translate.WorkerPool); ok {
+		min, max := pool.Bounds()
+		autoscaling = min < max
+		pinnedWorkers = len(pool.PinnedPairs()) > 0
+	}
+	_, backendDetection := backend.(translate.LanguageDetector)
+	_, backendBatch := backend.(translate.BatchTranslator)
+
+	resp := &nanabushv1.GetServerInfoResponse{
+		Version:          version,
+		Engines:          s.Info.Engines,
+		WorkerPoolSize:   int32(workerPoolSize),
+		MaxDocumentBytes: int64(maxDocumentBytes),
+		SupportedPrimitives: []nanabushv1.PrimitiveType{
+			nanabushv1.PrimitiveType_PRIMITIVE_TITLE,
+			nanabushv1.PrimitiveType_PRIMITIVE_DOC_TRANSLATE,
+		},
+		Features: map[string]bool{
+			"streaming":                  true,
+			"async_jobs":                 s.JobQueue != nil && !s.AsyncDisabled,
+			"job_cancellation":           s.JobQueue != nil,
+			"batch_translate":            true,
+			"backend_batch":              backendBatch,
+			"language_detection":         true,
+			"backend_language_detection": backendDetection,
+			"localize_formats":           s.Localizer != nil,
+			"priority":                   true,
+			"deadline_admission":         s.Estimator != nil,
+			"idempotent_retries":         true,
+			"heartbeat_stream":           true,
+			"config_watch":               true,
+			"namespace_quotas":           s.Quotas != nil,
+			"compression":                len(s.Info.Compressors) > 0,
+			"v2_api":                     s.Info.V2API,
+			"document_sets":              true,
+			"feedback":                   s.Feedback != nil,
+			"client_labels":              s.LabelSchema != nil,
+			"drain_mode":                 true,
+			"longrunning_operations":     true,
+			"worker_autoscaling":         autoscaling,
+			"pinned_workers":             pinnedWorkers,
+			"registration_required":      s.Info.RequireRegistration,
+		},
+		MaxRecvMessageBytes: int64(maxRecvMessageBytes),
+		MaxSendMessageBytes: int64(s.Info.MaxSendMessageBytes),
+		Compressors:         s.Info.Compressors,
+		AsyncThresholdBytes: AsyncThresholdBytes,
+		MaxBatchTexts:       MaxBatchTranslateTexts,
+		DefaultStreamWindow: defaultStreamWindow,
+		MaxStreamWindow:     maxStreamWindow,
+	}
+	if s.Health != nil {
+		resp.EngineHealth = engineHealthProto(s.Health.EngineStatus())
+	}
+
+	s.log(ctx).WithFields(logrus.Fields{
+		"version":          resp.Version,
+		"engines":          resp.Engines,
+		"worker_pool_size": resp.WorkerPoolSize,
+	}).Debug("GetServerInfo response")
+
+	return resp, nil
+}
+
+// engineHealthProto converts the engine's health check history for
+// GetServerInfo.
+func engineHealthProto(st EngineStatus) *nanabushv1.EngineHealth {
+	h := &nanabushv1.EngineHealth{
+		Healthy:              st.Healthy,
+		ConsecutiveFailures:  int32(st.ConsecutiveFailures),
+		ConsecutiveSuccesses: int32(st.ConsecutiveSuccesses),
+		Checks:               int32(st.Checks),
+		SuccessRatio:         st.SuccessRatio,
+		LastError:            st.LastError,
+		Transitions:          int32(st.Transitions),
+		Flapping:             st.Flapping,
+	}
+	if st.Checked {
+		h.CheckedAt = timestamppb.New(st.CheckedAt)
+	}
+	if !st.LastErrorAt.IsZero() {
+		h.LastErrorAt = timestamppb.New(st.LastErrorAt)
+	}
+	if !st.LastSuccessAt.IsZero() {
+		h.LastSuccessAt = timestamppb.New(st.LastSuccessAt)
+	}
+	return h
+}
+
+// workerCount returns the translator's worker pool size, or 0 if it doesn't use one.
+func (s *TranslationService) workerCount() int {
+	if pool, ok := translate.Backend(s.Translator).(*translate.WorkerPool); ok {
+		return pool.Size()
+	}
+	return 0
+}
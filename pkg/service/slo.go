@@ -0,0 +1,405 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	sloEventsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "iskoces_slo_events_total",
+			Help: "Total number of calls counted by each service level objective, by result (good, bad)",
+		},
+		[]string{"slo", "result"},
+	)
+
+	sloBurnRate = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "iskoces_slo_burn_rate",
+			Help: "Rate at which each service level objective's error budget is being spent over the window (1 = exactly on target)",
+		},
+		[]string{"slo", "window"},
+	)
+
+	sloErrorBudgetRemaining = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "iskoces_slo_error_budget_remaining",
+			Help: "Fraction of each service level objective's error budget left over the longest window (negative once overspent)",
+		},
+		[]string{"slo"},
+	)
+
+	sloTarget = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "iskoces_slo_target",
+			Help: "Fraction of calls each service level objective wants to be good",
+		},
+		[]string{"slo"},
+	)
+)
+
+// Objective types.
+const (
+	SLOTypeLatency   = "latency"    // Successful calls faster than a threshold
+	SLOTypeErrorRate = "error_rate" // Calls without a server-side failure
+)
+
+// Objective statuses, from the multiwindow burn-rate alerts of the Google SRE
+// workbook: a fast burn spends 2% of a 30-day budget in an hour, a slow burn
+// 5% in six hours.
+const (
+	SLOStatusOK       = "ok"
+	SLOStatusWarning  = "warning"  // Slow burn: over 6x in the last 6h and 30m
+	SLOStatusCritical = "critical" // Fast burn: over 14.4x in the last 1h and 5m
+	SLOStatusNoData   = "no_data"  // No calls in the longest window
+)
+
+// sloWindow is a window burn rates are computed over.
+type sloWindow struct {
+	label    string
+	duration time.Duration
+}
+
+// sloWindows are the windows burn rates are computed over, shortest first.
+var sloWindows = []sloWindow{
+	{"5m", 5 * time.Minute},
+	{"30m", 30 * time.Minute},
+	{"1h", time.Hour},
+	{"6h", 6 * time.Hour},
+}
+
+const (
+	// sloBucketWidth is the resolution calls are counted at.
+	sloBucketWidth = 10 * time.Second
+
+	// sloRefreshInterval is how often the burn-rate gauges are updated.
+	sloRefreshInterval = 15 * time.Second
+)
+
+// SLOObjective is a service level objective in the SLO file.
+type SLOObjective struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// Methods are the calls the objective covers: full gRPC methods
+	// (/package.Service/Method or package.Service/Method) or method names
+	// of any service. Empty covers every call.
+	Methods []string `json:"methods,omitempty"`
+	// LatencySeconds makes a latency objective: successful calls finishing
+	// within it are good. Without it, calls are good unless they failed on
+	// the server's side (INTERNAL, UNKNOWN, DATA_LOSS, UNAVAILABLE,
+	// DEADLINE_EXCEEDED).
+	LatencySeconds float64 `json:"latency_seconds,omitempty"`
+	// Target is the fraction of calls that should be good, e.g. 0.95 for
+	// "p95 under LatencySeconds".
+	Target float64 `json:"target"`
+}
+
+// SLOConfig is the SLO file format.
+type SLOConfig struct {
+	Objectives []SLOObjective `json:"objectives"`
+}
+
+// DefaultSLOConfig returns the built-in objectives, used without an SLO
+// file: title checks within 2s and synchronous translations within 10s at
+// p95, and 99.5% of translation calls without server-side failures.
+func DefaultSLOConfig() SLOConfig {
+	translation := make([]string, 0, len(quotaMethods))
+	for method := range quotaMethods {
+		translation = append(translation, method)
+	}
+	sort.Strings(translation)
+	return SLOConfig{Objectives: []SLOObjective{
+		{
+			Name:           "title-latency",
+			Description:    "95% of title checks answer within 2s",
+			Methods:        []string{"/nanabush.v1.TranslationService/CheckTitle"},
+			LatencySeconds: 2,
+			Target:         0.95,
+		},
+		{
+			Name:           "translate-latency",
+			Description:    "95% of synchronous translations answer within 10s",
+			Methods:        []string{"/nanabush.v1.TranslationService/Translate", "/nanabush.v2.TranslationService/Translate"},
+			LatencySeconds: 10,
+			Target:         0.95,
+		},
+		{
+			Name:        "translation-availability",
+			Description: "99.5% of translation calls don't fail on the server's side",
+			Methods:     translation,
+			Target:      0.995,
+		},
+	}}
+}
+
+// LoadSLOConfig reads a JSON SLO file.
+func LoadSLOConfig(path string) (SLOConfig, error) {
+	var cfg SLOConfig
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, fmt.Errorf("failed to read SLO file: %w", err)
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("failed to parse SLO file %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// sloBucket counts the calls of one sloBucketWidth interval.
+type sloBucket struct {
+	index     int64 // Interval number since the epoch
+	good, bad int64
+}
+
+// trackedObjective is an objective with its recent calls.
+type trackedObjective struct {
+	SLOObjective
+	latency time.Duration
+	buckets []sloBucket // Ring covering the longest window
+}
+
+// SLOTracker counts calls against service level objectives and reports
+// their burn rates, as metrics and for the /slo endpoint.
+type SLOTracker struct {
+	mu         sync.Mutex
+	objectives []*trackedObjective
+	stop       chan struct{}
+	stopOnce   sync.Once
+}
+
+// NewSLOTracker creates a tracker for cfg's objectives.
+func NewSLOTracker(cfg SLOConfig) (*SLOTracker, error) {
+	longest := sloWindows[len(sloWindows)-1].duration
+	t := &SLOTracker{stop: make(chan struct{})}
+	seen := make(map[string]bool)
+	for _, o := range cfg.Objectives {
+		switch {
+		case o.Name == "":
+			return nil, fmt.Errorf("SLO without a name")
+		case seen[o.Name]:
+			return nil, fmt.Errorf("SLO %q is defined twice", o.Name)
+		case o.Target <= 0 || o.Target >= 1:
+			return nil, fmt.Errorf("SLO %q: target must be between 0 and 1 (exclusive), got %v", o.Name, o.Target)
+		case o.LatencySeconds < 0:
+			return nil, fmt.Errorf("SLO %q: latency_seconds must not be negative", o.Name)
+		}
+		seen[o.Name] = true
+		t.objectives = append(t.objectives, &trackedObjective{
+			SLOObjective: o,
+			latency:      time.Duration(o.LatencySeconds * float64(time.Second)),
+			buckets:      make([]sloBucket, longest/sloBucketWidth),
+		})
+		sloTarget.WithLabelValues(o.Name).Set(o.Target)
+	}
+	return t, nil
+}
+
+// covers reports whether the objective counts calls to fullMethod.
+func (o *trackedObjective) covers(fullMethod string) bool {
+	if len(o.Methods) == 0 {
+		return true
+	}
+	_, method := splitMethod(fullMethod)
+	for _, m := range o.Methods {
+		switch {
+		case strings.HasPrefix(m, "/"):
+			if m == fullMethod {
+				return true
+			}
+		case strings.Contains(m, "/"):
+			if "/"+m == fullMethod {
+				return true
+			}
+		case m == method:
+			return true
+		}
+	}
+	return false
+}
+
+// good reports whether a call that took d and ended with code meets the
+// objective; ok is false if the objective doesn't count it.
+func (o *trackedObjective) good(d time.Duration, code codes.Code) (good, ok bool) {
+	if o.latency > 0 {
+		// Failed calls count against the error-rate objectives
+		return d <= o.latency, code == codes.OK
+	}
+	switch code {
+	case codes.Internal, codes.Unknown, codes.DataLoss, codes.Unavailable, codes.DeadlineExceeded:
+		return false, true
+	}
+	return true, true
+}
+
+// observe counts a call to fullMethod that took d and ended with err.
+func (t *SLOTracker) observe(fullMethod string, d time.Duration, err error) {
+	code := status.Code(err)
+	index := time.Now().UnixNano() / int64(sloBucketWidth)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, o := range t.objectives {
+		if !o.covers(fullMethod) {
+			continue
+		}
+		good, ok := o.good(d, code)
+		if !ok {
+			continue
+		}
+		b := &o.buckets[index%int64(len(o.buckets))]
+		if b.index != index {
+			*b = sloBucket{index: index}
+		}
+		if good {
+			b.good++
+			sloEventsTotal.WithLabelValues(o.Name, "good").Inc()
+		} else {
+			b.bad++
+			sloEventsTotal.WithLabelValues(o.Name, "bad").Inc()
+		}
+	}
+}
+
+// SLOWindowStatus is an objective's record over one window.
+type SLOWindowStatus struct {
+	Window    string  `json:"window"`
+	Calls     int64   `json:"calls"`
+	Good      int64   `json:"good"`
+	GoodRatio float64 `json:"good_ratio"` // 1 without calls
+	BurnRate  float64 `json:"burn_rate"`  // Bad ratio over the allowed bad ratio
+}
+
+// SLOStatus is whether an objective is being met.
+type SLOStatus struct {
+	SLOObjective
+	Type   string `json:"type"`
+	Status string `json:"status"`
+	// ErrorBudgetRemaining is the fraction of the error budget left over
+	// the longest window; negative once overspent.
+	ErrorBudgetRemaining float64           `json:"error_budget_remaining"`
+	Windows              []SLOWindowStatus `json:"windows"`
+}
+
+// Status reports how every objective is doing over each window.
+func (t *SLOTracker) Status() []SLOStatus {
+	index := time.Now().UnixNano() / int64(sloBucketWidth)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	statuses := make([]SLOStatus, 0, len(t.objectives))
+	for _, o := range t.objectives {
+		st := SLOStatus{SLOObjective: o.SLOObjective, Type: SLOTypeErrorRate}
+		if o.latency > 0 {
+			st.Type = SLOTypeLatency
+		}
+		burn := make(map[string]float64, len(sloWindows))
+		for _, w := range sloWindows {
+			ws := o.window(index, w)
+			burn[w.label] = ws.BurnRate
+			st.Windows = append(st.Windows, ws)
+		}
+		longest := st.Windows[len(st.Windows)-1]
+		st.ErrorBudgetRemaining = 1 - longest.BurnRate
+		switch {
+		case longest.Calls == 0:
+			st.Status = SLOStatusNoData
+		case burn["1h"] > 14.4 && burn["5m"] > 14.4:
+			st.Status = SLOStatusCritical
+		case burn["6h"] > 6 && burn["30m"] > 6:
+			st.Status = SLOStatusWarning
+		default:
+			st.Status = SLOStatusOK
+		}
+		statuses = append(statuses, st)
+	}
+	return statuses
+}
+
+// window sums the objective's calls over w, up to the interval index.
+// Callers hold t.mu.
+func (o *trackedObjective) window(index int64, w sloWindow) SLOWindowStatus {
+	ws := SLOWindowStatus{Window: w.label, GoodRatio: 1}
+	oldest := index - int64(w.duration/sloBucketWidth)
+	var bad int64
+	for _, b := range o.buckets {
+		if b.index > oldest && b.index <= index {
+			ws.Good += b.good
+			bad += b.bad
+		}
+	}
+	ws.Calls = ws.Good + bad
+	if ws.Calls > 0 {
+		badRatio := float64(bad) / float64(ws.Calls)
+		ws.GoodRatio = 1 - badRatio
+		ws.BurnRate = badRatio / (1 - o.Target)
+	}
+	return ws
+}
+
+// refresh updates the burn-rate and error budget gauges.
+func (t *SLOTracker) refresh() {
+	for _, st := range t.Status() {
+		for _, ws := range st.Windows {
+			sloBurnRate.WithLabelValues(st.Name, ws.Window).Set(ws.BurnRate)
+		}
+		sloErrorBudgetRemaining.WithLabelValues(st.Name).Set(st.ErrorBudgetRemaining)
+	}
+}
+
+// Start updates the burn-rate gauges every 15s until Stop is called.
+func (t *SLOTracker) Start() {
+	t.refresh()
+	go func() {
+		ticker := time.NewTicker(sloRefreshInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				t.refresh()
+			case <-t.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops updating the gauges.
+func (t *SLOTracker) Stop() {
+	t.stopOnce.Do(func() { close(t.stop) })
+}
+
+// SLOUnaryInterceptor counts each call against the objectives covering it.
+// It should run outside the recovery interceptor, so calls that panicked
+// count as INTERNAL.
+func SLOUnaryInterceptor(t *SLOTracker) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		t.observe(info.FullMethod, time.Since(start), err)
+		return resp, err
+	}
+}
+
+// SLOStreamInterceptor counts each stream against the objectives covering
+// it, timed until the handler returns.
+func SLOStreamInterceptor(t *SLOTracker) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		err := handler(srv, ss)
+		t.observe(info.FullMethod, time.Since(start), err)
+		return err
+	}
+}
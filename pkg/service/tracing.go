@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/dasmlab/iskoces/pkg/tracing"
+)
+
+// TracingUnaryInterceptor starts a server span for each call, continuing
+// the caller's trace from its traceparent metadata. The span is the parent
+// of the call's translation work and of the async jobs it submits.
+func TracingUnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, span := startCallSpan(ctx, info.FullMethod)
+		resp, err := handler(ctx, req)
+		endCallSpan(span, err)
+		return resp, err
+	}
+}
+
+// TracingStreamInterceptor starts a server span for each stream, as
+// TracingUnaryInterceptor does for calls.
+func TracingStreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, span := startCallSpan(ss.Context(), info.FullMethod)
+		err := handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+		endCallSpan(span, err)
+		return err
+	}
+}
+
+// startCallSpan starts the span of a call to method.
+func startCallSpan(ctx context.Context, method string) (context.Context, *tracing.Span) {
+	if !tracing.Enabled() {
+		return ctx, nil
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(tracing.TraceparentHeader); len(values) > 0 {
+			if parent, ok := tracing.ParseTraceparent(values[0]); ok {
+				ctx = tracing.ContextWithParent(ctx, parent)
+			}
+		}
+	}
+	service, name := splitMethod(method)
+	attrs := []tracing.Attribute{
+		tracing.String("rpc.system", "grpc"),
+		tracing.String("rpc.service", service),
+		tracing.String("rpc.method", name),
+	}
+	if requestID := RequestIDFromContext(ctx); requestID != "" {
+		attrs = append(attrs, tracing.String("request_id", requestID))
+	}
+	return tracing.StartKind(ctx, tracing.SpanKindServer, strings.TrimPrefix(method, "/"), attrs...)
+}
+
+// endCallSpan ends a call's span with the call's status code.
+func endCallSpan(span *tracing.Span, err error) {
+	span.SetAttributes(tracing.Int("rpc.grpc.status_code", int(status.Code(err))))
+	span.RecordError(err)
+	span.End()
+}
+
+// contextStream is a stream with a different context.
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context returns the stream's context.
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
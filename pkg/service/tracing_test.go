@@ -0,0 +1,153 @@
+package service
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/dasmlab/iskoces/pkg/tracing"
+)
+
+// exportedSpan is the part of an OTLP span the tests look at.
+type exportedSpan struct {
+	TraceID      string `json:"traceId"`
+	SpanID       string `json:"spanId"`
+	ParentSpanID string `json:"parentSpanId"`
+	Name         string `json:"name"`
+}
+
+// traceCollector installs a tracing exporter sampling every new trace and
+// returns a function that stops it and returns the spans it exported.
+func traceCollector(t *testing.T) func() []exportedSpan {
+	t.Helper()
+	var mu sync.Mutex
+	var spans []exportedSpan
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ResourceSpans []struct {
+				ScopeSpans []struct {
+					Spans []exportedSpan `json:"spans"`
+				} `json:"scopeSpans"`
+			} `json:"resourceSpans"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("export isn't JSON: %v", err)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		for _, rs := range req.ResourceSpans {
+			for _, ss := range rs.ScopeSpans {
+				spans = append(spans, ss.Spans...)
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	e, err := tracing.NewExporter(tracing.ExporterConfig{Endpoint: server.URL, SampleRatio: 1}, quietLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracing.SetExporter(e)
+	t.Cleanup(func() {
+		tracing.SetExporter(nil)
+		e.Stop()
+	})
+	return func() []exportedSpan {
+		tracing.SetExporter(nil)
+		e.Stop()
+		mu.Lock()
+		defer mu.Unlock()
+		return spans
+	}
+}
+
+func TestTracingInterceptorContinuesCaller(t *testing.T) {
+	collected := traceCollector(t)
+	const caller = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(tracing.TraceparentHeader, caller))
+
+	var inner tracing.SpanContext
+	info := &grpc.UnaryServerInfo{FullMethod: "/iskoces.v1.TranslationService/Translate"}
+	_, err := TracingUnaryInterceptor()(ctx, nil, info, func(ctx context.Context, req any) (any, error) {
+		inner = tracing.SpanContextFromContext(ctx)
+		_, span := tracing.Start(ctx, "work")
+		span.End()
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := hex.EncodeToString(inner.TraceID[:]); got != "4bf92f3577b34da6a3ce929d0e0e4736" || !inner.Sampled {
+		t.Errorf("handler span = %+v, want the caller's sampled trace", inner)
+	}
+
+	spans := collected()
+	if len(spans) != 2 {
+		t.Fatalf("exported %d spans, want 2", len(spans))
+	}
+	work, call := spans[0], spans[1]
+	if call.Name != "iskoces.v1.TranslationService/Translate" || call.ParentSpanID != "00f067aa0ba902b7" || call.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("call span = %+v, want a child of the caller's span", call)
+	}
+	if call.SpanID != hex.EncodeToString(inner.SpanID[:]) {
+		t.Errorf("call span ID = %s, handler saw %x", call.SpanID, inner.SpanID)
+	}
+	if work.ParentSpanID != call.SpanID || work.TraceID != call.TraceID {
+		t.Errorf("work span = %+v, want a child of the call span", work)
+	}
+}
+
+func TestTracingInterceptorBadTraceparent(t *testing.T) {
+	collected := traceCollector(t)
+	// Uppercase hex isn't valid, so the call starts a new trace
+	ctx := metadata.NewIncomingContext(context.Background(),
+		metadata.Pairs(tracing.TraceparentHeader, "00-4BF92F3577B34DA6A3CE929D0E0E4736-00F067AA0BA902B7-01"))
+	info := &grpc.UnaryServerInfo{FullMethod: "/iskoces.v1.TranslationService/Translate"}
+	TracingUnaryInterceptor()(ctx, nil, info, func(ctx context.Context, req any) (any, error) {
+		return nil, nil
+	})
+
+	spans := collected()
+	if len(spans) != 1 {
+		t.Fatalf("exported %d spans, want 1", len(spans))
+	}
+	if spans[0].ParentSpanID != "" || spans[0].TraceID == "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("span = %+v, want the root of a new trace", spans[0])
+	}
+}
+
+func TestTracingInterceptorUnsampledCaller(t *testing.T) {
+	collected := traceCollector(t)
+	ctx := metadata.NewIncomingContext(context.Background(),
+		metadata.Pairs(tracing.TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00"))
+	info := &grpc.UnaryServerInfo{FullMethod: "/iskoces.v1.TranslationService/Translate"}
+	TracingUnaryInterceptor()(ctx, nil, info, func(ctx context.Context, req any) (any, error) {
+		if sc := tracing.SpanContextFromContext(ctx); sc.Sampled {
+			t.Error("handler span is sampled, but the caller's isn't")
+		}
+		return nil, nil
+	})
+	if spans := collected(); len(spans) != 0 {
+		t.Errorf("exported %d spans of an unsampled trace", len(spans))
+	}
+}
+
+// Tracing off leaves the context alone.
+func TestTracingInterceptorDisabled(t *testing.T) {
+	ctx := context.Background()
+	info := &grpc.UnaryServerInfo{FullMethod: "/iskoces.v1.TranslationService/Translate"}
+	TracingUnaryInterceptor()(ctx, nil, info, func(got context.Context, req any) (any, error) {
+		if got != ctx {
+			t.Error("handler context changed with tracing disabled")
+		}
+		return nil, nil
+	})
+}
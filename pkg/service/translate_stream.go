@@ -0,0 +1,364 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	nanabushv1 "github.com/dasmlab/iskoces/pkg/proto/v1"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// defaultStreamWindow is used when the client doesn't request a window size.
+	defaultStreamWindow = 8
+
+	// maxStreamWindow caps the window a client may request.
+	maxStreamWindow = 64
+)
+
+// chunkKey identifies a chunk within a stream.
+type chunkKey struct {
+	orderingKey string
+	index       int32
+}
+
+// streamResult is a translated (or failed) chunk waiting to be emitted.
+type streamResult struct {
+	chunkKey
+	content string
+	err     error
+}
+
+// streamSession holds the state of a single TranslateStream call.
+// All fields except results are owned by the goroutine running TranslateStream.
+type streamSession struct {
+	service *TranslationService
+	stream  nanabushv1.TranslationService_TranslateStreamServer
+	ctx     context.Context
+
+	jobID      string
+	sourceLang string
+	targetLang string
+	window     int
+
+	// results receives finished translations; buffered to window so
+	// translation goroutines never block.
+	results chan streamResult
+
+	inflight map[chunkKey]bool
+	ready    map[chunkKey]streamResult
+	next     map[string]int32
+
+	emitted int
+	failed  int
+}
+
+// TranslateStream translates a stream of document chunks.
+//
+// The first message must carry source_language and target_language and may
+// request a window_size. The server replies with a control message echoing the
+// negotiated window before any translated chunk. From then on the client may
+// have at most window chunks outstanding (sent but not yet received back);
+// exceeding it fails the stream with RESOURCE_EXHAUSTED. This bounds what the
+// server buffers for a slow client: it can't send more until it has drained
+// translated chunks.
+//
+// Chunks are translated concurrently and may finish out of order. Each one is
+// held until every earlier chunk_index for its ordering_key has been emitted.
+func (s *TranslationService) TranslateStream(stream nanabushv1.TranslationService_TranslateStreamServer) error {
+	s.log(stream.Context()).Info("TranslateStream request started")
+
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+
+	first, err := stream.Recv()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		s.log(stream.Context()).WithError(err).Error("TranslateStream receive error")
+		return recvError(err)
+	}
+	if first.SourceLanguage == "" || first.TargetLanguage == "" {
+		s.log(stream.Context()).Error("TranslateStream: languages missing from first chunk")
+		return status.Error(codes.InvalidArgument, "source_language and target_language are required on the first chunk")
+	}
+
+	window := negotiateStreamWindow(first.WindowSize)
+	ss := &streamSession{
+		service:    s,
+		stream:     stream,
+		ctx:        ctx,
+		jobID:      first.JobId,
+		sourceLang: s.LanguageMapper.ToBackendCode(first.SourceLanguage),
+		targetLang: s.LanguageMapper.ToBackendCode(first.TargetLanguage),
+		window:     window,
+		results:    make(chan streamResult, window),
+		inflight:   make(map[chunkKey]bool),
+		ready:      make(map[chunkKey]streamResult),
+		next:       make(map[string]int32),
+	}
+
+	s.log(stream.Context()).WithFields(logrus.Fields{
+		"job_id":      ss.jobID,
+		"source_lang": ss.sourceLang,
+		"target_lang": ss.targetLang,
+		"window":      window,
+	}).Info("TranslateStream started")
+
+	// Handshake: tell the client how many chunks it may have outstanding
+	if err := stream.Send(&nanabushv1.TranslateChunk{
+		JobId:      ss.jobID,
+		WindowSize: int32(window),
+	}); err != nil {
+		s.log(stream.Context()).WithError(err).Error("TranslateStream: failed to send handshake")
+		return status.Error(codes.Internal, fmt.Sprintf("failed to send handshake: %v", err))
+	}
+
+	// The first message is a chunk only if it carries content
+	if first.Content != "" {
+		if err := ss.accept(first); err != nil {
+			return err
+		}
+	}
+
+	var incoming chan *nanabushv1.TranslateChunk
+	recvErr := make(chan error, 1)
+	inputDone := first.IsFinal
+	if !inputDone {
+		incoming = make(chan *nanabushv1.TranslateChunk)
+		go ss.receive(incoming, recvErr)
+	}
+
+	for !inputDone || len(ss.inflight) > 0 || len(ss.ready) > 0 {
+		if inputDone && len(ss.inflight) == 0 {
+			// Everything is translated but gaps in chunk_index are holding some back
+			if err := ss.flushRemaining(); err != nil {
+				return err
+			}
+			break
+		}
+
+		select {
+		case chunk, ok := <-incoming:
+			if !ok {
+				inputDone = true
+				incoming = nil
+				continue
+			}
+			// A content-less final message only marks the end of input
+			if chunk.IsFinal && chunk.Content == "" {
+				continue
+			}
+			if err := ss.accept(chunk); err != nil {
+				return err
+			}
+
+		case err := <-recvErr:
+			s.log(stream.Context()).WithError(err).Error("TranslateStream receive error")
+			return recvError(err)
+
+		case res := <-ss.results:
+			delete(ss.inflight, res.chunkKey)
+			ss.ready[res.chunkKey] = res
+			if err := ss.flush(res.orderingKey); err != nil {
+				return err
+			}
+
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		}
+	}
+
+	if err := stream.Send(&nanabushv1.TranslateChunk{
+		JobId:   ss.jobID,
+		IsFinal: true,
+	}); err != nil {
+		s.log(stream.Context()).WithError(err).Error("TranslateStream: failed to send final chunk")
+		return status.Error(codes.Internal, fmt.Sprintf("failed to send final chunk: %v", err))
+	}
+
+	s.log(stream.Context()).WithFields(logrus.Fields{
+		"job_id":  ss.jobID,
+		"emitted": ss.emitted,
+		"failed":  ss.failed,
+	}).Info("TranslateStream completed")
+
+	return nil
+}
+
+// recvError converts a receive error into the status returned to the client.
+// Status errors (e.g. from validation or cancellation) pass through unchanged.
+func recvError(err error) error {
+	if st, ok := status.FromError(err); ok && st.Code() != codes.Unknown {
+		return err
+	}
+	return status.Error(codes.Internal, fmt.Sprintf("failed to receive chunk: %v", err))
+}
+
+// negotiateStreamWindow clamps the client's requested window to server limits.
+func negotiateStreamWindow(requested int32) int {
+	switch {
+	case requested <= 0:
+		return defaultStreamWindow
+	case requested > maxStreamWindow:
+		return maxStreamWindow
+	default:
+		return int(requested)
+	}
+}
+
+// receive forwards client messages to incoming until the final chunk or EOF,
+// then closes it. Receive errors are reported on errs instead.
+func (ss *streamSession) receive(incoming chan<- *nanabushv1.TranslateChunk, errs chan<- error) {
+	for {
+		chunk, err := ss.stream.Recv()
+		if err == io.EOF {
+			close(incoming)
+			return
+		}
+		if err != nil {
+			errs <- err
+			return
+		}
+
+		select {
+		case incoming <- chunk:
+		case <-ss.ctx.Done():
+			return
+		}
+
+		if chunk.IsFinal {
+			close(incoming)
+			return
+		}
+	}
+}
+
+// accept validates a chunk against the window and starts translating it.
+func (ss *streamSession) accept(chunk *nanabushv1.TranslateChunk) error {
+	key := chunkKey{orderingKey: chunk.OrderingKey, index: chunk.ChunkIndex}
+
+	if key.index < 0 {
+		return status.Error(codes.InvalidArgument, fmt.Sprintf("chunk_index must be non-negative, got %d", key.index))
+	}
+	if _, done := ss.ready[key]; done || ss.inflight[key] || key.index < ss.next[key.orderingKey] {
+		return status.Error(codes.InvalidArgument,
+			fmt.Sprintf("duplicate chunk_index %d for ordering_key %q", key.index, key.orderingKey))
+	}
+	if len(ss.inflight)+len(ss.ready) >= ss.window {
+		ss.service.Logger.WithFields(logrus.Fields{
+			"job_id": ss.jobID,
+			"window": ss.window,
+		}).Warn("TranslateStream: client exceeded flow-control window")
+		return status.Error(codes.ResourceExhausted,
+			fmt.Sprintf("flow-control window of %d chunks exceeded; receive translated chunks before sending more", ss.window))
+	}
+
+	ss.inflight[key] = true
+	go ss.translate(key, chunk.Content)
+
+	return nil
+}
+
+// translate runs one chunk through the backend and reports the result.
+func (ss *streamSession) translate(key chunkKey, content string) {
+	res := streamResult{chunkKey: key}
+	// Runs on its own goroutine, outside the recovery interceptor: report a
+	// panic as a failed chunk
+	defer func() {
+		if r := recover(); r != nil {
+			ss.service.Logger.WithFields(logrus.Fields{
+				"job_id":      ss.jobID,
+				"chunk_index": key.index,
+				"panic":       fmt.Sprint(r),
+			}).Error("Recovered from panic while translating stream chunk")
+			res.content, res.err = "", fmt.Errorf("internal error: %v", r)
+		}
+		ss.results <- res
+	}()
+	if strings.TrimSpace(content) == "" {
+		res.content = content
+	} else {
+		res.content, res.err = ss.service.Translator.Translate(ss.ctx, content, ss.sourceLang, ss.targetLang)
+	}
+}
+
+// flush emits consecutive ready chunks for orderingKey, starting at the next expected index.
+func (ss *streamSession) flush(orderingKey string) error {
+	for {
+		key := chunkKey{orderingKey: orderingKey, index: ss.next[orderingKey]}
+		res, ok := ss.ready[key]
+		if !ok {
+			return nil
+		}
+		if err := ss.emit(res); err != nil {
+			return err
+		}
+		delete(ss.ready, key)
+		ss.next[orderingKey]++
+	}
+}
+
+// flushRemaining emits chunks still held back by missing indices, in order.
+// Called once input has ended, so the missing chunks are never coming.
+func (ss *streamSession) flushRemaining() error {
+	keys := make([]chunkKey, 0, len(ss.ready))
+	for key := range ss.ready {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].orderingKey != keys[j].orderingKey {
+			return keys[i].orderingKey < keys[j].orderingKey
+		}
+		return keys[i].index < keys[j].index
+	})
+
+	ss.service.Logger.WithFields(logrus.Fields{
+		"job_id":  ss.jobID,
+		"pending": len(keys),
+	}).Warn("TranslateStream: input ended with gaps in chunk_index; emitting remaining chunks")
+
+	for _, key := range keys {
+		if err := ss.emit(ss.ready[key]); err != nil {
+			return err
+		}
+		delete(ss.ready, key)
+	}
+	return nil
+}
+
+// emit sends a single translated chunk to the client.
+// Send blocks while the client isn't reading, which is what bounds buffering.
+func (ss *streamSession) emit(res streamResult) error {
+	out := &nanabushv1.TranslateChunk{
+		JobId:       ss.jobID,
+		ChunkIndex:  res.index,
+		OrderingKey: res.orderingKey,
+		Content:     res.content,
+	}
+	if res.err != nil {
+		ss.service.Logger.WithError(res.err).WithFields(logrus.Fields{
+			"job_id":       ss.jobID,
+			"ordering_key": res.orderingKey,
+			"chunk_index":  res.index,
+		}).Warn("TranslateStream: chunk translation failed")
+		out.Content = ""
+		out.ErrorMessage = fmt.Sprintf("translation failed: %v", res.err)
+		ss.failed++
+	}
+
+	if err := ss.stream.Send(out); err != nil {
+		ss.service.Logger.WithError(err).Error("TranslateStream send error")
+		return status.Error(codes.Internal, fmt.Sprintf("failed to send chunk: %v", err))
+	}
+	ss.emitted++
+
+	return nil
+}
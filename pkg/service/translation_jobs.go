@@ -0,0 +1,412 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	nanabushv1 "github.com/dasmlab/iskoces/pkg/proto/v1"
+	"github.com/dasmlab/iskoces/pkg/translate"
+	"github.com/sirupsen/logrus"
+)
+
+// SubmitTranslation queues a translation job and returns immediately with its ID.
+// The job is processed by the JobQueue regardless of document size. Retried
+// submissions (same idempotency key and content) return the existing job.
+func (s *TranslationService) SubmitTranslation(ctx context.Context, req *nanabushv1.TranslateRequest) (*nanabushv1.SubmitTranslationResponse, error) {
+	req.Priority = s.callerPriority(ctx, req.Priority)
+	s.log(ctx).WithFields(logrus.Fields{
+		"client_job_id": req.JobId,
+		"primitive":     req.Primitive,
+		"namespace":     req.Namespace,
+		"source_lang":   req.SourceLanguage,
+		"target_lang":   req.TargetLanguage,
+	}).Info("SubmitTranslation request received")
+
+	if err := validateTranslateRequest(req); err != nil {
+		s.log(ctx).WithError(err).Error("SubmitTranslation: invalid request")
+		return nil, err
+	}
+	if err := s.checkDeadline(ctx, req); err != nil {
+		return nil, err
+	}
+
+	jobID, err := s.JobQueue.CreateJob(ctx, req, callerClientID(ctx))
+	if err != nil {
+		s.log(ctx).WithError(err).Error("Failed to create translation job")
+		if errors.Is(err, ErrIdempotencyConflict) {
+			return nil, status.Error(codes.AlreadyExists, err.Error())
+		}
+		return nil, status.Error(codes.Internal, fmt.Sprintf("failed to queue translation job: %v", err))
+	}
+
+	resp, err := s.submittedJob(jobID)
+	if err != nil {
+		return nil, err
+	}
+	s.log(ctx).WithFields(logrus.Fields{
+		"job_id":        jobID,
+		"client_job_id": req.JobId,
+	}).Info("Translation job submitted")
+	return resp, nil
+}
+
+// submittedJob describes a job just created, for the caller to poll.
+func (s *TranslationService) submittedJob(jobID string) (*nanabushv1.SubmitTranslationResponse, error) {
+	job, err := s.JobQueue.GetJob(jobID)
+	if err != nil {
+		return nil, status.Error(codes.Internal, fmt.Sprintf("failed to load queued job: %v", err))
+	}
+	jobStatus, _, _ := job.GetStatus()
+	return &nanabushv1.SubmitTranslationResponse{
+		JobId:         jobID,
+		RequestId:     job.RequestID,
+		State:         jobStateToProto(jobStatus),
+		CreatedAt:     timestamppb.New(job.CreatedAt),
+		OperationName: OperationName(jobID),
+	}, nil
+}
+
+// GetTranslationStatus returns the current state and progress of a submitted job.
+func (s *TranslationService) GetTranslationStatus(ctx context.Context, req *nanabushv1.GetTranslationStatusRequest) (*nanabushv1.TranslationStatus, error) {
+	if req.JobId == "" {
+		s.log(ctx).Error("GetTranslationStatus: job_id is required")
+		return nil, invalidArgument("job_id", "is required")
+	}
+
+	job, err := s.JobQueue.GetJob(req.JobId)
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+
+	return jobToStatusProto(job), nil
+}
+
+// GetTranslationResult returns the output of a finished job, reading an
+// offloaded result from the result store. Failed and cancelled jobs return
+// the job's error as a status with ErrorInfo details; an expired result is
+// NOT_FOUND.
+func (s *TranslationService) GetTranslationResult(ctx context.Context, req *nanabushv1.GetTranslationResultRequest) (*nanabushv1.TranslateResponse, error) {
+	if req.JobId == "" {
+		s.log(ctx).Error("GetTranslationResult: job_id is required")
+		return nil, invalidArgument("job_id", "is required")
+	}
+
+	job, err := s.JobQueue.GetJob(req.JobId)
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+
+	title, markdown, resultErr := job.Result()
+
+	job.mu.RLock()
+	defer job.mu.RUnlock()
+
+	switch job.Status {
+	case JobStatusCompleted:
+		if resultErr != nil {
+			return nil, resultStatusError(resultErr)
+		}
+		return &nanabushv1.TranslateResponse{
+			JobId:                job.RequestID,
+			Success:              true,
+			TranslatedTitle:      title,
+			TranslatedMarkdown:   markdown,
+			CompletedAt:          timestampOrNil(job.CompletedAt),
+			TokensUsed:           int32(job.TokensUsed),
+			InferenceTimeSeconds: job.InferenceTime,
+		}, nil
+	case JobStatusFailed:
+		return nil, classError(job.ErrorClass, job.Error, job.SourceLang, job.TargetLang)
+	case JobStatusCancelled:
+		return nil, classError(translate.ErrorClassCancelled, job.ProgressMessage, job.SourceLang, job.TargetLang)
+	default:
+		return nil, status.Error(codes.FailedPrecondition,
+			fmt.Sprintf("job %s is %s; result not available yet", job.ID, job.Status))
+	}
+}
+
+// resultStatusError converts a failure to read a completed job's result:
+// NOT_FOUND once it has expired, UNAVAILABLE if the result store failed.
+func resultStatusError(err error) error {
+	if errors.Is(err, ErrResultNotFound) {
+		return status.Error(codes.NotFound, err.Error())
+	}
+	return status.Error(codes.Unavailable, fmt.Sprintf("failed to read job result: %v", err))
+}
+
+// CancelTranslation cancels a queued or in-flight job.
+func (s *TranslationService) CancelTranslation(ctx context.Context, req *nanabushv1.CancelTranslationRequest) (*nanabushv1.TranslationStatus, error) {
+	s.log(ctx).WithFields(logrus.Fields{
+		"job_id": req.JobId,
+		"reason": req.Reason,
+	}).Info("CancelTranslation request received")
+
+	if req.JobId == "" {
+		s.log(ctx).Error("CancelTranslation: job_id is required")
+		return nil, invalidArgument("job_id", "is required")
+	}
+
+	job, err := s.JobQueue.Cancel(req.JobId, req.Reason)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrJobNotFound):
+			return nil, status.Error(codes.NotFound, err.Error())
+		case errors.Is(err, ErrJobFinished):
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
+		default:
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+	}
+
+	return jobToStatusProto(job), nil
+}
+
+// validateTranslateRequest checks the fields required to translate a request.
+// Returns an InvalidArgument status error describing the first problem found.
+func validateTranslateRequest(req *nanabushv1.TranslateRequest) error {
+	if req.JobId == "" {
+		return invalidArgument("job_id", "is required")
+	}
+	if req.TargetLanguage == "" {
+		return invalidArgument("target_language", "is required")
+	}
+	if req.SourceLanguage == "" {
+		return invalidArgument("source_language", "is required")
+	}
+
+	switch req.Primitive {
+	case nanabushv1.PrimitiveType_PRIMITIVE_TITLE:
+		if req.GetTitle() == "" {
+			return invalidArgument("title", "is required for PRIMITIVE_TITLE")
+		}
+	case nanabushv1.PrimitiveType_PRIMITIVE_DOC_TRANSLATE:
+		if req.GetDoc() == nil {
+			return invalidArgument("doc", "is required for PRIMITIVE_DOC_TRANSLATE")
+		}
+	default:
+		return invalidArgument("primitive", fmt.Sprintf("has unsupported type %v", req.Primitive))
+	}
+
+	if req.NotBefore != nil {
+		if err := req.NotBefore.CheckValid(); err != nil {
+			return invalidArgument("not_before", "is not a valid timestamp")
+		}
+	}
+	if req.CallbackUrl != "" {
+		if err := validateCallbackURL(req.CallbackUrl); err != nil {
+			return invalidArgument("callback_url", "must be an absolute http or https URL")
+		}
+	}
+
+	return nil
+}
+
+// checkDeadline fails fast with RESOURCE_EXHAUSTED when the request sets
+// deadline_seconds and the estimated completion time (queued work at the same
+// or higher priority plus the request itself) exceeds it. The error carries a
+// RetryInfo detail with the estimated wait so clients can retry elsewhere.
+func (s *TranslationService) checkDeadline(ctx context.Context, req *nanabushv1.TranslateRequest) error {
+	if req.DeadlineSeconds <= 0 || s.Estimator == nil {
+		return nil
+	}
+
+	wait, total, backlogJobs, backlogBytes := s.estimateCompletion(requestBytes(req), requestPriority(req))
+
+	deadline := time.Duration(req.DeadlineSeconds) * time.Second
+	if total <= deadline {
+		return nil
+	}
+
+	s.log(ctx).WithFields(logrus.Fields{
+		"client_job_id":    req.JobId,
+		"deadline_seconds": req.DeadlineSeconds,
+		"estimated_total":  total.Round(time.Second).String(),
+		"estimated_wait":   wait.Round(time.Second).String(),
+		"backlog_jobs":     backlogJobs,
+		"backlog_bytes":    backlogBytes,
+	}).Warn("Rejecting request: deadline cannot be met")
+
+	st := status.New(codes.ResourceExhausted, fmt.Sprintf(
+		"estimated completion in %ds exceeds deadline of %ds (estimated wait %ds, %d jobs queued)",
+		int(math.Ceil(total.Seconds())), req.DeadlineSeconds, int(math.Ceil(wait.Seconds())), backlogJobs))
+	if detailed, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(wait)}); err == nil {
+		st = detailed
+	}
+	return st.Err()
+}
+
+// estimateCompletion estimates when a request of docBytes at the given
+// priority would start (wait) and finish (total), from the backlog of jobs at
+// the same or higher priority and the observed engine throughput.
+func (s *TranslationService) estimateCompletion(docBytes int, priority translate.Priority) (wait, total time.Duration, backlogJobs, backlogBytes int) {
+	if s.JobQueue != nil {
+		backlogJobs, backlogBytes = s.JobQueue.Backlog(priority)
+	}
+	estimator := s.Estimator
+	if estimator == nil {
+		estimator = NewCompletionEstimator()
+	}
+	wait, total = estimator.Estimate(docBytes, backlogBytes, s.workerCount())
+	return wait, total, backlogJobs, backlogBytes
+}
+
+// requestBytes returns the amount of text a request asks to translate.
+func requestBytes(req *nanabushv1.TranslateRequest) int {
+	if doc := req.GetDoc(); doc != nil {
+		return len(doc.Title) + len(doc.Markdown)
+	}
+	return len(req.GetTitle())
+}
+
+// jobToStatusProto converts a job into its gRPC status representation (thread-safe).
+func jobToStatusProto(job *TranslationJob) *nanabushv1.TranslationStatus {
+	position := job.QueuePosition()
+	result := job.ResultInfo()
+	job.mu.RLock()
+	defer job.mu.RUnlock()
+
+	resp := &nanabushv1.TranslationStatus{
+		JobId:           job.ID,
+		RequestId:       job.RequestID,
+		State:           jobStateToProto(job.Status),
+		ProgressPercent: job.ProgressPercent,
+		ProgressMessage: job.ProgressMessage,
+		ErrorMessage:    job.Error,
+		CreatedAt:       timestamppb.New(job.CreatedAt),
+		StartedAt:       timestampOrNil(job.StartedAt),
+		CompletedAt:     timestampOrNil(job.CompletedAt),
+		Priority:        priorityToProto(job.Priority),
+		QueuePosition:   int32(position),
+		NotBefore:       timestampOrNil(job.NotBefore),
+		ResultUrl:       result.URL,
+		ResultExpiresAt: timestampOrNil(result.ExpiresAt),
+		DuplicateOf:     job.DuplicateOf,
+		Usage:           jobUsageProto(job.Usage),
+		PausedBy:        job.PausedBy,
+	}
+	if job.Status == JobStatusFailed {
+		_, resp.ErrorReason = classStatus(job.ErrorClass)
+		resp.Retryable = job.ErrorClass.Retryable()
+	}
+	resp.Attempt = int32(job.Attempt)
+	for _, attempt := range job.Attempts {
+		_, reason := classStatus(attempt.ErrorClass)
+		resp.FailedAttempts = append(resp.FailedAttempts, &nanabushv1.JobAttempt{
+			Attempt:           int32(attempt.Attempt),
+			StartedAt:         timestamppb.New(attempt.StartedAt),
+			EndedAt:           timestamppb.New(attempt.EndedAt),
+			ErrorMessage:      attempt.Error,
+			ErrorReason:       reason,
+			RetryDelaySeconds: attempt.RetryDelay.Seconds(),
+		})
+	}
+	for _, event := range job.Progress {
+		resp.ProgressHistory = append(resp.ProgressHistory, &nanabushv1.ProgressEvent{
+			Sequence:        int64(event.Seq),
+			Time:            timestamppb.New(event.Time),
+			State:           jobStateToProto(event.Status),
+			ProgressPercent: event.Percent,
+			Message:         event.Message,
+			Chunk:           int32(event.Chunk),
+			TotalChunks:     int32(event.TotalChunks),
+		})
+	}
+	return resp
+}
+
+// jobUsageProto converts a job's usage to its wire form.
+func jobUsageProto(u JobUsage) *nanabushv1.JobUsage {
+	return &nanabushv1.JobUsage{
+		Characters:    u.Characters,
+		Chunks:        int32(u.Chunks),
+		EngineSeconds: u.EngineTime.Seconds(),
+		QueueSeconds:  u.QueueTime.Seconds(),
+		EstimatedCost: u.EstimatedCost,
+	}
+}
+
+// requestPriority returns the scheduling priority for a request.
+// Unspecified defaults to high for titles (interactive) and normal otherwise.
+func requestPriority(req *nanabushv1.TranslateRequest) translate.Priority {
+	switch req.Priority {
+	case nanabushv1.Priority_PRIORITY_LOW:
+		return translate.PriorityLow
+	case nanabushv1.Priority_PRIORITY_NORMAL:
+		return translate.PriorityNormal
+	case nanabushv1.Priority_PRIORITY_HIGH:
+		return translate.PriorityHigh
+	}
+	if req.Primitive == nanabushv1.PrimitiveType_PRIMITIVE_TITLE {
+		return translate.PriorityHigh
+	}
+	return translate.PriorityNormal
+}
+
+// priorityToProto maps a scheduling priority to the proto Priority enum.
+func priorityToProto(p translate.Priority) nanabushv1.Priority {
+	switch p {
+	case translate.PriorityLow:
+		return nanabushv1.Priority_PRIORITY_LOW
+	case translate.PriorityHigh:
+		return nanabushv1.Priority_PRIORITY_HIGH
+	default:
+		return nanabushv1.Priority_PRIORITY_NORMAL
+	}
+}
+
+// jobStateToProto maps a job status to the proto JobState enum.
+func jobStateToProto(s TranslationJobStatus) nanabushv1.JobState {
+	switch s {
+	case JobStatusQueued:
+		return nanabushv1.JobState_JOB_STATE_QUEUED
+	case JobStatusProcessing:
+		return nanabushv1.JobState_JOB_STATE_PROCESSING
+	case JobStatusPaused:
+		return nanabushv1.JobState_JOB_STATE_PAUSED
+	case JobStatusCompleted:
+		return nanabushv1.JobState_JOB_STATE_COMPLETED
+	case JobStatusFailed:
+		return nanabushv1.JobState_JOB_STATE_FAILED
+	case JobStatusCancelled:
+		return nanabushv1.JobState_JOB_STATE_CANCELLED
+	default:
+		return nanabushv1.JobState_JOB_STATE_UNSPECIFIED
+	}
+}
+
+// jobStatusFromProto maps a proto JobState to a job status.
+func jobStatusFromProto(s nanabushv1.JobState) (TranslationJobStatus, bool) {
+	switch s {
+	case nanabushv1.JobState_JOB_STATE_QUEUED:
+		return JobStatusQueued, true
+	case nanabushv1.JobState_JOB_STATE_PROCESSING:
+		return JobStatusProcessing, true
+	case nanabushv1.JobState_JOB_STATE_PAUSED:
+		return JobStatusPaused, true
+	case nanabushv1.JobState_JOB_STATE_COMPLETED:
+		return JobStatusCompleted, true
+	case nanabushv1.JobState_JOB_STATE_FAILED:
+		return JobStatusFailed, true
+	case nanabushv1.JobState_JOB_STATE_CANCELLED:
+		return JobStatusCancelled, true
+	default:
+		return "", false
+	}
+}
+
+// timestampOrNil converts an optional time to a proto timestamp.
+func timestampOrNil(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
@@ -10,6 +11,7 @@ import (
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/timestamppb"
 
+	"github.com/dasmlab/iskoces/pkg/audit"
 	nanabushv1 "github.com/dasmlab/iskoces/pkg/proto/v1"
 	"github.com/dasmlab/iskoces/pkg/translate"
 	"github.com/sirupsen/logrus"
@@ -22,6 +24,8 @@ type ClientInfo struct {
 	ClientVersion string
 	Namespace     string
 	Metadata      map[string]string
+	Labels        map[string]string // Routing/quota labels after schema defaults
+	Policy        ClientPolicy      // Policy derived from Labels
 	RegisteredAt  time.Time
 	LastHeartbeat time.Time
 }
@@ -38,6 +42,10 @@ type TranslationService struct {
 	// LanguageMapper handles conversion between proto language codes and backend codes.
 	LanguageMapper *translate.LanguageMapper
 
+	// Localizer converts numbers, dates, and units to target-locale conventions
+	// when a request sets localize_formats.
+	Localizer *translate.Localizer
+
 	// Logger for service operations.
 	Logger *logrus.Logger
 
@@ -45,10 +53,55 @@ type TranslationService struct {
 	clients           map[string]*ClientInfo
 	clientsMutex      sync.RWMutex
 	clientIDCounter   int64
-	heartbeatInterval int32 // seconds
+
+	// config is the client-facing configuration pushed to WatchConfig subscribers.
+	config *configHub
 
 	// Async job queue for translation requests
 	JobQueue *JobQueue
+
+	// AsyncDisabled turns off async jobs for clients: calls that create them
+	// are rejected (see AsyncUnaryInterceptor) and Translate handles large
+	// documents synchronously. v2 Translate still runs through JobQueue.
+	AsyncDisabled bool
+
+	// Schedules submits recurring translations (v2 PutSchedule) to JobQueue.
+	Schedules *ScheduleManager
+
+	// Info describes the deployment for GetServerInfo (set by the caller).
+	Info ServerInfo
+
+	// Estimator predicts completion times for deadline_seconds admission checks.
+	Estimator *CompletionEstimator
+
+	// Quotas enforces per-namespace rate limits and character budgets (nil disables them).
+	Quotas *QuotaManager
+
+	// Audit records translation calls in the audit log (nil disables it).
+	Audit *audit.Logger
+
+	// Health reports the engine's health checks in GetServerInfo (nil omits them).
+	Health *HealthMonitor
+
+	// LabelSchema validates client labels and maps them to policies (nil only
+	// checks label syntax).
+	LabelSchema *LabelSchema
+
+	// DrainRetryDelay is the retry hint for calls rejected while draining
+	// (0 = DefaultDrainRetryDelay).
+	DrainRetryDelay time.Duration
+
+	// OnDrainChange, if set, is called when drain mode is entered or left.
+	OnDrainChange func(draining bool)
+
+	// drain records whether the server is draining (see SetDraining).
+	drain drainState
+
+	// Feedback stores post-edit feedback from ReportTranslationFeedback.
+	Feedback *FeedbackStore
+
+	// results holds recent synchronous Translate results for idempotent retries.
+	results *resultCache
 }
 
 // NewTranslationService creates a new TranslationService instance.
@@ -60,34 +113,59 @@ func NewTranslationService(translator translate.Translator, logger *logrus.Logge
 	// Create job queue
 	jobQueue := NewJobQueue(logger)
 	
-	// Create job processor
+	// Create job processor; it feeds observed throughput to the estimator
+	estimator := NewCompletionEstimator()
 	processor := NewJobProcessor(translator, translate.NewLanguageMapper(), logger)
+	processor.estimator = estimator
 	jobQueue.SetProcessor(processor)
 
-	return &TranslationService{
+	// In-memory feedback store; callers may replace it with a file-backed one
+	feedback, _ := NewFeedbackStore("", DefaultMaxFeedbackRecords)
+
+	s := &TranslationService{
 		Translator:        translator,
 		LanguageMapper:    translate.NewLanguageMapper(),
+		Localizer:         translate.NewLocalizer(),
 		Logger:            logger,
 		clients:           make(map[string]*ClientInfo),
-		heartbeatInterval: 10, // Default: 10 seconds
+		config:            newConfigHub(10), // Default heartbeat interval: 10 seconds
 		JobQueue:          jobQueue,
+		Estimator:         estimator,
+		Feedback:          feedback,
+		results:           newResultCache(),
 	}
+	s.Schedules = NewScheduleManager(jobQueue, logger)
+	s.Schedules.paused = s.Draining
+	return s
+}
+
+// log returns the logger for the call with ctx (see RequestLogger).
+func (s *TranslationService) log(ctx context.Context) *logrus.Entry {
+	return RequestLogger(ctx, s.Logger)
 }
 
 // RegisterClient registers a new client with the server.
 // This should be called immediately after establishing a gRPC connection.
 func (s *TranslationService) RegisterClient(ctx context.Context, req *nanabushv1.RegisterClientRequest) (*nanabushv1.RegisterClientResponse, error) {
-	s.Logger.WithFields(logrus.Fields{
+	s.log(ctx).WithFields(logrus.Fields{
 		"client_name":    req.ClientName,
 		"client_version": req.ClientVersion,
 		"namespace":      req.Namespace,
 		"metadata":       req.Metadata,
+		"labels":         req.Labels,
 	}).Info("[gRPC] RegisterClient request received")
 
 	// Validate request
 	if req.ClientName == "" {
-		s.Logger.Error("[gRPC] RegisterClient: client_name is required")
-		return nil, status.Error(codes.InvalidArgument, "client_name is required")
+		s.log(ctx).Error("[gRPC] RegisterClient: client_name is required")
+		return nil, invalidArgument("client_name", "is required")
+	}
+	policy, err := resolveClientPolicy(s.LabelSchema, req.Labels)
+	if err != nil {
+		s.log(ctx).WithError(err).WithFields(logrus.Fields{
+			"client_name": req.ClientName,
+		}).Error("[gRPC] RegisterClient: invalid labels")
+		return nil, err
 	}
 
 	s.clientsMutex.Lock()
@@ -103,25 +181,25 @@ func (s *TranslationService) RegisterClient(ctx context.Context, req *nanabushv1
 	for existingID, existingClient := range s.clients {
 		// Remove clients with the same name (reconnection case)
 		if existingClient.ClientName == req.ClientName {
-			s.Logger.WithFields(logrus.Fields{
+			s.log(ctx).WithFields(logrus.Fields{
 				"old_client_id":   existingID,
 				"client_name":     req.ClientName,
 				"last_heartbeat":  existingClient.LastHeartbeat,
 				"registered_at":   existingClient.RegisteredAt,
 			}).Info("Removing old client with same name (new registration)")
-			delete(s.clients, existingID)
+			s.evictClient(existingID, existingClient, evictionReplaced, now)
 			removedOldClients++
 		} else {
 			// Also remove any stale clients (haven't sent heartbeat recently)
 			timeSinceLastHeartbeat := now.Sub(existingClient.LastHeartbeat)
 			if timeSinceLastHeartbeat > staleThreshold {
-				s.Logger.WithFields(logrus.Fields{
+				s.log(ctx).WithFields(logrus.Fields{
 					"stale_client_id":     existingID,
 					"client_name":         existingClient.ClientName,
 					"last_heartbeat":      existingClient.LastHeartbeat,
 					"time_since_heartbeat": timeSinceLastHeartbeat,
 				}).Info("Removing stale client during registration (no recent heartbeat)")
-				delete(s.clients, existingID)
+				s.evictClient(existingID, existingClient, evictionStale, now)
 				removedOldClients++
 			}
 		}
@@ -138,22 +216,25 @@ func (s *TranslationService) RegisterClient(ctx context.Context, req *nanabushv1
 		ClientVersion: req.ClientVersion,
 		Namespace:     req.Namespace,
 		Metadata:      req.Metadata,
+		Labels:        policy.Labels,
+		Policy:        policy,
 		RegisteredAt:  now,
 		LastHeartbeat: now,
 	}
 
 	// Store client
 	s.clients[clientID] = clientInfo
+	s.updateRegisteredClients()
 	
 	if removedOldClients > 0 {
-		s.Logger.WithFields(logrus.Fields{
+		s.log(ctx).WithFields(logrus.Fields{
 			"removed_old_clients": removedOldClients,
 			"new_client_id":        clientID,
 			"total_clients":        len(s.clients),
 		}).Info("Replaced old client(s) with new registration")
 	}
 
-	s.Logger.WithFields(logrus.Fields{
+	s.log(ctx).WithFields(logrus.Fields{
 		"client_id":     clientID,
 		"client_name":   req.ClientName,
 		"total_clients": len(s.clients),
@@ -166,13 +247,14 @@ func (s *TranslationService) RegisterClient(ctx context.Context, req *nanabushv1
 		ClientId:                 clientID,
 		Success:                  true,
 		Message:                  fmt.Sprintf("Client %q registered successfully", req.ClientName),
-		HeartbeatIntervalSeconds: int32(s.heartbeatInterval),
+		HeartbeatIntervalSeconds: s.heartbeatInterval(),
 		ExpiresAt:                timestamppb.New(expiresAt),
+		Policy:                   s.policyToProto(policy, req.Namespace),
 	}
 
-	s.Logger.WithFields(logrus.Fields{
+	s.log(ctx).WithFields(logrus.Fields{
 		"client_id":              clientID,
-		"heartbeat_interval_sec": s.heartbeatInterval,
+		"heartbeat_interval_sec": s.heartbeatInterval(),
 		"expires_at":             expiresAt.Format(time.RFC3339),
 		"response_success":       response.Success,
 		"response_message":       response.Message,
@@ -180,7 +262,7 @@ func (s *TranslationService) RegisterClient(ctx context.Context, req *nanabushv1
 
 	// Log the actual return to help debug if response is sent
 	defer func() {
-		s.Logger.WithFields(logrus.Fields{
+		s.log(ctx).WithFields(logrus.Fields{
 			"client_id": clientID,
 		}).Info("[gRPC] RegisterClient handler returning (response should be sent)")
 	}()
@@ -191,19 +273,19 @@ func (s *TranslationService) RegisterClient(ctx context.Context, req *nanabushv1
 // Heartbeat sends a keepalive and re-authentication signal from the client.
 // Should be called periodically to maintain the connection.
 func (s *TranslationService) Heartbeat(ctx context.Context, req *nanabushv1.HeartbeatRequest) (*nanabushv1.HeartbeatResponse, error) {
-	s.Logger.WithFields(logrus.Fields{
+	s.log(ctx).WithFields(logrus.Fields{
 		"client_id":   req.ClientId,
 		"client_name": req.ClientName,
 	}).Debug("[gRPC] Heartbeat request received")
 
 	// Validate request
 	if req.ClientId == "" {
-		s.Logger.Error("Heartbeat: client_id is required")
-		return nil, status.Error(codes.InvalidArgument, "client_id is required")
+		s.log(ctx).Error("Heartbeat: client_id is required")
+		return nil, invalidArgument("client_id", "is required")
 	}
 	if req.ClientName == "" {
-		s.Logger.Error("Heartbeat: client_name is required")
-		return nil, status.Error(codes.InvalidArgument, "client_name is required")
+		s.log(ctx).Error("Heartbeat: client_name is required")
+		return nil, invalidArgument("client_name", "is required")
 	}
 
 	s.clientsMutex.Lock()
@@ -212,7 +294,7 @@ func (s *TranslationService) Heartbeat(ctx context.Context, req *nanabushv1.Hear
 	// Look up client
 	clientInfo, exists := s.clients[req.ClientId]
 	if !exists {
-		s.Logger.WithFields(logrus.Fields{
+		s.log(ctx).WithFields(logrus.Fields{
 			"client_id":   req.ClientId,
 			"client_name": req.ClientName,
 		}).Warn("Heartbeat from unknown client")
@@ -220,14 +302,14 @@ func (s *TranslationService) Heartbeat(ctx context.Context, req *nanabushv1.Hear
 			Success:                  false,
 			Message:                  "Client not registered or expired",
 			ReceivedAt:               timestamppb.Now(),
-			HeartbeatIntervalSeconds: int32(s.heartbeatInterval),
+			HeartbeatIntervalSeconds: s.heartbeatInterval(),
 			ReRegisterRequired:       true,
 		}, nil
 	}
 
 	// Validate client name matches
 	if clientInfo.ClientName != req.ClientName {
-		s.Logger.WithFields(logrus.Fields{
+		s.log(ctx).WithFields(logrus.Fields{
 			"expected": clientInfo.ClientName,
 			"got":      req.ClientName,
 		}).Warn("Heartbeat client name mismatch")
@@ -235,38 +317,42 @@ func (s *TranslationService) Heartbeat(ctx context.Context, req *nanabushv1.Hear
 			Success:                  false,
 			Message:                  "Client name mismatch",
 			ReceivedAt:               timestamppb.Now(),
-			HeartbeatIntervalSeconds: int32(s.heartbeatInterval),
+			HeartbeatIntervalSeconds: s.heartbeatInterval(),
 			ReRegisterRequired:       true,
 		}, nil
 	}
 
-	// Update last heartbeat time
-	clientInfo.LastHeartbeat = time.Now()
+	// Update last heartbeat time, charging the client for any heartbeats
+	// that didn't arrive since the last one
+	now := time.Now()
+	timeSinceLastHeartbeat := now.Sub(clientInfo.LastHeartbeat)
+	s.recordHeartbeatMisses(clientInfo, s.heartbeatsDue(timeSinceLastHeartbeat)-1)
+	clientInfo.LastHeartbeat = now
 
 	// Check if registration expired (24 hours)
 	if time.Since(clientInfo.RegisteredAt) > 24*time.Hour {
-		s.Logger.WithFields(logrus.Fields{
+		s.log(ctx).WithFields(logrus.Fields{
 			"client_id":   req.ClientId,
 			"client_name": req.ClientName,
 		}).Warn("Client registration expired")
-		delete(s.clients, req.ClientId)
+		s.evictClient(req.ClientId, clientInfo, evictionRegistrationExpired, now)
+		s.updateRegisteredClients()
 		return &nanabushv1.HeartbeatResponse{
 			Success:                  false,
 			Message:                  "Registration expired",
 			ReceivedAt:               timestamppb.Now(),
-			HeartbeatIntervalSeconds: int32(s.heartbeatInterval),
+			HeartbeatIntervalSeconds: s.heartbeatInterval(),
 			ReRegisterRequired:       true,
 		}, nil
 	}
 
 	// Log heartbeat receipt (at debug level to avoid spam, but include timing info)
-	timeSinceLastHeartbeat := time.Since(clientInfo.LastHeartbeat)
-	s.Logger.WithFields(logrus.Fields{
+	s.log(ctx).WithFields(logrus.Fields{
 		"client_id":            req.ClientId,
 		"client_name":          req.ClientName,
 		"last_seen":            clientInfo.LastHeartbeat,
 		"time_since_last":      timeSinceLastHeartbeat,
-		"heartbeat_interval":   s.heartbeatInterval,
+		"heartbeat_interval":   s.heartbeatInterval(),
 		"time_since_registration": time.Since(clientInfo.RegisteredAt),
 	}).Debug("Heartbeat acknowledged")
 
@@ -274,7 +360,7 @@ func (s *TranslationService) Heartbeat(ctx context.Context, req *nanabushv1.Hear
 		Success:                  true,
 		Message:                  "Heartbeat acknowledged",
 		ReceivedAt:               timestamppb.Now(),
-		HeartbeatIntervalSeconds: int32(s.heartbeatInterval),
+		HeartbeatIntervalSeconds: s.heartbeatInterval(),
 		ReRegisterRequired:       false,
 	}, nil
 }
@@ -282,7 +368,7 @@ func (s *TranslationService) Heartbeat(ctx context.Context, req *nanabushv1.Hear
 // CheckTitle performs a lightweight pre-flight check with title only.
 // This validates that Iskoces is ready and can handle the request.
 func (s *TranslationService) CheckTitle(ctx context.Context, req *nanabushv1.TitleCheckRequest) (*nanabushv1.TitleCheckResponse, error) {
-	s.Logger.WithFields(logrus.Fields{
+	s.log(ctx).WithFields(logrus.Fields{
 		"title":       req.Title,
 		"source_lang": req.SourceLanguage,
 		"target_lang": req.LanguageTag,
@@ -290,22 +376,22 @@ func (s *TranslationService) CheckTitle(ctx context.Context, req *nanabushv1.Tit
 
 	// Validate request
 	if req.Title == "" {
-		s.Logger.Error("CheckTitle: title is required")
-		return nil, status.Error(codes.InvalidArgument, "title is required")
+		s.log(ctx).Error("CheckTitle: title is required")
+		return nil, invalidArgument("title", "is required")
 	}
 	if req.LanguageTag == "" {
-		s.Logger.Error("CheckTitle: language_tag is required")
-		return nil, status.Error(codes.InvalidArgument, "language_tag is required")
+		s.log(ctx).Error("CheckTitle: language_tag is required")
+		return nil, invalidArgument("language_tag", "is required")
 	}
 	if req.SourceLanguage == "" {
-		s.Logger.Error("CheckTitle: source_language is required")
-		return nil, status.Error(codes.InvalidArgument, "source_language is required")
+		s.log(ctx).Error("CheckTitle: source_language is required")
+		return nil, invalidArgument("source_language", "is required")
 	}
 
-	// Check translator health
+	// Check translator health (pre-flight checks are interactive: don't wait behind documents)
 	if s.Translator != nil {
-		if err := s.Translator.CheckHealth(ctx); err != nil {
-			s.Logger.WithError(err).Warn("Translator health check failed")
+		if err := s.Translator.CheckHealth(translate.WithPriority(ctx, translate.PriorityHigh)); err != nil {
+			s.log(ctx).WithError(err).Warn("Translator health check failed")
 			return &nanabushv1.TitleCheckResponse{
 				Ready:                false,
 				Message:              fmt.Sprintf("Translator not ready: %v", err),
@@ -314,33 +400,78 @@ func (s *TranslationService) CheckTitle(ctx context.Context, req *nanabushv1.Tit
 		}
 	}
 
-	// Estimate time based on title length (simple heuristic)
-	// For lightweight MT, translation is typically faster than vLLM
-	estimatedSeconds := int32(2 + len(req.Title)/20)
-	if estimatedSeconds < 2 {
-		estimatedSeconds = 2
+	// Estimate from the queue ahead of a normal-priority document and the
+	// engine's observed throughput; the size hint defaults to the title alone
+	docBytes := int(req.DocumentBytes)
+	if docBytes <= 0 {
+		docBytes = len(req.Title)
 	}
-	if estimatedSeconds > 30 {
-		estimatedSeconds = 30
-	}
-
-	s.Logger.WithFields(logrus.Fields{
-		"ready":         true,
-		"estimated_sec": estimatedSeconds,
+	wait, total, queueDepth, queuedBytes := s.estimateCompletion(docBytes, translate.PriorityNormal)
+
+	s.log(ctx).WithFields(logrus.Fields{
+		"ready":          true,
+		"document_bytes": docBytes,
+		"queue_depth":    queueDepth,
+		"queued_bytes":   queuedBytes,
+		"estimated_wait": wait.Round(time.Second).String(),
+		"estimated_sec":  roundUpSeconds(total),
 	}).Debug("CheckTitle response")
 
 	return &nanabushv1.TitleCheckResponse{
 		Ready:                true,
 		Message:              "Ready to handle translation request",
-		EstimatedTimeSeconds: estimatedSeconds,
+		EstimatedTimeSeconds: roundUpSeconds(total),
+		QueueDepth:           int32(queueDepth),
+		QueuedBytes:          int64(queuedBytes),
+		EstimatedWaitSeconds: roundUpSeconds(wait),
 	}, nil
 }
 
 // Translate performs full document translation.
-// For large documents (>10KB), this now uses async processing and returns immediately with a job ID.
+// For large documents (>10KB), this now uses async processing and returns immediately with
+// the queued job in the response's queued field.
 // Clients should poll the job status or use SSE to get progress updates.
+//
+// Retries are idempotent: a request with the same idempotency key (or
+// namespace + job_id) and the same content returns the earlier result, and
+// a duplicate arriving while the first is still running waits for it.
 func (s *TranslationService) Translate(ctx context.Context, req *nanabushv1.TranslateRequest) (*nanabushv1.TranslateResponse, error) {
-	s.Logger.WithFields(logrus.Fields{
+	req.Priority = s.callerPriority(ctx, req.Priority)
+	key, explicit := idempotencyKey(req)
+	if key == "" {
+		return s.translate(ctx, req)
+	}
+
+	entry, leader, err := s.results.begin(key, requestFingerprint(req), explicit)
+	if err != nil {
+		s.log(ctx).WithFields(logrus.Fields{
+			"job_id":          req.JobId,
+			"idempotency_key": req.IdempotencyKey,
+		}).Warn("Translate: idempotency key reused with a different request")
+		return nil, status.Error(codes.AlreadyExists, err.Error())
+	}
+
+	if !leader {
+		s.log(ctx).WithFields(logrus.Fields{
+			"job_id":          req.JobId,
+			"idempotency_key": req.IdempotencyKey,
+		}).Info("Translate: duplicate request, returning existing result")
+		select {
+		case <-entry.done:
+			return entry.resp, entry.err
+		case <-ctx.Done():
+			return nil, status.FromContextError(ctx.Err()).Err()
+		}
+	}
+
+	resp, err := s.translate(ctx, req)
+	s.results.finish(key, entry, resp, err)
+	return resp, err
+}
+
+// translate does the work for Translate, without idempotency handling.
+func (s *TranslationService) translate(ctx context.Context, req *nanabushv1.TranslateRequest) (*nanabushv1.TranslateResponse, error) {
+	s.log(ctx).WithFields(logrus.Fields{
 		"job_id":      req.JobId,
 		"primitive":   req.Primitive,
 		"namespace":   req.Namespace,
@@ -350,25 +481,30 @@ func (s *TranslationService) Translate(ctx context.Context, req *nanabushv1.Tran
 
 	// Validate request
 	if req.JobId == "" {
-		s.Logger.Error("Translate: job_id is required")
-		return nil, status.Error(codes.InvalidArgument, "job_id is required")
+		s.log(ctx).Error("Translate: job_id is required")
+		return nil, invalidArgument("job_id", "is required")
 	}
 	if req.TargetLanguage == "" {
-		s.Logger.Error("Translate: target_language is required")
-		return nil, status.Error(codes.InvalidArgument, "target_language is required")
+		s.log(ctx).Error("Translate: target_language is required")
+		return nil, invalidArgument("target_language", "is required")
 	}
 	if req.SourceLanguage == "" {
-		s.Logger.Error("Translate: source_language is required")
-		return nil, status.Error(codes.InvalidArgument, "source_language is required")
+		s.log(ctx).Error("Translate: source_language is required")
+		return nil, invalidArgument("source_language", "is required")
+	}
+
+	// Fail fast if the client's deadline can't be met
+	if err := s.checkDeadline(ctx, req); err != nil {
+		return nil, err
 	}
 
 	// Determine if we should use async processing
 	// For large documents (>10KB), use async; for small ones, process synchronously for backward compatibility
 	useAsync := false
-	if req.Primitive == nanabushv1.PrimitiveType_PRIMITIVE_DOC_TRANSLATE {
+	if req.Primitive == nanabushv1.PrimitiveType_PRIMITIVE_DOC_TRANSLATE && !s.AsyncDisabled {
 		if doc := req.GetDoc(); doc != nil {
 			// Use async if markdown is large (>10KB)
-			if len(doc.Markdown) > 10*1024 {
+			if len(doc.Markdown) > AsyncThresholdBytes {
 				useAsync = true
 			}
 		}
@@ -376,40 +512,43 @@ func (s *TranslationService) Translate(ctx context.Context, req *nanabushv1.Tran
 
 	if useAsync {
 		// Create async job and return immediately
-		jobID, err := s.JobQueue.CreateJob(req)
+		jobID, err := s.JobQueue.CreateJob(ctx, req, callerClientID(ctx))
 		if err != nil {
-			s.Logger.WithError(err).Error("Failed to create async translation job")
-			return &nanabushv1.TranslateResponse{
-				JobId:        req.JobId,
-				Success:      false,
-				ErrorMessage: fmt.Sprintf("Failed to queue translation job: %v", err),
-				CompletedAt:  timestamppb.Now(),
-			}, nil
+			s.log(ctx).WithError(err).Error("Failed to create async translation job")
+			if errors.Is(err, ErrIdempotencyConflict) {
+				return nil, status.Error(codes.AlreadyExists, err.Error())
+			}
+			return nil, internalError(ReasonQueueError, fmt.Sprintf("failed to queue translation job: %v", err))
 		}
 
-		s.Logger.WithFields(logrus.Fields{
-			"job_id":     jobID,
-			"request_id": req.JobId,
+		s.log(ctx).WithFields(logrus.Fields{
+			"job_id":        jobID,
+			"client_job_id": req.JobId,
 		}).Info("Translation job queued for async processing")
 
-		// Return response indicating job is queued
-		// Client should poll job status or use SSE endpoint
+		// Not translated yet: the client polls the job (GetTranslationStatus,
+		// /api/v1/jobs/{id} or its event stream) and fetches the result
+		queued, err := s.submittedJob(jobID)
+		if err != nil {
+			return nil, err
+		}
 		return &nanabushv1.TranslateResponse{
-			JobId:        req.JobId,
-			Success:      false, // Not completed yet
-			ErrorMessage: fmt.Sprintf("Translation queued. Use job ID '%s' to check status via /api/v1/jobs/%s or SSE endpoint", jobID, jobID),
-			CompletedAt:  timestamppb.Now(),
+			JobId:  req.JobId,
+			Queued: queued,
 		}, nil
 	}
 
 	// Small request - process synchronously for backward compatibility
 	startTime := time.Now()
 
+	// Dispatch to workers according to the request's priority
+	ctx = translate.WithPriority(ctx, requestPriority(req))
+
 	// Convert language codes to backend format
 	sourceLang := s.LanguageMapper.ToBackendCode(req.SourceLanguage)
 	targetLang := s.LanguageMapper.ToBackendCode(req.TargetLanguage)
 
-	s.Logger.WithFields(logrus.Fields{
+	s.log(ctx).WithFields(logrus.Fields{
 		"proto_source":   req.SourceLanguage,
 		"proto_target":   req.TargetLanguage,
 		"backend_source": sourceLang,
@@ -425,42 +564,32 @@ func (s *TranslationService) Translate(ctx context.Context, req *nanabushv1.Tran
 	case nanabushv1.PrimitiveType_PRIMITIVE_TITLE:
 		// Title-only translation
 		if req.GetTitle() == "" {
-			s.Logger.Error("Translate: title is required for PRIMITIVE_TITLE")
-			return nil, status.Error(codes.InvalidArgument, "title is required for PRIMITIVE_TITLE")
+			s.log(ctx).Error("Translate: title is required for PRIMITIVE_TITLE")
+			return nil, invalidArgument("title", "is required for PRIMITIVE_TITLE")
 		}
 
 		if s.Translator != nil {
 			translatedTitle, err = s.Translator.Translate(ctx, req.GetTitle(), sourceLang, targetLang)
 			if err != nil {
-				s.Logger.WithError(err).WithFields(logrus.Fields{
+				s.log(ctx).WithError(err).WithFields(logrus.Fields{
 					"job_id": req.JobId,
 				}).Error("Title translation failed")
-				return &nanabushv1.TranslateResponse{
-					JobId:        req.JobId,
-					Success:      false,
-					ErrorMessage: fmt.Sprintf("Translation failed: %v", err),
-					CompletedAt:  timestamppb.Now(),
-				}, nil
+				return nil, engineError(err, "translation failed", sourceLang, targetLang)
 			}
 		} else {
-			s.Logger.Error("Translate: translator not configured")
-			return &nanabushv1.TranslateResponse{
-				JobId:        req.JobId,
-				Success:      false,
-				ErrorMessage: "Translator not configured",
-				CompletedAt:  timestamppb.Now(),
-			}, nil
+			s.log(ctx).Error("Translate: translator not configured")
+			return nil, classError(translate.ErrorClassUnavailable, "translator not configured", sourceLang, targetLang)
 		}
 
 	case nanabushv1.PrimitiveType_PRIMITIVE_DOC_TRANSLATE:
 		// Full document translation (small document, synchronous)
 		if req.GetDoc() == nil {
-			s.Logger.Error("Translate: doc is required for PRIMITIVE_DOC_TRANSLATE")
-			return nil, status.Error(codes.InvalidArgument, "doc is required for PRIMITIVE_DOC_TRANSLATE")
+			s.log(ctx).Error("Translate: doc is required for PRIMITIVE_DOC_TRANSLATE")
+			return nil, invalidArgument("doc", "is required for PRIMITIVE_DOC_TRANSLATE")
 		}
 
 		doc := req.GetDoc()
-		s.Logger.WithFields(logrus.Fields{
+		s.log(ctx).WithFields(logrus.Fields{
 			"job_id":       req.JobId,
 			"title":        doc.Title,
 			"markdown_len": len(doc.Markdown),
@@ -471,15 +600,10 @@ func (s *TranslationService) Translate(ctx context.Context, req *nanabushv1.Tran
 			if doc.Title != "" {
 				translatedTitle, err = s.Translator.Translate(ctx, doc.Title, sourceLang, targetLang)
 				if err != nil {
-					s.Logger.WithError(err).WithFields(logrus.Fields{
+					s.log(ctx).WithError(err).WithFields(logrus.Fields{
 						"job_id": req.JobId,
 					}).Error("Title translation failed")
-					return &nanabushv1.TranslateResponse{
-						JobId:        req.JobId,
-						Success:      false,
-						ErrorMessage: fmt.Sprintf("Title translation failed: %v", err),
-						CompletedAt:  timestamppb.Now(),
-					}, nil
+					return nil, engineError(err, "title translation failed", sourceLang, targetLang)
 				}
 			}
 
@@ -487,38 +611,36 @@ func (s *TranslationService) Translate(ctx context.Context, req *nanabushv1.Tran
 			if doc.Markdown != "" {
 				translatedMarkdown, err = s.Translator.Translate(ctx, doc.Markdown, sourceLang, targetLang)
 				if err != nil {
-					s.Logger.WithError(err).WithFields(logrus.Fields{
+					s.log(ctx).WithError(err).WithFields(logrus.Fields{
 						"job_id": req.JobId,
 					}).Error("Markdown translation failed")
-					return &nanabushv1.TranslateResponse{
-						JobId:        req.JobId,
-						Success:      false,
-						ErrorMessage: fmt.Sprintf("Markdown translation failed: %v", err),
-						CompletedAt:  timestamppb.Now(),
-					}, nil
+					return nil, engineError(err, "markdown translation failed", sourceLang, targetLang)
 				}
 			}
 		} else {
-			s.Logger.Error("Translate: translator not configured")
-			return &nanabushv1.TranslateResponse{
-				JobId:        req.JobId,
-				Success:      false,
-				ErrorMessage: "Translator not configured",
-				CompletedAt:  timestamppb.Now(),
-			}, nil
+			s.log(ctx).Error("Translate: translator not configured")
+			return nil, classError(translate.ErrorClassUnavailable, "translator not configured", sourceLang, targetLang)
 		}
 
 	default:
-		s.Logger.WithFields(logrus.Fields{
+		s.log(ctx).WithFields(logrus.Fields{
 			"primitive": req.Primitive,
 		}).Error("Unsupported primitive type")
-		return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("unsupported primitive type: %v", req.Primitive))
+		return nil, invalidArgument("primitive", fmt.Sprintf("has unsupported type %v", req.Primitive))
+	}
+
+	// Optional post-processing: convert numbers/dates/units to the target locale.
+	// Uses the full BCP 47 tag (e.g. "fr-CA") since conventions differ by region.
+	if req.LocalizeFormats {
+		translatedTitle = s.Localizer.Localize(translatedTitle, req.SourceLanguage, req.TargetLanguage)
+		translatedMarkdown = s.Localizer.Localize(translatedMarkdown, req.SourceLanguage, req.TargetLanguage)
 	}
 
 	// Build response
 	inferenceTime := time.Since(startTime).Seconds()
+	s.Estimator.Observe(requestBytes(req), time.Since(startTime))
 
-	s.Logger.WithFields(logrus.Fields{
+	s.log(ctx).WithFields(logrus.Fields{
 		"job_id":         req.JobId,
 		"success":        true,
 		"inference_time": inferenceTime,
@@ -542,81 +664,141 @@ func (s *TranslationService) Translate(ctx context.Context, req *nanabushv1.Tran
 	return resp, nil
 }
 
-// TranslateStream supports streaming for large documents.
-// Client sends chunks, server responds with translated chunks.
-// Note: This is a simplified implementation. For production, consider
-// implementing proper chunking and streaming translation.
-func (s *TranslationService) TranslateStream(stream nanabushv1.TranslationService_TranslateStreamServer) error {
-	s.Logger.Info("TranslateStream request started")
+// DetectLanguage identifies the language of a text sample.
+// Uses the backend's detector when available, otherwise the built-in heuristic detector.
+func (s *TranslationService) DetectLanguage(ctx context.Context, req *nanabushv1.DetectLanguageRequest) (*nanabushv1.DetectLanguageResponse, error) {
+	s.log(ctx).WithFields(logrus.Fields{
+		"text_length":    len(req.Text),
+		"max_candidates": req.MaxCandidates,
+	}).Debug("DetectLanguage request received")
 
-	var jobID string
-	chunkIndex := int32(0)
+	// Validate request
+	if req.Text == "" {
+		s.log(ctx).Error("DetectLanguage: text is required")
+		return nil, invalidArgument("text", "is required")
+	}
 
-	for {
-		// Receive chunk from client
-		chunk, err := stream.Recv()
-		if err != nil {
-			if err.Error() == "EOF" {
-				// Client closed stream
-				s.Logger.WithFields(logrus.Fields{
-					"job_id": jobID,
-				}).Debug("TranslateStream: client closed stream")
-				break
-			}
-			s.Logger.WithError(err).Error("TranslateStream receive error")
-			return status.Error(codes.Internal, fmt.Sprintf("failed to receive chunk: %v", err))
-		}
+	candidates, err := translate.Detect(ctx, s.Translator, req.Text)
+	if err != nil {
+		// Backend detection failed; the built-in detector is still useful
+		s.log(ctx).WithError(err).Warn("Backend language detection failed, using built-in detector")
+		candidates = translate.DetectLanguage(req.Text)
+	}
 
-		if jobID == "" {
-			jobID = chunk.JobId
-			s.Logger.WithFields(logrus.Fields{
-				"job_id": jobID,
-			}).Info("TranslateStream started")
-		}
+	maxCandidates := int(req.MaxCandidates)
+	if maxCandidates <= 0 {
+		maxCandidates = 3 // Default: top 3 candidates
+	}
+	if len(candidates) > maxCandidates {
+		candidates = candidates[:maxCandidates]
+	}
 
-		// Check if this is the final chunk
-		if chunk.IsFinal {
-			s.Logger.WithFields(logrus.Fields{
-				"job_id": jobID,
-			}).Debug("TranslateStream final chunk received")
-
-			// Send final acknowledgment
-			if err := stream.Send(&nanabushv1.TranslateChunk{
-				JobId:      jobID,
-				ChunkIndex: chunkIndex,
-				IsFinal:    true,
-				Content:    "[Stream completed]",
-			}); err != nil {
-				s.Logger.WithError(err).Error("TranslateStream: failed to send final chunk")
-				return status.Error(codes.Internal, fmt.Sprintf("failed to send final chunk: %v", err))
-			}
-			break
-		}
+	resp := &nanabushv1.DetectLanguageResponse{
+		Candidates: make([]*nanabushv1.LanguageCandidate, 0, len(candidates)),
+	}
+	for _, c := range candidates {
+		resp.Candidates = append(resp.Candidates, &nanabushv1.LanguageCandidate{
+			Language:   c.Language,
+			Confidence: c.Confidence,
+		})
+	}
+
+	s.log(ctx).WithFields(logrus.Fields{
+		"candidates": len(resp.Candidates),
+	}).Debug("DetectLanguage response")
+
+	return resp, nil
+}
+
+// MaxBatchTranslateTexts is the maximum number of strings accepted by BatchTranslate.
+const MaxBatchTranslateTexts = 10000
+
+// BatchTranslate translates many short strings in one call.
+// Duplicate and empty strings are common in CMS label sets, so only unique
+// non-empty strings are sent to the backend; results are mapped back to
+// input order.
+func (s *TranslationService) BatchTranslate(ctx context.Context, req *nanabushv1.BatchTranslateRequest) (*nanabushv1.BatchTranslateResponse, error) {
+	s.log(ctx).WithFields(logrus.Fields{
+		"job_id":      req.JobId,
+		"count":       len(req.Texts),
+		"source_lang": req.SourceLanguage,
+		"target_lang": req.TargetLanguage,
+	}).Info("BatchTranslate request received")
 
-		// TODO: Implement actual streaming translation
-		// For now, echo back with translation placeholder
-		// In production, this should translate each chunk and send it back
-		translatedContent := chunk.Content + " [translated chunk " + fmt.Sprintf("%d", chunkIndex) + "]"
-
-		// Send translated chunk back to client
-		if err := stream.Send(&nanabushv1.TranslateChunk{
-			JobId:      jobID,
-			ChunkIndex: chunkIndex,
-			IsFinal:    false,
-			Content:    translatedContent,
-		}); err != nil {
-			s.Logger.WithError(err).Error("TranslateStream send error")
-			return status.Error(codes.Internal, fmt.Sprintf("failed to send chunk: %v", err))
+	// Validate request
+	if req.TargetLanguage == "" {
+		s.log(ctx).Error("BatchTranslate: target_language is required")
+		return nil, invalidArgument("target_language", "is required")
+	}
+	if req.SourceLanguage == "" {
+		s.log(ctx).Error("BatchTranslate: source_language is required")
+		return nil, invalidArgument("source_language", "is required")
+	}
+	if len(req.Texts) > MaxBatchTranslateTexts {
+		s.log(ctx).WithFields(logrus.Fields{
+			"count": len(req.Texts),
+			"limit": MaxBatchTranslateTexts,
+		}).Error("BatchTranslate: too many texts")
+		return nil, invalidArgument("texts",
+			fmt.Sprintf("has %d entries, exceeding the limit of %d", len(req.Texts), MaxBatchTranslateTexts))
+	}
+
+	sourceLang := s.LanguageMapper.ToBackendCode(req.SourceLanguage)
+	targetLang := s.LanguageMapper.ToBackendCode(req.TargetLanguage)
+
+	// Deduplicate: positions maps each unique text to its index in unique
+	unique := make([]string, 0, len(req.Texts))
+	positions := make(map[string]int, len(req.Texts))
+	for _, text := range req.Texts {
+		if text == "" {
+			continue
 		}
+		if _, ok := positions[text]; !ok {
+			positions[text] = len(unique)
+			unique = append(unique, text)
+		}
+	}
+
+	startTime := time.Now()
+	translated, err := translate.TranslateBatch(ctx, s.Translator, unique, sourceLang, targetLang)
+	if err != nil {
+		s.log(ctx).WithError(err).WithFields(logrus.Fields{
+			"job_id": req.JobId,
+		}).Error("Batch translation failed")
+		return nil, engineError(err, "batch translation failed", sourceLang, targetLang)
+	}
+	inferenceTime := time.Since(startTime).Seconds()
 
-		chunkIndex++
+	translations := make([]string, len(req.Texts))
+	for i, text := range req.Texts {
+		if text == "" {
+			continue
+		}
+		translations[i] = translated[positions[text]]
 	}
 
-	s.Logger.WithFields(logrus.Fields{
-		"job_id": jobID,
-	}).Info("TranslateStream completed")
+	s.log(ctx).WithFields(logrus.Fields{
+		"job_id":         req.JobId,
+		"count":          len(req.Texts),
+		"unique":         len(unique),
+		"inference_time": inferenceTime,
+	}).Info("BatchTranslate completed")
 
-	return nil
+	return &nanabushv1.BatchTranslateResponse{
+		JobId:                req.JobId,
+		Translations:         translations,
+		InferenceTimeSeconds: inferenceTime,
+	}, nil
+}
+
+// CleanupIdempotentResults drops cached Translate results older than maxAge.
+// Retries after that translate again.
+func (s *TranslationService) CleanupIdempotentResults(maxAge time.Duration) {
+	if removed := s.results.cleanup(maxAge); removed > 0 {
+		s.Logger.WithFields(logrus.Fields{
+			"removed": removed,
+		}).Debug("Cleaned up idempotent Translate results")
+	}
 }
 
 // GetRegisteredClients returns all currently registered clients (for monitoring/debugging).
@@ -654,7 +836,7 @@ func (s *TranslationService) CleanupExpiredClients(maxIdleTime time.Duration) {
 				"registered_at":         client.RegisteredAt,
 				"time_since_registration": now.Sub(client.RegisteredAt),
 			}).Info("Removing expired client (no heartbeat received)")
-			delete(s.clients, clientID)
+			s.evictClient(clientID, client, evictionExpired, now)
 			removed++
 		} else {
 			// Log clients that are still active but getting close to expiration
@@ -670,6 +852,7 @@ func (s *TranslationService) CleanupExpiredClients(maxIdleTime time.Duration) {
 	}
 
 	if removed > 0 {
+		s.updateRegisteredClients()
 		s.Logger.WithFields(logrus.Fields{
 			"removed":   removed,
 			"remaining": len(s.clients),
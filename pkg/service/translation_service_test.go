@@ -0,0 +1,57 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	nanabushv1 "github.com/dasmlab/iskoces/pkg/proto/v1"
+)
+
+// A document over the async threshold is queued: Translate returns the job
+// in Queued, not an error message, and a retry with the same key gets the
+// same job.
+func TestTranslateQueuesLargeDocuments(t *testing.T) {
+	logger := quietLogger()
+	s := &TranslationService{Logger: logger, JobQueue: NewJobQueue(logger), results: newResultCache()}
+	req := &nanabushv1.TranslateRequest{
+		JobId:          "client-job",
+		IdempotencyKey: "key-1",
+		Primitive:      nanabushv1.PrimitiveType_PRIMITIVE_DOC_TRANSLATE,
+		SourceLanguage: "en",
+		TargetLanguage: "fr",
+		Source: &nanabushv1.TranslateRequest_Doc{Doc: &nanabushv1.DocumentContent{
+			Markdown: strings.Repeat("x", AsyncThresholdBytes+1),
+		}},
+	}
+
+	resp, err := s.Translate(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Translate() = %v", err)
+	}
+	if resp.Success || resp.ErrorMessage != "" || resp.TranslatedMarkdown != "" {
+		t.Errorf("response = %+v, want neither a result nor an error", resp)
+	}
+	queued := resp.Queued
+	if queued == nil || queued.JobId == "" {
+		t.Fatalf("queued = %+v, want the job", queued)
+	}
+	if queued.State != nanabushv1.JobState_JOB_STATE_QUEUED || queued.RequestId != "client-job" ||
+		queued.OperationName != OperationName(queued.JobId) || queued.CreatedAt == nil {
+		t.Errorf("queued = %+v", queued)
+	}
+	if _, err := s.JobQueue.GetJob(queued.JobId); err != nil {
+		t.Errorf("GetJob(%s) = %v", queued.JobId, err)
+	}
+
+	again, err := s.Translate(context.Background(), req)
+	if err != nil {
+		t.Fatalf("retried Translate() = %v", err)
+	}
+	if again.Queued.GetJobId() != queued.JobId {
+		t.Errorf("retry queued job %s, want %s", again.Queued.GetJobId(), queued.JobId)
+	}
+	if n := len(s.JobQueue.ListJobs()); n != 1 {
+		t.Errorf("queue has %d jobs, want 1", n)
+	}
+}
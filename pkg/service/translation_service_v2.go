@@ -0,0 +1,577 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/text/language"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	nanabushv1 "github.com/dasmlab/iskoces/pkg/proto/v1"
+	nanabushv2 "github.com/dasmlab/iskoces/pkg/proto/v2"
+	"github.com/dasmlab/iskoces/pkg/translate"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// defaultWaitJobSeconds is the WaitJob timeout when the client doesn't set one.
+	defaultWaitJobSeconds = 30
+
+	// maxWaitJobSeconds caps the WaitJob timeout.
+	maxWaitJobSeconds = 300
+)
+
+// TranslationServiceV2 implements the v2 TranslationService on top of the same
+// job queue, engine, and limits as the v1 service.
+type TranslationServiceV2 struct {
+	nanabushv2.UnimplementedTranslationServiceServer
+
+	// V1 is the v1 service whose job queue and validation v2 reuses.
+	V1 *TranslationService
+
+	// Logger for service operations.
+	Logger *logrus.Logger
+}
+
+// NewTranslationServiceV2 creates a v2 service backed by a v1 service.
+func NewTranslationServiceV2(v1 *TranslationService, logger *logrus.Logger) *TranslationServiceV2 {
+	if logger == nil {
+		logger = v1.Logger
+	}
+	return &TranslationServiceV2{
+		V1:     v1,
+		Logger: logger,
+	}
+}
+
+// log returns the logger for the call with ctx (see RequestLogger).
+func (s *TranslationServiceV2) log(ctx context.Context) *logrus.Entry {
+	return RequestLogger(ctx, s.Logger)
+}
+
+// SubmitTranslation queues content for translation and returns the job.
+func (s *TranslationServiceV2) SubmitTranslation(ctx context.Context, req *nanabushv2.TranslationRequest) (*nanabushv2.TranslationJob, error) {
+	job, err := s.submit(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return jobToV2(job), nil
+}
+
+// GetJob returns a job's state and, once it has succeeded, its result.
+func (s *TranslationServiceV2) GetJob(ctx context.Context, req *nanabushv2.GetJobRequest) (*nanabushv2.TranslationJob, error) {
+	job, err := s.lookup(req.JobId)
+	if err != nil {
+		return nil, err
+	}
+	return jobToV2(job), nil
+}
+
+// WaitJob blocks until the job finishes, wait_seconds elapse, or the call ends.
+func (s *TranslationServiceV2) WaitJob(ctx context.Context, req *nanabushv2.WaitJobRequest) (*nanabushv2.TranslationJob, error) {
+	job, err := s.lookup(req.JobId)
+	if err != nil {
+		return nil, err
+	}
+
+	wait := req.WaitSeconds
+	switch {
+	case wait < 0:
+		return nil, invalidArgument("wait_seconds", "must not be negative")
+	case wait == 0:
+		wait = defaultWaitJobSeconds
+	case wait > maxWaitJobSeconds:
+		wait = maxWaitJobSeconds
+	}
+
+	timer := time.NewTimer(time.Duration(wait) * time.Second)
+	defer timer.Stop()
+
+	select {
+	case <-job.Done():
+	case <-timer.C:
+	case <-ctx.Done():
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
+	return jobToV2(job), nil
+}
+
+// CancelJob cancels a queued or running job.
+func (s *TranslationServiceV2) CancelJob(ctx context.Context, req *nanabushv2.CancelJobRequest) (*nanabushv2.TranslationJob, error) {
+	if _, err := s.V1.CancelTranslation(ctx, &nanabushv1.CancelTranslationRequest{
+		JobId:  req.JobId,
+		Reason: req.Reason,
+	}); err != nil {
+		return nil, err
+	}
+	job, err := s.lookup(req.JobId)
+	if err != nil {
+		return nil, err
+	}
+	return jobToV2(job), nil
+}
+
+// SubmitBatch validates every request, then queues them as one batch of
+// jobs and returns the batch. Nothing is queued if any request is invalid.
+func (s *TranslationServiceV2) SubmitBatch(ctx context.Context, req *nanabushv2.SubmitBatchRequest) (*nanabushv2.Batch, error) {
+	if len(req.Requests) == 0 {
+		return nil, invalidArgument("requests", "is required")
+	}
+	if len(req.Requests) > MaxBatchJobs {
+		return nil, invalidArgument("requests", fmt.Sprintf("must hold at most %d requests, got %d", MaxBatchJobs, len(req.Requests)))
+	}
+	if req.CallbackUrl != "" {
+		if err := validateCallbackURL(req.CallbackUrl); err != nil {
+			return nil, invalidArgument("callback_url", "must be an absolute http or https URL")
+		}
+	}
+
+	v1reqs := make([]*nanabushv1.TranslateRequest, 0, len(req.Requests))
+	for i, r := range req.Requests {
+		prefix := fmt.Sprintf("requests[%d].", i)
+		if r.GetCallbackUrl() != "" {
+			return nil, invalidArgument(prefix+"callback_url", "must be unset; set the batch's callback_url instead")
+		}
+		if err := validateV2Request(r); err != nil {
+			return nil, prefixFieldError(err, prefix)
+		}
+		v1req := requestToV1(r)
+		if v1req.Namespace == "" {
+			v1req.Namespace = req.Namespace
+		}
+		v1req.Priority = s.V1.callerPriority(ctx, v1req.Priority)
+		if err := validateTranslateRequest(v1req); err != nil {
+			return nil, prefixFieldError(err, prefix)
+		}
+		if err := s.V1.checkDeadline(ctx, v1req); err != nil {
+			return nil, prefixFieldError(err, prefix)
+		}
+		v1reqs = append(v1reqs, v1req)
+	}
+
+	batch, err := s.V1.JobQueue.CreateBatch(ctx, BatchSpec{
+		RequestID:   req.RequestId,
+		Namespace:   req.Namespace,
+		ClientID:    callerClientID(ctx),
+		CallbackURL: req.CallbackUrl,
+	}, v1reqs)
+	if err != nil {
+		s.log(ctx).WithError(err).Error("v2: failed to create translation batch")
+		if errors.Is(err, ErrIdempotencyConflict) {
+			return nil, status.Error(codes.AlreadyExists, err.Error())
+		}
+		return nil, status.Error(codes.Internal, fmt.Sprintf("failed to queue translation batch: %v", err))
+	}
+	return batchToV2(batch), nil
+}
+
+// GetBatch returns a batch's aggregate state and its jobs' states.
+func (s *TranslationServiceV2) GetBatch(ctx context.Context, req *nanabushv2.GetBatchRequest) (*nanabushv2.Batch, error) {
+	if req.BatchId == "" {
+		return nil, invalidArgument("batch_id", "is required")
+	}
+	batch, err := s.V1.JobQueue.GetBatch(req.BatchId)
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	return batchToV2(batch), nil
+}
+
+// Translate submits a job and waits for it to finish. If the call ends first,
+// a job without a request_id is cancelled; one with a request_id is left to
+// finish so a retry with the same request_id picks up its result.
+func (s *TranslationServiceV2) Translate(ctx context.Context, req *nanabushv2.TranslationRequest) (*nanabushv2.TranslationResult, error) {
+	if req.NotBefore != nil {
+		return nil, invalidArgument("not_before", "is only supported by SubmitTranslation")
+	}
+	job, err := s.submit(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case <-job.Done():
+	case <-ctx.Done():
+		if req.RequestId == "" {
+			_, _ = s.V1.JobQueue.Cancel(job.ID, "client went away")
+		}
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
+
+	title, markdown, resultErr := job.Result()
+
+	job.mu.RLock()
+	defer job.mu.RUnlock()
+
+	switch job.Status {
+	case JobStatusCompleted:
+		if resultErr != nil {
+			return nil, resultStatusError(resultErr)
+		}
+		return jobResultToV2(job, title, markdown), nil
+	case JobStatusFailed:
+		return nil, classError(job.ErrorClass, job.Error, job.SourceLang, job.TargetLang)
+	default:
+		return nil, classError(translate.ErrorClassCancelled, job.ProgressMessage, job.SourceLang, job.TargetLang)
+	}
+}
+
+// PutSchedule creates or replaces a recurring translation schedule.
+func (s *TranslationServiceV2) PutSchedule(ctx context.Context, req *nanabushv2.PutScheduleRequest) (*nanabushv2.Schedule, error) {
+	schedule := req.Schedule
+	if schedule == nil {
+		return nil, invalidArgument("schedule", "is required")
+	}
+	if schedule.Request == nil {
+		return nil, invalidArgument("request", "is required")
+	}
+	if schedule.Request.NotBefore != nil {
+		return nil, invalidArgument("not_before", "is not supported in schedules")
+	}
+	if err := validateV2Request(schedule.Request); err != nil {
+		return nil, err
+	}
+	if err := validateTranslateRequest(requestToV1(schedule.Request)); err != nil {
+		return nil, err
+	}
+
+	saved, err := s.V1.Schedules.Put(JobSchedule{
+		Name:     schedule.Name,
+		Cron:     schedule.Cron,
+		TimeZone: schedule.TimeZone,
+		Request:  schedule.Request,
+		Always:   schedule.Always,
+	})
+	if err != nil {
+		var fieldErr *ScheduleFieldError
+		if errors.As(err, &fieldErr) {
+			return nil, invalidArgument(fieldErr.Field, fieldErr.Description)
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return saved.ToProto(), nil
+}
+
+// GetSchedule returns a schedule and its last run.
+func (s *TranslationServiceV2) GetSchedule(ctx context.Context, req *nanabushv2.GetScheduleRequest) (*nanabushv2.Schedule, error) {
+	schedule, err := s.V1.Schedules.Get(req.Name)
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	return schedule.ToProto(), nil
+}
+
+// ListSchedules returns all schedules, by name.
+func (s *TranslationServiceV2) ListSchedules(ctx context.Context, req *nanabushv2.ListSchedulesRequest) (*nanabushv2.ListSchedulesResponse, error) {
+	resp := &nanabushv2.ListSchedulesResponse{}
+	for _, schedule := range s.V1.Schedules.List() {
+		resp.Schedules = append(resp.Schedules, schedule.ToProto())
+	}
+	return resp, nil
+}
+
+// DeleteSchedule removes a schedule.
+func (s *TranslationServiceV2) DeleteSchedule(ctx context.Context, req *nanabushv2.DeleteScheduleRequest) (*nanabushv2.DeleteScheduleResponse, error) {
+	if err := s.V1.Schedules.Delete(req.Name); err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	return &nanabushv2.DeleteScheduleResponse{}, nil
+}
+
+// submit validates a v2 request and queues it as a job via the v1 service.
+func (s *TranslationServiceV2) submit(ctx context.Context, req *nanabushv2.TranslationRequest) (*TranslationJob, error) {
+	if err := validateV2Request(req); err != nil {
+		s.log(ctx).WithError(err).Warn("v2: invalid translation request")
+		return nil, err
+	}
+
+	v1req := requestToV1(req)
+	resp, err := s.V1.SubmitTranslation(ctx, v1req)
+	if err != nil {
+		return nil, err
+	}
+	return s.lookup(resp.JobId)
+}
+
+// lookup returns a job by ID as a status error if it doesn't exist.
+func (s *TranslationServiceV2) lookup(jobID string) (*TranslationJob, error) {
+	if jobID == "" {
+		return nil, invalidArgument("job_id", "is required")
+	}
+	job, err := s.V1.JobQueue.GetJob(jobID)
+	if err != nil {
+		if errors.Is(err, ErrJobNotFound) {
+			return nil, status.Error(codes.NotFound, err.Error())
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return job, nil
+}
+
+// validateV2Request checks the fields v2 requires: BCP 47 languages and content.
+func validateV2Request(req *nanabushv2.TranslationRequest) error {
+	for _, f := range []struct{ field, tag string }{
+		{"source_language", req.SourceLanguage},
+		{"target_language", req.TargetLanguage},
+	} {
+		if f.tag == "" {
+			return invalidArgument(f.field, "is required")
+		}
+		if _, err := language.Parse(f.tag); err != nil {
+			return invalidArgument(f.field, fmt.Sprintf("must be a BCP 47 language tag, got %q", f.tag))
+		}
+	}
+
+	switch c := req.Content.(type) {
+	case *nanabushv2.TranslationRequest_Text:
+		if c.Text == "" {
+			return invalidArgument("text", "must not be empty")
+		}
+	case *nanabushv2.TranslationRequest_Document:
+		if c.Document == nil {
+			return invalidArgument("document", "is required")
+		}
+	default:
+		return invalidArgument("content", "one of text or document is required")
+	}
+	return nil
+}
+
+// requestToV1 converts a v2 request into the equivalent v1 request.
+func requestToV1(req *nanabushv2.TranslationRequest) *nanabushv1.TranslateRequest {
+	requestID := req.RequestId
+	if requestID == "" {
+		requestID = uuid.New().String()
+	}
+
+	v1req := &nanabushv1.TranslateRequest{
+		JobId:           requestID,
+		Namespace:       req.Namespace,
+		SourceLanguage:  req.SourceLanguage,
+		TargetLanguage:  req.TargetLanguage,
+		RequestedAt:     timestamppb.Now(),
+		LocalizeFormats: req.LocalizeFormats,
+		Priority:        nanabushv1.Priority(req.Priority),
+		DeadlineSeconds: req.DeadlineSeconds,
+		IdempotencyKey:  req.RequestId,
+		CallbackUrl:     req.CallbackUrl,
+		NotBefore:       req.NotBefore,
+	}
+	switch c := req.Content.(type) {
+	case *nanabushv2.TranslationRequest_Text:
+		v1req.Primitive = nanabushv1.PrimitiveType_PRIMITIVE_TITLE
+		v1req.Source = &nanabushv1.TranslateRequest_Title{Title: c.Text}
+	case *nanabushv2.TranslationRequest_Document:
+		v1req.Primitive = nanabushv1.PrimitiveType_PRIMITIVE_DOC_TRANSLATE
+		v1req.Source = &nanabushv1.TranslateRequest_Doc{Doc: &nanabushv1.DocumentContent{
+			Title:    c.Document.Title,
+			Markdown: c.Document.Markdown,
+			Metadata: c.Document.Metadata,
+		}}
+	}
+	return v1req
+}
+
+// jobToV2 converts a job into its v2 representation (thread-safe). An
+// offloaded result is read from the result store; if it can't be, or has
+// expired, the job is returned without its result.
+func jobToV2(job *TranslationJob) *nanabushv2.TranslationJob {
+	position := job.QueuePosition()
+	result := job.ResultInfo()
+	title, markdown, resultErr := job.Result()
+	job.mu.RLock()
+	defer job.mu.RUnlock()
+
+	out := &nanabushv2.TranslationJob{
+		JobId:           job.ID,
+		RequestId:       job.RequestID,
+		State:           jobStateToV2(job.Status),
+		ProgressPercent: job.ProgressPercent,
+		ProgressMessage: job.ProgressMessage,
+		Priority:        nanabushv2.Priority(priorityToProto(job.Priority)),
+		CreatedAt:       timestamppb.New(job.CreatedAt),
+		StartedAt:       timestampOrNil(job.StartedAt),
+		CompletedAt:     timestampOrNil(job.CompletedAt),
+		QueuePosition:   int32(position),
+		NotBefore:       timestampOrNil(job.NotBefore),
+		ResultUrl:       result.URL,
+		ResultExpiresAt: timestampOrNil(result.ExpiresAt),
+		DuplicateOf:     job.DuplicateOf,
+		BatchId:         job.BatchID,
+		PausedBy:        job.PausedBy,
+		Usage: &nanabushv2.JobUsage{
+			Characters:    job.Usage.Characters,
+			Chunks:        int32(job.Usage.Chunks),
+			EngineSeconds: job.Usage.EngineTime.Seconds(),
+			QueueSeconds:  job.Usage.QueueTime.Seconds(),
+			EstimatedCost: job.Usage.EstimatedCost,
+		},
+	}
+	switch job.Status {
+	case JobStatusCompleted:
+		if resultErr == nil {
+			out.Result = jobResultToV2(job, title, markdown)
+		}
+	case JobStatusFailed:
+		_, reason := classStatus(job.ErrorClass)
+		out.Error = &nanabushv2.JobError{
+			Reason:    reason,
+			Message:   job.Error,
+			Retryable: job.ErrorClass.Retryable(),
+		}
+	case JobStatusCancelled:
+		out.Error = &nanabushv2.JobError{
+			Reason:  ReasonCancelled,
+			Message: job.ProgressMessage,
+		}
+	}
+	out.Attempt = int32(job.Attempt)
+	for _, attempt := range job.Attempts {
+		_, reason := classStatus(attempt.ErrorClass)
+		out.FailedAttempts = append(out.FailedAttempts, &nanabushv2.JobAttempt{
+			Attempt:   int32(attempt.Attempt),
+			StartedAt: timestamppb.New(attempt.StartedAt),
+			EndedAt:   timestamppb.New(attempt.EndedAt),
+			Error: &nanabushv2.JobError{
+				Reason:    reason,
+				Message:   attempt.Error,
+				Retryable: attempt.ErrorClass.Retryable(),
+			},
+			RetryDelaySeconds: attempt.RetryDelay.Seconds(),
+		})
+	}
+	for _, event := range job.Progress {
+		out.ProgressHistory = append(out.ProgressHistory, &nanabushv2.ProgressEvent{
+			Sequence:        int64(event.Seq),
+			Time:            timestamppb.New(event.Time),
+			State:           jobStateToV2(event.Status),
+			ProgressPercent: event.Percent,
+			Message:         event.Message,
+			Chunk:           int32(event.Chunk),
+			TotalChunks:     int32(event.TotalChunks),
+		})
+	}
+	return out
+}
+
+// batchToV2 converts a batch into its v2 representation (thread-safe).
+func batchToV2(batch *JobBatch) *nanabushv2.Batch {
+	st := batch.Status()
+	out := &nanabushv2.Batch{
+		BatchId:         batch.ID,
+		RequestId:       batch.RequestID,
+		Namespace:       batch.Namespace,
+		State:           batchStateToV2(st.State),
+		ProgressPercent: st.ProgressPercent,
+		CreatedAt:       timestamppb.New(batch.CreatedAt),
+		CompletedAt:     timestampOrNil(st.CompletedAt),
+		TotalJobs:       int32(len(batch.Jobs)),
+		QueuedJobs:      int32(st.Queued),
+		RunningJobs:     int32(st.Running),
+		SucceededJobs:   int32(st.Succeeded),
+		FailedJobs:      int32(st.Failed),
+		CancelledJobs:   int32(st.Cancelled),
+	}
+	for _, js := range st.Jobs {
+		job := &nanabushv2.BatchJob{
+			JobId:           js.JobID,
+			RequestId:       js.RequestID,
+			State:           jobStateToV2(js.Status),
+			ProgressPercent: js.ProgressPercent,
+		}
+		switch js.Status {
+		case JobStatusFailed:
+			_, reason := classStatus(js.ErrorClass)
+			job.Error = &nanabushv2.JobError{
+				Reason:    reason,
+				Message:   js.Error,
+				Retryable: js.ErrorClass.Retryable(),
+			}
+		case JobStatusCancelled:
+			job.Error = &nanabushv2.JobError{
+				Reason:  ReasonCancelled,
+				Message: js.Message,
+			}
+		}
+		out.Jobs = append(out.Jobs, job)
+	}
+	return out
+}
+
+// batchStateToV2 maps a batch state to the v2 BatchState enum.
+func batchStateToV2(s BatchState) nanabushv2.BatchState {
+	switch s {
+	case BatchStateRunning:
+		return nanabushv2.BatchState_BATCH_STATE_RUNNING
+	case BatchStateSucceeded:
+		return nanabushv2.BatchState_BATCH_STATE_SUCCEEDED
+	case BatchStatePartiallyFailed:
+		return nanabushv2.BatchState_BATCH_STATE_PARTIALLY_FAILED
+	case BatchStateFailed:
+		return nanabushv2.BatchState_BATCH_STATE_FAILED
+	default:
+		return nanabushv2.BatchState_BATCH_STATE_UNSPECIFIED
+	}
+}
+
+// prefixFieldError returns an INVALID_ARGUMENT error for a field of a
+// nested message with the field reported under prefix (e.g.
+// "requests[3]."). Other errors are returned unchanged.
+func prefixFieldError(err error, prefix string) error {
+	st, ok := status.FromError(err)
+	if !ok || st.Code() != codes.InvalidArgument {
+		return err
+	}
+	for _, detail := range st.Details() {
+		if br, ok := detail.(*errdetails.BadRequest); ok && len(br.FieldViolations) > 0 {
+			fv := br.FieldViolations[0]
+			return invalidArgument(prefix+fv.Field, fv.Description)
+		}
+	}
+	return err
+}
+
+// jobResultToV2 builds the result of a completed job from its translated
+// title and markdown (see TranslationJob.Result). Callers hold job.mu.
+func jobResultToV2(job *TranslationJob, title, markdown string) *nanabushv2.TranslationResult {
+	result := &nanabushv2.TranslationResult{InferenceTimeSeconds: job.InferenceTime}
+	if job.Primitive == nanabushv1.PrimitiveType_PRIMITIVE_TITLE {
+		result.Content = &nanabushv2.TranslationResult_Text{Text: title}
+		return result
+	}
+	doc := &nanabushv2.Document{
+		Title:    title,
+		Markdown: markdown,
+	}
+	if job.Document != nil {
+		doc.Metadata = job.Document.Metadata
+	}
+	result.Content = &nanabushv2.TranslationResult_Document{Document: doc}
+	return result
+}
+
+// jobStateToV2 maps a job status to the v2 JobState enum.
+func jobStateToV2(s TranslationJobStatus) nanabushv2.JobState {
+	switch s {
+	case JobStatusQueued:
+		return nanabushv2.JobState_JOB_STATE_QUEUED
+	case JobStatusProcessing:
+		return nanabushv2.JobState_JOB_STATE_RUNNING
+	case JobStatusPaused:
+		return nanabushv2.JobState_JOB_STATE_PAUSED
+	case JobStatusCompleted:
+		return nanabushv2.JobState_JOB_STATE_SUCCEEDED
+	case JobStatusFailed:
+		return nanabushv2.JobState_JOB_STATE_FAILED
+	case JobStatusCancelled:
+		return nanabushv2.JobState_JOB_STATE_CANCELLED
+	default:
+		return nanabushv2.JobState_JOB_STATE_UNSPECIFIED
+	}
+}
@@ -0,0 +1,296 @@
+package tracing
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultServiceName is the service.name spans are exported with.
+	DefaultServiceName = "iskoces"
+
+	// spanBuffer is how many ended spans can wait to be exported; further
+	// spans are dropped rather than slowing down requests.
+	spanBuffer = 4096
+	// spanBatch is the most spans exported in one request.
+	spanBatch = 512
+	// exportInterval is how long spans wait to be batched with others.
+	exportInterval = 5 * time.Second
+	// exportTimeout bounds one export request.
+	exportTimeout = 10 * time.Second
+	// flushTimeout bounds exporting the buffered spans at shutdown.
+	flushTimeout = 5 * time.Second
+)
+
+var spansTotal = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "iskoces_trace_spans_total",
+		Help: "Total number of trace spans, by outcome (exported, failed, dropped)",
+	},
+	[]string{"outcome"},
+)
+
+// ExporterConfig configures an Exporter.
+type ExporterConfig struct {
+	// Endpoint is the collector's OTLP/HTTP base URL, e.g.
+	// http://otel-collector:4318; spans are POSTed to its /v1/traces.
+	Endpoint string
+	// ServiceName and ServiceVersion describe this server in the spans'
+	// resource (default service name: DefaultServiceName).
+	ServiceName    string
+	ServiceVersion string
+	// SampleRatio is the fraction of traces started here that are
+	// recorded; traces continued from a caller follow the caller's
+	// sampling decision.
+	SampleRatio float64
+}
+
+// Exporter batches ended spans and sends them to an OpenTelemetry
+// collector as OTLP/HTTP JSON in the background, so a slow or unreachable
+// collector never holds up requests.
+type Exporter struct {
+	config ExporterConfig
+	url    string
+	client *http.Client
+	logger *logrus.Logger
+
+	spans    chan *Span
+	quit     chan struct{} // Closed to stop; the buffered spans are still exported
+	stopOnce sync.Once
+	stopped  chan struct{}
+}
+
+// NewExporter starts an exporter for config.Endpoint. Install it with
+// SetExporter.
+func NewExporter(config ExporterConfig, logger *logrus.Logger) (*Exporter, error) {
+	if !strings.HasPrefix(config.Endpoint, "http://") && !strings.HasPrefix(config.Endpoint, "https://") {
+		return nil, fmt.Errorf("unsupported OTLP endpoint %q (want http:// or https://)", config.Endpoint)
+	}
+	if config.SampleRatio < 0 || config.SampleRatio > 1 {
+		return nil, fmt.Errorf("sample ratio %v is not between 0 and 1", config.SampleRatio)
+	}
+	if config.ServiceName == "" {
+		config.ServiceName = DefaultServiceName
+	}
+	e := &Exporter{
+		config:  config,
+		url:     strings.TrimSuffix(config.Endpoint, "/") + "/v1/traces",
+		client:  &http.Client{Timeout: exportTimeout},
+		logger:  logger,
+		spans:   make(chan *Span, spanBuffer),
+		quit:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	if strings.HasSuffix(strings.TrimSuffix(config.Endpoint, "/"), "/v1/traces") {
+		e.url = strings.TrimSuffix(config.Endpoint, "/")
+	}
+	go e.run()
+	return e, nil
+}
+
+// sample decides whether a new trace is recorded.
+func (e *Exporter) sample(traceID [16]byte) bool {
+	return sampledBelow(traceID, e.config.SampleRatio)
+}
+
+// export queues an ended span.
+func (e *Exporter) export(span *Span) {
+	select {
+	case e.spans <- span:
+	default:
+		spansTotal.WithLabelValues("dropped").Inc()
+	}
+}
+
+// run exports spans in batches every exportInterval, or sooner when a
+// batch fills up, until the exporter is stopped and its buffer is empty.
+func (e *Exporter) run() {
+	defer close(e.stopped)
+	ticker := time.NewTicker(exportInterval)
+	defer ticker.Stop()
+
+	var batch []*Span
+	for {
+		select {
+		case span := <-e.spans:
+			if batch = append(batch, span); len(batch) < spanBatch {
+				continue
+			}
+		case <-ticker.C:
+		case <-e.quit:
+		drain:
+			for {
+				select {
+				case span := <-e.spans:
+					if batch = append(batch, span); len(batch) == spanBatch {
+						e.send(batch)
+						batch = nil
+					}
+				default:
+					break drain
+				}
+			}
+			e.send(batch)
+			return
+		}
+		e.send(batch)
+		batch = nil
+	}
+}
+
+// send exports a batch, logging failures: spans aren't retried.
+func (e *Exporter) send(batch []*Span) {
+	if len(batch) == 0 {
+		return
+	}
+	if err := e.post(batch); err != nil {
+		spansTotal.WithLabelValues("failed").Add(float64(len(batch)))
+		e.logger.WithError(err).WithField("spans", len(batch)).Warn("Failed to export trace spans")
+		return
+	}
+	spansTotal.WithLabelValues("exported").Add(float64(len(batch)))
+}
+
+// post sends spans to the collector in an ExportTraceServiceRequest.
+func (e *Exporter) post(batch []*Span) error {
+	spans := make([]otlpSpan, 0, len(batch))
+	for _, span := range batch {
+		spans = append(spans, span.otlp())
+	}
+	resource := []otlpAttribute{otlpAttr(String("service.name", e.config.ServiceName))}
+	if e.config.ServiceVersion != "" {
+		resource = append(resource, otlpAttr(String("service.version", e.config.ServiceVersion)))
+	}
+	body, err := json.Marshal(otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource: otlpResource{Attributes: resource},
+		ScopeSpans: []otlpScopeSpans{{
+			Scope: otlpScope{Name: "github.com/dasmlab/iskoces"},
+			Spans: spans,
+		}},
+	}}})
+	if err != nil {
+		return err
+	}
+
+	resp, err := e.client.Post(e.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("collector answered %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// Stop exports the spans still buffered, waiting up to flushTimeout. Spans
+// ended afterwards are dropped.
+func (e *Exporter) Stop() {
+	e.stopOnce.Do(func() {
+		close(e.quit)
+		select {
+		case <-e.stopped:
+		case <-time.After(flushTimeout):
+			e.logger.Warn("Timed out exporting the remaining trace spans")
+		}
+	})
+}
+
+// OTLP/HTTP JSON encoding of an ExportTraceServiceRequest, see
+// https://opentelemetry.io/docs/specs/otlp/#json-protobuf-encoding. IDs are
+// hex and 64-bit integers are strings.
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              SpanKind        `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"` // 0 = unset, 2 = error
+	Message string `json:"message,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string         `json:"key"`
+	Value map[string]any `json:"value"`
+}
+
+// otlp encodes the span. It has ended, so its fields no longer change.
+func (s *Span) otlp() otlpSpan {
+	out := otlpSpan{
+		TraceID:           hex.EncodeToString(s.sc.TraceID[:]),
+		SpanID:            hex.EncodeToString(s.sc.SpanID[:]),
+		Name:              s.name,
+		Kind:              s.kind,
+		StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+	}
+	if s.parent != [8]byte{} {
+		out.ParentSpanID = hex.EncodeToString(s.parent[:])
+	}
+	for _, attr := range s.attrs {
+		out.Attributes = append(out.Attributes, otlpAttr(attr))
+	}
+	if s.failed {
+		out.Status = otlpStatus{Code: 2, Message: s.errMsg}
+	}
+	return out
+}
+
+// otlpAttr encodes an attribute as an OTLP KeyValue.
+func otlpAttr(attr Attribute) otlpAttribute {
+	var value map[string]any
+	switch v := attr.Value.(type) {
+	case int64:
+		value = map[string]any{"intValue": strconv.FormatInt(v, 10)}
+	case float64:
+		value = map[string]any{"doubleValue": v}
+	case bool:
+		value = map[string]any{"boolValue": v}
+	default:
+		value = map[string]any{"stringValue": fmt.Sprint(v)}
+	}
+	return otlpAttribute{Key: attr.Key, Value: value}
+}
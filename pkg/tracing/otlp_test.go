@@ -0,0 +1,193 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// testSpans returns ended spans with fixed IDs and times: a server span
+// continuing a caller's trace, and a failed client span within it.
+func testSpans(t *testing.T) []*Span {
+	var server, client Span
+	copy(server.sc.TraceID[:], mustHex(t, "4bf92f3577b34da6a3ce929d0e0e4736"))
+	copy(server.sc.SpanID[:], mustHex(t, "53995c3f42cd8ad8"))
+	copy(server.parent[:], mustHex(t, "00f067aa0ba902b7"))
+	server.sc.Sampled = true
+	server.name = "iskoces.v1.TranslationService/Translate"
+	server.kind = SpanKindServer
+	server.start = time.Unix(1700000000, 123456789)
+	server.end = time.Unix(1700000001, 987654321)
+	server.attrs = []Attribute{
+		String("rpc.system", "grpc"),
+		Int("rpc.grpc.status_code", 0),
+		Int64("chars", 1<<40),
+		Bool("cached", false),
+		{Key: "ratio", Value: 0.25},
+	}
+
+	client.sc = server.sc
+	copy(client.sc.SpanID[:], mustHex(t, "0102030405060708"))
+	client.parent = server.sc.SpanID
+	client.name = "worker.round_trip"
+	client.kind = SpanKindClient
+	client.start = time.Unix(1700000000, 500000000)
+	client.end = time.Unix(1700000001, 0)
+	client.attrs = []Attribute{Int("worker_id", 3)}
+	client.failed = true
+	client.errMsg = `worker "3" crashed`
+
+	var internal Span
+	copy(internal.sc.TraceID[:], mustHex(t, "0af7651916cd43dd8448eb211c80319c"))
+	copy(internal.sc.SpanID[:], mustHex(t, "b7ad6b7169203331"))
+	internal.name = "job.process"
+	internal.kind = SpanKindInternal
+	internal.start = time.Unix(1700000002, 0)
+	internal.end = time.Unix(1700000002, 1)
+	return []*Span{&server, &client, &internal}
+}
+
+// The exported ExportTraceServiceRequest is compared with
+// testdata/otlp_request.json; run with -update to rewrite it.
+func TestExportGolden(t *testing.T) {
+	c := newCollector(t)
+	e, err := NewExporter(ExporterConfig{Endpoint: c.server.URL + "/", ServiceName: "translator", ServiceVersion: "1.2.3"}, logrus.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer e.Stop()
+	if err := e.post(testSpans(t)); err != nil {
+		t.Fatalf("post() = %v", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.bodies) != 1 {
+		t.Fatalf("collector got %d requests, want 1", len(c.bodies))
+	}
+	if c.paths[0] != "/v1/traces" || c.types[0] != "application/json" {
+		t.Errorf("request to %s as %s, want /v1/traces as application/json", c.paths[0], c.types[0])
+	}
+	var got bytes.Buffer
+	if err := json.Indent(&got, c.bodies[0], "", "  "); err != nil {
+		t.Fatalf("request isn't JSON: %v", err)
+	}
+	got.WriteByte('\n')
+
+	golden := filepath.Join("testdata", "otlp_request.json")
+	if *update {
+		if err := os.WriteFile(golden, got.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.String() != string(want) {
+		t.Errorf("request =\n%s\nwant\n%s", got.String(), want)
+	}
+}
+
+func TestExporterEndpoint(t *testing.T) {
+	for _, tt := range []struct {
+		endpoint string
+		url      string
+	}{
+		{"http://collector:4318", "http://collector:4318/v1/traces"},
+		{"https://collector:4318/", "https://collector:4318/v1/traces"},
+		{"http://collector:4318/v1/traces", "http://collector:4318/v1/traces"},
+		{"http://gateway/otel/", "http://gateway/otel/v1/traces"},
+	} {
+		e, err := NewExporter(ExporterConfig{Endpoint: tt.endpoint}, logrus.New())
+		if err != nil {
+			t.Fatalf("NewExporter(%s) = %v", tt.endpoint, err)
+		}
+		e.Stop()
+		if e.url != tt.url {
+			t.Errorf("NewExporter(%s) URL = %s, want %s", tt.endpoint, e.url, tt.url)
+		}
+	}
+	for _, config := range []ExporterConfig{
+		{Endpoint: "collector:4318"},
+		{Endpoint: "grpc://collector:4317"},
+		{Endpoint: "http://collector:4318", SampleRatio: 1.5},
+	} {
+		if _, err := NewExporter(config, logrus.New()); err == nil {
+			t.Errorf("NewExporter(%+v) succeeded", config)
+		}
+	}
+}
+
+// Ended spans are exported in a batch when the exporter stops.
+func TestExportOnStop(t *testing.T) {
+	c := newCollector(t)
+	e := installExporter(t, c, 1)
+
+	ctx, parent := StartKind(context.Background(), SpanKindServer, "call")
+	_, child := Start(ctx, "work", String("key", "value"))
+	child.RecordError(errors.New("failed"))
+	child.End()
+	child.End() // Only the first End counts
+	parent.End()
+	e.Stop()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.bodies) != 1 {
+		t.Fatalf("collector got %d requests, want 1", len(c.bodies))
+	}
+	var req otlpRequest
+	if err := json.Unmarshal(c.bodies[0], &req); err != nil {
+		t.Fatal(err)
+	}
+	spans := req.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("exported %d spans, want 2", len(spans))
+	}
+	if spans[0].Name != "work" || spans[0].ParentSpanID != spans[1].SpanID || spans[0].TraceID != spans[1].TraceID {
+		t.Errorf("child span = %+v, want a child of %+v", spans[0], spans[1])
+	}
+	if spans[0].Status.Code != 2 || spans[0].Status.Message != "failed" {
+		t.Errorf("child status = %+v, want the error", spans[0].Status)
+	}
+	if spans[1].Kind != SpanKindServer || spans[1].ParentSpanID != "" {
+		t.Errorf("parent span = %+v, want a root server span", spans[1])
+	}
+	if got := req.ResourceSpans[0].Resource.Attributes[0]; got.Key != "service.name" || got.Value["stringValue"] != DefaultServiceName {
+		t.Errorf("resource attribute = %+v, want the default service name", got)
+	}
+}
+
+func TestExportCollectorError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		http.Error(w, "bad spans", http.StatusBadRequest)
+	}))
+	defer server.Close()
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	e, err := NewExporter(ExporterConfig{Endpoint: server.URL}, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer e.Stop()
+	err = e.post(testSpans(t))
+	if err == nil || !strings.Contains(err.Error(), "400") || !strings.Contains(err.Error(), "bad spans") {
+		t.Errorf("post() = %v, want the collector's answer", err)
+	}
+}
@@ -0,0 +1,256 @@
+// Package tracing records OpenTelemetry spans for requests as they go from
+// the gRPC and HTTP APIs through job processing to the translation workers,
+// and exports them to an OpenTelemetry collector over OTLP/HTTP (see
+// Exporter). Trace context is propagated with W3C traceparent headers.
+//
+// Spans are only recorded once an exporter is installed with SetExporter;
+// until then Start returns nil spans, whose methods do nothing, so
+// instrumented code doesn't need to check whether tracing is enabled.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TraceparentHeader is the W3C Trace Context header (and gRPC metadata
+// key) carrying the caller's trace and span.
+const TraceparentHeader = "traceparent"
+
+// SpanKind says what a span represents, with OTLP's values.
+type SpanKind int
+
+const (
+	SpanKindInternal SpanKind = 1 // An operation within the server
+	SpanKindServer   SpanKind = 2 // Handling a call from a client
+	SpanKindClient   SpanKind = 3 // A call to another service, such as a worker
+)
+
+// SpanContext identifies a span within its trace.
+type SpanContext struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+	Sampled bool // The trace is recorded
+}
+
+// IsValid reports whether sc identifies a span.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != [16]byte{} && sc.SpanID != [8]byte{}
+}
+
+// Traceparent formats sc as a traceparent header value.
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return fmt.Sprintf("00-%s-%s-%s", hex.EncodeToString(sc.TraceID[:]), hex.EncodeToString(sc.SpanID[:]), flags)
+}
+
+// ParseTraceparent parses a traceparent header value. Versions after 00
+// are accepted as long as they start with the fields of version 00. The
+// fields are lowercase hex, as W3C Trace Context requires.
+func ParseTraceparent(value string) (SpanContext, bool) {
+	var sc SpanContext
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return sc, false
+	}
+	if len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return sc, false
+	}
+	for _, part := range parts[:4] {
+		if strings.ContainsFunc(part, func(r rune) bool { return (r < '0' || r > '9') && (r < 'a' || r > 'f') }) {
+			return sc, false
+		}
+	}
+	hex.Decode(sc.TraceID[:], []byte(parts[1]))
+	hex.Decode(sc.SpanID[:], []byte(parts[2]))
+	var flags [1]byte
+	hex.Decode(flags[:], []byte(parts[3]))
+	sc.Sampled = flags[0]&1 == 1
+	if !sc.IsValid() {
+		return SpanContext{}, false
+	}
+	return sc, true
+}
+
+// Attribute is a key/value pair describing a span.
+type Attribute struct {
+	Key   string
+	Value any // string, int64, float64 or bool
+}
+
+// String returns a string attribute.
+func String(key, value string) Attribute { return Attribute{key, value} }
+
+// Int returns an integer attribute.
+func Int(key string, value int) Attribute { return Attribute{key, int64(value)} }
+
+// Int64 returns an integer attribute.
+func Int64(key string, value int64) Attribute { return Attribute{key, value} }
+
+// Bool returns a boolean attribute.
+func Bool(key string, value bool) Attribute { return Attribute{key, value} }
+
+// Span is a timed operation within a trace. A nil *Span is valid and
+// records nothing.
+type Span struct {
+	exporter *Exporter // nil when the trace isn't sampled
+	sc       SpanContext
+	parent   [8]byte
+	name     string
+	kind     SpanKind
+	start    time.Time
+
+	mu     sync.Mutex
+	end    time.Time
+	attrs  []Attribute
+	errMsg string // Set when the operation failed
+	failed bool
+	ended  bool
+}
+
+// SpanContext returns the span's identity, to propagate it or to parent
+// later spans on it.
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.sc
+}
+
+// SetAttributes adds attributes to the span.
+func (s *Span) SetAttributes(attrs ...Attribute) {
+	if s == nil || s.exporter == nil {
+		return
+	}
+	s.mu.Lock()
+	s.attrs = append(s.attrs, attrs...)
+	s.mu.Unlock()
+}
+
+// RecordError marks the span as failed with err, if err isn't nil.
+func (s *Span) RecordError(err error) {
+	if s == nil || s.exporter == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	s.failed = true
+	s.errMsg = err.Error()
+	s.mu.Unlock()
+}
+
+// End ends the span and queues it for export. Only the first call has an
+// effect.
+func (s *Span) End() {
+	if s == nil || s.exporter == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = time.Now()
+	s.mu.Unlock()
+	s.exporter.export(s)
+}
+
+// spanKey is the context key of the current span.
+type spanKey struct{}
+
+// remoteKey is the context key of a parent SpanContext without a local
+// span, such as the caller's.
+type remoteKey struct{}
+
+// SpanFromContext returns the current span in ctx, or nil.
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// SpanContextFromContext returns the context of the current span in ctx,
+// or of the parent set with ContextWithParent.
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	if span := SpanFromContext(ctx); span != nil {
+		return span.sc
+	}
+	sc, _ := ctx.Value(remoteKey{}).(SpanContext)
+	return sc
+}
+
+// ContextWithParent returns ctx with sc as the parent of the spans started
+// from it, e.g. the caller's span from a traceparent header, or the span
+// that submitted an async job. An invalid sc leaves ctx unchanged.
+func ContextWithParent(ctx context.Context, sc SpanContext) context.Context {
+	if !sc.IsValid() {
+		return ctx
+	}
+	return context.WithValue(context.WithValue(ctx, spanKey{}, (*Span)(nil)), remoteKey{}, sc)
+}
+
+// exporter is where spans go, nil when tracing is disabled.
+var exporter atomic.Pointer[Exporter]
+
+// SetExporter records spans and sends them to e from now on (nil stops
+// recording them).
+func SetExporter(e *Exporter) {
+	exporter.Store(e)
+}
+
+// Enabled reports whether spans are being recorded.
+func Enabled() bool {
+	return exporter.Load() != nil
+}
+
+// Start starts an internal span named name as a child of the current span
+// in ctx, returning a context with the new span. The span must be ended.
+func Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, *Span) {
+	return StartKind(ctx, SpanKindInternal, name, attrs...)
+}
+
+// StartKind starts a span of the given kind, as Start does.
+func StartKind(ctx context.Context, kind SpanKind, name string, attrs ...Attribute) (context.Context, *Span) {
+	e := exporter.Load()
+	if e == nil {
+		return ctx, nil
+	}
+
+	parent := SpanContextFromContext(ctx)
+	span := &Span{name: name, kind: kind, start: time.Now()}
+	if parent.IsValid() {
+		span.sc.TraceID = parent.TraceID
+		span.parent = parent.SpanID
+		span.sc.Sampled = parent.Sampled
+	} else {
+		rand.Read(span.sc.TraceID[:])
+		span.sc.Sampled = e.sample(span.sc.TraceID)
+	}
+	rand.Read(span.sc.SpanID[:])
+	if span.sc.Sampled {
+		span.exporter = e
+		span.attrs = attrs
+	}
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// sampledBelow reports whether traceID falls within ratio of all trace IDs,
+// judging by its last 8 bytes, which W3C Trace Context expects to be random.
+func sampledBelow(traceID [16]byte, ratio float64) bool {
+	switch {
+	case ratio >= 1:
+		return true
+	case ratio <= 0:
+		return false
+	}
+	return binary.BigEndian.Uint64(traceID[8:])>>1 < uint64(ratio*(1<<63))
+}
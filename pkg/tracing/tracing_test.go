@@ -0,0 +1,280 @@
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+// collector is a fake OTLP/HTTP collector recording the request bodies it
+// receives.
+type collector struct {
+	server *httptest.Server
+
+	mu     sync.Mutex
+	paths  []string
+	types  []string
+	bodies [][]byte
+}
+
+func newCollector(t *testing.T) *collector {
+	t.Helper()
+	c := &collector{}
+	c.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		c.mu.Lock()
+		c.paths = append(c.paths, r.URL.Path)
+		c.types = append(c.types, r.Header.Get("Content-Type"))
+		c.bodies = append(c.bodies, body)
+		c.mu.Unlock()
+	}))
+	t.Cleanup(c.server.Close)
+	return c
+}
+
+// installExporter installs an exporter to c sampling ratio of new traces,
+// until the test ends.
+func installExporter(t *testing.T, c *collector, ratio float64) *Exporter {
+	t.Helper()
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	e, err := NewExporter(ExporterConfig{Endpoint: c.server.URL, SampleRatio: ratio}, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetExporter(e)
+	t.Cleanup(func() {
+		SetExporter(nil)
+		e.Stop()
+	})
+	return e
+}
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// The cases follow the W3C Trace Context test suite's traceparent tests.
+func TestParseTraceparent(t *testing.T) {
+	const (
+		traceID = "0af7651916cd43dd8448eb211c80319c"
+		spanID  = "b7ad6b7169203331"
+	)
+	tests := []struct {
+		name    string
+		value   string
+		ok      bool
+		sampled bool
+	}{
+		{name: "sampled", value: "00-" + traceID + "-" + spanID + "-01", ok: true, sampled: true},
+		{name: "not sampled", value: "00-" + traceID + "-" + spanID + "-00", ok: true},
+		{name: "unknown flags ignored", value: "00-" + traceID + "-" + spanID + "-09", ok: true, sampled: true},
+		{name: "unknown flags not sampled", value: "00-" + traceID + "-" + spanID + "-08", ok: true},
+		{name: "surrounding whitespace", value: " \t00-" + traceID + "-" + spanID + "-01 ", ok: true, sampled: true},
+		{name: "future version", value: "cc-" + traceID + "-" + spanID + "-01", ok: true, sampled: true},
+		{name: "future version with more fields", value: "cc-" + traceID + "-" + spanID + "-01-what-the-future-will-be-like", ok: true, sampled: true},
+		{name: "future version without dash", value: "cc-" + traceID + "-" + spanID + "-01.what-the-future-will-be-like"},
+		{name: "version 00 with more fields", value: "00-" + traceID + "-" + spanID + "-01-what-the-future-will-be-like"},
+		{name: "version 00 trailing dash", value: "00-" + traceID + "-" + spanID + "-01-"},
+		{name: "version ff", value: "ff-" + traceID + "-" + spanID + "-01"},
+		{name: "version not hex", value: "0g-" + traceID + "-" + spanID + "-01"},
+		{name: "version too short", value: "0-" + traceID + "-" + spanID + "-01"},
+		{name: "uppercase version", value: "0A-" + traceID + "-" + spanID + "-01"},
+		{name: "uppercase trace ID", value: "00-0AF7651916CD43DD8448EB211C80319C-" + spanID + "-01"},
+		{name: "uppercase span ID", value: "00-" + traceID + "-B7AD6B7169203331-01"},
+		{name: "uppercase flags", value: "00-" + traceID + "-" + spanID + "-0A"},
+		{name: "zero trace ID", value: "00-00000000000000000000000000000000-" + spanID + "-01"},
+		{name: "zero span ID", value: "00-" + traceID + "-0000000000000000-01"},
+		{name: "short trace ID", value: "00-" + traceID[1:] + "-" + spanID + "-01"},
+		{name: "long span ID", value: "00-" + traceID + "-" + spanID + "0-01"},
+		{name: "trace ID not hex", value: "00-" + traceID[:31] + "z-" + spanID + "-01"},
+		{name: "span ID not hex", value: "00-" + traceID + "-" + spanID[:15] + ".-01"},
+		{name: "flags not hex", value: "00-" + traceID + "-" + spanID + "-0x"},
+		{name: "short flags", value: "00-" + traceID + "-" + spanID + "-1"},
+		{name: "missing flags", value: "00-" + traceID + "-" + spanID},
+		{name: "other separator", value: "00_" + traceID + "_" + spanID + "_01"},
+		{name: "empty", value: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc, ok := ParseTraceparent(tt.value)
+			if ok != tt.ok {
+				t.Fatalf("ParseTraceparent(%q) ok = %v, want %v", tt.value, ok, tt.ok)
+			}
+			if !ok {
+				if sc != (SpanContext{}) {
+					t.Errorf("ParseTraceparent(%q) = %+v, want the zero SpanContext", tt.value, sc)
+				}
+				return
+			}
+			if hex.EncodeToString(sc.TraceID[:]) != traceID || hex.EncodeToString(sc.SpanID[:]) != spanID {
+				t.Errorf("ParseTraceparent(%q) = %x-%x", tt.value, sc.TraceID, sc.SpanID)
+			}
+			if sc.Sampled != tt.sampled {
+				t.Errorf("ParseTraceparent(%q) sampled = %v, want %v", tt.value, sc.Sampled, tt.sampled)
+			}
+		})
+	}
+}
+
+func TestTraceparent(t *testing.T) {
+	var sc SpanContext
+	copy(sc.TraceID[:], mustHex(t, "4bf92f3577b34da6a3ce929d0e0e4736"))
+	copy(sc.SpanID[:], mustHex(t, "00f067aa0ba902b7"))
+	if got, want := sc.Traceparent(), "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00"; got != want {
+		t.Errorf("Traceparent() = %s, want %s", got, want)
+	}
+	sc.Sampled = true
+	if got, want := sc.Traceparent(), "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"; got != want {
+		t.Errorf("Traceparent() = %s, want %s", got, want)
+	}
+	if parsed, ok := ParseTraceparent(sc.Traceparent()); !ok || parsed != sc {
+		t.Errorf("ParseTraceparent(Traceparent()) = %+v, %v, want %+v", parsed, ok, sc)
+	}
+}
+
+func TestStartDisabled(t *testing.T) {
+	ctx := context.Background()
+	got, span := Start(ctx, "op", String("key", "value"))
+	if span != nil || got != ctx {
+		t.Fatalf("Start() without an exporter = %v, %v, want the context unchanged and no span", got, span)
+	}
+	// A nil span's methods do nothing
+	span.SetAttributes(Int("n", 1))
+	span.RecordError(io.EOF)
+	span.End()
+	if sc := span.SpanContext(); sc.IsValid() {
+		t.Errorf("nil span context = %+v", sc)
+	}
+}
+
+func TestStartContinuesParent(t *testing.T) {
+	c := newCollector(t)
+	// New traces aren't sampled, but the caller's decision wins
+	installExporter(t, c, 0)
+
+	parent, ok := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	if !ok {
+		t.Fatal("bad parent")
+	}
+	ctx := ContextWithParent(context.Background(), parent)
+	if got := SpanContextFromContext(ctx); got != parent {
+		t.Errorf("SpanContextFromContext() = %+v, want the parent", got)
+	}
+	ctx, span := StartKind(ctx, SpanKindServer, "call")
+	defer span.End()
+	sc := span.SpanContext()
+	if sc.TraceID != parent.TraceID || !sc.Sampled || span.parent != parent.SpanID || span.exporter == nil {
+		t.Fatalf("span = %+v, parent %x, want a recorded child of %+v", sc, span.parent, parent)
+	}
+	if sc.SpanID == parent.SpanID || !sc.IsValid() {
+		t.Errorf("span ID = %x, want a new one", sc.SpanID)
+	}
+	if SpanFromContext(ctx) != span || SpanContextFromContext(ctx) != sc {
+		t.Error("context doesn't carry the new span")
+	}
+
+	// Spans started from the span's context are its children, and what it
+	// propagates onwards names it
+	_, child := Start(ctx, "work")
+	defer child.End()
+	if child.sc.TraceID != parent.TraceID || child.parent != sc.SpanID || !child.sc.Sampled {
+		t.Errorf("child = %+v, parent %x, want a child of %x", child.sc, child.parent, sc.SpanID)
+	}
+	if got, want := sc.Traceparent(), "00-4bf92f3577b34da6a3ce929d0e0e4736-"+hex.EncodeToString(sc.SpanID[:])+"-01"; got != want {
+		t.Errorf("Traceparent() = %s, want %s", got, want)
+	}
+
+	// A parent set on a context with a span replaces it
+	other, _ := ParseTraceparent("00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+	_, span2 := Start(ContextWithParent(ctx, other), "job")
+	defer span2.End()
+	if span2.sc.TraceID != other.TraceID || span2.parent != other.SpanID {
+		t.Errorf("span = %+v, parent %x, want a child of %+v", span2.sc, span2.parent, other)
+	}
+}
+
+func TestStartUnsampledParent(t *testing.T) {
+	c := newCollector(t)
+	// New traces are all sampled, but the caller's decision wins
+	e := installExporter(t, c, 1)
+
+	parent, _ := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	ctx, span := Start(ContextWithParent(context.Background(), parent), "call", String("key", "value"))
+	if span.exporter != nil || span.sc.Sampled || span.sc.TraceID != parent.TraceID {
+		t.Fatalf("span = %+v, want an unrecorded span in the parent's trace", span.sc)
+	}
+	// The decision is propagated
+	_, child := Start(ctx, "work")
+	if child.sc.Sampled || child.sc.TraceID != parent.TraceID {
+		t.Errorf("child = %+v, want unsampled in the parent's trace", child.sc)
+	}
+	if got := span.SpanContext().Traceparent(); got[len(got)-2:] != "00" {
+		t.Errorf("Traceparent() = %s, want flags 00", got)
+	}
+	child.End()
+	span.End()
+
+	e.Stop()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.bodies) != 0 {
+		t.Errorf("collector got %d exports of unsampled spans", len(c.bodies))
+	}
+}
+
+func TestStartNewTrace(t *testing.T) {
+	c := newCollector(t)
+	installExporter(t, c, 1)
+
+	_, a := Start(context.Background(), "a")
+	_, b := Start(context.Background(), "b")
+	defer a.End()
+	defer b.End()
+	if !a.sc.IsValid() || !a.sc.Sampled || a.parent != [8]byte{} {
+		t.Errorf("root span = %+v, parent %x, want a sampled root", a.sc, a.parent)
+	}
+	if a.sc.TraceID == b.sc.TraceID {
+		t.Error("two root spans share a trace ID")
+	}
+
+	// An invalid parent is ignored
+	ctx := context.Background()
+	if got := ContextWithParent(ctx, SpanContext{}); got != ctx {
+		t.Error("ContextWithParent() with an invalid parent changed the context")
+	}
+}
+
+func TestSampledBelow(t *testing.T) {
+	var low, high [16]byte
+	copy(high[8:], mustHex(t, "ffffffffffffffff"))
+	copy(low[8:], mustHex(t, "0000000000000001"))
+	tests := []struct {
+		traceID [16]byte
+		ratio   float64
+		want    bool
+	}{
+		{low, 0, false},
+		{low, 0.01, true},
+		{high, 0.99, false},
+		{high, 1, true},
+		{low, 1.5, true},
+		{high, -1, false},
+	}
+	for _, tt := range tests {
+		if got := sampledBelow(tt.traceID, tt.ratio); got != tt.want {
+			t.Errorf("sampledBelow(%x, %v) = %v, want %v", tt.traceID[8:], tt.ratio, got, tt.want)
+		}
+	}
+}
@@ -122,7 +122,7 @@ func (c *ArgosClient) Translate(ctx context.Context, text, sourceLang, targetLan
 			"status_code": resp.StatusCode,
 			"response":     string(bodyBytes),
 		}).Error("Translation request returned non-OK status")
-		return "", fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(bodyBytes))
+		return "", httpStatusError(resp.StatusCode, string(bodyBytes))
 	}
 
 	// Decode response
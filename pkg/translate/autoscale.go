@@ -0,0 +1,207 @@
+package translate
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/sirupsen/logrus"
+)
+
+// Autoscaling defaults, used for zero fields of ScalingConfig.
+const (
+	DefaultScaleInterval     = 10 * time.Second
+	DefaultScaleUpQueueWait  = 500 * time.Millisecond
+	DefaultScaleUpBusyRatio  = 0.8
+	DefaultWorkerIdleTimeout = 5 * time.Minute
+)
+
+var workerPoolScaleEvents = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "iskoces_worker_pool_scale_events_total",
+		Help: "Total number of worker pool scale events, by direction (up, down)",
+	},
+	[]string{"engine", "direction"},
+)
+
+// ScalingConfig bounds the worker pool and controls when it scales.
+// With MinWorkers equal to MaxWorkers the pool has a fixed size.
+type ScalingConfig struct {
+	// MinWorkers is the number of workers kept running when idle (at least 1).
+	MinWorkers int
+	// MaxWorkers is the most workers the pool will run.
+	MaxWorkers int
+	// Interval is how often the pool re-evaluates its size.
+	Interval time.Duration
+	// ScaleUpQueueWait adds a worker when a request waited longer than this
+	// for a worker since the last evaluation.
+	ScaleUpQueueWait time.Duration
+	// ScaleUpBusyRatio adds a worker when at least this fraction of workers is busy.
+	ScaleUpBusyRatio float64
+	// IdleTimeout stops a worker (down to MinWorkers) that has been idle this long.
+	IdleTimeout time.Duration
+	// Pinned dedicates workers to language pairs, in addition to the
+	// general workers bounded by MinWorkers and MaxWorkers.
+	Pinned []PinnedPair
+}
+
+// withDefaults fills zero fields and clamps the bounds.
+func (c ScalingConfig) withDefaults() ScalingConfig {
+	if c.MaxWorkers < 1 {
+		c.MaxWorkers = 1
+	}
+	if c.MinWorkers < 1 {
+		c.MinWorkers = 1
+	}
+	if c.MinWorkers > c.MaxWorkers {
+		c.MinWorkers = c.MaxWorkers
+	}
+	if c.Interval <= 0 {
+		c.Interval = DefaultScaleInterval
+	}
+	if c.ScaleUpQueueWait <= 0 {
+		c.ScaleUpQueueWait = DefaultScaleUpQueueWait
+	}
+	if c.ScaleUpBusyRatio <= 0 || c.ScaleUpBusyRatio > 1 {
+		c.ScaleUpBusyRatio = DefaultScaleUpBusyRatio
+	}
+	if c.IdleTimeout <= 0 {
+		c.IdleTimeout = DefaultWorkerIdleTimeout
+	}
+	return c
+}
+
+// autoscale re-evaluates the pool size every interval until shutdown.
+func (p *WorkerPool) autoscale() {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(p.scaling.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.shutdown:
+			return
+		case <-ticker.C:
+			p.scale()
+		}
+	}
+}
+
+// scale adds workers while requests are queueing or most workers are busy,
+// and stops one long-idle worker otherwise.
+func (p *WorkerPool) scale() {
+	p.waitMu.Lock()
+	maxWait := p.maxQueueWait
+	p.maxQueueWait = 0
+	p.waitMu.Unlock()
+
+	// A worker translates one request at a time; requests beyond the first
+	// on its connection are queued inside the worker
+	// Pinned and remote workers are never scaled, and the load on
+	// interactive workers doesn't count towards the busy ratio
+	p.workerMu.RLock()
+	total, bulk, busy, queued := 0, 0, 0, 0
+	for _, worker := range p.workers {
+		if worker.pair != "" || worker.remote() {
+			continue
+		}
+		total++
+		if !worker.interactive {
+			bulk++
+		}
+		worker.mu.Lock()
+		if worker.busy && !worker.interactive {
+			busy++
+		}
+		if worker.inFlight > 1 {
+			queued += worker.inFlight - 1
+		}
+		worker.mu.Unlock()
+	}
+	p.workerMu.RUnlock()
+
+	waiting := p.dispatcher.waiting() + queued
+	busyRatio := 1.0
+	if bulk > 0 {
+		busyRatio = float64(busy) / float64(bulk)
+	}
+
+	if total < p.scaling.MaxWorkers && (waiting > 0 || maxWait > p.scaling.ScaleUpQueueWait || busyRatio >= p.scaling.ScaleUpBusyRatio) {
+		add := waiting
+		if add < 1 {
+			add = 1
+		}
+		if add > p.scaling.MaxWorkers-total {
+			add = p.scaling.MaxWorkers - total
+		}
+		p.logger.WithFields(logrus.Fields{
+			"engine":         p.engine,
+			"workers":        total,
+			"adding":         add,
+			"busy_ratio":     busyRatio,
+			"waiting":        waiting,
+			"max_queue_wait": maxWait.String(),
+		}).Info("Scaling worker pool up")
+		for i := 0; i < add; i++ {
+			if err := p.startWorker(p.nextWorkerID(), ""); err != nil {
+				p.logger.WithError(err).Warn("Failed to start worker while scaling up")
+				break
+			}
+			workerPoolScaleEvents.WithLabelValues(string(p.engine), "up").Inc()
+		}
+		return
+	}
+
+	if total > p.scaling.MinWorkers && waiting == 0 {
+		p.stopIdleWorker()
+	}
+}
+
+// stopIdleWorker stops one worker that has been idle for the idle timeout.
+func (p *WorkerPool) stopIdleWorker() {
+	worker := p.dispatcher.takeIdle(p.maxInFlight, func(w *TranslationWorker) bool {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		return !w.interactive && !w.remote() && !w.busy && time.Since(w.lastUsed) >= p.scaling.IdleTimeout
+	})
+	if worker == nil {
+		return
+	}
+
+	worker.advance(workerStopping)
+	p.dropWorker(worker, false)
+	remaining := p.Size()
+	worker.stop()
+
+	workerPoolScaleEvents.WithLabelValues(string(p.engine), "down").Inc()
+	worker.logger.WithFields(logrus.Fields{
+		"idle_timeout": p.scaling.IdleTimeout.String(),
+		"workers":      remaining,
+	}).Info("Scaling worker pool down: stopped idle worker")
+}
+
+// nextWorkerID returns the lowest worker ID not in use.
+func (p *WorkerPool) nextWorkerID() int {
+	p.workerMu.RLock()
+	defer p.workerMu.RUnlock()
+
+	used := make(map[int]bool, len(p.workers))
+	for _, w := range p.workers {
+		used[w.id] = true
+	}
+	id := 0
+	for used[id] {
+		id++
+	}
+	return id
+}
+
+// noteQueueWait records how long a request waited for a worker, for scaling decisions.
+func (p *WorkerPool) noteQueueWait(d time.Duration) {
+	p.waitMu.Lock()
+	if d > p.maxQueueWait {
+		p.maxQueueWait = d
+	}
+	p.waitMu.Unlock()
+}
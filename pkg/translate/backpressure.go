@@ -0,0 +1,86 @@
+package translate
+
+import (
+	"errors"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	// DefaultOverloadThreshold is how long a request waits for a worker
+	// before the pool checks whether it would time out anyway.
+	DefaultOverloadThreshold = 500 * time.Millisecond
+
+	// minRetryAfter is the shortest wait suggested to rejected requests.
+	minRetryAfter = time.Second
+
+	// serviceTimeWeight is the weight of each new round trip in the
+	// average service time.
+	serviceTimeWeight = 0.2
+)
+
+var workerOverloads = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "iskoces_worker_overload_rejections_total",
+		Help: "Total number of requests rejected because the worker queue was saturated",
+	},
+	[]string{"engine", "priority"},
+)
+
+// noteServiceTime folds a worker round trip into the average used to
+// estimate queue waits.
+func (p *WorkerPool) noteServiceTime(d time.Duration) {
+	p.waitMu.Lock()
+	defer p.waitMu.Unlock()
+	if p.serviceTime == 0 {
+		p.serviceTime = d
+		return
+	}
+	p.serviceTime += time.Duration(serviceTimeWeight * float64(d-p.serviceTime))
+}
+
+// estimateWait estimates how long a request with ahead requests before it
+// waits for one of d's workers: each ready worker's slots turn over once per
+// average round trip. It reports false until a round trip has been observed
+// or while no worker is ready.
+func (p *WorkerPool) estimateWait(d *dispatcher, ahead int) (time.Duration, bool) {
+	p.waitMu.Lock()
+	service := p.serviceTime
+	p.waitMu.Unlock()
+	if service == 0 {
+		return 0, false
+	}
+
+	p.workerMu.RLock()
+	slots := 0
+	for _, w := range p.workers {
+		if p.dispatcherForPair(w.pair) == d && w.ready() {
+			slots += p.maxInFlight
+		}
+	}
+	p.workerMu.RUnlock()
+	if slots == 0 {
+		return 0, false
+	}
+
+	return time.Duration(float64(service) * float64(ahead+1) / float64(slots)), true
+}
+
+// withBackpressure sets up a dispatcher to reject requests early when it is
+// saturated.
+func (p *WorkerPool) withBackpressure(d *dispatcher) *dispatcher {
+	d.overloadAfter = p.overloadAfter
+	d.estimate = func(ahead int) (time.Duration, bool) {
+		return p.estimateWait(d, ahead)
+	}
+	return d
+}
+
+// noteOverload counts a rejected request.
+func (p *WorkerPool) noteOverload(err error, priority Priority) {
+	if errors.Is(err, ErrOverloaded) {
+		workerOverloads.WithLabelValues(string(p.engine), priority.String()).Inc()
+	}
+}
@@ -0,0 +1,33 @@
+package translate
+
+import (
+	"context"
+	"fmt"
+)
+
+// BatchTranslator is implemented by backends that can translate many texts in
+// a single round trip. It's optional: use TranslateBatch, which falls back to
+// one Translate call per text when the backend has no batch support.
+type BatchTranslator interface {
+	// TranslateBatch translates texts from sourceLang to targetLang.
+	// The returned slice has the same length and order as texts.
+	TranslateBatch(ctx context.Context, texts []string, sourceLang, targetLang string) ([]string, error)
+}
+
+// TranslateBatch translates texts using the translator's batch method if it
+// has one, otherwise one text at a time. Results are returned in input order.
+func TranslateBatch(ctx context.Context, t Translator, texts []string, sourceLang, targetLang string) ([]string, error) {
+	if batcher, ok := t.(BatchTranslator); ok {
+		return batcher.TranslateBatch(ctx, texts, sourceLang, targetLang)
+	}
+
+	translated := make([]string, len(texts))
+	for i, text := range texts {
+		out, err := t.Translate(ctx, text, sourceLang, targetLang)
+		if err != nil {
+			return nil, fmt.Errorf("text %d: %w", i, err)
+		}
+		translated[i] = out
+	}
+	return translated, nil
+}
@@ -0,0 +1,154 @@
+package translate
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// DetectedLanguage is a candidate language for a text sample.
+type DetectedLanguage struct {
+	// Language is the ISO 639-1 code (e.g., "en", "fr").
+	Language string
+	// Confidence is in the range [0, 1].
+	Confidence float64
+}
+
+// LanguageDetector is implemented by backends that can identify the language
+// of a text sample. It's optional: callers should type-assert a Translator and
+// fall back to DetectLanguage when the backend has no detection support.
+type LanguageDetector interface {
+	// DetectLanguage returns candidate languages ordered by descending confidence.
+	DetectLanguage(ctx context.Context, text string) ([]DetectedLanguage, error)
+}
+
+// Detect runs language detection using the translator's own detector if it
+// has one, otherwise the built-in heuristic detector.
+func Detect(ctx context.Context, t Translator, text string) ([]DetectedLanguage, error) {
+	if detector, ok := t.(LanguageDetector); ok {
+		return detector.DetectLanguage(ctx, text)
+	}
+	return DetectLanguage(text), nil
+}
+
+// stopwords holds high-frequency function words for Latin-script languages.
+// Matching tokens against these lists is crude but fast and good enough to
+// decide "is this already in the target language?" for typical documents.
+var stopwords = map[string][]string{
+	"en": {"the", "and", "of", "to", "is", "in", "that", "it", "for", "with", "as", "was", "on", "are", "this", "be", "by", "from", "or", "have", "not", "which", "you", "they"},
+	"fr": {"le", "la", "les", "de", "des", "et", "est", "un", "une", "du", "en", "que", "qui", "dans", "pour", "pas", "sur", "au", "avec", "ce", "sont", "il", "elle", "nous"},
+	"es": {"el", "la", "los", "las", "de", "y", "es", "en", "que", "un", "una", "por", "con", "para", "del", "se", "no", "al", "lo", "como", "su", "pero", "son", "está"},
+	"de": {"der", "die", "das", "und", "ist", "nicht", "ein", "eine", "zu", "den", "mit", "von", "sich", "auf", "für", "im", "dem", "des", "auch", "es", "sie", "ich", "wir", "sind"},
+	"it": {"il", "lo", "la", "gli", "le", "di", "e", "è", "che", "un", "una", "per", "con", "non", "del", "della", "sono", "nel", "alla", "come", "ma", "si", "anche", "questo"},
+	"pt": {"o", "a", "os", "as", "de", "e", "é", "que", "um", "uma", "do", "da", "em", "para", "com", "não", "por", "se", "no", "na", "são", "mais", "como", "mas"},
+	"nl": {"de", "het", "een", "en", "van", "is", "dat", "niet", "op", "te", "zijn", "met", "voor", "die", "er", "aan", "ook", "als", "maar", "wordt", "bij", "ik", "we", "naar"},
+	"sv": {"och", "att", "det", "som", "en", "är", "av", "för", "med", "på", "till", "den", "inte", "har", "de", "ett", "om", "var", "jag", "men", "vi", "kan", "så", "från"},
+	"pl": {"i", "w", "na", "z", "jest", "że", "do", "nie", "się", "to", "o", "jak", "ale", "po", "co", "tak", "za", "od", "są", "przez", "dla", "czy", "jego", "być"},
+}
+
+// scriptLanguages maps Unicode scripts to the language they most likely indicate.
+var scriptLanguages = []struct {
+	table    *unicode.RangeTable
+	language string
+}{
+	{unicode.Hiragana, "ja"},
+	{unicode.Katakana, "ja"},
+	{unicode.Hangul, "ko"},
+	{unicode.Han, "zh"},
+	{unicode.Cyrillic, "ru"},
+	{unicode.Arabic, "ar"},
+	{unicode.Greek, "el"},
+	{unicode.Devanagari, "hi"},
+}
+
+// DetectLanguage is the built-in detector used when the backend has none.
+// It identifies non-Latin scripts by code point ranges and Latin-script
+// languages by stopword frequency. Returns nil if nothing could be detected.
+func DetectLanguage(text string) []DetectedLanguage {
+	// Script-based detection first: a majority of non-Latin letters is decisive
+	scriptCounts := make(map[string]int)
+	letters := 0
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		for _, s := range scriptLanguages {
+			if unicode.Is(s.table, r) {
+				scriptCounts[s.language]++
+				break
+			}
+		}
+	}
+	if letters == 0 {
+		return nil
+	}
+
+	// Japanese text mixes kana with Han characters; any kana means Japanese
+	if scriptCounts["ja"] > 0 {
+		scriptCounts["ja"] += scriptCounts["zh"]
+		delete(scriptCounts, "zh")
+	}
+
+	var candidates []DetectedLanguage
+	scriptTotal := 0
+	for lang, count := range scriptCounts {
+		scriptTotal += count
+		candidates = append(candidates, DetectedLanguage{
+			Language:   lang,
+			Confidence: float64(count) / float64(letters),
+		})
+	}
+	if scriptTotal*2 > letters {
+		sortCandidates(candidates)
+		return candidates
+	}
+
+	// Latin script: score each language by stopword hits
+	tokens := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	scores := make(map[string]int)
+	totalHits := 0
+	for lang, words := range stopwords {
+		set := make(map[string]struct{}, len(words))
+		for _, w := range words {
+			set[w] = struct{}{}
+		}
+		for _, tok := range tokens {
+			if _, ok := set[tok]; ok {
+				scores[lang]++
+				totalHits++
+			}
+		}
+	}
+	if totalHits == 0 {
+		return nil
+	}
+
+	candidates = candidates[:0]
+	for lang, hits := range scores {
+		candidates = append(candidates, DetectedLanguage{
+			Language:   lang,
+			Confidence: float64(hits) / float64(totalHits),
+		})
+	}
+	sortCandidates(candidates)
+
+	return candidates
+}
+
+// sortCandidates orders candidates by descending confidence (ties by code).
+func sortCandidates(candidates []DetectedLanguage) {
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].Confidence != candidates[j].Confidence {
+			return candidates[i].Confidence > candidates[j].Confidence
+		}
+		return candidates[i].Language < candidates[j].Language
+	})
+}
@@ -0,0 +1,377 @@
+package translate
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Priority orders translation requests waiting for a worker.
+type Priority int
+
+const (
+	// PriorityLow is for background or bulk work.
+	PriorityLow Priority = iota
+	// PriorityNormal is the default.
+	PriorityNormal
+	// PriorityHigh is for interactive requests such as titles.
+	PriorityHigh
+)
+
+// String returns the priority name used in logs and metrics.
+func (p Priority) String() string {
+	switch p {
+	case PriorityLow:
+		return "low"
+	case PriorityHigh:
+		return "high"
+	default:
+		return "normal"
+	}
+}
+
+type priorityKey struct{}
+
+// WithPriority returns a context carrying the scheduling priority for
+// translation requests made with it.
+func WithPriority(ctx context.Context, p Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, p)
+}
+
+// PriorityFromContext returns the priority set by WithPriority, or PriorityNormal.
+func PriorityFromContext(ctx context.Context) Priority {
+	if p, ok := ctx.Value(priorityKey{}).(Priority); ok {
+		return p
+	}
+	return PriorityNormal
+}
+
+type requestIDKey struct{}
+
+// WithRequestID returns a context carrying the ID of the request that
+// translations made with it are for. Workers are sent the ID, so their log
+// lines can be matched with the server's.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	if id == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext returns the request ID set by WithRequestID, or "".
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// errWorkerTimeout is returned when no worker became available in time.
+var errWorkerTimeout = fmt.Errorf("%w: timeout waiting for available worker", ErrEngineUnavailable)
+
+// errPoolClosed is returned for requests made while the pool shuts down.
+var errPoolClosed = fmt.Errorf("%w: worker pool is shutting down", ErrEngineUnavailable)
+
+// waiter is a request waiting for a worker.
+type waiter struct {
+	priority Priority
+	seq      uint64
+	ready    chan *TranslationWorker // buffered: release never blocks
+	index    int                     // heap index, -1 once popped
+}
+
+// waiterHeap orders waiters by priority (highest first), then arrival (FIFO).
+type waiterHeap []*waiter
+
+func (h waiterHeap) Len() int { return len(h) }
+
+func (h waiterHeap) Less(i, j int) bool {
+	if h[i].priority != h[j].priority {
+		return h[i].priority > h[j].priority
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h waiterHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *waiterHeap) Push(x any) {
+	w := x.(*waiter)
+	w.index = len(*h)
+	*h = append(*h, w)
+}
+
+func (h *waiterHeap) Pop() any {
+	old := *h
+	n := len(old)
+	w := old[n-1]
+	old[n-1] = nil
+	w.index = -1
+	*h = old[:n-1]
+	return w
+}
+
+// dispatcher hands worker request slots to waiting requests in priority order.
+// Within a priority level requests are served first come, first served. A
+// worker appears in idle once per free slot; requests go to the worker with
+// the most free slots, spreading load across workers.
+//
+// Interactive workers form a separate lane: their slots only serve
+// PriorityHigh requests, which prefer them, so titles and pre-flight checks
+// don't queue behind bulk document chunks when the pool is saturated.
+type dispatcher struct {
+	mu      sync.Mutex
+	idle    []*TranslationWorker
+	waiters waiterHeap
+	seq     uint64
+	closed  bool
+
+	capacity int // Most waiters allowed; 0 = unbounded
+
+	// A request still waiting after overloadAfter is rejected with an
+	// *OverloadedError if estimate says it would time out anyway. estimate
+	// returns the wait for a request with ahead requests before it, and false
+	// if there's no basis for an estimate yet.
+	overloadAfter time.Duration
+	estimate      func(ahead int) (time.Duration, bool)
+}
+
+// newDispatcher creates an empty dispatcher allowing up to capacity waiting
+// requests (0 = unbounded).
+func newDispatcher(capacity int) *dispatcher {
+	return &dispatcher{capacity: capacity}
+}
+
+// acquire blocks until a worker is available for a request of the given
+// priority, ctx is done, or timeout elapses. When the queue is full, or the
+// request is unlikely to get a worker before timeout, it fails early with an
+// *OverloadedError so callers can back off.
+func (d *dispatcher) acquire(ctx context.Context, priority Priority, timeout time.Duration) (*TranslationWorker, error) {
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return nil, errPoolClosed
+	}
+	if i := d.leastLoaded(priority); i >= 0 {
+		worker := d.idle[i]
+		d.idle = append(d.idle[:i], d.idle[i+1:]...)
+		d.mu.Unlock()
+		return worker, nil
+	}
+	if d.capacity > 0 && len(d.waiters) >= d.capacity {
+		waiting := len(d.waiters)
+		d.mu.Unlock()
+		return nil, d.overloaded(waiting, waiting)
+	}
+	w := &waiter{
+		priority: priority,
+		seq:      d.seq,
+		ready:    make(chan *TranslationWorker, 1),
+	}
+	d.seq++
+	heap.Push(&d.waiters, w)
+	d.mu.Unlock()
+
+	deadline := time.Now().Add(timeout)
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	var threshold <-chan time.Time
+	if d.estimate != nil && d.overloadAfter > 0 && d.overloadAfter < timeout {
+		t := time.NewTimer(d.overloadAfter)
+		defer t.Stop()
+		threshold = t.C
+	}
+
+	var err error
+wait:
+	for {
+		select {
+		case worker := <-w.ready:
+			if worker == nil {
+				return nil, errPoolClosed
+			}
+			return worker, nil
+		case <-ctx.Done():
+			err = ctx.Err()
+			break wait
+		case <-timer.C:
+			err = errWorkerTimeout
+			break wait
+		case <-threshold:
+			threshold = nil
+			if err = d.checkOverload(w, deadline); err != nil {
+				break wait
+			}
+		}
+	}
+
+	// Withdraw from the queue. If a worker was handed over in the meantime,
+	// pass it on to the next waiter.
+	d.mu.Lock()
+	if w.index >= 0 {
+		heap.Remove(&d.waiters, w.index)
+		d.mu.Unlock()
+		return nil, err
+	}
+	d.mu.Unlock()
+	if worker := <-w.ready; worker != nil {
+		d.release(worker)
+	}
+
+	return nil, err
+}
+
+// release makes a worker available, handing it straight to the
+// highest-priority waiter if there is one. Slots of workers that are no longer
+// ready (stopping or exited) are dropped.
+func (d *dispatcher) release(worker *TranslationWorker) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed || !worker.ready() {
+		return
+	}
+	if len(d.waiters) > 0 && laneAllows(worker, d.waiters[0].priority) {
+		w := heap.Pop(&d.waiters).(*waiter)
+		w.ready <- worker
+		return
+	}
+	d.idle = append(d.idle, worker)
+}
+
+// close stops handing out workers and fails every waiting request.
+func (d *dispatcher) close() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.closed = true
+	d.idle = nil
+	for len(d.waiters) > 0 {
+		heap.Pop(&d.waiters).(*waiter).ready <- nil
+	}
+}
+
+// checkOverload returns an *OverloadedError if the waiter's estimated wait
+// runs past deadline, or nil to keep waiting.
+func (d *dispatcher) checkOverload(w *waiter, deadline time.Time) error {
+	d.mu.Lock()
+	if w.index < 0 {
+		// Already handed a worker
+		d.mu.Unlock()
+		return nil
+	}
+	ahead := 0
+	for i := range d.waiters {
+		if i != w.index && d.waiters.Less(i, w.index) {
+			ahead++
+		}
+	}
+	waiting := len(d.waiters)
+	d.mu.Unlock()
+
+	if wait, ok := d.estimate(ahead); !ok || wait <= time.Until(deadline) {
+		return nil
+	}
+	return d.overloaded(ahead, waiting)
+}
+
+// overloaded builds the rejection for a request with ahead requests before it.
+func (d *dispatcher) overloaded(ahead, waiting int) error {
+	wait := time.Duration(0)
+	if d.estimate != nil {
+		wait, _ = d.estimate(ahead)
+	}
+	// A hint of zero would have clients retry at once
+	return &OverloadedError{RetryAfter: max(wait, minRetryAfter), Waiting: waiting}
+}
+
+// laneAllows reports whether a request of the given priority may use the worker.
+func laneAllows(worker *TranslationWorker, priority Priority) bool {
+	return !worker.interactive || priority >= PriorityHigh
+}
+
+// leastLoaded returns the index in idle of a slot a request of the given
+// priority may use, or -1 if there is none. PriorityHigh requests prefer
+// interactive workers; otherwise the worker with the most free slots wins.
+// Callers hold d.mu.
+func (d *dispatcher) leastLoaded(priority Priority) int {
+	free := make(map[*TranslationWorker]int, len(d.idle))
+	for _, w := range d.idle {
+		free[w]++
+	}
+
+	preferInteractive := priority >= PriorityHigh
+	best := -1
+	for i, w := range d.idle {
+		if !laneAllows(w, priority) {
+			continue
+		}
+		if best < 0 {
+			best = i
+			continue
+		}
+		b := d.idle[best]
+		if preferInteractive && w.interactive != b.interactive {
+			if w.interactive {
+				best = i
+			}
+			continue
+		}
+		if free[w] > free[b] {
+			best = i
+		}
+	}
+	return best
+}
+
+// remove drops all of a worker's slots from the idle list (e.g. after its process died).
+func (d *dispatcher) remove(worker *TranslationWorker) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.removeLocked(worker)
+}
+
+// removeLocked drops a worker's slots. Callers hold d.mu.
+func (d *dispatcher) removeLocked(worker *TranslationWorker) {
+	idle := d.idle[:0]
+	for _, w := range d.idle {
+		if w != worker {
+			idle = append(idle, w)
+		}
+	}
+	for i := len(idle); i < len(d.idle); i++ {
+		d.idle[i] = nil
+	}
+	d.idle = idle
+}
+
+// takeIdle removes and returns the first worker with all slots free
+// for which match returns true, or nil. A taken worker can't be acquired
+// again unless its slots are released.
+func (d *dispatcher) takeIdle(slots int, match func(*TranslationWorker) bool) *TranslationWorker {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	free := make(map[*TranslationWorker]int, len(d.idle))
+	for _, w := range d.idle {
+		free[w]++
+	}
+	for _, w := range d.idle {
+		if free[w] >= slots && match(w) {
+			d.removeLocked(w)
+			return w
+		}
+	}
+	return nil
+}
+
+// waiting returns the number of requests waiting for a worker.
+func (d *dispatcher) waiting() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.waiters)
+}
@@ -0,0 +1,118 @@
+package translate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrUnsupportedLanguage is returned when the engine can't translate
+	// between the requested languages.
+	ErrUnsupportedLanguage = errors.New("unsupported language pair")
+	// ErrEngineUnavailable is returned when the engine can't be reached or
+	// has no capacity (e.g. no worker became available).
+	ErrEngineUnavailable = errors.New("translation engine unavailable")
+	// ErrOverloaded is returned when the engine's queue is saturated and the
+	// request was rejected rather than left to time out. The error is an
+	// *OverloadedError carrying the estimated wait.
+	ErrOverloaded = errors.New("translation engine overloaded")
+)
+
+// OverloadedError rejects a request because the worker queue is saturated.
+type OverloadedError struct {
+	// RetryAfter estimates when a worker will be free for the request.
+	RetryAfter time.Duration
+	// Waiting is the number of requests queued for a worker.
+	Waiting int
+}
+
+func (e *OverloadedError) Error() string {
+	return fmt.Sprintf("%v: %d requests waiting for a worker, estimated wait %s",
+		ErrOverloaded, e.Waiting, e.RetryAfter.Round(time.Millisecond))
+}
+
+// Unwrap makes errors.Is match ErrOverloaded.
+func (e *OverloadedError) Unwrap() error {
+	return ErrOverloaded
+}
+
+// RetryAfter returns the estimated wait carried by an overload rejection.
+// It reports false for any other error.
+func RetryAfter(err error) (time.Duration, bool) {
+	var overloaded *OverloadedError
+	if errors.As(err, &overloaded) {
+		return overloaded.RetryAfter, true
+	}
+	return 0, false
+}
+
+// ErrorClass groups engine errors by how callers should react to them.
+type ErrorClass string
+
+const (
+	// ErrorClassUnsupportedLanguage: the language pair isn't supported; don't retry.
+	ErrorClassUnsupportedLanguage ErrorClass = "unsupported_language"
+	// ErrorClassUnavailable: the engine is down or saturated; retry later.
+	ErrorClassUnavailable ErrorClass = "unavailable"
+	// ErrorClassOverloaded: the engine's queue is full; retry after the
+	// estimated wait.
+	ErrorClassOverloaded ErrorClass = "overloaded"
+	// ErrorClassTimeout: the request ran out of time; retry, perhaps with smaller input.
+	ErrorClassTimeout ErrorClass = "timeout"
+	// ErrorClassCancelled: the caller cancelled the request.
+	ErrorClassCancelled ErrorClass = "cancelled"
+	// ErrorClassEngine: the engine failed in some other way.
+	ErrorClassEngine ErrorClass = "engine_error"
+)
+
+// Retryable reports whether a request that failed with this class may
+// succeed if retried unchanged.
+func (c ErrorClass) Retryable() bool {
+	return c == ErrorClassUnavailable || c == ErrorClassOverloaded || c == ErrorClassTimeout
+}
+
+// ClassifyError returns the class of an error returned by a Translator.
+func ClassifyError(err error) ErrorClass {
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, ErrUnsupportedLanguage):
+		return ErrorClassUnsupportedLanguage
+	case errors.Is(err, context.Canceled):
+		return ErrorClassCancelled
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrorClassTimeout
+	case errors.Is(err, ErrOverloaded):
+		return ErrorClassOverloaded
+	case errors.Is(err, ErrEngineUnavailable):
+		return ErrorClassUnavailable
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if netErr.Timeout() {
+			return ErrorClassTimeout
+		}
+		return ErrorClassUnavailable
+	}
+
+	return ErrorClassEngine
+}
+
+// httpStatusError builds the error for a non-OK response from an HTTP
+// engine, classifying it where the status makes the cause clear.
+func httpStatusError(statusCode int, body string) error {
+	switch {
+	case statusCode == http.StatusBadRequest && strings.Contains(strings.ToLower(body), "language"):
+		return fmt.Errorf("%w: unexpected status %d: %s", ErrUnsupportedLanguage, statusCode, body)
+	case statusCode == http.StatusTooManyRequests || statusCode >= http.StatusInternalServerError:
+		return fmt.Errorf("%w: unexpected status %d: %s", ErrEngineUnavailable, statusCode, body)
+	default:
+		return fmt.Errorf("unexpected status %d: %s", statusCode, body)
+	}
+}
@@ -2,6 +2,7 @@ package translate
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/sirupsen/logrus"
 )
@@ -30,13 +31,58 @@ type Config struct {
 	// MaxWorkers is the number of Python worker subprocesses to maintain (default: 4).
 	// Only used if UseWorkerPool is true.
 	MaxWorkers int
+	// MinWorkers enables autoscaling when below MaxWorkers: the pool keeps at
+	// least MinWorkers running and adds workers up to MaxWorkers under load
+	// (0 = fixed at MaxWorkers). Only used if UseWorkerPool is true.
+	MinWorkers int
+	// Scaling tunes autoscaling; zero fields use the defaults. Its MinWorkers
+	// and MaxWorkers are taken from the fields above.
+	Scaling ScalingConfig
+	// MaxInFlightPerWorker is how many requests may be outstanding on each
+	// worker's connection (default: DefaultMaxInFlightPerWorker).
+	MaxInFlightPerWorker int
+	// PythonPath, WorkerScriptPath and WorkerSocketDir locate the worker
+	// interpreter, script and sockets (defaults: DefaultPythonPath,
+	// DefaultWorkerScriptPath, DefaultWorkerSocketDir).
+	PythonPath       string
+	WorkerScriptPath string
+	WorkerSocketDir  string
+	// WorkerRequestTimeout bounds one request to a worker (default: DefaultWorkerRequestTimeout).
+	WorkerRequestTimeout time.Duration
+	// WorkerReadyTimeout is how long a new worker gets to start (default: DefaultWorkerReadyTimeout).
+	WorkerReadyTimeout time.Duration
+	// QueueTimeout is how long a request waits for a free worker (default: DefaultQueueTimeout).
+	QueueTimeout time.Duration
+	// QueueCapacity caps requests waiting for a worker (0 = unbounded).
+	QueueCapacity int
+	// OverloadThreshold is how long a request waits before being rejected as
+	// overloaded if it would time out anyway (default: DefaultOverloadThreshold).
+	OverloadThreshold time.Duration
+	// WorkerMaxRSSBytes and WorkerMaxRequests recycle a worker that exceeds
+	// that resident memory or has served that many requests (0 = no limit).
+	WorkerMaxRSSBytes int64
+	WorkerMaxRequests int
+	// WorkerTransport is the worker protocol: WorkerTransportJSON (default)
+	// or WorkerTransportGRPC.
+	WorkerTransport string
+	// InteractiveWorkers reserves workers for high-priority requests such as
+	// titles (at most MinWorkers-1).
+	InteractiveWorkers int
+	// WorkerSandbox isolates worker processes (user, cgroup, resource limits,
+	// read-only filesystem).
+	WorkerSandbox WorkerSandbox
+	// RemoteWorkers are host:port addresses of workers running elsewhere,
+	// reached over TCP with mutual TLS configured by RemoteWorkerTLS.
+	RemoteWorkers   []string
+	RemoteWorkerTLS WorkerTLS
 	// Logger is the logger instance to use. If nil, a default logger is created.
 	Logger *logrus.Logger
 }
 
 // NewTranslator creates a new Translator instance based on the configuration.
 // This factory function allows switching between different MT backends
-// without changing the gRPC service implementation.
+// without changing the gRPC service implementation. The translator is
+// instrumented (see Instrument); use Backend to reach the backend itself.
 func NewTranslator(cfg Config) (Translator, error) {
 	if cfg.Logger == nil {
 		cfg.Logger = logrus.New()
@@ -55,14 +101,43 @@ func NewTranslator(cfg Config) (Translator, error) {
 		if maxWorkers == 0 {
 			maxWorkers = 4 // Default: 4 workers
 		}
+		minWorkers := cfg.MinWorkers
+		if minWorkers == 0 || minWorkers > maxWorkers {
+			minWorkers = maxWorkers
+		}
 
 		cfg.Logger.WithFields(logrus.Fields{
-			"engine":     cfg.Engine,
+			"engine":      cfg.Engine,
+			"min_workers": minWorkers,
 			"max_workers": maxWorkers,
-			"method":     "worker_pool_unix_socket",
+			"method":      "worker_pool_unix_socket",
 		}).Info("Creating translator with worker pool")
 
-		return NewWorkerPool(cfg.Engine, maxWorkers, cfg.Logger)
+		scaling := cfg.Scaling
+		scaling.MinWorkers = minWorkers
+		scaling.MaxWorkers = maxWorkers
+		pool, err := NewWorkerPoolWithOptions(cfg.Engine, scaling, WorkerPoolOptions{
+			PythonPath:         cfg.PythonPath,
+			ScriptPath:         cfg.WorkerScriptPath,
+			SocketDir:          cfg.WorkerSocketDir,
+			MaxInFlight:        cfg.MaxInFlightPerWorker,
+			RequestTimeout:     cfg.WorkerRequestTimeout,
+			ReadyTimeout:       cfg.WorkerReadyTimeout,
+			QueueTimeout:       cfg.QueueTimeout,
+			QueueCapacity:      cfg.QueueCapacity,
+			OverloadThreshold:  cfg.OverloadThreshold,
+			MaxRSSBytes:        cfg.WorkerMaxRSSBytes,
+			MaxRequests:        cfg.WorkerMaxRequests,
+			InteractiveWorkers: cfg.InteractiveWorkers,
+			Transport:          cfg.WorkerTransport,
+			Sandbox:            cfg.WorkerSandbox,
+			RemoteWorkers:      cfg.RemoteWorkers,
+			RemoteTLS:          cfg.RemoteWorkerTLS,
+		}, cfg.Logger)
+		if err != nil {
+			return nil, err
+		}
+		return Instrument(pool, cfg.Engine), nil
 	}
 
 	// Fall back to HTTP client (legacy mode)
@@ -79,9 +154,9 @@ func NewTranslator(cfg Config) (Translator, error) {
 
 	switch cfg.Engine {
 	case EngineLibreTranslate:
-		return NewLibreTranslateClient(cfg.BaseURL, cfg.Logger), nil
+		return Instrument(NewLibreTranslateClient(cfg.BaseURL, cfg.Logger), cfg.Engine), nil
 	case EngineArgos:
-		return NewArgosClient(cfg.BaseURL, cfg.Logger), nil
+		return Instrument(NewArgosClient(cfg.BaseURL, cfg.Logger), cfg.Engine), nil
 	default:
 		cfg.Logger.WithFields(logrus.Fields{
 			"engine": cfg.Engine,
@@ -0,0 +1,106 @@
+package translate
+
+import (
+	"context"
+	"time"
+	"unicode/utf8"
+)
+
+// instrumentedTranslator records the translation request metrics of every
+// call to the translator it wraps, so they are the same whichever backend
+// is active.
+type instrumentedTranslator struct {
+	next    Translator
+	metrics *MetricsCollector
+}
+
+// Instrument wraps t so its translations are recorded in the translation
+// request metrics under engine: counts by outcome and error class,
+// durations, and request and response sizes. NewTranslator instruments the
+// translators it creates; use Backend to reach the wrapped translator.
+func Instrument(t Translator, engine EngineType) Translator {
+	return &instrumentedTranslator{
+		next:    t,
+		metrics: NewMetricsCollector(nil, string(engine)),
+	}
+}
+
+// Backend returns the translator wrapped by Instrument, or t itself, to
+// check what kind of backend it is.
+func Backend(t Translator) Translator {
+	for {
+		wrapper, ok := t.(interface{ Unwrap() Translator })
+		if !ok {
+			return t
+		}
+		t = wrapper.Unwrap()
+	}
+}
+
+// Unwrap returns the wrapped translator.
+func (t *instrumentedTranslator) Unwrap() Translator {
+	return t.next
+}
+
+// Translate translates text with the wrapped translator and records it.
+func (t *instrumentedTranslator) Translate(ctx context.Context, text, sourceLang, targetLang string) (string, error) {
+	start := time.Now()
+	translated, err := t.next.Translate(ctx, text, sourceLang, targetLang)
+	duration := time.Since(start)
+	t.metrics.RecordTranslationRequest(duration, err, len(text), len(translated))
+	if err == nil {
+		t.metrics.RecordThroughput(utf8.RuneCountInString(text), duration)
+	}
+	return translated, err
+}
+
+// TranslateBatch translates texts with the wrapped translator's batch
+// method if it has one, otherwise one at a time, and records the batch as
+// one request.
+func (t *instrumentedTranslator) TranslateBatch(ctx context.Context, texts []string, sourceLang, targetLang string) ([]string, error) {
+	if len(texts) == 0 {
+		return []string{}, nil
+	}
+	start := time.Now()
+	translated, err := TranslateBatch(ctx, t.next, texts, sourceLang, targetLang)
+	duration := time.Since(start)
+	t.metrics.RecordTranslationRequest(duration, err, totalLen(texts), totalLen(translated))
+	if err == nil {
+		t.metrics.RecordThroughput(totalChars(texts), duration)
+	}
+	return translated, err
+}
+
+// DetectLanguage detects the language of text with the wrapped translator's
+// detector if it has one, otherwise the built-in one.
+func (t *instrumentedTranslator) DetectLanguage(ctx context.Context, text string) ([]DetectedLanguage, error) {
+	return Detect(ctx, t.next, text)
+}
+
+// CheckHealth checks the wrapped translator's health.
+func (t *instrumentedTranslator) CheckHealth(ctx context.Context) error {
+	return t.next.CheckHealth(ctx)
+}
+
+// SupportedLanguages returns the wrapped translator's languages.
+func (t *instrumentedTranslator) SupportedLanguages(ctx context.Context) ([]string, error) {
+	return t.next.SupportedLanguages(ctx)
+}
+
+// totalLen returns the combined length of texts in bytes.
+func totalLen(texts []string) int {
+	n := 0
+	for _, text := range texts {
+		n += len(text)
+	}
+	return n
+}
+
+// totalChars returns the combined length of texts in characters.
+func totalChars(texts []string) int {
+	n := 0
+	for _, text := range texts {
+		n += utf8.RuneCountInString(text)
+	}
+	return n
+}
@@ -123,7 +123,7 @@ func (c *LibreTranslateClient) Translate(ctx context.Context, text, sourceLang,
 			"status_code": resp.StatusCode,
 			"response":    string(bodyBytes),
 		}).Error("Translation request returned non-OK status")
-		return "", fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(bodyBytes))
+		return "", httpStatusError(resp.StatusCode, string(bodyBytes))
 	}
 
 	// Decode response
@@ -142,6 +142,87 @@ func (c *LibreTranslateClient) Translate(ctx context.Context, text, sourceLang,
 	return ltResp.TranslatedText, nil
 }
 
+// batchTranslateRequest is a LibreTranslate request with an array of texts.
+type batchTranslateRequest struct {
+	Q      []string `json:"q"`
+	Source string   `json:"source"`
+	Target string   `json:"target"`
+	Format string   `json:"format"`
+}
+
+// batchTranslateResponse is the LibreTranslate response to an array request.
+type batchTranslateResponse struct {
+	TranslatedText []string `json:"translatedText"`
+}
+
+// TranslateBatch translates many texts in one request.
+// LibreTranslate accepts an array for "q" and returns translations in the same order.
+func (c *LibreTranslateClient) TranslateBatch(ctx context.Context, texts []string, sourceLang, targetLang string) ([]string, error) {
+	if len(texts) == 0 {
+		return []string{}, nil
+	}
+
+	c.logger.WithFields(logrus.Fields{
+		"source_lang": sourceLang,
+		"target_lang": targetLang,
+		"count":       len(texts),
+	}).Debug("Batch translating with LibreTranslate")
+
+	buf := new(bytes.Buffer)
+	if err := json.NewEncoder(buf).Encode(&batchTranslateRequest{
+		Q:      texts,
+		Source: sourceLang,
+		Target: targetLang,
+		Format: "text",
+	}); err != nil {
+		c.logger.WithError(err).Error("Failed to encode batch translation request")
+		return nil, fmt.Errorf("encode request: %w", err)
+	}
+
+	url := c.baseURL + "/translate"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, buf)
+	if err != nil {
+		c.logger.WithError(err).Error("Failed to create batch translation request")
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	startTime := time.Now()
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		c.logger.WithError(err).WithFields(logrus.Fields{
+			"url": url,
+		}).Error("Batch translation request failed")
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		c.logger.WithFields(logrus.Fields{
+			"status_code": resp.StatusCode,
+			"response":    string(bodyBytes),
+		}).Error("Batch translation request returned non-OK status")
+		return nil, httpStatusError(resp.StatusCode, string(bodyBytes))
+	}
+
+	var ltResp batchTranslateResponse
+	if err := json.NewDecoder(resp.Body).Decode(&ltResp); err != nil {
+		c.logger.WithError(err).Error("Failed to decode batch translation response")
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+	if len(ltResp.TranslatedText) != len(texts) {
+		return nil, fmt.Errorf("LibreTranslate returned %d translations for %d texts", len(ltResp.TranslatedText), len(texts))
+	}
+
+	c.logger.WithFields(logrus.Fields{
+		"count":       len(texts),
+		"duration_ms": time.Since(startTime).Milliseconds(),
+	}).Info("Batch translation completed successfully")
+
+	return ltResp.TranslatedText, nil
+}
+
 // CheckHealth verifies that LibreTranslate is ready and operational.
 func (c *LibreTranslateClient) CheckHealth(ctx context.Context) error {
 	c.logger.Debug("Checking LibreTranslate health")
@@ -217,3 +298,71 @@ func (c *LibreTranslateClient) SupportedLanguages(ctx context.Context) ([]string
 
 	return codes, nil
 }
+
+// detectRequest represents a LibreTranslate /detect API request.
+type detectRequest struct {
+	Q string `json:"q"`
+}
+
+// detectResponse represents one candidate in the /detect response.
+// LibreTranslate reports confidence as a percentage (0-100).
+type detectResponse struct {
+	Language   string  `json:"language"`
+	Confidence float64 `json:"confidence"`
+}
+
+// DetectLanguage identifies the language of text using the /detect endpoint.
+func (c *LibreTranslateClient) DetectLanguage(ctx context.Context, text string) ([]DetectedLanguage, error) {
+	c.logger.WithFields(logrus.Fields{
+		"text_length": len(text),
+	}).Debug("Detecting language with LibreTranslate")
+
+	buf := new(bytes.Buffer)
+	if err := json.NewEncoder(buf).Encode(&detectRequest{Q: text}); err != nil {
+		c.logger.WithError(err).Error("Failed to encode detect request")
+		return nil, fmt.Errorf("encode request: %w", err)
+	}
+
+	url := c.baseURL + "/detect"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, buf)
+	if err != nil {
+		c.logger.WithError(err).Error("Failed to create detect request")
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		c.logger.WithError(err).WithFields(logrus.Fields{
+			"url": url,
+		}).Error("Detect request failed")
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		c.logger.WithFields(logrus.Fields{
+			"status_code": resp.StatusCode,
+			"response":    string(bodyBytes),
+		}).Error("Detect request returned non-OK status")
+		return nil, httpStatusError(resp.StatusCode, string(bodyBytes))
+	}
+
+	var detections []detectResponse
+	if err := json.NewDecoder(resp.Body).Decode(&detections); err != nil {
+		c.logger.WithError(err).Error("Failed to decode detect response")
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+
+	candidates := make([]DetectedLanguage, 0, len(detections))
+	for _, d := range detections {
+		candidates = append(candidates, DetectedLanguage{
+			Language:   d.Language,
+			Confidence: d.Confidence / 100,
+		})
+	}
+	sortCandidates(candidates)
+
+	return candidates, nil
+}
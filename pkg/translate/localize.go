@@ -0,0 +1,327 @@
+package translate
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"golang.org/x/text/number"
+)
+
+// Localizer rewrites numbers, dates, and units in translated text so they
+// follow the conventions of the target locale (e.g. "1,234.5" -> "1 234,5"
+// and "03/14/2024" -> "14/03/2024" for fr-CA).
+//
+// MT engines translate words but leave numeric formats untouched, so this
+// runs as an optional pass over the engine output. Numbers are read in the
+// source language's format, which must be known. Fenced code blocks, inline
+// code spans, link targets, autolinks and bare URLs are never modified.
+type Localizer struct{}
+
+// NewLocalizer creates a new localizer instance.
+func NewLocalizer() *Localizer {
+	return &Localizer{}
+}
+
+// protectedSpanPattern matches the parts of markdown that are left exactly
+// as written: fenced code blocks, inline code spans, link and image targets,
+// reference link definitions, autolinks and bare URLs.
+var protectedSpanPattern = regexp.MustCompile("(?s)```.*?```" +
+	"|`[^`\n]*`" +
+	`|\]\([^)\n]*\)` +
+	`|(?m:^[ \t]*\[[^\]\n]+\]:[ \t]*\S+)` +
+	`|<[a-zA-Z][a-zA-Z0-9+.-]*:[^>\s]*>` +
+	`|\b(?:https?|ftp)://[^\s<>()\[\]]+` +
+	`|\bwww\.[^\s<>()\[\]]+`)
+
+// numberFormat is how a locale writes numbers: its grouping and decimal
+// separators.
+type numberFormat struct {
+	group, decimal byte
+}
+
+var (
+	// periodDecimal is "1,234.5" (English, Chinese, Japanese, ...).
+	periodDecimal = numberFormat{group: ',', decimal: '.'}
+	// commaDecimal is "1.234,5" (German, French, Spanish, ...).
+	commaDecimal = numberFormat{group: '.', decimal: ','}
+)
+
+// sourceNumberFormats are the number formats of the source languages
+// numbers can be read in, by base language. Text in other languages isn't
+// localized, as its numbers can't be read reliably.
+var sourceNumberFormats = map[string]numberFormat{
+	"en": periodDecimal, "zh": periodDecimal, "ja": periodDecimal, "ko": periodDecimal,
+	"he": periodDecimal, "th": periodDecimal, "hi": periodDecimal, "ms": periodDecimal,
+	"ga": periodDecimal,
+	"de": commaDecimal, "fr": commaDecimal, "es": commaDecimal, "it": commaDecimal,
+	"pt": commaDecimal, "nl": commaDecimal, "ru": commaDecimal, "pl": commaDecimal,
+	"cs": commaDecimal, "sk": commaDecimal, "tr": commaDecimal, "nb": commaDecimal,
+	"no": commaDecimal, "da": commaDecimal, "fi": commaDecimal, "sv": commaDecimal,
+	"hu": commaDecimal, "ro": commaDecimal, "uk": commaDecimal, "el": commaDecimal,
+	"id": commaDecimal, "ca": commaDecimal, "bg": commaDecimal, "hr": commaDecimal,
+	"sl": commaDecimal, "lt": commaDecimal, "lv": commaDecimal, "et": commaDecimal,
+}
+
+// localizePatterns are the patterns matching numbers written in each
+// source format.
+var localizePatterns = map[numberFormat]*regexp.Regexp{
+	periodDecimal: localizePattern(periodDecimal),
+	commaDecimal:  localizePattern(commaDecimal),
+}
+
+// localizePattern returns the pattern matching, in priority order:
+//  1. US-style dates (MM/DD/YYYY)
+//  2. a number followed by an imperial unit
+//  3. a number with thousands separators and/or a decimal part
+//
+// with numbers written in format.
+func localizePattern(format numberFormat) *regexp.Regexp {
+	g := regexp.QuoteMeta(string(format.group))
+	d := regexp.QuoteMeta(string(format.decimal))
+	num := `\d{1,3}(?:` + g + `\d{3})+(?:` + d + `\d+)?`
+	return regexp.MustCompile(
+		`\b(\d{1,2})/(\d{1,2})/(\d{4})\b` +
+			`|(` + num + `|\d+(?:` + d + `\d+)?) ?(°F|miles|mile|mi|lbs|lb|feet|ft)\b` +
+			`|` + num + `|\d+` + d + `\d+`)
+}
+
+// imperialUnit describes how to convert an imperial unit to its metric equivalent.
+type imperialUnit struct {
+	metric  string
+	convert func(float64) float64
+}
+
+var imperialUnits = map[string]imperialUnit{
+	"°F":    {metric: "°C", convert: func(v float64) float64 { return (v - 32) * 5 / 9 }},
+	"mi":    {metric: "km", convert: func(v float64) float64 { return v * 1.609344 }},
+	"mile":  {metric: "km", convert: func(v float64) float64 { return v * 1.609344 }},
+	"miles": {metric: "km", convert: func(v float64) float64 { return v * 1.609344 }},
+	"lb":    {metric: "kg", convert: func(v float64) float64 { return v * 0.45359237 }},
+	"lbs":   {metric: "kg", convert: func(v float64) float64 { return v * 0.45359237 }},
+	"ft":    {metric: "m", convert: func(v float64) float64 { return v * 0.3048 }},
+	"feet":  {metric: "m", convert: func(v float64) float64 { return v * 0.3048 }},
+}
+
+// imperialRegions are the regions that still use imperial units day to day.
+var imperialRegions = map[string]bool{
+	"US": true,
+	"LR": true,
+	"MM": true,
+}
+
+// dateLayouts maps base language codes to their preferred numeric date layout.
+// Languages not listed use DD/MM/YYYY.
+var dateLayouts = map[string]string{
+	"de": "%02d.%02d.%04d",
+	"ru": "%02d.%02d.%04d",
+	"pl": "%02d.%02d.%04d",
+	"fi": "%02d.%02d.%04d",
+	"cs": "%02d.%02d.%04d",
+	"sk": "%02d.%02d.%04d",
+	"tr": "%02d.%02d.%04d",
+	"nb": "%02d.%02d.%04d",
+	"no": "%02d.%02d.%04d",
+	"da": "%02d.%02d.%04d",
+	"nl": "%02d-%02d-%04d",
+}
+
+// yearFirstLanguages write numeric dates as YYYY/MM/DD (or YYYY-MM-DD).
+var yearFirstLanguages = map[string]string{
+	"zh": "%04d/%02d/%02d",
+	"ja": "%04d/%02d/%02d",
+	"ko": "%04d-%02d-%02d",
+	"sv": "%04d-%02d-%02d",
+	"hu": "%04d.%02d.%02d",
+	"lt": "%04d-%02d-%02d",
+}
+
+// Localize converts numbers, dates, and units in text, written in
+// sourceLang, to the conventions of targetLang (BCP 47 tags such as "fr-CA"
+// or "de"). Text is returned unchanged if either tag cannot be parsed or the
+// source language's number format isn't known.
+func (l *Localizer) Localize(text, sourceLang, targetLang string) string {
+	if text == "" || sourceLang == "" || targetLang == "" {
+		return text
+	}
+
+	tag, err := language.Parse(targetLang)
+	if err != nil {
+		return text
+	}
+	source, err := language.Parse(sourceLang)
+	if err != nil {
+		return text
+	}
+	sourceBase, _ := source.Base()
+	format, ok := sourceNumberFormats[sourceBase.String()]
+	if !ok {
+		return text
+	}
+	// Only US English writes dates month first and uses imperial units
+	sourceRegion, _ := source.Region()
+	sourceUS := sourceBase.String() == "en" && sourceRegion.String() == "US"
+
+	// Only touch prose; leave code and links exactly as written
+	var out strings.Builder
+	last := 0
+	for _, span := range protectedSpanPattern.FindAllStringIndex(text, -1) {
+		out.WriteString(l.localizeProse(text[last:span[0]], tag, format, sourceUS))
+		out.WriteString(text[span[0]:span[1]])
+		last = span[1]
+	}
+	out.WriteString(l.localizeProse(text[last:], tag, format, sourceUS))
+
+	return out.String()
+}
+
+// localizeProse localizes a run of text that contains no protected spans,
+// reading numbers in format.
+func (l *Localizer) localizeProse(text string, tag language.Tag, format numberFormat, sourceUS bool) string {
+	base, _ := tag.Base()
+	region, _ := tag.Region()
+	lang := base.String()
+	// Region() infers a likely region when the tag has none ("en" -> US, "fr" -> FR)
+	isUS := region.String() == "US"
+	metric := !imperialRegions[region.String()]
+
+	printer := message.NewPrinter(tag)
+
+	var out strings.Builder
+	last := 0
+	for _, m := range localizePatterns[format].FindAllStringSubmatchIndex(text, -1) {
+		start, end := m[0], m[1]
+
+		// Skip matches embedded in larger tokens such as version strings ("1.2.3"),
+		// IP addresses, or identifiers ("v1.5").
+		if !isStandalone(text, start, end) {
+			continue
+		}
+
+		out.WriteString(text[last:start])
+		last = end
+
+		switch {
+		case m[2] >= 0:
+			// US date: MM/DD/YYYY, only from US English
+			if !sourceUS {
+				out.WriteString(text[start:end])
+				continue
+			}
+			month, _ := strconv.Atoi(text[m[2]:m[3]])
+			day, _ := strconv.Atoi(text[m[4]:m[5]])
+			year, _ := strconv.Atoi(text[m[6]:m[7]])
+			out.WriteString(formatDate(lang, isUS, month, day, year, text[start:end]))
+
+		case m[8] >= 0:
+			// Number with an imperial unit
+			value, decimals, ok := parseNumber(text[m[8]:m[9]], format)
+			unit := text[m[10]:m[11]]
+			conv, known := imperialUnits[unit]
+			if !ok || !known || !metric || !sourceUS {
+				if ok {
+					out.WriteString(formatNumber(printer, value, decimals))
+					out.WriteString(text[m[9]:end])
+				} else {
+					out.WriteString(text[start:end])
+				}
+				continue
+			}
+			converted := conv.convert(value)
+			if decimals == 0 {
+				decimals = 1
+			}
+			out.WriteString(formatNumber(printer, converted, decimals))
+			out.WriteString(" ")
+			out.WriteString(conv.metric)
+
+		default:
+			// Plain number with grouping and/or decimals
+			value, decimals, ok := parseNumber(text[start:end], format)
+			if !ok {
+				out.WriteString(text[start:end])
+				continue
+			}
+			out.WriteString(formatNumber(printer, value, decimals))
+		}
+	}
+	out.WriteString(text[last:])
+
+	return out.String()
+}
+
+// isStandalone reports whether text[start:end] is not glued to surrounding
+// digits, dots, or letters.
+func isStandalone(text string, start, end int) bool {
+	if start > 0 {
+		prev := text[start-1]
+		if prev == '.' || prev == ',' || isAlnum(prev) {
+			return false
+		}
+	}
+	if end < len(text) {
+		next := text[end]
+		if isAlnum(next) {
+			return false
+		}
+		// A trailing "." or "," followed by a digit means we matched part of a
+		// longer token (e.g. "1.2.3"); followed by anything else it's punctuation.
+		if (next == '.' || next == ',') && end+1 < len(text) && isDigit(text[end+1]) {
+			return false
+		}
+	}
+	return true
+}
+
+func isDigit(b byte) bool {
+	return b >= '0' && b <= '9'
+}
+
+func isAlnum(b byte) bool {
+	return isDigit(b) || (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || b == '_'
+}
+
+// parseNumber parses a number written in format. Returns the value and the
+// number of fractional digits.
+func parseNumber(s string, format numberFormat) (float64, int, bool) {
+	clean := strings.ReplaceAll(s, string(format.group), "")
+	clean = strings.Replace(clean, string(format.decimal), ".", 1)
+	value, err := strconv.ParseFloat(clean, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	decimals := 0
+	if idx := strings.IndexByte(clean, '.'); idx >= 0 {
+		decimals = len(clean) - idx - 1
+	}
+	return value, decimals, true
+}
+
+// formatNumber formats value for the printer's locale with a fixed number of decimals.
+func formatNumber(printer *message.Printer, value float64, decimals int) string {
+	return printer.Sprint(number.Decimal(value,
+		number.MinFractionDigits(decimals),
+		number.MaxFractionDigits(decimals),
+	))
+}
+
+// formatDate renders a date using the numeric layout preferred by lang.
+// original is returned unchanged for US English targets or invalid dates.
+func formatDate(lang string, isUS bool, month, day, year int, original string) string {
+	if month < 1 || month > 12 || day < 1 || day > 31 {
+		return original
+	}
+	if isUS {
+		return original
+	}
+	if layout, ok := yearFirstLanguages[lang]; ok {
+		return fmt.Sprintf(layout, year, month, day)
+	}
+	if layout, ok := dateLayouts[lang]; ok {
+		return fmt.Sprintf(layout, day, month, year)
+	}
+	return fmt.Sprintf("%02d/%02d/%04d", day, month, year)
+}
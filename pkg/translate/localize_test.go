@@ -0,0 +1,131 @@
+package translate
+
+import "testing"
+
+func TestLocalize(t *testing.T) {
+	tests := []struct {
+		name   string
+		text   string
+		source string
+		target string
+		want   string
+	}{
+		{
+			name:   "english decimal to french",
+			text:   "It costs 2.5 euros.",
+			source: "en", target: "fr",
+			want: "It costs 2,5 euros.",
+		},
+		{
+			name:   "english grouping to german",
+			text:   "About 1,234.5 users.",
+			source: "en", target: "de",
+			want: "About 1.234,5 users.",
+		},
+		{
+			name:   "german source read in german format",
+			text:   "Etwa 1.234,5 Nutzer.",
+			source: "de", target: "en",
+			want: "Etwa 1,234.5 Nutzer.",
+		},
+		{
+			name:   "german decimal to english",
+			text:   "Faktor 2,5 schneller.",
+			source: "de", target: "en",
+			want: "Faktor 2.5 schneller.",
+		},
+		{
+			name:   "us date to german",
+			text:   "Released 03/14/2024.",
+			source: "en-US", target: "de",
+			want: "Released 14.03.2024.",
+		},
+		{
+			name:   "british source dates are left alone",
+			text:   "Released 03/04/2024.",
+			source: "en-GB", target: "de",
+			want: "Released 03/04/2024.",
+		},
+		{
+			name:   "unknown source format",
+			text:   "It costs 2.5 euros.",
+			source: "xx", target: "fr",
+			want: "It costs 2.5 euros.",
+		},
+		{
+			name:   "empty source",
+			text:   "It costs 2.5 euros.",
+			source: "", target: "fr",
+			want: "It costs 2.5 euros.",
+		},
+		{
+			name:   "auto source",
+			text:   "It costs 2.5 euros.",
+			source: "auto", target: "fr",
+			want: "It costs 2.5 euros.",
+		},
+		{
+			name:   "markdown link target",
+			text:   "See [docs](https://example.com/v/2.5/guide) for 2.5.",
+			source: "en", target: "fr",
+			want: "See [docs](https://example.com/v/2.5/guide) for 2,5.",
+		},
+		{
+			name:   "image target",
+			text:   "![chart](img/1.5/chart.png)",
+			source: "en", target: "fr",
+			want: "![chart](img/1.5/chart.png)",
+		},
+		{
+			name:   "reference link definition",
+			text:   "[docs]: https://example.com/2.5/",
+			source: "en", target: "fr",
+			want: "[docs]: https://example.com/2.5/",
+		},
+		{
+			name:   "autolink",
+			text:   "Go to <https://example.com/v/2.5/guide> now.",
+			source: "en", target: "fr",
+			want: "Go to <https://example.com/v/2.5/guide> now.",
+		},
+		{
+			name:   "bare url",
+			text:   "Go to https://example.com/v/2.5/guide now.",
+			source: "en", target: "fr",
+			want: "Go to https://example.com/v/2.5/guide now.",
+		},
+		{
+			name:   "bare www url",
+			text:   "Go to www.example.com/1.5 now.",
+			source: "en", target: "fr",
+			want: "Go to www.example.com/1.5 now.",
+		},
+		{
+			name:   "inline code",
+			text:   "Run `sleep 2.5` for 2.5 seconds.",
+			source: "en", target: "fr",
+			want: "Run `sleep 2.5` for 2,5 seconds.",
+		},
+		{
+			name:   "fenced code",
+			text:   "```\nx = 2.5\n```\n",
+			source: "en", target: "fr",
+			want: "```\nx = 2.5\n```\n",
+		},
+		{
+			name:   "version strings",
+			text:   "Upgrade to 1.2.3.",
+			source: "en", target: "fr",
+			want: "Upgrade to 1.2.3.",
+		},
+	}
+
+	var l Localizer
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := l.Localize(tt.text, tt.source, tt.target); got != tt.want {
+				t.Errorf("Localize(%q, %q, %q) = %q, want %q", tt.text, tt.source, tt.target, got, tt.want)
+			}
+		})
+	}
+}
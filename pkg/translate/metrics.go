@@ -52,6 +52,14 @@ var (
 		[]string{"engine", "status"},
 	)
 
+	translationErrorsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "iskoces_translation_errors_total",
+			Help: "Total number of failed translation requests, by error class (unsupported_language, unavailable, overloaded, timeout, cancelled, engine_error)",
+		},
+		[]string{"engine", "class"},
+	)
+
 	translationRequestDuration = promauto.NewHistogramVec(
 		prometheus.HistogramOpts{
 			Name:    "iskoces_translation_request_duration_seconds",
@@ -140,6 +148,57 @@ var (
 		[]string{"engine", "worker_id"},
 	)
 
+	// Throughput metrics, for sizing worker pools
+	translationCharactersTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "iskoces_translation_characters_total",
+			Help: "Total number of source characters translated successfully (rate() gives the engine's characters per second)",
+		},
+		[]string{"engine"},
+	)
+
+	translationThroughput = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "iskoces_translation_characters_per_second",
+			Help:    "Characters per second of each successful translation request, from its source characters and duration",
+			Buckets: []float64{10, 50, 100, 250, 500, 1000, 2500, 5000, 10000, 25000},
+		},
+		[]string{"engine"},
+	)
+
+	workerCharactersTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "iskoces_worker_characters_total",
+			Help: "Total number of source characters each worker translated successfully",
+		},
+		[]string{"engine", "worker_id"},
+	)
+
+	workerBusySecondsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "iskoces_worker_busy_seconds_total",
+			Help: "Total time each worker spent on successful translation requests, in seconds (characters over busy seconds is its throughput while busy)",
+		},
+		[]string{"engine", "worker_id"},
+	)
+
+	workerThroughput = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "iskoces_worker_characters_per_second",
+			Help: "Recent characters per second of each worker while translating (moving average over its requests)",
+		},
+		[]string{"engine", "worker_id"},
+	)
+
+	workerModelLoadTime = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "iskoces_worker_model_load_seconds",
+			Help:    "Time from starting a worker process until it is ready to translate, by kind (pinned workers load and warm up their pair's model first; general workers load models on first use)",
+			Buckets: []float64{0.5, 1, 2, 5, 10, 20, 30, 60, 120, 300},
+		},
+		[]string{"engine", "kind"},
+	)
+
 	// Memory metrics (if available)
 	workerMemoryUsage = promauto.NewGaugeVec(
 		prometheus.GaugeOpts{
@@ -150,11 +209,19 @@ var (
 	)
 )
 
+// throughputSmoothing is the weight of each request in a worker's moving
+// average throughput.
+const throughputSmoothing = 0.2
+
 // MetricsCollector collects and updates metrics for the worker pool.
 type MetricsCollector struct {
 	pool   *WorkerPool
 	engine string
 	mu     sync.RWMutex
+
+	// Moving average characters per second, by worker ID
+	throughput   map[int]float64
+	throughputMu sync.Mutex
 }
 
 // NewMetricsCollector creates a new metrics collector for a worker pool.
@@ -189,7 +256,7 @@ func (mc *MetricsCollector) UpdateMetrics() {
 		}
 
 		// Check if process is running
-		if worker.process != nil && worker.process.ProcessState == nil {
+		if worker.state == workerReady {
 			activeWorkers++
 			// Calculate uptime
 			if !worker.lastUsed.IsZero() {
@@ -207,7 +274,7 @@ func (mc *MetricsCollector) UpdateMetrics() {
 	workerPoolActiveWorkers.WithLabelValues(mc.engine).Set(float64(activeWorkers))
 	workerPoolBusyWorkers.WithLabelValues(mc.engine).Set(float64(busyWorkers))
 	workerPoolIdleWorkers.WithLabelValues(mc.engine).Set(float64(idleWorkers))
-	workerQueueLength.WithLabelValues(mc.engine).Set(float64(len(mc.pool.requestQueue)))
+	workerQueueLength.WithLabelValues(mc.engine).Set(float64(mc.pool.waiting()))
 
 	// Update worker uptimes
 	for workerID, uptime := range workerUptimes {
@@ -215,11 +282,13 @@ func (mc *MetricsCollector) UpdateMetrics() {
 	}
 }
 
-// RecordTranslationRequest records metrics for a translation request.
-func (mc *MetricsCollector) RecordTranslationRequest(duration time.Duration, success bool, requestSize, responseSize int) {
+// RecordTranslationRequest records metrics for a translation request that
+// failed with err, or succeeded if err is nil.
+func (mc *MetricsCollector) RecordTranslationRequest(duration time.Duration, err error, requestSize, responseSize int) {
 	status := "success"
-	if !success {
+	if err != nil {
 		status = "error"
+		translationErrorsTotal.WithLabelValues(mc.engine, string(ClassifyError(err))).Inc()
 	}
 
 	translationRequestsTotal.WithLabelValues(mc.engine, status).Inc()
@@ -228,6 +297,51 @@ func (mc *MetricsCollector) RecordTranslationRequest(duration time.Duration, suc
 	translationResponseSize.WithLabelValues(mc.engine).Observe(float64(responseSize))
 }
 
+// RecordThroughput records a successful translation of chars source
+// characters that took duration.
+func (mc *MetricsCollector) RecordThroughput(chars int, duration time.Duration) {
+	if chars <= 0 {
+		return
+	}
+	translationCharactersTotal.WithLabelValues(mc.engine).Add(float64(chars))
+	if duration > 0 {
+		translationThroughput.WithLabelValues(mc.engine).Observe(float64(chars) / duration.Seconds())
+	}
+}
+
+// RecordWorkerThroughput records a worker translating chars source
+// characters successfully in duration, and updates its moving average.
+func (mc *MetricsCollector) RecordWorkerThroughput(workerID int, chars int, duration time.Duration) {
+	if chars <= 0 || duration <= 0 {
+		return
+	}
+	id := fmt.Sprintf("%d", workerID)
+	workerCharactersTotal.WithLabelValues(mc.engine, id).Add(float64(chars))
+	workerBusySecondsTotal.WithLabelValues(mc.engine, id).Add(duration.Seconds())
+
+	rate := float64(chars) / duration.Seconds()
+	mc.throughputMu.Lock()
+	if mc.throughput == nil {
+		mc.throughput = make(map[int]float64)
+	}
+	if avg, ok := mc.throughput[workerID]; ok {
+		rate = throughputSmoothing*rate + (1-throughputSmoothing)*avg
+	}
+	mc.throughput[workerID] = rate
+	mc.throughputMu.Unlock()
+	workerThroughput.WithLabelValues(mc.engine, id).Set(rate)
+}
+
+// RecordWorkerReady records how long a worker took from starting to being
+// ready, including loading its model if it is pinned.
+func (mc *MetricsCollector) RecordWorkerReady(pinned bool, duration time.Duration) {
+	kind := "general"
+	if pinned {
+		kind = "pinned"
+	}
+	workerModelLoadTime.WithLabelValues(mc.engine, kind).Observe(duration.Seconds())
+}
+
 // RecordWorkerStart records a worker start event.
 func (mc *MetricsCollector) RecordWorkerStart(workerID int) {
 	workerStartsTotal.WithLabelValues(mc.engine, fmt.Sprintf("%d", workerID)).Inc()
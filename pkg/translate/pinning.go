@@ -0,0 +1,130 @@
+package translate
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PinnedPair dedicates workers to one language pair. Pinned workers load the
+// pair's model at startup and keep it loaded; requests for the pair go only
+// to them, and they serve no other pair. They are outside the pool's
+// Min/MaxWorkers bounds and are never scaled.
+type PinnedPair struct {
+	SourceLang string // Backend (ISO 639-1) code, e.g. "en"
+	TargetLang string
+	Workers    int
+}
+
+// key returns the pair's routing key.
+func (pp PinnedPair) key() string {
+	return pairKey(pp.SourceLang, pp.TargetLang)
+}
+
+// String returns the pair in the flag format, e.g. "en:fr=2".
+func (pp PinnedPair) String() string {
+	return fmt.Sprintf("%s=%d", pp.key(), pp.Workers)
+}
+
+// pairKey is the routing key for a language pair, e.g. "en:fr".
+func pairKey(sourceLang, targetLang string) string {
+	return strings.ToLower(sourceLang) + ":" + strings.ToLower(targetLang)
+}
+
+// ParsePinnedPairs parses a comma-separated list of language pairs with an
+// optional worker count, e.g. "en:fr=2,fr:en". The count defaults to 1.
+func ParsePinnedPairs(s string) ([]PinnedPair, error) {
+	var pairs []PinnedPair
+	seen := make(map[string]bool)
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		pair, count, hasCount := strings.Cut(item, "=")
+		workers := 1
+		if hasCount {
+			n, err := strconv.Atoi(strings.TrimSpace(count))
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid worker count in pinned pair %q", item)
+			}
+			workers = n
+		}
+
+		source, target, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		source, target = strings.TrimSpace(source), strings.TrimSpace(target)
+		if !ok || source == "" || target == "" || source == target {
+			return nil, fmt.Errorf("invalid pinned pair %q: want source:target, e.g. en:fr", item)
+		}
+
+		pp := PinnedPair{
+			SourceLang: strings.ToLower(source),
+			TargetLang: strings.ToLower(target),
+			Workers:    workers,
+		}
+		if seen[pp.key()] {
+			return nil, fmt.Errorf("language pair %s pinned more than once", pp.key())
+		}
+		seen[pp.key()] = true
+		pairs = append(pairs, pp)
+	}
+	return pairs, nil
+}
+
+// dispatcherFor returns the dispatcher serving a language pair: the pair's
+// pinned workers if it has any, otherwise the general workers.
+func (p *WorkerPool) dispatcherFor(sourceLang, targetLang string) *dispatcher {
+	if d, ok := p.pinned[pairKey(sourceLang, targetLang)]; ok {
+		return d
+	}
+	return p.dispatcher
+}
+
+// dispatcherForPair returns the dispatcher a worker pinned to pair ("" for
+// general workers) releases its slots to.
+func (p *WorkerPool) dispatcherForPair(pair string) *dispatcher {
+	if d, ok := p.pinned[pair]; ok {
+		return d
+	}
+	return p.dispatcher
+}
+
+// dispatchers returns the general dispatcher followed by each pinned pair's.
+func (p *WorkerPool) dispatchers() []*dispatcher {
+	all := []*dispatcher{p.dispatcher}
+	for _, pp := range p.scaling.Pinned {
+		all = append(all, p.pinned[pp.key()])
+	}
+	return all
+}
+
+// waiting returns the number of requests waiting for a worker of any kind.
+func (p *WorkerPool) waiting() int {
+	n := 0
+	for _, d := range p.dispatchers() {
+		n += d.waiting()
+	}
+	return n
+}
+
+// PinnedPairs returns the language pairs with dedicated workers.
+func (p *WorkerPool) PinnedPairs() []PinnedPair {
+	return append([]PinnedPair(nil), p.scaling.Pinned...)
+}
+
+// workerCounts returns the number of local workers in the pool (including
+// ones monitor is about to restart) for each pair, "" being general workers.
+// Callers hold p.workerMu.
+func (p *WorkerPool) workerCounts() map[string]int {
+	counts := make(map[string]int)
+	for _, w := range p.workers {
+		if !w.remote() {
+			counts[w.pair]++
+		}
+	}
+	for pair, n := range p.restarting {
+		counts[pair] += n
+	}
+	return counts
+}
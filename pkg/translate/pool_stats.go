@@ -0,0 +1,138 @@
+package translate
+
+import (
+	"sort"
+	"time"
+)
+
+// PoolStats is a snapshot of the worker pool for operators, showing why
+// throughput dropped (workers restarting, quarantined or recycled, requests
+// queueing) without going through the logs.
+type PoolStats struct {
+	Engine     EngineType
+	MinWorkers int
+	MaxWorkers int
+	Workers    int // Workers in the pool, including pinned and starting ones
+	Busy       int // Workers with requests in flight
+	// QueueDepth is the number of requests waiting for a worker;
+	// QueueDepthByPair splits it by pinned pair ("" for general workers).
+	QueueDepth       int
+	QueueDepthByPair map[string]int
+	// QueueCapacity is the most requests that may wait for the general or
+	// a pinned pair's workers (0 = unbounded).
+	QueueCapacity int
+	// AverageRoundTrip is the recent average time a worker takes to answer.
+	AverageRoundTrip time.Duration
+	// LastError is the most recent worker failure, kept across restarts.
+	LastError   string
+	LastErrorAt time.Time
+
+	PerWorker []WorkerStats
+}
+
+// WorkerStats describes one worker in PoolStats.
+type WorkerStats struct {
+	ID          int
+	Generation  uint64
+	Pair        string // Pinned pair, "" for general workers
+	Address     string // host:port of a remote worker, "" for a local process
+	Interactive bool
+	Standby     bool // Started by an upgrade in progress; takes no requests yet
+	State       string
+	Quarantined bool
+	InFlight    int
+	Requests    int // Served since start
+	StartedAt   time.Time
+	Uptime      time.Duration
+	LastUsed    time.Time
+	RSSBytes    int64
+	LastError   string
+	LastErrorAt time.Time
+	// From the worker's ready message
+	Version       string
+	EngineVersion string
+	Models        []string
+}
+
+// PoolStats returns a snapshot of the pool and each of its workers, ordered
+// by worker ID.
+func (p *WorkerPool) PoolStats() PoolStats {
+	stats := PoolStats{
+		Engine:           p.engine,
+		MinWorkers:       p.scaling.MinWorkers,
+		MaxWorkers:       p.scaling.MaxWorkers,
+		QueueDepthByPair: map[string]int{"": p.dispatcher.waiting()},
+		QueueCapacity:    p.dispatcher.capacity,
+	}
+	for _, pp := range p.scaling.Pinned {
+		stats.QueueDepthByPair[pp.key()] = p.pinned[pp.key()].waiting()
+	}
+	for _, n := range stats.QueueDepthByPair {
+		stats.QueueDepth += n
+	}
+
+	p.waitMu.Lock()
+	stats.AverageRoundTrip = p.serviceTime
+	stats.LastError, stats.LastErrorAt = p.lastError, p.lastErrorAt
+	p.waitMu.Unlock()
+
+	p.workerMu.RLock()
+	workers := append([]*TranslationWorker(nil), p.workers...)
+	p.workerMu.RUnlock()
+
+	now := time.Now()
+	for _, w := range workers {
+		w.mu.Lock()
+		ws := WorkerStats{
+			ID:            w.id,
+			Generation:    w.generation,
+			Pair:          w.pair,
+			Address:       w.address,
+			Interactive:   w.interactive,
+			Standby:       w.standby,
+			State:         w.state.String(),
+			Quarantined:   w.quarantined,
+			InFlight:      w.inFlight,
+			Requests:      w.requests,
+			StartedAt:     w.startedAt,
+			Uptime:        now.Sub(w.startedAt),
+			LastUsed:      w.lastUsed,
+			RSSBytes:      w.rssBytes,
+			LastError:     w.lastError,
+			LastErrorAt:   w.lastErrorAt,
+			Version:       w.hello.Version,
+			EngineVersion: w.hello.Engine,
+			Models:        append([]string(nil), w.hello.Models...),
+		}
+		w.mu.Unlock()
+
+		if ws.InFlight > 0 {
+			stats.Busy++
+		}
+		stats.PerWorker = append(stats.PerWorker, ws)
+	}
+	stats.Workers = len(stats.PerWorker)
+	sort.Slice(stats.PerWorker, func(i, j int) bool {
+		a, b := stats.PerWorker[i], stats.PerWorker[j]
+		if a.ID != b.ID {
+			return a.ID < b.ID
+		}
+		return a.Generation < b.Generation
+	})
+	return stats
+}
+
+// noteError records a failure attributed to the worker for PoolStats.
+func (w *TranslationWorker) noteError(err error) {
+	if err == nil {
+		return
+	}
+	now := time.Now()
+	w.mu.Lock()
+	w.lastError, w.lastErrorAt = err.Error(), now
+	w.mu.Unlock()
+
+	w.pool.waitMu.Lock()
+	w.pool.lastError, w.pool.lastErrorAt = err.Error(), now
+	w.pool.waitMu.Unlock()
+}
@@ -0,0 +1,116 @@
+package translate
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"syscall"
+)
+
+// WorkerSandbox isolates worker processes, which translate untrusted content.
+// Zero fields leave that kind of isolation off.
+type WorkerSandbox struct {
+	// UID runs workers as a dedicated user, and GID as its group (default:
+	// the server's group). Switching user needs the server to run as root.
+	UID int
+	GID int
+	// CgroupPath is a cgroup directory (e.g. /sys/fs/cgroup/iskoces-workers)
+	// each worker is moved into once started, so the cgroup's memory and CPU
+	// limits cover all workers together.
+	CgroupPath string
+	// MaxAddressSpaceBytes, MaxFileSizeBytes, MaxOpenFiles and MaxProcesses
+	// set each worker's RLIMIT_AS, RLIMIT_FSIZE, RLIMIT_NOFILE and RLIMIT_NPROC.
+	MaxAddressSpaceBytes int64
+	MaxFileSizeBytes     int64
+	MaxOpenFiles         int
+	MaxProcesses         int
+	// ReadOnly refuses file writes from the worker's Python code outside the
+	// socket directory and WritableDirs (e.g. a model cache).
+	ReadOnly     bool
+	WritableDirs []string
+}
+
+// validate checks the sandbox settings before any worker starts.
+func (s WorkerSandbox) validate() error {
+	if s.UID < 0 || s.GID < 0 {
+		return errors.New("worker UID and GID must not be negative")
+	}
+	if s.MaxAddressSpaceBytes < 0 || s.MaxFileSizeBytes < 0 || s.MaxOpenFiles < 0 || s.MaxProcesses < 0 {
+		return errors.New("worker resource limits must not be negative")
+	}
+	if s.CgroupPath != "" {
+		if _, err := os.Stat(filepath.Join(s.CgroupPath, "cgroup.procs")); err != nil {
+			return fmt.Errorf("worker cgroup: %w", err)
+		}
+	}
+	for _, dir := range s.WritableDirs {
+		if !filepath.IsAbs(dir) {
+			return fmt.Errorf("writable directory %q must be an absolute path", dir)
+		}
+	}
+	return nil
+}
+
+// args returns the worker script options that apply the resource limits and
+// write restrictions.
+func (s WorkerSandbox) args() []string {
+	var args []string
+	limit := func(flag string, value int64) {
+		if value > 0 {
+			args = append(args, flag, strconv.FormatInt(value, 10))
+		}
+	}
+	limit("--rlimit-as", s.MaxAddressSpaceBytes)
+	limit("--rlimit-fsize", s.MaxFileSizeBytes)
+	limit("--rlimit-nofile", int64(s.MaxOpenFiles))
+	limit("--rlimit-nproc", int64(s.MaxProcesses))
+	if s.ReadOnly {
+		args = append(args, "--read-only")
+		for _, dir := range s.WritableDirs {
+			args = append(args, "--writable", dir)
+		}
+	}
+	return args
+}
+
+// gid returns the group workers run as.
+func (s WorkerSandbox) gid() int {
+	if s.GID > 0 {
+		return s.GID
+	}
+	return os.Getgid()
+}
+
+// apply sets the user the worker process runs as.
+func (s WorkerSandbox) apply(cmd *exec.Cmd) {
+	if s.UID == 0 {
+		return
+	}
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Credential: &syscall.Credential{Uid: uint32(s.UID), Gid: uint32(s.gid())},
+	}
+}
+
+// prepareSocketDir lets a dedicated worker user create its socket.
+func (s WorkerSandbox) prepareSocketDir(dir string) error {
+	if s.UID == 0 {
+		return nil
+	}
+	return os.Chown(dir, s.UID, s.gid())
+}
+
+// assign moves a started worker into the sandbox's cgroup. The worker takes
+// no requests until it is ready, so nothing untrusted runs outside it.
+func (s WorkerSandbox) assign(pid int) error {
+	if s.CgroupPath == "" {
+		return nil
+	}
+	procs := filepath.Join(s.CgroupPath, "cgroup.procs")
+	if err := os.WriteFile(procs, []byte(strconv.Itoa(pid)), 0644); err != nil {
+		return fmt.Errorf("failed to move worker into cgroup %s: %w", s.CgroupPath, err)
+	}
+	return nil
+}
@@ -0,0 +1,316 @@
+package translate
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+const (
+	// DefaultMaxInFlightPerWorker is how many requests may be outstanding on
+	// one worker connection. The worker queues them and translates requests
+	// for the same language pair together.
+	DefaultMaxInFlightPerWorker = 8
+
+	// DefaultWorkerRequestTimeout bounds one request's round trip.
+	DefaultWorkerRequestTimeout = 5 * time.Minute
+
+	// workerWriteTimeout bounds writing one request to the socket.
+	workerWriteTimeout = 10 * time.Second
+
+	// DefaultWorkerShutdownTimeout is how long Close waits for requests in
+	// flight before stopping the workers.
+	DefaultWorkerShutdownTimeout = 30 * time.Second
+
+	// workerExitTimeout is how long a worker gets to exit after being asked to.
+	workerExitTimeout = 5 * time.Second
+
+	// DefaultWorkerReadyTimeout is how long a new worker gets to load and
+	// announce itself ready. Pinned workers load their model first.
+	DefaultWorkerReadyTimeout = 2 * time.Minute
+
+	// workerHelloTimeout bounds reading the ready message on a new connection
+	// to a worker that is already running.
+	workerHelloTimeout = 10 * time.Second
+)
+
+// workerHello is the first message a worker sends on every connection,
+// once it is ready to translate.
+type workerHello struct {
+	Ready   bool     `json:"ready"`
+	Version string   `json:"version,omitempty"` // Worker script version
+	Engine  string   `json:"engine,omitempty"`  // Translation library and version
+	Models  []string `json:"models,omitempty"`  // Installed pairs, e.g. "en:fr"
+	Pinned  string   `json:"pinned,omitempty"`  // Pair the worker is pinned to
+}
+
+// Worker transports, selected with WorkerPoolOptions.Transport.
+const (
+	// WorkerTransportJSON multiplexes newline-delimited JSON requests on one
+	// connection per worker.
+	WorkerTransportJSON = "json"
+	// WorkerTransportGRPC calls the worker's WorkerService (proto/worker.proto).
+	WorkerTransportGRPC = "grpc"
+)
+
+// workerTransport is a connection to a worker over one of the transports.
+type workerTransport interface {
+	// roundTrip sends a request and waits for its response, ctx, or timeout.
+	roundTrip(ctx context.Context, req *TranslationRequest, timeout time.Duration) (*TranslationResponse, error)
+	// send delivers a control message that expects no response (shutdown).
+	send(req *TranslationRequest) error
+	// info returns the ready message the worker sent when connected.
+	info() workerHello
+	// failed reports whether the connection is unusable.
+	failed() bool
+	// fail closes the connection, failing pending requests with err.
+	fail(err error)
+	// close closes the connection.
+	close()
+}
+
+// dial connects to the worker over the pool's transport, on its Unix socket
+// or, for a remote worker, over TCP with mutual TLS. It waits up to
+// helloTimeout for the worker's ready message.
+func (w *TranslationWorker) dial(helloTimeout time.Duration) (workerTransport, error) {
+	p := w.pool
+	var conn workerTransport
+	var err error
+	switch {
+	case p.transport == WorkerTransportGRPC && w.remote():
+		conn, err = dialGRPCWorker(w.address, credentials.NewTLS(p.remoteTLS), helloTimeout)
+	case p.transport == WorkerTransportGRPC:
+		conn, err = dialGRPCWorker("unix://"+w.socketPath, insecure.NewCredentials(), helloTimeout)
+	case w.remote():
+		conn, err = dialRemoteWorker(w.address, p.remoteTLS, helloTimeout)
+	default:
+		conn, err = dialWorker(w.socketPath, helloTimeout)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
+// workerConn is a multiplexed connection to a worker. Requests carry an ID
+// that the worker echoes in its response, so many requests can be in flight
+// on one connection and responses may arrive in any order.
+type workerConn struct {
+	conn    net.Conn
+	decoder *json.Decoder
+	hello   workerHello
+	writeMu sync.Mutex // serializes request lines
+
+	mu      sync.Mutex
+	pending map[uint64]chan *TranslationResponse
+	nextID  uint64
+	err     error // set once the connection has failed
+}
+
+// dialWorker connects to a worker socket, waits up to helloTimeout for the
+// worker's ready message, and starts reading responses.
+func dialWorker(socketPath string, helloTimeout time.Duration) (*workerConn, error) {
+	conn, err := net.DialUnix("unix", nil, &net.UnixAddr{Name: socketPath, Net: "unix"})
+	if err != nil {
+		return nil, err
+	}
+	return newWorkerConn(conn, helloTimeout)
+}
+
+// dialRemoteWorker connects to a remote worker over TCP with mutual TLS and
+// starts the connection as dialWorker does.
+func dialRemoteWorker(address string, config *tls.Config, helloTimeout time.Duration) (*workerConn, error) {
+	dialer := &tls.Dialer{
+		NetDialer: &net.Dialer{Timeout: helloTimeout},
+		Config:    config,
+	}
+	conn, err := dialer.Dial("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	return newWorkerConn(conn, helloTimeout)
+}
+
+// newWorkerConn waits up to helloTimeout for the worker's ready message on
+// conn and starts reading responses.
+func newWorkerConn(conn net.Conn, helloTimeout time.Duration) (*workerConn, error) {
+	wc := &workerConn{
+		conn:    conn,
+		decoder: json.NewDecoder(bufio.NewReader(conn)),
+		pending: make(map[uint64]chan *TranslationResponse),
+	}
+
+	conn.SetReadDeadline(time.Now().Add(helloTimeout))
+	if err := wc.decoder.Decode(&wc.hello); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("no ready message from worker: %w", err)
+	}
+	if !wc.hello.Ready {
+		conn.Close()
+		return nil, fmt.Errorf("worker sent an unexpected first message instead of ready")
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	go wc.readLoop()
+	return wc, nil
+}
+
+// requestTimeout is how long a request may take: the time left before ctx's
+// deadline, capped at ceiling.
+func requestTimeout(ctx context.Context, ceiling time.Duration) time.Duration {
+	if deadline, ok := ctx.Deadline(); ok {
+		return min(time.Until(deadline), ceiling)
+	}
+	return ceiling
+}
+
+// roundTrip sends a request and waits for its response, ctx, or timeout.
+// The worker is told the timeout, and an abandoned request is cancelled on
+// the worker.
+func (wc *workerConn) roundTrip(ctx context.Context, req *TranslationRequest, timeout time.Duration) (*TranslationResponse, error) {
+	wc.mu.Lock()
+	if wc.err != nil {
+		err := wc.err
+		wc.mu.Unlock()
+		return nil, err
+	}
+	wc.nextID++
+	id := wc.nextID
+	ch := make(chan *TranslationResponse, 1)
+	wc.pending[id] = ch
+	wc.mu.Unlock()
+
+	sent := *req
+	sent.ID = id
+	sent.TimeoutMs = max(timeout.Milliseconds(), 1)
+	if err := wc.write(&sent); err != nil {
+		wc.forget(id)
+		wc.fail(fmt.Errorf("%w: %w: failed to send request: %w", ErrEngineUnavailable, errWorkerFault, err))
+		return nil, wc.failure()
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case resp := <-ch:
+		if resp == nil {
+			return nil, wc.failure()
+		}
+		return resp, nil
+	case <-ctx.Done():
+		wc.abandon(id)
+		return nil, fmt.Errorf("request abandoned: %w", ctx.Err())
+	case <-timer.C:
+		wc.abandon(id)
+		return nil, fmt.Errorf("worker request timed out after %s: %w", timeout, context.DeadlineExceeded)
+	}
+}
+
+// write sends one request line.
+func (wc *workerConn) write(req *TranslationRequest) error {
+	line, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	wc.writeMu.Lock()
+	defer wc.writeMu.Unlock()
+	wc.conn.SetWriteDeadline(time.Now().Add(workerWriteTimeout))
+	_, err = wc.conn.Write(append(line, '\n'))
+	return err
+}
+
+// info returns the worker's ready message.
+func (wc *workerConn) info() workerHello {
+	return wc.hello
+}
+
+// send writes a control message that expects no response, such as a shutdown.
+func (wc *workerConn) send(req *TranslationRequest) error {
+	if wc.failed() {
+		return wc.failure()
+	}
+	return wc.write(req)
+}
+
+// abandon stops waiting for a request and asks the worker to skip it.
+func (wc *workerConn) abandon(id uint64) {
+	if wc.forget(id) {
+		_ = wc.write(&TranslationRequest{ID: id, Cancel: true})
+	}
+}
+
+// forget removes a pending request. Returns false if it was already answered.
+func (wc *workerConn) forget(id uint64) bool {
+	wc.mu.Lock()
+	defer wc.mu.Unlock()
+	_, ok := wc.pending[id]
+	delete(wc.pending, id)
+	return ok
+}
+
+// readLoop delivers responses to their requests until the connection fails.
+func (wc *workerConn) readLoop() {
+	for {
+		var resp TranslationResponse
+		if err := wc.decoder.Decode(&resp); err != nil {
+			wc.fail(fmt.Errorf("%w: %w: worker connection closed: %w", ErrEngineUnavailable, errWorkerFault, err))
+			return
+		}
+		wc.mu.Lock()
+		ch, ok := wc.pending[resp.ID]
+		delete(wc.pending, resp.ID)
+		wc.mu.Unlock()
+		if ok {
+			ch <- &resp
+		}
+	}
+}
+
+// fail marks the connection failed, closes it, and wakes every pending request.
+func (wc *workerConn) fail(err error) {
+	wc.mu.Lock()
+	if wc.err != nil {
+		wc.mu.Unlock()
+		return
+	}
+	wc.err = err
+	pending := wc.pending
+	wc.pending = make(map[uint64]chan *TranslationResponse)
+	wc.mu.Unlock()
+
+	wc.conn.Close()
+	for _, ch := range pending {
+		close(ch)
+	}
+}
+
+// failure returns the error the connection failed with.
+func (wc *workerConn) failure() error {
+	wc.mu.Lock()
+	defer wc.mu.Unlock()
+	if wc.err == nil {
+		return fmt.Errorf("%w: %w: worker connection closed", ErrEngineUnavailable, errWorkerFault)
+	}
+	return wc.err
+}
+
+// failed reports whether the connection has failed.
+func (wc *workerConn) failed() bool {
+	wc.mu.Lock()
+	defer wc.mu.Unlock()
+	return wc.err != nil
+}
+
+// close closes the connection, failing any pending requests.
+func (wc *workerConn) close() {
+	wc.fail(fmt.Errorf("%w: %w: worker connection closed", ErrEngineUnavailable, errWorkerFault))
+}
@@ -0,0 +1,244 @@
+package translate
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// crashReportWait is how long a request whose worker connection broke waits
+// for the process to exit, so the failure can carry a crash report. The
+// broken connection is usually noticed just before the exit.
+const crashReportWait = time.Second
+
+// CrashClass is the likely cause of a worker's death.
+type CrashClass string
+
+const (
+	// CrashClassOOM: killed by the kernel (SIGKILL the pool didn't send) or
+	// the worker ran out of memory (Python MemoryError).
+	CrashClassOOM CrashClass = "oom"
+	// CrashClassSegfault: SIGSEGV or SIGBUS, usually a native library bug.
+	CrashClassSegfault CrashClass = "segfault"
+	// CrashClassAbort: SIGABRT, e.g. a failed assertion in native code.
+	CrashClassAbort CrashClass = "abort"
+	// CrashClassSignal: any other signal.
+	CrashClassSignal CrashClass = "signal"
+	// CrashClassModelLoad: the worker exited before reporting ready, e.g.
+	// a pinned model failed to load.
+	CrashClassModelLoad CrashClass = "model_load"
+	// CrashClassKilled: the pool killed the worker (a failed probe, or it
+	// didn't stop or become ready in time).
+	CrashClassKilled CrashClass = "killed"
+	// CrashClassError: the worker exited with a non-zero status, e.g. an
+	// uncaught Python exception.
+	CrashClassError CrashClass = "error"
+	// CrashClassExit: the worker exited cleanly when it shouldn't have.
+	CrashClassExit CrashClass = "exit"
+)
+
+var workerCrashes = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "iskoces_worker_crashes_total",
+		Help: "Total number of worker processes that died unexpectedly, by crash class (oom, segfault, abort, signal, model_load, killed, error, exit)",
+	},
+	[]string{"engine", "class"},
+)
+
+// CrashRequest describes the last request sent to a worker before it died.
+type CrashRequest struct {
+	RequestID  string // ID of the call the request was for, if known
+	SourceLang string
+	TargetLang string
+	Texts      int // Texts in the request (1 for a single text)
+	Chars      int // Total length of the texts
+	StartedAt  time.Time
+}
+
+// CrashReport describes a worker's death: how the process ended, what it
+// was doing, and what it last wrote.
+type CrashReport struct {
+	WorkerID    int
+	Generation  uint64
+	Pair        string // Pinned language pair key, "" for general workers
+	Class       CrashClass
+	ExitCode    int    // -1 if the process was killed by a signal
+	Signal      string // Signal name, e.g. "segmentation fault", if killed by one
+	At          time.Time
+	Uptime      time.Duration
+	RSSBytes    int64         // Resident memory at the last metrics update before death
+	LastRequest *CrashRequest // nil if the worker never received a request
+	Output      []string      // Last lines the worker wrote, oldest first
+}
+
+// Summary describes the crash in one line for error messages.
+func (r *CrashReport) Summary() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "worker %d crashed (%s", r.WorkerID, r.Class)
+	if r.Signal != "" {
+		fmt.Fprintf(&b, ", signal: %s", r.Signal)
+	} else {
+		fmt.Fprintf(&b, ", exit code %d", r.ExitCode)
+	}
+	fmt.Fprintf(&b, ") after %s", r.Uptime.Round(time.Second))
+	if r.RSSBytes > 0 {
+		fmt.Fprintf(&b, " using %d MiB", r.RSSBytes>>20)
+	}
+	if req := r.LastRequest; req != nil {
+		fmt.Fprintf(&b, "; last request %s -> %s, %d text(s), %d chars", req.SourceLang, req.TargetLang, req.Texts, req.Chars)
+		if req.RequestID != "" {
+			fmt.Fprintf(&b, " (request %s)", req.RequestID)
+		}
+	}
+	return b.String()
+}
+
+// CrashError is a request failure caused by the worker's process dying.
+// It unwraps to the underlying failure, so it classifies the same way.
+type CrashError struct {
+	Report *CrashReport
+	Err    error
+}
+
+func (e *CrashError) Error() string {
+	return fmt.Sprintf("%v: %s", e.Err, e.Report.Summary())
+}
+
+func (e *CrashError) Unwrap() error {
+	return e.Err
+}
+
+// CrashReportOf returns the crash report carried by err, if any.
+func CrashReportOf(err error) (*CrashReport, bool) {
+	var crash *CrashError
+	if errors.As(err, &crash) {
+		return crash.Report, true
+	}
+	return nil, false
+}
+
+// noteRequest records the request being sent for crash reports.
+func (w *TranslationWorker) noteRequest(req *TranslationRequest) {
+	info := &CrashRequest{
+		RequestID:  req.RequestID,
+		SourceLang: req.SourceLang,
+		TargetLang: req.TargetLang,
+		Texts:      len(req.Texts),
+		Chars:      len(req.Text),
+		StartedAt:  time.Now(),
+	}
+	if req.Texts == nil {
+		info.Texts = 1
+	}
+	for _, text := range req.Texts {
+		info.Chars += len(text)
+	}
+
+	w.mu.Lock()
+	w.lastRequest = info
+	w.mu.Unlock()
+}
+
+// kill kills the worker's process, marking the exit as the pool's doing.
+// A remote worker is disconnected instead.
+func (w *TranslationWorker) kill() {
+	w.mu.Lock()
+	w.killed = true
+	w.mu.Unlock()
+	if w.remote() {
+		w.disconnect()
+		return
+	}
+	if w.process != nil && w.process.Process != nil {
+		w.process.Process.Kill()
+	}
+}
+
+// crashError attaches a crash report to a worker fault if the worker's
+// process has died (or dies within crashReportWait). Remote workers have no
+// process to report on.
+func (w *TranslationWorker) crashError(err error) error {
+	if !errors.Is(err, errWorkerFault) || w.remote() {
+		return err
+	}
+	select {
+	case <-w.exited:
+	case <-time.After(crashReportWait):
+		return err
+	}
+	return &CrashError{Report: w.crashReport(), Err: err}
+}
+
+// crashReport builds the worker's crash report and counts the crash. It
+// must only be called once the process has exited; later calls return the
+// same report.
+func (w *TranslationWorker) crashReport() *CrashReport {
+	w.crashOnce.Do(func() {
+		w.mu.Lock()
+		report := &CrashReport{
+			WorkerID:    w.id,
+			Generation:  w.generation,
+			Pair:        w.pair,
+			ExitCode:    -1,
+			At:          time.Now(),
+			Uptime:      time.Since(w.startedAt),
+			RSSBytes:    w.rssBytes,
+			LastRequest: w.lastRequest,
+		}
+		killed := w.killed
+		ready := w.hello.Ready
+		w.mu.Unlock()
+		report.Output = w.output.snapshot()
+
+		var signal syscall.Signal
+		signaled := false
+		if w.process != nil && w.process.ProcessState != nil {
+			state := w.process.ProcessState
+			report.ExitCode = state.ExitCode()
+			if status, ok := state.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+				signal, signaled = status.Signal(), true
+				report.Signal = signal.String()
+			}
+		}
+		report.Class = classifyCrash(report, signal, signaled, killed, ready)
+
+		workerCrashes.WithLabelValues(string(w.pool.engine), string(report.Class)).Inc()
+		w.crash = report
+	})
+	return w.crash
+}
+
+// classifyCrash picks the likely cause of a worker's death.
+func classifyCrash(report *CrashReport, signal syscall.Signal, signaled, killed, ready bool) CrashClass {
+	outOfMemory := false
+	for _, line := range report.Output {
+		if strings.Contains(line, "MemoryError") || strings.Contains(line, "std::bad_alloc") {
+			outOfMemory = true
+			break
+		}
+	}
+
+	switch {
+	case killed:
+		return CrashClassKilled
+	case signaled && signal == syscall.SIGKILL, outOfMemory:
+		return CrashClassOOM
+	case signaled && (signal == syscall.SIGSEGV || signal == syscall.SIGBUS):
+		return CrashClassSegfault
+	case signaled && signal == syscall.SIGABRT:
+		return CrashClassAbort
+	case signaled:
+		return CrashClassSignal
+	case !ready:
+		return CrashClassModelLoad
+	case report.ExitCode != 0:
+		return CrashClassError
+	default:
+		return CrashClassExit
+	}
+}
@@ -0,0 +1,175 @@
+package translate
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	workerpb "github.com/dasmlab/iskoces/pkg/proto/worker"
+)
+
+// workerRequestIDMetadataKey is the metadata key Translate calls carry the
+// request ID in, like the server's own calls.
+const workerRequestIDMetadataKey = "x-request-id"
+
+// grpcWorkerConn talks to a worker serving WorkerService (proto/worker.proto)
+// over its Unix socket, or over TCP with mutual TLS for a remote worker.
+// Deadlines and cancellation travel with each call, and errors come back as
+// status codes instead of JSON fields.
+type grpcWorkerConn struct {
+	cc     *grpc.ClientConn
+	client workerpb.WorkerServiceClient
+	hello  workerHello
+
+	mu  sync.Mutex
+	err error // set once the connection has been closed
+}
+
+// dialGRPCWorker connects to a worker's gRPC target and calls Hello, which
+// succeeds once the worker is serving.
+func dialGRPCWorker(target string, creds credentials.TransportCredentials, helloTimeout time.Duration) (*grpcWorkerConn, error) {
+	cc, err := grpc.NewClient(target, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, err
+	}
+	wc := &grpcWorkerConn{
+		cc:     cc,
+		client: workerpb.NewWorkerServiceClient(cc),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), helloTimeout)
+	defer cancel()
+	resp, err := wc.client.Hello(ctx, &workerpb.HelloRequest{})
+	if err != nil {
+		cc.Close()
+		return nil, fmt.Errorf("no hello from worker: %w", err)
+	}
+	wc.hello = workerHello{
+		Ready:   true,
+		Version: resp.GetVersion(),
+		Engine:  resp.GetEngine(),
+		Models:  resp.GetModels(),
+		Pinned:  resp.GetPinned(),
+	}
+	return wc, nil
+}
+
+// roundTrip sends a request and waits for its response, ctx, or timeout.
+// Translation errors reported by the worker come back as unsuccessful
+// responses, like on the JSON transport.
+func (wc *grpcWorkerConn) roundTrip(ctx context.Context, req *TranslationRequest, timeout time.Duration) (*TranslationResponse, error) {
+	if wc.failed() {
+		return nil, wc.failure()
+	}
+
+	callCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if req.Ping {
+		if _, err := wc.client.Ping(callCtx, &workerpb.PingRequest{}); err != nil {
+			return nil, wc.callError(ctx, err, timeout)
+		}
+		return &TranslationResponse{Success: true}, nil
+	}
+
+	if req.RequestID != "" {
+		callCtx = metadata.AppendToOutgoingContext(callCtx, workerRequestIDMetadataKey, req.RequestID)
+	}
+	resp, err := wc.client.Translate(callCtx, &workerpb.TranslateRequest{
+		Text:       req.Text,
+		Texts:      req.Texts,
+		SourceLang: req.SourceLang,
+		TargetLang: req.TargetLang,
+	})
+	if err != nil {
+		switch status.Code(err) {
+		case codes.InvalidArgument:
+			return &TranslationResponse{
+				Error:     status.Convert(err).Message(),
+				ErrorCode: string(ErrorClassUnsupportedLanguage),
+			}, nil
+		case codes.Internal, codes.Unknown:
+			return &TranslationResponse{Error: status.Convert(err).Message()}, nil
+		}
+		return nil, wc.callError(ctx, err, timeout)
+	}
+
+	return &TranslationResponse{
+		Success:         true,
+		TranslatedText:  resp.GetTranslatedText(),
+		TranslatedTexts: resp.GetTranslatedTexts(),
+	}, nil
+}
+
+// callError converts a failed call into the errors the JSON transport
+// returns: abandoned, timed out, or a worker fault.
+func (wc *grpcWorkerConn) callError(ctx context.Context, err error, timeout time.Duration) error {
+	if ctx.Err() != nil {
+		return fmt.Errorf("request abandoned: %w", ctx.Err())
+	}
+	if status.Code(err) == codes.DeadlineExceeded {
+		return fmt.Errorf("worker request timed out after %s: %w", timeout, context.DeadlineExceeded)
+	}
+	return fmt.Errorf("%w: %w: %s", ErrEngineUnavailable, errWorkerFault, status.Convert(err).Message())
+}
+
+// send delivers a control message. Only shutdown is meaningful here.
+func (wc *grpcWorkerConn) send(req *TranslationRequest) error {
+	if wc.failed() {
+		return wc.failure()
+	}
+	if !req.Shutdown {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), workerWriteTimeout)
+	defer cancel()
+	_, err := wc.client.Shutdown(ctx, &workerpb.ShutdownRequest{})
+	return err
+}
+
+// info returns the worker's hello.
+func (wc *grpcWorkerConn) info() workerHello {
+	return wc.hello
+}
+
+// fail marks the connection failed and closes it.
+func (wc *grpcWorkerConn) fail(err error) {
+	wc.mu.Lock()
+	if wc.err != nil {
+		wc.mu.Unlock()
+		return
+	}
+	wc.err = err
+	wc.mu.Unlock()
+	wc.cc.Close()
+}
+
+// failure returns the error the connection was closed with.
+func (wc *grpcWorkerConn) failure() error {
+	wc.mu.Lock()
+	defer wc.mu.Unlock()
+	if wc.err == nil {
+		return fmt.Errorf("%w: %w: worker connection closed", ErrEngineUnavailable, errWorkerFault)
+	}
+	return wc.err
+}
+
+// failed reports whether the connection has been closed. The gRPC client
+// reconnects by itself, so a failed call doesn't fail the connection.
+func (wc *grpcWorkerConn) failed() bool {
+	wc.mu.Lock()
+	defer wc.mu.Unlock()
+	return wc.err != nil
+}
+
+// close closes the connection, failing calls in progress.
+func (wc *grpcWorkerConn) close() {
+	wc.fail(fmt.Errorf("%w: %w: worker connection closed", ErrEngineUnavailable, errWorkerFault))
+}
@@ -0,0 +1,134 @@
+package translate
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// workerState is where a worker is in its lifecycle. States only move
+// forward: starting → ready → stopping → exited. A worker whose process dies
+// goes straight to exited from starting or ready.
+type workerState int
+
+const (
+	// workerStarting: the process is starting; its ID is reserved but it
+	// takes no requests yet.
+	workerStarting workerState = iota
+	// workerReady: the worker's request slots are in the dispatcher.
+	workerReady
+	// workerStopping: the worker is being stopped (scale-down or shutdown)
+	// and must not be restarted when its process exits.
+	workerStopping
+	// workerExited: the process has exited and the worker is out of the pool.
+	workerExited
+)
+
+// String returns the state name used in logs.
+func (s workerState) String() string {
+	switch s {
+	case workerStarting:
+		return "starting"
+	case workerReady:
+		return "ready"
+	case workerStopping:
+		return "stopping"
+	case workerExited:
+		return "exited"
+	default:
+		return fmt.Sprintf("workerState(%d)", int(s))
+	}
+}
+
+// errWorkerIDInUse is returned by startWorker when a live worker already has the ID.
+var errWorkerIDInUse = errors.New("worker ID already in use")
+
+// advance moves the worker to state s. It returns false, leaving the state
+// unchanged, if the worker is already at or past s.
+func (w *TranslationWorker) advance(s workerState) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.state >= s {
+		return false
+	}
+	w.state = s
+	return true
+}
+
+// awaitReady waits for a just-started worker to accept a connection and send
+// its ready message, keeping that connection for requests. It fails if the
+// process exits or timeout elapses first.
+func (w *TranslationWorker) awaitReady(timeout time.Duration) (workerHello, error) {
+	deadline := time.Now().Add(timeout)
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return workerHello{}, fmt.Errorf("no ready message within %s", timeout)
+		}
+
+		socketStart := time.Now()
+		conn, err := w.dial(remaining)
+		if err == nil {
+			w.pool.metrics.RecordSocketConnection(w.id, time.Since(socketStart), true)
+			w.mu.Lock()
+			w.conn = conn
+			w.mu.Unlock()
+			return conn.info(), nil
+		}
+
+		// The socket doesn't exist until the worker is listening
+		select {
+		case <-w.exited:
+			return workerHello{}, fmt.Errorf("process exited before becoming ready: %v", w.waitErr)
+		case <-ticker.C:
+		}
+	}
+}
+
+// ready reports whether the worker can take requests.
+func (w *TranslationWorker) ready() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.state == workerReady && !w.quarantined && !w.standby
+}
+
+// dropWorker removes a worker from the pool if it is still listed. The
+// entry is matched by identity, so a newer generation with the same ID is
+// left alone. restarting marks that the caller is about to start a
+// replacement, so the health check doesn't start one as well.
+func (p *WorkerPool) dropWorker(worker *TranslationWorker, restarting bool) {
+	p.workerMu.Lock()
+	defer p.workerMu.Unlock()
+
+	for i, w := range p.workers {
+		if w == worker {
+			p.workers = append(p.workers[:i], p.workers[i+1:]...)
+			break
+		}
+	}
+	if restarting {
+		p.restarting[worker.pair]++
+	}
+}
+
+// interactiveWorkers counts the interactive workers that are starting or
+// ready, among standby workers or among the others. Callers hold p.workerMu.
+func (p *WorkerPool) interactiveWorkers(standby bool) int {
+	n := 0
+	for _, w := range p.workers {
+		if w.interactive && w.isStandby() == standby && w.currentState() < workerStopping {
+			n++
+		}
+	}
+	return n
+}
+
+// restartDone records that a replacement started by monitor has been attempted.
+func (p *WorkerPool) restartDone(pair string) {
+	p.workerMu.Lock()
+	p.restarting[pair]--
+	p.workerMu.Unlock()
+}
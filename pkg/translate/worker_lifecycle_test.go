@@ -0,0 +1,227 @@
+package translate
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// newLifecyclePool returns a pool with no workers whose launches run
+// pythonPath with a script that never becomes ready.
+func newLifecyclePool(t *testing.T, pythonPath string) *WorkerPool {
+	t.Helper()
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	script := filepath.Join(t.TempDir(), "worker.sh")
+	if err := os.WriteFile(script, []byte("exec sleep 30\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	p := &WorkerPool{
+		engine:       EngineArgos,
+		launch:       WorkerLaunch{PythonPath: pythonPath, ScriptPath: script},
+		maxInFlight:  2,
+		socketDir:    t.TempDir(),
+		readyTimeout: 300 * time.Millisecond,
+		transport:    WorkerTransportJSON,
+		logger:       logger,
+		dispatcher:   newDispatcher(0),
+		pinned:       make(map[string]*dispatcher),
+		shutdown:     make(chan struct{}),
+		restarting:   make(map[string]int),
+	}
+	p.metrics = NewMetricsCollector(p, string(EngineArgos))
+	return p
+}
+
+// addReadyWorker lists a ready worker in the pool and releases its slots,
+// as launchWorker does for a worker that started.
+func addReadyWorker(p *WorkerPool, id int, generation uint64) *TranslationWorker {
+	w := &TranslationWorker{
+		id:         id,
+		generation: generation,
+		state:      workerReady,
+		exited:     make(chan struct{}),
+		output:     &workerLogTail{},
+		logger:     p.logger.WithField("worker_id", id),
+		pool:       p,
+		startedAt:  time.Now(),
+	}
+	p.workerMu.Lock()
+	p.workers = append(p.workers, w)
+	p.workerMu.Unlock()
+	for i := 0; i < p.maxInFlight; i++ {
+		p.dispatcher.release(w)
+	}
+	return w
+}
+
+// idleSlots counts the dispatcher's free slots of w.
+func idleSlots(d *dispatcher, w *TranslationWorker) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	n := 0
+	for _, idle := range d.idle {
+		if idle == w {
+			n++
+		}
+	}
+	return n
+}
+
+func TestWorkerStateTransitions(t *testing.T) {
+	states := []workerState{workerStarting, workerReady, workerStopping, workerExited}
+	for _, from := range states {
+		for _, to := range states {
+			w := &TranslationWorker{state: from}
+			ok := w.advance(to)
+			want := to > from
+			if ok != want {
+				t.Errorf("advance %s -> %s = %v, want %v", from, to, ok, want)
+			}
+			got := w.currentState()
+			if want && got != to {
+				t.Errorf("advance %s -> %s left state %s", from, to, got)
+			}
+			if !want && got != from {
+				t.Errorf("refused advance %s -> %s changed state to %s", from, to, got)
+			}
+		}
+	}
+}
+
+func TestWorkerNotReadyTakesNoSlots(t *testing.T) {
+	p := newLifecyclePool(t, "/nonexistent/python")
+	for _, state := range []workerState{workerStarting, workerStopping, workerExited} {
+		w := &TranslationWorker{state: state, pool: p}
+		p.dispatcher.release(w)
+		if n := idleSlots(p.dispatcher, w); n != 0 {
+			t.Errorf("%s worker has %d free slots after release, want 0", state, n)
+		}
+	}
+}
+
+func TestStaleGenerationExitKeepsReplacement(t *testing.T) {
+	p := newLifecyclePool(t, "/nonexistent/python")
+	stale := addReadyWorker(p, 1, 1)
+	// The stale generation was taken out of the pool (e.g. by a failed
+	// probe) and a replacement started under the same ID before its
+	// process exited
+	p.dropWorker(stale, false)
+	replacement := addReadyWorker(p, 1, 2)
+
+	p.dropWorker(stale, false)
+	if len(p.workers) != 1 || p.workers[0] != replacement {
+		t.Fatalf("dropping the stale generation left %d workers, want only the replacement", len(p.workers))
+	}
+
+	// Its process exits now: monitor must only remove its own slots and
+	// not start another worker with the ID
+	close(stale.exited)
+	stale.monitor()
+
+	if got := stale.currentState(); got != workerExited {
+		t.Errorf("stale worker state = %s, want exited", got)
+	}
+	if len(p.workers) != 1 || p.workers[0] != replacement {
+		t.Errorf("after the stale exit the pool has %d workers, want only the replacement", len(p.workers))
+	}
+	if got := replacement.currentState(); got != workerReady {
+		t.Errorf("replacement state = %s, want ready", got)
+	}
+	if n := idleSlots(p.dispatcher, stale); n != 0 {
+		t.Errorf("stale worker has %d free slots, want 0", n)
+	}
+	if n := idleSlots(p.dispatcher, replacement); n != p.maxInFlight {
+		t.Errorf("replacement has %d free slots, want %d", n, p.maxInFlight)
+	}
+	if n := p.restarting[""]; n != 0 {
+		t.Errorf("restarting = %d after monitor finished, want 0", n)
+	}
+}
+
+func TestDeadWorkerNotRequeued(t *testing.T) {
+	p := newLifecyclePool(t, "/nonexistent/python")
+	w := addReadyWorker(p, 1, 1)
+
+	// A request holds one slot when the process dies
+	held, err := p.dispatcher.acquire(context.Background(), PriorityNormal, time.Second)
+	if err != nil || held != w {
+		t.Fatalf("acquire() = %v, %v", held, err)
+	}
+	close(w.exited)
+	// Restarting fails (the interpreter doesn't exist), so the worker
+	// stays dead
+	w.monitor()
+
+	// The request finishing hands its slot back
+	p.dispatcher.release(held)
+	if n := idleSlots(p.dispatcher, w); n != 0 {
+		t.Errorf("dead worker has %d free slots, want 0", n)
+	}
+	if _, err := p.dispatcher.acquire(context.Background(), PriorityNormal, 50*time.Millisecond); !errors.Is(err, errWorkerTimeout) {
+		t.Errorf("acquire() with only a dead worker = %v, want a timeout", err)
+	}
+	if len(p.workers) != 0 {
+		t.Errorf("pool lists %d workers after a failed restart, want 0", len(p.workers))
+	}
+	if n := p.restarting[""]; n != 0 {
+		t.Errorf("restarting = %d after monitor finished, want 0", n)
+	}
+}
+
+func TestWorkerIDReuse(t *testing.T) {
+	sh, err := os.Stat("/bin/sh")
+	if err != nil || sh.IsDir() {
+		t.Skip("needs /bin/sh")
+	}
+	p := newLifecyclePool(t, "/bin/sh")
+
+	// The ID is reserved while the first launch starts its process
+	first := make(chan error, 1)
+	go func() {
+		_, err := p.launchWorker(7, "", false)
+		first <- err
+	}()
+	deadline := time.Now().Add(time.Second)
+	for {
+		p.workerMu.RLock()
+		listed := len(p.workers)
+		p.workerMu.RUnlock()
+		if listed == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the first launch never listed its worker")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if _, err := p.launchWorker(7, "", false); !errors.Is(err, errWorkerIDInUse) {
+		t.Errorf("launching a starting worker's ID = %v, want errWorkerIDInUse", err)
+	}
+
+	// It never becomes ready, so it is killed and the ID freed
+	if err := <-first; err == nil || errors.Is(err, errWorkerIDInUse) {
+		t.Fatalf("first launch = %v, want a readiness failure", err)
+	}
+	p.workerMu.RLock()
+	listed := len(p.workers)
+	p.workerMu.RUnlock()
+	if listed != 0 {
+		t.Fatalf("pool lists %d workers after the failed start, want 0", listed)
+	}
+
+	// The ID can be used again, by a new generation with its own socket
+	if _, err := p.launchWorker(7, "", false); err == nil || errors.Is(err, errWorkerIDInUse) {
+		t.Errorf("relaunching the freed ID = %v, want a readiness failure", err)
+	}
+	// The refused launch took no generation
+	if p.generation != 2 {
+		t.Errorf("generation = %d after two started processes, want 2", p.generation)
+	}
+}
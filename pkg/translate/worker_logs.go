@@ -0,0 +1,137 @@
+package translate
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// workerLogTailLines is how many output lines are kept per worker for
+// error reports.
+const workerLogTailLines = 50
+
+// workerRequestIDPattern finds the request ID workers tag the log lines
+// about a request with.
+var workerRequestIDPattern = regexp.MustCompile(`request_id=([^\s)]+)`)
+
+// workerLogTail keeps the last lines a worker wrote.
+type workerLogTail struct {
+	mu    sync.Mutex
+	lines []string
+	next  int // Index of the oldest line once the buffer is full
+}
+
+// add appends a line, dropping the oldest when full.
+func (t *workerLogTail) add(line string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.lines) < workerLogTailLines {
+		t.lines = append(t.lines, line)
+		return
+	}
+	t.lines[t.next] = line
+	t.next = (t.next + 1) % workerLogTailLines
+}
+
+// snapshot returns the kept lines, oldest first.
+func (t *workerLogTail) snapshot() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make([]string, 0, len(t.lines))
+	out = append(out, t.lines[t.next:]...)
+	return append(out, t.lines[:t.next]...)
+}
+
+// String joins the kept lines for inclusion in a log entry.
+func (t *workerLogTail) String() string {
+	return strings.Join(t.snapshot(), "\n")
+}
+
+// parseWorkerLogLevel guesses a log level from a line of worker output:
+// Python tracebacks and errors, warnings (including the logging module's
+// "WARNING:" prefix), and debug output. Anything else is informational.
+func parseWorkerLogLevel(line string) logrus.Level {
+	lower := strings.ToLower(strings.TrimSpace(line))
+	switch {
+	case strings.HasPrefix(lower, "traceback"),
+		strings.HasPrefix(lower, "critical"),
+		strings.HasPrefix(lower, "error"),
+		strings.HasPrefix(lower, "failed"),
+		strings.Contains(lower, "exception"):
+		return logrus.ErrorLevel
+	case strings.HasPrefix(lower, "warn"):
+		return logrus.WarnLevel
+	case strings.HasPrefix(lower, "debug"):
+		return logrus.DebugLevel
+	default:
+		return logrus.InfoLevel
+	}
+}
+
+// captureOutput connects the worker's stdout and stderr to the logger.
+// It must be called before the process starts; the returned function closes
+// the parent's copies of the write ends and must be called after Start (or
+// after a failed Start) so the readers see EOF when the worker exits.
+func (w *TranslationWorker) captureOutput() (func(), error) {
+	stdoutR, stdoutW, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	stderrR, stderrW, err := os.Pipe()
+	if err != nil {
+		stdoutR.Close()
+		stdoutW.Close()
+		return nil, err
+	}
+	w.process.Stdout = stdoutW
+	w.process.Stderr = stderrW
+
+	go w.logOutput(stdoutR, "stdout")
+	go w.logOutput(stderrR, "stderr")
+
+	return func() {
+		stdoutW.Close()
+		stderrW.Close()
+	}, nil
+}
+
+// logOutput logs each line the worker writes to a stream and keeps it in
+// the worker's tail.
+func (w *TranslationWorker) logOutput(r io.ReadCloser, stream string) {
+	defer r.Close()
+
+	logger := w.logger.WithField("stream", stream)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		w.output.add(line)
+		entry := logger
+		if m := workerRequestIDPattern.FindStringSubmatch(line); m != nil {
+			entry = logger.WithField("request_id", m[1])
+		}
+		entry.Log(parseWorkerLogLevel(line), line)
+	}
+}
+
+// logFailure logs a failure attributed to the worker along with its recent
+// output, which usually holds the Python traceback, and the ID of the
+// request that failed, if known.
+func (w *TranslationWorker) logFailure(err error, msg, requestID string) {
+	w.noteError(err)
+	fields := logrus.Fields{
+		"output": w.output.String(),
+	}
+	if requestID != "" {
+		fields["request_id"] = requestID
+	}
+	w.logger.WithError(err).WithFields(fields).Warn(msg)
+}
@@ -2,9 +2,9 @@ package translate
 
 import (
 	"context"
-	"encoding/json"
+	"crypto/tls"
+	"errors"
 	"fmt"
-	"io"
 	"net"
 	"os"
 	"os/exec"
@@ -13,79 +13,321 @@ import (
 	"strings"
 	"sync"
 	"time"
+	"unicode/utf8"
 
 	"github.com/sirupsen/logrus"
+
+	"github.com/dasmlab/iskoces/pkg/tracing"
 )
 
 // WorkerPool manages a pool of Python translation workers using Unix domain sockets.
 // This provides fast, local communication without HTTP overhead.
 type WorkerPool struct {
-	engine        EngineType
-	pythonPath    string
-	scriptPath    string
-	workers       []*TranslationWorker
-	workerMu      sync.RWMutex
-	maxWorkers    int
-	socketDir     string
-	logger        *logrus.Logger
-	metrics       *MetricsCollector
-	requestQueue  chan *TranslationRequest
-	workerReady   chan *TranslationWorker
-	shutdown      chan struct{}
-	wg            sync.WaitGroup
+	engine         EngineType
+	launch         WorkerLaunch // How workers are started
+	standbyLaunch  WorkerLaunch // How standby workers are started during an upgrade
+	upgradeMu      sync.Mutex   // Serializes upgrades
+	workers        []*TranslationWorker
+	workerMu       sync.RWMutex
+	scaling        ScalingConfig
+	maxInFlight    int // Requests outstanding per worker connection
+	socketDir      string
+	readyTimeout   time.Duration // How long a new worker gets to announce itself
+	requestTimeout time.Duration // Bounds one request's round trip
+	queueTimeout   time.Duration // How long a request waits for a free worker
+	overloadAfter  time.Duration // When a waiting request is checked for overload
+	maxRSSBytes    int64         // Recycle workers above this RSS (0 = no limit)
+	maxRequests    int           // Recycle workers after this many requests (0 = no limit)
+	interactive    int           // General workers reserved for PriorityHigh requests
+	transport      string        // Worker protocol (WorkerTransportJSON or WorkerTransportGRPC)
+	sandbox        WorkerSandbox // Isolation applied to worker processes
+	remoteAddrs    []string      // Remote workers' host:port
+	remoteTLS      *tls.Config   // Mutual TLS for remote workers
+	logger         *logrus.Logger
+	metrics        *MetricsCollector
+	dispatcher     *dispatcher            // General workers
+	pinned         map[string]*dispatcher // Pinned workers by pair key
+	shutdown       chan struct{}
+	closeOnce      sync.Once
+	wg             sync.WaitGroup
+	retries        *retryBudget
+	generation     uint64         // Last worker generation started
+	restarting     map[string]int // Replacements monitor is about to start, by pair
+
+	// Longest queue wait since the last scaling decision, the average
+	// worker round trip for queue wait estimates, and the last worker failure
+	waitMu       sync.Mutex
+	maxQueueWait time.Duration
+	serviceTime  time.Duration
+	lastError    string
+	lastErrorAt  time.Time
 }
 
 // TranslationWorker represents a single Python subprocess worker.
 type TranslationWorker struct {
-	id           int
-	process      *exec.Cmd
-	socketPath   string
-	listener     net.Listener
-	conn         net.Conn
-	mu           sync.Mutex
-	busy         bool
-	lastUsed     time.Time
-	logger       *logrus.Entry // Use Entry for structured logging with fields
-	pool         *WorkerPool
+	id          int
+	generation  uint64 // Distinguishes restarts of the same ID
+	pair        string // Pinned language pair key, "" for general workers
+	interactive bool   // Reserved for PriorityHigh requests
+	standby     bool   // Started by an upgrade; takes no requests until the switch
+	state       workerState
+	process     *exec.Cmd
+	socketPath  string
+	address     string // host:port of a remote worker, "" for a local process
+	exitOnce    sync.Once
+	listener    net.Listener
+	conn        workerTransport // Connection, redialed if it fails
+	mu          sync.Mutex
+	busy        bool
+	quarantined bool           // Failed a request; out of rotation until a probe succeeds
+	inFlight    int            // Requests outstanding on conn
+	requests    int            // Requests served since start
+	exited      chan struct{}  // Closed once the process has exited
+	waitErr     error          // Process exit status, set before exited is closed
+	output      *workerLogTail // Last lines the process wrote, for error reports
+	startedAt   time.Time
+	lastUsed    time.Time
+	hello       workerHello // Ready message: version, engine, models
+	rssBytes    int64       // Resident memory at the last metrics update
+	lastError   string      // Last failure attributed to the worker
+	lastErrorAt time.Time
+	lastRequest *CrashRequest // Last request sent, for crash reports
+	killed      bool          // The pool killed the process
+	crashOnce   sync.Once
+	crash       *CrashReport // Set once the process has died unexpectedly
+	logger      *logrus.Entry // Use Entry for structured logging with fields
+	pool        *WorkerPool
 }
 
 // TranslationRequest represents a translation request sent to a worker.
+// Batch requests set Texts instead of Text. ID correlates the response on a
+// multiplexed connection; a request with Cancel set asks the worker to skip
+// the earlier request with the same ID, stopping between texts if it has
+// started. TimeoutMs is how long the caller will wait; the worker answers
+// with a timeout error rather than translating after it passes. A
+// request with Shutdown set asks the worker to finish queued requests and exit,
+// and one with Ping set is answered with an empty success by the translator.
 type TranslationRequest struct {
-	Text       string `json:"text"`
-	SourceLang string `json:"source_lang"`
-	TargetLang string `json:"target_lang"`
+	ID         uint64   `json:"id,omitempty"`
+	Cancel     bool     `json:"cancel,omitempty"`
+	Shutdown   bool     `json:"shutdown,omitempty"`
+	Ping       bool     `json:"ping,omitempty"`
+	TimeoutMs  int64    `json:"timeout_ms,omitempty"`
+	Text       string   `json:"text,omitempty"`
+	Texts      []string `json:"texts,omitempty"`
+	SourceLang string   `json:"source_lang"`
+	TargetLang string   `json:"target_lang"`
+	RequestID  string   `json:"request_id,omitempty"` // Call the request is for, logged by the worker
+}
+
+// chars returns the number of characters the request translates.
+func (r *TranslationRequest) chars() int {
+	if r.Texts != nil {
+		return totalChars(r.Texts)
+	}
+	return utf8.RuneCountInString(r.Text)
 }
 
 // TranslationResponse represents a response from a worker.
 type TranslationResponse struct {
-	Success        bool   `json:"success"`
-	TranslatedText string `json:"translated_text,omitempty"`
-	Error          string `json:"error,omitempty"`
+	ID              uint64   `json:"id,omitempty"` // ID of the request answered
+	Success         bool     `json:"success"`
+	TranslatedText  string   `json:"translated_text,omitempty"`
+	TranslatedTexts []string `json:"translated_texts,omitempty"`
+	Error           string   `json:"error,omitempty"`
+	ErrorCode       string   `json:"error_code,omitempty"` // e.g. "unsupported_language"
+}
+
+// err converts an unsuccessful response into an error, keeping the worker's
+// error code so callers can classify it.
+func (r *TranslationResponse) err(prefix string) error {
+	if r.ErrorCode == string(ErrorClassUnsupportedLanguage) {
+		return fmt.Errorf("%s: %w: %s", prefix, ErrUnsupportedLanguage, r.Error)
+	}
+	if r.ErrorCode == string(ErrorClassTimeout) {
+		return fmt.Errorf("%s: %w: %s", prefix, context.DeadlineExceeded, r.Error)
+	}
+	return fmt.Errorf("%s: %s", prefix, r.Error)
+}
+
+const (
+	// DefaultPythonPath is the interpreter used to run workers.
+	DefaultPythonPath = "python3"
+	// DefaultWorkerScriptPath is where the container image installs the worker script.
+	DefaultWorkerScriptPath = "/app/scripts/translate_worker.py"
+	// DefaultWorkerSocketDir holds the workers' Unix sockets.
+	DefaultWorkerSocketDir = "/tmp/iskoces-workers"
+	// DefaultQueueTimeout is how long a request waits for a free worker.
+	DefaultQueueTimeout = 10 * time.Second
+)
+
+// WorkerPoolOptions configures worker processes and request handling.
+// Zero fields use the defaults.
+type WorkerPoolOptions struct {
+	// PythonPath is the interpreter that runs the worker script (default: DefaultPythonPath).
+	PythonPath string
+	// ScriptPath is the worker script (default: DefaultWorkerScriptPath).
+	ScriptPath string
+	// SocketDir is created if needed and holds the workers' sockets
+	// (default: DefaultWorkerSocketDir).
+	SocketDir string
+	// MaxInFlight is how many requests may be outstanding on each worker's
+	// connection (default: DefaultMaxInFlightPerWorker).
+	MaxInFlight int
+	// RequestTimeout is the most one request's round trip to a worker may
+	// take; a sooner caller deadline shortens it (default:
+	// DefaultWorkerRequestTimeout).
+	RequestTimeout time.Duration
+	// ReadyTimeout is how long a new worker gets to announce itself ready
+	// (default: DefaultWorkerReadyTimeout).
+	ReadyTimeout time.Duration
+	// QueueTimeout is how long a request waits for a free worker (default: DefaultQueueTimeout).
+	QueueTimeout time.Duration
+	// QueueCapacity caps the requests waiting for a worker; further requests
+	// are rejected at once with an *OverloadedError (0 = unbounded).
+	QueueCapacity int
+	// OverloadThreshold is how long a request waits before it is rejected
+	// with an *OverloadedError if its estimated wait exceeds QueueTimeout
+	// (default: DefaultOverloadThreshold; negative disables early rejection).
+	OverloadThreshold time.Duration
+	// MaxRSSBytes recycles a worker whose resident memory exceeds it
+	// (0 = no limit). Argos workers grow slowly over time.
+	MaxRSSBytes int64
+	// MaxRequests recycles a worker after it has served this many requests
+	// (0 = no limit).
+	MaxRequests int
+	// Transport is the worker protocol: WorkerTransportJSON (default) or
+	// WorkerTransportGRPC.
+	Transport string
+	// InteractiveWorkers reserves general workers for PriorityHigh requests
+	// (titles, pre-flight checks). At most MinWorkers-1 are reserved, so at
+	// least one worker always serves other requests.
+	InteractiveWorkers int
+	// Sandbox isolates the worker processes (default: no isolation).
+	Sandbox WorkerSandbox
+	// RemoteWorkers are host:port addresses of workers running elsewhere
+	// (e.g. on GPU nodes), reached over TCP with RemoteTLS. They serve
+	// requests alongside the local workers.
+	RemoteWorkers []string
+	// RemoteTLS is the mutual TLS configuration for RemoteWorkers (required
+	// when there are any).
+	RemoteTLS WorkerTLS
+}
+
+// withDefaults fills zero fields.
+func (o WorkerPoolOptions) withDefaults() WorkerPoolOptions {
+	if o.PythonPath == "" {
+		o.PythonPath = DefaultPythonPath
+	}
+	if o.ScriptPath == "" {
+		o.ScriptPath = DefaultWorkerScriptPath
+	}
+	if o.SocketDir == "" {
+		o.SocketDir = DefaultWorkerSocketDir
+	}
+	if o.MaxInFlight <= 0 {
+		o.MaxInFlight = DefaultMaxInFlightPerWorker
+	}
+	if o.RequestTimeout <= 0 {
+		o.RequestTimeout = DefaultWorkerRequestTimeout
+	}
+	if o.ReadyTimeout <= 0 {
+		o.ReadyTimeout = DefaultWorkerReadyTimeout
+	}
+	if o.QueueTimeout <= 0 {
+		o.QueueTimeout = DefaultQueueTimeout
+	}
+	if o.QueueCapacity < 0 {
+		o.QueueCapacity = 0
+	}
+	if o.OverloadThreshold == 0 {
+		o.OverloadThreshold = DefaultOverloadThreshold
+	}
+	if o.MaxRSSBytes < 0 {
+		o.MaxRSSBytes = 0
+	}
+	if o.MaxRequests < 0 {
+		o.MaxRequests = 0
+	}
+	if o.Transport == "" {
+		o.Transport = WorkerTransportJSON
+	}
+	if o.InteractiveWorkers < 0 {
+		o.InteractiveWorkers = 0
+	}
+	return o
 }
 
-// NewWorkerPool creates a new worker pool for Python translation workers.
+// NewWorkerPool creates a new worker pool with a fixed number of Python translation workers.
 func NewWorkerPool(engine EngineType, maxWorkers int, logger *logrus.Logger) (*WorkerPool, error) {
+	return NewScalingWorkerPool(engine, ScalingConfig{MinWorkers: maxWorkers, MaxWorkers: maxWorkers}, DefaultMaxInFlightPerWorker, logger)
+}
+
+// NewScalingWorkerPool creates a worker pool that starts scaling.MinWorkers
+// workers and scales between MinWorkers and MaxWorkers with load. Each worker
+// accepts up to maxInFlight outstanding requests (0 = DefaultMaxInFlightPerWorker).
+func NewScalingWorkerPool(engine EngineType, scaling ScalingConfig, maxInFlight int, logger *logrus.Logger) (*WorkerPool, error) {
+	return NewWorkerPoolWithOptions(engine, scaling, WorkerPoolOptions{MaxInFlight: maxInFlight}, logger)
+}
+
+// NewWorkerPoolWithOptions creates a worker pool like NewScalingWorkerPool,
+// with worker paths, timeouts and queueing set by opts.
+func NewWorkerPoolWithOptions(engine EngineType, scaling ScalingConfig, opts WorkerPoolOptions, logger *logrus.Logger) (*WorkerPool, error) {
 	if logger == nil {
 		logger = logrus.New()
 	}
 
-	// Use /tmp for socket directory (works in Kubernetes)
-	socketDir := "/tmp/iskoces-workers"
-	if err := os.MkdirAll(socketDir, 0755); err != nil {
+	opts = opts.withDefaults()
+	if opts.Transport != WorkerTransportJSON && opts.Transport != WorkerTransportGRPC {
+		return nil, fmt.Errorf("unknown worker transport %q (supported: %s, %s)", opts.Transport, WorkerTransportJSON, WorkerTransportGRPC)
+	}
+	if err := opts.Sandbox.validate(); err != nil {
+		return nil, fmt.Errorf("worker sandbox: %w", err)
+	}
+	if err := os.MkdirAll(opts.SocketDir, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create socket directory: %w", err)
 	}
+	if err := opts.Sandbox.prepareSocketDir(opts.SocketDir); err != nil {
+		return nil, fmt.Errorf("failed to give the worker user the socket directory: %w", err)
+	}
+	if _, err := os.Stat(opts.ScriptPath); err != nil {
+		return nil, fmt.Errorf("worker script: %w", err)
+	}
+	var remoteTLS *tls.Config
+	if len(opts.RemoteWorkers) > 0 {
+		var err error
+		if remoteTLS, err = opts.RemoteTLS.config(); err != nil {
+			return nil, fmt.Errorf("remote worker TLS: %w", err)
+		}
+	}
 
 	pool := &WorkerPool{
-		engine:       engine,
-		pythonPath:   "python3",
-		scriptPath:   "/app/scripts/translate_worker.py",
-		maxWorkers:   maxWorkers,
-		socketDir:    socketDir,
-		logger:       logger,
-		metrics:      NewMetricsCollector(nil, string(engine)), // Will be set after pool creation
-		requestQueue: make(chan *TranslationRequest, 100), // Buffered queue
-		workerReady: make(chan *TranslationWorker, maxWorkers),
-		shutdown:     make(chan struct{}),
+		engine:         engine,
+		launch:         WorkerLaunch{PythonPath: opts.PythonPath, ScriptPath: opts.ScriptPath},
+		scaling:        scaling.withDefaults(),
+		maxInFlight:    opts.MaxInFlight,
+		socketDir:      opts.SocketDir,
+		readyTimeout:   opts.ReadyTimeout,
+		requestTimeout: opts.RequestTimeout,
+		queueTimeout:   opts.QueueTimeout,
+		overloadAfter:  opts.OverloadThreshold,
+		maxRSSBytes:    opts.MaxRSSBytes,
+		maxRequests:    opts.MaxRequests,
+		transport:      opts.Transport,
+		sandbox:        opts.Sandbox,
+		remoteAddrs:    opts.RemoteWorkers,
+		remoteTLS:      remoteTLS,
+		interactive:    min(opts.InteractiveWorkers, scaling.withDefaults().MinWorkers-1),
+		logger:         logger,
+		metrics:        NewMetricsCollector(nil, string(engine)), // Will be set after pool creation
+		pinned:         make(map[string]*dispatcher),
+		restarting:     make(map[string]int),
+		retries:        newRetryBudget(),
+		shutdown:       make(chan struct{}),
+	}
+	pool.dispatcher = pool.withBackpressure(newDispatcher(opts.QueueCapacity))
+	for _, pp := range pool.scaling.Pinned {
+		pool.pinned[pp.key()] = pool.withBackpressure(newDispatcher(opts.QueueCapacity))
 	}
 
 	// Set metrics pool reference
@@ -99,12 +341,30 @@ func NewWorkerPool(engine EngineType, maxWorkers int, logger *logrus.Logger) (*W
 	pool.wg.Add(1)
 	go pool.updateMetricsLoop()
 
-	// Pre-start workers
-	for i := 0; i < maxWorkers; i++ {
-		if err := pool.startWorker(i); err != nil {
+	// Scale between min and max workers with load
+	if pool.scaling.MinWorkers < pool.scaling.MaxWorkers {
+		pool.wg.Add(1)
+		go pool.autoscale()
+	}
+
+	// Pre-start the minimum number of workers, then the pinned workers
+	for i := 0; i < pool.scaling.MinWorkers; i++ {
+		if err := pool.startWorker(i, ""); err != nil {
 			logger.WithError(err).Warn("Failed to start initial worker, will retry")
 		}
 	}
+	for _, pp := range pool.scaling.Pinned {
+		for i := 0; i < pp.Workers; i++ {
+			if err := pool.startWorker(pool.nextWorkerID(), pp.key()); err != nil {
+				logger.WithError(err).WithField("pair", pp.key()).Warn("Failed to start pinned worker, will retry")
+			}
+		}
+	}
+	for _, address := range pool.remoteAddrs {
+		if err := pool.connectRemote(pool.nextWorkerID(), address); err != nil {
+			logger.WithError(err).WithField("address", address).Warn("Failed to connect to remote worker, will retry")
+		}
+	}
 
 	return pool, nil
 }
@@ -156,7 +416,11 @@ func (p *WorkerPool) updateWorkerMemory() {
 			pid := worker.process.Process.Pid
 			memoryBytes := p.getProcessMemory(pid)
 			if memoryBytes > 0 {
+				worker.mu.Lock()
+				worker.rssBytes = memoryBytes
+				worker.mu.Unlock()
 				p.metrics.UpdateWorkerMemory(worker.id, memoryBytes)
+				worker.checkMemoryLimit(memoryBytes)
 			}
 		}
 	}
@@ -189,189 +453,465 @@ func (p *WorkerPool) getProcessMemory(pid int) int64 {
 	return 0
 }
 
-// startWorker starts a new Python worker subprocess.
-func (p *WorkerPool) startWorker(id int) error {
-	p.workerMu.Lock()
-	defer p.workerMu.Unlock()
+// startWorker starts a new Python worker subprocess with the given ID,
+// pinned to pair unless pair is "". The ID is reserved while the process
+// starts; starting an ID that a live worker holds fails with errWorkerIDInUse.
+func (p *WorkerPool) startWorker(id int, pair string) error {
+	_, err := p.launchWorker(id, pair, false)
+	return err
+}
 
-	socketPath := filepath.Join(p.socketDir, fmt.Sprintf("worker-%d.sock", id))
+// launchWorker starts a worker as startWorker does and returns it once
+// ready. A standby worker is started with p.standbyLaunch and its request
+// slots are held back until an upgrade switches to it.
+func (p *WorkerPool) launchWorker(id int, pair string, standby bool) (*TranslationWorker, error) {
+	p.workerMu.Lock()
+	select {
+	case <-p.shutdown:
+		p.workerMu.Unlock()
+		return nil, errPoolClosed
+	default:
+	}
+	for _, w := range p.workers {
+		if w.id == id {
+			p.workerMu.Unlock()
+			return nil, fmt.Errorf("worker %d (generation %d, %s): %w", id, w.generation, w.currentState(), errWorkerIDInUse)
+		}
+	}
+	p.generation++
+	generation := p.generation
+	interactive := pair == "" && p.interactiveWorkers(standby) < p.interactive
+	launch := p.launch
+	if standby {
+		launch = p.standbyLaunch
+	}
 
-	// Remove old socket if it exists
+	// Each generation gets its own socket, so a replacement never races a
+	// dying process for the same path
+	socketPath := filepath.Join(p.socketDir, fmt.Sprintf("worker-%d-%d.sock", id, generation))
 	os.Remove(socketPath)
 
 	// Start Python worker with Unix socket server
 	// The Python script will listen on the socket
-	cmd := exec.Command(p.pythonPath, p.scriptPath, "--socket", socketPath)
-	cmd.Stderr = os.Stderr // Log errors to stderr
+	args := []string{launch.ScriptPath, "--socket", socketPath, "--transport", p.transport}
+	if pair != "" {
+		args = append(args, "--pin", pair)
+	}
+	args = append(args, p.sandbox.args()...)
+	cmd := exec.Command(launch.PythonPath, args...)
+	if len(launch.Env) > 0 {
+		cmd.Env = append(os.Environ(), launch.Env...)
+	}
+	p.sandbox.apply(cmd)
 
-	workerLogger := p.logger.WithField("worker_id", id)
+	workerLogger := p.logger.WithFields(logrus.Fields{
+		"worker_id":  id,
+		"generation": generation,
+	})
+	if pair != "" {
+		workerLogger = workerLogger.WithField("pair", pair)
+	}
+	if interactive {
+		workerLogger = workerLogger.WithField("interactive", true)
+	}
 	worker := &TranslationWorker{
-		id:         id,
-		process:    cmd,
-		socketPath: socketPath,
-		logger:     workerLogger,
-		pool:       p,
-		lastUsed:   time.Now(),
+		id:          id,
+		generation:  generation,
+		pair:        pair,
+		interactive: interactive,
+		standby:     standby,
+		state:       workerStarting,
+		process:     cmd,
+		socketPath:  socketPath,
+		logger:      workerLogger,
+		pool:        p,
+		startedAt:   time.Now(),
+		lastUsed:    time.Now(),
+		exited:      make(chan struct{}),
+		output:      &workerLogTail{},
 	}
+	p.workers = append(p.workers, worker)
+	p.workerMu.Unlock()
 
-	if err := cmd.Start(); err != nil {
-		return fmt.Errorf("failed to start worker %d: %w", id, err)
+	// Worker output goes to the logger with the worker's fields
+	closeOutput, err := worker.captureOutput()
+	if err != nil {
+		p.dropWorker(worker, false)
+		return nil, fmt.Errorf("failed to capture output of worker %d: %w", id, err)
 	}
-
-	// Wait a moment for socket to be created
-	time.Sleep(100 * time.Millisecond)
-
-	// Verify socket exists
-	if _, err := os.Stat(socketPath); err != nil {
+	startedAt := time.Now()
+	err = cmd.Start()
+	closeOutput()
+	if err != nil {
+		p.dropWorker(worker, false)
+		return nil, fmt.Errorf("failed to start worker %d: %w", id, err)
+	}
+	go func() {
+		worker.waitErr = cmd.Wait()
+		close(worker.exited)
+	}()
+	if err := p.sandbox.assign(cmd.Process.Pid); err != nil {
 		cmd.Process.Kill()
-		return fmt.Errorf("worker %d socket not created: %w", id, err)
+		<-worker.exited
+		p.dropWorker(worker, false)
+		os.Remove(socketPath)
+		return nil, fmt.Errorf("worker %d: %w", id, err)
 	}
 
-	p.workers = append(p.workers, worker)
-	p.workerReady <- worker
+	// The worker announces itself once it can translate (pinned workers
+	// load their model first)
+	hello, err := worker.awaitReady(p.readyTimeout)
+	if err != nil {
+		select {
+		case <-worker.exited:
+			// The process died while loading
+			err = &CrashError{Report: worker.crashReport(), Err: err}
+		default:
+			worker.kill()
+			<-worker.exited
+		}
+		p.dropWorker(worker, false)
+		os.Remove(socketPath)
+		worker.logFailure(err, "Worker did not become ready", "")
+		return nil, fmt.Errorf("worker %d did not become ready: %w", id, err)
+	}
 
-	worker.logger.Info("Worker started")
-	p.metrics.RecordWorkerStart(id)
+	worker.mu.Lock()
+	worker.hello = hello
+	worker.mu.Unlock()
+	p.metrics.RecordWorkerReady(hello.Pinned != "", time.Since(startedAt))
 
 	// Monitor worker process
 	go worker.monitor()
 
-	return nil
+	// Shutdown or a crash may have overtaken the start
+	if !worker.advance(workerReady) {
+		return nil, fmt.Errorf("worker %d stopped while starting: %w", id, errPoolClosed)
+	}
+	if !standby {
+		for i := 0; i < p.maxInFlight; i++ {
+			p.dispatcherForPair(pair).release(worker)
+		}
+	}
+
+	worker.logger.WithFields(logrus.Fields{
+		"version": hello.Version,
+		"engine":  hello.Engine,
+		"models":  len(hello.Models),
+		"standby": standby,
+	}).Info("Worker started")
+	p.metrics.RecordWorkerStart(id)
+
+	return worker, nil
 }
 
-// monitor monitors the worker process and restarts it if it dies.
+// monitor waits for the worker process to exit, takes the worker out of the
+// pool, and starts a replacement unless the worker was being stopped.
 func (w *TranslationWorker) monitor() {
-	err := w.process.Wait()
-	w.logger.WithError(err).Warn("Worker process exited")
+	<-w.exited
+	err := w.waitErr
 
-	// Mark as dead
 	w.mu.Lock()
+	previous := w.state
+	w.state = workerExited
 	w.busy = false
-	w.conn = nil
+	if w.conn != nil {
+		w.conn.close()
+		w.conn = nil
+	}
 	w.mu.Unlock()
 
+	// Workers stopped by scale-down or shutdown stay stopped, and a standby
+	// worker that dies fails its upgrade instead
+	restart := previous < workerStopping && !w.isStandby()
+	select {
+	case <-w.pool.shutdown:
+		restart = false
+	default:
+	}
+
+	// Only this generation's slots and pool entry are removed
+	w.pool.dispatcherForPair(w.pair).remove(w)
+	if w.remote() {
+		w.pool.dropWorker(w, false)
+		w.reconnect(restart)
+		return
+	}
+	w.pool.dropWorker(w, restart)
+
+	if !restart {
+		if previous < workerStopping {
+			// A standby worker or one caught by shutdown still crashed
+			w.crashReport()
+		}
+		w.logger.WithError(err).Info("Worker process exited")
+		return
+	}
+	crash := w.crashReport()
+	w.noteError(fmt.Errorf("worker %d exited unexpectedly: %v", w.id, err))
+	w.logger.WithError(err).WithFields(logrus.Fields{
+		"state":       previous.String(),
+		"crash_class": string(crash.Class),
+		"exit_code":   crash.ExitCode,
+		"signal":      crash.Signal,
+		"rss_bytes":   crash.RSSBytes,
+		"output":      w.output.String(),
+	}).Warn("Worker process exited unexpectedly, restarting")
+	defer w.pool.restartDone(w.pair)
+
 	// Record restart
 	w.pool.metrics.RecordWorkerRestart(w.id)
 
 	// Restart worker
 	time.Sleep(1 * time.Second)
-	if err := w.pool.startWorker(w.id); err != nil {
-		w.logger.WithError(err).Error("Failed to restart worker")
+	if err := w.pool.startWorker(w.id, w.pair); err != nil {
+		if errors.Is(err, errWorkerIDInUse) || errors.Is(err, errPoolClosed) {
+			w.logger.WithError(err).Info("Worker not restarted")
+			return
+		}
+		w.logger.WithError(err).Error("Failed to restart worker, the health check will retry")
 	}
 }
 
-// healthCheckWorkers checks worker health and restarts dead ones.
+// currentState returns the worker's lifecycle state.
+func (w *TranslationWorker) currentState() workerState {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.state
+}
+
+// healthCheckWorkers tops the pool back up to the minimum size and each
+// pinned pair back up to its worker count, e.g. after a dead worker failed to
+// restart, and reconnects to remote workers that are missing. Workers being
+// restarted by monitor count as present.
 func (p *WorkerPool) healthCheckWorkers() {
 	p.workerMu.RLock()
-	workers := make([]*TranslationWorker, len(p.workers))
-	copy(workers, p.workers)
+	counts := p.workerCounts()
 	p.workerMu.RUnlock()
 
-	for _, worker := range workers {
-		worker.mu.Lock()
-		processState := worker.process.ProcessState
-		worker.mu.Unlock()
+	want := map[string]int{"": p.scaling.MinWorkers}
+	for _, pp := range p.scaling.Pinned {
+		want[pp.key()] = pp.Workers
+	}
 
-		if processState != nil && processState.Exited() {
-			p.logger.WithField("worker_id", worker.id).Warn("Worker is dead, restarting")
-			// Remove from pool
-			p.workerMu.Lock()
-			for i, w := range p.workers {
-				if w.id == worker.id {
-					p.workers = append(p.workers[:i], p.workers[i+1:]...)
-					break
-				}
+	for pair, n := range want {
+		for i := counts[pair]; i < n; i++ {
+			id := p.nextWorkerID()
+			logger := p.logger.WithField("worker_id", id)
+			if pair != "" {
+				logger = logger.WithField("pair", pair)
+			}
+			logger.Warn("Worker pool below minimum size, starting worker")
+			if err := p.startWorker(id, pair); err != nil {
+				logger.WithError(err).Error("Failed to start worker")
+				break
 			}
-			p.workerMu.Unlock()
-			// Restart
-			p.startWorker(worker.id)
 		}
 	}
+	p.healthCheckRemote()
 }
 
 // Translate translates text using an available worker from the pool.
 func (p *WorkerPool) Translate(ctx context.Context, text, sourceLang, targetLang string) (string, error) {
-	startTime := time.Now()
-	requestSize := len(text)
+	resp, err := p.exchange(ctx, &TranslationRequest{
+		Text:       text,
+		SourceLang: sourceLang,
+		TargetLang: targetLang,
+	}, len(text))
+	if err != nil {
+		return "", err
+	}
+
+	if !resp.Success {
+		return "", resp.err("translation failed")
+	}
+
+	return resp.TranslatedText, nil
+}
+
+// TranslateBatch translates many texts in a single worker round trip.
+func (p *WorkerPool) TranslateBatch(ctx context.Context, texts []string, sourceLang, targetLang string) ([]string, error) {
+	if len(texts) == 0 {
+		return []string{}, nil
+	}
+
+	resp, err := p.exchange(ctx, &TranslationRequest{
+		Texts:      texts,
+		SourceLang: sourceLang,
+		TargetLang: targetLang,
+	}, totalLen(texts))
+	if err != nil {
+		return nil, err
+	}
+
+	if !resp.Success {
+		return nil, resp.err("batch translation failed")
+	}
+	if len(resp.TranslatedTexts) != len(texts) {
+		return nil, fmt.Errorf("worker returned %d translations for %d texts", len(resp.TranslatedTexts), len(texts))
+	}
+
+	return resp.TranslatedTexts, nil
+}
+
+// exchange sends a request to an available worker and reads its response.
+// If the worker fails (its connection breaks or it sends a malformed
+// response), the worker is quarantined and the request is retried once on
+// another worker, within the pool's retry budget.
+func (p *WorkerPool) exchange(ctx context.Context, req *TranslationRequest, requestSize int) (resp *TranslationResponse, err error) {
+	ctx, span := tracing.Start(ctx, "worker_pool.translate",
+		tracing.String("engine", string(p.engine)),
+		tracing.String("source_lang", req.SourceLang),
+		tracing.String("target_lang", req.TargetLang),
+		tracing.Int("request_bytes", requestSize),
+	)
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+	if req.Texts != nil {
+		span.SetAttributes(tracing.Int("texts", len(req.Texts)))
+	}
+	req.RequestID = RequestIDFromContext(ctx)
 
-	// Get available worker (with metrics)
+	// Pinned pairs are served only by their own workers
+	d := p.dispatcherFor(req.SourceLang, req.TargetLang)
+
+	resp, worker, err := p.attempt(ctx, d, req)
+	if err == nil {
+		p.retries.deposit()
+		return resp, nil
+	}
+	if worker != nil && errors.Is(err, errWorkerFault) {
+		worker.quarantine(err)
+		if ctx.Err() == nil && p.hasReadyWorker(d, worker) {
+			if !p.retries.withdraw() {
+				workerRetries.WithLabelValues(string(p.engine), "budget_exhausted").Inc()
+			} else {
+				worker.logger.WithError(err).Warn("Worker failed, retrying request on another worker")
+				span.SetAttributes(tracing.Bool("retried", true))
+				resp, _, err = p.attempt(ctx, d, req)
+				outcome := "success"
+				if err != nil {
+					outcome = "failure"
+				}
+				workerRetries.WithLabelValues(string(p.engine), outcome).Inc()
+			}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// attempt sends a request to one worker from d. It returns the worker used,
+// if one was acquired, so failures can be attributed to it.
+func (p *WorkerPool) attempt(ctx context.Context, d *dispatcher, req *TranslationRequest) (*TranslationResponse, *TranslationWorker, error) {
+	// Get available worker in priority order (with metrics)
 	waitStart := time.Now()
-	var worker *TranslationWorker
-	select {
-	case worker = <-p.workerReady:
-		// Got a worker
-		p.metrics.RecordQueueWait(time.Since(waitStart))
-	case <-ctx.Done():
-		p.metrics.RecordTranslationRequest(time.Since(startTime), false, requestSize, 0)
-		return "", ctx.Err()
-	case <-time.After(10 * time.Second):
-		p.metrics.RecordTranslationRequest(time.Since(startTime), false, requestSize, 0)
-		return "", fmt.Errorf("timeout waiting for available worker")
-	}
-
-	// Mark worker as busy
+	priority := PriorityFromContext(ctx)
+	worker, err := d.acquire(ctx, priority, p.queueTimeout)
+	if err != nil {
+		p.noteOverload(err, priority)
+		return nil, nil, err
+	}
+	p.metrics.RecordQueueWait(time.Since(waitStart))
+	p.noteQueueWait(time.Since(waitStart))
+	queueWait := time.Since(waitStart)
+
+	// Take one of the worker's request slots
 	worker.mu.Lock()
+	worker.inFlight++
 	worker.busy = true
 	worker.lastUsed = time.Now()
 	worker.mu.Unlock()
 
-	// Return worker when done
+	// Return the slot when done, unless the worker was quarantined meanwhile
+	// (it gets its free slots back when it recovers)
 	defer func() {
 		worker.mu.Lock()
-		worker.busy = false
+		worker.inFlight--
+		worker.requests++
+		worker.busy = worker.inFlight > 0
+		worker.lastUsed = time.Now()
+		worker.checkRequestLimit()
+		ready := worker.state == workerReady && !worker.quarantined
 		worker.mu.Unlock()
-		p.workerReady <- worker
+		if ready {
+			d.release(worker)
+		}
 	}()
 
-	// Connect to worker socket (with metrics)
-	socketStart := time.Now()
-	conn, err := net.DialUnix("unix", nil, &net.UnixAddr{Name: worker.socketPath, Net: "unix"})
-	socketDuration := time.Since(socketStart)
+	conn, err := worker.connection()
 	if err != nil {
-		p.metrics.RecordSocketConnection(worker.id, socketDuration, false)
-		p.metrics.RecordTranslationRequest(time.Since(startTime), false, requestSize, 0)
-		return "", fmt.Errorf("failed to connect to worker socket: %w", err)
+		return nil, worker, worker.crashError(fmt.Errorf("%w: %w: failed to connect to worker socket: %w", ErrEngineUnavailable, errWorkerFault, err))
 	}
-	defer conn.Close()
-	p.metrics.RecordSocketConnection(worker.id, socketDuration, true)
-
-	// Set timeout
-	conn.SetDeadline(time.Now().Add(5 * time.Minute))
+	worker.noteRequest(req)
 
-	// Send request
-	req := &TranslationRequest{
-		Text:       text,
-		SourceLang: sourceLang,
-		TargetLang: targetLang,
+	// Requests are multiplexed on the worker's connection; an abandoned request
+	// (e.g. the job was cancelled) is cancelled on the worker too
+	roundTripStart := time.Now()
+	rtCtx, span := tracing.StartKind(ctx, tracing.SpanKindClient, "worker.round_trip",
+		tracing.Int("worker_id", worker.id),
+		tracing.Int64("queue_wait_ms", queueWait.Milliseconds()),
+	)
+	if worker.remote() {
+		span.SetAttributes(tracing.String("worker_address", worker.address))
 	}
-
-	encoder := json.NewEncoder(conn)
-	if err := encoder.Encode(req); err != nil {
-		p.metrics.RecordTranslationRequest(time.Since(startTime), false, requestSize, 0)
-		return "", fmt.Errorf("failed to send request: %w", err)
+	resp, err := conn.roundTrip(rtCtx, req, requestTimeout(ctx, p.requestTimeout))
+	if err == nil && !resp.Success {
+		span.RecordError(errors.New(resp.Error))
 	}
-
-	// Read response
-	decoder := json.NewDecoder(conn)
-	var resp TranslationResponse
-	if err := decoder.Decode(&resp); err != nil {
-		if err == io.EOF {
-			p.metrics.RecordTranslationRequest(time.Since(startTime), false, requestSize, 0)
-			return "", fmt.Errorf("worker connection closed")
-		}
-		p.metrics.RecordTranslationRequest(time.Since(startTime), false, requestSize, 0)
-		return "", fmt.Errorf("failed to read response: %w", err)
+	span.RecordError(err)
+	span.End()
+	if err != nil {
+		return nil, worker, worker.crashError(err)
+	}
+	roundTrip := time.Since(roundTripStart)
+	p.noteServiceTime(roundTrip)
+	if !resp.Success {
+		worker.logFailure(errors.New(resp.Error), "Worker failed to translate request", req.RequestID)
+	} else {
+		p.metrics.RecordWorkerThroughput(worker.id, req.chars(), roundTrip)
+	}
+	if req.Texts != nil && resp.Success && len(resp.TranslatedTexts) != len(req.Texts) {
+		return nil, worker, fmt.Errorf("%w: %w: worker returned %d translations for %d texts", ErrEngineUnavailable, errWorkerFault, len(resp.TranslatedTexts), len(req.Texts))
 	}
 
-	responseSize := len(resp.TranslatedText)
-	success := resp.Success
-	p.metrics.RecordTranslationRequest(time.Since(startTime), success, requestSize, responseSize)
+	return resp, worker, nil
+}
+
+// connection returns the worker's multiplexed connection, dialing a new one
+// if there is none or the last one failed.
+func (w *TranslationWorker) connection() (workerTransport, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
 
-	if !success {
-		return "", fmt.Errorf("translation failed: %s", resp.Error)
+	if w.conn != nil && !w.conn.failed() {
+		return w.conn, nil
 	}
 
-	return resp.TranslatedText, nil
+	socketStart := time.Now()
+	conn, err := w.dial(workerHelloTimeout)
+	w.pool.metrics.RecordSocketConnection(w.id, time.Since(socketStart), err == nil)
+	if err != nil {
+		return nil, err
+	}
+	w.conn = conn
+	return conn, nil
+}
+
+// Size returns the number of workers currently in the pool, including pinned workers.
+func (p *WorkerPool) Size() int {
+	p.workerMu.RLock()
+	defer p.workerMu.RUnlock()
+	return len(p.workers)
+}
+
+// Bounds returns the minimum and maximum number of general (unpinned) workers.
+func (p *WorkerPool) Bounds() (min, max int) {
+	return p.scaling.MinWorkers, p.scaling.MaxWorkers
 }
 
 // CheckHealth verifies the worker pool is healthy.
@@ -391,20 +931,119 @@ func (p *WorkerPool) SupportedLanguages(ctx context.Context) ([]string, error) {
 	}, nil
 }
 
-// Close shuts down the worker pool.
+// Close shuts down the worker pool, giving requests in flight up to
+// DefaultWorkerShutdownTimeout to finish.
 func (p *WorkerPool) Close() error {
-	close(p.shutdown)
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultWorkerShutdownTimeout)
+	defer cancel()
+	return p.Shutdown(ctx)
+}
 
-	p.workerMu.Lock()
-	for _, worker := range p.workers {
-		if worker.process != nil {
-			worker.process.Process.Kill()
+// Shutdown stops the pool gracefully. New requests are refused at once,
+// requests in flight get until ctx is done to finish, and then each worker is
+// asked to exit. Workers that haven't exited within workerExitTimeout are killed.
+func (p *WorkerPool) Shutdown(ctx context.Context) error {
+	p.closeOnce.Do(func() {
+		close(p.shutdown)
+		for _, d := range p.dispatchers() {
+			d.close()
+		}
+
+		if n := p.waitIdle(ctx); n > 0 {
+			p.logger.WithFields(logrus.Fields{
+				"in_flight": n,
+			}).Warn("Worker pool shutdown timed out with requests in flight")
 		}
-		os.Remove(worker.socketPath)
-	}
-	p.workerMu.Unlock()
 
-	p.wg.Wait()
+		p.workerMu.Lock()
+		workers := make([]*TranslationWorker, len(p.workers))
+		copy(workers, p.workers)
+		p.workers = nil
+		p.workerMu.Unlock()
+
+		var stopped sync.WaitGroup
+		for _, worker := range workers {
+			stopped.Add(1)
+			go func(w *TranslationWorker) {
+				defer stopped.Done()
+				w.stop()
+			}(worker)
+		}
+		stopped.Wait()
+
+		p.wg.Wait()
+		p.logger.WithFields(logrus.Fields{
+			"workers": len(workers),
+		}).Info("Worker pool shut down")
+	})
 	return nil
 }
 
+// requestExit sends the worker a shutdown message and waits for the process
+// to exit.
+func (w *TranslationWorker) requestExit() {
+	conn, err := w.connection()
+	if err != nil {
+		return
+	}
+	if err := conn.send(&TranslationRequest{Shutdown: true}); err != nil {
+		w.logger.WithError(err).Warn("Failed to send shutdown message to worker")
+		return
+	}
+	select {
+	case <-w.exited:
+	case <-time.After(workerExitTimeout):
+		w.logger.Warn("Worker did not exit after shutdown message, killing it")
+	}
+}
+
+// waitIdle waits until no worker has requests in flight or ctx is done, and
+// returns the number of requests still in flight.
+func (p *WorkerPool) waitIdle(ctx context.Context) int {
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		inFlight := 0
+		p.workerMu.RLock()
+		for _, worker := range p.workers {
+			worker.mu.Lock()
+			inFlight += worker.inFlight
+			worker.mu.Unlock()
+		}
+		p.workerMu.RUnlock()
+
+		if inFlight == 0 {
+			return 0
+		}
+		select {
+		case <-ctx.Done():
+			return inFlight
+		case <-ticker.C:
+		}
+	}
+}
+
+// stop asks the worker process to exit and kills it if it doesn't in time.
+// A remote worker is only disconnected, since other servers may use it.
+// Requests still pending on its connection fail with errPoolClosed.
+func (w *TranslationWorker) stop() {
+	w.advance(workerStopping)
+	if !w.remote() {
+		w.requestExit()
+	}
+
+	w.mu.Lock()
+	if w.conn != nil {
+		w.conn.fail(errPoolClosed)
+		w.conn = nil
+	}
+	w.mu.Unlock()
+
+	select {
+	case <-w.exited:
+	default:
+		w.kill()
+	}
+	os.Remove(w.socketPath)
+}
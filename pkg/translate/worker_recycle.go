@@ -0,0 +1,96 @@
+package translate
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/sirupsen/logrus"
+)
+
+// Reasons a worker is recycled, used in logs and metrics.
+const (
+	recycleReasonMemory   = "memory"
+	recycleReasonRequests = "requests"
+)
+
+var workerRecycles = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "iskoces_worker_recycles_total",
+		Help: "Total number of workers replaced after reaching a limit, by reason (memory, requests)",
+	},
+	[]string{"engine", "reason"},
+)
+
+// checkRequestLimit recycles the worker once it has served the pool's
+// request limit. Remote workers aren't the pool's to recycle. Callers hold
+// w.mu.
+func (w *TranslationWorker) checkRequestLimit() {
+	if limit := w.pool.maxRequests; limit > 0 && w.requests >= limit && w.state == workerReady && !w.remote() {
+		go w.recycle(recycleReasonRequests)
+	}
+}
+
+// checkMemoryLimit recycles the worker if its RSS is over the pool's limit.
+func (w *TranslationWorker) checkMemoryLimit(rssBytes int64) {
+	if limit := w.pool.maxRSSBytes; limit > 0 && rssBytes > limit {
+		go w.recycle(recycleReasonMemory)
+	}
+}
+
+// recycle replaces a worker that reached a limit: it is taken out of
+// rotation, a replacement is started, and once its requests in flight finish
+// (or the request timeout passes) it is stopped.
+func (w *TranslationWorker) recycle(reason string) {
+	w.mu.Lock()
+	if w.state != workerReady {
+		w.mu.Unlock()
+		return
+	}
+	w.state = workerStopping
+	requests := w.requests
+	w.mu.Unlock()
+
+	p := w.pool
+	p.dispatcherForPair(w.pair).remove(w)
+	workerRecycles.WithLabelValues(string(p.engine), reason).Inc()
+	w.logger.WithFields(logrus.Fields{
+		"reason":   reason,
+		"requests": requests,
+	}).Info("Recycling worker")
+
+	// The old worker stays in the pool until it stops, so the health check
+	// doesn't start a second replacement
+	if err := p.startWorker(p.nextWorkerID(), w.pair); err != nil {
+		w.logger.WithError(err).Warn("Failed to start replacement worker, the health check will retry")
+	}
+
+	w.awaitIdle(p.requestTimeout)
+	p.dropWorker(w, false)
+	w.stop()
+}
+
+// awaitIdle waits until the worker has no requests in flight, its process
+// exits, or timeout elapses.
+func (w *TranslationWorker) awaitIdle(timeout time.Duration) {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		w.mu.Lock()
+		inFlight := w.inFlight
+		w.mu.Unlock()
+		if inFlight == 0 {
+			return
+		}
+		select {
+		case <-w.exited:
+			return
+		case <-deadline.C:
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package translate
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// errRemoteConnected is returned by connectRemote when the pool already has
+// a live connection to the address.
+var errRemoteConnected = errors.New("remote worker already connected")
+
+// WorkerTLS configures the mutual TLS used to reach remote workers: the
+// pool presents CertFile/KeyFile as its client certificate and accepts only
+// workers whose certificate is signed by CAFile.
+type WorkerTLS struct {
+	CertFile string
+	KeyFile  string
+	CAFile   string
+	// ServerName is checked against the workers' certificates (default:
+	// the host part of each worker's address).
+	ServerName string
+}
+
+// config loads the certificates into a client TLS config.
+func (t WorkerTLS) config() (*tls.Config, error) {
+	if t.CertFile == "" || t.KeyFile == "" || t.CAFile == "" {
+		return nil, errors.New("a client certificate, key and CA are required")
+	}
+	cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("client certificate: %w", err)
+	}
+	caPEM, err := os.ReadFile(t.CAFile)
+	if err != nil {
+		return nil, fmt.Errorf("CA: %w", err)
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(caPEM) {
+		return nil, fmt.Errorf("CA: no certificates in %s", t.CAFile)
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      roots,
+		ServerName:   t.ServerName,
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+// remote reports whether the worker runs elsewhere and is reached over TCP.
+func (w *TranslationWorker) remote() bool {
+	return w.address != ""
+}
+
+// connectRemote adds the remote worker at address to the pool under id once
+// it answers. A remote worker serves general requests, or a pinned pair's if
+// it reports being pinned to one of the pool's pinned pairs. The pool
+// doesn't start, scale, recycle or upgrade remote workers; it only connects
+// to them, and disconnects on shutdown without stopping them.
+func (p *WorkerPool) connectRemote(id int, address string) error {
+	worker := &TranslationWorker{
+		id:        id,
+		address:   address,
+		state:     workerStarting,
+		pool:      p,
+		startedAt: time.Now(),
+		lastUsed:  time.Now(),
+		exited:    make(chan struct{}),
+		output:    &workerLogTail{},
+	}
+	conn, err := worker.dial(workerHelloTimeout)
+	if err != nil {
+		return fmt.Errorf("remote worker %s: %w", address, err)
+	}
+	hello := conn.info()
+
+	p.workerMu.Lock()
+	select {
+	case <-p.shutdown:
+		p.workerMu.Unlock()
+		conn.close()
+		return errPoolClosed
+	default:
+	}
+	for _, w := range p.workers {
+		if w.id == id {
+			p.workerMu.Unlock()
+			conn.close()
+			return fmt.Errorf("worker %d (generation %d, %s): %w", id, w.generation, w.currentState(), errWorkerIDInUse)
+		}
+		if w.address == address && w.currentState() < workerStopping {
+			p.workerMu.Unlock()
+			conn.close()
+			return fmt.Errorf("%s: %w", address, errRemoteConnected)
+		}
+	}
+	p.generation++
+	worker.generation = p.generation
+	if _, ok := p.pinned[hello.Pinned]; ok {
+		worker.pair = hello.Pinned
+	}
+	worker.conn = conn
+	worker.hello = hello
+	worker.logger = p.logger.WithFields(logrus.Fields{
+		"worker_id":  id,
+		"generation": worker.generation,
+		"address":    address,
+	})
+	if worker.pair != "" {
+		worker.logger = worker.logger.WithField("pair", worker.pair)
+	}
+	p.workers = append(p.workers, worker)
+	p.workerMu.Unlock()
+
+	go worker.monitor()
+
+	if !worker.advance(workerReady) {
+		return fmt.Errorf("remote worker %s dropped while connecting: %w", address, errPoolClosed)
+	}
+	for i := 0; i < p.maxInFlight; i++ {
+		p.dispatcherForPair(worker.pair).release(worker)
+	}
+
+	worker.logger.WithFields(logrus.Fields{
+		"version": hello.Version,
+		"engine":  hello.Engine,
+		"models":  len(hello.Models),
+	}).Info("Remote worker connected")
+	p.metrics.RecordWorkerStart(id)
+	return nil
+}
+
+// disconnect takes a remote worker out of the pool. There is no process to
+// kill; monitor sees the worker as exited.
+func (w *TranslationWorker) disconnect() {
+	w.exitOnce.Do(func() { close(w.exited) })
+}
+
+// reconnect replaces a remote worker the pool gave up on (it failed its
+// probes) with a fresh connection under the same ID. If the worker can't be
+// reached, the health check keeps trying.
+func (w *TranslationWorker) reconnect(restart bool) {
+	if !restart {
+		w.logger.Info("Disconnected from remote worker")
+		return
+	}
+	w.noteError(fmt.Errorf("lost remote worker %d (%s)", w.id, w.address))
+	w.logger.Warn("Lost remote worker, reconnecting")
+	w.pool.metrics.RecordWorkerRestart(w.id)
+
+	time.Sleep(1 * time.Second)
+	if err := w.pool.connectRemote(w.id, w.address); err != nil {
+		if errors.Is(err, errWorkerIDInUse) || errors.Is(err, errRemoteConnected) || errors.Is(err, errPoolClosed) {
+			w.logger.WithError(err).Info("Remote worker not reconnected")
+			return
+		}
+		w.logger.WithError(err).Warn("Failed to reconnect to remote worker, the health check will retry")
+	}
+}
+
+// healthCheckRemote connects to the configured remote workers that aren't
+// in the pool, e.g. because they were unreachable at startup.
+func (p *WorkerPool) healthCheckRemote() {
+	p.workerMu.RLock()
+	connected := make(map[string]bool)
+	for _, w := range p.workers {
+		if w.remote() {
+			connected[w.address] = true
+		}
+	}
+	p.workerMu.RUnlock()
+
+	for _, address := range p.remoteAddrs {
+		if connected[address] {
+			continue
+		}
+		if err := p.connectRemote(p.nextWorkerID(), address); err != nil && !errors.Is(err, errRemoteConnected) {
+			p.logger.WithError(err).WithField("address", address).Warn("Remote worker unreachable, will retry")
+		}
+	}
+}
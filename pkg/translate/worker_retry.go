@@ -0,0 +1,186 @@
+package translate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// retryBudgetRatio is the fraction of a retry each request earns.
+	retryBudgetRatio = 0.1
+	// retryBudgetMax caps the retries that can be saved up.
+	retryBudgetMax = 10
+
+	// quarantineProbes is how many times a quarantined worker is probed
+	// before it is restarted.
+	quarantineProbes = 3
+	// quarantineProbeInterval is the delay before each probe.
+	quarantineProbeInterval = 2 * time.Second
+)
+
+// errWorkerFault marks failures caused by a worker rather than the request:
+// a broken connection or a malformed response. Such requests may be retried
+// on another worker.
+var errWorkerFault = errors.New("worker failed")
+
+var (
+	workerRetries = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "iskoces_worker_retries_total",
+			Help: "Total number of requests retried on another worker after a worker failure, by outcome (success, failure, budget_exhausted)",
+		},
+		[]string{"engine", "outcome"},
+	)
+
+	workerQuarantines = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "iskoces_worker_quarantines_total",
+			Help: "Total number of times a worker was taken out of rotation after failing a request",
+		},
+		[]string{"engine"},
+	)
+)
+
+// retryBudget limits retries to a fraction of requests, so a failing pool
+// isn't hit with a second wave of load.
+type retryBudget struct {
+	mu     sync.Mutex
+	tokens float64
+}
+
+// newRetryBudget creates a budget with the maximum number of retries saved.
+func newRetryBudget() *retryBudget {
+	return &retryBudget{tokens: retryBudgetMax}
+}
+
+// deposit credits the budget for a request that succeeded first time.
+func (b *retryBudget) deposit() {
+	b.mu.Lock()
+	b.tokens = min(b.tokens+retryBudgetRatio, retryBudgetMax)
+	b.mu.Unlock()
+}
+
+// withdraw takes one retry from the budget, reporting false if none is left.
+func (b *retryBudget) withdraw() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// hasReadyWorker reports whether a worker other than exclude can serve
+// requests from the same dispatcher.
+func (p *WorkerPool) hasReadyWorker(d *dispatcher, exclude *TranslationWorker) bool {
+	p.workerMu.RLock()
+	defer p.workerMu.RUnlock()
+	for _, w := range p.workers {
+		if w != exclude && p.dispatcherForPair(w.pair) == d && w.ready() {
+			return true
+		}
+	}
+	return false
+}
+
+// quarantine takes a worker that failed a request out of rotation and
+// starts probing it. Requests already on it are left to finish.
+func (w *TranslationWorker) quarantine(cause error) {
+	w.mu.Lock()
+	if w.state != workerReady || w.quarantined {
+		w.mu.Unlock()
+		return
+	}
+	w.quarantined = true
+	w.mu.Unlock()
+
+	w.pool.dispatcherForPair(w.pair).remove(w)
+	workerQuarantines.WithLabelValues(string(w.pool.engine)).Inc()
+	w.logFailure(cause, "Worker quarantined", "")
+
+	go w.probe()
+}
+
+// probe pings a quarantined worker and returns it to rotation once it
+// answers. A worker that doesn't recover is killed, and monitor restarts it.
+func (w *TranslationWorker) probe() {
+	for attempt := 1; attempt <= quarantineProbes; attempt++ {
+		select {
+		case <-w.exited:
+			return
+		case <-w.pool.shutdown:
+			return
+		case <-time.After(quarantineProbeInterval):
+		}
+
+		err := w.ping()
+		if err == nil {
+			w.recover()
+			return
+		}
+		w.logger.WithError(err).WithFields(logrus.Fields{
+			"attempt": attempt,
+		}).Warn("Quarantined worker failed probe")
+	}
+
+	w.logger.Error("Quarantined worker did not recover, restarting it")
+	w.kill()
+}
+
+// ping checks the worker answers a ping on a fresh connection. The fresh
+// connection replaces the worker's if that one has failed.
+func (w *TranslationWorker) ping() error {
+	conn, err := w.dial(workerHelloTimeout)
+	if err != nil {
+		return err
+	}
+
+	// The ping is queued behind any requests the worker is still translating
+	ctx, cancel := context.WithTimeout(context.Background(), w.pool.requestTimeout)
+	defer cancel()
+	resp, err := conn.roundTrip(ctx, &TranslationRequest{Ping: true}, w.pool.requestTimeout)
+	if err == nil && !resp.Success {
+		err = fmt.Errorf("ping failed: %s", resp.Error)
+	}
+	if err != nil {
+		conn.close()
+		return err
+	}
+
+	w.mu.Lock()
+	if w.conn == nil || w.conn.failed() {
+		w.conn, conn = conn, nil
+	}
+	w.mu.Unlock()
+	if conn != nil {
+		conn.close()
+	}
+	return nil
+}
+
+// recover returns a quarantined worker's free request slots to rotation.
+// Slots still in flight are returned by their requests as they finish.
+func (w *TranslationWorker) recover() {
+	w.mu.Lock()
+	if w.state != workerReady || !w.quarantined {
+		w.mu.Unlock()
+		return
+	}
+	w.quarantined = false
+	free := w.pool.maxInFlight - w.inFlight
+	w.mu.Unlock()
+
+	d := w.pool.dispatcherForPair(w.pair)
+	for i := 0; i < free; i++ {
+		d.release(w)
+	}
+	w.logger.Info("Quarantined worker recovered")
+}
@@ -0,0 +1,173 @@
+package translate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/sirupsen/logrus"
+)
+
+// WorkerLaunch is how worker processes are started. Upgrade switches the
+// pool to workers started another way.
+type WorkerLaunch struct {
+	PythonPath string
+	ScriptPath string
+	// Env is added to the server's environment for the workers, e.g.
+	// ARGOS_PACKAGES_DIR=/models/v2 to load another set of models.
+	Env []string
+}
+
+// ErrUpgradeInProgress is returned by Upgrade while another upgrade runs.
+var ErrUpgradeInProgress = errors.New("a worker upgrade is already in progress")
+
+var workerUpgrades = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "iskoces_worker_upgrades_total",
+		Help: "Total number of worker pool upgrades, by outcome (success, aborted)",
+	},
+	[]string{"engine", "outcome"},
+)
+
+// Upgrade replaces every worker without dropping availability (blue/green):
+// a standby set of workers matching the current one is started with launch
+// (empty paths keep the current ones) and health-checked; dispatch then
+// switches to it, and the old workers are stopped once their requests in
+// flight finish. If a standby worker fails to start or to answer, the
+// upgrade is aborted and the current workers keep serving. Remote workers
+// are upgraded where they run, so they are left alone.
+func (p *WorkerPool) Upgrade(ctx context.Context, launch WorkerLaunch) error {
+	if !p.upgradeMu.TryLock() {
+		return ErrUpgradeInProgress
+	}
+	defer p.upgradeMu.Unlock()
+
+	p.workerMu.Lock()
+	if launch.PythonPath == "" {
+		launch.PythonPath = p.launch.PythonPath
+	}
+	if launch.ScriptPath == "" {
+		launch.ScriptPath = p.launch.ScriptPath
+	}
+	p.standbyLaunch = launch
+	var old []*TranslationWorker
+	for _, w := range p.workers {
+		if !w.isStandby() && !w.remote() && w.currentState() < workerStopping {
+			old = append(old, w)
+		}
+	}
+	p.workerMu.Unlock()
+
+	if _, err := os.Stat(launch.ScriptPath); err != nil {
+		return fmt.Errorf("worker script: %w", err)
+	}
+
+	// The standby set has as many workers as the current one, and at least
+	// the configured counts in case workers are restarting
+	counts := map[string]int{"": p.scaling.MinWorkers}
+	for _, pp := range p.scaling.Pinned {
+		counts[pp.key()] = pp.Workers
+	}
+	current := make(map[string]int)
+	for _, w := range old {
+		current[w.pair]++
+	}
+	pairs := make([]string, 0, len(counts))
+	for pair := range counts {
+		counts[pair] = max(counts[pair], current[pair])
+		pairs = append(pairs, pair)
+	}
+	sort.Strings(pairs)
+
+	logger := p.logger.WithFields(logrus.Fields{
+		"python":      launch.PythonPath,
+		"script":      launch.ScriptPath,
+		"env":         len(launch.Env),
+		"old_workers": len(old),
+	})
+	logger.Info("Upgrading workers: starting standby workers")
+
+	var standby []*TranslationWorker
+	abort := func(err error) error {
+		for _, w := range standby {
+			w.retire()
+		}
+		workerUpgrades.WithLabelValues(string(p.engine), "aborted").Inc()
+		logger.WithError(err).Warn("Worker upgrade aborted, current workers keep serving")
+		return fmt.Errorf("worker upgrade aborted: %w", err)
+	}
+
+	for _, pair := range pairs {
+		for i := 0; i < counts[pair]; i++ {
+			if err := ctx.Err(); err != nil {
+				return abort(err)
+			}
+			w, err := p.launchWorker(p.nextWorkerID(), pair, true)
+			if err != nil {
+				return abort(err)
+			}
+			standby = append(standby, w)
+		}
+	}
+
+	// Every standby worker must still be up and answer a ping
+	for _, w := range standby {
+		if w.currentState() != workerReady {
+			return abort(fmt.Errorf("standby worker %d exited", w.id))
+		}
+		if err := w.ping(); err != nil {
+			return abort(fmt.Errorf("standby worker %d failed its health check: %w", w.id, err))
+		}
+	}
+
+	// Switch: the new workers take requests before the old ones stop taking
+	// them, so requests never wait on an empty pool
+	p.workerMu.Lock()
+	p.launch = launch
+	p.workerMu.Unlock()
+	for _, w := range standby {
+		w.mu.Lock()
+		w.standby = false
+		w.mu.Unlock()
+		for i := 0; i < p.maxInFlight; i++ {
+			p.dispatcherForPair(w.pair).release(w)
+		}
+	}
+	for _, w := range old {
+		go w.retire()
+	}
+
+	workerUpgrades.WithLabelValues(string(p.engine), "success").Inc()
+	logger.WithFields(logrus.Fields{
+		"new_workers": len(standby),
+	}).Info("Upgraded workers: switched to the new workers, draining the old ones")
+	return nil
+}
+
+// isStandby reports whether the worker belongs to an upgrade's standby set.
+func (w *TranslationWorker) isStandby() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.standby
+}
+
+// retire takes a worker out of rotation for good: its requests in flight are
+// left to finish (up to the request timeout), then it is stopped.
+func (w *TranslationWorker) retire() {
+	w.mu.Lock()
+	if w.state >= workerStopping {
+		w.mu.Unlock()
+		return
+	}
+	w.state = workerStopping
+	w.mu.Unlock()
+
+	w.pool.dispatcherForPair(w.pair).remove(w)
+	w.awaitIdle(w.pool.requestTimeout)
+	w.pool.dropWorker(w, false)
+	w.stop()
+}
@@ -24,11 +24,13 @@ fi
 # Run the container in detached mode
 # -d runs in detached mode (background)
 # -p 50051:50051 maps host port 50051 to container port 50051 (gRPC server)
+# -p 8080:8080 maps host port 8080 to container port 8080 (HTTP API and health probes)
 # -p 5000:5000 maps host port 5000 to container port 5000 (MT engine)
 # -v mounts the models volume for persistence (LibreTranslate stores in $HOME/.local/share/argos-translate)
 # --name sets the container name
 CONTAINER_ID=$(docker run -d \
     -p 50051:50051 \
+    -p 8080:8080 \
     -p 5000:5000 \
     -v "${MODEL_VOLUME}:/models" \
     -e ISKOCES_MODEL_DIR=/models \
@@ -5,118 +5,746 @@ This script runs as a subprocess and translates text using Argos Translate direc
 It listens on a Unix domain socket for requests and responds via the same socket.
 
 This eliminates HTTP overhead and allows fast local communication.
+
+Connections are persistent and requests are pipelined: each request carries an
+"id" that is echoed on its response. Queued requests are translated one at a
+time, grouped by language pair, and a {"id": N, "cancel": true} message drops a
+request the caller has given up on, stopping a batch between texts if it has
+started. A request's "timeout_ms" is how long the caller will wait; once it
+has passed the request is answered with a "timeout" error instead of being
+translated. A request's "request_id" is the ID of the server call it is for;
+failures are logged with it as request_id=ID so they can be matched with the
+server's logs. A {"shutdown": true} message makes the
+worker finish the requests it has queued and exit.
+
+With --pin source:target the worker is dedicated to one language pair: the
+model is loaded at startup and kept in memory for the life of the process.
+
+Workers sharing a package directory install models one at a time under a lock
+file there, so a package is downloaded once however many workers need it, and
+no worker loads a package another is still unpacking. A manifest next to the
+lock records finished installs and when the package index was last refreshed;
+an install interrupted by a crash is redone by the next worker to need it.
+
+Every connection starts with a ready message from the worker, sent once it can
+translate: {"ready": true, "version": ..., "engine": ..., "models": [...]}.
+
+With --transport grpc the worker instead serves the WorkerService defined in
+proto/worker.proto (messages in worker_pb2.py) on the socket; this needs the
+grpcio and protobuf packages. The request ID comes in x-request-id metadata.
+
+With --listen host:port the worker is a remote worker: it serves the same
+protocol over TCP, requiring mutual TLS (--tls-cert, --tls-key, and
+--tls-client-ca for the servers' client certificates). Servers reach it with
+-remote-workers and only disconnect from it on shutdown.
+
+The --rlimit-* options cap the worker's resources and --read-only refuses file
+writes outside the socket directory and any --writable directories, since the
+worker translates untrusted content. Both are applied before the worker
+listens, so they cover every request.
 """
 
 import sys
+import argparse
+import contextlib
+import fcntl
 import json
 import socket
+import ssl
 import os
+import queue
+import resource
+import threading
+import time
 import argostranslate.package
+import argostranslate.settings
 import argostranslate.translate
 
-def translate_text(text, source_lang, target_lang):
-    """Translate text using Argos Translate library directly."""
+class UnsupportedLanguagePair(Exception):
+    """Raised when no Argos package exists for the requested language pair."""
+
+class RequestAbandoned(Exception):
+    """Raised when the caller gave up on a request while it was being translated."""
+
+def is_installed(source_lang, target_lang):
+    """Check whether a translation for the pair is already installed."""
+    languages = {lang.code: lang for lang in argostranslate.translate.get_installed_languages()}
+    if source_lang not in languages or target_lang not in languages:
+        return False
+    return languages[source_lang].get_translation(languages[target_lang]) is not None
+
+# Worker script version, reported in the ready message
+WORKER_VERSION = "2"
+
+# Queued in place of a request to stop the translator loop
+SHUTDOWN = object()
+
+# Loaded translation for the pinned pair, reused for every request
+pinned_translation = None
+pinned_pair = None
+
+# Language pairs known to be installed, so the package directory is only
+# checked the first time a pair is used
+ready_pairs = set()
+
+# Lock and manifest shared by the workers using a package directory
+INSTALL_LOCK_NAME = '.iskoces-install.lock'
+MANIFEST_NAME = '.iskoces-manifest.json'
+
+# How long a package index refreshed by any worker is reused, in seconds
+INDEX_MAX_AGE = 3600
+
+@contextlib.contextmanager
+def install_lock():
+    """Hold the package directory's install lock, shared by all workers."""
+    directory = str(argostranslate.settings.package_data_dir)
+    os.makedirs(directory, exist_ok=True)
+    with open(os.path.join(directory, INSTALL_LOCK_NAME), 'a') as f:
+        fcntl.flock(f, fcntl.LOCK_EX)
+        try:
+            yield
+        finally:
+            fcntl.flock(f, fcntl.LOCK_UN)
+
+def manifest_path():
+    return os.path.join(str(argostranslate.settings.package_data_dir), MANIFEST_NAME)
+
+def read_manifest():
+    """Read the install manifest, empty if there is none yet."""
     try:
-        # Ensure packages are installed
-        argostranslate.package.update_package_index()
-        available_packages = argostranslate.package.get_available_packages()
-        
-        # Find and install the required translation package if needed
+        with open(manifest_path()) as f:
+            manifest = json.load(f)
+    except (OSError, ValueError):
+        manifest = {}
+    manifest.setdefault('pairs', {})
+    return manifest
+
+def write_manifest(manifest):
+    """Replace the manifest in one step, so readers never see it half written."""
+    path = manifest_path()
+    partial = f"{path}.{os.getpid()}"
+    with open(partial, 'w') as f:
+        json.dump(manifest, f, indent=2)
+    os.replace(partial, path)
+
+def refresh_index(manifest):
+    """Refresh the package index unless a worker did so recently. Call with the install lock held."""
+    fresh = time.time() - manifest.get('index_updated_at', 0) < INDEX_MAX_AGE
+    if fresh and os.path.exists(argostranslate.settings.local_package_index):
+        return
+    argostranslate.package.update_package_index()
+    manifest['index_updated_at'] = time.time()
+    write_manifest(manifest)
+
+def install_interrupted(manifest, key):
+    """Report whether an install of the pair was started and never finished."""
+    return manifest['pairs'].get(key, {}).get('state') == 'installing'
+
+def ensure_package(source_lang, target_lang):
+    """Install the Argos package for a language pair if needed.
+
+    Installs happen under the shared install lock and are recorded in the
+    manifest, so concurrent workers wait for one download rather than each
+    fetching the package.
+    """
+    if (source_lang, target_lang) in ready_pairs:
+        return
+    key = f"{source_lang}:{target_lang}"
+
+    # An install in progress is marked in the manifest before unpacking
+    # starts, so check the package directory before the manifest
+    if is_installed(source_lang, target_lang) and not install_interrupted(read_manifest(), key):
+        ready_pairs.add((source_lang, target_lang))
+        return
+
+    with install_lock():
+        # Another worker may have installed the pair while this one waited;
+        # an install still marked in progress was cut short by a crash
+        manifest = read_manifest()
+        interrupted = install_interrupted(manifest, key)
+        if is_installed(source_lang, target_lang) and not interrupted:
+            ready_pairs.add((source_lang, target_lang))
+            return
+
+        refresh_index(manifest)
         package_to_install = next(
-            (pkg for pkg in available_packages 
+            (pkg for pkg in argostranslate.package.get_available_packages()
              if pkg.from_code == source_lang and pkg.to_code == target_lang),
             None
         )
-        if package_to_install and not package_to_install.installed:
-            argostranslate.package.install_from_path(package_to_install.download())
+        if package_to_install is None:
+            raise UnsupportedLanguagePair(f"unsupported language pair: {source_lang} -> {target_lang}")
+
+        if interrupted:
+            print(f"Removing interrupted install of {source_lang} -> {target_lang}", file=sys.stderr, flush=True)
+            for pkg in argostranslate.package.get_installed_packages():
+                if pkg.from_code == source_lang and pkg.to_code == target_lang:
+                    argostranslate.package.uninstall(pkg)
+
+        print(f"Installing {source_lang} -> {target_lang} package", file=sys.stderr, flush=True)
+        manifest['pairs'][key] = {'state': 'installing', 'pid': os.getpid(), 'started_at': time.time()}
+        write_manifest(manifest)
+        argostranslate.package.install_from_path(package_to_install.download())
+        manifest['pairs'][key] = {
+            'state': 'installed',
+            'version': getattr(package_to_install, 'package_version', ''),
+            'installed_at': time.time(),
+        }
+        write_manifest(manifest)
+    ready_pairs.add((source_lang, target_lang))
+
+def load_translation(source_lang, target_lang):
+    """Load the installed translation for a pair (raises if unsupported)."""
+    ensure_package(source_lang, target_lang)
+    languages = {lang.code: lang for lang in argostranslate.translate.get_installed_languages()}
+    if source_lang not in languages or target_lang not in languages:
+        raise UnsupportedLanguagePair(f"unsupported language pair: {source_lang} -> {target_lang}")
+    translation = languages[source_lang].get_translation(languages[target_lang])
+    if translation is None:
+        raise UnsupportedLanguagePair(f"unsupported language pair: {source_lang} -> {target_lang}")
+    return translation
+
+def pin(source_lang, target_lang):
+    """Load the pinned pair's model and keep it for the life of the worker."""
+    global pinned_translation, pinned_pair
+    pinned_translation = load_translation(source_lang, target_lang)
+    # Warm the model so the first request doesn't pay for loading it
+    pinned_translation.translate("warm up")
+    pinned_pair = (source_lang, target_lang)
+
+def translate_one(text, source_lang, target_lang):
+    """Translate one text, using the pinned model when the pair matches."""
+    if pinned_translation is not None and (source_lang, target_lang) == pinned_pair:
+        return pinned_translation.translate(text)
+    return argostranslate.translate.translate(text, source_lang, target_lang)
+
+def translate_text(text, source_lang, target_lang):
+    """Translate text using Argos Translate library directly."""
+    try:
+        ensure_package(source_lang, target_lang)
         
         # Translate directly using the library
-        translated = argostranslate.translate.translate(text, source_lang, target_lang)
+        translated = translate_one(text, source_lang, target_lang)
         return translated
+    except UnsupportedLanguagePair:
+        raise
     except Exception as e:
         raise Exception(f"Translation failed: {str(e)}")
 
-def handle_request(conn):
-    """Handle a single translation request."""
+def translate_texts(texts, source_lang, target_lang, abandoned=None):
+    """Translate a batch of texts, checking the language package only once.
+
+    abandoned is checked between texts; once it returns True the rest of the
+    batch is skipped and RequestAbandoned is raised.
+    """
     try:
-        # Read request (JSON line)
-        data = conn.recv(4096)
-        if not data:
+        ensure_package(source_lang, target_lang)
+        translated = []
+        for t in texts:
+            if abandoned is not None and abandoned():
+                raise RequestAbandoned()
+            translated.append(translate_one(t, source_lang, target_lang) if t else t)
+        return translated
+    except (UnsupportedLanguagePair, RequestAbandoned):
+        raise
+    except Exception as e:
+        raise Exception(f"Batch translation failed: {str(e)}")
+
+def log_request_error(server_request_id, message):
+    """Log a request's failure, tagged with the server's request ID if it sent one."""
+    tag = f" (request_id={server_request_id})" if server_request_id else ""
+    print(f"Error translating request{tag}: {message}", file=sys.stderr, flush=True)
+
+def error_response(request_id, message, error_code=None):
+    """Build a failure response, echoing the request ID if there was one."""
+    response = {'success': False, 'error': message}
+    if error_code:
+        response['error_code'] = error_code
+    if request_id is not None:
+        response['id'] = request_id
+    return response
+
+class Connection:
+    """A persistent connection from the Go pool.
+
+    Requests carry an "id" that is echoed on the response, so several can be
+    outstanding at once and responses may be sent out of order. Requests
+    without an id (older clients) are answered in the order they arrive.
+    """
+
+    def __init__(self, conn):
+        self.conn = conn
+        self.send_lock = threading.Lock()
+        self.cancelled = set()
+        self.cancel_lock = threading.Lock()
+
+    def send(self, response):
+        data = (json.dumps(response) + '\n').encode('utf-8')
+        with self.send_lock:
+            try:
+                self.conn.sendall(data)
+            except OSError as e:
+                print(f"Error sending response: {e}", file=sys.stderr, flush=True)
+
+    def cancel(self, request_id):
+        with self.cancel_lock:
+            self.cancelled.add(request_id)
+
+    def is_cancelled(self, request_id):
+        """Report whether the client has cancelled the request."""
+        if request_id is None:
             return False
-        
-        # Parse request
-        request = json.loads(data.decode('utf-8'))
-        text = request.get('text', '')
-        source_lang = request.get('source_lang', 'en')
-        target_lang = request.get('target_lang', 'fr')
-        
-        # Translate
-        translated = translate_text(text, source_lang, target_lang)
-        
-        # Send response
-        response = {
-            'success': True,
-            'translated_text': translated
-        }
-        response_json = json.dumps(response) + '\n'
-        conn.sendall(response_json.encode('utf-8'))
-        
-        return True
-        
-    except json.JSONDecodeError as e:
-        error_response = {
-            'success': False,
-            'error': f'Invalid JSON: {str(e)}'
-        }
-        conn.sendall((json.dumps(error_response) + '\n').encode('utf-8'))
+        with self.cancel_lock:
+            return request_id in self.cancelled
+
+    def take_cancelled(self, request_id):
+        """Report (and forget) whether the request was cancelled by the client."""
+        if request_id is None:
+            return False
+        with self.cancel_lock:
+            if request_id in self.cancelled:
+                self.cancelled.discard(request_id)
+                return True
         return False
+
+def installed_models():
+    """List installed language pairs as "source:target"."""
+    models = []
+    try:
+        for lang in argostranslate.translate.get_installed_languages():
+            for translation in getattr(lang, 'translations_from', []):
+                models.append(f"{lang.code}:{translation.to_lang.code}")
     except Exception as e:
-        error_response = {
-            'success': False,
-            'error': str(e)
-        }
-        conn.sendall((json.dumps(error_response) + '\n').encode('utf-8'))
-        return False
+        print(f"Error listing installed models: {e}", file=sys.stderr, flush=True)
+    return sorted(set(models))
 
-def main():
-    """Main loop: listen on Unix socket, handle requests."""
-    if len(sys.argv) < 3 or sys.argv[1] != '--socket':
-        print("Usage: translate_worker.py --socket /path/to/socket", file=sys.stderr)
+def engine_version():
+    """Report the translation library and its version."""
+    try:
+        from importlib.metadata import version
+        return f"argostranslate {version('argostranslate')}"
+    except Exception:
+        return "argostranslate"
+
+def hello():
+    """Build the ready message sent first on every connection."""
+    message = {
+        'ready': True,
+        'version': WORKER_VERSION,
+        'engine': engine_version(),
+        'models': installed_models(),
+    }
+    if pinned_pair is not None:
+        message['pinned'] = f"{pinned_pair[0]}:{pinned_pair[1]}"
+    return message
+
+def read_requests(connection, work):
+    """Read newline-delimited JSON requests from a connection onto the work queue."""
+    buf = b''
+    try:
+        while True:
+            data = connection.conn.recv(65536)
+            if not data:
+                break
+            buf += data
+            while b'\n' in buf:
+                line, buf = buf.split(b'\n', 1)
+                if not line.strip():
+                    continue
+                try:
+                    request = json.loads(line.decode('utf-8'))
+                except json.JSONDecodeError as e:
+                    connection.send(error_response(None, f'Invalid JSON: {str(e)}'))
+                    continue
+                if request.get('cancel'):
+                    connection.cancel(request.get('id'))
+                    continue
+                if request.get('shutdown'):
+                    work.put((connection, SHUTDOWN))
+                    continue
+                if request.get('timeout_ms'):
+                    request['deadline'] = time.monotonic() + request['timeout_ms'] / 1000
+                work.put((connection, request))
+    except OSError as e:
+        print(f"Error reading requests: {e}", file=sys.stderr, flush=True)
+    finally:
+        # Let the translator finish queued requests before the socket closes
+        work.put((connection, None))
+
+def expired(request):
+    """Report whether the caller's timeout for the request has passed."""
+    deadline = request.get('deadline')
+    return deadline is not None and time.monotonic() > deadline
+
+def handle_request(connection, request):
+    """Translate a single request and send the response.
+
+    A batch stops between texts if the request is cancelled or times out.
+    Nothing is sent for a cancelled request, since the client has forgotten it.
+    """
+    request_id = request.get('id')
+    abandoned = lambda: connection.is_cancelled(request_id) or expired(request)
+    source_lang = request.get('source_lang', 'en')
+    target_lang = request.get('target_lang', 'fr')
+    try:
+        if request.get('ping'):
+            # Health probe: answered by the translator thread, so it shows
+            # requests are being processed
+            response = {'success': True}
+        elif 'texts' in request:
+            # Batch: translate each text, preserving order
+            response = {
+                'success': True,
+                'translated_texts': translate_texts(request['texts'], source_lang, target_lang, abandoned)
+            }
+        else:
+            response = {
+                'success': True,
+                'translated_text': translate_text(request.get('text', ''), source_lang, target_lang)
+            }
+        if request_id is not None:
+            response['id'] = request_id
+    except UnsupportedLanguagePair as e:
+        response = error_response(request_id, str(e), 'unsupported_language')
+    except RequestAbandoned:
+        if connection.take_cancelled(request_id):
+            return
+        response = error_response(request_id, 'deadline exceeded during translation', 'timeout')
+    except Exception as e:
+        log_request_error(request.get('request_id'), e)
+        response = error_response(request_id, str(e))
+    connection.send(response)
+
+def translate_loop(work, socket_path):
+    """Translate queued requests one at a time.
+
+    Everything already queued is drained and grouped by language pair, so a
+    burst of requests for the same pair loads its model once. Requests the
+    client cancelled while queued are skipped, and ones whose timeout passed
+    while queued are answered with a timeout error. After a shutdown message the
+    requests queued before it are finished and the process exits.
+    """
+    shutting_down = False
+    while True:
+        items = [work.get()]
+        while True:
+            try:
+                items.append(work.get_nowait())
+            except queue.Empty:
+                break
+
+        groups = {}
+        closed = []
+        for connection, request in items:
+            if request is None:
+                closed.append(connection)
+                continue
+            if request is SHUTDOWN:
+                shutting_down = True
+                continue
+            key = (request.get('source_lang', 'en'), request.get('target_lang', 'fr'))
+            groups.setdefault(key, []).append((connection, request))
+
+        for pending in groups.values():
+            for connection, request in pending:
+                request_id = request.get('id')
+                if connection.take_cancelled(request_id):
+                    continue
+                if expired(request):
+                    connection.send(error_response(request_id, 'deadline exceeded while queued', 'timeout'))
+                    continue
+                handle_request(connection, request)
+                # Forget a cancel that arrived after the response was sent
+                connection.take_cancelled(request_id)
+
+        for connection in closed:
+            connection.conn.close()
+
+        if shutting_down:
+            print("Worker shutting down", file=sys.stderr, flush=True)
+            if socket_path and os.path.exists(socket_path):
+                os.remove(socket_path)
+            os._exit(0)
+
+def serve_grpc(socket_path, args):
+    """Serve WorkerService (proto/worker.proto) on the Unix socket until shut down.
+
+    With --listen it is served on TCP instead, requiring client certificates
+    signed by --tls-client-ca.
+
+    Requests are translated one at a time; a request whose caller has gone
+    away by the time its turn comes is skipped.
+    """
+    import grpc
+    from concurrent import futures
+    import worker_pb2
+
+    translate_lock = threading.Lock()
+    stopping = threading.Event()
+
+    def hello_rpc(request, context):
+        message = hello()
+        del message['ready']
+        return worker_pb2.HelloResponse(**message)
+
+    def translate_rpc(request, context):
+        with translate_lock:
+            if not context.is_active():
+                context.abort(grpc.StatusCode.CANCELLED, 'request abandoned')
+            try:
+                if request.texts:
+                    translated = translate_texts(list(request.texts), request.source_lang, request.target_lang,
+                                                 lambda: not context.is_active())
+                    return worker_pb2.TranslateResponse(translated_texts=translated)
+                translated = translate_text(request.text, request.source_lang, request.target_lang)
+                return worker_pb2.TranslateResponse(translated_text=translated)
+            except UnsupportedLanguagePair as e:
+                context.abort(grpc.StatusCode.INVALID_ARGUMENT, str(e))
+            except RequestAbandoned:
+                context.abort(grpc.StatusCode.CANCELLED, 'request abandoned')
+            except Exception as e:
+                log_request_error(dict(context.invocation_metadata()).get('x-request-id'), e)
+                context.abort(grpc.StatusCode.INTERNAL, str(e))
+
+    def ping_rpc(request, context):
+        # Answered once requests queued before it are done
+        with translate_lock:
+            return worker_pb2.PingResponse()
+
+    def shutdown_rpc(request, context):
+        stopping.set()
+        return worker_pb2.ShutdownResponse()
+
+    def unary(handler, request_type, response_type):
+        return grpc.unary_unary_rpc_method_handler(
+            handler,
+            request_deserializer=request_type.FromString,
+            response_serializer=response_type.SerializeToString)
+
+    handlers = {
+        'Hello': unary(hello_rpc, worker_pb2.HelloRequest, worker_pb2.HelloResponse),
+        'Translate': unary(translate_rpc, worker_pb2.TranslateRequest, worker_pb2.TranslateResponse),
+        'Ping': unary(ping_rpc, worker_pb2.PingRequest, worker_pb2.PingResponse),
+        'Shutdown': unary(shutdown_rpc, worker_pb2.ShutdownRequest, worker_pb2.ShutdownResponse),
+    }
+    server = grpc.server(futures.ThreadPoolExecutor(max_workers=16))
+    server.add_generic_rpc_handlers((
+        grpc.method_handlers_generic_handler('iskoces.worker.v1.WorkerService', handlers),))
+    if args.listen:
+        with open(args.tls_key, 'rb') as f:
+            key = f.read()
+        with open(args.tls_cert, 'rb') as f:
+            cert = f.read()
+        with open(args.tls_client_ca, 'rb') as f:
+            client_ca = f.read()
+        credentials = grpc.ssl_server_credentials([(key, cert)], root_certificates=client_ca,
+                                                  require_client_auth=True)
+        server.add_secure_port(args.listen, credentials)
+        address = args.listen
+    else:
+        server.add_insecure_port('unix:' + socket_path)
+        address = socket_path
+    server.start()
+    print(f"Worker serving gRPC on {address}", file=sys.stderr, flush=True)
+
+    try:
+        stopping.wait()
+    except KeyboardInterrupt:
+        pass
+
+    # Let requests already accepted finish before exiting
+    print("Worker shutting down", file=sys.stderr, flush=True)
+    server.stop(grace=4).wait()
+    if socket_path and os.path.exists(socket_path):
+        os.remove(socket_path)
+
+def pin_from_arg(pair):
+    """Pin the worker to a "source:target" pair, exiting if it can't be loaded."""
+    source_lang, _, target_lang = pair.partition(':')
+    try:
+        pin(source_lang, target_lang)
+    except Exception as e:
+        print(f"Failed to pin {pair}: {e}", file=sys.stderr, flush=True)
         sys.exit(1)
-    
-    socket_path = sys.argv[2]
+    print(f"Worker pinned to {source_lang} -> {target_lang}", file=sys.stderr, flush=True)
+
+# Write flags that make an open() a write
+WRITE_FLAGS = os.O_WRONLY | os.O_RDWR | os.O_CREAT | os.O_APPEND | os.O_TRUNC
+
+# Audit events that modify the filesystem, with the index of their path
+# arguments
+WRITE_EVENTS = {
+    'os.remove': (0,),
+    'os.rename': (0, 1),
+    'os.mkdir': (0,),
+    'os.rmdir': (0,),
+    'os.truncate': (0,),
+    'os.chmod': (0,),
+    'os.chown': (0,),
+    'os.link': (0, 1),
+    'os.symlink': (1,),
+    'shutil.rmtree': (0,),
+}
+
+def set_rlimit(name, limit, value):
+    """Lower a resource limit, keeping a stricter existing hard limit."""
+    if not value:
+        return
+    _, hard = resource.getrlimit(limit)
+    if hard != resource.RLIM_INFINITY:
+        value = min(value, hard)
+    resource.setrlimit(limit, (value, value))
+    print(f"Worker limit {name} set to {value}", file=sys.stderr, flush=True)
+
+def install_read_only(writable):
+    """Refuse file writes outside the writable directories.
+
+    This is an audit hook, so it covers Python code (including the translation
+    libraries) but not native extensions writing directly.
+    """
+    roots = [os.path.realpath(d) for d in writable]
+
+    def allowed(path):
+        if isinstance(path, int):
+            return True  # Already-open descriptor
+        if isinstance(path, bytes):
+            path = os.fsdecode(path)
+        real = os.path.realpath(os.fspath(path))
+        return any(real == root or real.startswith(root + os.sep) for root in roots)
+
+    def hook(event, args):
+        if event == 'open':
+            path, mode, flags = args
+            writing = bool(flags & WRITE_FLAGS) or (isinstance(mode, str) and any(c in mode for c in 'wax+'))
+            if path is not None and writing and not allowed(path):
+                raise PermissionError(f"read-only worker: write to {path} refused")
+        elif event in WRITE_EVENTS:
+            for i in WRITE_EVENTS[event]:
+                if i < len(args) and not allowed(args[i]):
+                    raise PermissionError(f"read-only worker: {event} on {args[i]} refused")
+
+    sys.addaudithook(hook)
+    print(f"Worker file writes limited to {', '.join(roots)}", file=sys.stderr, flush=True)
+
+def apply_sandbox(args):
+    """Apply the resource limits and write restrictions given on the command line."""
+    set_rlimit('address space', resource.RLIMIT_AS, args.rlimit_as)
+    set_rlimit('file size', resource.RLIMIT_FSIZE, args.rlimit_fsize)
+    set_rlimit('open files', resource.RLIMIT_NOFILE, args.rlimit_nofile)
+    set_rlimit('processes', resource.RLIMIT_NPROC, args.rlimit_nproc)
+    if args.read_only:
+        writable = list(args.writable)
+        if args.socket:
+            writable.append(os.path.dirname(os.path.abspath(args.socket)))
+        install_read_only(writable)
+
+def tls_context(args):
+    """Server TLS context for --listen: the worker's certificate, with client
+    certificates required and checked against --tls-client-ca."""
+    context = ssl.SSLContext(ssl.PROTOCOL_TLS_SERVER)
+    context.minimum_version = ssl.TLSVersion.TLSv1_2
+    context.load_cert_chain(args.tls_cert, args.tls_key)
+    context.load_verify_locations(args.tls_client_ca)
+    context.verify_mode = ssl.CERT_REQUIRED
+    return context
+
+def serve_connection(conn, work, context):
+    """Complete the TLS handshake if listening on TCP, then serve the connection."""
+    if context is not None:
+        try:
+            conn = context.wrap_socket(conn, server_side=True)
+        except (ssl.SSLError, OSError) as e:
+            print(f"TLS handshake failed: {e}", file=sys.stderr, flush=True)
+            conn.close()
+            return
+    connection = Connection(conn)
+    connection.send(hello())
+    read_requests(connection, work)
+
+def main():
+    """Main loop: listen on Unix socket (or TCP with mutual TLS), handle requests."""
+    parser = argparse.ArgumentParser(description='Iskoces translation worker')
+    listen = parser.add_mutually_exclusive_group(required=True)
+    listen.add_argument('--socket', help='Unix socket path to listen on')
+    listen.add_argument('--listen', help='host:port to listen on with mutual TLS, for a remote worker')
+    parser.add_argument('--tls-cert', help="Worker's certificate for --listen")
+    parser.add_argument('--tls-key', help='Private key for --tls-cert')
+    parser.add_argument('--tls-client-ca', help='CA that signs the certificates of servers allowed to connect')
+    parser.add_argument('--pin', help='Dedicate the worker to a language pair, e.g. en:fr')
+    parser.add_argument('--transport', choices=['json', 'grpc'], default='json',
+                        help='Protocol: newline-delimited JSON or gRPC WorkerService')
+    parser.add_argument('--rlimit-as', type=int, help='Maximum address space in bytes')
+    parser.add_argument('--rlimit-fsize', type=int, help='Maximum size of a written file in bytes')
+    parser.add_argument('--rlimit-nofile', type=int, help='Maximum open file descriptors')
+    parser.add_argument('--rlimit-nproc', type=int, help="Maximum processes for the worker's user")
+    parser.add_argument('--read-only', action='store_true',
+                        help='Refuse file writes outside the socket directory and --writable directories')
+    parser.add_argument('--writable', action='append', default=[],
+                        help='Directory the worker may write to with --read-only (repeatable)')
+    args = parser.parse_args()
+    if args.listen and not (args.tls_cert and args.tls_key and args.tls_client_ca):
+        parser.error('--listen needs --tls-cert, --tls-key and --tls-client-ca')
+    apply_sandbox(args)
+
+    socket_path = args.socket
     
     # Remove old socket if it exists
-    if os.path.exists(socket_path):
+    if socket_path and os.path.exists(socket_path):
         os.remove(socket_path)
+
+    if args.transport == 'grpc':
+        # The socket appears once the model is loaded, when Hello can succeed
+        if args.pin:
+            pin_from_arg(args.pin)
+        serve_grpc(socket_path, args)
+        return
     
-    # Create Unix domain socket server
-    sock = socket.socket(socket.AF_UNIX, socket.SOCK_STREAM)
-    sock.bind(socket_path)
-    sock.listen(5)
+    context = None
+    if args.listen:
+        # Remote worker: TCP, each connection authenticated with mutual TLS
+        host, _, port = args.listen.rpartition(':')
+        context = tls_context(args)
+        sock = socket.create_server((host, int(port)))
+        address = args.listen
+    else:
+        # Create Unix domain socket server
+        sock = socket.socket(socket.AF_UNIX, socket.SOCK_STREAM)
+        sock.bind(socket_path)
+        sock.listen(5)
     
-    # Make socket readable/writable by group (for Kubernetes)
-    os.chmod(socket_path, 0660)
+        # Make socket readable/writable by group (for Kubernetes)
+        os.chmod(socket_path, 0660)
+        address = socket_path
     
-    print(f"Worker listening on {socket_path}", file=sys.stderr, flush=True)
+    print(f"Worker listening on {address}", file=sys.stderr, flush=True)
+
+    # Load the pinned model before accepting connections; the server waits
+    # for the ready message sent on accept
+    if args.pin:
+        try:
+            pin_from_arg(args.pin)
+        except SystemExit:
+            if socket_path:
+                os.remove(socket_path)
+            raise
     
-    # Accept connections and handle requests
+    # A single translator thread serves requests from every connection
+    work = queue.Queue()
+    threading.Thread(target=translate_loop, args=(work, socket_path), daemon=True).start()
+
+    # Accept persistent connections; each gets a reader thread
     while True:
         try:
             conn, addr = sock.accept()
-            # Handle request (blocking)
-            handle_request(conn)
-            conn.close()
+            threading.Thread(target=serve_connection, args=(conn, work, context), daemon=True).start()
         except KeyboardInterrupt:
             break
         except Exception as e:
-            print(f"Error handling request: {e}", file=sys.stderr, flush=True)
-            if 'conn' in locals():
-                conn.close()
-    
+            print(f"Error accepting connection: {e}", file=sys.stderr, flush=True)
+
     sock.close()
-    os.remove(socket_path)
+    if socket_path:
+        os.remove(socket_path)
 
 if __name__ == '__main__':
     main()
-